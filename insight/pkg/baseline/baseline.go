@@ -0,0 +1,104 @@
+// Package baseline 实现扫描类命令共用的基线文件：把当前的全部发现记下来，之后的运行只把
+// 基线之外的发现当作新问题。发现按 工具 + 规则 + 仓库 + 路径 + 内容哈希 识别，不含行号，
+// 文件其他地方的改动不会让已有的发现变成新发现。
+package baseline
+
+import (
+    "crypto/sha256"
+    "encoding/hex"
+    "encoding/json"
+    "os"
+    "sort"
+    "strings"
+    "time"
+
+    "kingbrain/insight/pkg/i18n"
+)
+
+// Version 是基线文件格式的版本，格式不兼容地改动时递增
+const Version = 1
+
+// Finding 是基线中的一条发现；Line 与 Text 只供人阅读，不参与识别
+type Finding struct {
+    Fingerprint string `json:"fingerprint"`
+    Rule        string `json:"rule"`
+    Repo        string `json:"repo"`
+    Path        string `json:"path"`
+    Line        int    `json:"line,omitempty"`
+    Text        string `json:"text,omitempty"`
+}
+
+// File 是一个基线文件
+type File struct {
+    Version  int       `json:"version"`
+    Tool     string    `json:"tool"`
+    Created  time.Time `json:"created"`
+    Findings []Finding `json:"findings"`
+
+    known map[string]bool
+}
+
+// Fingerprint 计算一条发现的指纹；content 为匹配行或依赖版本等会随问题修复而变化的内容，
+// 比较前去掉首尾空白
+func Fingerprint(tool, rule, repo, path, content string) string {
+    sum := sha256.Sum256([]byte(strings.Join([]string{tool, rule, repo, path, strings.TrimSpace(content)}, "\x00")))
+    return hex.EncodeToString(sum[:10])
+}
+
+// New 用当前的全部发现生成基线，Fingerprint 为空的发现由调用方保证已经填好
+func New(tool string, findings []Finding) *File {
+    f := &File{Version: Version, Tool: tool, Created: time.Now().UTC(), Findings: findings}
+    sort.SliceStable(f.Findings, func(i, j int) bool {
+        a, b := f.Findings[i], f.Findings[j]
+        if a.Repo != b.Repo {
+            return a.Repo < b.Repo
+        }
+        if a.Path != b.Path {
+            return a.Path < b.Path
+        }
+        return a.Line < b.Line
+    })
+    f.index()
+    return f
+}
+
+// Load 读取基线文件，并检查它是否由 tool 生成
+func Load(path, tool string) (*File, error) {
+    b, err := os.ReadFile(path)
+    if err != nil {
+        return nil, err
+    }
+    var f File
+    if err := json.Unmarshal(b, &f); err != nil {
+        return nil, i18n.Errorf("解析基线 %s: %w", path, err)
+    }
+    if f.Version != Version {
+        return nil, i18n.Errorf("基线 %s 的格式版本为 %d，当前只支持 %d，请重新生成", path, f.Version, Version)
+    }
+    if f.Tool != tool {
+        return nil, i18n.Errorf("基线 %s 由 %s 生成，不能用于 %s", path, f.Tool, tool)
+    }
+    f.index()
+    return &f, nil
+}
+
+// Save 写出基线文件，内容按仓库、路径排序，方便提交进版本库后审阅改动
+func (f *File) Save(path string) error {
+    b, err := json.MarshalIndent(f, "", "  ")
+    if err != nil {
+        return err
+    }
+    return os.WriteFile(path, append(b, '\n'), 0o644)
+}
+
+func (f *File) index() {
+    f.known = make(map[string]bool, len(f.Findings))
+    for _, x := range f.Findings {
+        f.known[x.Fingerprint] = true
+    }
+}
+
+// Has 判断指纹是否在基线中；f 为 nil 时总是 false
+func (f *File) Has(fingerprint string) bool {
+    return f != nil && f.known[fingerprint]
+}
@@ -0,0 +1,255 @@
+package bundle
+
+import (
+    "archive/tar"
+    "compress/gzip"
+    "encoding/json"
+    "io"
+    "os"
+    "path"
+    "path/filepath"
+    "sort"
+    "strings"
+    "time"
+
+    "kingbrain/insight/pkg/i18n"
+)
+
+// Version 是清单格式的版本，导入时拒绝更高的版本
+const Version = 1
+
+// Ext 是包文件的扩展名
+const Ext = ".kbb"
+
+// Match 是快照中的一条匹配，Line 从 1 开始
+type Match struct {
+    Repo    string `json:"repo"`
+    Path    string `json:"path"`
+    Line    int    `json:"line"`
+    Preview string `json:"preview"`
+    URL     string `json:"url,omitempty"`
+}
+
+// Query 是一个查询及其结果快照；Results 为快照在包中的路径
+type Query struct {
+    Name       string    `json:"name"`
+    Query      string    `json:"query"`
+    Pattern    string    `json:"pattern"`
+    Ran        time.Time `json:"ran"`
+    MatchCount int       `json:"matchCount"`
+    LimitHit   bool      `json:"limitHit,omitempty"`
+    Error      string    `json:"error,omitempty"`
+    Results    string    `json:"results,omitempty"`
+}
+
+// File 是拉取的文件片段；Full 为 false 时只保留匹配行附近的内容，每行带原始行号
+type File struct {
+    Repo  string `json:"repo"`
+    Rev   string `json:"rev,omitempty"`
+    Path  string `json:"path"`
+    Full  bool   `json:"full,omitempty"`
+    Lines int    `json:"lines"`
+    Entry string `json:"entry"`
+}
+
+// Report 是附带的报告（digest/changelog 等命令输出的 HTML、Markdown 或文本）
+type Report struct {
+    Name  string `json:"name"`
+    Entry string `json:"entry"`
+}
+
+// Manifest 描述包中的全部内容，位于包中的 manifest.json
+type Manifest struct {
+    Version  int       `json:"version"`
+    Created  time.Time `json:"created"`
+    Instance string    `json:"instance"`
+    Note     string    `json:"note,omitempty"`
+    Queries  []Query   `json:"queries,omitempty"`
+    Files    []File    `json:"files,omitempty"`
+    Reports  []Report  `json:"reports,omitempty"`
+}
+
+const manifestEntry = "manifest.json"
+
+// Writer 把条目写入 tar.gz 包，Close 时写入清单
+type Writer struct {
+    Manifest Manifest
+
+    f   *os.File
+    gz  *gzip.Writer
+    tw  *tar.Writer
+    now time.Time
+}
+
+// Create 创建包文件
+func Create(file string) (*Writer, error) {
+    f, err := os.Create(file)
+    if err != nil {
+        return nil, err
+    }
+    gz := gzip.NewWriter(f)
+    now := time.Now()
+    return &Writer{Manifest: Manifest{Version: Version, Created: now}, f: f, gz: gz, tw: tar.NewWriter(gz), now: now}, nil
+}
+
+// Add 写入一个条目
+func (w *Writer) Add(entry string, data []byte) error {
+    hdr := &tar.Header{Name: entry, Mode: 0o644, Size: int64(len(data)), ModTime: w.now, Typeflag: tar.TypeReg}
+    if err := w.tw.WriteHeader(hdr); err != nil {
+        return err
+    }
+    _, err := w.tw.Write(data)
+    return err
+}
+
+// AddJSON 把 v 编码为 JSON 写入一个条目
+func (w *Writer) AddJSON(entry string, v any) error {
+    b, err := json.MarshalIndent(v, "", "  ")
+    if err != nil {
+        return err
+    }
+    return w.Add(entry, b)
+}
+
+// Close 写入清单并关闭包
+func (w *Writer) Close() error {
+    err := w.AddJSON(manifestEntry, w.Manifest)
+    for _, c := range []io.Closer{w.tw, w.gz, w.f} {
+        if cerr := c.Close(); err == nil {
+            err = cerr
+        }
+    }
+    return err
+}
+
+// Abort 关闭并删除没有写完的包
+func (w *Writer) Abort() {
+    w.tw.Close()
+    w.gz.Close()
+    w.f.Close()
+    os.Remove(w.f.Name())
+}
+
+// Bundle 是读入内存的包
+type Bundle struct {
+    Manifest Manifest
+    entries  map[string][]byte
+}
+
+// Open 读取包文件并校验清单
+func Open(file string) (*Bundle, error) {
+    f, err := os.Open(file)
+    if err != nil {
+        return nil, err
+    }
+    defer f.Close()
+    gz, err := gzip.NewReader(f)
+    if err != nil {
+        return nil, i18n.Errorf("%s 不是 kb bundle 包: %w", file, err)
+    }
+    b := &Bundle{entries: map[string][]byte{}}
+    tr := tar.NewReader(gz)
+    for {
+        hdr, err := tr.Next()
+        if err == io.EOF {
+            break
+        }
+        if err != nil {
+            return nil, i18n.Errorf("读取 %s: %w", file, err)
+        }
+        if hdr.Typeflag != tar.TypeReg {
+            continue
+        }
+        data, err := io.ReadAll(tr)
+        if err != nil {
+            return nil, i18n.Errorf("读取 %s: %w", file, err)
+        }
+        b.entries[path.Clean(hdr.Name)] = data
+    }
+    raw, ok := b.entries[manifestEntry]
+    if !ok {
+        return nil, i18n.Errorf("%s 中没有 %s，不是 kb bundle 包", file, manifestEntry)
+    }
+    if err := json.Unmarshal(raw, &b.Manifest); err != nil {
+        return nil, i18n.Errorf("解析 %s 的清单: %w", file, err)
+    }
+    if b.Manifest.Version > Version {
+        return nil, i18n.Errorf("%s 的格式版本为 %d，当前的 kb 只支持到 %d，请升级", file, b.Manifest.Version, Version)
+    }
+    return b, nil
+}
+
+// Entry 返回包中条目的内容
+func (b *Bundle) Entry(entry string) ([]byte, bool) {
+    data, ok := b.entries[entry]
+    return data, ok
+}
+
+// Matches 读取查询的结果快照
+func (b *Bundle) Matches(q Query) ([]Match, error) {
+    if q.Results == "" {
+        return nil, nil
+    }
+    raw, ok := b.entries[q.Results]
+    if !ok {
+        return nil, i18n.Errorf("包中缺少 %s", q.Results)
+    }
+    var out []Match
+    if err := json.Unmarshal(raw, &out); err != nil {
+        return nil, i18n.Errorf("解析 %s: %w", q.Results, err)
+    }
+    return out, nil
+}
+
+// Dir 返回导入的包所在的目录：<用户缓存目录>/insight/bundles
+func Dir() (string, error) {
+    dir, err := os.UserCacheDir()
+    if err != nil {
+        return "", err
+    }
+    return filepath.Join(dir, "insight", "bundles"), nil
+}
+
+// Imported 列出已导入的包名，按名字排序
+func Imported() ([]string, error) {
+    dir, err := Dir()
+    if err != nil {
+        return nil, err
+    }
+    ents, err := os.ReadDir(dir)
+    if os.IsNotExist(err) {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, err
+    }
+    var names []string
+    for _, e := range ents {
+        if !e.IsDir() && strings.HasSuffix(e.Name(), Ext) {
+            names = append(names, strings.TrimSuffix(e.Name(), Ext))
+        }
+    }
+    sort.Strings(names)
+    return names, nil
+}
+
+// Slug 把查询等文字转成可用作条目名的短串
+func Slug(s string, n int) string {
+    var b strings.Builder
+    dash := false
+    for _, r := range s {
+        ok := r == '_' || r == '.' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r > 0x7f
+        switch {
+        case ok:
+            b.WriteRune(r)
+            dash = false
+        case !dash && b.Len() > 0:
+            b.WriteByte('-')
+            dash = true
+        }
+        if b.Len() >= n {
+            break
+        }
+    }
+    return strings.Trim(b.String(), "-.")
+}
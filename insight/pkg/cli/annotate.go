@@ -0,0 +1,245 @@
+package cli
+
+import (
+    "context"
+    "fmt"
+    "regexp"
+    "strings"
+
+    "github.com/spf13/cobra"
+    "go.opentelemetry.io/otel/attribute"
+    "kingbrain/insight/pkg/config"
+    "kingbrain/insight/pkg/i18n"
+    "kingbrain/insight/pkg/issues"
+    "kingbrain/insight/pkg/loc"
+    "kingbrain/insight/pkg/sg"
+    "kingbrain/insight/pkg/tracing"
+)
+
+func newAnnotateCmd() *cobra.Command {
+    var (
+        queries []string
+        pattern string
+        review  bool
+        dryRun  bool
+        sccDiff bool
+    )
+
+    cmd := &cobra.Command{
+        Use:   "annotate <owner/repo#N>",
+        Short: "对 PR 改动的文件运行查询，并把结果以评论/review 的形式回帖到 GitHub",
+        Long: `没有给 --query 时运行配置文件 annotate.queries 中的查询（name、query、pattern，与 digest.queries 相同）。
+--scc-diff（或配置 annotate.scc_diff: true）按语言附上改动文件在目标分支与 PR 分支上的代码行数变化，
+行数在 Sourcegraph 上读取文件内容后统计（与 count-loc-remote 相同的近似 scc 算法），不需要本地检出。
+
+  kb annotate acme/api#42 -q 'TODO' --dry-run
+  kb annotate acme/api#42 --review
+  kb annotate acme/api#42 --scc-diff`,
+        Args: cobra.ExactArgs(1),
+        RunE: func(cmd *cobra.Command, args []string) error {
+            cfg, err := config.Load()
+            if err != nil {
+                return err
+            }
+            var qs []config.DigestQuery
+            for _, q := range queries {
+                qs = append(qs, config.DigestQuery{Query: q})
+            }
+            if len(qs) == 0 {
+                qs = cfg.Annotate.Queries
+            }
+            if !cmd.Flags().Changed("scc-diff") {
+                sccDiff = cfg.Annotate.SccDiff
+            }
+            if len(qs) == 0 && !sccDiff {
+                return i18n.Errorf("至少需要一个 --query（或配置 annotate.queries），或者指定 --scc-diff")
+            }
+            pr, err := issues.OpenPull(args[0])
+            if err != nil {
+                return err
+            }
+            files, err := pr.Files()
+            if err != nil {
+                return err
+            }
+            if len(files) == 0 {
+                fmt.Println(i18n.T("PR 没有可检查的文件"))
+                return nil
+            }
+
+            c := sg.New()
+            summary, comments, err := annotatePull(cmd.Context(), c, pr, files, qs, pattern)
+            if err != nil {
+                return err
+            }
+            if sccDiff {
+                table, err := annotateSccDiff(cmd.Context(), c, pr, files)
+                if err != nil {
+                    return err
+                }
+                if summary != "" && !strings.HasSuffix(summary, "\n\n") {
+                    summary += "\n"
+                }
+                summary += table
+            }
+            if dryRun {
+                fmt.Println(summary)
+                for _, c := range comments {
+                    fmt.Printf("%s:%d\n  %s\n", c.Path, c.Line, c.Body)
+                }
+                return nil
+            }
+            if review {
+                err = pr.Review(summary, comments)
+            } else {
+                err = pr.Comment(summary)
+            }
+            if err != nil {
+                return err
+            }
+            fmt.Print(i18n.Sprintf("已回帖到 %s#%d（%d 条行内评论）\n", pr.Repo, pr.Number, len(comments)))
+            return nil
+        },
+    }
+
+    cmd.Flags().StringArrayVarP(&queries, "query", "q", nil, "要运行的查询（可重复），会自动限定到 PR 仓库与改动文件；默认取配置 annotate.queries")
+    cmd.Flags().StringVarP(&pattern, "pattern", "p", "literal", "搜索模式：literal|regexp|structural（配置中的查询可单独指定）")
+    cmd.Flags().BoolVar(&sccDiff, "scc-diff", false, "附上改动文件按语言的代码行数变化（默认取配置 annotate.scc_diff）")
+    cmd.Flags().BoolVar(&review, "review", false, "以 review 形式提交，并在改动行上挂逐行评论")
+    cmd.Flags().BoolVar(&dryRun, "dry-run", false, "只打印将要回帖的内容")
+    return cmd
+}
+
+// annotatePull 逐个查询并生成 Markdown 摘要与行内评论（只保留落在 diff 新增行上的匹配）；
+// 查询没有单独指定 pattern 时用 pattern，已删除的文件不参与查询
+func annotatePull(ctx context.Context, c *sg.Client, pr *issues.PullRequest, files []issues.PullFile, queries []config.DigestQuery, pattern string) (string, []issues.ReviewComment, error) {
+    changed := map[string]issues.PullFile{}
+    paths := make([]string, 0, len(files))
+    for _, f := range files {
+        if f.Status == "removed" {
+            continue
+        }
+        changed[f.Path] = f
+        paths = append(paths, regexp.QuoteMeta(f.Path))
+    }
+    if len(queries) == 0 || len(paths) == 0 {
+        return "", nil, nil
+    }
+    scope := buildQuery(
+        fmt.Sprintf("repo:^%s$@%s", regexp.QuoteMeta(pr.Repo), pr.HeadRef),
+        "file:^(?:"+strings.Join(paths, "|")+")$",
+    )
+
+    var b strings.Builder
+    var comments []issues.ReviewComment
+    b.WriteString(i18n.T("### insight 检查结果\n\n"))
+    for _, dq := range queries {
+        q, p := dq.Query, dq.Pattern
+        if p == "" {
+            p = pattern
+        }
+        label := q
+        if dq.Name != "" {
+            label = dq.Name
+        }
+        qctx, span := tracing.Start(ctx, "annotate.query", attribute.String("sg.query", q))
+        res, err := c.Search(qctx, buildQuery(scope, q), p)
+        tracing.End(span, err)
+        if err != nil {
+            return "", nil, fmt.Errorf("%s: %w", label, err)
+        }
+        n := 0
+        var lines []string
+        for _, fm := range res.Results {
+            for _, lm := range fm.LineMatches {
+                line := lm.LineNumber + 1
+                n++
+                lines = append(lines, fmt.Sprintf("- `%s:%d` %s", fm.File.Path, line, strings.TrimSpace(lm.Preview)))
+                if changed[fm.File.Path].Lines[line] {
+                    comments = append(comments, issues.ReviewComment{
+                        Path: fm.File.Path,
+                        Line: line,
+                        Body: i18n.Sprintf("insight: 命中查询 `%s`", label),
+                    })
+                }
+            }
+        }
+        mark := "✅"
+        if n > 0 {
+            mark = "⚠️"
+        }
+        fmt.Fprint(&b, i18n.Sprintf("%s `%s`：%d 处匹配\n", mark, label, n))
+        if n > 0 {
+            b.WriteString("\n" + strings.Join(lines, "\n") + "\n\n")
+        }
+    }
+    return b.String(), comments, nil
+}
+
+// annotateSccDiff 统计改动文件在 BaseRef 与 HeadRef 上按语言的代码行数，生成 Markdown 表格；
+// 新增的文件只读 head，删除的只读 base，重命名的在 base 上读旧路径
+func annotateSccDiff(ctx context.Context, c *sg.Client, pr *issues.PullRequest, files []issues.PullFile) (string, error) {
+    var specs []sg.FileSpec
+    var base []bool // 与 specs 一一对应，true 表示读的是 BaseRef
+    for _, f := range files {
+        old := f.Path
+        if f.Previous != "" {
+            old = f.Previous
+        }
+        if f.Status != "added" && loc.Detect(old) != nil {
+            specs = append(specs, sg.FileSpec{Repo: pr.Repo, Rev: pr.BaseRef, Path: old})
+            base = append(base, true)
+        }
+        if f.Status != "removed" && loc.Detect(f.Path) != nil {
+            specs = append(specs, sg.FileSpec{Repo: pr.Repo, Rev: pr.HeadRef, Path: f.Path})
+            base = append(base, false)
+        }
+    }
+
+    before, after := loc.Summary{}, loc.Summary{}
+    for i, r := range c.GetFiles(ctx, specs) {
+        if r.Err != nil {
+            return "", i18n.Errorf("读取 %s@%s: %w", r.Path, r.Rev, r.Err)
+        }
+        content := []byte(r.Content)
+        if len(content) > locMaxFileSize || loc.Binary(content) {
+            continue
+        }
+        sum := after
+        if base[i] {
+            sum = before
+        }
+        sum.Add(loc.Count(loc.Detect(r.Path), content))
+    }
+
+    var b strings.Builder
+    b.WriteString(i18n.T("### 代码行数变化\n\n"))
+    if len(before) == 0 && len(after) == 0 {
+        b.WriteString(i18n.T("改动的文件中没有可统计的源码\n"))
+        return b.String(), nil
+    }
+    b.WriteString(i18n.T("| 语言 | 文件（前→后） | 代码行（前→后） | 变化 |\n|---|---|---|---|\n"))
+    merged := loc.Summary{}
+    for _, s := range []loc.Summary{before, after} {
+        for _, t := range s {
+            merged.Add(*t)
+        }
+    }
+    var total [2]loc.Stats
+    for _, l := range merged.Sorted() {
+        var o, n loc.Stats
+        if t := before[l.Name]; t != nil {
+            o = *t
+        }
+        if t := after[l.Name]; t != nil {
+            n = *t
+        }
+        fmt.Fprintf(&b, "| %s | %d → %d | %d → %d | %+d |\n", l.Name, o.Count, n.Count, o.Code, n.Code, n.Code-o.Code)
+        total[0].Count, total[0].Code = total[0].Count+o.Count, total[0].Code+o.Code
+        total[1].Count, total[1].Code = total[1].Count+n.Count, total[1].Code+n.Code
+    }
+    fmt.Fprint(&b, i18n.Sprintf("| 合计 | %d → %d | %d → %d | %+d |\n", total[0].Count, total[1].Count, total[0].Code, total[1].Code, total[1].Code-total[0].Code))
+    return b.String(), nil
+}
+
+func init() { rootCmd.AddCommand(newAnnotateCmd()) }
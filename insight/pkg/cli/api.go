@@ -0,0 +1,122 @@
+package cli
+
+import (
+    "bytes"
+    "encoding/json"
+    "fmt"
+    "io"
+    "os"
+    "strings"
+
+    "github.com/spf13/cobra"
+    "kingbrain/insight/pkg/i18n"
+    "kingbrain/insight/pkg/sg"
+)
+
+func newAPICmd() *cobra.Command {
+    var (
+        vars   string
+        fields []string
+        raw    bool
+    )
+
+    cmd := &cobra.Command{
+        Use:   "api [query-file|-]",
+        Short: "发送原始 GraphQL 查询并打印响应（子命令还没覆盖的 API 的兜底入口）",
+        Long: `从文件或 stdin（不给参数或为 "-"）读取 GraphQL 文档，沿用 SG_URL/LOCAL_SG_ENDPOINT 的认证与故障切换。
+变量用 --vars 传 JSON 对象（@path 表示从文件读取），或用 -F key=value 逐个指定，
+value 是合法 JSON 时按 JSON 解析（数字、布尔、对象），否则作为字符串。
+
+  echo 'query { currentUser { username } }' | kb api
+  kb api repo.graphql -F name=github.com/acme/api -F first=10`,
+        Args: cobra.MaximumNArgs(1),
+        RunE: func(cmd *cobra.Command, args []string) error {
+            src := "-"
+            if len(args) > 0 {
+                src = args[0]
+            }
+            doc, err := readAPIDocument(src)
+            if err != nil {
+                return err
+            }
+            v, err := apiVariables(vars, fields)
+            if err != nil {
+                return err
+            }
+            var resp json.RawMessage
+            if err := sg.New().GraphQL(cmd.Context(), doc, v, &resp); err != nil {
+                return err
+            }
+            out := []byte(resp)
+            if !raw {
+                var buf bytes.Buffer
+                if json.Indent(&buf, resp, "", "  ") == nil {
+                    out = buf.Bytes()
+                }
+            }
+            fmt.Println(string(out))
+            var check struct {
+                Errors []json.RawMessage `json:"errors"`
+            }
+            if json.Unmarshal(resp, &check) == nil && len(check.Errors) > 0 {
+                return i18n.Errorf("响应包含 %d 个 GraphQL 错误", len(check.Errors))
+            }
+            return nil
+        },
+    }
+
+    cmd.Flags().StringVar(&vars, "vars", "", "变量，JSON 对象；@path 表示从文件读取")
+    cmd.Flags().StringArrayVarP(&fields, "field", "F", nil, "单个变量 key=value（可重复，覆盖 --vars 中的同名变量）")
+    cmd.Flags().BoolVar(&raw, "raw", false, "原样输出响应，不格式化")
+    return cmd
+}
+
+func readAPIDocument(src string) (string, error) {
+    var b []byte
+    var err error
+    if src == "-" {
+        b, err = io.ReadAll(os.Stdin)
+    } else {
+        b, err = os.ReadFile(src)
+    }
+    if err != nil {
+        return "", err
+    }
+    doc := strings.TrimSpace(string(b))
+    if doc == "" {
+        return "", i18n.Errorf("GraphQL 文档为空")
+    }
+    return doc, nil
+}
+
+// apiVariables 合并 --vars 与 -F 指定的变量
+func apiVariables(vars string, fields []string) (map[string]any, error) {
+    v := map[string]any{}
+    if vars != "" {
+        b := []byte(vars)
+        if strings.HasPrefix(vars, "@") {
+            var err error
+            if b, err = os.ReadFile(vars[1:]); err != nil {
+                return nil, err
+            }
+        }
+        if err := json.Unmarshal(b, &v); err != nil {
+            return nil, i18n.Errorf("--vars 不是 JSON 对象: %w", err)
+        }
+    }
+    for _, f := range fields {
+        key, val, ok := strings.Cut(f, "=")
+        if !ok || key == "" {
+            return nil, i18n.Errorf("-F 格式应为 key=value：%q", f)
+        }
+        var parsed any
+        if json.Unmarshal([]byte(val), &parsed) == nil {
+            v[key] = parsed
+        } else {
+            v[key] = val
+        }
+    }
+    return v, nil
+}
+
+func init() { rootCmd.AddCommand(newAPICmd()) }
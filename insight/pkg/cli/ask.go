@@ -0,0 +1,281 @@
+package cli
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "os"
+    "path/filepath"
+    "regexp"
+    "strings"
+    "time"
+
+    "github.com/spf13/cobra"
+    "kingbrain/insight/pkg/i18n"
+    "kingbrain/insight/pkg/llm"
+    "kingbrain/insight/pkg/sg"
+)
+
+const askPrompt = `你是代码库问答助手，通过 Sourcegraph 搜索回答用户关于代码的问题。
+每一轮只回复一个 JSON 对象，不要有其他文字，可用的动作：
+
+  {"action": "search", "query": "<Sourcegraph 查询>", "pattern": "literal|regexp|structural", "reason": "<为什么这样搜>"}
+  {"action": "read", "repo": "<仓库名>", "path": "<文件路径>", "start": <起始行>, "end": <结束行>}
+  {"action": "answer", "answer": "<答案>"}
+
+查询可以使用 repo:、file:、lang:、type:symbol 等过滤器；结果以 repo/path:line 开头。
+先用宽泛的查询定位，再用 read 查看关键代码。答案用中文，每个结论都要以 repo/path:line 的形式注明出处，
+只引用在搜索或阅读结果中出现过的位置；找不到依据时直接说明。`
+
+// askStep 是 transcript 中的一条记录
+type askStep struct {
+    Time   time.Time `json:"time"`
+    Step   int       `json:"step"`
+    Role   string    `json:"role"` // model|tool|error
+    Action string    `json:"action,omitempty"`
+    Text   string    `json:"text"`
+    Tokens int       `json:"tokens,omitempty"` // 本次请求的估算输入 token 数
+}
+
+// askAction 是模型在一轮中给出的动作
+type askAction struct {
+    Action  string `json:"action"`
+    Query   string `json:"query"`
+    Pattern string `json:"pattern"`
+    Reason  string `json:"reason"`
+    Repo    string `json:"repo"`
+    Path    string `json:"path"`
+    Start   int    `json:"start"`
+    End     int    `json:"end"`
+    Answer  string `json:"answer"`
+}
+
+var citeRe = regexp.MustCompile(`([\w.-]+(?:/[\w.-]+)+):(\d+)(?:-(\d+))?`)
+
+func newAskCmd() *cobra.Command {
+    var (
+        maxSteps   int
+        maxTokens  int
+        maxResults int
+        transcript string
+        verbose    bool
+    )
+
+    cmd := &cobra.Command{
+        Use:   "ask <question>",
+        Short: "让模型自行规划并执行 Sourcegraph 搜索，综合结果回答问题并给出出处链接",
+        Long: `模型每一轮可以发起一次搜索或读取一段文件，看到结果后决定下一步，最后给出带 repo/path:line 出处的答案。
+受 --max-steps 与 --max-tokens（所有请求的估算输入 token 合计）限制，用尽前最后一轮会要求模型直接作答。
+发送给模型的搜索结果与文件内容会先按 llm.redact 与内置规则脱敏。
+每次运行的完整过程记录在 transcript 文件中（默认 <用户缓存目录>/insight/ask/<时间>.jsonl）。
+
+  kb ask "payments-api 的重试策略是怎么配置的"
+  kb ask "哪些服务还在用 v1 的鉴权中间件" --max-steps 12 -v`,
+        Args: cobra.MinimumNArgs(1),
+        RunE: func(cmd *cobra.Command, args []string) error {
+            s, err := newLLMSession()
+            if err != nil {
+                return err
+            }
+            if transcript == "" {
+                dir, err := os.UserCacheDir()
+                if err != nil {
+                    return err
+                }
+                transcript = filepath.Join(dir, "insight", "ask", time.Now().Format("20060102-150405.000")+".jsonl")
+            }
+            if err := os.MkdirAll(filepath.Dir(transcript), 0o755); err != nil {
+                return err
+            }
+            f, err := os.Create(transcript)
+            if err != nil {
+                return err
+            }
+            defer f.Close()
+
+            a := &asker{s: s, c: sg.New(), log: f, verbose: verbose, maxResults: maxResults, seen: map[string][2]string{}}
+            answer, err := a.run(cmd.Context(), strings.Join(args, " "), maxSteps, maxTokens)
+            fmt.Fprint(os.Stderr, i18n.Sprintf("过程记录: %s\n", transcript))
+            if err != nil {
+                return err
+            }
+            fmt.Println(answer)
+            if refs := a.citations(answer); len(refs) > 0 {
+                fmt.Println(i18n.T("\n出处:"))
+                for _, r := range refs {
+                    fmt.Println("  " + r)
+                }
+            }
+            return nil
+        },
+    }
+
+    cmd.Flags().IntVar(&maxSteps, "max-steps", 8, "最多的模型调用轮数")
+    cmd.Flags().IntVar(&maxTokens, "max-tokens", 60000, "所有请求的估算输入 token 合计上限")
+    cmd.Flags().IntVar(&maxResults, "results", 30, "每次搜索返回给模型的最多匹配行数")
+    cmd.Flags().StringVar(&transcript, "transcript", "", "过程记录（JSON Lines）的保存路径")
+    cmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "在 stderr 显示每一轮的动作")
+    return cmd
+}
+
+type asker struct {
+    s          *llmSession
+    c          *sg.Client
+    log        *os.File
+    verbose    bool
+    maxResults int
+    seen       map[string][2]string // 出现过的 repo/path → {repo, path}，用于校验答案中的引用
+    step       int
+}
+
+func (a *asker) record(st askStep) {
+    st.Time, st.Step = time.Now().UTC(), a.step
+    b, _ := json.Marshal(st)
+    a.log.Write(append(b, '\n'))
+}
+
+// run 执行 模型 → 工具 → 模型 的循环，直到模型作答或预算用尽
+func (a *asker) run(ctx context.Context, question string, maxSteps, maxTokens int) (string, error) {
+    msgs := []llm.Message{
+        {Role: "system", Content: askPrompt},
+        {Role: "user", Content: "问题: " + a.s.redact(question)},
+    }
+    used := 0
+    for a.step = 1; a.step <= maxSteps; a.step++ {
+        cost := 0
+        for _, m := range msgs {
+            cost += llm.EstimateTokens(m.Content)
+        }
+        last := a.step == maxSteps || used+cost*2 > maxTokens
+        if last {
+            msgs = append(msgs, llm.Message{Role: "user", Content: `预算即将用尽，这是最后一轮：请根据已有结果直接回复 {"action": "answer", ...}。`})
+            cost += 40
+        }
+        if used+cost > maxTokens {
+            return "", i18n.Errorf("超出 token 预算（已用约 %d，上限 %d），未得到答案", used, maxTokens)
+        }
+        used += cost
+        reply, err := a.s.client.Chat(ctx, msgs, a.s.maxOut)
+        if err != nil {
+            a.record(askStep{Role: "error", Text: err.Error(), Tokens: cost})
+            return "", err
+        }
+        msgs = append(msgs, llm.Message{Role: "assistant", Content: reply})
+        act, perr := parseAskAction(reply)
+        a.record(askStep{Role: "model", Action: act.Action, Text: reply, Tokens: cost})
+        if perr != nil {
+            if last {
+                // 最后一轮仍不是 JSON 时把原文当作答案
+                return reply, nil
+            }
+            msgs = append(msgs, llm.Message{Role: "user", Content: "无法解析你的回复：" + perr.Error() + "。请只回复一个 JSON 对象。"})
+            continue
+        }
+        if act.Action == "answer" {
+            return act.Answer, nil
+        }
+        if last {
+            return "", i18n.Errorf("已到最后一轮，模型仍在请求 %s，未得到答案", act.Action)
+        }
+        result := a.s.redact(a.execute(ctx, act))
+        a.record(askStep{Role: "tool", Action: act.Action, Text: result})
+        msgs = append(msgs, llm.Message{Role: "user", Content: "结果:\n" + result})
+    }
+    return "", i18n.Errorf("在 %d 轮内未得到答案", maxSteps)
+}
+
+// parseAskAction 取出回复中的第一个 JSON 对象（模型有时会包在 ``` 代码块里或附带说明）
+func parseAskAction(reply string) (askAction, error) {
+    var act askAction
+    i, j := strings.Index(reply, "{"), strings.LastIndex(reply, "}")
+    if i < 0 || j < i {
+        return act, i18n.Errorf("没有 JSON 对象")
+    }
+    if err := json.Unmarshal([]byte(reply[i:j+1]), &act); err != nil {
+        return act, err
+    }
+    switch act.Action {
+    case "search", "read", "answer":
+        return act, nil
+    }
+    return act, i18n.Errorf("未知动作 %q", act.Action)
+}
+
+// execute 执行一个工具动作，错误也作为结果返回给模型，让它调整查询
+func (a *asker) execute(ctx context.Context, act askAction) string {
+    switch act.Action {
+    case "search":
+        if a.verbose {
+            fmt.Fprint(os.Stderr, i18n.Sprintf("[%d] 搜索 %s（%s）\n", a.step, act.Query, act.Reason))
+        }
+        pattern := act.Pattern
+        if pattern == "" {
+            pattern = "literal"
+        }
+        res, err := a.c.Search(ctx, act.Query, pattern)
+        if err != nil {
+            return "搜索失败: " + err.Error()
+        }
+        var b strings.Builder
+        fmt.Fprint(&b, i18n.Sprintf("共 %d 处匹配", res.MatchCount))
+        n := 0
+        for _, fm := range res.Results {
+            a.seen[fm.Repository.Name+"/"+fm.File.Path] = [2]string{fm.Repository.Name, fm.File.Path}
+            for _, m := range fm.LineMatches {
+                if n == a.maxResults {
+                    fmt.Fprint(&b, i18n.Sprintf("\n（只列出前 %d 行）", n))
+                    return b.String()
+                }
+                fmt.Fprintf(&b, "\n%s/%s:%d: %s", fm.Repository.Name, fm.File.Path, m.LineNumber+1, strings.TrimSpace(m.Preview))
+                n++
+            }
+        }
+        return b.String()
+    case "read":
+        if a.verbose {
+            fmt.Fprint(os.Stderr, i18n.Sprintf("[%d] 读取 %s/%s:%d-%d\n", a.step, act.Repo, act.Path, act.Start, act.End))
+        }
+        files := a.c.GetFiles(ctx, []sg.FileSpec{{Repo: act.Repo, Path: act.Path}})
+        if files[0].Err != nil {
+            return "读取失败: " + files[0].Err.Error()
+        }
+        a.seen[act.Repo+"/"+act.Path] = [2]string{act.Repo, act.Path}
+        lines := strings.Split(files[0].Content, "\n")
+        start, end := max(act.Start, 1), act.End
+        if end < start || end > len(lines) {
+            end = len(lines)
+        }
+        end = min(end, start+199) // 每次最多 200 行
+        var b strings.Builder
+        for i := start; i <= end && i <= len(lines); i++ {
+            fmt.Fprintf(&b, "%s/%s:%d: %s\n", act.Repo, act.Path, i, lines[i-1])
+        }
+        return b.String()
+    }
+    return "未知动作"
+}
+
+// citations 把答案中出现过的 repo/path:line 引用转成链接；没在结果里出现过的路径标注为未核实
+func (a *asker) citations(answer string) []string {
+    var out []string
+    dup := map[string]bool{}
+    for _, m := range citeRe.FindAllStringSubmatch(answer, -1) {
+        if dup[m[0]] {
+            continue
+        }
+        dup[m[0]] = true
+        loc, ok := a.seen[m[1]]
+        if !ok {
+            out = append(out, m[0]+"（未在结果中出现，未核实）")
+            continue
+        }
+        lines := m[2]
+        if m[3] != "" {
+            lines += "-" + m[3]
+        }
+        out = append(out, fmt.Sprintf("%s  %s", m[0], blobURL(a.c, loc[0], "", loc[1], lines)))
+    }
+    return out
+}
+
+func init() { rootCmd.AddCommand(newAskCmd()) }
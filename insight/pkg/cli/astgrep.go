@@ -0,0 +1,182 @@
+package cli
+
+import (
+    "bytes"
+    "fmt"
+    "io/fs"
+    "os"
+    "path/filepath"
+    "strings"
+
+    "github.com/spf13/cobra"
+    "kingbrain/insight/pkg/i18n"
+    "kingbrain/insight/pkg/sg"
+    "kingbrain/insight/pkg/structural"
+)
+
+// 超过该大小的本地文件不参与匹配
+const astGrepMaxFile = 2 << 20
+
+// langExts 是 --lang 可选的语言及其扩展名
+var langExts = map[string][]string{
+    "go":     {".go"},
+    "python": {".py"},
+    "js":     {".js", ".jsx", ".mjs", ".cjs"},
+    "ts":     {".ts", ".tsx"},
+    "java":   {".java"},
+    "kotlin": {".kt", ".kts"},
+    "c":      {".c", ".h"},
+    "cpp":    {".cc", ".cpp", ".cxx", ".hpp", ".hh"},
+    "csharp": {".cs"},
+    "rust":   {".rs"},
+    "ruby":   {".rb"},
+    "php":    {".php"},
+}
+
+func newAstGrepCmd() *cobra.Command {
+    var (
+        langs  []string
+        globs  []string
+        format string
+        nul    bool
+    )
+
+    cmd := &cobra.Command{
+        Use:   "ast-grep <pattern> [dir...]",
+        Short: "在本地目录上做结构化搜索（与远程 -p structural 相同的洞语法），适合未提交的代码",
+        Long: `模式语法：:[name] 匹配括号平衡的任意文本（可跨行），:[[name]] 只匹配标识符，
+... 是匿名洞，同名洞必须匹配相同文本，模式中的空白匹配任意空白。例如：
+
+  kb ast-grep 'if err != nil { return :[e] }' --lang go ./pkg
+  kb ast-grep 'fetch(:[url], ...)' --lang ts -f paths -0 | xargs -0 sed -i ...`,
+        Args: cobra.MinimumNArgs(1),
+        RunE: func(cmd *cobra.Command, args []string) error {
+            out, err := newMatchPrinter(format, nul, false)
+            if err != nil {
+                return err
+            }
+            pat, err := structural.Compile(args[0])
+            if err != nil {
+                return err
+            }
+            var exts []string
+            for _, l := range langs {
+                e, ok := langExts[strings.ToLower(l)]
+                if !ok {
+                    return i18n.Errorf("不支持的语言 %q", l)
+                }
+                exts = append(exts, e...)
+            }
+            dirs := args[1:]
+            if len(dirs) == 0 {
+                dirs = []string{"."}
+            }
+            total := 0
+            for _, dir := range dirs {
+                res, err := astGrepDir(pat, dir, exts, globs)
+                if err != nil {
+                    return err
+                }
+                total += res.MatchCount
+                if err := out.print(cmd.Context(), nil, "", "", res); err != nil {
+                    return err
+                }
+            }
+            if out.text() {
+                fmt.Printf("Total matches: %d\n", total)
+            }
+            return nil
+        },
+    }
+
+    cmd.Flags().StringSliceVarP(&langs, "lang", "l", nil, "只搜索这些语言的文件（可重复）：go|python|js|ts|java|kotlin|c|cpp|csharp|rust|ruby|php")
+    cmd.Flags().StringSliceVarP(&globs, "glob", "g", nil, "只搜索文件名匹配这些 glob 的文件（可重复），如 '*_test.go'")
+    cmd.Flags().StringVarP(&format, "format", "f", "", "输出格式：text|lines|json|paths（默认终端为 text，管道为 lines）")
+    cmd.Flags().BoolVarP(&nul, "null", "0", false, "只输出文件路径，以 NUL 分隔（配合 xargs -0）")
+    return cmd
+}
+
+// astGrepDir 遍历目录（跳过隐藏目录、node_modules、vendor 与二进制文件），
+// 结果组织成与远程搜索相同的结构，仓库名取 git remote（不是 git 仓库时为目录名）
+func astGrepDir(pat *structural.Pattern, dir string, exts, globs []string) (*sg.SearchResults, error) {
+    root, err := filepath.Abs(dir)
+    if err != nil {
+        return nil, err
+    }
+    repo := filepath.Base(root)
+    if r, err := gitRoot(root); err == nil {
+        if name, err := localRepoName(r); err == nil {
+            repo = name
+        }
+    }
+    res := &sg.SearchResults{}
+    err = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+        if err != nil {
+            return nil
+        }
+        if d.IsDir() {
+            if p != root && (strings.HasPrefix(d.Name(), ".") || d.Name() == "node_modules" || d.Name() == "vendor") {
+                return filepath.SkipDir
+            }
+            return nil
+        }
+        if !wantFile(d.Name(), exts, globs) {
+            return nil
+        }
+        if info, err := d.Info(); err != nil || info.Size() > astGrepMaxFile {
+            return nil
+        }
+        b, err := os.ReadFile(p)
+        if err != nil || bytes.IndexByte(b[:min(len(b), 8000)], 0) >= 0 {
+            return nil
+        }
+        src := string(b)
+        matches := pat.FindAll(src)
+        if len(matches) == 0 {
+            return nil
+        }
+        rel, _ := filepath.Rel(root, p)
+        fm := sg.FileMatch{Repository: sg.Repository{Name: repo}, File: sg.File{Path: filepath.ToSlash(rel)}}
+        for _, m := range matches {
+            // 预览取匹配起始行的整行；行号与远程结果一样从 0 开始
+            start := strings.LastIndexByte(src[:m.Start], '\n') + 1
+            end := strings.IndexByte(src[m.Start:], '\n')
+            if end < 0 {
+                end = len(src)
+            } else {
+                end += m.Start
+            }
+            fm.LineMatches = append(fm.LineMatches, sg.LineMatch{Preview: src[start:end], LineNumber: m.Line - 1})
+        }
+        res.MatchCount += len(matches)
+        res.Results = append(res.Results, fm)
+        return nil
+    })
+    return res, err
+}
+
+func wantFile(name string, exts, globs []string) bool {
+    if len(exts) > 0 {
+        ok := false
+        for _, e := range exts {
+            if strings.HasSuffix(name, e) {
+                ok = true
+                break
+            }
+        }
+        if !ok {
+            return false
+        }
+    }
+    if len(globs) == 0 {
+        return true
+    }
+    for _, g := range globs {
+        if ok, _ := filepath.Match(g, name); ok {
+            return true
+        }
+    }
+    return false
+}
+
+func init() { rootCmd.AddCommand(newAstGrepCmd()) }
@@ -0,0 +1,443 @@
+package cli
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "os"
+    "path/filepath"
+    "sort"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/spf13/cobra"
+    "kingbrain/insight/pkg/baseline"
+    "kingbrain/insight/pkg/codeowners"
+    "kingbrain/insight/pkg/config"
+    "kingbrain/insight/pkg/falsepos"
+    "kingbrain/insight/pkg/i18n"
+    "kingbrain/insight/pkg/preview"
+    "kingbrain/insight/pkg/progress"
+    "kingbrain/insight/pkg/sg"
+)
+
+// 没有 CODEOWNERS 或没有匹配规则的文件归到这个团队
+const unowned = "(unowned)"
+
+// AuditTeamScore 是一个团队在一次 audit 中的得分
+type AuditTeamScore struct {
+    Team       string         `json:"team"`
+    Violations int            `json:"violations"`
+    ByRule     map[string]int `json:"byRule"`
+    Code       int            `json:"code,omitempty"`    // 已统计到的代码行数（只含有本地检出的仓库）
+    PerKLOC    float64        `json:"perKloc,omitempty"` // Code 为 0 时不计算
+    Previous   *int           `json:"previous,omitempty"`
+    Delta      int            `json:"delta"`
+}
+
+// AuditViolation 是一处违规；ID 供 mark-fp 标记误报，路径匹配没有行号（Line 为 0）；
+// Baselined 为 --baseline 中已有的违规
+type AuditViolation struct {
+    ID        string `json:"id"`
+    Rule      string `json:"rule"`
+    Team      string `json:"team"`
+    Repo      string `json:"repo"`
+    Path      string `json:"path"`
+    Line      int    `json:"line,omitempty"`
+    Preview   string `json:"preview,omitempty"`
+    Baselined bool   `json:"baselined,omitempty"`
+}
+
+// AuditReport 是 audit -f json 的输出，也是保存的快照；Excluded 为按误报标记排除的匹配数
+type AuditReport struct {
+    Name        string           `json:"name"`
+    Taken       time.Time        `json:"taken"`
+    Baseline    *time.Time       `json:"baseline,omitempty"`
+    Rules       []string         `json:"rules"`
+    FailedRules []string         `json:"failedRules,omitempty"`
+    Teams       []AuditTeamScore `json:"teams"`
+    Excluded    int              `json:"excluded,omitempty"`
+    Violations  []AuditViolation `json:"violations,omitempty"`
+}
+
+func newAuditCmd() *cobra.Command {
+    var (
+        pattern  string
+        parallel int
+        format   string
+        name     string
+        noLOC    bool
+        noSave   bool
+        matches  bool
+        bl       baselineFlags
+        issue    issueOptions
+    )
+
+    cmd := &cobra.Command{
+        Use:   "audit <rules-file|->",
+        Short: "按规则查询统计违规，结合 CODEOWNERS 生成各团队的记分卡（每 KLOC 违规数、与上次对比）",
+        Long: `规则文件与 batch 相同：每行一条查询，可写成 名称<TAB>查询，每处匹配算一次违规。
+违规按所在仓库的 CODEOWNERS 归属到团队（取第一个所有者），代码行数用 scc 在本地检出上
+按文件统计后同样按 CODEOWNERS 归属。每次运行的结果保存在用户缓存目录，下次运行时作为对比基线。
+用 kb mark-fp <ID> 标记为误报的匹配（--matches 列出每处违规的 ID）不计入违规数。
+--baseline 时记分卡仍按全部违规计算，但只列出基线之外的新违规，有新违规时以退出码 1 结束。
+--create-issues 为（基线之外的）违规建 issue，选项同 todos；--issue-per rule 时每条规则一个。
+
+  kb audit rules.tsv
+  kb audit rules.tsv --matches
+  kb audit rules.tsv --write-baseline audit-baseline.json
+  kb audit rules.tsv --baseline audit-baseline.json --matches
+  kb audit rules.tsv -f json > scorecard.json
+  kb audit rules.tsv --create-issues --issue-per rule --issue-repo github.com/acme/tracker`,
+        Args: cobra.ExactArgs(1),
+        RunE: func(cmd *cobra.Command, args []string) error {
+            if err := checkFormat(format, "text", "json"); err != nil {
+                return err
+            }
+            rules, err := readBatchFile(args[0])
+            if err != nil {
+                return err
+            }
+            if len(rules) == 0 {
+                return i18n.Errorf("%s 中没有规则", args[0])
+            }
+            if name == "" {
+                name = "stdin"
+                if args[0] != "-" {
+                    name = strings.TrimSuffix(filepath.Base(args[0]), filepath.Ext(args[0]))
+                }
+            }
+            cfg, err := config.Load()
+            if err != nil {
+                return err
+            }
+            known, err := bl.load("audit")
+            if err != nil {
+                return err
+            }
+            fp, err := falsepos.Load()
+            if err != nil {
+                fmt.Fprint(os.Stderr, i18n.Sprintf("警告: 读取误报标记失败，本次不排除误报: %v\n", err))
+                fp = nil
+            }
+            c := sg.New()
+            report, err := runAudit(cmd.Context(), c, cfg.Workspace, name, rules, pattern, parallel, !noLOC, fp)
+            if err != nil {
+                return err
+            }
+            base, err := loadAuditBaseline(name)
+            if err != nil {
+                fmt.Fprint(os.Stderr, i18n.Sprintf("警告: 读取上次结果失败: %v\n", err))
+            }
+            applyAuditBaseline(report, base)
+            var findings []baseline.Finding
+            fresh := 0
+            for i := range report.Violations {
+                f := auditFinding(report.Violations[i])
+                findings = append(findings, f)
+                if known.Has(f.Fingerprint) {
+                    report.Violations[i].Baselined = true
+                } else {
+                    fresh++
+                }
+            }
+            if err := bl.save("audit", findings); err != nil {
+                return err
+            }
+            if !noSave {
+                if err := saveAuditReport(report); err != nil {
+                    fmt.Fprint(os.Stderr, i18n.Sprintf("警告: 保存本次结果失败: %v\n", err))
+                }
+            }
+            if format == "json" {
+                if err := writeJSON(os.Stdout, report); err != nil {
+                    return err
+                }
+            } else {
+                printAudit(report, matches)
+            }
+            if err := createIssues(issue, auditIssueFindings(c, report)); err != nil {
+                return err
+            }
+            return bl.failOnNew(cmd, fresh, len(findings)-fresh)
+        },
+    }
+
+    cmd.Flags().StringVarP(&pattern, "pattern", "p", "literal", "搜索模式：literal|regexp|structural")
+    cmd.Flags().IntVarP(&parallel, "parallel", "j", 4, "并发查询数")
+    cmd.Flags().StringVarP(&format, "format", "f", "text", "输出格式：text|json")
+    cmd.Flags().StringVar(&name, "name", "", "记分卡名称，决定与哪次历史结果对比（默认取规则文件名）")
+    cmd.Flags().BoolVar(&noLOC, "no-loc", false, "不统计代码行数（不需要本地检出与 scc），只输出违规数")
+    cmd.Flags().BoolVar(&noSave, "no-save", false, "不保存本次结果")
+    cmd.Flags().BoolVar(&matches, "matches", false, "text 格式下按规则列出每处违规及其 ID（供 mark-fp 使用）")
+    addBaselineFlags(cmd, &bl)
+    addIssueFlags(cmd, &issue)
+    return cmd
+}
+
+// runAudit 执行全部规则并按团队汇总；部分规则失败只告警，全部失败才报错。
+// fp 中标记过的误报不计入违规（fp 可为 nil）
+func runAudit(ctx context.Context, c *sg.Client, ws config.Workspace, name string, rules []batchQuery, pattern string, parallel int, withLOC bool, fp *falsepos.Store) (*AuditReport, error) {
+    report := &AuditReport{Name: name, Taken: time.Now().UTC()}
+    results := runBatch(ctx, c, rules, pattern, parallel, nil, nil)
+
+    owners := map[string]*codeowners.File{}
+    teamOf := func(repo, path string) string {
+        f, ok := owners[repo]
+        if !ok {
+            f = loadCodeowners(ctx, c, repo)
+            owners[repo] = f
+        }
+        if f != nil {
+            if o := f.Owners(path); len(o) > 0 {
+                return o[0]
+            }
+        }
+        return unowned
+    }
+
+    teams := map[string]*AuditTeamScore{}
+    team := func(t string) *AuditTeamScore {
+        if teams[t] == nil {
+            teams[t] = &AuditTeamScore{Team: t, ByRule: map[string]int{}}
+        }
+        return teams[t]
+    }
+    for _, r := range results {
+        report.Rules = append(report.Rules, r.Name)
+        if r.Error != "" {
+            fmt.Fprintf(os.Stderr, "✗ %s: %s\n", r.Name, r.Error)
+            report.FailedRules = append(report.FailedRules, r.Name)
+            continue
+        }
+        for _, fm := range r.Results {
+            repo, path := fm.Repository.Name, fm.File.Path
+            lines := fm.LineMatches
+            if len(lines) == 0 {
+                lines = []sg.LineMatch{{LineNumber: -1}} // 路径匹配没有行
+            }
+            t := teamOf(repo, path)
+            for _, m := range lines {
+                if fp != nil && fp.Excluded(r.Name, repo, path, m.Preview) {
+                    report.Excluded++
+                    continue
+                }
+                s := team(t)
+                s.Violations++
+                s.ByRule[r.Name]++
+                report.Violations = append(report.Violations, AuditViolation{
+                    ID: falsepos.MatchID(r.Name, repo, path, m.Preview), Rule: r.Name, Team: t,
+                    Repo: repo, Path: path, Line: m.LineNumber + 1, Preview: m.Preview,
+                })
+            }
+        }
+    }
+    if len(report.FailedRules) == len(results) {
+        return nil, i18n.Errorf("所有规则均查询失败")
+    }
+
+    if withLOC {
+        var missing []string
+        repos := make([]string, 0, len(owners))
+        for repo := range owners {
+            repos = append(repos, repo)
+        }
+        sort.Strings(repos)
+        // 每个检出都要跑一遍 scc，仓库多时很慢，给出进度；没有检出的仓库不算失败，最后汇总提示
+        bar := progress.New(len(repos))
+        for _, repo := range repos {
+            bar.Begin(repo)
+            dir, err := findCheckout(ws, repo)
+            if err != nil {
+                missing = append(missing, repo)
+                bar.End(repo, nil)
+                continue
+            }
+            files, err := sccFiles(dir)
+            if err != nil {
+                bar.End(repo, err)
+                continue
+            }
+            bar.End(repo, nil)
+            for path, code := range files {
+                if !scopeAllows(repo, path) {
+                    continue
+                }
+                team(teamOf(repo, path)).Code += code
+            }
+        }
+        bar.Finish()
+        if len(missing) > 0 {
+            fmt.Fprint(os.Stderr, i18n.Sprintf("警告: %d 个仓库没有本地检出，未计入代码行数: %s\n", len(missing), strings.Join(missing, ", ")))
+        }
+    }
+
+    for _, s := range teams {
+        if s.Code > 0 {
+            s.PerKLOC = float64(s.Violations) / (float64(s.Code) / 1000)
+        }
+        report.Teams = append(report.Teams, *s)
+    }
+    sort.Slice(report.Teams, func(i, j int) bool {
+        a, b := report.Teams[i], report.Teams[j]
+        if a.PerKLOC != b.PerKLOC {
+            return a.PerKLOC > b.PerKLOC
+        }
+        if a.Violations != b.Violations {
+            return a.Violations > b.Violations
+        }
+        return a.Team < b.Team
+    })
+    return report, nil
+}
+
+// loadCodeowners 按 GitHub 的查找顺序读取仓库的 CODEOWNERS，都没有时返回 nil
+func loadCodeowners(ctx context.Context, c *sg.Client, repo string) *codeowners.File {
+    for _, p := range codeowners.Candidates {
+        if content, err := c.FileContent(ctx, repo, "", p); err == nil {
+            return codeowners.Parse(content)
+        }
+    }
+    return nil
+}
+
+// applyAuditBaseline 填入上次的违规数与变化；上次没有出现的团队 Previous 为 nil
+func applyAuditBaseline(r, base *AuditReport) {
+    if base == nil {
+        return
+    }
+    r.Baseline = &base.Taken
+    prev := map[string]int{}
+    for _, t := range base.Teams {
+        prev[t.Team] = t.Violations
+    }
+    for i := range r.Teams {
+        if v, ok := prev[r.Teams[i].Team]; ok {
+            r.Teams[i].Previous = &v
+            r.Teams[i].Delta = r.Teams[i].Violations - v
+        } else {
+            r.Teams[i].Delta = r.Teams[i].Violations
+        }
+    }
+}
+
+func printAudit(r *AuditReport, matches bool) {
+    if r.Baseline != nil {
+        fmt.Print(i18n.Sprintf("%s：%d 条规则，对比 %s\n\n", r.Name, len(r.Rules), r.Baseline.Local().Format("2006-01-02 15:04")))
+    } else {
+        fmt.Print(i18n.Sprintf("%s：%d 条规则，首次运行，暂无对比\n\n", r.Name, len(r.Rules)))
+    }
+    fmt.Printf("%s %s %8s %s %s %s\n", preview.Pad(i18n.T("团队"), 32), preview.PadLeft(i18n.T("违规"), 8), "KLOC",
+        preview.PadLeft(i18n.T("每KLOC"), 8), preview.PadLeft(i18n.T("上次"), 8), preview.PadLeft(i18n.T("变化"), 6))
+    for _, t := range r.Teams {
+        kloc, per, prev, delta := "-", "-", "-", "-"
+        if t.Code > 0 {
+            kloc, per = fmt.Sprintf("%.1f", float64(t.Code)/1000), fmt.Sprintf("%.2f", t.PerKLOC)
+        }
+        if t.Previous != nil {
+            prev = fmt.Sprint(*t.Previous)
+        }
+        if r.Baseline != nil {
+            delta = fmt.Sprintf("%+d", t.Delta)
+        }
+        fmt.Printf("%s %8d %8s %8s %8s %6s\n", preview.Pad(t.Team, 32), t.Violations, kloc, per, prev, delta)
+    }
+    if r.Excluded > 0 {
+        fmt.Print(i18n.Sprintf("\n已排除 %d 处标记为误报的匹配（kb mark-fp list 查看）\n", r.Excluded))
+    }
+    if !matches {
+        return
+    }
+    rule := ""
+    for _, v := range r.Violations {
+        if v.Baselined {
+            continue
+        }
+        if v.Rule != rule {
+            rule = v.Rule
+            fmt.Printf("\n== %s ==\n", rule)
+        }
+        loc := v.Repo + "/" + v.Path
+        if v.Line > 0 {
+            loc += fmt.Sprintf(":%d", v.Line)
+        }
+        fmt.Printf("  %s  %s  %s\n", v.ID, loc, preview.Render(strings.TrimSpace(v.Preview)))
+    }
+}
+
+// auditIssueFindings 把基线之外的违规换成建 issue 用的发现，规则名作为 Rule（--issue-per rule 按规则分组）
+func auditIssueFindings(c *sg.Client, r *AuditReport) []Finding {
+    var out []Finding
+    for _, v := range r.Violations {
+        if v.Baselined {
+            continue
+        }
+        line := ""
+        if v.Line > 0 {
+            line = strconv.Itoa(v.Line)
+        }
+        out = append(out, Finding{Repo: v.Repo, Path: v.Path, Line: v.Line, Rule: v.Rule,
+            Text: strings.TrimSpace(v.Preview), URL: blobURL(c, v.Repo, "", v.Path, line)})
+    }
+    return out
+}
+
+// auditFinding 把违规换成基线中的发现，按匹配行内容识别
+func auditFinding(v AuditViolation) baseline.Finding {
+    return baseline.Finding{
+        Fingerprint: baseline.Fingerprint("audit", v.Rule, v.Repo, v.Path, v.Preview),
+        Rule:        v.Rule,
+        Repo:        v.Repo,
+        Path:        v.Path,
+        Line:        v.Line,
+        Text:        strings.TrimSpace(v.Preview),
+    }
+}
+
+func auditDir(name string) (string, error) {
+    dir, err := os.UserCacheDir()
+    if err != nil {
+        return "", err
+    }
+    return filepath.Join(dir, "insight", "audit", name), nil
+}
+
+// loadAuditBaseline 取同名记分卡最近一次保存的结果，没有时返回 nil
+func loadAuditBaseline(name string) (*AuditReport, error) {
+    dir, err := auditDir(name)
+    if err != nil {
+        return nil, err
+    }
+    names, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+    if len(names) == 0 {
+        return nil, nil
+    }
+    sort.Strings(names) // 文件名即时间戳
+    b, err := os.ReadFile(names[len(names)-1])
+    if err != nil {
+        return nil, err
+    }
+    var r AuditReport
+    if err := json.Unmarshal(b, &r); err != nil {
+        return nil, fmt.Errorf("%s: %w", names[len(names)-1], err)
+    }
+    return &r, nil
+}
+
+func saveAuditReport(r *AuditReport) error {
+    dir, err := auditDir(r.Name)
+    if err != nil {
+        return err
+    }
+    if err := os.MkdirAll(dir, 0o755); err != nil {
+        return err
+    }
+    b, err := json.Marshal(r)
+    if err != nil {
+        return err
+    }
+    return os.WriteFile(filepath.Join(dir, r.Taken.Format("20060102-150405")+".json"), b, 0o644)
+}
+
+func init() { rootCmd.AddCommand(newAuditCmd()) }
@@ -0,0 +1,66 @@
+package cli
+
+import (
+    "fmt"
+    "os"
+
+    "github.com/spf13/cobra"
+    "kingbrain/insight/pkg/baseline"
+    "kingbrain/insight/pkg/i18n"
+)
+
+// baselineFlags 是 audit、vulns 等扫描类命令共用的 --baseline/--write-baseline
+type baselineFlags struct {
+    read, write string
+}
+
+func addBaselineFlags(cmd *cobra.Command, b *baselineFlags) {
+    cmd.Flags().StringVar(&b.read, "baseline", "", "基线文件：只报告基线之外的新发现，有新发现时以退出码 1 结束")
+    cmd.Flags().StringVar(&b.write, "write-baseline", "", "把本次的全部发现写成基线文件")
+}
+
+// load 读取 --baseline，没有指定时返回 nil（nil 基线中没有任何发现）
+func (b *baselineFlags) load(tool string) (*baseline.File, error) {
+    if b.read == "" {
+        return nil, nil
+    }
+    return baseline.Load(b.read, tool)
+}
+
+// save 在指定了 --write-baseline 时写出本次的全部发现
+func (b *baselineFlags) save(tool string, findings []baseline.Finding) error {
+    if b.write == "" {
+        return nil
+    }
+    if err := baseline.New(tool, findings).Save(b.write); err != nil {
+        return err
+    }
+    fmt.Fprint(os.Stderr, i18n.Sprintf("已把 %d 条发现写入基线 %s\n", len(findings), b.write))
+    return nil
+}
+
+// failOnNew 在使用基线且有新发现时以退出码 1 结束；known 为被基线忽略的条数
+func (b *baselineFlags) failOnNew(cmd *cobra.Command, fresh, known int) error {
+    if b.read == "" {
+        return nil
+    }
+    fmt.Fprint(os.Stderr, i18n.Sprintf("基线 %s：%d 条已知发现已忽略，%d 条新发现\n", b.read, known, fresh))
+    if fresh > 0 {
+        return exitWith(cmd, exitFalse, "")
+    }
+    return nil
+}
+
+// splitBaseline 把 items 分成基线之外的新条目与本次的全部发现（交给 save）；finding 给出条目对应的发现，
+// known 为 nil 时全部条目都是新的
+func splitBaseline[T any](known *baseline.File, items []T, finding func(T) baseline.Finding) (fresh []T, findings []baseline.Finding) {
+    fresh = make([]T, 0, len(items))
+    for _, it := range items {
+        f := finding(it)
+        findings = append(findings, f)
+        if !known.Has(f.Fingerprint) {
+            fresh = append(fresh, it)
+        }
+    }
+    return fresh, findings
+}
@@ -0,0 +1,211 @@
+package cli
+
+import (
+    "bufio"
+    "context"
+    "fmt"
+    "io"
+    "os"
+    "strings"
+    "sync"
+
+    "github.com/spf13/cobra"
+    "go.opentelemetry.io/otel/attribute"
+    "kingbrain/insight/pkg/i18n"
+    "kingbrain/insight/pkg/progress"
+    "kingbrain/insight/pkg/sg"
+    "kingbrain/insight/pkg/tracing"
+)
+
+type batchQuery struct {
+    Name  string
+    Query string
+}
+
+type batchResult struct {
+    Name       string         `json:"name"`
+    Query      string         `json:"query"`
+    MatchCount int            `json:"matchCount"`
+    Results    []sg.FileMatch `json:"results,omitempty"`
+    Error      string         `json:"error,omitempty"`
+}
+
+func newBatchCmd() *cobra.Command {
+    var (
+        pattern  string
+        parallel int
+        format   string
+        exports  []string
+        ckptPath string
+        resume   bool
+    )
+
+    cmd := &cobra.Command{
+        Use:   "batch <queries-file|->",
+        Short: "批量执行查询文件中的查询（每行一条，可写成 名称<TAB>查询），显示进度与错误统计",
+        Args:  cobra.ExactArgs(1),
+        RunE: func(cmd *cobra.Command, args []string) error {
+            if err := checkFormat(format, "text", "json"); err != nil {
+                return err
+            }
+            queries, err := readBatchFile(args[0])
+            if err != nil {
+                return err
+            }
+            if ckptPath == "" && args[0] != "-" {
+                ckptPath = args[0] + ".checkpoint"
+            }
+            if ckptPath == "" && resume {
+                return i18n.Errorf("从 stdin 读取查询时 --resume 需要显式指定 --checkpoint")
+            }
+            var ckpt *checkpoint
+            var done map[string]batchResult
+            if ckptPath != "" {
+                if ckpt, done, err = openCheckpoint(ckptPath, resume); err != nil {
+                    return err
+                }
+                if len(done) > 0 {
+                    fmt.Fprint(os.Stderr, i18n.Sprintf("从 %s 恢复：跳过 %d 条已完成的查询\n", ckptPath, len(done)))
+                }
+            }
+
+            run := newRun("batch", args[0])
+            c := sg.New()
+            results := runBatch(cmd.Context(), c, queries, pattern, parallel, ckpt, done)
+
+            var failed int
+            for _, r := range results {
+                if r.Error != "" {
+                    failed++
+                }
+                for _, m := range exportMatches(c, &sg.SearchResults{Results: r.Results}) {
+                    m.Rule = r.Name
+                    run.Matches = append(run.Matches, m)
+                }
+            }
+            if ckpt != nil {
+                if err := ckpt.close(failed == 0); err != nil {
+                    return err
+                }
+            }
+            if err := printBatch(format, results); err != nil {
+                return err
+            }
+            if err := runExports(exports, run); err != nil {
+                return err
+            }
+            if failed > 0 {
+                return i18n.Errorf("%d/%d 条查询失败", failed, len(results))
+            }
+            return nil
+        },
+    }
+
+    cmd.Flags().StringVarP(&pattern, "pattern", "p", "literal", "搜索模式：literal|regexp|structural")
+    cmd.Flags().IntVarP(&parallel, "parallel", "j", 4, "并发查询数")
+    cmd.Flags().StringVarP(&format, "format", "f", "text", "输出格式：text|json（每行一个查询结果）")
+    cmd.Flags().StringVar(&ckptPath, "checkpoint", "", "检查点日志路径（默认 <queries-file>.checkpoint，全部成功后自动删除）")
+    cmd.Flags().BoolVar(&resume, "resume", false, "从检查点继续，跳过已成功的查询")
+    addExportFlag(cmd, &exports)
+    return cmd
+}
+
+// readBatchFile 读取查询文件，忽略空行与 # 注释；"-" 表示 stdin
+func readBatchFile(path string) ([]batchQuery, error) {
+    var r io.Reader = os.Stdin
+    if path != "-" {
+        f, err := os.Open(path)
+        if err != nil {
+            return nil, err
+        }
+        defer f.Close()
+        r = f
+    }
+    var out []batchQuery
+    sc := bufio.NewScanner(r)
+    for sc.Scan() {
+        line := strings.TrimSpace(sc.Text())
+        if line == "" || strings.HasPrefix(line, "#") {
+            continue
+        }
+        q := batchQuery{Name: line, Query: line}
+        if name, query, ok := strings.Cut(line, "\t"); ok {
+            q = batchQuery{Name: strings.TrimSpace(name), Query: strings.TrimSpace(query)}
+        }
+        out = append(out, q)
+    }
+    return out, sc.Err()
+}
+
+// runBatch 用固定大小的 worker 池执行查询，结果顺序与输入一致；
+// done 中已有的结果直接复用，新完成的结果写入 ckpt（可为 nil）
+func runBatch(ctx context.Context, c *sg.Client, queries []batchQuery, pattern string, parallel int,
+    ckpt *checkpoint, done map[string]batchResult) []batchResult {
+    results := make([]batchResult, len(queries))
+    var todo []int
+    for i, q := range queries {
+        if r, ok := done[checkpointKey(pattern, q)]; ok {
+            results[i] = r
+            continue
+        }
+        todo = append(todo, i)
+    }
+
+    bar := progress.New(len(todo))
+    idx := make(chan int)
+    var wg sync.WaitGroup
+    for w := 0; w < max(parallel, 1); w++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            for i := range idx {
+                q := queries[i]
+                bar.Begin(q.Name)
+                qctx, span := tracing.Start(ctx, "batch.query", attribute.String("batch.name", q.Name), attribute.String("sg.query", q.Query))
+                res, err := c.Search(qctx, q.Query, pattern)
+                tracing.End(span, err)
+                r := batchResult{Name: q.Name, Query: q.Query}
+                if err != nil {
+                    r.Error = err.Error()
+                } else {
+                    r.MatchCount, r.Results = res.MatchCount, res.Results
+                }
+                results[i] = r
+                if ckpt != nil {
+                    if cerr := ckpt.record(checkpointKey(pattern, q), r); cerr != nil && err == nil {
+                        err = i18n.Errorf("写检查点: %w", cerr)
+                    }
+                }
+                bar.End(q.Name, err)
+            }
+        }()
+    }
+    for _, i := range todo {
+        idx <- i
+    }
+    close(idx)
+    wg.Wait()
+    bar.Finish()
+    return results
+}
+
+func printBatch(format string, results []batchResult) error {
+    if format == "json" {
+        for _, r := range results {
+            if err := writeJSONLine(os.Stdout, r); err != nil {
+                return err
+            }
+        }
+        return nil
+    }
+    for _, r := range results {
+        if r.Error != "" {
+            fmt.Printf("%-40s  error: %s\n", r.Name, r.Error)
+            continue
+        }
+        fmt.Printf("%-40s  %d matches in %d files\n", r.Name, r.MatchCount, len(r.Results))
+    }
+    return nil
+}
+
+func init() { rootCmd.AddCommand(newBatchCmd()) }
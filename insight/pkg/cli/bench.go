@@ -0,0 +1,268 @@
+package cli
+
+import (
+    "context"
+    "fmt"
+    "os"
+    "sort"
+    "strings"
+    "sync"
+    "time"
+
+    "github.com/spf13/cobra"
+    "kingbrain/insight/pkg/config"
+    "kingbrain/insight/pkg/i18n"
+    "kingbrain/insight/pkg/progress"
+    "kingbrain/insight/pkg/sg"
+)
+
+// benchEndpoint 是参与基准测试的一个实例
+type benchEndpoint struct {
+    Name   string
+    Client *sg.Client
+}
+
+// benchRun 是一次执行的结果
+type benchRun struct {
+    Duration   time.Duration
+    FirstMatch time.Duration
+    MatchCount int
+    Err        error
+}
+
+// BenchSummary 是一个端点的基准统计；时间单位为毫秒
+type BenchSummary struct {
+    Endpoint    string      `json:"endpoint"`
+    Runs        int         `json:"runs"`
+    Errors      int         `json:"errors"`
+    MinMs       float64     `json:"minMs"`
+    P50Ms       float64     `json:"p50Ms"`
+    P90Ms       float64     `json:"p90Ms"`
+    P99Ms       float64     `json:"p99Ms"`
+    MaxMs       float64     `json:"maxMs"`
+    MeanMs      float64     `json:"meanMs"`
+    FirstP50Ms  float64     `json:"firstMatchP50Ms,omitempty"`
+    FirstP90Ms  float64     `json:"firstMatchP90Ms,omitempty"`
+    Counts      map[int]int `json:"counts"` // 匹配数 → 出现次数
+    Stable      bool        `json:"stable"`
+    FirstErrors []string    `json:"errorSamples,omitempty"`
+}
+
+func newBenchCmd() *cobra.Command {
+    var (
+        pattern  string
+        runs     int
+        warmup   int
+        parallel int
+        stream   bool
+        federate bool
+        format   string
+    )
+
+    cmd := &cobra.Command{
+        Use:   "bench <query|->",
+        Short: "重复执行同一查询，统计延迟分布、结果数是否稳定，流式模式下还统计首个匹配时间",
+        Long: `供 Sourcegraph 管理员做容量调优：按 --runs 次数执行查询（先跑 --warmup 次预热不计入），
+报告 min/p50/p90/p99/max/mean 延迟与每次返回的匹配数。--federate 时对配置中的每个实例分别测试。
+注意非流式模式下查询里没有 count: 时会按 --max-results 自动追加，需要测完整查询时用 --max-results 0。
+为了只统计实例本身的耗时，bench 不等待共享配额，被限流 (HTTP 429) 时也不重试，直接计为一次错误。
+
+  kb bench 'lang:go fmt.Errorf' -n 20
+  kb bench 'repo:^github\.com/acme/ TODO' --stream --federate -j 4`,
+        Args: cobra.ExactArgs(1),
+        RunE: func(cmd *cobra.Command, args []string) error {
+            if err := checkFormat(format, "text", "json"); err != nil {
+                return err
+            }
+            if runs < 1 {
+                return i18n.Errorf("--runs 至少为 1")
+            }
+            q, err := readQueryArg(args[0])
+            if err != nil {
+                return err
+            }
+            endpoints, err := benchEndpoints(federate)
+            if err != nil {
+                return err
+            }
+
+            bar := progress.New(len(endpoints) * (runs + warmup))
+            var summaries []BenchSummary
+            for _, ep := range endpoints {
+                results := runBench(cmd.Context(), bar, ep, q, pattern, runs, warmup, parallel, stream)
+                summaries = append(summaries, summarizeBench(ep.Name, results))
+            }
+            bar.Finish()
+
+            if format == "json" {
+                return writeJSON(os.Stdout, summaries)
+            }
+            for _, s := range summaries {
+                printBench(s, stream)
+            }
+            return nil
+        },
+    }
+
+    cmd.Flags().StringVarP(&pattern, "pattern", "p", "literal", "搜索模式：literal|regexp|structural")
+    cmd.Flags().IntVarP(&runs, "runs", "n", 10, "计入统计的执行次数")
+    cmd.Flags().IntVar(&warmup, "warmup", 1, "预热次数（不计入统计）")
+    cmd.Flags().IntVarP(&parallel, "parallel", "j", 1, "同一端点上的并发执行数")
+    cmd.Flags().BoolVar(&stream, "stream", false, "使用流式搜索接口，并统计首个匹配时间")
+    cmd.Flags().BoolVar(&federate, "federate", false, "对配置文件中的每个实例分别测试")
+    cmd.Flags().StringVarP(&format, "format", "f", "text", "输出格式：text|json")
+    return cmd
+}
+
+// benchEndpoints 返回要测试的实例；客户端不走共享配额与限流重试，等待时间不会混进延迟
+func benchEndpoints(federate bool) ([]benchEndpoint, error) {
+    if !federate {
+        c := sg.New()
+        return []benchEndpoint{{Name: c.URL(""), Client: c.WithoutThrottling()}}, nil
+    }
+    cfg, err := config.Load()
+    if err != nil {
+        return nil, err
+    }
+    if len(cfg.Instances) == 0 {
+        p, _ := config.Path()
+        return nil, i18n.Errorf("--federate 需要在 %s 中配置 instances", p)
+    }
+    var eps []benchEndpoint
+    for _, inst := range cfg.Instances {
+        eps = append(eps, benchEndpoint{Name: inst.Name, Client: sg.NewInstance(inst.URL, inst.TokenFor()).WithoutThrottling()})
+    }
+    return eps, nil
+}
+
+// runBench 先串行预热，再用 parallel 个 worker 执行 runs 次，结果按完成顺序返回
+func runBench(ctx context.Context, bar *progress.Bar, ep benchEndpoint, q, pattern string, runs, warmup, parallel int, stream bool) []benchRun {
+    once := func() benchRun {
+        start := time.Now()
+        if stream {
+            st, err := ep.Client.StreamSearch(ctx, q, pattern)
+            if err != nil {
+                return benchRun{Duration: time.Since(start), Err: err}
+            }
+            return benchRun{Duration: st.Elapsed, FirstMatch: st.FirstMatch, MatchCount: st.MatchCount}
+        }
+        res, err := ep.Client.Search(ctx, q, pattern)
+        if err != nil {
+            return benchRun{Duration: time.Since(start), Err: err}
+        }
+        return benchRun{Duration: time.Since(start), MatchCount: res.MatchCount}
+    }
+    for i := 0; i < warmup; i++ {
+        label := fmt.Sprintf("%s warmup %d", ep.Name, i+1)
+        bar.Begin(label)
+        bar.End(label, once().Err)
+    }
+
+    var (
+        mu      sync.Mutex
+        results []benchRun
+        wg      sync.WaitGroup
+    )
+    idx := make(chan int)
+    for w := 0; w < max(parallel, 1); w++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            for i := range idx {
+                label := fmt.Sprintf("%s #%d", ep.Name, i+1)
+                bar.Begin(label)
+                r := once()
+                mu.Lock()
+                results = append(results, r)
+                mu.Unlock()
+                bar.End(label, r.Err)
+            }
+        }()
+    }
+    for i := 0; i < runs; i++ {
+        idx <- i
+    }
+    close(idx)
+    wg.Wait()
+    return results
+}
+
+func summarizeBench(endpoint string, results []benchRun) BenchSummary {
+    s := BenchSummary{Endpoint: endpoint, Runs: len(results), Counts: map[int]int{}}
+    var lat, first []time.Duration
+    var total time.Duration
+    for _, r := range results {
+        if r.Err != nil {
+            s.Errors++
+            if len(s.FirstErrors) < 3 {
+                s.FirstErrors = append(s.FirstErrors, r.Err.Error())
+            }
+            continue
+        }
+        lat = append(lat, r.Duration)
+        total += r.Duration
+        if r.FirstMatch > 0 {
+            first = append(first, r.FirstMatch)
+        }
+        s.Counts[r.MatchCount]++
+    }
+    s.Stable = len(s.Counts) == 1
+    if len(lat) == 0 {
+        return s
+    }
+    sortDurations(lat)
+    s.MinMs, s.MaxMs = ms(lat[0]), ms(lat[len(lat)-1])
+    s.P50Ms, s.P90Ms, s.P99Ms = ms(percentile(lat, 50)), ms(percentile(lat, 90)), ms(percentile(lat, 99))
+    s.MeanMs = ms(total / time.Duration(len(lat)))
+    if len(first) > 0 {
+        sortDurations(first)
+        s.FirstP50Ms, s.FirstP90Ms = ms(percentile(first, 50)), ms(percentile(first, 90))
+    }
+    return s
+}
+
+func sortDurations(d []time.Duration) {
+    sort.Slice(d, func(i, j int) bool { return d[i] < d[j] })
+}
+
+// percentile 用最近秩法取已排序样本的第 p 百分位
+func percentile(sorted []time.Duration, p int) time.Duration {
+    i := (p*len(sorted)+99)/100 - 1
+    return sorted[min(max(i, 0), len(sorted)-1)]
+}
+
+func ms(d time.Duration) float64 {
+    return float64(d.Microseconds()) / 1000
+}
+
+func printBench(s BenchSummary, stream bool) {
+    fmt.Print(i18n.Sprintf("%s：%d 次，失败 %d\n", s.Endpoint, s.Runs, s.Errors))
+    for _, e := range s.FirstErrors {
+        fmt.Print(i18n.Sprintf("  错误示例  %s\n", e))
+    }
+    if s.Runs == s.Errors {
+        fmt.Println()
+        return
+    }
+    fmt.Print(i18n.Sprintf("  延迟      min %.0fms  p50 %.0fms  p90 %.0fms  p99 %.0fms  max %.0fms  mean %.0fms\n",
+        s.MinMs, s.P50Ms, s.P90Ms, s.P99Ms, s.MaxMs, s.MeanMs))
+    if stream {
+        fmt.Print(i18n.Sprintf("  首个匹配  p50 %.0fms  p90 %.0fms\n", s.FirstP50Ms, s.FirstP90Ms))
+    }
+    counts := make([]int, 0, len(s.Counts))
+    for n := range s.Counts {
+        counts = append(counts, n)
+    }
+    sort.Ints(counts)
+    if s.Stable {
+        fmt.Print(i18n.Sprintf("  匹配数    稳定：%d\n\n", counts[0]))
+        return
+    }
+    parts := make([]string, len(counts))
+    for i, n := range counts {
+        parts[i] = fmt.Sprintf("%d×%d", n, s.Counts[n])
+    }
+    fmt.Print(i18n.Sprintf("  匹配数    不稳定：%s\n\n", strings.Join(parts, ", ")))
+}
+
+func init() { rootCmd.AddCommand(newBenchCmd()) }
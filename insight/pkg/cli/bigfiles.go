@@ -0,0 +1,314 @@
+package cli
+
+import (
+    "fmt"
+    "os"
+    "path"
+    "regexp"
+    "sort"
+    "strconv"
+    "strings"
+    "sync"
+
+    "github.com/spf13/cobra"
+    "kingbrain/insight/pkg/baseline"
+    "kingbrain/insight/pkg/i18n"
+    "kingbrain/insight/pkg/progress"
+    "kingbrain/insight/pkg/sg"
+)
+
+// defaultBinaryExts 是路径搜索时视为二进制制品的扩展名
+var defaultBinaryExts = []string{
+    "zip", "tar", "gz", "tgz", "bz2", "xz", "7z", "rar", "jar", "war", "ear", "apk", "ipa",
+    "exe", "dll", "so", "dylib", "a", "o", "lib", "bin", "class", "pyc", "whl", "deb", "rpm",
+    "iso", "dmg", "img", "msi", "pdf", "psd", "mp4", "mov", "mp3", "wav", "sqlite", "db",
+}
+
+// bigFile 是报告中的一个文件；Binary 为服务端按内容判断的二进制，Artifact 为按扩展名判断的制品
+type bigFile struct {
+    sg.TreeFile
+    Artifact bool `json:"artifact,omitempty"`
+}
+
+// bigFilesRepo 是一个仓库中超过阈值的大文件与二进制文件，按大小降序
+type bigFilesRepo struct {
+    Repo  string    `json:"repo"`
+    Total int64     `json:"total_size"`
+    Files []bigFile `json:"files"`
+    Error string    `json:"error,omitempty"`
+}
+
+func newBigFilesCmd() *cobra.Command {
+    var (
+        repos    []string
+        minSize  string
+        binaries bool
+        exts     []string
+        rev      string
+        limit    int
+        parallel int
+        format   string
+        bl       baselineFlags
+    )
+
+    cmd := &cobra.Command{
+        Use:   "bigfiles [repo...]",
+        Short: "找出各仓库中超过大小阈值的文件与提交进仓库的二进制文件，按仓库分组报告",
+        Long: `检查的仓库为参数中给出的仓库（支持 * ? glob）加上 --repo 在仓库缓存中匹配的全部仓库；
+没有 --repo 时改为按扩展名做路径搜索（type:path，受 --scope 限定），找出含二进制制品的仓库。
+逐个读取这些仓库目录树的文件大小，报告不小于 --min-size 的文件，
+以及服务端判断为二进制、或扩展名属于制品的文件（不论大小，--binaries=false 时只看大小）。
+仓库按大文件的总大小排序。大小写法：500K、20MB、1.5G（按 1024 换算）。--baseline 时只报告基线之外
+新出现的文件（文件变大不算新发现），有新发现时以退出码 1 结束。
+
+  kb bigfiles github.com/acme/api github.com/acme/web --min-size 5MB
+  kb bigfiles --repo '^github\.com/acme/' -f csv > bigfiles.csv`,
+        RunE: func(cmd *cobra.Command, args []string) error {
+            if err := checkFormat(format, "text", "json", "csv"); err != nil {
+                return err
+            }
+            threshold, err := parseSize(minSize)
+            if err != nil {
+                return err
+            }
+            known, err := bl.load("bigfiles")
+            if err != nil {
+                return err
+            }
+            if len(args) == 0 && len(repos) == 0 {
+                fmt.Fprintln(os.Stderr, i18n.T("提示: 没有指定仓库，将在整个实例上做路径搜索"))
+            }
+            ctx := cmd.Context()
+            c := sg.New()
+            targets, err := expandRepoNames(ctx, c, args)
+            if err != nil {
+                return err
+            }
+
+            // --repo 匹配的仓库全部按目录树检查；否则用路径搜索找出有制品的仓库，文件大小以目录树为准
+            isArtifact := artifactRegexp(exts)
+            seen := map[string]bool{}
+            for _, r := range targets {
+                seen[r] = true
+            }
+            if len(repos) > 0 {
+                matched, err := reposMatching(ctx, c, repos)
+                if err != nil {
+                    return err
+                }
+                for _, r := range matched {
+                    if !seen[r] {
+                        seen[r] = true
+                        targets = append(targets, r)
+                    }
+                }
+            } else if len(exts) > 0 {
+                q := buildQuery("type:path", "file:"+isArtifact.String(), repoFilter(repos), countFilter(limit))
+                res, err := c.Search(ctx, q, "regexp")
+                if err != nil {
+                    return err
+                }
+                for _, fm := range res.Results {
+                    if !seen[fm.Repository.Name] {
+                        seen[fm.Repository.Name] = true
+                        targets = append(targets, fm.Repository.Name)
+                    }
+                }
+            }
+            if len(targets) == 0 {
+                if len(repos) > 0 {
+                    return i18n.Errorf("--repo 没有匹配仓库缓存中的任何仓库（可运行 kb repos --refresh 更新缓存）")
+                }
+                fmt.Fprintln(os.Stderr, i18n.T("没有找到含二进制制品的仓库"))
+                return nil
+            }
+
+            results := make([]bigFilesRepo, len(targets))
+            bar := progress.New(len(targets))
+            idx := make(chan int)
+            var wg sync.WaitGroup
+            for w := 0; w < max(parallel, 1); w++ {
+                wg.Add(1)
+                go func() {
+                    defer wg.Done()
+                    for i := range idx {
+                        bar.Begin(targets[i])
+                        files, err := c.TreeSizes(ctx, targets[i], rev)
+                        results[i] = collectBigFiles(targets[i], files, threshold, binaries, isArtifact)
+                        if err != nil {
+                            results[i].Error = err.Error()
+                        }
+                        bar.End(targets[i], err)
+                    }
+                }()
+            }
+            for i := range targets {
+                idx <- i
+            }
+            close(idx)
+            wg.Wait()
+            bar.Finish()
+
+            var report []bigFilesRepo
+            var findings []baseline.Finding
+            all, fresh := 0, 0
+            for _, r := range results {
+                files, fs := splitBaseline(known, r.Files, func(f bigFile) baseline.Finding { return bigFileBaselineFinding(r.Repo, f) })
+                findings = append(findings, fs...)
+                all, fresh = all+len(r.Files), fresh+len(files)
+                r.Files, r.Total = files, 0
+                for _, f := range files {
+                    r.Total += f.Size
+                }
+                if len(r.Files) > 0 || r.Error != "" {
+                    report = append(report, r)
+                }
+            }
+            if err := bl.save("bigfiles", findings); err != nil {
+                return err
+            }
+            sort.SliceStable(report, func(i, j int) bool { return report[i].Total > report[j].Total })
+            if err := printBigFiles(format, report, len(targets), threshold); err != nil {
+                return err
+            }
+            return bl.failOnNew(cmd, fresh, all-fresh)
+        },
+    }
+
+    cmd.Flags().StringSliceVar(&repos, "repo", nil, "限定仓库（可重复，支持正则；含 * ? 的 glob 按仓库缓存展开）")
+    cmd.Flags().StringVar(&minSize, "min-size", "1MB", "报告不小于该大小的文件，如 500K、20MB")
+    cmd.Flags().BoolVar(&binaries, "binaries", true, "同时报告二进制文件与制品（不论大小）")
+    cmd.Flags().StringSliceVar(&exts, "ext", defaultBinaryExts, "路径搜索与判断制品用的扩展名（为空时不做路径搜索）")
+    cmd.Flags().StringVar(&rev, "rev", "", "读取目录树的 revision（默认 HEAD）")
+    cmd.Flags().IntVar(&limit, "limit", 5000, "路径搜索最多返回的文件数（count:）")
+    cmd.Flags().IntVarP(&parallel, "parallel", "j", 4, "同时读取目录树的仓库数")
+    cmd.Flags().StringVarP(&format, "format", "f", "text", "输出格式：text|json|csv")
+    addBaselineFlags(cmd, &bl)
+    return cmd
+}
+
+// bigFileBaselineFinding 按 类别 + 路径 识别，不含大小，已知的大文件变大不算新发现
+func bigFileBaselineFinding(repo string, f bigFile) baseline.Finding {
+    rule := "size"
+    switch {
+    case f.Binary:
+        rule = "binary"
+    case f.Artifact:
+        rule = "artifact"
+    }
+    return baseline.Finding{
+        Fingerprint: baseline.Fingerprint("bigfiles", rule, repo, f.Path, ""),
+        Rule:        rule,
+        Repo:        repo,
+        Path:        f.Path,
+        Text:        humanSize(f.Size),
+    }
+}
+
+// printBigFiles 按格式输出报告；checked 为检查过的仓库数
+func printBigFiles(format string, report []bigFilesRepo, checked int, threshold int64) error {
+    switch format {
+    case "json":
+        return writeJSON(os.Stdout, report)
+    case "csv":
+        var rows [][]string
+        for _, r := range report {
+            for _, f := range r.Files {
+                rows = append(rows, []string{r.Repo, f.Path, strconv.FormatInt(f.Size, 10), strconv.FormatBool(f.Binary), strconv.FormatBool(f.Artifact)})
+            }
+        }
+        return writeCSV(os.Stdout, []string{"repo", "path", "size", "binary", "artifact"}, rows)
+    }
+    if len(report) == 0 {
+        fmt.Print(i18n.Sprintf("%d 个仓库中没有超过 %s 的文件或二进制文件\n", checked, humanSize(threshold)))
+        return nil
+    }
+    for _, r := range report {
+        if r.Error != "" {
+            fmt.Print(i18n.Sprintf("%s：读取目录树失败: %s\n\n", r.Repo, r.Error))
+            continue
+        }
+        fmt.Print(i18n.Sprintf("%s（%d 个文件，共 %s）\n", r.Repo, len(r.Files), humanSize(r.Total)))
+        for _, f := range r.Files {
+            kind := ""
+            switch {
+            case f.Binary:
+                kind = "bin"
+            case f.Artifact:
+                kind = "art"
+            }
+            fmt.Printf("  %9s  %-3s  %s\n", humanSize(f.Size), kind, f.Path)
+        }
+        fmt.Println()
+    }
+    return nil
+}
+
+// artifactRegexp 生成匹配这些扩展名的路径正则
+func artifactRegexp(exts []string) *regexp.Regexp {
+    quoted := make([]string, 0, len(exts))
+    for _, e := range exts {
+        if e = strings.TrimPrefix(strings.TrimSpace(e), "."); e != "" {
+            quoted = append(quoted, regexp.QuoteMeta(strings.ToLower(e)))
+        }
+    }
+    if len(quoted) == 0 {
+        return regexp.MustCompile(`$^`)
+    }
+    return regexp.MustCompile(`(?i)\.(?:` + strings.Join(quoted, "|") + `)$`)
+}
+
+// collectBigFiles 挑出大小不小于 threshold 的文件；binaries 时二进制与制品不论大小都算
+func collectBigFiles(repo string, files []sg.TreeFile, threshold int64, binaries bool, artifact *regexp.Regexp) bigFilesRepo {
+    r := bigFilesRepo{Repo: repo}
+    for _, f := range files {
+        bf := bigFile{TreeFile: f, Artifact: artifact.MatchString(path.Base(f.Path))}
+        if f.Size >= threshold || (binaries && (f.Binary || bf.Artifact)) {
+            r.Files = append(r.Files, bf)
+            r.Total += f.Size
+        }
+    }
+    sort.SliceStable(r.Files, func(i, j int) bool { return r.Files[i].Size > r.Files[j].Size })
+    return r
+}
+
+// parseSize 解析 500K、20MB、1.5G 这类大小，单位按 1024 换算，没有单位时为字节
+func parseSize(s string) (int64, error) {
+    t := strings.ToUpper(strings.TrimSpace(s))
+    t = strings.TrimSuffix(strings.TrimSuffix(t, "IB"), "B")
+    mult := int64(1)
+    if n := len(t); n > 0 {
+        switch t[n-1] {
+        case 'K':
+            mult = 1 << 10
+        case 'M':
+            mult = 1 << 20
+        case 'G':
+            mult = 1 << 30
+        }
+        if mult > 1 {
+            t = t[:n-1]
+        }
+    }
+    v, err := strconv.ParseFloat(strings.TrimSpace(t), 64)
+    if err != nil || v < 0 {
+        return 0, i18n.Errorf("无法解析大小 %q（如 500K、20MB、1.5G）", s)
+    }
+    return int64(v * float64(mult)), nil
+}
+
+// humanSize 把字节数写成 1.5 MB 这样的形式
+func humanSize(n int64) string {
+    const unit = 1024
+    if n < unit {
+        return fmt.Sprintf("%d B", n)
+    }
+    div, exp := int64(unit), 0
+    for m := n / unit; m >= unit; m /= unit {
+        div *= unit
+        exp++
+    }
+    return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+func init() { rootCmd.AddCommand(newBigFilesCmd()) }
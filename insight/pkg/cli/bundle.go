@@ -0,0 +1,472 @@
+package cli
+
+import (
+    "bufio"
+    "context"
+    "fmt"
+    "os"
+    "path"
+    "path/filepath"
+    "sort"
+    "strings"
+    "time"
+
+    "github.com/spf13/cobra"
+    "kingbrain/insight/pkg/bundle"
+    "kingbrain/insight/pkg/config"
+    "kingbrain/insight/pkg/i18n"
+    "kingbrain/insight/pkg/progress"
+    "kingbrain/insight/pkg/sg"
+)
+
+func newBundleCmd() *cobra.Command {
+    cmd := &cobra.Command{
+        Use:   "bundle",
+        Short: "把查询、结果快照、文件片段与报告打成离线包，在没有实例访问权限的环境中浏览",
+        Long: `kb bundle export 运行给定的查询，把查询、结果快照、匹配所在文件的片段与附带的报告
+（digest --dry-run、changelog 等的输出）打成一个 .kbb 文件（tar.gz，内含 manifest.json）。
+kb bundle import 在另一台机器上导入离线包并在终端中浏览，全程不需要访问实例。
+
+  kb bundle export -o audit.kbb 'ioutil.ReadAll' 'lang:go os.Setenv' --report weekly.html
+  kb bundle export -o audit.kbb --queries queries.txt --digest --full-files
+  kb bundle import audit.kbb
+  kb bundle list`,
+    }
+    cmd.AddCommand(newBundleExportCmd(), newBundleImportCmd(), newBundleListCmd())
+    return cmd
+}
+
+func newBundleExportCmd() *cobra.Command {
+    var (
+        out      string
+        pattern  string
+        files    []string
+        digest   bool
+        reports  []string
+        around   int
+        full     bool
+        maxFiles int
+        note     string
+    )
+    cmd := &cobra.Command{
+        Use:   "export -o <file.kbb> [query]...",
+        Short: "运行查询并把结果、文件片段与报告打成离线包",
+        Long: `查询来自位置参数、--queries 文件（每行一个，# 开头为注释）与 --digest（配置中的 digest 查询）。
+对每个有匹配的文件按匹配所在的 revision 批量拉取内容，保留匹配行前后 --context 行（带原始行号，匹配行以 > 标出），
+--full-files 保留整个文件；最多拉取 --max-files 个文件。--report 附带任意文件（可重复），导入后原样查看。
+查询失败只记入包中，不中断导出。`,
+        RunE: func(cmd *cobra.Command, args []string) error {
+            if out == "" {
+                return i18n.Errorf("需要 -o 指定离线包的路径")
+            }
+            queries, err := bundleQueries(args, files, digest, pattern)
+            if err != nil {
+                return err
+            }
+            if len(queries) == 0 && len(reports) == 0 {
+                return i18n.Errorf("没有要打包的查询或报告")
+            }
+            w, err := bundle.Create(out)
+            if err != nil {
+                return err
+            }
+            if err := exportBundle(cmd.Context(), sg.New(), w, queries, reports, around, full, maxFiles); err != nil {
+                w.Abort()
+                return err
+            }
+            w.Manifest.Note = note
+            if err := w.Close(); err != nil {
+                os.Remove(out)
+                return err
+            }
+            m := w.Manifest
+            fmt.Fprint(os.Stderr, i18n.Sprintf("已写入 %s：%d 个查询，%d 个文件片段，%d 个报告\n", out, len(m.Queries), len(m.Files), len(m.Reports)))
+            return nil
+        },
+    }
+    cmd.Flags().StringVarP(&out, "output", "o", "", "离线包的路径（建议以 .kbb 结尾）")
+    cmd.Flags().StringVarP(&pattern, "pattern", "p", "literal", "位置参数与 --queries 中查询的搜索模式：literal|regexp|structural")
+    cmd.Flags().StringArrayVar(&files, "queries", nil, "从文件读取查询，每行一个（可重复，- 为 stdin）")
+    cmd.Flags().BoolVar(&digest, "digest", false, "同时打包配置文件中 digest 段的查询")
+    cmd.Flags().StringArrayVar(&reports, "report", nil, "附带的报告文件（可重复）")
+    cmd.Flags().IntVarP(&around, "context", "C", 5, "文件片段保留匹配行前后的行数")
+    cmd.Flags().BoolVar(&full, "full-files", false, "保留匹配所在的整个文件")
+    cmd.Flags().IntVar(&maxFiles, "max-files", 200, "最多拉取的文件数（0 为不拉取文件）")
+    cmd.Flags().StringVar(&note, "note", "", "写入离线包的说明，导入时显示")
+    return cmd
+}
+
+// bundleQueries 汇总位置参数、查询文件与 digest 配置中的查询
+func bundleQueries(args, files []string, digest bool, pattern string) ([]bundle.Query, error) {
+    var out []bundle.Query
+    for _, a := range args {
+        out = append(out, bundle.Query{Name: a, Query: a, Pattern: pattern})
+    }
+    for _, f := range files {
+        doc, err := readAPIDocument(f)
+        if err != nil {
+            return nil, err
+        }
+        sc := bufio.NewScanner(strings.NewReader(doc))
+        for sc.Scan() {
+            l := strings.TrimSpace(sc.Text())
+            if l == "" || strings.HasPrefix(l, "#") {
+                continue
+            }
+            out = append(out, bundle.Query{Name: l, Query: l, Pattern: pattern})
+        }
+    }
+    if digest {
+        cfg, err := config.Load()
+        if err != nil {
+            return nil, err
+        }
+        if len(cfg.Digest.Queries) == 0 {
+            return nil, i18n.Errorf("配置文件中没有 digest 查询")
+        }
+        for _, q := range cfg.Digest.Queries {
+            p := q.Pattern
+            if p == "" {
+                p = "literal"
+            }
+            out = append(out, bundle.Query{Name: q.Name, Query: q.Query, Pattern: p})
+        }
+    }
+    return out, nil
+}
+
+// exportBundle 运行查询、拉取文件片段并把报告写入包
+func exportBundle(ctx context.Context, c *sg.Client, w *bundle.Writer, queries []bundle.Query, reports []string, around int, full bool, maxFiles int) error {
+    w.Manifest.Instance = c.URL("")
+    c = c.WithRevisions()
+
+    // hits 记录每个文件（按匹配所在的 revision 区分）的匹配行，files 保持首次出现的顺序
+    type fileKey struct{ repo, rev, path string }
+    hits := map[fileKey]map[int]bool{}
+    var files []fileKey
+
+    bar := progress.New(len(queries))
+    for i, q := range queries {
+        bar.Begin(q.Name)
+        q.Ran = time.Now()
+        res, err := c.Search(ctx, q.Query, q.Pattern)
+        if err != nil {
+            q.Error = err.Error()
+        } else {
+            q.MatchCount, q.LimitHit = res.MatchCount, res.LimitHit
+            var matches []bundle.Match
+            for _, m := range exportMatches(c, res) {
+                matches = append(matches, bundle.Match{Repo: m.Repo, Path: m.Path, Line: m.Line, Preview: m.Preview, URL: m.URL})
+            }
+            for _, fm := range res.Results {
+                k := fileKey{fm.Repository.Name, fm.Rev, fm.File.Path}
+                if len(fm.LineMatches) > 0 && hits[k] == nil {
+                    hits[k] = map[int]bool{}
+                    files = append(files, k)
+                }
+                for _, lm := range fm.LineMatches {
+                    hits[k][lm.LineNumber+1] = true
+                }
+            }
+            q.Results = fmt.Sprintf("results/%03d.json", i+1)
+            if err := w.AddJSON(q.Results, matches); err != nil {
+                bar.Finish()
+                return err
+            }
+        }
+        w.Manifest.Queries = append(w.Manifest.Queries, q)
+        bar.End(q.Name, err)
+    }
+    bar.Finish()
+
+    if len(files) > maxFiles {
+        fmt.Fprint(os.Stderr, i18n.Sprintf("有匹配的文件共 %d 个，只拉取前 %d 个（--max-files）\n", len(files), maxFiles))
+        files = files[:maxFiles]
+    }
+    specs := make([]sg.FileSpec, len(files))
+    for i, k := range files {
+        specs[i] = sg.FileSpec{Repo: k.repo, Rev: k.rev, Path: k.path}
+    }
+    bar = progress.New(len(files))
+    fetched := c.GetFilesFunc(ctx, specs, func(r sg.FileResult) { bar.End(r.Repo+"/"+r.Path, r.Err) })
+    bar.Finish()
+    for i, k := range files {
+        if fetched[i].Err != nil {
+            continue
+        }
+        dir := k.repo
+        if k.rev != "" {
+            dir += "@" + k.rev
+        }
+        f := bundle.File{Repo: k.repo, Rev: k.rev, Path: k.path, Full: full, Entry: path.Join("files", dir, k.path)}
+        var text string
+        text, f.Lines = fileSnippet(fetched[i].Content, hits[k], around, full)
+        if err := w.Add(f.Entry, []byte(text)); err != nil {
+            return err
+        }
+        w.Manifest.Files = append(w.Manifest.Files, f)
+    }
+
+    seen := map[string]bool{}
+    for _, r := range reports {
+        data, err := os.ReadFile(r)
+        if err != nil {
+            return err
+        }
+        name := filepath.Base(r)
+        for i := 2; seen[name]; i++ {
+            name = fmt.Sprintf("%d-%s", i, filepath.Base(r))
+        }
+        seen[name] = true
+        rep := bundle.Report{Name: name, Entry: "reports/" + name}
+        if err := w.Add(rep.Entry, data); err != nil {
+            return err
+        }
+        w.Manifest.Reports = append(w.Manifest.Reports, rep)
+    }
+    return nil
+}
+
+// fileSnippet 返回要保存的文件内容与保留的行数：full 时为原文，否则为匹配行前后 around 行，
+// 每行带原始行号，不相邻的片段之间以 ⋯ 分隔
+func fileSnippet(content string, hit map[int]bool, around int, full bool) (string, int) {
+    lines := strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+    if full {
+        return content, len(lines)
+    }
+    keep := make([]bool, len(lines)+1)
+    for l := range hit {
+        for i := max(l-around, 1); i <= min(l+around, len(lines)); i++ {
+            keep[i] = true
+        }
+    }
+    var b strings.Builder
+    kept, last := 0, 0
+    for i := 1; i <= len(lines); i++ {
+        if !keep[i] {
+            continue
+        }
+        if last > 0 && i > last+1 {
+            b.WriteString("        ⋯\n")
+        }
+        mark := " "
+        if hit[i] {
+            mark = ">"
+        }
+        fmt.Fprintf(&b, "%s%6d | %s\n", mark, i, lines[i-1])
+        kept++
+        last = i
+    }
+    return b.String(), kept
+}
+
+func newBundleImportCmd() *cobra.Command {
+    var format string
+    cmd := &cobra.Command{
+        Use:   "import <file.kbb|name>",
+        Short: "导入离线包并在终端中浏览",
+        Long: `把离线包复制到 <用户缓存目录>/insight/bundles/ 后打开浏览器：queries/ 下是各查询的结果快照，
+files/ 下是文件片段，reports/ 下是附带的报告，summary 为包的概要；回车查看，← 返回，q 退出。
+参数也可以是已导入的包名（见 kb bundle list）。stdout 不是终端或给了 -f 时只打印概要。`,
+        Args: cobra.ExactArgs(1),
+        RunE: func(cmd *cobra.Command, args []string) error {
+            if format != "" {
+                if err := checkFormat(format, "text", "json"); err != nil {
+                    return err
+                }
+            }
+            b, name, err := importBundle(args[0])
+            if err != nil {
+                return err
+            }
+            switch {
+            case format == "json":
+                return writeJSON(os.Stdout, b.Manifest)
+            case format == "text" || !stdoutTTY || !stdinTTY():
+                fmt.Print(bundleSummary(name, b))
+                return nil
+            }
+            stopPager()
+            root, open := bundleTree(name, b)
+            return browseTree(name, root, open)
+        },
+    }
+    cmd.Flags().StringVarP(&format, "format", "f", "", "只打印概要：text|json")
+    return cmd
+}
+
+// importBundle 打开离线包；arg 是文件时先复制到导入目录，否则按已导入的包名查找
+func importBundle(arg string) (*bundle.Bundle, string, error) {
+    dir, err := bundle.Dir()
+    if err != nil {
+        return nil, "", err
+    }
+    if _, err := os.Stat(arg); err != nil {
+        name := strings.TrimSuffix(arg, bundle.Ext)
+        b, err := bundle.Open(filepath.Join(dir, name+bundle.Ext))
+        if os.IsNotExist(err) {
+            return nil, "", i18n.Errorf("%s 既不是文件也不是已导入的包（见 kb bundle list）", arg)
+        }
+        return b, name, err
+    }
+    b, err := bundle.Open(arg)
+    if err != nil {
+        return nil, "", err
+    }
+    name := strings.TrimSuffix(filepath.Base(arg), filepath.Ext(arg))
+    dst := filepath.Join(dir, name+bundle.Ext)
+    if abs, _ := filepath.Abs(arg); abs != dst {
+        data, err := os.ReadFile(arg)
+        if err != nil {
+            return nil, "", err
+        }
+        if err := os.MkdirAll(dir, 0o755); err != nil {
+            return nil, "", err
+        }
+        if err := os.WriteFile(dst, data, 0o644); err != nil {
+            return nil, "", err
+        }
+        fmt.Fprint(os.Stderr, i18n.Sprintf("已导入为 %s（%s）\n", name, dst))
+    }
+    return b, name, nil
+}
+
+func bundleSummary(name string, b *bundle.Bundle) string {
+    m := b.Manifest
+    var s strings.Builder
+    s.WriteString(i18n.Sprintf("%s：%s 导出自 %s\n", name, m.Created.Local().Format("2006-01-02 15:04"), m.Instance))
+    if m.Note != "" {
+        s.WriteString(m.Note + "\n")
+    }
+    s.WriteString(i18n.Sprintf("\n查询（%d）:\n", len(m.Queries)))
+    for _, q := range m.Queries {
+        switch {
+        case q.Error != "":
+            fmt.Fprintf(&s, "  ✗ %s: %s\n", q.Name, q.Error)
+        case q.LimitHit:
+            fmt.Fprintf(&s, "  %6d+ %s\n", q.MatchCount, q.Name)
+        default:
+            fmt.Fprintf(&s, "  %6d  %s\n", q.MatchCount, q.Name)
+        }
+    }
+    s.WriteString(i18n.Sprintf("\n文件片段: %d\n", len(m.Files)))
+    if len(m.Reports) > 0 {
+        s.WriteString(i18n.Sprintf("\n报告（%d）:\n", len(m.Reports)))
+        for _, r := range m.Reports {
+            s.WriteString("  " + r.Name + "\n")
+        }
+    }
+    return s.String()
+}
+
+// bundleTree 为浏览器构建包的目录树，返回按相对路径查看条目的函数
+func bundleTree(name string, b *bundle.Bundle) (*treeNode, func(string) error) {
+    views := map[string]func() (string, error){
+        "summary": func() (string, error) { return bundleSummary(name, b), nil },
+    }
+    width := len(fmt.Sprint(len(b.Manifest.Queries)))
+    for i, q := range b.Manifest.Queries {
+        p := fmt.Sprintf("queries/%0*d-%s", width, i+1, bundle.Slug(q.Name, 40))
+        views[p] = func() (string, error) { return queryView(b, q) }
+    }
+    for _, f := range b.Manifest.Files {
+        views[f.Entry] = func() (string, error) {
+            data, ok := b.Entry(f.Entry)
+            if !ok {
+                return "", i18n.Errorf("包中缺少 %s", f.Entry)
+            }
+            return string(data), nil
+        }
+    }
+    for _, r := range b.Manifest.Reports {
+        views[r.Entry] = func() (string, error) {
+            data, ok := b.Entry(r.Entry)
+            if !ok {
+                return "", i18n.Errorf("包中缺少 %s", r.Entry)
+            }
+            return string(data), nil
+        }
+    }
+    paths := make([]string, 0, len(views))
+    for p := range views {
+        paths = append(paths, p)
+    }
+    return buildTree(paths), func(p string) error {
+        text, err := views[p]()
+        if err != nil {
+            return err
+        }
+        return pageText(name+":"+p, text)
+    }
+}
+
+// queryView 按文件分组列出查询快照中的匹配
+func queryView(b *bundle.Bundle, q bundle.Query) (string, error) {
+    var s strings.Builder
+    s.WriteString(i18n.Sprintf("查询: %s\n模式: %s\n运行于: %s\n", q.Query, q.Pattern, q.Ran.Local().Format("2006-01-02 15:04")))
+    if q.Error != "" {
+        s.WriteString(i18n.Sprintf("失败: %s\n", q.Error))
+        return s.String(), nil
+    }
+    more := ""
+    if q.LimitHit {
+        more = "+"
+    }
+    s.WriteString(i18n.Sprintf("匹配: %d%s\n", q.MatchCount, more))
+    matches, err := b.Matches(q)
+    if err != nil {
+        return "", err
+    }
+    sort.SliceStable(matches, func(i, j int) bool {
+        if matches[i].Repo != matches[j].Repo {
+            return matches[i].Repo < matches[j].Repo
+        }
+        return matches[i].Path < matches[j].Path
+    })
+    file := ""
+    for _, m := range matches {
+        if f := m.Repo + "/" + m.Path; f != file {
+            file = f
+            fmt.Fprintf(&s, "\n%s\n", f)
+        }
+        fmt.Fprintf(&s, "  %5d | %s\n", m.Line, m.Preview)
+    }
+    return s.String(), nil
+}
+
+func newBundleListCmd() *cobra.Command {
+    return &cobra.Command{
+        Use:   "list",
+        Short: "列出已导入的离线包",
+        Args:  cobra.NoArgs,
+        RunE: func(cmd *cobra.Command, _ []string) error {
+            names, err := bundle.Imported()
+            if err != nil {
+                return err
+            }
+            if len(names) == 0 {
+                fmt.Fprint(os.Stderr, i18n.Sprintf("还没有导入离线包\n"))
+                return nil
+            }
+            dir, err := bundle.Dir()
+            if err != nil {
+                return err
+            }
+            for _, n := range names {
+                b, err := bundle.Open(filepath.Join(dir, n+bundle.Ext))
+                if err != nil {
+                    fmt.Printf("%s  ✗ %v\n", n, err)
+                    continue
+                }
+                m := b.Manifest
+                fmt.Print(i18n.Sprintf("%s  %s  %s  %d 个查询，%d 个文件片段，%d 个报告\n", n, m.Created.Local().Format("2006-01-02"), m.Instance,
+                    len(m.Queries), len(m.Files), len(m.Reports)))
+            }
+            return nil
+        },
+    }
+}
+
+func init() {
+    rootCmd.AddCommand(newBundleCmd())
+}
@@ -0,0 +1,337 @@
+package cli
+
+import (
+    "context"
+    "fmt"
+    "io"
+    "os"
+    "regexp"
+    "sort"
+    "strings"
+    "time"
+
+    "github.com/spf13/cobra"
+    "kingbrain/insight/pkg/config"
+    "kingbrain/insight/pkg/i18n"
+    "kingbrain/insight/pkg/sg"
+)
+
+// defaultChangelogGroups 是没有配置 changelog.groups 时使用的 Conventional Commits 分组
+var defaultChangelogGroups = []config.ChangelogGroup{
+    {Title: "破坏性变更", Pattern: `^\w+(\([^)]*\))?!:|(?m)^BREAKING[ -]CHANGE:`},
+    {Title: "新功能", Pattern: `^feat\b`},
+    {Title: "问题修复", Pattern: `^fix\b`},
+    {Title: "性能优化", Pattern: `^perf\b`},
+    {Title: "回滚", Pattern: `^revert\b`},
+}
+
+const defaultTicketPattern = `\b[A-Z][A-Z0-9]+-\d+\b`
+
+// conventionalRe 拆出 Conventional Commits 标题中的类型、范围与描述
+var conventionalRe = regexp.MustCompile(`^(\w+)(?:\(([^)]*)\))?!?:\s*(.*)$`)
+
+// ChangelogEntry 是 changelog 中的一条；Scope 与 Summary 从 Conventional Commits 标题中拆出，
+// 不是这种格式的标题原样作为 Summary
+type ChangelogEntry struct {
+    Scope   string    `json:"scope,omitempty"`
+    Summary string    `json:"summary"`
+    Tickets []string  `json:"tickets,omitempty"`
+    Commit  sg.Commit `json:"commit"`
+}
+
+// ChangelogSection 是 changelog 中的一节
+type ChangelogSection struct {
+    Title   string           `json:"title"`
+    Entries []ChangelogEntry `json:"entries"`
+}
+
+// RepoChangelog 是一个仓库在 From 与 To 之间的 changelog
+type RepoChangelog struct {
+    Repo     string             `json:"repo"`
+    From     string             `json:"from,omitempty"`
+    To       string             `json:"to"`
+    Sections []ChangelogSection `json:"sections"`
+}
+
+type changelogRules struct {
+    titles    []string
+    patterns  []*regexp.Regexp
+    tickets   *regexp.Regexp
+    ticketURL string
+    all       bool
+}
+
+func newChangelogCmd() *cobra.Command {
+    var (
+        from   string
+        to     string
+        since  string
+        until  string
+        all    bool
+        merges bool
+        format string
+    )
+
+    cmd := &cobra.Command{
+        Use:   "changelog <repo>...",
+        Short: "按提交说明的前缀（feat:、fix: 等）与工单号，从提交搜索生成分组的 Markdown changelog",
+        Long: `取出 --from 与 --to 之间的提交（按祖先关系比较，同 git log from..to，从旁支合入的提交也包括在内；
+--until 再按日期截止），或用提交搜索（type:commit）取出 --since 与 --until 之间的提交，按配置文件中
+changelog.groups 的正则分组，生成 Markdown。没有配置分组时使用 Conventional Commits：破坏性变更（feat!: 或
+正文中的 BREAKING CHANGE:）、feat、fix、perf、revert。不属于任何分组但带工单号的提交归入“其他变更”，
+--all 时其余提交也列入。changelog.tickets 为工单号正则（默认形如 PROJ-123），配置 changelog.ticket_url
+（其中的 {id} 替换为工单号）后工单号写成链接。仓库名支持 * ? glob（按仓库缓存展开）。
+
+  changelog:
+    tickets: '\bPAY-\d+\b'
+    ticket_url: https://jira.example.com/browse/{id}
+    groups:
+      - {title: 新功能, pattern: '^feat\b'}
+      - {title: 问题修复, pattern: '^(fix|hotfix)\b'}
+
+  kb changelog github.com/acme/api --from v1.2.0 --to v1.3.0 > CHANGELOG-1.3.0.md
+  kb changelog 'github.com/acme/payments-*' --since 2024-06-01`,
+        Args: cobra.MinimumNArgs(1),
+        RunE: func(cmd *cobra.Command, args []string) error {
+            if err := checkFormat(format, "markdown", "json"); err != nil {
+                return err
+            }
+            if from != "" && since != "" {
+                return i18n.Errorf("--from 与 --since 只能指定一个")
+            }
+            var after, before time.Time
+            for _, d := range []struct {
+                flag, value string
+                t           *time.Time
+            }{{"--since", since, &after}, {"--until", until, &before}} {
+                if d.value == "" {
+                    continue
+                }
+                t, err := time.ParseInLocation("2006-01-02", d.value, time.Local)
+                if err != nil {
+                    return i18n.Errorf("%s 应为 YYYY-MM-DD: %w", d.flag, err)
+                }
+                *d.t = t
+            }
+            cfg, err := config.Load()
+            if err != nil {
+                return err
+            }
+            rules, err := newChangelogRules(cfg.Changelog, all)
+            if err != nil {
+                return err
+            }
+
+            ctx := cmd.Context()
+            c := sg.New()
+            repos, err := expandRepoNames(ctx, c, args)
+            if err != nil {
+                return err
+            }
+            var logs []RepoChangelog
+            for _, repo := range repos {
+                l, err := repoChangelog(ctx, c, repo, from, to, after, before, merges, rules)
+                if err != nil {
+                    return fmt.Errorf("%s: %w", repo, err)
+                }
+                logs = append(logs, l)
+            }
+            if format == "json" {
+                return writeJSON(os.Stdout, logs)
+            }
+            for i, l := range logs {
+                if i > 0 {
+                    fmt.Println()
+                }
+                writeChangelog(os.Stdout, l, rules, from == "" && since != "", after, before)
+            }
+            return nil
+        },
+    }
+
+    cmd.Flags().StringVar(&from, "from", "", "起点的标签、分支或 commit（不含）")
+    cmd.Flags().StringVar(&to, "to", "", "终点的标签、分支或 commit（默认默认分支）")
+    cmd.Flags().StringVar(&since, "since", "", "只取该日期（YYYY-MM-DD）之后的提交，代替 --from")
+    cmd.Flags().StringVar(&until, "until", "", "只取该日期（YYYY-MM-DD）之前的提交")
+    cmd.Flags().BoolVar(&all, "all", false, "不属于任何分组的提交也列入“其他变更”")
+    cmd.Flags().BoolVar(&merges, "merges", false, "包含合并提交")
+    cmd.Flags().StringVarP(&format, "format", "f", "markdown", "输出格式：markdown|json")
+    return cmd
+}
+
+func newChangelogRules(cfg config.Changelog, all bool) (*changelogRules, error) {
+    groups, builtin := cfg.Groups, false
+    if len(groups) == 0 {
+        groups, builtin = defaultChangelogGroups, true
+    }
+    r := &changelogRules{ticketURL: cfg.TicketURL, all: all}
+    for _, g := range groups {
+        re, err := regexp.Compile(g.Pattern)
+        if err != nil {
+            return nil, i18n.Errorf("changelog 分组 %s 的正则无效: %w", g.Title, err)
+        }
+        if builtin {
+            g.Title = i18n.T(g.Title)
+        }
+        r.titles = append(r.titles, g.Title)
+        r.patterns = append(r.patterns, re)
+    }
+    tickets := cfg.Tickets
+    if tickets == "" {
+        tickets = defaultTicketPattern
+    }
+    re, err := regexp.Compile(tickets)
+    if err != nil {
+        return nil, i18n.Errorf("changelog.tickets 正则无效: %w", err)
+    }
+    r.tickets = re
+    return r, nil
+}
+
+// group 返回提交所属的节；不属于任何节时返回 ""
+func (r *changelogRules) group(cm sg.Commit, tickets []string) string {
+    msg := cm.Subject
+    if cm.Body != "" {
+        msg += "\n\n" + cm.Body
+    }
+    for i, re := range r.patterns {
+        if re.MatchString(msg) {
+            return r.titles[i]
+        }
+    }
+    if len(tickets) > 0 || r.all {
+        return i18n.T("其他变更")
+    }
+    return ""
+}
+
+// changelogLimit 是 --from 时一个范围内最多取的提交数
+const changelogLimit = 10000
+
+// repoChangelog 取出 (起点, 终点] 之间的提交并分组。--from 按提交的祖先关系比较两个 revision
+// （repository.comparison，即 git log from..to），从旁支合入的更早提交也包括在内；
+// 只给了 --since/--until 时用提交搜索按日期过滤
+func repoChangelog(ctx context.Context, c *sg.Client, repo, from, to string, after, before time.Time, merges bool, rules *changelogRules) (RepoChangelog, error) {
+    l := RepoChangelog{Repo: repo, From: from, To: to}
+    if l.To == "" {
+        l.To = "HEAD"
+    }
+    var commits []sg.Commit
+    if from != "" {
+        cs, truncated, err := c.Comparison(ctx, repo, from, l.To, changelogLimit)
+        if err != nil {
+            return l, err
+        }
+        if truncated {
+            fmt.Fprint(os.Stderr, i18n.Sprintf("警告: %s 中 %s..%s 之间的提交超过 %d 个，changelog 只包含最新的 %d 个\n", repo, from, l.To, changelogLimit, changelogLimit))
+        }
+        commits = cs
+    } else {
+        parts := []string{"repo:^" + regexp.QuoteMeta(repo) + "$", "type:commit", "count:all"}
+        if to != "" {
+            parts = append(parts, "rev:"+to)
+        }
+        if !after.IsZero() {
+            parts = append(parts, fmt.Sprintf("after:%q", after.UTC().Format(time.RFC3339)))
+        }
+        if !before.IsZero() {
+            parts = append(parts, fmt.Sprintf("before:%q", before.UTC().Format(time.RFC3339)))
+        }
+        cs, limitHit, err := c.SearchCommits(ctx, strings.Join(parts, " "))
+        if err != nil {
+            return l, err
+        }
+        if limitHit {
+            fmt.Fprint(os.Stderr, i18n.Sprintf("警告: %s 的提交搜索结果被截断，changelog 可能不完整，可缩小范围后分段生成\n", repo))
+        }
+        commits = cs
+    }
+    sort.SliceStable(commits, func(i, j int) bool { return commits[i].Date.After(commits[j].Date) })
+
+    sections := map[string]*ChangelogSection{}
+    for _, cm := range commits {
+        if (cm.Merge && !merges) || (!after.IsZero() && cm.Date.Before(after)) || (!before.IsZero() && !cm.Date.Before(before)) {
+            continue
+        }
+        tickets := uniqueStrings(rules.tickets.FindAllString(cm.Subject+"\n"+cm.Body, -1))
+        title := rules.group(cm, tickets)
+        if title == "" {
+            continue
+        }
+        if cm.URL != "" {
+            cm.URL = c.URL(cm.URL)
+        }
+        e := ChangelogEntry{Summary: cm.Subject, Tickets: tickets, Commit: cm}
+        if m := conventionalRe.FindStringSubmatch(cm.Subject); m != nil {
+            e.Scope, e.Summary = m[2], m[3]
+        }
+        s, ok := sections[title]
+        if !ok {
+            s = &ChangelogSection{Title: title}
+            sections[title] = s
+        }
+        s.Entries = append(s.Entries, e)
+    }
+    for _, t := range append(append([]string{}, rules.titles...), i18n.T("其他变更")) {
+        if s, ok := sections[t]; ok {
+            l.Sections = append(l.Sections, *s)
+            delete(sections, t)
+        }
+    }
+    return l, nil
+}
+
+func uniqueStrings(in []string) []string {
+    var out []string
+    seen := map[string]bool{}
+    for _, s := range in {
+        if !seen[s] {
+            seen[s] = true
+            out = append(out, s)
+        }
+    }
+    return out
+}
+
+func writeChangelog(w io.Writer, l RepoChangelog, rules *changelogRules, byDate bool, after, before time.Time) {
+    span := l.To
+    switch {
+    case l.From != "":
+        span = l.From + "..." + l.To
+    case byDate:
+        end := l.To
+        if !before.IsZero() {
+            end = before.Format("2006-01-02")
+        }
+        span = after.Format("2006-01-02") + " → " + end
+    }
+    fmt.Fprintf(w, "## %s %s\n", l.Repo, span)
+    if len(l.Sections) == 0 {
+        fmt.Fprint(w, i18n.Sprintf("\n_没有符合条件的提交_\n"))
+        return
+    }
+    for _, s := range l.Sections {
+        fmt.Fprintf(w, "\n### %s\n\n", s.Title)
+        for _, e := range s.Entries {
+            line := "- "
+            if e.Scope != "" {
+                line += "**" + e.Scope + ":** "
+            }
+            line += e.Summary
+            short := e.Commit.OID[:min(len(e.Commit.OID), 7)]
+            if e.Commit.URL != "" {
+                line += fmt.Sprintf(" ([%s](%s))", short, e.Commit.URL)
+            } else {
+                line += " (" + short + ")"
+            }
+            if rules.ticketURL != "" {
+                for _, t := range e.Tickets {
+                    line += fmt.Sprintf(" [%s](%s)", t, strings.ReplaceAll(rules.ticketURL, "{id}", t))
+                }
+            }
+            fmt.Fprintln(w, line)
+        }
+    }
+}
+
+func init() { rootCmd.AddCommand(newChangelogCmd()) }
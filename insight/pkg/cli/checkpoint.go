@@ -0,0 +1,84 @@
+package cli
+
+import (
+    "bytes"
+    "encoding/json"
+    "errors"
+    "os"
+    "sync"
+)
+
+// checkpoint 是批量任务的日志文件：每完成一条查询追加一行 JSON 并 fsync，
+// 进程中途退出后可用 --resume 跳过已成功的查询
+type checkpoint struct {
+    mu   sync.Mutex
+    path string
+    f    *os.File
+}
+
+type checkpointEntry struct {
+    Key    string      `json:"key"`
+    Result batchResult `json:"result"`
+}
+
+func checkpointKey(pattern string, q batchQuery) string {
+    return pattern + "\x00" + q.Name + "\x00" + q.Query
+}
+
+// openCheckpoint 打开日志；resume 时读回已成功的结果，否则清空重写
+func openCheckpoint(path string, resume bool) (*checkpoint, map[string]batchResult, error) {
+    done := map[string]batchResult{}
+    flag := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+    if resume {
+        flag = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+        b, err := os.ReadFile(path)
+        if err != nil && !errors.Is(err, os.ErrNotExist) {
+            return nil, nil, err
+        }
+        if err == nil {
+            // 最后一行可能因崩溃只写了一半：截掉最后一个换行之后的内容，否则新记录会接在半行后面
+            keep := bytes.LastIndexByte(b, '\n') + 1
+            if keep < len(b) {
+                if err := os.Truncate(path, int64(keep)); err != nil {
+                    return nil, nil, err
+                }
+            }
+            for _, line := range bytes.Split(b[:keep], []byte("\n")) {
+                var e checkpointEntry
+                // 无法解析的行（如旧版本写坏的）直接忽略
+                if json.Unmarshal(line, &e) == nil && e.Result.Error == "" {
+                    done[e.Key] = e.Result
+                }
+            }
+        }
+    }
+    f, err := os.OpenFile(path, flag, 0o644)
+    if err != nil {
+        return nil, nil, err
+    }
+    return &checkpoint{path: path, f: f}, done, nil
+}
+
+func (c *checkpoint) record(key string, r batchResult) error {
+    b, err := json.Marshal(checkpointEntry{Key: key, Result: r})
+    if err != nil {
+        return err
+    }
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    if _, err := c.f.Write(append(b, '\n')); err != nil {
+        return err
+    }
+    return c.f.Sync()
+}
+
+// close 关闭日志；全部成功时删除，保留失败的日志供下次 --resume
+func (c *checkpoint) close(success bool) error {
+    if err := c.f.Close(); err != nil {
+        return err
+    }
+    if success {
+        return os.Remove(c.path)
+    }
+    return nil
+}
@@ -0,0 +1,359 @@
+package cli
+
+import (
+    "context"
+    "fmt"
+    "math"
+    "os"
+    "regexp"
+    "sort"
+    "strconv"
+    "strings"
+    "sync"
+
+    "github.com/spf13/cobra"
+    "kingbrain/insight/pkg/i18n"
+    "kingbrain/insight/pkg/loc"
+    "kingbrain/insight/pkg/preview"
+    "kingbrain/insight/pkg/progress"
+    "kingbrain/insight/pkg/sg"
+)
+
+// testFileRe 匹配常见的测试文件命名与测试目录
+var testFileRe = regexp.MustCompile(`(^|/)(tests?|__tests__|spec)/|_test\.(go|py)$|(^|/)test_[^/]*\.py$|\.(test|spec)\.[cm]?[jt]sx?$|Tests?\.(java|kt|cs)$|_spec\.rb$`)
+
+// RepoComparison 是 compare-repos 矩阵中的一行；tests 为测试文件占源码文件的百分比
+type RepoComparison struct {
+    Repo    string             `json:"repo"`
+    Metrics map[string]float64 `json:"metrics"`
+    Errors  map[string]string  `json:"errors,omitempty"`
+}
+
+// repoMetric 是矩阵的一列；count 为匹配计数，--per-kloc 时按代码行数归一化
+type repoMetric struct {
+    name    string
+    count   bool
+    measure func(ctx context.Context, c *sg.Client, repo string) (float64, error)
+}
+
+func newCompareReposCmd() *cobra.Command {
+    var (
+        names      []string
+        custom     []string
+        deprecated string
+        pattern    string
+        sortBy     string
+        asc        bool
+        perKLOC    bool
+        exclude    []string
+        parallel   int
+        format     string
+    )
+
+    cmd := &cobra.Command{
+        Use:   "compare-repos <repo>...",
+        Short: "对一组仓库计算代码行数、测试文件占比、TODO 数、deprecated API 数等指标，输出可排序的对比矩阵",
+        Long: `内置指标：loc（代码行数，同 count-loc-remote 的 tar 方式）、tests（测试文件占源码文件的百分比）、
+todos（TODO/FIXME/HACK 数）、deprecated（--deprecated 查询的匹配数）。--metric 名称=查询 可加入自定义
+的计数指标（按 -p 的模式搜索，可重复）。--per-kloc 把计数指标换算成每千行代码的数量，便于比较大小不同的仓库。
+默认的 deprecated 查询只能找到标记为 deprecated 的声明，要统计对某些 API 的调用请换成具体的查询。
+仓库名支持 * ? glob（按仓库缓存展开）。
+
+  kb compare-repos 'github.com/acme/*' --sort todos --per-kloc
+  kb compare-repos github.com/acme/api github.com/acme/web --deprecated 'ioutil\.|errors\.Wrap\(' \
+      --metric 'panics=panic\(' -f csv > matrix.csv`,
+        Args: cobra.MinimumNArgs(1),
+        RunE: func(cmd *cobra.Command, args []string) error {
+            if err := checkFormat(format, "text", "csv", "json"); err != nil {
+                return err
+            }
+            builtin := map[string]repoMetric{
+                "loc": {name: "loc", measure: func(ctx context.Context, c *sg.Client, repo string) (float64, error) {
+                    r, err := countRemoteLOC(ctx, c, repo, "", "tar", 20000, exclude)
+                    if err != nil {
+                        return 0, err
+                    }
+                    code := 0
+                    for _, l := range r.Languages {
+                        code += l.Code
+                    }
+                    return float64(code), nil
+                }},
+                "tests":      {name: "tests", measure: testFileRatio},
+                "todos":      countMetric("todos", `\b(TODO|FIXME|HACK)\b`, "regexp"),
+                "deprecated": countMetric("deprecated", deprecated, pattern),
+            }
+            metrics, err := selectRepoMetrics(names, custom, pattern, builtin)
+            if err != nil {
+                return err
+            }
+            if perKLOC && !hasRepoMetric(metrics, "loc") {
+                metrics = append([]repoMetric{builtin["loc"]}, metrics...)
+            }
+            if sortBy == "" {
+                sortBy = metrics[0].name
+            } else if !hasRepoMetric(metrics, sortBy) {
+                return i18n.Errorf("--sort %s 不是选中的指标", sortBy)
+            }
+
+            ctx := cmd.Context()
+            c := sg.New()
+            repos, err := expandRepoNames(ctx, c, args)
+            if err != nil {
+                return err
+            }
+            rows := compareRepos(ctx, c, repos, metrics, parallel)
+            if perKLOC {
+                normalizePerKLOC(rows, metrics)
+            }
+            sortComparisons(rows, sortBy, asc)
+
+            switch format {
+            case "json":
+                return writeJSON(os.Stdout, rows)
+            case "csv":
+                header := []string{"repo"}
+                for _, m := range metrics {
+                    header = append(header, m.name)
+                }
+                var records [][]string
+                for _, r := range rows {
+                    rec := []string{r.Repo}
+                    for _, m := range metrics {
+                        v, ok := r.Metrics[m.name]
+                        if !ok {
+                            rec = append(rec, "")
+                            continue
+                        }
+                        rec = append(rec, strconv.FormatFloat(v, 'f', -1, 64))
+                    }
+                    records = append(records, rec)
+                }
+                return writeCSV(os.Stdout, header, records)
+            }
+            printComparison(rows, metrics, perKLOC)
+            return nil
+        },
+    }
+
+    cmd.Flags().StringSliceVar(&names, "metrics", []string{"loc", "tests", "todos", "deprecated"}, "要计算的内置指标：loc|tests|todos|deprecated")
+    cmd.Flags().StringArrayVar(&custom, "metric", nil, "自定义计数指标，格式 名称=查询（可重复）")
+    cmd.Flags().StringVar(&deprecated, "deprecated", `(?i)@deprecated\b|\bDeprecated:|DeprecationWarning|\[Obsolete`, "deprecated 指标的查询")
+    cmd.Flags().StringVarP(&pattern, "pattern", "p", "regexp", "--deprecated 与 --metric 查询的搜索模式：literal|regexp|structural")
+    cmd.Flags().StringVar(&sortBy, "sort", "", "按该指标排序（默认第一个指标），从大到小")
+    cmd.Flags().BoolVar(&asc, "asc", false, "从小到大排序")
+    cmd.Flags().BoolVar(&perKLOC, "per-kloc", false, "计数指标换算成每千行代码的数量（会同时计算 loc）")
+    cmd.Flags().StringSliceVar(&exclude, "exclude-dir", nil, "统计 loc 时跳过这些目录名，如 vendor,node_modules")
+    cmd.Flags().IntVarP(&parallel, "parallel", "j", 4, "同时计算的仓库数")
+    cmd.Flags().StringVarP(&format, "format", "f", "text", "输出格式：text|csv|json")
+    return cmd
+}
+
+// countMetric 返回以查询在仓库中的匹配数为值的指标
+func countMetric(name, query, pattern string) repoMetric {
+    return repoMetric{name: name, count: true, measure: func(ctx context.Context, c *sg.Client, repo string) (float64, error) {
+        res, err := c.Uncapped().SearchEach(ctx, buildQuery(query, "repo:^"+regexp.QuoteMeta(repo)+"$", "count:all"), pattern,
+            func(*sg.SearchResults, sg.FileMatch) error { return nil })
+        if err != nil {
+            return 0, err
+        }
+        return float64(res.MatchCount), nil
+    }}
+}
+
+// testFileRatio 按文件树计算测试文件占源码文件（能识别语言的文件）的百分比
+func testFileRatio(ctx context.Context, c *sg.Client, repo string) (float64, error) {
+    paths, err := c.Tree(ctx, repo, "")
+    if err != nil {
+        return 0, err
+    }
+    src, tests := 0, 0
+    for _, p := range paths {
+        if loc.Detect(p) == nil {
+            continue
+        }
+        src++
+        if testFileRe.MatchString(p) {
+            tests++
+        }
+    }
+    if src == 0 {
+        return 0, nil
+    }
+    return float64(tests) * 100 / float64(src), nil
+}
+
+// selectRepoMetrics 按 --metrics 的顺序取内置指标，再接上 --metric 定义的自定义指标
+func selectRepoMetrics(names, custom []string, pattern string, builtin map[string]repoMetric) ([]repoMetric, error) {
+    var out []repoMetric
+    seen := map[string]bool{}
+    for _, n := range names {
+        m, ok := builtin[strings.TrimSpace(n)]
+        if !ok {
+            return nil, i18n.Errorf("未知指标 %q（可选：loc、tests、todos、deprecated，自定义指标用 --metric）", n)
+        }
+        if !seen[m.name] {
+            seen[m.name] = true
+            out = append(out, m)
+        }
+    }
+    for _, spec := range custom {
+        name, q, ok := strings.Cut(spec, "=")
+        name = strings.TrimSpace(name)
+        if !ok || name == "" || q == "" {
+            return nil, i18n.Errorf("--metric 格式应为 名称=查询: %q", spec)
+        }
+        if seen[name] {
+            return nil, i18n.Errorf("指标 %s 重复", name)
+        }
+        seen[name] = true
+        out = append(out, countMetric(name, q, pattern))
+    }
+    if len(out) == 0 {
+        return nil, i18n.Errorf("没有选中任何指标")
+    }
+    return out, nil
+}
+
+func hasRepoMetric(metrics []repoMetric, name string) bool {
+    for _, m := range metrics {
+        if m.name == name {
+            return true
+        }
+    }
+    return false
+}
+
+// compareRepos 并发计算每个仓库的全部指标；单个指标失败只记在该仓库的 Errors 中
+func compareRepos(ctx context.Context, c *sg.Client, repos []string, metrics []repoMetric, parallel int) []RepoComparison {
+    rows := make([]RepoComparison, len(repos))
+    bar := progress.New(len(repos))
+    idx := make(chan int)
+    var wg sync.WaitGroup
+    for w := 0; w < max(parallel, 1); w++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            for i := range idx {
+                repo := repos[i]
+                bar.Begin(repo)
+                r := RepoComparison{Repo: repo, Metrics: map[string]float64{}}
+                var failed error
+                for _, m := range metrics {
+                    v, err := m.measure(ctx, c, repo)
+                    if err != nil {
+                        if r.Errors == nil {
+                            r.Errors = map[string]string{}
+                        }
+                        r.Errors[m.name] = err.Error()
+                        failed = err
+                        continue
+                    }
+                    r.Metrics[m.name] = v
+                }
+                rows[i] = r
+                bar.End(repo, failed)
+            }
+        }()
+    }
+    for i := range repos {
+        idx <- i
+    }
+    close(idx)
+    wg.Wait()
+    bar.Finish()
+    return rows
+}
+
+// normalizePerKLOC 把计数指标除以千行代码数；没有代码行数的仓库去掉这些值
+func normalizePerKLOC(rows []RepoComparison, metrics []repoMetric) {
+    for _, r := range rows {
+        code, ok := r.Metrics["loc"]
+        for _, m := range metrics {
+            if !m.count {
+                continue
+            }
+            if v, has := r.Metrics[m.name]; has {
+                if ok && code > 0 {
+                    r.Metrics[m.name] = v / (code / 1000)
+                } else {
+                    delete(r.Metrics, m.name)
+                }
+            }
+        }
+    }
+}
+
+// sortComparisons 按指标排序，没有该指标值的仓库排在最后
+func sortComparisons(rows []RepoComparison, by string, asc bool) {
+    sort.SliceStable(rows, func(i, j int) bool {
+        a, okA := rows[i].Metrics[by]
+        b, okB := rows[j].Metrics[by]
+        switch {
+        case okA != okB:
+            return okA
+        case !okA || a == b:
+            return rows[i].Repo < rows[j].Repo
+        case asc:
+            return a < b
+        }
+        return a > b
+    })
+}
+
+func printComparison(rows []RepoComparison, metrics []repoMetric, perKLOC bool) {
+    width := len("repo")
+    for _, r := range rows {
+        width = max(width, preview.Width(r.Repo))
+    }
+    format := func(m repoMetric, v float64) string {
+        switch {
+        case m.name == "tests":
+            return fmt.Sprintf("%.1f%%", v)
+        case m.count && perKLOC:
+            return fmt.Sprintf("%.2f", v)
+        }
+        return strconv.FormatFloat(math.Round(v), 'f', 0, 64)
+    }
+    head := preview.Pad("repo", width)
+    for _, m := range metrics {
+        name := m.name
+        if m.count && perKLOC {
+            name += "/kloc"
+        }
+        head += "  " + preview.PadLeft(name, max(len(name), 10))
+    }
+    fmt.Println(head)
+    var errs []string
+    for _, r := range rows {
+        line := preview.Pad(r.Repo, width)
+        for _, m := range metrics {
+            name := m.name
+            if m.count && perKLOC {
+                name += "/kloc"
+            }
+            cell := "-"
+            if v, ok := r.Metrics[m.name]; ok {
+                cell = format(m, v)
+            } else if _, failed := r.Errors[m.name]; failed {
+                cell = "ERR"
+            }
+            line += "  " + preview.PadLeft(cell, max(len(name), 10))
+        }
+        fmt.Println(line)
+        for _, m := range metrics {
+            if e, ok := r.Errors[m.name]; ok {
+                errs = append(errs, fmt.Sprintf("  %s %s: %s", r.Repo, m.name, e))
+            }
+        }
+    }
+    if len(errs) > 0 {
+        fmt.Println()
+        fmt.Println(i18n.T("以下指标计算失败："))
+        for _, e := range errs {
+            fmt.Println(e)
+        }
+    }
+}
+
+func init() { rootCmd.AddCommand(newCompareReposCmd()) }
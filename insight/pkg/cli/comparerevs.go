@@ -0,0 +1,107 @@
+package cli
+
+import (
+    "context"
+    "fmt"
+    "regexp"
+    "sort"
+
+    "github.com/spf13/cobra"
+    "kingbrain/insight/pkg/diff"
+    "kingbrain/insight/pkg/i18n"
+    "kingbrain/insight/pkg/sg"
+)
+
+func newCompareRevsCmd() *cobra.Command {
+    var (
+        query    string
+        pattern  string
+        ctxLines int
+    )
+
+    cmd := &cobra.Command{
+        Use:   "compare-revs <repo> <rev-a> <rev-b> [path]",
+        Short: "通过 API 对比文件或搜索结果在两个 revision 之间的差异（unified diff），无需本地克隆",
+        Long: `对比两个 revision：
+
+  kb compare-revs github.com/acme/api v1.2.0 v1.3.0 internal/server.go
+  kb compare-revs github.com/acme/api v1.2.0 main --query 'deprecatedCall('`,
+        Args: cobra.RangeArgs(3, 4),
+        RunE: func(cmd *cobra.Command, args []string) error {
+            repo, revA, revB := args[0], args[1], args[2]
+            c := sg.New()
+            var a, b []string
+            var err error
+            switch {
+            case len(args) == 4 && query == "":
+                a, b, err = revFiles(cmd.Context(), c, repo, revA, revB, args[3])
+            case len(args) == 3 && query != "":
+                a, b, err = revResultSets(cmd.Context(), c, repo, revA, revB, query, pattern)
+            default:
+                return i18n.Errorf("需要指定文件路径或 --query（二选一）")
+            }
+            if err != nil {
+                return err
+            }
+            name := repo
+            if len(args) == 4 {
+                name += "/" + args[3]
+            }
+            out := diff.Unified(name+"@"+revA, name+"@"+revB, a, b, ctxLines)
+            if out == "" {
+                fmt.Println(i18n.T("两个 revision 没有差异"))
+                return nil
+            }
+            fmt.Print(out)
+            return nil
+        },
+    }
+
+    cmd.Flags().StringVarP(&query, "query", "q", "", "对比该查询在两个 revision 上的结果集而不是文件")
+    cmd.Flags().StringVarP(&pattern, "pattern", "p", "literal", "--query 的搜索模式：literal|regexp|structural")
+    cmd.Flags().IntVarP(&ctxLines, "unified", "U", 3, "上下文行数")
+    return cmd
+}
+
+func revFiles(ctx context.Context, c *sg.Client, repo, revA, revB, path string) ([]string, []string, error) {
+    a, err := c.FileContent(ctx, repo, revA, path)
+    if err != nil {
+        return nil, nil, err
+    }
+    b, err := c.FileContent(ctx, repo, revB, path)
+    if err != nil {
+        return nil, nil, err
+    }
+    return diff.Lines(a), diff.Lines(b), nil
+}
+
+// revResultSets 把两个 revision 上的匹配渲染成排好序的 "路径: 预览" 行再做对比。
+// 不带行号，避免无关改动导致的行号漂移淹没真正的增减
+func revResultSets(ctx context.Context, c *sg.Client, repo, revA, revB, query, pattern string) ([]string, []string, error) {
+    render := func(rev string) ([]string, error) {
+        q := buildQuery(fmt.Sprintf("repo:^%s$@%s", regexp.QuoteMeta(repo), rev), query, "count:all")
+        res, err := c.Search(ctx, q, pattern)
+        if err != nil {
+            return nil, err
+        }
+        var lines []string
+        for _, fm := range res.Results {
+            for _, lm := range fm.LineMatches {
+                lines = append(lines, fm.File.Path+": "+lm.Preview)
+            }
+        }
+        sort.Strings(lines)
+        return lines, nil
+    }
+    a, err := render(revA)
+    if err != nil {
+        return nil, nil, err
+    }
+    b, err := render(revB)
+    if err != nil {
+        return nil, nil, err
+    }
+    return a, b, nil
+}
+
+func init() { rootCmd.AddCommand(newCompareRevsCmd()) }
@@ -0,0 +1,197 @@
+package cli
+
+import (
+    "crypto/sha256"
+    "fmt"
+    "io"
+    "os"
+    "path"
+    "sort"
+    "strings"
+
+    "github.com/spf13/cobra"
+    "kingbrain/insight/pkg/config"
+    "kingbrain/insight/pkg/i18n"
+    "kingbrain/insight/pkg/llm"
+    "kingbrain/insight/pkg/sg"
+    "kingbrain/insight/pkg/vecstore"
+)
+
+// contextSnippet 是候选片段及其得分
+type contextSnippet struct {
+    vecstore.Chunk
+    Hits   int
+    Score  float64
+    Tokens int
+}
+
+func newContextCmd() *cobra.Command {
+    var (
+        pattern  string
+        repos    []string
+        budget   int
+        output   string
+        noRedact bool
+    )
+
+    cmd := &cobra.Command{
+        Use:   "context <query|->",
+        Short: "为查询挑选最有价值的代码片段，在 token 预算内输出 Markdown 上下文包，可直接贴进 LLM 提示词",
+        Long: `片段以匹配所在的函数为单位（不支持的语言取匹配行上下 10 行），按以下规则排序后在预算内贪心选取：
+包含的匹配行越多、越紧凑得分越高；有函数名的完整定义优先；同一文件已选过的片段依次降权，
+让结果覆盖更多文件；内容完全相同的片段（如 vendor 的副本）只保留一份。
+默认按内置规则与 llm.redact 脱敏；token 数为估算值。
+
+  kb context --budget 8000 'lang:go RetryPolicy'
+  kb context --budget 4000 'repo:acme/api func.*Handler' -p regexp -o ctx.md`,
+        Args: cobra.ExactArgs(1),
+        RunE: func(cmd *cobra.Command, args []string) error {
+            q, err := readQueryArg(args[0])
+            if err != nil {
+                return err
+            }
+            cfg, err := config.Load()
+            if err != nil {
+                return err
+            }
+            red, err := llm.NewRedactor(cfg.LLM.Redact)
+            if err != nil {
+                return err
+            }
+            query := buildQuery(q, repoFilter(repos))
+            c := sg.New()
+            res, err := c.Search(cmd.Context(), query, pattern)
+            if err != nil {
+                return err
+            }
+            picked, total := selectContext(rankContext(res, chunkMatches(cmd.Context(), c, res)), budget)
+            if !noRedact {
+                for i := range picked {
+                    picked[i].Text, _ = red.Redact(picked[i].Text)
+                }
+            }
+
+            var w io.Writer = os.Stdout
+            if output != "" {
+                f, err := os.Create(output)
+                if err != nil {
+                    return err
+                }
+                defer f.Close()
+                w = f
+            }
+            writeContextBundle(w, q, picked)
+            fmt.Fprint(os.Stderr, i18n.Sprintf("选取 %d/%d 个片段，约 %d token（预算 %d）\n", len(picked), total, contextTokens(picked), budget))
+            return nil
+        },
+    }
+
+    cmd.Flags().StringVarP(&pattern, "pattern", "p", "literal", "搜索模式：literal|regexp|structural")
+    cmd.Flags().StringSliceVar(&repos, "repo", nil, "限定仓库（可重复，支持正则；含 * ? 的 glob 按仓库缓存展开）")
+    cmd.Flags().IntVar(&budget, "budget", 8000, "输出的估算 token 上限")
+    cmd.Flags().StringVarP(&output, "output", "o", "", "写入文件而不是 stdout")
+    cmd.Flags().BoolVar(&noRedact, "no-redact", false, "不做脱敏")
+    return cmd
+}
+
+// rankContext 统计每个片段覆盖的匹配行并打分，去掉内容重复的片段
+func rankContext(res *sg.SearchResults, chunks []vecstore.Chunk) []contextSnippet {
+    hits := map[string][]int{}
+    for _, fm := range res.Results {
+        key := fm.Repository.Name + "\x00" + fm.File.Path
+        for _, m := range fm.LineMatches {
+            hits[key] = append(hits[key], m.LineNumber+1)
+        }
+    }
+    var out []contextSnippet
+    seen := map[[32]byte]bool{}
+    for _, ch := range chunks {
+        sum := sha256.Sum256([]byte(strings.TrimSpace(ch.Text)))
+        if seen[sum] {
+            continue
+        }
+        seen[sum] = true
+        s := contextSnippet{Chunk: ch, Tokens: llm.EstimateTokens(ch.Text) + 20}
+        for _, l := range hits[ch.Repo+"\x00"+ch.Path] {
+            if l >= ch.Start && l <= ch.End {
+                s.Hits++
+            }
+        }
+        lines := float64(ch.End - ch.Start + 1)
+        s.Score = float64(s.Hits) * (1 + float64(s.Hits)*10/lines)
+        if ch.Name != "" {
+            s.Score *= 1.5
+        }
+        out = append(out, s)
+    }
+    sort.SliceStable(out, func(i, j int) bool { return out[i].Score > out[j].Score })
+    return out
+}
+
+// selectContext 按得分贪心选取，同一文件每多选一个片段其余片段得分乘 0.6；返回选中的片段与候选总数
+func selectContext(cands []contextSnippet, budget int) ([]contextSnippet, int) {
+    total := len(cands)
+    var picked []contextSnippet
+    perFile := map[string]int{}
+    left := budget
+    for len(cands) > 0 {
+        best, bestScore := -1, 0.0
+        for i, c := range cands {
+            if c.Tokens > left {
+                continue
+            }
+            score := c.Score
+            for k := 0; k < perFile[c.Repo+"/"+c.Path]; k++ {
+                score *= 0.6
+            }
+            if best < 0 || score > bestScore {
+                best, bestScore = i, score
+            }
+        }
+        if best < 0 {
+            break
+        }
+        c := cands[best]
+        cands = append(cands[:best], cands[best+1:]...)
+        picked = append(picked, c)
+        perFile[c.Repo+"/"+c.Path]++
+        left -= c.Tokens
+    }
+    // 输出时按文件与行号排列，便于阅读
+    sort.Slice(picked, func(i, j int) bool {
+        a, b := picked[i], picked[j]
+        if a.Repo+a.Path != b.Repo+b.Path {
+            return a.Repo+"/"+a.Path < b.Repo+"/"+b.Path
+        }
+        return a.Start < b.Start
+    })
+    return picked, total
+}
+
+func contextTokens(snips []contextSnippet) int {
+    n := 0
+    for _, s := range snips {
+        n += s.Tokens
+    }
+    return n
+}
+
+// writeContextBundle 输出 Markdown：每个片段一个标题加代码块；内容本身含 ``` 时加长围栏
+func writeContextBundle(w io.Writer, query string, snips []contextSnippet) {
+    fmt.Fprintf(w, "# Code context: %s\n\n", query)
+    fmt.Fprintf(w, "<!-- %d snippets, ~%d tokens -->\n", len(snips), contextTokens(snips))
+    for _, s := range snips {
+        title := fmt.Sprintf("%s/%s:%d-%d", s.Repo, s.Path, s.Start, s.End)
+        if s.Name != "" {
+            title += " (" + s.Name + ")"
+        }
+        fence := "```"
+        for strings.Contains(s.Text, fence) {
+            fence += "`"
+        }
+        lang := strings.TrimPrefix(path.Ext(s.Path), ".")
+        fmt.Fprintf(w, "\n## %s\n\n%s%s\n%s\n%s\n", title, fence, lang, strings.TrimRight(s.Text, "\n"), fence)
+    }
+}
+
+func init() { rootCmd.AddCommand(newContextCmd()) }
@@ -0,0 +1,288 @@
+package cli
+
+import (
+    "context"
+    "fmt"
+    "os"
+    "sort"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+
+    "github.com/spf13/cobra"
+    "kingbrain/insight/pkg/i18n"
+    "kingbrain/insight/pkg/progress"
+    "kingbrain/insight/pkg/sg"
+)
+
+// Contributor 是一位作者在仓库（或目录）中的提交统计；同一邮箱的提交算作同一人
+type Contributor struct {
+    Name    string    `json:"name"`
+    Email   string    `json:"email,omitempty"`
+    Commits int       `json:"commits"`
+    Share   float64   `json:"share"`
+    First   time.Time `json:"first"`
+    Last    time.Time `json:"last"`
+}
+
+// ContributorReport 是一个仓库（或目录）的贡献者统计。BusFactor 为提交数合计超过 --threshold
+// 比例所需的最少作者数，越小说明知识越集中在少数人手里
+type ContributorReport struct {
+    Repo         string        `json:"repo"`
+    Path         string        `json:"path,omitempty"`
+    Commits      int           `json:"commits"`
+    Authors      int           `json:"authors"`
+    BusFactor    int           `json:"busFactor"`
+    LastActivity time.Time     `json:"lastActivity,omitempty"`
+    Contributors []Contributor `json:"contributors"`
+    Error        string        `json:"error,omitempty"`
+}
+
+func newContributorsCmd() *cobra.Command {
+    var (
+        path      string
+        rev       string
+        since     string
+        limit     int
+        merges    bool
+        threshold float64
+        top       int
+        parallel  int
+        format    string
+    )
+
+    cmd := &cobra.Command{
+        Use:   "contributors [repo|dir]...",
+        Short: "统计仓库或目录的贡献者：提交数、最近活跃时间与 bus factor",
+        Long: `参数为仓库名（支持 * ? glob，按仓库缓存展开）时通过 Sourcegraph 的提交历史统计，为本地目录
+（默认当前目录）时直接读 git log，只统计该目录下的提交。同一邮箱的提交算作同一人，默认不计合并提交。
+
+bus factor 是提交数合计超过 --threshold（默认一半）所需的最少作者数：为 1 表示一个人写了
+大部分代码，这个人离开后就没有人熟悉它了。配合 --since 只看最近的提交，结果更能反映现状。
+
+  kb contributors github.com/acme/api --path services/billing --since 2024-01-01
+  kb contributors 'github.com/acme/*' -f csv > contributors.csv
+  kb contributors . --top 0`,
+        RunE: func(cmd *cobra.Command, args []string) error {
+            if err := checkFormat(format, "text", "csv", "json"); err != nil {
+                return err
+            }
+            if threshold <= 0 || threshold >= 1 {
+                return i18n.Errorf("--threshold 应在 0 与 1 之间")
+            }
+            var from time.Time
+            if since != "" {
+                t, err := time.ParseInLocation("2006-01-02", since, time.Local)
+                if err != nil {
+                    return i18n.Errorf("--since 应为 YYYY-MM-DD: %w", err)
+                }
+                from = t
+            }
+            if len(args) == 0 {
+                args = []string{"."}
+            }
+
+            ctx := cmd.Context()
+            c := sg.New()
+            var targets []string
+            var remote []string
+            for _, a := range args {
+                if isDir(a) {
+                    targets = append(targets, a)
+                    continue
+                }
+                remote = append(remote, a)
+            }
+            if len(remote) > 0 {
+                repos, err := expandRepoNames(ctx, c, remote)
+                if err != nil {
+                    return err
+                }
+                targets = append(targets, repos...)
+            }
+
+            reports := make([]ContributorReport, len(targets))
+            bar := progress.New(len(targets))
+            idx := make(chan int)
+            var wg sync.WaitGroup
+            for w := 0; w < max(parallel, 1); w++ {
+                wg.Add(1)
+                go func() {
+                    defer wg.Done()
+                    for i := range idx {
+                        t := targets[i]
+                        bar.Begin(t)
+                        r, err := contributorReport(ctx, c, t, rev, path, from, limit, merges, threshold)
+                        if err != nil {
+                            r.Error = err.Error()
+                        }
+                        reports[i] = r
+                        bar.End(t, err)
+                    }
+                }()
+            }
+            for i := range targets {
+                idx <- i
+            }
+            close(idx)
+            wg.Wait()
+            bar.Finish()
+
+            failed := 0
+            for _, r := range reports {
+                if r.Error != "" {
+                    failed++
+                }
+            }
+            if failed == len(reports) {
+                return i18n.Errorf("所有仓库的提交历史都读取失败")
+            }
+
+            switch format {
+            case "json":
+                return writeJSON(os.Stdout, reports)
+            case "csv":
+                var rows [][]string
+                for _, r := range reports {
+                    for _, p := range r.Contributors {
+                        rows = append(rows, []string{r.Repo, r.Path, p.Name, p.Email, strconv.Itoa(p.Commits),
+                            strconv.FormatFloat(p.Share, 'f', 4, 64), p.First.Format(time.RFC3339), p.Last.Format(time.RFC3339),
+                            strconv.Itoa(r.BusFactor)})
+                    }
+                }
+                return writeCSV(os.Stdout, []string{"repo", "path", "name", "email", "commits", "share", "first", "last", "bus_factor"}, rows)
+            }
+            for i, r := range reports {
+                if r.Error != "" {
+                    continue
+                }
+                if i > 0 {
+                    fmt.Println()
+                }
+                printContributors(r, top)
+            }
+            return nil
+        },
+    }
+
+    cmd.Flags().StringVar(&path, "path", "", "只统计修改过该路径（仓库内的文件或目录）的提交")
+    cmd.Flags().StringVar(&rev, "rev", "", "从该分支、标签或 commit 往回统计（默认 HEAD）")
+    cmd.Flags().StringVar(&since, "since", "", "只统计该日期（YYYY-MM-DD）之后的提交")
+    cmd.Flags().IntVar(&limit, "limit", 10000, "每个仓库最多读取的提交数（0 表示不限）")
+    cmd.Flags().BoolVar(&merges, "merges", false, "把合并提交也计入")
+    cmd.Flags().Float64Var(&threshold, "threshold", 0.5, "计算 bus factor 时要覆盖的提交比例")
+    cmd.Flags().IntVar(&top, "top", 10, "text 输出中每个仓库列出的贡献者数（0 表示全部）")
+    cmd.Flags().IntVarP(&parallel, "parallel", "j", 4, "同时统计的仓库数")
+    cmd.Flags().StringVarP(&format, "format", "f", "text", "输出格式：text|csv|json")
+    return cmd
+}
+
+// contributorReport 读取一个仓库或本地目录的提交历史并汇总；本地目录的 Repo 为 origin 对应的仓库名，
+// Path 为目录在仓库内的路径
+func contributorReport(ctx context.Context, c *sg.Client, target, rev, path string, since time.Time, limit int, merges bool, threshold float64) (ContributorReport, error) {
+    r := ContributorReport{Repo: target, Path: path}
+    var (
+        commits []sg.Commit
+        err     error
+    )
+    if isDir(target) {
+        if p, perr := sccPoint(target); perr == nil {
+            r.Repo = p.Repo
+            if p.Dir != "." {
+                r.Path = strings.TrimSuffix(p.Dir+"/"+path, "/")
+            }
+        }
+        commits, err = localCommits(target, rev, path, since, limit)
+    } else {
+        commits, err = c.Commits(ctx, target, rev, path, since, limit)
+    }
+    if err != nil {
+        return r, err
+    }
+
+    byKey := map[string]*Contributor{}
+    for _, cm := range commits {
+        if cm.Merge && !merges {
+            continue
+        }
+        key := strings.ToLower(cm.Email)
+        if key == "" {
+            key = cm.Author
+        }
+        p, ok := byKey[key]
+        if !ok {
+            p = &Contributor{Name: cm.Author, Email: cm.Email, First: cm.Date, Last: cm.Date}
+            byKey[key] = p
+        }
+        p.Commits++
+        if cm.Date.Before(p.First) {
+            p.First = cm.Date
+        }
+        if cm.Date.After(p.Last) {
+            // 以最近一次提交的署名为准，改过名字的作者按新名字显示
+            p.Last, p.Name = cm.Date, cm.Author
+        }
+        r.Commits++
+        if cm.Date.After(r.LastActivity) {
+            r.LastActivity = cm.Date
+        }
+    }
+    for _, p := range byKey {
+        p.Share = float64(p.Commits) / float64(r.Commits)
+        r.Contributors = append(r.Contributors, *p)
+    }
+    sort.Slice(r.Contributors, func(i, j int) bool {
+        a, b := r.Contributors[i], r.Contributors[j]
+        if a.Commits != b.Commits {
+            return a.Commits > b.Commits
+        }
+        return a.Last.After(b.Last)
+    })
+    r.Authors = len(r.Contributors)
+    r.BusFactor = busFactor(r.Contributors, r.Commits, threshold)
+    return r, nil
+}
+
+// busFactor 返回提交数从多到少累加、超过 threshold 比例所需的作者数；contributors 已按提交数排序
+func busFactor(contributors []Contributor, total int, threshold float64) int {
+    sum := 0
+    for i, p := range contributors {
+        sum += p.Commits
+        if float64(sum) > threshold*float64(total) {
+            return i + 1
+        }
+    }
+    return len(contributors)
+}
+
+func printContributors(r ContributorReport, top int) {
+    title := r.Repo
+    if r.Path != "" {
+        title += "/" + r.Path
+    }
+    if r.Commits == 0 {
+        fmt.Print(i18n.Sprintf("%s：没有符合条件的提交\n", title))
+        return
+    }
+    fmt.Print(i18n.Sprintf("%s：%d 个提交，%d 位作者，bus factor %d，最近活跃 %s\n", title, r.Commits, r.Authors,
+        r.BusFactor, r.LastActivity.Format("2006-01-02")))
+    list := r.Contributors
+    if top > 0 && len(list) > top {
+        list = list[:top]
+    }
+    fmt.Printf("  %7s  %6s  %-10s  %-10s  %s\n", "commits", "share", "first", "last", "author")
+    for _, p := range list {
+        who := p.Name
+        if p.Email != "" {
+            who += " <" + p.Email + ">"
+        }
+        fmt.Printf("  %7d  %5.1f%%  %-10s  %-10s  %s\n", p.Commits, p.Share*100, p.First.Format("2006-01-02"),
+            p.Last.Format("2006-01-02"), who)
+    }
+    if rest := len(r.Contributors) - len(list); rest > 0 {
+        fmt.Print(i18n.Sprintf("  …… 另有 %d 位作者（--top 0 列出全部）\n", rest))
+    }
+}
+
+func init() { rootCmd.AddCommand(newContributorsCmd()) }
@@ -0,0 +1,188 @@
+package cli
+
+import (
+    "bufio"
+    "context"
+    "fmt"
+    "os"
+    "path/filepath"
+    "regexp"
+    "sort"
+    "strings"
+
+    "github.com/spf13/cobra"
+    "kingbrain/insight/pkg/config"
+    "kingbrain/insight/pkg/i18n"
+    "kingbrain/insight/pkg/sg"
+)
+
+// ctagsKinds 把 Sourcegraph 的符号类型映射为 ctags 的单字母 kind
+var ctagsKinds = map[string]string{
+    "FUNCTION": "f", "METHOD": "m", "CONSTRUCTOR": "m", "CLASS": "c", "STRUCT": "s",
+    "INTERFACE": "i", "VARIABLE": "v", "CONSTANT": "d", "FIELD": "m", "PROPERTY": "m",
+    "MODULE": "n", "NAMESPACE": "n", "PACKAGE": "p", "ENUM": "g", "ENUMMEMBER": "e",
+    "TYPEPARAMETER": "t",
+}
+
+type tagEntry struct {
+    name, file, kind, container string
+    line                        int
+}
+
+func newCtagsCmd() *cobra.Command {
+    var (
+        rev    string
+        output string
+        emacs  bool
+        filter string
+    )
+
+    cmd := &cobra.Command{
+        Use:   "ctags <repo...>",
+        Short: "从 Sourcegraph 拉取仓库的符号，生成映射到本地检出路径的 tags/TAGS 文件",
+        Long: `按配置文件 workspace.repos / workspace.roots 找到每个仓库的本地检出，
+把远程符号写成 ctags（默认）或 etags 文件，编辑器无需本地索引即可跨仓库跳转。
+文件路径相对 tags 文件所在目录书写；找不到检出的仓库写成 <repo>/<path> 并给出警告。
+
+  kb ctags github.com/acme/api github.com/acme/billing -o ~/src/tags
+  kb ctags github.com/acme/api --query 'lang:go' --etags -o TAGS`,
+        Args: cobra.MinimumNArgs(1),
+        RunE: func(cmd *cobra.Command, args []string) error {
+            if output == "" {
+                output = "tags"
+                if emacs {
+                    output = "TAGS"
+                }
+            }
+            cfg, err := config.Load()
+            if err != nil {
+                return err
+            }
+            outDir, err := filepath.Abs(filepath.Dir(output))
+            if err != nil {
+                return err
+            }
+            entries, err := collectTags(cmd.Context(), sg.New(), cfg.Workspace, args, rev, filter, outDir)
+            if err != nil {
+                return err
+            }
+            if emacs {
+                err = writeEtags(output, entries)
+            } else {
+                err = writeCtags(output, entries)
+            }
+            if err != nil {
+                return err
+            }
+            fmt.Fprint(os.Stderr, i18n.Sprintf("写入 %s：%d 个符号\n", output, len(entries)))
+            return nil
+        },
+    }
+
+    cmd.Flags().StringVar(&rev, "rev", "", "分支/标签/commit（默认默认分支）")
+    cmd.Flags().StringVarP(&output, "output", "o", "", "输出文件（默认 tags，--etags 时为 TAGS）")
+    cmd.Flags().BoolVarP(&emacs, "etags", "e", false, "输出 Emacs etags 格式")
+    cmd.Flags().StringVarP(&filter, "query", "q", "", "附加到符号查询的过滤条件，如 'lang:go -file:_test'")
+    return cmd
+}
+
+// collectTags 逐个仓库查询全部符号并映射为 tags 文件中的路径
+func collectTags(ctx context.Context, c *sg.Client, ws config.Workspace, repos []string, rev, filter, outDir string) ([]tagEntry, error) {
+    var entries []tagEntry
+    for _, repo := range repos {
+        scope := "repo:^" + regexp.QuoteMeta(repo) + "$"
+        if rev != "" {
+            scope += "@" + rev
+        }
+        syms, err := c.Symbols(ctx, buildQuery(scope, "type:symbol count:all", filter))
+        if err != nil {
+            return nil, fmt.Errorf("%s: %w", repo, err)
+        }
+        base := filepath.Join(outDir, filepath.FromSlash(repo))
+        if root, err := findCheckout(ws, repo); err == nil {
+            base = root
+        } else {
+            fmt.Fprint(os.Stderr, i18n.Sprintf("警告: %v，路径写成 %s/<path>\n", err, repo))
+        }
+        for _, s := range syms {
+            file := filepath.Join(base, filepath.FromSlash(s.Path))
+            if rel, err := filepath.Rel(outDir, file); err == nil && !strings.HasPrefix(rel, "..") {
+                file = rel
+            }
+            kind := ctagsKinds[strings.ToUpper(s.Kind)]
+            if kind == "" {
+                kind = "x"
+            }
+            entries = append(entries, tagEntry{name: s.Name, file: filepath.ToSlash(file), kind: kind, container: s.ContainerName, line: s.Line + 1})
+        }
+    }
+    return entries, nil
+}
+
+// writeCtags 输出按名字排序的扩展 ctags 格式，地址用行号
+func writeCtags(path string, entries []tagEntry) error {
+    sort.SliceStable(entries, func(i, j int) bool {
+        if entries[i].name != entries[j].name {
+            return entries[i].name < entries[j].name
+        }
+        if entries[i].file != entries[j].file {
+            return entries[i].file < entries[j].file
+        }
+        return entries[i].line < entries[j].line
+    })
+    f, err := os.Create(path)
+    if err != nil {
+        return err
+    }
+    w := bufio.NewWriter(f)
+    fmt.Fprintln(w, "!_TAG_FILE_FORMAT\t2\t/extended format/")
+    fmt.Fprintln(w, "!_TAG_FILE_SORTED\t1\t/0=unsorted, 1=sorted, 2=foldcase/")
+    fmt.Fprintln(w, "!_TAG_PROGRAM_NAME\tinsight\t//")
+    for _, e := range entries {
+        fmt.Fprintf(w, "%s\t%s\t%d;\"\t%s", e.name, e.file, e.line, e.kind)
+        if e.container != "" {
+            fmt.Fprintf(w, "\tclass:%s", e.container)
+        }
+        fmt.Fprintln(w)
+    }
+    if err := w.Flush(); err != nil {
+        f.Close()
+        return err
+    }
+    return f.Close()
+}
+
+// writeEtags 输出 etags 格式：每个文件一节，"\f\n<file>,<size>\n" 后跟 "<name>\x7f<name>\x01<line>,\n"
+func writeEtags(path string, entries []tagEntry) error {
+    byFile := map[string][]tagEntry{}
+    var files []string
+    for _, e := range entries {
+        if _, ok := byFile[e.file]; !ok {
+            files = append(files, e.file)
+        }
+        byFile[e.file] = append(byFile[e.file], e)
+    }
+    sort.Strings(files)
+
+    f, err := os.Create(path)
+    if err != nil {
+        return err
+    }
+    w := bufio.NewWriter(f)
+    for _, file := range files {
+        var sec strings.Builder
+        es := byFile[file]
+        sort.Slice(es, func(i, j int) bool { return es[i].line < es[j].line })
+        for _, e := range es {
+            fmt.Fprintf(&sec, "%s\x7f%s\x01%d,\n", e.name, e.name, e.line)
+        }
+        fmt.Fprintf(w, "\x0c\n%s,%d\n%s", file, sec.Len(), sec.String())
+    }
+    if err := w.Flush(); err != nil {
+        f.Close()
+        return err
+    }
+    return f.Close()
+}
+
+func init() { rootCmd.AddCommand(newCtagsCmd()) }
@@ -0,0 +1,228 @@
+package cli
+
+import (
+    "context"
+    "fmt"
+    "os"
+    "regexp"
+    "sort"
+    "strings"
+    "sync"
+    "unicode"
+
+    "github.com/spf13/cobra"
+    "kingbrain/insight/pkg/baseline"
+    "kingbrain/insight/pkg/i18n"
+    "kingbrain/insight/pkg/progress"
+    "kingbrain/insight/pkg/sg"
+)
+
+// deadKinds 是参与检查的 Go 符号类型（字段、包名等不检查）
+var deadKinds = map[string]bool{
+    "FUNCTION": true, "METHOD": true, "STRUCT": true, "INTERFACE": true,
+    "CLASS": true, "CONSTANT": true, "VARIABLE": true,
+}
+
+// DeadSymbol 是一个没有发现外部引用的导出符号
+type DeadSymbol struct {
+    sg.Symbol
+    Method string `json:"method"` // precise（代码智能）或 search（文本搜索估计）
+    URL    string `json:"url"`
+}
+
+func newDeadcodeCmd() *cobra.Command {
+    var (
+        rev      string
+        format   string
+        parallel int
+        internal bool
+        bl       baselineFlags
+    )
+
+    cmd := &cobra.Command{
+        Use:   "deadcode <repo>",
+        Short: "找出 Go 仓库中在整个实例里没有外部引用的导出符号",
+        Long: `枚举仓库中的导出符号（符号搜索），再逐个查询整个实例中来自其他仓库的引用。
+有精确代码智能索引时使用 references，否则退化为按标识符的文本搜索（结果偏保守）。
+--baseline 时只列出基线之外新出现的无引用符号，有新发现时以退出码 1 结束。`,
+        Args: cobra.ExactArgs(1),
+        RunE: func(cmd *cobra.Command, args []string) error {
+            if err := checkFormat(format, "text", "json"); err != nil {
+                return err
+            }
+            known, err := bl.load("deadcode")
+            if err != nil {
+                return err
+            }
+            c := sg.New()
+            all, checked, err := findDeadcode(cmd.Context(), c, args[0], rev, parallel, internal)
+            if err != nil {
+                return err
+            }
+            dead, findings := splitBaseline(known, all, deadBaselineFinding)
+            if err := bl.save("deadcode", findings); err != nil {
+                return err
+            }
+            if format == "json" {
+                if err := writeJSON(os.Stdout, dead); err != nil {
+                    return err
+                }
+                return bl.failOnNew(cmd, len(dead), len(all)-len(dead))
+            }
+            fmt.Print(i18n.Sprintf("检查了 %d 个导出符号，%d 个没有外部引用：\n\n", checked, len(dead)))
+            last := ""
+            for _, d := range dead {
+                if d.Path != last {
+                    fmt.Println(d.Path)
+                    last = d.Path
+                }
+                name := d.Name
+                if d.ContainerName != "" {
+                    name = d.ContainerName + "." + name
+                }
+                fmt.Printf("  %5d  %-10s %-40s [%s]\n", d.Line+1, strings.ToLower(d.Kind), name, d.Method)
+            }
+            return bl.failOnNew(cmd, len(dead), len(all)-len(dead))
+        },
+    }
+
+    cmd.Flags().StringVar(&rev, "rev", "", "检查的 revision（默认 HEAD）")
+    cmd.Flags().StringVarP(&format, "format", "f", "text", "输出格式：text|json")
+    cmd.Flags().IntVarP(&parallel, "parallel", "j", 8, "并发查询数")
+    cmd.Flags().BoolVar(&internal, "include-internal", false, "把同仓库其他包的引用也算作外部引用")
+    addBaselineFlags(cmd, &bl)
+    return cmd
+}
+
+// deadBaselineFinding 按 符号类型 + 限定名 识别，不含行号，移动符号不算新发现
+func deadBaselineFinding(d DeadSymbol) baseline.Finding {
+    name := d.Name
+    if d.ContainerName != "" {
+        name = d.ContainerName + "." + name
+    }
+    return baseline.Finding{
+        Fingerprint: baseline.Fingerprint("deadcode", d.Kind, d.Repo, d.Path, name),
+        Rule:        d.Kind,
+        Repo:        d.Repo,
+        Path:        d.Path,
+        Line:        d.Line + 1,
+        Text:        name,
+    }
+}
+
+// findDeadcode 返回没有外部引用的导出符号以及检查过的符号总数
+func findDeadcode(ctx context.Context, c *sg.Client, repo, rev string, parallel int, internal bool) ([]DeadSymbol, int, error) {
+    scope := "repo:^" + regexp.QuoteMeta(repo) + "$"
+    if rev != "" {
+        scope += "@" + rev
+    }
+    syms, err := c.Symbols(ctx, buildQuery(scope, "type:symbol lang:go -file:_test\\.go$ count:all", "^[A-Z]"))
+    if err != nil {
+        return nil, 0, err
+    }
+    var cands []sg.Symbol
+    for _, s := range syms {
+        if deadKinds[s.Kind] && isExported(s.Name) {
+            cands = append(cands, s)
+        }
+    }
+
+    var (
+        mu   sync.Mutex
+        dead []DeadSymbol
+        errs int
+    )
+    bar := progress.New(len(cands))
+    idx := make(chan int)
+    var wg sync.WaitGroup
+    for w := 0; w < max(parallel, 1); w++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            for i := range idx {
+                s := cands[i]
+                label := s.Path + ":" + s.Name
+                bar.Begin(label)
+                used, method, err := hasExternalRefs(ctx, c, s, rev, internal)
+                if err == nil && !used {
+                    mu.Lock()
+                    dead = append(dead, DeadSymbol{Symbol: s, Method: method,
+                        URL: blobURL(c, s.Repo, rev, s.Path, fmt.Sprint(s.Line+1))})
+                    mu.Unlock()
+                }
+                if err != nil {
+                    mu.Lock()
+                    errs++
+                    mu.Unlock()
+                }
+                bar.End(label, err)
+            }
+        }()
+    }
+    for i := range cands {
+        idx <- i
+    }
+    close(idx)
+    wg.Wait()
+    bar.Finish()
+    if errs == len(cands) && errs > 0 {
+        return nil, 0, i18n.Errorf("所有 %d 个符号的引用查询均失败", errs)
+    }
+
+    sort.Slice(dead, func(i, j int) bool {
+        if dead[i].Path != dead[j].Path {
+            return dead[i].Path < dead[j].Path
+        }
+        return dead[i].Line < dead[j].Line
+    })
+    return dead, len(cands), nil
+}
+
+// hasExternalRefs 判断符号是否被外部引用：优先精确代码智能，缺索引时用文本搜索
+func hasExternalRefs(ctx context.Context, c *sg.Client, s sg.Symbol, rev string, internal bool) (bool, string, error) {
+    refs, precise, err := c.References(ctx, s.Repo, rev, s.Path, s.Line, s.Character, 1000)
+    if err != nil {
+        return false, "", err
+    }
+    dir := s.Path[:strings.LastIndex(s.Path, "/")+1]
+    if precise {
+        for _, r := range refs {
+            if r.Repo != s.Repo {
+                return true, "precise", nil
+            }
+            // 同仓库：只有其他包的引用才算（--include-internal）
+            if internal && !(strings.HasPrefix(r.Path, dir) && !strings.Contains(r.Path[len(dir):], "/")) {
+                return true, "precise", nil
+            }
+        }
+        return false, "precise", nil
+    }
+
+    q := buildQuery(`\b`+regexp.QuoteMeta(s.Name)+`\b`, "lang:go", "count:1")
+    repo := regexp.QuoteMeta(s.Repo)
+    queries := []string{buildQuery(q, "-repo:^"+repo+"$")}
+    if internal {
+        // 同仓库里排除声明所在的包目录（只排除这一层，子目录是其他包），与精确引用的判断一致；
+        // -file: 作用于所有仓库，所以同仓库单独查一次
+        queries = append(queries, buildQuery(q, "repo:^"+repo+"$", "-file:^"+regexp.QuoteMeta(dir)+"[^/]+$"))
+    }
+    for _, q := range queries {
+        res, err := c.Search(ctx, q, "regexp")
+        if err != nil {
+            return false, "", err
+        }
+        if res.MatchCount > 0 {
+            return true, "search", nil
+        }
+    }
+    return false, "search", nil
+}
+
+func isExported(name string) bool {
+    for _, r := range name {
+        return unicode.IsUpper(r)
+    }
+    return false
+}
+
+func init() { rootCmd.AddCommand(newDeadcodeCmd()) }
@@ -0,0 +1,98 @@
+package cli
+
+import (
+    "os"
+    "os/exec"
+    "runtime"
+    "strings"
+    "unicode/utf16"
+
+    "kingbrain/insight/pkg/i18n"
+)
+
+// copyToClipboard 依次尝试各平台的剪贴板工具
+func copyToClipboard(text string) error {
+    var cands [][]string
+    switch runtime.GOOS {
+    case "darwin":
+        cands = [][]string{{"pbcopy"}}
+    case "windows":
+        cands = [][]string{{"clip"}}
+    default:
+        // clip.exe 只在 WSL 下能找到
+        cands = [][]string{{"wl-copy"}, {"xclip", "-selection", "clipboard"}, {"xsel", "--clipboard", "--input"}, {"clip.exe"}}
+    }
+    for _, c := range cands {
+        bin, err := exec.LookPath(c[0])
+        if err != nil {
+            continue
+        }
+        cmd := exec.Command(bin, c[1:]...)
+        cmd.Stdin = strings.NewReader(text)
+        if strings.HasPrefix(c[0], "clip") {
+            // clip 按控制台代码页解释输入，中文会乱码；带 BOM 的 UTF-16LE 则能原样写入
+            cmd.Stdin = strings.NewReader(utf16le(text))
+        }
+        return cmd.Run()
+    }
+    return i18n.Errorf("找不到剪贴板工具（%s）", runtime.GOOS)
+}
+
+func utf16le(s string) string {
+    var b strings.Builder
+    b.WriteString("\xff\xfe")
+    for _, u := range utf16.Encode([]rune(s)) {
+        b.WriteByte(byte(u))
+        b.WriteByte(byte(u >> 8))
+    }
+    return b.String()
+}
+
+// openBrowser 用系统默认程序打开链接；BROWSER 环境变量优先
+func openBrowser(url string) error {
+    var cmd *exec.Cmd
+    // BROWSER 只含空白时切分结果为空，按没有设置处理
+    parts := splitCommand(os.Getenv("BROWSER"))
+    switch {
+    case len(parts) > 0:
+        cmd = exec.Command(parts[0], append(parts[1:], url)...)
+    case runtime.GOOS == "darwin":
+        cmd = exec.Command("open", url)
+    case runtime.GOOS == "windows":
+        cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+    default:
+        cmd = exec.Command("xdg-open", url)
+    }
+    return cmd.Start()
+}
+
+// splitCommand 按空白切分命令行，双引号内的空格不切分（Windows 上常见 "C:\Program Files\..."），反斜杠原样保留
+func splitCommand(s string) []string {
+    var parts []string
+    var cur strings.Builder
+    quoted, started := false, false
+    for _, r := range s {
+        switch {
+        case r == '"':
+            quoted, started = !quoted, true
+        case (r == ' ' || r == '\t') && !quoted:
+            if started {
+                parts = append(parts, cur.String())
+                cur.Reset()
+                started = false
+            }
+        default:
+            cur.WriteRune(r)
+            started = true
+        }
+    }
+    if started {
+        parts = append(parts, cur.String())
+    }
+    return parts
+}
+
+func isDir(path string) bool {
+    fi, err := os.Stat(path)
+    return err == nil && fi.IsDir()
+}
@@ -0,0 +1,357 @@
+package cli
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "fmt"
+    "html/template"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+    "time"
+
+    "github.com/spf13/cobra"
+    "kingbrain/insight/pkg/config"
+    "kingbrain/insight/pkg/i18n"
+    "kingbrain/insight/pkg/mail"
+    "kingbrain/insight/pkg/sg"
+)
+
+// 每条查询在邮件里最多列出的新增匹配数
+const digestMaxNew = 20
+
+// digestSnapshot 是一次 digest 运行的快照，用于下次对比
+type digestSnapshot struct {
+    Taken   time.Time                   `json:"taken"`
+    Queries map[string]digestQueryState `json:"queries"`
+    LOC     map[string]int              `json:"loc,omitempty"`
+}
+
+// digestQueryState 记录匹配总数与每处匹配的键（仓库+路径+去空白的预览，不含行号，避免行号漂移被算作新增）
+type digestQueryState struct {
+    Count int      `json:"count"`
+    Keys  []string `json:"keys"`
+}
+
+type digestItem struct {
+    Repo, Path, Preview, URL string
+    Line                     int
+}
+
+// digestQueryReport 是一条查询的对比结果；Baseline 为 false 表示基线快照里没有这条查询
+// （新加的查询或上次查询失败），此时不算变化，也不列新增
+type digestQueryReport struct {
+    Name, Query     string
+    Count, Previous int
+    Delta           int
+    Baseline        bool
+    New             []digestItem
+    MoreNew         int
+    Error           string
+}
+
+type digestLOCReport struct {
+    Repo           string
+    Code, Previous int
+    Delta          int
+    Growth         string
+}
+
+type digestReport struct {
+    Subject  string
+    Since    time.Time
+    Baseline bool
+    Queries  []digestQueryReport
+    LOC      []digestLOCReport
+}
+
+func newDigestCmd() *cobra.Command {
+    var (
+        dryRun      bool
+        output      string
+        noSave      bool
+        baselineAge time.Duration
+    )
+
+    cmd := &cobra.Command{
+        Use:   "digest",
+        Short: "运行配置中的查询并与上周快照对比，生成 HTML 周报并通过 SMTP 发送",
+        Long: `在配置文件中配置 digest 段：
+
+  digest:
+    subject: "代码周报"
+    from: insight@acme.dev
+    to: [eng-managers@acme.dev]
+    smtp: {host: smtp.acme.dev, port: 587, username: insight, password_env: SMTP_PASSWORD}
+    queries:
+      - {name: 新增 TODO, query: '\bTODO\b lang:go', pattern: regexp}
+      - {name: 废弃 API ioutil, query: 'ioutil.ReadAll'}
+    loc: [github.com/acme/api]   # 用本地检出 + scc 统计代码行数
+
+每次运行都会在 <用户缓存目录>/insight/digest/ 下保存快照；对比基线取至少 --baseline-age
+之前的最新快照（没有时取最早的一份）。适合用 cron 每周运行一次。`,
+        Args: cobra.NoArgs,
+        RunE: func(cmd *cobra.Command, _ []string) error {
+            cfg, err := config.Load()
+            if err != nil {
+                return err
+            }
+            d := cfg.Digest
+            if len(d.Queries) == 0 && len(d.LOC) == 0 {
+                p, _ := config.Path()
+                return i18n.Errorf("未配置 digest.queries / digest.loc（%s）", p)
+            }
+
+            now := time.Now()
+            snap, items := takeDigestSnapshot(cmd.Context(), sg.New(), cfg, now)
+            base, err := loadDigestBaseline(now.Add(-baselineAge))
+            if err != nil {
+                return err
+            }
+            report := buildDigestReport(d, snap, base, items)
+
+            var html bytes.Buffer
+            if err := digestTmpl.Execute(&html, report); err != nil {
+                return err
+            }
+            switch {
+            case output != "":
+                err = os.WriteFile(output, html.Bytes(), 0o644)
+            case dryRun:
+                _, err = os.Stdout.Write(html.Bytes())
+            default:
+                srv := mail.Server{Host: d.SMTP.Host, Port: d.SMTP.Port, Username: d.SMTP.Username}
+                if d.SMTP.PasswordEnv != "" {
+                    srv.Password = os.Getenv(d.SMTP.PasswordEnv)
+                }
+                if err = srv.SendHTML(d.From, d.To, report.Subject, html.String()); err == nil {
+                    fmt.Fprint(os.Stderr, i18n.Sprintf("已发送给 %s\n", strings.Join(d.To, ", ")))
+                }
+            }
+            if err != nil {
+                return err
+            }
+            if noSave || dryRun {
+                return nil
+            }
+            return saveDigestSnapshot(snap)
+        },
+    }
+
+    cmd.Flags().BoolVar(&dryRun, "dry-run", false, "把 HTML 打印到 stdout，不发送也不保存快照")
+    cmd.Flags().StringVarP(&output, "output", "o", "", "把 HTML 写到文件而不发送")
+    cmd.Flags().BoolVar(&noSave, "no-save", false, "不保存本次快照")
+    cmd.Flags().DurationVar(&baselineAge, "baseline-age", 6*24*time.Hour, "对比基线至少要有多久")
+    return cmd
+}
+
+// takeDigestSnapshot 运行全部查询与 LOC 统计；失败的查询记入报告而不中断
+func takeDigestSnapshot(ctx context.Context, c *sg.Client, cfg *config.Config, now time.Time) (*digestSnapshot, map[string]map[string]digestItem) {
+    snap := &digestSnapshot{Taken: now, Queries: map[string]digestQueryState{}, LOC: map[string]int{}}
+    items := map[string]map[string]digestItem{}
+    for _, q := range cfg.Digest.Queries {
+        pattern := q.Pattern
+        if pattern == "" {
+            pattern = "literal"
+        }
+        res, err := c.Search(ctx, q.Query, pattern)
+        if err != nil {
+            fmt.Fprintf(os.Stderr, "✗ %s: %v\n", q.Name, err)
+            continue
+        }
+        st := digestQueryState{Count: res.MatchCount}
+        items[q.Name] = map[string]digestItem{}
+        for _, fm := range res.Results {
+            for _, lm := range fm.LineMatches {
+                key := fm.Repository.Name + "\x00" + fm.File.Path + "\x00" + strings.Join(strings.Fields(lm.Preview), " ")
+                st.Keys = append(st.Keys, key)
+                items[q.Name][key] = digestItem{
+                    Repo: fm.Repository.Name, Path: fm.File.Path, Line: lm.LineNumber + 1,
+                    Preview: strings.TrimSpace(lm.Preview), URL: fmt.Sprintf("%s?L%d", c.URL(fm.File.URL), lm.LineNumber+1),
+                }
+            }
+        }
+        snap.Queries[q.Name] = st
+    }
+    for _, repo := range cfg.Digest.LOC {
+        dir, err := findCheckout(cfg.Workspace, repo)
+        if err != nil {
+            fmt.Fprintf(os.Stderr, "✗ LOC %s: %v\n", repo, err)
+            continue
+        }
+        langs, err := sccJSON(dir)
+        if err != nil {
+            fmt.Fprintf(os.Stderr, "✗ LOC %s: %v\n", repo, err)
+            continue
+        }
+        code := 0
+        for _, l := range langs {
+            code += l.Code
+        }
+        snap.LOC[repo] = code
+    }
+    return snap, items
+}
+
+func buildDigestReport(d config.Digest, snap, base *digestSnapshot, items map[string]map[string]digestItem) digestReport {
+    r := digestReport{Subject: d.Subject}
+    if r.Subject == "" {
+        r.Subject = i18n.Sprintf("insight 代码周报 %s", snap.Taken.Format("2006-01-02"))
+    }
+    if base != nil {
+        r.Since, r.Baseline = base.Taken, true
+    }
+    for _, q := range d.Queries {
+        qr := digestQueryReport{Name: q.Name, Query: q.Query}
+        st, ok := snap.Queries[q.Name]
+        if !ok {
+            qr.Error = i18n.T("查询失败")
+            r.Queries = append(r.Queries, qr)
+            continue
+        }
+        qr.Count = st.Count
+        if prev, ok := base.queryState(q.Name); ok {
+            qr.Previous, qr.Delta, qr.Baseline = prev.Count, st.Count-prev.Count, true
+            seen := map[string]bool{}
+            for _, k := range prev.Keys {
+                seen[k] = true
+            }
+            for _, k := range st.Keys {
+                if seen[k] {
+                    continue
+                }
+                seen[k] = true
+                if len(qr.New) < digestMaxNew {
+                    qr.New = append(qr.New, items[q.Name][k])
+                } else {
+                    qr.MoreNew++
+                }
+            }
+        }
+        r.Queries = append(r.Queries, qr)
+    }
+    repos := make([]string, 0, len(snap.LOC))
+    for repo := range snap.LOC {
+        repos = append(repos, repo)
+    }
+    sort.Strings(repos)
+    for _, repo := range repos {
+        lr := digestLOCReport{Repo: repo, Code: snap.LOC[repo]}
+        if base != nil {
+            if prev, ok := base.LOC[repo]; ok {
+                lr.Previous, lr.Delta = prev, lr.Code-prev
+                if prev > 0 {
+                    lr.Growth = fmt.Sprintf("%+.1f%%", float64(lr.Delta)*100/float64(prev))
+                }
+            }
+        }
+        r.LOC = append(r.LOC, lr)
+    }
+    return r
+}
+
+// queryState 取快照中一条查询的结果，s 为 nil 或快照里没有这条查询时返回 false
+func (s *digestSnapshot) queryState(name string) (digestQueryState, bool) {
+    if s == nil {
+        return digestQueryState{}, false
+    }
+    st, ok := s.Queries[name]
+    return st, ok
+}
+
+func digestDir() (string, error) {
+    dir, err := os.UserCacheDir()
+    if err != nil {
+        return "", err
+    }
+    return filepath.Join(dir, "insight", "digest"), nil
+}
+
+// loadDigestBaseline 取 before 之前的最新快照；都比 before 新时取最早的一份，没有快照时返回 nil
+func loadDigestBaseline(before time.Time) (*digestSnapshot, error) {
+    dir, err := digestDir()
+    if err != nil {
+        return nil, err
+    }
+    names, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+    sort.Strings(names) // 文件名即时间戳
+    var pick string
+    for _, n := range names {
+        t, err := time.ParseInLocation("20060102-150405", strings.TrimSuffix(filepath.Base(n), ".json"), time.UTC)
+        if err == nil && t.After(before) {
+            break
+        }
+        pick = n
+    }
+    if pick == "" && len(names) > 0 {
+        pick = names[0]
+    }
+    if pick == "" {
+        return nil, nil
+    }
+    b, err := os.ReadFile(pick)
+    if err != nil {
+        return nil, err
+    }
+    var s digestSnapshot
+    if err := json.Unmarshal(b, &s); err != nil {
+        return nil, fmt.Errorf("%s: %w", pick, err)
+    }
+    return &s, nil
+}
+
+func saveDigestSnapshot(s *digestSnapshot) error {
+    dir, err := digestDir()
+    if err != nil {
+        return err
+    }
+    if err := os.MkdirAll(dir, 0o755); err != nil {
+        return err
+    }
+    b, err := json.Marshal(s)
+    if err != nil {
+        return err
+    }
+    return os.WriteFile(filepath.Join(dir, s.Taken.UTC().Format("20060102-150405")+".json"), b, 0o644)
+}
+
+var digestTmpl = template.Must(template.New("digest").Funcs(template.FuncMap{
+    "signed": func(n int) string { return fmt.Sprintf("%+d", n) },
+    "t":      i18n.T,
+    "tf":     i18n.Sprintf,
+}).Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>{{.Subject}}</title></head>
+<body style="font-family: -apple-system, 'Segoe UI', sans-serif; font-size: 14px; color: #222;">
+<h2>{{.Subject}}</h2>
+{{if .Baseline}}<p>{{tf "对比基线：%s" (.Since.Format "2006-01-02 15:04")}}</p>{{else}}<p>{{t "首次运行，暂无对比基线。"}}</p>{{end}}
+{{if .Queries}}
+<h3>{{t "查询趋势"}}</h3>
+<table cellpadding="6" style="border-collapse: collapse;">
+<tr style="background: #f0f0f0;"><th align="left">{{t "查询"}}</th><th align="right">{{t "当前"}}</th><th align="right">{{t "上次"}}</th><th align="right">{{t "变化"}}</th></tr>
+{{range .Queries}}<tr style="border-top: 1px solid #ddd;">
+<td><b>{{.Name}}</b><br><code style="color: #666;">{{.Query}}</code></td>
+{{if .Error}}<td colspan="3" style="color: #c00;">{{.Error}}</td>{{else}}
+<td align="right">{{.Count}}</td><td align="right">{{if .Baseline}}{{.Previous}}{{else}}-{{end}}</td>
+<td align="right" style="color: {{if gt .Delta 0}}#c00{{else if lt .Delta 0}}#080{{else}}#666{{end}};">{{if .Baseline}}{{signed .Delta}}{{else}}-{{end}}</td>{{end}}
+</tr>{{end}}
+</table>
+{{range .Queries}}{{if .New}}
+<h4>{{.Name}}{{t "："}}{{if .MoreNew}}{{tf "新增 %d + %d 处" (len .New) .MoreNew}}{{else}}{{tf "新增 %d 处" (len .New)}}{{end}}</h4>
+<ul>{{range .New}}<li><a href="{{.URL}}">{{.Repo}}/{{.Path}}:{{.Line}}</a> <code>{{.Preview}}</code></li>{{end}}</ul>
+{{end}}{{end}}
+{{end}}
+{{if .LOC}}
+<h3>{{t "代码行数"}}</h3>
+<table cellpadding="6" style="border-collapse: collapse;">
+<tr style="background: #f0f0f0;"><th align="left">{{t "仓库"}}</th><th align="right">{{t "代码行"}}</th><th align="right">{{t "变化"}}</th><th align="right">{{t "增长"}}</th></tr>
+{{range .LOC}}<tr style="border-top: 1px solid #ddd;"><td>{{.Repo}}</td><td align="right">{{.Code}}</td>
+<td align="right">{{if .Previous}}{{signed .Delta}}{{else}}-{{end}}</td><td align="right">{{.Growth}}</td></tr>{{end}}
+</table>
+{{end}}
+<p style="color: #999; font-size: 12px;">{{t "由 kb digest 生成"}}</p>
+</body></html>
+`))
+
+func init() { rootCmd.AddCommand(newDigestCmd()) }
@@ -0,0 +1,229 @@
+package cli
+
+import (
+    "context"
+    "fmt"
+    "os"
+    "sort"
+    "strconv"
+    "strings"
+
+    "github.com/spf13/cobra"
+    "kingbrain/insight/pkg/graph"
+    "kingbrain/insight/pkg/i18n"
+    "kingbrain/insight/pkg/manifest"
+    "kingbrain/insight/pkg/sg"
+)
+
+// DriftEntry 是某仓库某份清单中对目标依赖的一次声明
+type DriftEntry struct {
+    Repo      string `json:"repo"`
+    Path      string `json:"path"`
+    Line      int    `json:"line"`
+    Ecosystem string `json:"ecosystem"`
+    Version   string `json:"version"`
+    Target    string `json:"target"`
+    Status    string `json:"status"` // outdated|current|ahead|unknown
+    URL       string `json:"url"`
+}
+
+func newDriftCmd() *cobra.Command {
+    var (
+        repos    []string
+        target   string
+        format   string
+        limit    int
+        offline  bool
+        outdated bool
+    )
+
+    cmd := &cobra.Command{
+        Use:   "drift <module>",
+        Short: "跨仓库检查某依赖在 go.mod/package.json/requirements.txt 中的版本，找出落后的仓库",
+        Args:  cobra.ExactArgs(1),
+        RunE: func(cmd *cobra.Command, args []string) error {
+            if err := checkFormat(format, "text", "json", "csv", "dot", "mermaid"); err != nil {
+                return err
+            }
+            c := sg.New()
+            entries, err := searchDrift(cmd.Context(), c, args[0], repos, limit)
+            if err != nil {
+                return err
+            }
+            if len(entries) == 0 {
+                fmt.Fprint(os.Stderr, i18n.Sprintf("没有仓库声明依赖 %s\n", args[0]))
+                return nil
+            }
+            targets := driftTargets(cmd.Context(), args[0], target, offline, entries)
+            classifyDrift(entries, targets)
+            if outdated {
+                kept := entries[:0]
+                for _, e := range entries {
+                    if e.Status == "outdated" {
+                        kept = append(kept, e)
+                    }
+                }
+                entries = kept
+            }
+            return printDrift(format, args[0], entries)
+        },
+    }
+
+    cmd.Flags().StringSliceVar(&repos, "repo", nil, "限定仓库（可重复，支持正则；含 * ? 的 glob 按仓库缓存展开）")
+    cmd.Flags().StringVar(&target, "target", "", "目标版本（默认取注册中心的最新版本；离线时取各仓库中的最高版本）")
+    cmd.Flags().StringVarP(&format, "format", "f", "text", "输出格式：text|json|csv|dot|mermaid（dot/mermaid 为反向依赖图）")
+    cmd.Flags().IntVar(&limit, "limit", 5000, "最多返回的匹配数（count:）")
+    cmd.Flags().BoolVar(&offline, "offline", false, "不查询 proxy.golang.org/npm/PyPI")
+    cmd.Flags().BoolVar(&outdated, "outdated", false, "只输出落后于目标版本的仓库")
+    return cmd
+}
+
+// searchDrift 在清单文件中搜索依赖名，并从匹配行直接解析版本（不拉取整份文件）
+func searchDrift(ctx context.Context, c *sg.Client, module string, repos []string, limit int) ([]DriftEntry, error) {
+    res, err := c.Search(ctx, buildQuery(manifest.FileFilter, repoFilter(repos), countFilter(limit), module), "literal")
+    if err != nil {
+        return nil, err
+    }
+    var out []DriftEntry
+    for _, fm := range res.Results {
+        for _, lm := range fm.LineMatches {
+            d, ok := manifest.ParseLine(fm.File.Path, lm.Preview)
+            if !ok || !sameDependency(d, module) {
+                continue
+            }
+            line := lm.LineNumber + 1
+            out = append(out, DriftEntry{
+                Repo:      fm.Repository.Name,
+                Path:      fm.File.Path,
+                Line:      line,
+                Ecosystem: d.Ecosystem,
+                Version:   d.Version,
+                URL:       fmt.Sprintf("%s?L%d", c.URL(fm.File.URL), line),
+            })
+        }
+    }
+    return out, nil
+}
+
+// sameDependency 字面量搜索会命中前缀相同的其他依赖，这里按名字精确比对（PyPI 不区分大小写）
+func sameDependency(d manifest.Dependency, module string) bool {
+    if d.Ecosystem == manifest.PyPI {
+        return strings.EqualFold(d.Name, module)
+    }
+    return d.Name == module
+}
+
+// driftTargets 为每个生态确定目标版本：--target > 注册中心最新版本 > 已观察到的最高版本
+func driftTargets(ctx context.Context, module, target string, offline bool, entries []DriftEntry) map[string]string {
+    targets := map[string]string{}
+    for _, e := range entries {
+        if _, ok := targets[e.Ecosystem]; ok {
+            continue
+        }
+        switch {
+        case target != "":
+            targets[e.Ecosystem] = target
+        case !offline:
+            v, err := manifest.Latest(ctx, e.Ecosystem, module)
+            if err == nil {
+                targets[e.Ecosystem] = v
+                continue
+            }
+            fmt.Fprint(os.Stderr, i18n.Sprintf("警告: 查询 %s 最新版本失败，改用仓库中的最高版本: %v\n", e.Ecosystem, err))
+            fallthrough
+        default:
+            targets[e.Ecosystem] = highestVersion(e.Ecosystem, entries)
+        }
+    }
+    return targets
+}
+
+func highestVersion(ecosystem string, entries []DriftEntry) string {
+    var best string
+    for _, e := range entries {
+        if e.Ecosystem != ecosystem || manifest.CleanVersion(e.Version) == "" {
+            continue
+        }
+        if best == "" || manifest.Compare(e.Version, best) > 0 {
+            best = e.Version
+        }
+    }
+    return best
+}
+
+// classifyDrift 标记每条声明相对目标版本的状态，并把落后的排在前面
+func classifyDrift(entries []DriftEntry, targets map[string]string) {
+    for i := range entries {
+        e := &entries[i]
+        e.Target = targets[e.Ecosystem]
+        switch {
+        case manifest.CleanVersion(e.Version) == "" || e.Target == "":
+            e.Status = "unknown"
+        case manifest.Compare(e.Version, e.Target) < 0:
+            e.Status = "outdated"
+        case manifest.Compare(e.Version, e.Target) > 0:
+            e.Status = "ahead"
+        default:
+            e.Status = "current"
+        }
+    }
+    rank := map[string]int{"outdated": 0, "unknown": 1, "ahead": 2, "current": 3}
+    sort.SliceStable(entries, func(i, j int) bool {
+        a, b := entries[i], entries[j]
+        if rank[a.Status] != rank[b.Status] {
+            return rank[a.Status] < rank[b.Status]
+        }
+        if c := manifest.Compare(a.Version, b.Version); c != 0 {
+            return c < 0
+        }
+        return a.Repo < b.Repo
+    })
+}
+
+// driftStatusColor 是依赖图中按状态给仓库节点着的颜色
+var driftStatusColor = map[string]string{"outdated": "#f8d7da", "ahead": "#d1ecf1", "current": "#d4edda"}
+
+// driftGraph 画出 仓库 → 依赖 的反向依赖图，边上标注版本，仓库按状态着色、按生态分组
+func driftGraph(module string, entries []DriftEntry) *graph.Graph {
+    g := graph.New("drift " + module)
+    for _, e := range entries {
+        target := module + " (" + e.Ecosystem + ")"
+        label := module
+        if e.Target != "" {
+            label += i18n.Sprintf("\n目标 %s", e.Target)
+        }
+        g.AddNode(graph.Node{ID: target, Label: label})
+        g.AddNode(graph.Node{ID: e.Repo, Group: e.Ecosystem, Color: driftStatusColor[e.Status]})
+        g.AddEdge(e.Repo, target, e.Version)
+    }
+    return g
+}
+
+func printDrift(format, module string, entries []DriftEntry) error {
+    switch format {
+    case "dot", "mermaid":
+        return driftGraph(module, entries).Write(os.Stdout, format)
+    case "json":
+        return writeJSON(os.Stdout, entries)
+    case "csv":
+        var rows [][]string
+        for _, e := range entries {
+            rows = append(rows, []string{e.Repo, e.Path, strconv.Itoa(e.Line), e.Ecosystem, e.Version, e.Target, e.Status, e.URL})
+        }
+        return writeCSV(os.Stdout, []string{"repo", "path", "line", "ecosystem", "version", "target", "status", "url"}, rows)
+    }
+
+    var n int
+    for _, e := range entries {
+        if e.Status == "outdated" {
+            n++
+        }
+    }
+    fmt.Print(i18n.Sprintf("%d/%d 处声明落后于目标版本\n\n", n, len(entries)))
+    for _, e := range entries {
+        fmt.Printf("%-9s %-14s -> %-12s %s/%s:%d\n", e.Status, e.Version, e.Target, e.Repo, e.Path, e.Line)
+    }
+    return nil
+}
+
+func init() { rootCmd.AddCommand(newDriftCmd()) }
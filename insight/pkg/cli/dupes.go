@@ -0,0 +1,192 @@
+package cli
+
+import (
+    "context"
+    "fmt"
+    "os"
+    "sort"
+
+    "github.com/spf13/cobra"
+    "kingbrain/insight/pkg/baseline"
+    "kingbrain/insight/pkg/fingerprint"
+    "kingbrain/insight/pkg/i18n"
+    "kingbrain/insight/pkg/progress"
+    "kingbrain/insight/pkg/sg"
+)
+
+type dupeFile struct {
+    Repo string `json:"repo"`
+    Path string `json:"path"`
+    URL  string `json:"url"`
+    fps  []fingerprint.Fingerprint
+}
+
+// DupePair 是一对疑似复制粘贴的文件
+type DupePair struct {
+    A          dupeFile             `json:"a"`
+    B          dupeFile             `json:"b"`
+    Similarity float64              `json:"similarity"`
+    Regions    []fingerprint.Region `json:"regions"`
+}
+
+func newDupesCmd() *cobra.Command {
+    var (
+        threshold float64
+        k, w      int
+        maxFiles  int
+        sameRepo  bool
+        pattern   string
+        format    string
+        bl        baselineFlags
+    )
+
+    cmd := &cobra.Command{
+        Use:   "dupes <query>",
+        Short: "对查询命中的文件做指纹（winnowing），找出跨仓库的疑似复制粘贴代码",
+        Long: `拉取查询命中的文件内容，按 k 行滚动哈希 + winnowing 计算指纹，
+报告相似度不低于 --threshold 的文件对及其重复区域。--baseline 时只报告基线之外新出现的文件对，
+有新发现时以退出码 1 结束。例如：
+
+  kb dupes 'lang:go file:retry' --threshold 0.6`,
+        Args: cobra.ExactArgs(1),
+        RunE: func(cmd *cobra.Command, args []string) error {
+            if err := checkFormat(format, "text", "json"); err != nil {
+                return err
+            }
+            known, err := bl.load("dupes")
+            if err != nil {
+                return err
+            }
+            c := sg.New()
+            files, err := fetchDupeFiles(cmd.Context(), c, args[0], pattern, maxFiles, k, w)
+            if err != nil {
+                return err
+            }
+            all := findDupes(files, threshold, k, sameRepo)
+            pairs, findings := splitBaseline(known, all, dupeBaselineFinding)
+            if err := bl.save("dupes", findings); err != nil {
+                return err
+            }
+            if format == "json" {
+                if err := writeJSON(os.Stdout, pairs); err != nil {
+                    return err
+                }
+                return bl.failOnNew(cmd, len(pairs), len(all)-len(pairs))
+            }
+            fmt.Print(i18n.Sprintf("比较了 %d 个文件，%d 对相似度 ≥ %.0f%%：\n\n", len(files), len(pairs), threshold*100))
+            for _, p := range pairs {
+                fmt.Printf("%3.0f%%  %s/%s\n      %s/%s\n", p.Similarity*100, p.A.Repo, p.A.Path, p.B.Repo, p.B.Path)
+                for _, r := range p.Regions {
+                    fmt.Printf("      L%d-%d  ↔  L%d-%d\n", r.StartA, r.EndA, r.StartB, r.EndB)
+                }
+                fmt.Println()
+            }
+            return bl.failOnNew(cmd, len(pairs), len(all)-len(pairs))
+        },
+    }
+
+    cmd.Flags().Float64Var(&threshold, "threshold", 0.5, "相似度阈值（0-1）")
+    cmd.Flags().IntVar(&k, "min-lines", 6, "最短重复片段行数（k-gram 的 k）")
+    cmd.Flags().IntVar(&w, "window", 4, "winnowing 窗口大小")
+    cmd.Flags().IntVar(&maxFiles, "max-files", 200, "最多拉取的文件数")
+    cmd.Flags().BoolVar(&sameRepo, "same-repo", false, "也报告同一仓库内的重复")
+    cmd.Flags().StringVarP(&pattern, "pattern", "p", "literal", "搜索模式：literal|regexp|structural")
+    cmd.Flags().StringVarP(&format, "format", "f", "text", "输出格式：text|json")
+    addBaselineFlags(cmd, &bl)
+    return cmd
+}
+
+// dupeBaselineFinding 按文件对识别（与 A、B 的先后无关），不含相似度，重复区域变化不算新发现
+func dupeBaselineFinding(p DupePair) baseline.Finding {
+    a, b := p.A.Repo+"/"+p.A.Path, p.B.Repo+"/"+p.B.Path
+    if b < a {
+        a, b = b, a
+    }
+    return baseline.Finding{
+        Fingerprint: baseline.Fingerprint("dupes", "dupe", "", a, b),
+        Rule:        "dupe",
+        Repo:        p.A.Repo,
+        Path:        p.A.Path,
+        Text:        fmt.Sprintf("%s/%s (%.0f%%)", p.B.Repo, p.B.Path, p.Similarity*100),
+    }
+}
+
+// fetchDupeFiles 搜索并批量拉取文件内容计算指纹
+func fetchDupeFiles(ctx context.Context, c *sg.Client, query, pattern string, maxFiles, k, w int) ([]dupeFile, error) {
+    res, err := c.Search(ctx, buildQuery(query, "type:file", countFilter(maxFiles)), pattern)
+    if err != nil {
+        return nil, err
+    }
+    matches := res.Results
+    if len(matches) > maxFiles {
+        matches = matches[:maxFiles]
+    }
+    specs := make([]sg.FileSpec, len(matches))
+    for i, fm := range matches {
+        specs[i] = sg.FileSpec{Repo: fm.Repository.Name, Path: fm.File.Path}
+    }
+    bar := progress.New(len(matches))
+    fetched := c.GetFilesFunc(ctx, specs, func(r sg.FileResult) { bar.End(r.Repo+"/"+r.Path, r.Err) })
+    bar.Finish()
+    var files []dupeFile
+    for i, fm := range matches {
+        if fetched[i].Err != nil {
+            continue
+        }
+        if fps := fingerprint.Winnow(fetched[i].Content, k, w); len(fps) > 0 {
+            files = append(files, dupeFile{Repo: fm.Repository.Name, Path: fm.File.Path, URL: c.URL(fm.File.URL), fps: fps})
+        }
+    }
+    return files, nil
+}
+
+// findDupes 用倒排索引统计文件对之间的共享指纹，再对超过阈值的文件对计算重复区域
+func findDupes(files []dupeFile, threshold float64, k int, sameRepo bool) []DupePair {
+    index := map[uint64][]int{}
+    for i, f := range files {
+        seen := map[uint64]bool{}
+        for _, fp := range f.fps {
+            if !seen[fp.Hash] {
+                seen[fp.Hash] = true
+                index[fp.Hash] = append(index[fp.Hash], i)
+            }
+        }
+    }
+    shared := map[[2]int]int{}
+    for _, ids := range index {
+        // 出现在大量文件里的指纹多半是样板代码（import 块、license 头），跳过
+        if len(ids) > 50 {
+            continue
+        }
+        for x := 0; x < len(ids); x++ {
+            for y := x + 1; y < len(ids); y++ {
+                shared[[2]int{ids[x], ids[y]}]++
+            }
+        }
+    }
+
+    var pairs []DupePair
+    for key, n := range shared {
+        a, b := files[key[0]], files[key[1]]
+        if !sameRepo && a.Repo == b.Repo {
+            continue
+        }
+        if float64(n)/float64(min(len(a.fps), len(b.fps))) < threshold {
+            continue
+        }
+        score, regions := fingerprint.Compare(a.fps, b.fps, k, k)
+        if score < threshold {
+            continue
+        }
+        pairs = append(pairs, DupePair{A: a, B: b, Similarity: score, Regions: regions})
+    }
+    sort.Slice(pairs, func(i, j int) bool {
+        if pairs[i].Similarity != pairs[j].Similarity {
+            return pairs[i].Similarity > pairs[j].Similarity
+        }
+        return pairs[i].A.Repo+pairs[i].A.Path < pairs[j].A.Repo+pairs[j].A.Path
+    })
+    return pairs
+}
+
+func init() { rootCmd.AddCommand(newDupesCmd()) }
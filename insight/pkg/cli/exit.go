@@ -0,0 +1,44 @@
+package cli
+
+import (
+    "errors"
+    "fmt"
+    "os"
+
+    "github.com/spf13/cobra"
+)
+
+// 退出码：0 成功，1 表示“没有结果”这类预期内的否定结论（find 没有匹配、--fail-if-matches 命中等），
+// 2 为错误（参数错误、请求失败等）
+const (
+    exitOK    = 0
+    exitFalse = 1
+    exitError = 2
+)
+
+// codeError 让命令以指定的退出码结束，不视为命令执行失败
+type codeError struct{ code int }
+
+func (e *codeError) Error() string { return fmt.Sprintf("exit status %d", e.code) }
+
+// exitWith 返回以 code 退出的错误，并关闭 cobra 对这个错误的 Error:/用法输出；
+// msg 非空时先打印到 stderr
+func exitWith(cmd *cobra.Command, code int, msg string) error {
+    cmd.SilenceErrors, cmd.SilenceUsage = true, true
+    if msg != "" {
+        fmt.Fprintln(os.Stderr, msg)
+    }
+    return &codeError{code: code}
+}
+
+// exitStatus 把命令返回的错误换算成退出码；codeError 不算失败，返回的 error 为 nil
+func exitStatus(err error) (int, error) {
+    var ce *codeError
+    switch {
+    case err == nil:
+        return exitOK, nil
+    case errors.As(err, &ce):
+        return ce.code, nil
+    }
+    return exitError, err
+}
@@ -0,0 +1,263 @@
+package cli
+
+import (
+    "bufio"
+    "context"
+    "fmt"
+    "io"
+    "os"
+    "sort"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/spf13/cobra"
+    "kingbrain/insight/pkg/i18n"
+    "kingbrain/insight/pkg/loc"
+    "kingbrain/insight/pkg/sg"
+)
+
+// ContextLine 是匹配附近的一行及最后修改它的提交
+type ContextLine struct {
+    Line   int    `json:"line"`
+    Text   string `json:"text"`
+    Commit string `json:"commit,omitempty"`
+}
+
+// MatchContext 是 explain-match 为一处匹配汇总的上下文：Introduced 为最后修改匹配行的提交，
+// Nearby 为附近代码中的其他提交（按时间从新到旧）
+type MatchContext struct {
+    Repo       string        `json:"repo"`
+    Rev        string        `json:"rev,omitempty"`
+    Path       string        `json:"path"`
+    Line       int           `json:"line"`
+    Language   string        `json:"language,omitempty"`
+    URL        string        `json:"url"`
+    Permalink  string        `json:"permalink,omitempty"`
+    Code       []ContextLine `json:"code"`
+    Introduced *sg.Commit    `json:"introduced,omitempty"`
+    Nearby     []sg.Commit   `json:"nearby,omitempty"`
+}
+
+func newExplainMatchCmd() *cobra.Command {
+    var (
+        rev    string
+        around int
+        format string
+    )
+    cmd := &cobra.Command{
+        Use:   "explain-match <repo> <path> <line> | explain-match <repo/path:line> | explain-match -",
+        Short: "汇总一处匹配的上下文：所在行的 blame、引入它的提交说明、附近代码与链接，便于贴进事故或评审文档",
+        Long: `一个参数时按 find -f lines 的输出格式 repo/path:line[:预览] 解析，仓库名取前三段（host/owner/name）；
+仓库名不是这种形式时用三个参数。也可以是本地文件 path:line（按 git remote 推断仓库），
+或 - 从 stdin 读取第一行。默认输出 Markdown，-f text 为纯文本，-f json 供脚本处理。
+
+  kb explain-match github.com/acme/api internal/server.go 42
+  kb find 'os.Setenv' -f lines | head -1 | kb explain-match -
+  kb explain-match ./internal/server.go:42 -C 10 | pbcopy`,
+        Args: cobra.RangeArgs(1, 3),
+        RunE: func(cmd *cobra.Command, args []string) error {
+            if err := checkFormat(format, "markdown", "text", "json"); err != nil {
+                return err
+            }
+            repo, path, line, err := parseMatchSpec(args)
+            if err != nil {
+                return err
+            }
+            mc, err := explainMatch(cmd.Context(), sg.New(), repo, rev, path, line, around)
+            if err != nil {
+                return err
+            }
+            if format == "json" {
+                return writeJSON(os.Stdout, mc)
+            }
+            writeMatchContext(os.Stdout, mc, format == "markdown")
+            return nil
+        },
+    }
+    cmd.Flags().StringVar(&rev, "rev", "", "分支、标签或 commit（默认 HEAD）")
+    cmd.Flags().IntVarP(&around, "context", "C", 5, "显示匹配行前后的行数")
+    cmd.Flags().StringVarP(&format, "format", "f", "markdown", "输出格式：markdown|text|json")
+    return cmd
+}
+
+// parseMatchSpec 把参数解析为 仓库/路径/行号
+func parseMatchSpec(args []string) (string, string, int, error) {
+    if len(args) == 2 {
+        return "", "", 0, i18n.Errorf("需要 <repo> <path> <line> 三个参数，或一个 repo/path:line")
+    }
+    if len(args) == 3 {
+        line, err := strconv.Atoi(args[2])
+        if err != nil || line <= 0 {
+            return "", "", 0, i18n.Errorf("行号应为正整数：%q", args[2])
+        }
+        return args[0], args[1], line, nil
+    }
+    spec := args[0]
+    if spec == "-" {
+        sc := bufio.NewScanner(os.Stdin)
+        sc.Buffer(make([]byte, 0, 64*1024), 1<<20)
+        if !sc.Scan() {
+            return "", "", 0, i18n.Errorf("stdin 中没有匹配")
+        }
+        spec = strings.TrimSpace(sc.Text())
+    }
+    file, rest, ok := strings.Cut(spec, ":")
+    num, _, _ := strings.Cut(rest, ":")
+    line, err := strconv.Atoi(num)
+    if !ok || err != nil || line <= 0 {
+        return "", "", 0, i18n.Errorf("无法解析 %q，应为 repo/path:line", spec)
+    }
+    if _, err := os.Stat(file); err == nil {
+        repo, path, err := localToRemote(file)
+        return repo, path, line, err
+    }
+    parts := strings.SplitN(file, "/", 4)
+    if len(parts) < 4 || !strings.Contains(parts[0], ".") {
+        return "", "", 0, i18n.Errorf("无法从 %q 中分出仓库名，请用 <repo> <path> <line> 三个参数", file)
+    }
+    return strings.Join(parts[:3], "/"), parts[3], line, nil
+}
+
+// explainMatch 拉取文件内容与附近代码的 blame，找出引入匹配行的提交
+func explainMatch(ctx context.Context, c *sg.Client, repo, rev, path string, line, around int) (*MatchContext, error) {
+    content, err := c.FileContent(ctx, repo, rev, path)
+    if err != nil {
+        return nil, err
+    }
+    lines := strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+    if line > len(lines) {
+        return nil, i18n.Errorf("%s 只有 %d 行", path, len(lines))
+    }
+    start, end := max(line-around, 1), min(line+around, len(lines))
+    mc := &MatchContext{Repo: repo, Rev: rev, Path: path, Line: line, URL: blobURL(c, repo, rev, path, strconv.Itoa(line))}
+    if l := loc.Detect(path); l != nil {
+        mc.Language = l.Name
+    }
+    // 固定链接指向 rev 当前解析到的提交，文件之后改动也不会错位
+    if head, err := c.Commits(ctx, repo, rev, "", time.Time{}, 1); err == nil && len(head) == 1 {
+        mc.Permalink = blobURL(c, repo, head[0].OID, path, strconv.Itoa(line))
+    }
+    hunks, err := c.Blame(ctx, repo, rev, path, start, end)
+    if err != nil {
+        return nil, err
+    }
+    owner := func(n int) *sg.Commit {
+        for i := range hunks {
+            if hunks[i].StartLine <= n && n < hunks[i].EndLine {
+                return &hunks[i].Commit
+            }
+        }
+        return nil
+    }
+    for n := start; n <= end; n++ {
+        cl := ContextLine{Line: n, Text: lines[n-1]}
+        if cm := owner(n); cm != nil {
+            cl.Commit = cm.OID
+        }
+        mc.Code = append(mc.Code, cl)
+    }
+    if cm := owner(line); cm != nil {
+        intro := *cm
+        intro.URL = c.URL(intro.URL)
+        mc.Introduced = &intro
+    }
+    seen := map[string]bool{}
+    if mc.Introduced != nil {
+        seen[mc.Introduced.OID] = true
+    }
+    for _, h := range hunks {
+        if seen[h.Commit.OID] {
+            continue
+        }
+        seen[h.Commit.OID] = true
+        cm := h.Commit
+        cm.URL = c.URL(cm.URL)
+        mc.Nearby = append(mc.Nearby, cm)
+    }
+    sort.SliceStable(mc.Nearby, func(i, j int) bool { return mc.Nearby[i].Date.After(mc.Nearby[j].Date) })
+    return mc, nil
+}
+
+func shortOID(oid string) string {
+    if len(oid) > 7 {
+        return oid[:7]
+    }
+    return oid
+}
+
+// writeMatchContext 把上下文写成一段可直接粘贴的 Markdown 或纯文本
+func writeMatchContext(w io.Writer, mc *MatchContext, md bool) {
+    commitRef := func(cm *sg.Commit) string {
+        if md && cm.URL != "" {
+            return fmt.Sprintf("[`%s`](%s)", shortOID(cm.OID), cm.URL)
+        }
+        return shortOID(cm.OID)
+    }
+    where := fmt.Sprintf("%s/%s:%d", mc.Repo, mc.Path, mc.Line)
+    if mc.Rev != "" {
+        where = fmt.Sprintf("%s@%s/%s:%d", mc.Repo, mc.Rev, mc.Path, mc.Line)
+    }
+    if md {
+        fmt.Fprintf(w, "#### `%s`\n\n", where)
+        links := []string{fmt.Sprintf("[%s](%s)", i18n.T("在 Sourcegraph 中查看"), mc.URL)}
+        if mc.Permalink != "" {
+            links = append(links, fmt.Sprintf("[%s](%s)", i18n.T("固定链接"), mc.Permalink))
+        }
+        fmt.Fprintf(w, "%s\n\n", strings.Join(links, " · "))
+        fmt.Fprintf(w, "```%s\n", strings.ToLower(strings.ReplaceAll(mc.Language, " ", "")))
+    } else {
+        fmt.Fprintln(w, where)
+        fmt.Fprintln(w, mc.URL)
+        if mc.Permalink != "" {
+            fmt.Fprintln(w, mc.Permalink)
+        }
+        fmt.Fprintln(w)
+    }
+    width := len(strconv.Itoa(mc.Code[len(mc.Code)-1].Line))
+    for _, l := range mc.Code {
+        mark := " "
+        if l.Line == mc.Line {
+            mark = ">"
+        }
+        fmt.Fprintf(w, "%s %s %*d | %s\n", shortOID(l.Commit), mark, width, l.Line, l.Text)
+    }
+    if md {
+        fmt.Fprintln(w, "```")
+    }
+    fmt.Fprintln(w)
+
+    if cm := mc.Introduced; cm != nil {
+        title := i18n.T("引入这一行的提交")
+        if md {
+            title = "**" + title + "**"
+        }
+        fmt.Fprintf(w, "%s: %s %s\n", title, commitRef(cm), cm.Subject)
+        fmt.Fprintf(w, "%s <%s> · %s\n", cm.Author, cm.Email, cm.Date.Local().Format("2006-01-02 15:04"))
+        if body := strings.TrimSpace(cm.Body); body != "" {
+            fmt.Fprintln(w)
+            for _, l := range strings.Split(body, "\n") {
+                if md {
+                    l = strings.TrimRight("> "+l, " ")
+                } else {
+                    l = strings.TrimRight("    "+l, " ")
+                }
+                fmt.Fprintln(w, l)
+            }
+        }
+        fmt.Fprintln(w)
+    }
+    if len(mc.Nearby) > 0 {
+        title := i18n.T("附近代码的其他提交")
+        if md {
+            title = "**" + title + "**"
+        }
+        fmt.Fprintln(w, title)
+        for i := range mc.Nearby {
+            cm := &mc.Nearby[i]
+            fmt.Fprintf(w, "- %s %s %s: %s\n", commitRef(cm), cm.Date.Local().Format("2006-01-02"), cm.Author, cm.Subject)
+        }
+    }
+}
+
+func init() { rootCmd.AddCommand(newExplainMatchCmd()) }
@@ -0,0 +1,55 @@
+package cli
+
+import (
+    "strings"
+    "time"
+
+    "github.com/spf13/cobra"
+    "kingbrain/insight/pkg/export"
+    "kingbrain/insight/pkg/sg"
+)
+
+func addExportFlag(cmd *cobra.Command, specs *[]string) {
+    cmd.Flags().StringArrayVar(specs, "export", nil,
+        "导出结果，格式 kind=path（可重复），kind 可选："+strings.Join(export.Kinds(), "|"))
+}
+
+// runExports 依次执行所有 --export
+func runExports(specs []string, run *export.Run) error {
+    for _, s := range specs {
+        if err := export.Write(s, run); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+func newRun(command, query string) *export.Run {
+    return &export.Run{Command: command, Query: query, Started: time.Now()}
+}
+
+// exportMatches 把搜索结果展开成逐行的导出记录
+func exportMatches(c *sg.Client, res *sg.SearchResults) []export.Match {
+    var out []export.Match
+    for _, fm := range res.Results {
+        for _, lm := range fm.LineMatches {
+            out = append(out, export.Match{
+                Repo:        fm.Repository.Name,
+                Path:        fm.File.Path,
+                Line:        lm.LineNumber + 1,
+                Preview:     lm.Preview,
+                URL:         c.URL(fm.File.URL),
+                Annotations: lm.Annotations,
+            })
+        }
+    }
+    return out
+}
+
+func exportFindings(findings []Finding) []export.Match {
+    out := make([]export.Match, 0, len(findings))
+    for _, f := range findings {
+        out = append(out, export.Match{Repo: f.Repo, Path: f.Path, Line: f.Line, Rule: f.Rule, Preview: f.Text, URL: f.URL})
+    }
+    return out
+}
@@ -0,0 +1,44 @@
+package cli
+
+import (
+    "context"
+    "sync"
+
+    "kingbrain/insight/pkg/config"
+    "kingbrain/insight/pkg/i18n"
+    "kingbrain/insight/pkg/sg"
+)
+
+// instanceResult 是某个实例返回的搜索结果
+type instanceResult struct {
+    Instance string
+    Client   *sg.Client
+    Results  *sg.SearchResults
+    Err      error
+}
+
+// federatedSearch 把同一个查询并发发往配置中的所有实例，结果按配置顺序返回
+func federatedSearch(ctx context.Context, q, pattern string) ([]instanceResult, error) {
+    cfg, err := config.Load()
+    if err != nil {
+        return nil, err
+    }
+    if len(cfg.Instances) == 0 {
+        p, _ := config.Path()
+        return nil, i18n.Errorf("--federate 需要在 %s 中配置 instances", p)
+    }
+
+    out := make([]instanceResult, len(cfg.Instances))
+    var wg sync.WaitGroup
+    for i, inst := range cfg.Instances {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            c := sg.NewInstance(inst.URL, inst.TokenFor())
+            res, err := c.Search(ctx, q, pattern)
+            out[i] = instanceResult{Instance: inst.Name, Client: c, Results: res, Err: err}
+        }()
+    }
+    wg.Wait()
+    return out, nil
+}
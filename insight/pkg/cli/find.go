@@ -1,70 +1,243 @@
 package cli
 
 import (
+    "context"
     "fmt"
+    "os"
     "github.com/spf13/cobra"
+    "kingbrain/insight/pkg/export"
+    "kingbrain/insight/pkg/i18n"
     "kingbrain/insight/pkg/sg"
 )
 
 func newFindCmd() *cobra.Command {
-    var pattern string
+    var (
+        pattern  string
+        exports  []string
+        federate bool
+        repos    []string
+        revs     []string
+        allBr    bool
+        brLimit  int
+        format   string
+        nul      bool
+        encl     bool
+        allOf    []string
+        anyOf    []string
+        summary  bool
+        failHit  bool
+        failNone bool
+    )
 
     cmd := &cobra.Command{
-        Use:   "find [-p pattern] <keyword>",
+        Use:   "find [-p pattern] <keyword|-> | find --all-of a --all-of b [--any-of c ...]",
         Short: "在 Sourcegraph 上做搜索：文本、正则或结构化",
-        Args:  cobra.MinimumNArgs(1),
-        RunE: func(_ *cobra.Command, args []string) error {
-            // 第一个位置参数就是 keyword
-            keyword := args[0]
+        Long: `退出码与 grep 相同：有匹配为 0，没有匹配为 1，出错为 2。
+--fail-if-matches 反过来，有匹配时以 1 退出，用于 CI 中“禁止出现 X”的检查；
+--fail-if-none 是默认行为的显式写法，没有匹配时在 stderr 说明原因。
 
-            // 构造 GraphQL 查询，动态注入 patternType（枚举无需引号）
-            query := fmt.Sprintf(`
-query ($q: String!) {
-  search(version: V3, query: $q, patternType: %s) {
-    results {
-      matchCount
-      results {
-        ... on FileMatch {
-          file { path }
-          lineMatches { preview lineNumber }
-        }
-      }
-    }
-  }
-}
-`, pattern)
-
-            // 发送请求并解析任意返回结构
-            var out map[string]any
-            if err := sg.New().GraphQL(query, map[string]any{"q": keyword}, &out); err != nil {
+  kb find 'import "github.com/pkg/errors"' --fail-if-matches`,
+        Args: func(cmd *cobra.Command, args []string) error {
+            if len(args) == 0 && len(allOf)+len(anyOf) == 0 {
+                return i18n.Errorf("需要 keyword 或 --all-of/--any-of")
+            }
+            return nil
+        },
+        RunE: func(cmd *cobra.Command, args []string) error {
+            out, err := newMatchPrinter(format, nul, encl)
+            if err != nil {
                 return err
             }
+            out.hide = hideGenerated
+            // 第一个位置参数就是 keyword，"-" 时从 stdin 读取
+            var q string
+            if len(args) > 0 {
+                if q, err = readQueryArg(args[0]); err != nil {
+                    return err
+                }
+            }
+            keyword := buildQuery(boolQuery(q, allOf, anyOf), repoFilter(repos))
 
-            // 挖出 data.search.results
-            data := out["data"].(map[string]any)
-            search := data["search"].(map[string]any)
-            results := search["results"].(map[string]any)
+            if failHit && failNone {
+                return i18n.Errorf("--fail-if-matches 与 --fail-if-none 不能同时使用")
+            }
+            run := newRun("find", keyword)
+            // --summarize 的摘要跟在结果后面；非 text 格式写到 stderr，不破坏机器可读的输出。
+            // 之后按匹配数决定退出码
+            summarize := func() error {
+                out.done()
+                if summary {
+                    w := os.Stderr
+                    if out.text() {
+                        w = os.Stdout
+                    }
+                    if err := summarizeMatches(cmd.Context(), w, keyword, run.Matches); err != nil {
+                        return err
+                    }
+                }
+                return matchExit(cmd, out.matched, failHit, failNone)
+            }
+            if federate {
+                if len(revs) > 0 || allBr {
+                    return i18n.Errorf("--federate 不能与 --rev/--all-branches 同时使用")
+                }
+                if err := findFederated(cmd.Context(), out, run, keyword, pattern, exports); err != nil {
+                    return err
+                }
+                return summarize()
+            }
+
+            // 发送请求并解析为类型化结果
+            c := sg.New()
+            if len(revs) > 0 || allBr {
+                all, err := expandRevs(cmd.Context(), c, revs, repos, allBr, brLimit)
+                if err != nil {
+                    return err
+                }
+                if err := findRevs(cmd.Context(), c, out, run, keyword, pattern, all, exports); err != nil {
+                    return err
+                }
+                return summarize()
+            }
+            if !encl {
+                // 边解码边输出，不保留整个结果集；只有导出或摘要时才收集匹配
+                if err := findStream(cmd.Context(), c, out, run, keyword, pattern, len(exports) > 0 || summary); err != nil {
+                    return err
+                }
+                if err := runExports(exports, run); err != nil {
+                    return err
+                }
+                return summarize()
+            }
+            res, err := c.Search(cmd.Context(), keyword, pattern)
+            if err != nil {
+                return err
+            }
 
             // 打印总命中数
-            fmt.Printf("Total matches: %v\n\n", results["matchCount"])
+            if out.text() {
+                fmt.Printf("Total matches: %v\n\n", res.MatchCount)
+            }
 
             // 逐条列出文件路径和行预览
-            for _, item := range results["results"].([]any) {
-                fm := item.(map[string]any)
-                file := fm["file"].(map[string]any)
-                fmt.Printf("File: %s\n", file["path"])
-                for _, lm := range fm["lineMatches"].([]any) {
-                    m := lm.(map[string]any)
-                    fmt.Printf("  %5v | %s\n", m["lineNumber"], m["preview"])
-                }
-                fmt.Println()
+            if err := out.print(cmd.Context(), c, "", "", res); err != nil {
+                return err
             }
-            return nil
+
+            run.Matches = exportMatches(c, res)
+            if err := runExports(exports, run); err != nil {
+                return err
+            }
+            return summarize()
         },
     }
 
     // 可选的模式标志：literal|regexp|structural
     cmd.Flags().StringVarP(&pattern, "pattern", "p", "literal",
         "搜索模式：literal（文本）|regexp（正则）|structural（结构化）")
+    cmd.Flags().StringArrayVar(&allOf, "all-of", nil, "必须同时出现的关键词（可重复，AND）")
+    cmd.Flags().StringArrayVar(&anyOf, "any-of", nil, "至少出现一个的关键词（可重复，OR）")
+    cmd.Flags().StringSliceVar(&repos, "repo", nil, "限定仓库（可重复，支持正则；含 * ? 的 glob 按仓库缓存展开）")
+    cmd.Flags().StringSliceVar(&revs, "rev", nil, "在指定分支/标签/commit 上搜索（可重复），按 revision 汇总结果")
+    cmd.Flags().BoolVar(&allBr, "all-branches", false, "枚举 --repo 指定仓库的所有分支并逐个搜索")
+    cmd.Flags().IntVar(&brLimit, "branch-limit", 100, "--all-branches 时每个仓库最多枚举的分支数")
+    cmd.Flags().BoolVar(&federate, "federate", false, "并发搜索配置文件中的所有实例并合并结果")
+    cmd.Flags().StringVarP(&format, "format", "f", "", "输出格式：text|lines|json|paths（默认终端为 text，管道为 lines）")
+    cmd.Flags().BoolVarP(&nul, "null", "0", false, "只输出文件路径，以 NUL 分隔（配合 xargs -0）")
+    cmd.Flags().BoolVar(&encl, "enclosing-function", false, "拉取文件并打印每个匹配所在的整个函数/方法（Go、Python、JS/TS、Java、C/C++、Rust 等）")
+    cmd.Flags().BoolVar(&failHit, "fail-if-matches", false, "有匹配时以退出码 1 结束（没有匹配为 0）")
+    cmd.Flags().BoolVar(&failNone, "fail-if-none", false, "没有匹配时以退出码 1 结束，并在 stderr 说明（默认行为的显式写法）")
+    cmd.Flags().BoolVar(&summary, "summarize", false, "把匹配行（先脱敏、按 token 上限截断）发给配置的 llm 接口，打印用法模式摘要")
+    addExportFlag(cmd, &exports)
     return cmd
 }
+
+// printFileMatches 打印文件与行预览；instance 非空时在文件前标注来源实例
+func printFileMatches(instance string, res *sg.SearchResults) {
+    for _, fm := range res.Results {
+        if instance != "" {
+            fmt.Printf("File: [%s] %s/%s%s\n", instance, fm.Repository.Name, fm.File.Path, indexSuffix(fm.Index))
+        } else {
+            fmt.Printf("File: %s%s\n", fm.File.Path, indexSuffix(fm.Index))
+        }
+        for _, m := range fm.LineMatches {
+            fmt.Printf("  %5v | %s%s\n", m.LineNumber, renderMatch(m), annotationSuffix(m.Annotations))
+        }
+        fmt.Println()
+    }
+}
+
+// findFederated 在所有实例上搜索；部分实例失败只告警，全部失败才报错
+func findFederated(ctx context.Context, out *matchPrinter, run *export.Run, keyword, pattern string, exports []string) error {
+    results, err := federatedSearch(ctx, keyword, pattern)
+    if err != nil {
+        return err
+    }
+    total, failed := 0, 0
+    for _, r := range results {
+        if r.Err != nil {
+            failed++
+            fmt.Fprintf(os.Stderr, "[%s] %v\n", r.Instance, r.Err)
+            continue
+        }
+        total += r.Results.MatchCount
+    }
+    if failed == len(results) {
+        return i18n.Errorf("所有实例均查询失败")
+    }
+
+    if out.text() {
+        fmt.Printf("Total matches: %v\n\n", total)
+    }
+    for _, r := range results {
+        if r.Err != nil {
+            continue
+        }
+        if err := out.print(ctx, r.Client, r.Instance, "", r.Results); err != nil {
+            return err
+        }
+        for _, m := range exportMatches(r.Client, r.Results) {
+            m.Instance = r.Instance
+            run.Matches = append(run.Matches, m)
+        }
+    }
+    return runExports(exports, run)
+}
+
+// findStream 用 SearchEach 逐个文件输出结果；keep 为 true 时把匹配收集到 run 中供导出
+func findStream(ctx context.Context, c *sg.Client, out *matchPrinter, run *export.Run, keyword, pattern string, keep bool) error {
+    header := false
+    res, err := c.SearchEach(ctx, keyword, pattern, func(res *sg.SearchResults, fm sg.FileMatch) error {
+        if out.text() && !header {
+            fmt.Printf("Total matches: %v\n\n", res.MatchCount)
+            header = true
+        }
+        one := &sg.SearchResults{Results: []sg.FileMatch{fm}}
+        if keep {
+            run.Matches = append(run.Matches, exportMatches(c, one)...)
+        }
+        return out.print(ctx, c, "", "", one)
+    })
+    if err != nil {
+        return err
+    }
+    if out.text() && !header {
+        fmt.Printf("Total matches: %v\n\n", res.MatchCount)
+    }
+    return nil
+}
+
+// matchExit 按匹配数给出 find 的退出码：默认没有匹配为 1；failHit 时有匹配为 1
+func matchExit(cmd *cobra.Command, n int, failHit, failNone bool) error {
+    switch {
+    case failHit && n > 0:
+        return exitWith(cmd, exitFalse, i18n.Sprintf("发现 %d 处匹配（--fail-if-matches）", n))
+    case failHit:
+        return nil
+    case n == 0 && failNone:
+        return exitWith(cmd, exitFalse, i18n.T("没有匹配（--fail-if-none）"))
+    case n == 0:
+        return exitWith(cmd, exitFalse, "")
+    }
+    return nil
+}
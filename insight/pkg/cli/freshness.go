@@ -0,0 +1,31 @@
+package cli
+
+import (
+    "time"
+
+    "kingbrain/insight/pkg/i18n"
+    "kingbrain/insight/pkg/sg"
+)
+
+// indexSuffix 把仓库的索引状态写成 text 输出中文件标题后的 "  [索引 ...]"；没有标注时为空
+func indexSuffix(s *sg.IndexStatus) string {
+    switch {
+    case s == nil:
+        return ""
+    case !s.Indexed:
+        return i18n.T("  [未索引，搜索最新代码]")
+    case s.Current:
+        return i18n.Sprintf("  [索引最新 %s@%s]", s.Branch, shortOID(s.Commit))
+    }
+    mark := ""
+    if s.Stale {
+        mark = i18n.T("已过期，")
+    }
+    return i18n.Sprintf("  [索引%s落后于 %s：%s 更新于 %s前，提交于 %s前]", mark, s.Branch, shortOID(s.Commit),
+        sg.FormatAge(s.Lag(time.Now())), sg.FormatAge(time.Since(s.CommitDate)))
+}
+
+func init() {
+    rootCmd.PersistentFlags().BoolVar(&sg.DefaultFreshness.Annotate, "index-age", false, "给每个结果标注所在仓库与分支的索引状态：索引的提交、是否落后于分支、最后更新的时间（搜索结束后批量查询，结果随后一起输出）")
+    rootCmd.PersistentFlags().DurationVar(&sg.DefaultFreshness.StaleAfter, "warn-stale", 0, "标出来自落后超过该时长（如 24h）的索引的匹配，并在 stderr 汇总这些仓库；隐含 --index-age")
+}
@@ -0,0 +1,62 @@
+package cli
+
+import (
+    "fmt"
+    "os"
+    "sort"
+    "strings"
+
+    "kingbrain/insight/pkg/generated"
+    "kingbrain/insight/pkg/i18n"
+    "kingbrain/insight/pkg/sg"
+)
+
+// hideGenerated 是 --hide-generated 的值；只作用于 find、run-template 打印的结果（见 matchPrinter），
+// 计数、退出码、导出以及其他命令的统计都按完整的搜索结果
+var hideGenerated bool
+
+func init() {
+    rootCmd.PersistentFlags().BoolVar(&hideGenerated, "hide-generated", true, "find、run-template 打印结果时隐藏疑似噪音的匹配：超长行（压缩代码）、高熵的编码数据、二进制内容、带生成代码标记的行与 .min.js、.pb.go 等生成文件，并在 stderr 给出隐藏的数量；匹配数、退出码与导出不受影响")
+}
+
+// dropGenerated 返回 fm 去掉噪音行匹配后的副本，生成文件（见 generated.Path）中的匹配全部去掉，
+// 数量按原因计入 hidden；没有剩下行匹配时返回 false。只匹配路径的结果（没有行匹配）原样保留。
+// 不改动 fm 的 LineMatches，调用方之后还会把完整的结果用于导出
+func dropGenerated(fm sg.FileMatch, hidden generated.Counts) (sg.FileMatch, bool) {
+    if len(fm.LineMatches) == 0 {
+        return fm, true
+    }
+    if generated.Path(fm.File.Path) {
+        hidden[generated.Generated] += len(fm.LineMatches)
+        return fm, false
+    }
+    var kept []sg.LineMatch
+    for _, lm := range fm.LineMatches {
+        if r := generated.Line(lm.Preview); r != "" {
+            hidden[r]++
+            continue
+        }
+        kept = append(kept, lm)
+    }
+    fm.LineMatches = kept
+    return fm, len(kept) > 0
+}
+
+// printHidden 在 stderr 说明隐藏了多少匹配及原因
+func printHidden(hidden generated.Counts) {
+    if hidden.Total() == 0 {
+        return
+    }
+    names := map[generated.Reason]string{
+        generated.Minified:  i18n.T("压缩代码"),
+        generated.Encoded:   i18n.T("编码数据"),
+        generated.Binary:    i18n.T("二进制内容"),
+        generated.Generated: i18n.T("生成代码"),
+    }
+    var parts []string
+    for r, n := range hidden {
+        parts = append(parts, fmt.Sprintf("%s %d", names[r], n))
+    }
+    sort.Strings(parts)
+    fmt.Fprint(os.Stderr, i18n.Sprintf("已隐藏 %d 个疑似噪音的匹配（%s），--hide-generated=false 显示全部\n", hidden.Total(), strings.Join(parts, i18n.T("，"))))
+}
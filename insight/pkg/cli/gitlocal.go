@@ -0,0 +1,121 @@
+package cli
+
+import (
+    "fmt"
+    "net/url"
+    "os/exec"
+    "path/filepath"
+    "strconv"
+    "strings"
+    "time"
+
+    "kingbrain/insight/pkg/i18n"
+    "kingbrain/insight/pkg/sg"
+)
+
+// gitOutput 在 dir 下执行 git 并返回去掉首尾空白的输出
+func gitOutput(dir string, args ...string) (string, error) {
+    out, err := gitRawOutput(dir, args...)
+    return strings.TrimSpace(out), err
+}
+
+// gitRawOutput 与 gitOutput 相同，但原样返回输出，用于 -z 这类首尾空白也有意义的格式
+func gitRawOutput(dir string, args ...string) (string, error) {
+    out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).Output()
+    if err != nil {
+        if ee, ok := err.(*exec.ExitError); ok && len(ee.Stderr) > 0 {
+            return "", fmt.Errorf("git %s: %s", strings.Join(args, " "), strings.TrimSpace(string(ee.Stderr)))
+        }
+        return "", err
+    }
+    return string(out), nil
+}
+
+// gitRoot 返回 path 所在仓库的根目录
+func gitRoot(path string) (string, error) {
+    dir := path
+    if !isDir(path) {
+        dir = filepath.Dir(path)
+    }
+    root, err := gitOutput(dir, "rev-parse", "--show-toplevel")
+    if err != nil {
+        return "", err
+    }
+    return filepath.FromSlash(root), nil
+}
+
+// remoteRepoName 把 git remote 地址转换为 Sourcegraph 仓库名：
+// git@github.com:acme/api.git、ssh://git@github.com/acme/api、https://github.com/acme/api.git
+// 都对应 github.com/acme/api
+func remoteRepoName(remote string) (string, error) {
+    remote = strings.TrimSuffix(strings.TrimSpace(remote), "/")
+    remote = strings.TrimSuffix(remote, ".git")
+    if !strings.Contains(remote, "://") {
+        // scp 风格：[user@]host:path
+        if at := strings.Index(remote, "@"); at >= 0 {
+            remote = remote[at+1:]
+        }
+        host, path, ok := strings.Cut(remote, ":")
+        if !ok {
+            return "", i18n.Errorf("无法识别的 remote 地址 %q", remote)
+        }
+        return host + "/" + strings.TrimPrefix(path, "/"), nil
+    }
+    u, err := url.Parse(remote)
+    if err != nil {
+        return "", err
+    }
+    return u.Hostname() + u.Path, nil
+}
+
+// localRepoName 返回本地仓库 origin 对应的 Sourcegraph 仓库名
+func localRepoName(root string) (string, error) {
+    remote, err := gitOutput(root, "remote", "get-url", "origin")
+    if err != nil {
+        return "", err
+    }
+    return remoteRepoName(remote)
+}
+
+// localCommits 用 git log 列出 dir 下（或 dir 内 path 下）的提交，字段与 Sourcegraph 的提交历史一致；
+// rev 为空时取 HEAD，since 非零时只列该时间之后的提交，limit 为 0 表示不限
+func localCommits(dir, rev, path string, since time.Time, limit int) ([]sg.Commit, error) {
+    if rev == "" {
+        rev = "HEAD"
+    }
+    args := []string{"log", "--format=%H%x1f%P%x1f%an%x1f%ae%x1f%aI%x1f%s%x1f%b%x1e"}
+    if !since.IsZero() {
+        args = append(args, "--since="+since.Format(time.RFC3339))
+    }
+    if limit > 0 {
+        args = append(args, "-n", strconv.Itoa(limit))
+    }
+    if path == "" {
+        path = "."
+    }
+    out, err := gitOutput(dir, append(args, rev, "--", path)...)
+    if err != nil {
+        return nil, err
+    }
+    var commits []sg.Commit
+    for _, rec := range strings.Split(out, "\x1e") {
+        f := strings.Split(strings.TrimLeft(rec, "\n"), "\x1f")
+        if len(f) < 7 {
+            continue
+        }
+        date, err := time.Parse(time.RFC3339, f[4])
+        if err != nil {
+            return nil, err
+        }
+        commits = append(commits, sg.Commit{
+            OID:     f[0],
+            Merge:   len(strings.Fields(f[1])) > 1,
+            Author:  f[2],
+            Email:   f[3],
+            Date:    date,
+            Subject: f[5],
+            Body:    strings.TrimSpace(f[6]),
+        })
+    }
+    return commits, nil
+}
@@ -0,0 +1,361 @@
+package cli
+
+import (
+    "context"
+    "fmt"
+    "os"
+    "path"
+    "regexp"
+    "sort"
+    "strings"
+    "sync"
+    "time"
+
+    "github.com/spf13/cobra"
+    "kingbrain/insight/pkg/i18n"
+    "kingbrain/insight/pkg/manifest"
+    "kingbrain/insight/pkg/progress"
+    "kingbrain/insight/pkg/sg"
+)
+
+// archiveTag 是查询在某个标签上的结果
+type archiveTag struct {
+    Tag     string    `json:"tag"`
+    Date    time.Time `json:"date"`
+    Matches int       `json:"matches"`
+    Paths   []string  `json:"paths,omitempty"`
+    Error   string    `json:"error,omitempty"`
+}
+
+// archiveChange 是相邻两个标签之间匹配从无到有（appeared）或从有到无（disappeared）的变化
+type archiveChange struct {
+    Tag    string `json:"tag"`
+    Change string `json:"change"`
+}
+
+// archiveReport 是一个仓库按版本顺序排列的各标签结果；GoneIn 为最后一次出现之后的第一个标签，
+// 最新的标签中仍有匹配时为空
+type archiveReport struct {
+    Repo      string          `json:"repo"`
+    Tags      []archiveTag    `json:"tags"`
+    FirstSeen string          `json:"first_seen,omitempty"`
+    LastSeen  string          `json:"last_seen,omitempty"`
+    GoneIn    string          `json:"gone_in,omitempty"`
+    Changes   []archiveChange `json:"changes,omitempty"`
+}
+
+func newGrepArchiveCmd() *cobra.Command {
+    var (
+        tagGlobs []string
+        tagLimit int
+        pattern  string
+        order    string
+        parallel int
+        files    bool
+        format   string
+    )
+
+    cmd := &cobra.Command{
+        Use:   "grep-archive <query|-> <repo>...",
+        Short: "在仓库的发布标签上逐个搜索，找出匹配最早出现与消失的版本",
+        Long: `按 --tags 的 glob 列出每个仓库的标签，把查询展开成每个标签一次的 rev: 搜索，
+再按版本号（--sort date 时按标签提交时间）排序，报告匹配首次出现、最后出现以及从哪个版本起消失，
+适合事故排查时确认问题代码进入和离开了哪些发布版本。仓库名支持 * ? glob（按仓库缓存展开）。
+所有标签上都没有匹配时以退出码 1 结束。
+
+  kb grep-archive 'InsecureSkipVerify: true' github.com/acme/api --tags 'v1.*'
+  kb grep-archive -p regexp 'legacyAuth\(' 'github.com/acme/payments-*' --tags 'v2.*' --tags 'release-*' -f json`,
+        Args: cobra.MinimumNArgs(2),
+        RunE: func(cmd *cobra.Command, args []string) error {
+            if err := checkFormat(format, "text", "json"); err != nil {
+                return err
+            }
+            if err := checkFormat(order, "version", "date"); err != nil {
+                return err
+            }
+            q, err := readQueryArg(args[0])
+            if err != nil {
+                return err
+            }
+            ctx := cmd.Context()
+            c := sg.New()
+            repos, err := expandRepoNames(ctx, c, args[1:])
+            if err != nil {
+                return err
+            }
+
+            var reports []*archiveReport
+            for _, repo := range repos {
+                tags, err := releaseTags(ctx, c, repo, tagGlobs, tagLimit)
+                if err != nil {
+                    return err
+                }
+                if len(tags) == 0 {
+                    fmt.Fprint(os.Stderr, i18n.Sprintf("警告: %s 中没有匹配 %s 的标签，跳过\n", repo, strings.Join(tagGlobs, ", ")))
+                    continue
+                }
+                sortTags(tags, order)
+                r := &archiveReport{Repo: repo}
+                for _, t := range tags {
+                    r.Tags = append(r.Tags, archiveTag{Tag: t.Name, Date: t.Date})
+                }
+                reports = append(reports, r)
+            }
+            if len(reports) == 0 {
+                return i18n.Errorf("没有可搜索的标签")
+            }
+
+            searchArchive(ctx, c, q, pattern, reports, parallel)
+            seen, failed, total := false, 0, 0
+            for _, r := range reports {
+                r.summarize()
+                seen = seen || r.FirstSeen != ""
+                for _, t := range r.Tags {
+                    total++
+                    if t.Error != "" {
+                        failed++
+                    }
+                }
+            }
+            if failed == total {
+                return i18n.Errorf("所有标签均查询失败")
+            }
+
+            if format == "json" {
+                if err := writeJSON(os.Stdout, reports); err != nil {
+                    return err
+                }
+            } else {
+                for _, r := range reports {
+                    printArchiveReport(r, files)
+                }
+            }
+            if !seen {
+                return exitWith(cmd, exitFalse, "")
+            }
+            return nil
+        },
+    }
+
+    cmd.Flags().StringArrayVarP(&tagGlobs, "tags", "t", []string{"*"}, "要搜索的标签，glob（可重复），如 'v1.*'")
+    cmd.Flags().IntVar(&tagLimit, "tag-limit", 100, "每个仓库最多搜索的标签数：先按 --tags 过滤，再取提交时间最近的")
+    cmd.Flags().StringVarP(&pattern, "pattern", "p", "literal", "搜索模式：literal|regexp|structural")
+    cmd.Flags().StringVar(&order, "sort", "version", "标签排序：version（按版本号）|date（按提交时间）")
+    cmd.Flags().IntVarP(&parallel, "parallel", "j", 4, "并发查询数")
+    cmd.Flags().BoolVar(&files, "files", false, "text 格式下列出出现与消失时的匹配文件")
+    cmd.Flags().StringVarP(&format, "format", "f", "text", "输出格式：text|json")
+    return cmd
+}
+
+// expandRepoNames 把含 * ? 的参数按仓库缓存展开成仓库全名，其余原样保留
+func expandRepoNames(ctx context.Context, c *sg.Client, args []string) ([]string, error) {
+    var out []string
+    for _, a := range args {
+        if !isRepoGlob(a) {
+            out = append(out, a)
+            continue
+        }
+        cache, err := cachedRepos(ctx, c, false)
+        if err != nil {
+            return nil, i18n.Errorf("展开 %s: %w", a, err)
+        }
+        n := len(out)
+        for _, r := range cache.Repos {
+            if ok, _ := path.Match(a, r.Name); ok {
+                out = append(out, r.Name)
+            }
+        }
+        if len(out) == n {
+            return nil, i18n.Errorf("%s 没有匹配任何仓库（可运行 kb repos --refresh 更新缓存）", a)
+        }
+    }
+    return out, nil
+}
+
+// tagFetchLimit 是每个仓库最多取的标签数，glob 过滤与 --tag-limit 都在取回之后进行
+const tagFetchLimit = 10000
+
+// releaseTags 列出仓库中匹配任一 glob 的标签，按提交时间取最近的 limit 个。服务端的 query 是子串匹配，
+// 数量上限又作用在 glob 过滤之前，所以这里不带 query 取回标签，先按 glob 过滤再截取
+func releaseTags(ctx context.Context, c *sg.Client, repo string, globs []string, limit int) ([]sg.Tag, error) {
+    tags, err := c.Tags(ctx, repo, "", tagFetchLimit)
+    if err != nil {
+        return nil, err
+    }
+    if len(tags) >= tagFetchLimit {
+        fmt.Fprint(os.Stderr, i18n.Sprintf("警告: %s 的标签超过 %d 个，只在最近的 %d 个中按 --tags 过滤\n", repo, tagFetchLimit, tagFetchLimit))
+    }
+    var out []sg.Tag
+    for _, t := range tags {
+        if limit > 0 && len(out) == limit {
+            break
+        }
+        for _, g := range globs {
+            if ok, _ := path.Match(g, t.Name); ok {
+                out = append(out, t)
+                break
+            }
+        }
+    }
+    return out, nil
+}
+
+// sortTags 按版本号或提交时间从旧到新排序
+func sortTags(tags []sg.Tag, order string) {
+    sort.SliceStable(tags, func(i, j int) bool {
+        a, b := tags[i], tags[j]
+        if order == "date" && !a.Date.Equal(b.Date) {
+            return a.Date.Before(b.Date)
+        }
+        if c := manifest.Compare(a.Name, b.Name); c != 0 {
+            return c < 0
+        }
+        return a.Name < b.Name
+    })
+}
+
+// searchArchive 在每个仓库的每个标签上各搜一次，结果写回 reports
+func searchArchive(ctx context.Context, c *sg.Client, q, pattern string, reports []*archiveReport, parallel int) {
+    type job struct {
+        repo string
+        tag  *archiveTag
+    }
+    var jobs []job
+    for _, r := range reports {
+        for i := range r.Tags {
+            jobs = append(jobs, job{r.Repo, &r.Tags[i]})
+        }
+    }
+    bar := progress.New(len(jobs))
+    ch := make(chan job)
+    var wg sync.WaitGroup
+    for w := 0; w < max(parallel, 1); w++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            for j := range ch {
+                label := j.repo + "@" + j.tag.Tag
+                bar.Begin(label)
+                res, err := c.Search(ctx, buildQuery(q, "repo:^"+regexp.QuoteMeta(j.repo)+"$", "rev:"+j.tag.Tag), pattern)
+                if err != nil {
+                    j.tag.Error = err.Error()
+                } else {
+                    j.tag.Matches = res.MatchCount
+                    for _, fm := range res.Results {
+                        j.tag.Paths = append(j.tag.Paths, fm.File.Path)
+                    }
+                }
+                bar.End(label, err)
+            }
+        }()
+    }
+    for _, j := range jobs {
+        ch <- j
+    }
+    close(ch)
+    wg.Wait()
+    bar.Finish()
+}
+
+// summarize 计算首次出现、最后出现、消失的版本与每次变化；查询失败的标签不参与比较
+func (r *archiveReport) summarize() {
+    present, known := false, false
+    for _, t := range r.Tags {
+        if t.Error != "" {
+            continue
+        }
+        hit := t.Matches > 0
+        if hit && r.FirstSeen == "" {
+            r.FirstSeen = t.Tag
+        }
+        if hit {
+            r.LastSeen, r.GoneIn = t.Tag, ""
+        } else if present {
+            r.GoneIn = t.Tag
+        }
+        if known && hit != present {
+            change := "appeared"
+            if !hit {
+                change = "disappeared"
+            }
+            r.Changes = append(r.Changes, archiveChange{Tag: t.Tag, Change: change})
+        } else if !known && hit {
+            r.Changes = append(r.Changes, archiveChange{Tag: t.Tag, Change: "appeared"})
+        }
+        present, known = hit, true
+    }
+}
+
+func printArchiveReport(r *archiveReport, files bool) {
+    fmt.Print(i18n.Sprintf("%s（%d 个标签）\n", r.Repo, len(r.Tags)))
+    changes := map[string]string{}
+    for _, ch := range r.Changes {
+        changes[ch.Tag] = ch.Change
+    }
+    width := 0
+    for _, t := range r.Tags {
+        width = max(width, len(t.Tag))
+    }
+    prev := map[string]bool{}
+    for _, t := range r.Tags {
+        date := ""
+        if !t.Date.IsZero() {
+            date = t.Date.Format("2006-01-02")
+        }
+        var status string
+        switch {
+        case t.Error != "":
+            status = i18n.Sprintf("查询失败: %s", t.Error)
+        case t.Matches == 0:
+            status = i18n.T("没有匹配")
+        default:
+            status = i18n.Sprintf("%d 处匹配 / %d 个文件", t.Matches, len(t.Paths))
+        }
+        mark := ""
+        switch changes[t.Tag] {
+        case "appeared":
+            mark = i18n.T("  + 出现")
+        case "disappeared":
+            mark = i18n.T("  - 消失")
+        }
+        fmt.Printf("  %-*s  %-10s  %s%s\n", width, t.Tag, date, status, mark)
+        if t.Error != "" {
+            continue
+        }
+        // --files 时列出相对上一个标签新增或消失的文件
+        if files && mark != "" {
+            cur := map[string]bool{}
+            for _, p := range t.Paths {
+                cur[p] = true
+                if !prev[p] {
+                    fmt.Printf("      + %s\n", p)
+                }
+            }
+            var gone []string
+            for p := range prev {
+                if !cur[p] {
+                    gone = append(gone, p)
+                }
+            }
+            sort.Strings(gone)
+            for _, p := range gone {
+                fmt.Printf("      - %s\n", p)
+            }
+        }
+        prev = map[string]bool{}
+        for _, p := range t.Paths {
+            prev[p] = true
+        }
+    }
+    switch {
+    case r.FirstSeen == "":
+        fmt.Println(i18n.T("  所有标签中均没有匹配"))
+    case r.GoneIn == "":
+        fmt.Print(i18n.Sprintf("  首次出现于 %s，最新的标签 %s 中仍存在\n", r.FirstSeen, r.LastSeen))
+    default:
+        fmt.Print(i18n.Sprintf("  首次出现于 %s，最后出现于 %s，自 %s 起消失\n", r.FirstSeen, r.LastSeen, r.GoneIn))
+    }
+    fmt.Println()
+}
+
+func init() { rootCmd.AddCommand(newGrepArchiveCmd()) }
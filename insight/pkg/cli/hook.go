@@ -0,0 +1,51 @@
+package cli
+
+import (
+    "github.com/spf13/cobra"
+    "kingbrain/insight/pkg/hook"
+    "kingbrain/insight/pkg/sg"
+)
+
+var hookPath string
+
+func init() {
+    rootCmd.PersistentFlags().StringVar(&hookPath, "hook", "", "Starlark 脚本，在输出与导出之前过滤、改写或注解每处匹配（见 kb help hooks）")
+    rootCmd.AddCommand(&cobra.Command{
+        Use:   "hooks",
+        Short: "用 Starlark 脚本处理搜索结果（--hook）",
+        Long: `--hook 指定的 Starlark 脚本会在搜索结果输出、导出之前处理每处匹配，对所有会发起搜索的命令
+（find、batch、audit、todos 等）都生效。脚本必须定义 match(m)，m 是一个 dict：
+
+  repo, path, url   仓库、文件路径与链接
+  line              行号（从 1 开始，路径匹配为 0）
+  preview           匹配行
+  annotations       注解 dict，text 输出中显示在行尾，json 与 --export 中原样保留
+
+返回 None 或 False 丢弃这处匹配，True 原样保留，返回 dict（通常就是改过的 m）时按其中的
+preview 与 annotations 更新匹配。除 Starlark 内置函数外还可以用 re_search(pattern, s)，
+返回第一处匹配的子串，没有时为 None；print 输出到 stderr。一个文件的匹配全被丢弃时整个文件不再输出，
+服务端给出的总匹配数不受影响。
+
+  def match(m):
+      if "/testdata/" in m["path"]:
+          return None
+      sev = "high" if re_search(r"(?i)password|secret", m["preview"]) else "low"
+      m["annotations"]["severity"] = sev
+      return m
+
+  kb find 'os.Getenv(' --hook severity.star -f json`,
+    })
+}
+
+// applyHook 加载 --hook 并设置 sg.DefaultHook，之后创建的 Client 的搜索结果都先经过脚本
+func applyHook() error {
+    if hookPath == "" {
+        return nil
+    }
+    s, err := hook.Load(hookPath)
+    if err != nil {
+        return err
+    }
+    sg.DefaultHook = s.Apply
+    return nil
+}
@@ -0,0 +1,403 @@
+package cli
+
+import (
+    "bufio"
+    "context"
+    "errors"
+    "fmt"
+    "io"
+    "net/http"
+    "net/url"
+    "os"
+    "path/filepath"
+    "runtime"
+    "strings"
+    "time"
+
+    "github.com/spf13/cobra"
+    "golang.org/x/term"
+    "kingbrain/insight/pkg/config"
+    "kingbrain/insight/pkg/i18n"
+    "kingbrain/insight/pkg/sg"
+)
+
+// applyEndpoint 把配置文件中的 endpoint（由 init 写入）设为没有 SG_URL、LOCAL_SG_ENDPOINT 时的默认实例
+func applyEndpoint() error {
+    cfg, err := config.Load()
+    if err != nil {
+        return err
+    }
+    sg.DefaultEndpoint, sg.DefaultToken = cfg.Endpoint.URL, cfg.Endpoint.TokenFor()
+    return nil
+}
+
+// prompter 在终端中逐项提问；yes 时不提问，直接采用默认值
+type prompter struct {
+    in  *bufio.Reader
+    yes bool
+}
+
+// ask 提问并返回输入，直接回车时返回 def
+func (p *prompter) ask(question, def string) (string, error) {
+    if p.yes {
+        return def, nil
+    }
+    if def != "" {
+        fmt.Printf("%s [%s]: ", question, def)
+    } else {
+        fmt.Printf("%s: ", question)
+    }
+    line, err := p.in.ReadString('\n')
+    if err != nil && !(errors.Is(err, io.EOF) && line != "") {
+        return "", err
+    }
+    if line = strings.TrimSpace(line); line != "" {
+        return line, nil
+    }
+    return def, nil
+}
+
+// confirm 提问是否继续，直接回车时返回 def
+func (p *prompter) confirm(question string, def bool) (bool, error) {
+    hint := "y/N"
+    if def {
+        hint = "Y/n"
+    }
+    for {
+        ans, err := p.ask(question+" ("+hint+")", "")
+        if err != nil || ans == "" {
+            return def, err
+        }
+        switch strings.ToLower(ans) {
+        case "y", "yes", "是":
+            return true, nil
+        case "n", "no", "否":
+            return false, nil
+        }
+    }
+}
+
+// secret 读取不回显的输入（token），stdin 不是终端时按普通行读取
+func (p *prompter) secret(question string) (string, error) {
+    if p.yes {
+        return "", nil
+    }
+    if !stdinTTY() {
+        return p.ask(question, "")
+    }
+    fmt.Printf("%s: ", question)
+    b, err := term.ReadPassword(int(os.Stdin.Fd()))
+    fmt.Println()
+    return strings.TrimSpace(string(b)), err
+}
+
+func newInitCmd() *cobra.Command {
+    var (
+        endpoint     string
+        tokenEnv     string
+        shell        string
+        yes          bool
+        noCompletion bool
+    )
+
+    cmd := &cobra.Command{
+        Use:   "init",
+        Short: "引导完成初始配置：实例地址、访问 token、连通性检查、写入配置文件、安装 shell 补全、检查 scc",
+        Long: `逐步完成首次使用所需的配置：
+
+  1. Sourcegraph 实例地址（默认取已有配置或 SG_URL）
+  2. 给出创建访问 token 的页面链接，读取粘贴的 token（不回显；留空则沿用已有 token 或匿名访问）
+  3. 用该地址与 token 查询当前用户，检查连通性与 token 是否有效
+  4. 写入配置文件的 endpoint 一节（文件权限 0600）；没有设置 SG_URL、LOCAL_SG_ENDPOINT 时各命令使用它
+  5. 为当前 shell（bash、zsh、fish，按 $SHELL 判断或用 --shell 指定）安装补全
+  6. 检查 scc 是否可用（scc、audit 等命令的行数统计需要它）
+
+环境变量仍然优先于配置文件。--token-env 时配置文件只记录环境变量名，不保存 token 本身；
+-y 时不提问，全部采用默认值（需要 --url、已有配置或 SG_URL），适合脚本化的环境准备。例如：
+
+  kb init
+  kb init --url https://sourcegraph.acme.com --token-env ACME_SG_TOKEN -y`,
+        Args: cobra.NoArgs,
+        RunE: func(cmd *cobra.Command, _ []string) error {
+            if !yes && !stdinTTY() {
+                return i18n.Errorf("init 需要在终端中运行，或使用 -y 与 --url")
+            }
+            stopPager()
+            cfgPath, err := config.Path()
+            if err != nil {
+                return err
+            }
+            cfg, err := config.Load()
+            if err != nil {
+                return err
+            }
+            p := &prompter{in: bufio.NewReader(os.Stdin), yes: yes}
+
+            // 1. 实例地址
+            def := endpoint
+            if def == "" {
+                def = cfg.Endpoint.URL
+            }
+            if def == "" {
+                def = os.Getenv("SG_URL")
+            }
+            var base string
+            for {
+                ans, err := p.ask(i18n.T("Sourcegraph 实例地址"), def)
+                if err != nil {
+                    return err
+                }
+                if base, err = normalizeEndpoint(ans); err == nil {
+                    break
+                }
+                if yes {
+                    return err
+                }
+                fmt.Println(err)
+            }
+
+            // 2. 访问 token
+            inst := config.Instance{Name: cfg.Endpoint.Name, URL: base}
+            if inst.Name == "" || cfg.Endpoint.URL != base {
+                u, _ := url.Parse(base)
+                inst.Name = u.Host
+            }
+            if cfg.Endpoint.URL == base {
+                inst.Token, inst.TokenEnv = cfg.Endpoint.Token, cfg.Endpoint.TokenEnv
+            }
+            if tokenEnv != "" {
+                inst.Token, inst.TokenEnv = "", tokenEnv
+                if os.Getenv(tokenEnv) == "" {
+                    fmt.Fprint(os.Stderr, i18n.Sprintf("警告: 环境变量 %s 当前为空\n", tokenEnv))
+                }
+            } else if !yes {
+                // Sourcegraph 把 /user/settings 重定向到当前登录用户的设置页
+                link := base + "/user/settings/tokens/new"
+                fmt.Print(i18n.Sprintf("\n在浏览器中创建访问 token：%s\n", link))
+                if ok, err := p.confirm(i18n.T("现在打开这个页面？"), false); err != nil {
+                    return err
+                } else if ok {
+                    if err := openBrowser(link); err != nil {
+                        fmt.Fprintln(os.Stderr, err)
+                    }
+                }
+                hint := i18n.T("留空为匿名访问")
+                if inst.TokenFor() != "" {
+                    hint = i18n.T("留空沿用已有 token")
+                }
+                tok, err := p.secret(i18n.Sprintf("粘贴 token（不回显，%s）", hint))
+                if err != nil {
+                    return err
+                }
+                if tok != "" {
+                    inst.Token, inst.TokenEnv = tok, ""
+                }
+            }
+
+            // 3. 连通性检查
+            fmt.Print(i18n.Sprintf("\n正在连接 %s ...\n", base))
+            if user, err := checkEndpoint(cmd.Context(), base, inst.TokenFor()); err != nil {
+                fmt.Print(i18n.Sprintf("✗ 连接失败：%v\n", err))
+                if yes {
+                    return i18n.Errorf("连通性检查失败，未写入配置")
+                }
+                ok, err := p.confirm(i18n.T("仍然保存配置？"), false)
+                if err != nil {
+                    return err
+                }
+                if !ok {
+                    return exitWith(cmd, exitFalse, i18n.T("未写入配置"))
+                }
+            } else if user == "" {
+                fmt.Print(i18n.T("✓ 已连接（匿名访问，私有仓库可能搜不到）\n"))
+            } else {
+                fmt.Print(i18n.Sprintf("✓ 已连接，当前用户 %s\n", user))
+            }
+
+            // 4. 写入配置文件
+            cfg.Endpoint = inst
+            commented := config.HasComments()
+            if err := cfg.Save(); err != nil {
+                return err
+            }
+            fmt.Print(i18n.Sprintf("✓ 已写入 %s\n", cfgPath))
+            if commented {
+                fmt.Print(i18n.T("注意: 配置文件按当前设置重新生成，原有的注释没有保留\n"))
+            }
+            for _, env := range []string{"SG_URL", "LOCAL_SG_ENDPOINT"} {
+                if v := os.Getenv(env); v != "" && v != base {
+                    fmt.Print(i18n.Sprintf("注意: 环境变量 %s=%s 优先于配置文件，如需使用新配置请取消设置\n", env, v))
+                }
+            }
+            if os.Getenv("SG_TOKEN") != "" && inst.TokenEnv != "SG_TOKEN" {
+                fmt.Print(i18n.T("注意: 环境变量 SG_TOKEN 优先于配置文件中的 token\n"))
+            }
+
+            // 5. shell 补全
+            if !noCompletion {
+                if shell == "" {
+                    shell = detectShell()
+                }
+                if err := offerCompletion(cmd.Root(), p, shell); err != nil {
+                    fmt.Print(i18n.Sprintf("✗ 安装补全失败：%v\n", err))
+                }
+            }
+
+            // 6. scc
+            if bin, err := sccBinary(); err == nil {
+                fmt.Print(i18n.Sprintf("✓ 找到 scc：%s\n", bin))
+            } else {
+                fmt.Print(i18n.T("- 没有找到 scc（可选，scc、audit 等命令的行数统计需要它）：go install github.com/boyter/scc/v3@latest，或见 https://github.com/boyter/scc\n"))
+            }
+
+            fmt.Print(i18n.T("\n完成。试试：kb find 'TODO' --repo <仓库>\n"))
+            return nil
+        },
+    }
+
+    cmd.Flags().StringVar(&endpoint, "url", "", "Sourcegraph 实例地址（默认取已有配置或 SG_URL）")
+    cmd.Flags().StringVar(&tokenEnv, "token-env", "", "从该环境变量读取 token，配置文件中只记录变量名")
+    cmd.Flags().StringVar(&shell, "shell", "", "安装补全的 shell：bash|zsh|fish（默认按 $SHELL 判断）")
+    cmd.Flags().BoolVarP(&yes, "yes", "y", false, "不提问，全部采用默认值")
+    cmd.Flags().BoolVar(&noCompletion, "no-completion", false, "不安装 shell 补全")
+    return cmd
+}
+
+// normalizeEndpoint 补全协议、去掉末尾的 /，并检查地址格式
+func normalizeEndpoint(s string) (string, error) {
+    s = strings.TrimRight(strings.TrimSpace(s), "/")
+    if s == "" {
+        return "", i18n.Errorf("需要 Sourcegraph 实例地址，如 https://sourcegraph.example.com")
+    }
+    if !strings.Contains(s, "://") {
+        s = "https://" + s
+    }
+    u, err := url.Parse(s)
+    if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+        return "", i18n.Errorf("无效的实例地址 %q", s)
+    }
+    return s, nil
+}
+
+// checkEndpoint 查询 token 对应的用户，确认地址可达、token 有效；匿名访问时返回空用户名
+func checkEndpoint(ctx context.Context, base, token string) (string, error) {
+    ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+    defer cancel()
+    user, err := sg.NewInstance(base, token).CurrentUser(ctx)
+    var se *sg.StatusError
+    if errors.As(err, &se) && (se.StatusCode == http.StatusUnauthorized || se.StatusCode == http.StatusForbidden) {
+        return "", i18n.Errorf("token 无效或没有权限（HTTP %d）", se.StatusCode)
+    }
+    if err == nil && user == "" && token != "" {
+        return "", i18n.Errorf("实例没有识别这个 token")
+    }
+    return user, err
+}
+
+// detectShell 按 $SHELL 判断当前 shell，Windows 上为 powershell
+func detectShell() string {
+    if sh := filepath.Base(os.Getenv("SHELL")); sh != "." && sh != "" {
+        return strings.TrimSuffix(sh, ".exe")
+    }
+    if runtime.GOOS == "windows" {
+        return "powershell"
+    }
+    return ""
+}
+
+// offerCompletion 询问后为 shell 安装补全：bash、fish 写入各自自动加载的目录，
+// zsh 在 .zshrc 末尾加一行 source；其他 shell 只给出手动安装的方法
+func offerCompletion(root *cobra.Command, p *prompter, shell string) error {
+    name := root.Name()
+    switch shell {
+    case "bash", "zsh", "fish":
+    case "powershell", "pwsh":
+        fmt.Print(i18n.Sprintf("- PowerShell 补全请在 $PROFILE 中加入：%s completion powershell | Out-String | Invoke-Expression\n", name))
+        return nil
+    default:
+        fmt.Print(i18n.Sprintf("- 不认识的 shell %q，跳过补全安装（见 %s completion --help）\n", shell, name))
+        return nil
+    }
+    ok, err := p.confirm(i18n.Sprintf("为 %s 安装 %s 的命令补全？", shell, name), true)
+    if err != nil || !ok {
+        return err
+    }
+    home, err := os.UserHomeDir()
+    if err != nil {
+        return err
+    }
+    dataHome := os.Getenv("XDG_DATA_HOME")
+    if dataHome == "" {
+        dataHome = filepath.Join(home, ".local", "share")
+    }
+    configHome := os.Getenv("XDG_CONFIG_HOME")
+    if configHome == "" {
+        configHome = filepath.Join(home, ".config")
+    }
+
+    var path string
+    switch shell {
+    case "bash":
+        // bash-completion 2 按命令名从这个目录自动加载
+        path = filepath.Join(dataHome, "bash-completion", "completions", name)
+        err = writeCompletion(path, func(w io.Writer) error { return root.GenBashCompletionV2(w, true) })
+    case "fish":
+        path = filepath.Join(configHome, "fish", "completions", name+".fish")
+        err = writeCompletion(path, func(w io.Writer) error { return root.GenFishCompletion(w, true) })
+    case "zsh":
+        dir := os.Getenv("ZDOTDIR")
+        if dir == "" {
+            dir = home
+        }
+        path = filepath.Join(dir, ".zshrc")
+        err = appendLine(path, fmt.Sprintf("source <(%s completion zsh)", name))
+    }
+    if err != nil {
+        return err
+    }
+    fmt.Print(i18n.Sprintf("✓ 已安装补全：%s（新开的 shell 中生效）\n", path))
+    return nil
+}
+
+func writeCompletion(path string, gen func(io.Writer) error) error {
+    if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+        return err
+    }
+    f, err := os.Create(path)
+    if err != nil {
+        return err
+    }
+    if err := gen(f); err != nil {
+        f.Close()
+        return err
+    }
+    return f.Close()
+}
+
+// appendLine 在文件末尾追加一行，文件中已有这一行时什么都不做
+func appendLine(path, line string) error {
+    b, err := os.ReadFile(path)
+    if err != nil && !errors.Is(err, os.ErrNotExist) {
+        return err
+    }
+    for _, l := range strings.Split(string(b), "\n") {
+        if strings.TrimSpace(l) == line {
+            return nil
+        }
+    }
+    f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+    if err != nil {
+        return err
+    }
+    prefix := ""
+    if len(b) > 0 && !strings.HasSuffix(string(b), "\n") {
+        prefix = "\n"
+    }
+    if _, err := fmt.Fprintf(f, "%s%s\n", prefix, line); err != nil {
+        f.Close()
+        return err
+    }
+    return f.Close()
+}
+
+func init() { rootCmd.AddCommand(newInitCmd()) }
@@ -0,0 +1,162 @@
+package cli
+
+import (
+    "bytes"
+    "fmt"
+    "os"
+    "sort"
+    "strings"
+    "text/template"
+
+    "github.com/spf13/cobra"
+    "kingbrain/insight/pkg/i18n"
+    "kingbrain/insight/pkg/issues"
+)
+
+// Finding 是各类扫描命令（todos 等）产出的一条通用发现，用于建 issue、导出等
+type Finding struct {
+    Repo string `json:"repo"`
+    Path string `json:"path"`
+    Line int    `json:"line"`
+    Rule string `json:"rule"`
+    Text string `json:"text"`
+    URL  string `json:"url"`
+}
+
+const (
+    // 标题是去重的依据（已存在同名的打开 issue 时跳过），不能带匹配数这类每次运行都会变的内容；
+    // 带上来源命令，避免 todos、audit、migrate 等在同一仓库的 issue 互相当成已存在
+    defaultIssueTitle = `[insight] {{.Source}}{{if .Rule}}: {{.Rule}}{{end}} in {{.Repo}}`
+    defaultIssueBody  = `insight {{.Source}} 在 {{.Repo}} 中发现 {{.Count}} 处{{if .Rule}} {{.Rule}}{{end}}：
+
+{{range .Findings}}- [{{.Repo}}/{{.Path}}:{{.Line}}]({{.URL}}) {{.Text}}
+{{end}}`
+)
+
+type issueOptions struct {
+    enabled   bool
+    groupBy   string
+    target    string
+    titleTmpl string
+    bodyTmpl  string
+    labels    []string
+    dryRun    bool
+    source    string // 来源，默认是命令名，见 issueData.Source
+}
+
+// issueData 是标题/正文模板可用的字段
+type issueData struct {
+    Repo     string
+    Source   string
+    Rule     string
+    Count    int
+    Findings []Finding
+}
+
+func addIssueFlags(cmd *cobra.Command, o *issueOptions) {
+    o.source = cmd.Name()
+    f := cmd.Flags()
+    f.BoolVar(&o.enabled, "create-issues", false, "为发现创建 GitHub/GitLab issue（已存在同名的打开 issue 时跳过）")
+    f.StringVar(&o.groupBy, "issue-per", "repo", "issue 粒度：repo（每仓库一个）|rule（每规则一个）")
+    f.StringVar(&o.target, "issue-repo", "", "issue 统一建在此仓库（--issue-per rule 时必填），如 github.com/acme/tracker")
+    f.StringVar(&o.titleTmpl, "issue-title", "", "issue 标题模板（text/template，可用 .Repo .Source .Rule .Count .Findings）；同名的打开 issue 视为已存在，标题中不要放 .Count 等会变的字段")
+    f.StringVar(&o.bodyTmpl, "issue-body", "", "issue 正文模板（text/template），默认列出全部匹配链接")
+    f.StringSliceVar(&o.labels, "issue-label", nil, "issue 标签（可重复）")
+    f.BoolVar(&o.dryRun, "dry-run", false, "只打印将要创建的 issue，不调用 API")
+}
+
+// createIssues 按 repo 或 rule 分组发现，渲染模板并逐个创建 issue；进度写到 stderr，不干扰结果输出
+func createIssues(o issueOptions, findings []Finding) error {
+    if !o.enabled || len(findings) == 0 {
+        return nil
+    }
+    if o.groupBy != "repo" && o.groupBy != "rule" {
+        return i18n.Errorf("--issue-per 只能是 repo 或 rule")
+    }
+    if o.groupBy == "rule" && o.target == "" {
+        return i18n.Errorf("--issue-per rule 需要同时指定 --issue-repo")
+    }
+    if o.titleTmpl == "" {
+        o.titleTmpl = defaultIssueTitle
+    }
+    if o.bodyTmpl == "" {
+        o.bodyTmpl = defaultIssueBody
+    }
+    title, err := template.New("title").Parse(o.titleTmpl)
+    if err != nil {
+        return fmt.Errorf("--issue-title: %w", err)
+    }
+    body, err := template.New("body").Parse(o.bodyTmpl)
+    if err != nil {
+        return fmt.Errorf("--issue-body: %w", err)
+    }
+
+    groups := map[string]*issueData{}
+    for _, f := range findings {
+        key, d := f.Repo, issueData{Repo: f.Repo, Source: o.source}
+        if o.groupBy == "rule" {
+            key, d = f.Rule, issueData{Repo: o.target, Source: o.source, Rule: f.Rule}
+        }
+        g, ok := groups[key]
+        if !ok {
+            g = &d
+            groups[key] = g
+        }
+        g.Findings = append(g.Findings, f)
+        g.Count++
+    }
+    keys := make([]string, 0, len(groups))
+    for k := range groups {
+        keys = append(keys, k)
+    }
+    sort.Strings(keys)
+
+    var failed int
+    for _, k := range keys {
+        g := groups[k]
+        var t, b bytes.Buffer
+        if err := title.Execute(&t, g); err != nil {
+            return err
+        }
+        if err := body.Execute(&b, g); err != nil {
+            return err
+        }
+        is := issues.Issue{Title: strings.TrimSpace(t.String()), Body: b.String(), Labels: o.labels}
+        repo := g.Repo
+        if o.target != "" {
+            repo = o.target
+        }
+
+        if o.dryRun {
+            fmt.Fprintf(os.Stderr, "[dry-run] %s: %s\n%s\n", repo, is.Title, is.Body)
+            continue
+        }
+        url, err := createIssue(repo, is)
+        if err != nil {
+            failed++
+            fmt.Fprintf(os.Stderr, "✗ %s: %v\n", repo, err)
+            continue
+        }
+        fmt.Fprintf(os.Stderr, "✓ %s: %s\n", repo, url)
+    }
+    if failed > 0 {
+        return i18n.Errorf("%d 个 issue 创建失败", failed)
+    }
+    return nil
+}
+
+// createIssue 去重后创建单个 issue，已存在时返回原链接
+func createIssue(repo string, is issues.Issue) (string, error) {
+    tr, err := issues.ForRepo(repo)
+    if err != nil {
+        return "", err
+    }
+    url, found, err := tr.FindOpen(is.Title)
+    if err != nil {
+        return "", err
+    }
+    if found {
+        return i18n.Sprintf("%s（已存在，跳过）", url), nil
+    }
+    return tr.Create(is)
+}
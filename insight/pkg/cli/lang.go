@@ -0,0 +1,89 @@
+package cli
+
+import (
+    "fmt"
+    "os"
+    "strings"
+    "sync"
+
+    "github.com/spf13/cobra"
+    "github.com/spf13/pflag"
+    "kingbrain/insight/pkg/i18n"
+)
+
+// langFlag 是 --ui-lang 的值；不叫 --lang，因为 ast-grep、repos、usage-examples 的 --lang 是编程语言过滤器
+var langFlag string
+
+var (
+    langOnce sync.Once
+    langErr  error
+)
+
+// usageHeadings 是 cobra 用法模板中需要翻译的固定文本
+var usageHeadings = []string{
+    "Usage:", "Aliases:", "Examples:", "Available Commands:", "Additional Commands:",
+    "Global Flags:", "Flags:", "Additional help topics:",
+    `Use "{{.CommandPath}} [command] --help" for more information about a command.`,
+}
+
+func init() {
+    rootCmd.PersistentFlags().StringVar(&langFlag, "ui-lang", "", "界面语言：zh-CN|en-US（默认取 INSIGHT_LANG，未设置时为 zh-CN）")
+
+    // --help 在 PersistentPreRunE 之前处理，帮助与用法输出要在这里先选好语言
+    help, usage := rootCmd.HelpFunc(), rootCmd.UsageFunc()
+    rootCmd.SetHelpFunc(func(c *cobra.Command, args []string) {
+        if err := applyLang(c.Root()); err != nil {
+            fmt.Fprintln(os.Stderr, i18n.T("警告:"), err)
+        }
+        help(c, args)
+    })
+    rootCmd.SetUsageFunc(func(c *cobra.Command) error {
+        applyLang(c.Root())
+        return usage(c)
+    })
+}
+
+// applyLang 按 --ui-lang、INSIGHT_LANG 的顺序选择界面语言，并把整棵命令树的帮助文本换成该语言；
+// 只在第一次调用时生效，语言不支持时仍用默认语言翻译帮助
+func applyLang(root *cobra.Command) error {
+    langOnce.Do(func() {
+        l := langFlag
+        if l == "" {
+            l = os.Getenv("INSIGHT_LANG")
+        }
+        if l != "" {
+            langErr = i18n.SetLang(l)
+        }
+        localizeCommands(root, map[*pflag.Flag]bool{})
+        tmpl := "\n" + root.UsageTemplate()
+        for _, h := range usageHeadings {
+            tmpl = strings.ReplaceAll(tmpl, "\n"+h, "\n"+i18n.T(h))
+        }
+        root.SetUsageTemplate(tmpl[1:])
+    })
+    return langErr
+}
+
+// localizeCommands 翻译 c 及其子命令的说明与 flag 用法；seen 避免继承下来的同一个 flag 被翻译两次
+func localizeCommands(c *cobra.Command, seen map[*pflag.Flag]bool) {
+    c.Short, c.Long, c.Example = i18n.T(c.Short), i18n.T(c.Long), i18n.T(c.Example)
+    localize := func(f *pflag.Flag) {
+        if seen[f] {
+            return
+        }
+        seen[f] = true
+        // cobra 自动生成的 --help/--version 的用法带命令名，按格式串翻译
+        for _, p := range []string{"help for ", "version for "} {
+            if name, ok := strings.CutPrefix(f.Usage, p); ok {
+                f.Usage = i18n.Sprintf(p+"%s", name)
+                return
+            }
+        }
+        f.Usage = i18n.T(f.Usage)
+    }
+    c.PersistentFlags().VisitAll(localize)
+    c.Flags().VisitAll(localize)
+    for _, sub := range c.Commands() {
+        localizeCommands(sub, seen)
+    }
+}
@@ -0,0 +1,180 @@
+package cli
+
+import (
+    "fmt"
+    "io/fs"
+    "net/url"
+    "os"
+    "os/exec"
+    "path/filepath"
+    "regexp"
+    "runtime"
+    "strings"
+
+    "github.com/spf13/cobra"
+    "kingbrain/insight/pkg/config"
+    "kingbrain/insight/pkg/i18n"
+)
+
+// <base>/<repo>[@rev]/-/blob/<path>
+var blobPathRe = regexp.MustCompile(`^/(.+?)(?:@[^/]+)?/-/(?:blob|tree)/(.+)$`)
+
+// scanDepth 是在工作区根目录下查找 git 仓库的最大深度（覆盖 root/host/org/repo 布局）
+const scanDepth = 4
+
+func newLocalCmd() *cobra.Command {
+    var edit bool
+
+    cmd := &cobra.Command{
+        Use:   "local <sourcegraph-url> | local <repo> <path> [line]",
+        Short: "把 Sourcegraph 上的结果映射到本地检出路径，可直接用 $EDITOR 打开",
+        Long: `按配置文件 workspace.repos / workspace.roots 把远程结果映射为本地绝对路径。
+
+  kb local https://sg.example.com/github.com/acme/api/-/blob/main.go?L42
+  kb local github.com/acme/api main.go 42 --edit`,
+        Args: cobra.RangeArgs(1, 3),
+        RunE: func(_ *cobra.Command, args []string) error {
+            repo, path, line, err := parseRemoteRef(args)
+            if err != nil {
+                return err
+            }
+            cfg, err := config.Load()
+            if err != nil {
+                return err
+            }
+            root, err := findCheckout(cfg.Workspace, repo)
+            if err != nil {
+                return err
+            }
+            abs := filepath.Join(root, filepath.FromSlash(path))
+            if edit {
+                return openEditor(abs, line)
+            }
+            if line != "" {
+                fmt.Printf("%s:%s\n", abs, line)
+            } else {
+                fmt.Println(abs)
+            }
+            return nil
+        },
+    }
+
+    cmd.Flags().BoolVarP(&edit, "edit", "e", false, "用 $EDITOR 打开（vim 风格 +line，VS Code 用 -g）")
+    return cmd
+}
+
+// parseRemoteRef 支持 Sourcegraph 链接或 repo path [line] 两种写法
+func parseRemoteRef(args []string) (repo, path, line string, err error) {
+    if len(args) > 1 {
+        repo, path = args[0], args[1]
+        if len(args) == 3 {
+            line = args[2]
+        }
+        return repo, path, line, nil
+    }
+    u, err := url.Parse(args[0])
+    if err != nil {
+        return "", "", "", err
+    }
+    m := blobPathRe.FindStringSubmatch(u.Path)
+    if m == nil {
+        return "", "", "", i18n.Errorf("不是 Sourcegraph 文件链接：%s", args[0])
+    }
+    // ?L12 或 ?L12-20，只取起始行
+    for k := range u.Query() {
+        if strings.HasPrefix(k, "L") {
+            line, _, _ = strings.Cut(k[1:], "-")
+            line, _, _ = strings.Cut(line, ":")
+        }
+    }
+    return m[1], m[2], line, nil
+}
+
+// findCheckout 依次尝试：显式映射、root/<repo 全名>、root/<仓库短名>、扫描 root 下 git remote 匹配的仓库
+func findCheckout(ws config.Workspace, repo string) (string, error) {
+    if dir, ok := ws.Repos[repo]; ok {
+        return config.ExpandHome(dir), nil
+    }
+    short := repo[strings.LastIndex(repo, "/")+1:]
+    for _, r := range ws.Roots {
+        r = config.ExpandHome(r)
+        for _, cand := range []string{filepath.Join(r, filepath.FromSlash(repo)), filepath.Join(r, short)} {
+            if name, err := localRepoName(cand); err == nil && name == repo {
+                return cand, nil
+            }
+        }
+    }
+    for _, r := range ws.Roots {
+        if dir := scanForRepo(config.ExpandHome(r), repo); dir != "" {
+            return dir, nil
+        }
+    }
+    if len(ws.Roots) == 0 && len(ws.Repos) == 0 {
+        p, _ := config.Path()
+        return "", i18n.Errorf("未配置工作区，请在 %s 中设置 workspace.roots", p)
+    }
+    return "", i18n.Errorf("在工作区中找不到 %s 的本地检出", repo)
+}
+
+// scanForRepo 在 root 下有限深度地查找 origin 指向 repo 的 git 仓库
+func scanForRepo(root, repo string) string {
+    var found string
+    // 配置里的 C:/src 与 WalkDir 产出的 C:\src\x 分隔符不同，先规范化再算深度
+    root = filepath.Clean(root)
+    base := strings.Count(strings.TrimSuffix(root, string(filepath.Separator)), string(filepath.Separator))
+    filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+        if found != "" {
+            return filepath.SkipAll
+        }
+        if err != nil || !d.IsDir() {
+            return nil
+        }
+        if strings.Count(p, string(filepath.Separator))-base > scanDepth {
+            return filepath.SkipDir
+        }
+        if _, err := os.Stat(filepath.Join(p, ".git")); err == nil {
+            if name, err := localRepoName(p); err == nil && name == repo {
+                found = p
+            }
+            return filepath.SkipDir
+        }
+        if p != root && strings.HasPrefix(d.Name(), ".") || d.Name() == "node_modules" || d.Name() == "vendor" {
+            return filepath.SkipDir
+        }
+        return nil
+    })
+    return found
+}
+
+// openEditor 以 $VISUAL/$EDITOR（默认 vi，Windows 上为 notepad）打开文件并跳到指定行
+func openEditor(path, line string) error {
+    // 只含空白的变量切分结果为空，按没有设置处理
+    parts := splitCommand(os.Getenv("VISUAL"))
+    if len(parts) == 0 {
+        parts = splitCommand(os.Getenv("EDITOR"))
+    }
+    if len(parts) == 0 && runtime.GOOS == "windows" {
+        parts = []string{"notepad"}
+    }
+    if len(parts) == 0 {
+        parts = []string{"vi"}
+    }
+    args := parts[1:]
+    name := strings.ToLower(filepath.Base(parts[0]))
+    for _, ext := range []string{".exe", ".cmd", ".bat"} {
+        name = strings.TrimSuffix(name, ext)
+    }
+    switch {
+    case line == "" || name == "notepad":
+        args = append(args, path)
+    case name == "code" || name == "codium" || name == "cursor":
+        args = append(args, "-g", path+":"+line)
+    default:
+        args = append(args, "+"+line, path)
+    }
+    cmd := exec.Command(parts[0], args...)
+    cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+    return cmd.Run()
+}
+
+func init() { rootCmd.AddCommand(newLocalCmd()) }
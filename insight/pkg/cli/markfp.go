@@ -0,0 +1,248 @@
+package cli
+
+import (
+    "encoding/json"
+    "fmt"
+    "io"
+    "os"
+    "os/user"
+    "path/filepath"
+    "strings"
+    "time"
+
+    "github.com/spf13/cobra"
+    "kingbrain/insight/pkg/falsepos"
+    "kingbrain/insight/pkg/i18n"
+    "kingbrain/insight/pkg/preview"
+)
+
+func newMarkFPCmd() *cobra.Command {
+    var (
+        reason string
+        name   string
+    )
+
+    cmd := &cobra.Command{
+        Use:   "mark-fp <match-id>...",
+        Short: "把 audit 的某处违规标记为误报，之后的 audit 报告自动排除；可导出导入标记与同事共享",
+        Long: `ID 来自 kb audit --matches 或 audit -f json 中的 violations，在最近保存的各记分卡结果里查找
+（--name 只查该记分卡），可以只写能唯一确定的前缀。误报按 规则 + 仓库 + 路径 + 匹配行内容 识别，
+行号变化不影响；匹配行本身被修改后需要重新标记。标记保存在 <用户配置目录>/insight/false-positives.json
+（INSIGHT_FALSE_POSITIVES 可指定其他路径，如放进团队共享的仓库）。
+
+  kb audit rules.tsv --matches
+  kb mark-fp 3f9a1c0b2e7d --reason "测试数据，不是真实密钥"
+  kb mark-fp list
+  kb mark-fp export > fp.json && kb mark-fp import fp.json`,
+        Args: cobra.MinimumNArgs(1),
+        RunE: func(cmd *cobra.Command, args []string) error {
+            store, err := falsepos.Load()
+            if err != nil {
+                return err
+            }
+            violations, err := savedViolations(name)
+            if err != nil {
+                return err
+            }
+            by := ""
+            if u, err := user.Current(); err == nil {
+                by = u.Username
+            }
+            added := 0
+            for _, id := range args {
+                v, err := lookupViolation(violations, id)
+                if err != nil {
+                    return err
+                }
+                e := falsepos.Entry{
+                    ID: v.ID, Rule: v.Rule, Repo: v.Repo, Path: v.Path, Hash: falsepos.LineHash(v.Preview),
+                    Preview: strings.TrimSpace(v.Preview), Reason: reason, By: by, Marked: time.Now().UTC(),
+                }
+                if store.Add(e) {
+                    added++
+                    fmt.Print(i18n.Sprintf("已标记 %s：%s %s/%s\n", v.ID, v.Rule, v.Repo, v.Path))
+                } else {
+                    fmt.Print(i18n.Sprintf("%s 已经标记过\n", v.ID))
+                }
+            }
+            if err := store.Save(); err != nil {
+                return err
+            }
+            if added > 0 {
+                fmt.Fprint(os.Stderr, i18n.Sprintf("共 %d 条误报标记，下次 audit 起排除\n", len(store.Entries)))
+            }
+            return nil
+        },
+    }
+
+    cmd.Flags().StringVar(&reason, "reason", "", "误报原因，导出后同事也能看到")
+    cmd.Flags().StringVar(&name, "name", "", "只在该记分卡最近一次的结果中查找 ID")
+    cmd.AddCommand(newMarkFPListCmd(), newMarkFPRemoveCmd(), newMarkFPExportCmd(), newMarkFPImportCmd())
+    return cmd
+}
+
+func newMarkFPListCmd() *cobra.Command {
+    var format string
+    cmd := &cobra.Command{
+        Use:   "list",
+        Short: "列出已标记的误报",
+        Args:  cobra.NoArgs,
+        RunE: func(cmd *cobra.Command, args []string) error {
+            if err := checkFormat(format, "text", "json"); err != nil {
+                return err
+            }
+            store, err := falsepos.Load()
+            if err != nil {
+                return err
+            }
+            if format == "json" {
+                return writeJSON(os.Stdout, store.Entries)
+            }
+            if len(store.Entries) == 0 {
+                fmt.Println(i18n.T("没有误报标记"))
+                return nil
+            }
+            for _, e := range store.Entries {
+                fmt.Printf("%s  %s  %s/%s\n", e.ID, e.Rule, e.Repo, e.Path)
+                if e.Preview != "" {
+                    fmt.Printf("    %s\n", preview.Render(e.Preview))
+                }
+                if e.Reason != "" || e.By != "" {
+                    fmt.Printf("    %s (%s, %s)\n", e.Reason, e.By, e.Marked.Local().Format("2006-01-02"))
+                }
+            }
+            return nil
+        },
+    }
+    cmd.Flags().StringVarP(&format, "format", "f", "text", "输出格式：text|json")
+    return cmd
+}
+
+func newMarkFPRemoveCmd() *cobra.Command {
+    return &cobra.Command{
+        Use:   "rm <match-id>...",
+        Short: "取消误报标记",
+        Args:  cobra.MinimumNArgs(1),
+        RunE: func(cmd *cobra.Command, args []string) error {
+            store, err := falsepos.Load()
+            if err != nil {
+                return err
+            }
+            for _, id := range args {
+                if !store.Remove(id) {
+                    return i18n.Errorf("没有 ID 为 %s 的误报标记", id)
+                }
+            }
+            return store.Save()
+        },
+    }
+}
+
+func newMarkFPExportCmd() *cobra.Command {
+    return &cobra.Command{
+        Use:   "export [file]",
+        Short: "导出误报标记（JSON），默认写到 stdout",
+        Args:  cobra.MaximumNArgs(1),
+        RunE: func(cmd *cobra.Command, args []string) error {
+            store, err := falsepos.Load()
+            if err != nil {
+                return err
+            }
+            var w io.Writer = os.Stdout
+            if len(args) == 1 && args[0] != "-" {
+                f, err := os.Create(args[0])
+                if err != nil {
+                    return err
+                }
+                defer f.Close()
+                w = f
+            }
+            return writeJSON(w, store)
+        },
+    }
+}
+
+func newMarkFPImportCmd() *cobra.Command {
+    return &cobra.Command{
+        Use:   "import <file|->",
+        Short: "合并同事导出的误报标记，已有的标记保持不变",
+        Args:  cobra.ExactArgs(1),
+        RunE: func(cmd *cobra.Command, args []string) error {
+            var r io.Reader = os.Stdin
+            if args[0] != "-" {
+                f, err := os.Open(args[0])
+                if err != nil {
+                    return err
+                }
+                defer f.Close()
+                r = f
+            }
+            var in falsepos.Store
+            if err := json.NewDecoder(r).Decode(&in); err != nil {
+                return i18n.Errorf("解析 %s 失败（需要 mark-fp export 的输出）: %w", args[0], err)
+            }
+            store, err := falsepos.Load()
+            if err != nil {
+                return err
+            }
+            n := store.Merge(in.Entries)
+            if err := store.Save(); err != nil {
+                return err
+            }
+            fmt.Print(i18n.Sprintf("导入 %d 条新标记（文件中共 %d 条）\n", n, len(in.Entries)))
+            return nil
+        },
+    }
+}
+
+// savedViolations 读取各记分卡（name 非空时只读该记分卡）最近一次保存结果中的违规
+func savedViolations(name string) ([]AuditViolation, error) {
+    names := []string{name}
+    if name == "" {
+        root, err := auditDir("")
+        if err != nil {
+            return nil, err
+        }
+        dirs, _ := filepath.Glob(filepath.Join(root, "*"))
+        names = names[:0]
+        for _, d := range dirs {
+            names = append(names, filepath.Base(d))
+        }
+    }
+    var out []AuditViolation
+    for _, n := range names {
+        r, err := loadAuditBaseline(n)
+        if err != nil {
+            return nil, err
+        }
+        if r != nil {
+            out = append(out, r.Violations...)
+        }
+    }
+    if len(out) == 0 {
+        return nil, i18n.Errorf("没有找到保存的 audit 结果，请先运行 kb audit（不加 --no-save）")
+    }
+    return out, nil
+}
+
+// lookupViolation 按 ID 或唯一前缀查找违规
+func lookupViolation(vs []AuditViolation, id string) (AuditViolation, error) {
+    var found []AuditViolation
+    for _, v := range vs {
+        if v.ID == id {
+            return v, nil
+        }
+        if strings.HasPrefix(v.ID, id) && (len(found) == 0 || found[len(found)-1].ID != v.ID) {
+            found = append(found, v)
+        }
+    }
+    switch len(found) {
+    case 0:
+        return AuditViolation{}, i18n.Errorf("最近的 audit 结果中没有 ID 为 %s 的违规", id)
+    case 1:
+        return found[0], nil
+    }
+    return AuditViolation{}, i18n.Errorf("ID 前缀 %s 对应多处违规，请写完整的 ID", id)
+}
+
+func init() { rootCmd.AddCommand(newMarkFPCmd()) }
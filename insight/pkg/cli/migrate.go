@@ -0,0 +1,198 @@
+package cli
+
+import (
+    "fmt"
+    "io"
+    "os"
+    "regexp"
+    "sort"
+    "strings"
+
+    "github.com/spf13/cobra"
+    "kingbrain/insight/pkg/i18n"
+    "kingbrain/insight/pkg/sg"
+)
+
+// repoPlan 是单个仓库的迁移工作量
+type repoPlan struct {
+    Repo      string
+    Owner     string
+    CallSites int
+    Files     []filePlan
+}
+
+type filePlan struct {
+    Path  string
+    URL   string
+    Lines []int
+}
+
+// effort 按调用点数量粗估工作量
+func effort(sites int) string {
+    switch {
+    case sites <= 5:
+        return "S"
+    case sites <= 25:
+        return "M"
+    case sites <= 100:
+        return "L"
+    }
+    return "XL"
+}
+
+// repoOwner 取仓库名中的组织部分：github.com/acme/api → acme
+func repoOwner(repo string) string {
+    parts := strings.Split(repo, "/")
+    if len(parts) >= 3 {
+        return parts[len(parts)-2]
+    }
+    return repo
+}
+
+// wordRegexp 把 s 转成按整词匹配的正则：只在首尾是单词字符的一侧加 \b，
+// 否则 foo( 或 .Close 这样以标点开头、结尾的 API 在标点外侧没有单词边界，永远匹配不到
+func wordRegexp(s string) string {
+    re := regexp.QuoteMeta(s)
+    if s == "" {
+        return re
+    }
+    if isWordByte(s[0]) {
+        re = `\b` + re
+    }
+    if isWordByte(s[len(s)-1]) {
+        re += `\b`
+    }
+    return re
+}
+
+// isWordByte 与 RE2 的 \b 一致，只把 ASCII 字母、数字和下划线算作单词字符
+func isWordByte(b byte) bool {
+    return b == '_' || '0' <= b && b <= '9' || 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z'
+}
+
+func newMigratePlanCmd() *cobra.Command {
+    var (
+        repos  []string
+        output string
+        limit  int
+        issue  issueOptions
+    )
+
+    cmd := &cobra.Command{
+        Use:   "migrate-plan <old-api> <new-api>",
+        Short: "查找旧 API 的全部调用点，按 组织/仓库 聚类并估算工作量，生成迁移计划文档",
+        Args:  cobra.ExactArgs(2),
+        RunE: func(cmd *cobra.Command, args []string) error {
+            oldAPI, newAPI := args[0], args[1]
+            c := sg.New()
+            q := buildQuery(wordRegexp(oldAPI), repoFilter(repos), countFilter(limit))
+            res, err := c.Search(cmd.Context(), q, "regexp")
+            if err != nil {
+                return err
+            }
+            plans := buildRepoPlans(c, res)
+
+            w := io.Writer(os.Stdout)
+            if output != "" {
+                f, err := os.Create(output)
+                if err != nil {
+                    return err
+                }
+                defer f.Close()
+                w = f
+            }
+            if err := writeMigrationPlan(w, oldAPI, newAPI, plans, res.MatchCount >= limit && limit > 0); err != nil {
+                return err
+            }
+            if output != "" {
+                fmt.Fprint(os.Stderr, i18n.Sprintf("迁移计划已写入 %s\n", output))
+            }
+
+            var findings []Finding
+            // 来源带上新旧 API：同一仓库的不同迁移各自成一个 issue，同一迁移重复运行时按标题去重
+            issue.source = fmt.Sprintf("migrate %s → %s", oldAPI, newAPI)
+            for _, fm := range res.Results {
+                for _, lm := range fm.LineMatches {
+                    findings = append(findings, Finding{Repo: fm.Repository.Name, Path: fm.File.Path, Line: lm.LineNumber + 1,
+                        Text: strings.TrimSpace(lm.Preview), URL: fmt.Sprintf("%s?L%d", c.URL(fm.File.URL), lm.LineNumber+1)})
+                }
+            }
+            return createIssues(issue, findings)
+        },
+    }
+
+    cmd.Flags().StringSliceVar(&repos, "repo", nil, "限定仓库（可重复，支持正则；含 * ? 的 glob 按仓库缓存展开）")
+    cmd.Flags().StringVarP(&output, "output", "o", "", "把计划写入文件（默认 stdout）")
+    cmd.Flags().IntVar(&limit, "limit", 5000, "最多统计的调用点数（count:）")
+    addIssueFlags(cmd, &issue)
+    return cmd
+}
+
+// buildRepoPlans 按仓库汇总调用点，按 组织、工作量（调用点数）排序
+func buildRepoPlans(c *sg.Client, res *sg.SearchResults) []repoPlan {
+    idx := map[string]int{}
+    var plans []repoPlan
+    for _, fm := range res.Results {
+        i, ok := idx[fm.Repository.Name]
+        if !ok {
+            i = len(plans)
+            idx[fm.Repository.Name] = i
+            plans = append(plans, repoPlan{Repo: fm.Repository.Name, Owner: repoOwner(fm.Repository.Name)})
+        }
+        fp := filePlan{Path: fm.File.Path, URL: c.URL(fm.File.URL)}
+        for _, lm := range fm.LineMatches {
+            fp.Lines = append(fp.Lines, lm.LineNumber+1)
+        }
+        plans[i].Files = append(plans[i].Files, fp)
+        plans[i].CallSites += len(fp.Lines)
+    }
+    sort.Slice(plans, func(i, j int) bool {
+        if plans[i].Owner != plans[j].Owner {
+            return plans[i].Owner < plans[j].Owner
+        }
+        return plans[i].CallSites > plans[j].CallSites
+    })
+    return plans
+}
+
+func writeMigrationPlan(w io.Writer, oldAPI, newAPI string, plans []repoPlan, truncated bool) error {
+    var b strings.Builder
+    total, files := 0, 0
+    owners := map[string][3]int{} // 仓库数、文件数、调用点数
+    var ownerNames []string
+    for _, p := range plans {
+        total += p.CallSites
+        files += len(p.Files)
+        o, ok := owners[p.Owner]
+        if !ok {
+            ownerNames = append(ownerNames, p.Owner)
+        }
+        owners[p.Owner] = [3]int{o[0] + 1, o[1] + len(p.Files), o[2] + p.CallSites}
+    }
+
+    fmt.Fprint(&b, i18n.Sprintf("# 迁移计划：`%s` → `%s`\n\n", oldAPI, newAPI))
+    fmt.Fprint(&b, i18n.Sprintf("共 %d 个仓库、%d 个文件、%d 处调用点。\n\n", len(plans), files, total))
+    if truncated {
+        b.WriteString(i18n.T("> 结果达到 --limit 上限，实际调用点可能更多。\n\n"))
+    }
+    b.WriteString(i18n.T("## 按组织汇总\n\n| 组织 | 仓库 | 文件 | 调用点 | 工作量 |\n|---|---:|---:|---:|:---:|\n"))
+    for _, o := range ownerNames {
+        s := owners[o]
+        fmt.Fprintf(&b, "| %s | %d | %d | %d | %s |\n", o, s[0], s[1], s[2], effort(s[2]))
+    }
+    b.WriteString(i18n.T("\n## 按仓库明细\n"))
+    for _, p := range plans {
+        fmt.Fprint(&b, i18n.Sprintf("\n### %s（%d 处，工作量 %s）\n\n", p.Repo, p.CallSites, effort(p.CallSites)))
+        for _, f := range p.Files {
+            lines := make([]string, len(f.Lines))
+            for i, l := range f.Lines {
+                lines[i] = fmt.Sprintf("[L%d](%s?L%d)", l, f.URL, l)
+            }
+            fmt.Fprint(&b, i18n.Sprintf("- [ ] `%s`：%s\n", f.Path, strings.Join(lines, " ")))
+        }
+    }
+    _, err := io.WriteString(w, b.String())
+    return err
+}
+
+func init() { rootCmd.AddCommand(newMigratePlanCmd()) }
@@ -0,0 +1,337 @@
+package cli
+
+import (
+    "context"
+    "fmt"
+    "os"
+    "sort"
+    "strings"
+
+    "github.com/spf13/cobra"
+    "kingbrain/insight/pkg/graph"
+    "kingbrain/insight/pkg/i18n"
+    "kingbrain/insight/pkg/manifest"
+    "kingbrain/insight/pkg/progress"
+    "kingbrain/insight/pkg/sg"
+)
+
+// goModFilter 只取真正的模块定义，跳过 vendor 与 testdata 中的 go.mod
+const goModFilter = `file:(^|/)go\.mod$ -file:(^|/)(vendor|testdata)/`
+
+// ModuleInfo 是实例中一份 go.mod 定义的 Go 模块及其 require
+type ModuleInfo struct {
+    Module   string       `json:"module"`
+    Repo     string       `json:"repo"`
+    Path     string       `json:"path"`
+    URL      string       `json:"url"`
+    Requires []ModRequire `json:"requires"`
+}
+
+// ModRequire 是一条 require；Internal 表示被依赖的模块也定义在实例中
+type ModRequire struct {
+    Module   string `json:"module"`
+    Version  string `json:"version"`
+    Indirect bool   `json:"indirect,omitempty"`
+    Line     int    `json:"line,omitempty"`
+    Internal bool   `json:"internal,omitempty"`
+}
+
+// ModRequirer 是 --requires 查询的一条结果：Module（定义于 Repo/Path）在 Line 行 require 了目标模块的 Version
+type ModRequirer struct {
+    Module   string `json:"module"`
+    Repo     string `json:"repo"`
+    Path     string `json:"path"`
+    Line     int    `json:"line"`
+    Version  string `json:"version"`
+    Indirect bool   `json:"indirect,omitempty"`
+    URL      string `json:"url"`
+}
+
+func newModgraphCmd() *cobra.Command {
+    var (
+        repos    []string
+        format   string
+        maxFiles int
+        requires string
+        version  string
+        direct   bool
+        external bool
+        cycles   bool
+    )
+
+    cmd := &cobra.Command{
+        Use:   "modgraph",
+        Short: "汇总实例中的 go.mod，构建仓库间的 Go 模块依赖图，查询谁依赖某模块（的某些版本）并检测依赖环",
+        Long: `搜索并拉取各仓库的 go.mod（跳过 vendor/ 与 testdata/），以 module 路径为节点、require 为边构建依赖图。
+默认只画实例内定义的模块之间的依赖，--external 时也画出外部模块；replace 指令不参与计算。
+
+不带 --requires 时列出每个模块依赖的实例内模块，并报告依赖环（同一模块在多个 go.mod 中定义时取第一个）；
+--cycles 只输出依赖环，发现环时以退出码 1 结束，便于在 CI 中检查。
+--requires 列出 require 了该模块的所有模块（含 // indirect，--direct 时不含），
+--version 进一步按版本条件过滤，如 '<v1.5'、'>=v1.2,<v2'。例如：
+
+  kb modgraph --repo '^github.com/acme/' -f dot | dot -Tsvg > modules.svg
+  kb modgraph --requires github.com/acme/log --version '<v1.5'
+  kb modgraph --cycles`,
+        Args: cobra.NoArgs,
+        RunE: func(cmd *cobra.Command, _ []string) error {
+            if err := checkFormat(format, "text", "json", "dot", "mermaid"); err != nil {
+                return err
+            }
+            if version != "" && requires == "" {
+                return i18n.Errorf("--version 需要与 --requires 一起使用")
+            }
+            if cycles && requires != "" {
+                return i18n.Errorf("--cycles 与 --requires 不能同时使用")
+            }
+            constraint, err := manifest.ParseConstraint(version)
+            if err != nil {
+                return i18n.Errorf("--version %q 无效: %w", version, err)
+            }
+
+            c := sg.New()
+            files, mods, err := fetchGoModules(cmd.Context(), c, repos, maxFiles)
+            if err != nil {
+                return err
+            }
+            if len(mods) == 0 {
+                fmt.Fprint(os.Stderr, i18n.Sprintf("没有找到 go.mod\n"))
+                return nil
+            }
+            if direct {
+                for _, m := range mods {
+                    kept := m.Requires[:0]
+                    for _, r := range m.Requires {
+                        if !r.Indirect {
+                            kept = append(kept, r)
+                        }
+                    }
+                    m.Requires = kept
+                }
+            }
+
+            if requires != "" {
+                found := modRequirers(mods, requires, constraint)
+                return printModRequirers(format, requires, constraint, found)
+            }
+            g := modGraph(mods, external)
+            loops := g.Cycles()
+            if cycles {
+                if err := printModCycles(format, loops); err != nil {
+                    return err
+                }
+                if len(loops) > 0 {
+                    return exitWith(cmd, exitFalse, "")
+                }
+                return nil
+            }
+            return printModGraph(format, files, mods, g, loops)
+        },
+    }
+
+    cmd.Flags().StringSliceVar(&repos, "repo", nil, "限定仓库（可重复，支持正则；含 * ? 的 glob 按仓库缓存展开）")
+    cmd.Flags().StringVarP(&format, "format", "f", "text", "输出格式：text|json|dot|mermaid")
+    cmd.Flags().IntVar(&maxFiles, "max-files", 2000, "最多拉取的 go.mod 数")
+    cmd.Flags().StringVar(&requires, "requires", "", "列出 require 了该模块的模块")
+    cmd.Flags().StringVar(&version, "version", "", "与 --requires 一起使用，只列出版本满足条件的 require，如 '<v1.5'")
+    cmd.Flags().BoolVar(&direct, "direct", false, "忽略 // indirect 的 require")
+    cmd.Flags().BoolVar(&external, "external", false, "依赖图中也画出实例外的模块")
+    cmd.Flags().BoolVar(&cycles, "cycles", false, "只输出依赖环，发现环时以退出码 1 结束")
+    return cmd
+}
+
+// fetchGoModules 找出并拉取 go.mod，返回解析出的模块（按 module 路径去重并排序）与 go.mod 的份数
+func fetchGoModules(ctx context.Context, c *sg.Client, repos []string, maxFiles int) (int, []*ModuleInfo, error) {
+    res, err := c.Search(ctx, buildQuery(goModFilter, repoFilter(repos), "type:path", countFilter(maxFiles)), "literal")
+    if err != nil {
+        return 0, nil, err
+    }
+    files := res.Results
+    if len(files) > maxFiles {
+        files = files[:maxFiles]
+    }
+    specs := make([]sg.FileSpec, len(files))
+    for i, fm := range files {
+        specs[i] = sg.FileSpec{Repo: fm.Repository.Name, Path: fm.File.Path}
+    }
+    bar := progress.New(len(files))
+    fetched := c.GetFilesFunc(ctx, specs, func(r sg.FileResult) { bar.End(r.Repo+"/"+r.Path, r.Err) })
+    bar.Finish()
+
+    var all []*ModuleInfo
+    for i, fm := range files {
+        if fetched[i].Err != nil {
+            continue
+        }
+        name := manifest.GoModule(fetched[i].Content)
+        if name == "" {
+            continue
+        }
+        m := &ModuleInfo{Module: name, Repo: fm.Repository.Name, Path: fm.File.Path, URL: c.URL(fm.File.URL), Requires: []ModRequire{}}
+        for _, d := range manifest.Parse(fm.File.Path, fetched[i].Content) {
+            m.Requires = append(m.Requires, ModRequire{Module: d.Name, Version: d.Version, Indirect: d.Indirect, Line: d.Line})
+        }
+        all = append(all, m)
+    }
+    sort.SliceStable(all, func(i, j int) bool {
+        if all[i].Module != all[j].Module {
+            return all[i].Module < all[j].Module
+        }
+        return all[i].Repo+"/"+all[i].Path < all[j].Repo+"/"+all[j].Path
+    })
+
+    // 同一模块出现在多处（fork、副本）时取第一个，其余只提示
+    var mods []*ModuleInfo
+    defined := map[string]*ModuleInfo{}
+    for _, m := range all {
+        if first, ok := defined[m.Module]; ok {
+            fmt.Fprint(os.Stderr, i18n.Sprintf("警告: 模块 %s 同时定义于 %s/%s 与 %s/%s，使用前者\n", m.Module, first.Repo, first.Path, m.Repo, m.Path))
+            continue
+        }
+        defined[m.Module] = m
+        mods = append(mods, m)
+    }
+    for _, m := range mods {
+        for i := range m.Requires {
+            _, m.Requires[i].Internal = defined[m.Requires[i].Module]
+        }
+    }
+    return len(files), mods, nil
+}
+
+// modRequirers 找出 require 了 module 且版本满足 constraint 的模块，按版本从低到高排序
+func modRequirers(mods []*ModuleInfo, module string, constraint manifest.Constraint) []ModRequirer {
+    out := []ModRequirer{}
+    for _, m := range mods {
+        for _, r := range m.Requires {
+            if r.Module != module || !constraint.Match(r.Version) {
+                continue
+            }
+            url := m.URL
+            if r.Line > 0 {
+                url = fmt.Sprintf("%s?L%d", m.URL, r.Line)
+            }
+            out = append(out, ModRequirer{Module: m.Module, Repo: m.Repo, Path: m.Path, Line: r.Line, Version: r.Version, Indirect: r.Indirect, URL: url})
+        }
+    }
+    sort.SliceStable(out, func(i, j int) bool {
+        if c := manifest.Compare(out[i].Version, out[j].Version); c != 0 {
+            return c < 0
+        }
+        return out[i].Module < out[j].Module
+    })
+    return out
+}
+
+// modGraph 以模块为节点、require 为边（标注版本）构建依赖图；external 为 false 时只保留实例内的模块
+func modGraph(mods []*ModuleInfo, external bool) *graph.Graph {
+    g := graph.New("modgraph")
+    for _, m := range mods {
+        g.AddNode(graph.Node{ID: m.Module})
+    }
+    for _, m := range mods {
+        for _, r := range m.Requires {
+            switch {
+            case r.Internal:
+            case external:
+                g.AddNode(graph.Node{ID: r.Module, Color: "#eeeeee"})
+            default:
+                continue
+            }
+            g.AddEdge(m.Module, r.Module, r.Version)
+        }
+    }
+    return g
+}
+
+func printModRequirers(format, module string, constraint manifest.Constraint, found []ModRequirer) error {
+    switch format {
+    case "json":
+        return writeJSON(os.Stdout, found)
+    case "dot", "mermaid":
+        g := graph.New("requires " + module)
+        g.AddNode(graph.Node{ID: module, Color: "#d1ecf1"})
+        for _, r := range found {
+            g.AddEdge(r.Module, module, r.Version)
+        }
+        return g.Write(os.Stdout, format)
+    }
+    target := module
+    if len(constraint) > 0 {
+        target += " " + constraint.String()
+    }
+    if len(found) == 0 {
+        fmt.Fprint(os.Stderr, i18n.Sprintf("没有模块依赖 %s\n", target))
+        return nil
+    }
+    fmt.Print(i18n.Sprintf("%d 处 require 了 %s\n\n", len(found), target))
+    for _, r := range found {
+        line := fmt.Sprintf("%-14s %-40s %s/%s:%d", r.Version, r.Module, r.Repo, r.Path, r.Line)
+        if r.Indirect {
+            line += "  // indirect"
+        }
+        fmt.Println(line)
+    }
+    return nil
+}
+
+func printModCycles(format string, loops [][]string) error {
+    if format == "json" {
+        if loops == nil {
+            loops = [][]string{}
+        }
+        return writeJSON(os.Stdout, loops)
+    }
+    if len(loops) == 0 {
+        fmt.Print(i18n.Sprintf("没有依赖环\n"))
+        return nil
+    }
+    fmt.Print(i18n.Sprintf("发现 %d 个依赖环：\n", len(loops)))
+    for _, l := range loops {
+        fmt.Println("  " + strings.Join(l, " → "))
+    }
+    return nil
+}
+
+func printModGraph(format string, files int, mods []*ModuleInfo, g *graph.Graph, loops [][]string) error {
+    switch format {
+    case "json":
+        if loops == nil {
+            loops = [][]string{}
+        }
+        return writeJSON(os.Stdout, struct {
+            Modules []*ModuleInfo `json:"modules"`
+            Cycles  [][]string    `json:"cycles"`
+        }{mods, loops})
+    case "dot", "mermaid":
+        // 环上的模块着色
+        for _, l := range loops {
+            for _, id := range l {
+                g.AddNode(graph.Node{ID: id, Color: "#f8d7da"})
+            }
+        }
+        return g.Write(os.Stdout, format)
+    }
+
+    edges := 0
+    for _, m := range mods {
+        for _, r := range m.Requires {
+            if r.Internal {
+                edges++
+            }
+        }
+    }
+    fmt.Print(i18n.Sprintf("%d 份 go.mod，%d 个模块，实例内依赖 %d 条\n\n", files, len(mods), edges))
+    for _, m := range mods {
+        fmt.Printf("%s  %s/%s\n", m.Module, m.Repo, m.Path)
+        for _, e := range g.Edges {
+            if e.From == m.Module {
+                fmt.Printf("  → %s %s\n", e.To, e.Label)
+            }
+        }
+    }
+    fmt.Println()
+    return printModCycles(format, loops)
+}
+
+func init() { rootCmd.AddCommand(newModgraphCmd()) }
@@ -0,0 +1,134 @@
+package cli
+
+import (
+    "fmt"
+    "os"
+    "path/filepath"
+    "regexp"
+    "strings"
+
+    "github.com/spf13/cobra"
+    "kingbrain/insight/pkg/i18n"
+    "kingbrain/insight/pkg/sg"
+)
+
+// path:12 或 path:12-20
+var lineSuffixRe = regexp.MustCompile(`^(.*?):(\d+(?:-\d+)?)$`)
+
+func newOpenCmd() *cobra.Command {
+    var (
+        rev     string
+        copyURL bool
+        browse  bool
+    )
+
+    cmd := &cobra.Command{
+        Use:   "open <repo> [path] [line] | open <local-file>[:line]",
+        Short: "生成 Sourcegraph 链接：打印、复制到剪贴板或在浏览器中打开",
+        Long: `生成 Sourcegraph 上的文件/行链接。
+
+  kb open github.com/acme/api internal/server.go 42
+  kb open ./internal/server.go:42-50     # 本地文件，按 git remote 推断仓库`,
+        Args: cobra.RangeArgs(1, 3),
+        RunE: func(_ *cobra.Command, args []string) error {
+            link, err := deepLink(sg.New(), args, rev)
+            if err != nil {
+                return err
+            }
+            fmt.Println(link)
+            if copyURL {
+                if err := copyToClipboard(link); err != nil {
+                    return err
+                }
+                fmt.Fprintln(os.Stderr, i18n.T("已复制到剪贴板"))
+            }
+            if browse {
+                return openBrowser(link)
+            }
+            return nil
+        },
+    }
+
+    cmd.Flags().StringVar(&rev, "rev", "", "分支、标签或 commit（默认为仓库默认分支）")
+    cmd.Flags().BoolVarP(&copyURL, "copy", "c", false, "复制到剪贴板")
+    cmd.Flags().BoolVarP(&browse, "browser", "b", false, "在浏览器中打开")
+    return cmd
+}
+
+// deepLink 把参数解析为 仓库/路径/行 并拼出链接
+func deepLink(c *sg.Client, args []string, rev string) (string, error) {
+    var repo, path, lines string
+    if len(args) == 1 {
+        local, l := args[0], ""
+        if m := lineSuffixRe.FindStringSubmatch(local); m != nil {
+            local, l = m[1], m[2]
+        }
+        if _, err := os.Stat(local); err == nil {
+            var err error
+            if repo, path, err = localToRemote(local); err != nil {
+                return "", err
+            }
+            lines = l
+        } else {
+            repo = args[0]
+        }
+    } else {
+        repo, path = args[0], args[1]
+        if len(args) == 3 {
+            lines = args[2]
+        }
+    }
+    return blobURL(c, repo, rev, path, lines), nil
+}
+
+// localToRemote 把本地路径映射为 (Sourcegraph 仓库名, 仓库内路径)
+func localToRemote(local string) (string, string, error) {
+    abs, err := filepath.Abs(local)
+    if err != nil {
+        return "", "", err
+    }
+    root, err := gitRoot(abs)
+    if err != nil {
+        return "", "", err
+    }
+    repo, err := localRepoName(root)
+    if err != nil {
+        return "", "", err
+    }
+    // 解析符号链接，避免 /tmp 与 /private/tmp 这类差异导致 Rel 失败
+    if r, err := filepath.EvalSymlinks(root); err == nil {
+        root = r
+    }
+    if a, err := filepath.EvalSymlinks(abs); err == nil {
+        abs = a
+    }
+    rel, err := filepath.Rel(root, abs)
+    if err != nil {
+        return "", "", err
+    }
+    if rel == "." {
+        rel = ""
+    }
+    return repo, filepath.ToSlash(rel), nil
+}
+
+// blobURL 拼接 <base>/<repo>[@rev]/-/blob/<path>?L<lines>；path 为空时指向仓库首页
+func blobURL(c *sg.Client, repo, rev, path, lines string) string {
+    u := "/" + strings.Trim(repo, "/")
+    if rev != "" {
+        u += "@" + rev
+    }
+    if path != "" {
+        kind := "blob"
+        if lines == "" && strings.HasSuffix(path, "/") {
+            kind = "tree"
+        }
+        u += "/-/" + kind + "/" + strings.Trim(path, "/")
+    }
+    if lines != "" {
+        u += "?L" + lines
+    }
+    return c.URL(u)
+}
+
+func init() { rootCmd.AddCommand(newOpenCmd()) }
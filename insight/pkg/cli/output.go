@@ -0,0 +1,43 @@
+package cli
+
+import (
+    "encoding/csv"
+    "encoding/json"
+    "io"
+
+    "kingbrain/insight/pkg/i18n"
+)
+
+// checkFormat 校验 --format 取值
+func checkFormat(format string, allowed ...string) error {
+    for _, a := range allowed {
+        if format == a {
+            return nil
+        }
+    }
+    return i18n.Errorf("不支持的输出格式 %q（可选：%v）", format, allowed)
+}
+
+// writeJSON 以缩进 JSON 输出任意值
+func writeJSON(w io.Writer, v any) error {
+    enc := json.NewEncoder(w)
+    enc.SetIndent("", "  ")
+    return enc.Encode(v)
+}
+
+// writeJSONLine 输出单行 JSON（JSON Lines）
+func writeJSONLine(w io.Writer, v any) error {
+    return json.NewEncoder(w).Encode(v)
+}
+
+// writeCSV 输出带表头的 CSV
+func writeCSV(w io.Writer, header []string, rows [][]string) error {
+    cw := csv.NewWriter(w)
+    if err := cw.Write(header); err != nil {
+        return err
+    }
+    if err := cw.WriteAll(rows); err != nil {
+        return err
+    }
+    return cw.Error()
+}
@@ -0,0 +1,120 @@
+package cli
+
+import (
+    "bufio"
+    "bytes"
+    "io"
+    "os"
+    "os/exec"
+    "strings"
+
+    "golang.org/x/term"
+    "kingbrain/insight/pkg/preview"
+    "kingbrain/insight/pkg/progress"
+)
+
+var (
+    noPager bool
+    // stdoutTTY 记录换成分页管道之前 stdout 是否连着终端
+    stdoutTTY = progress.IsTerminal(os.Stdout)
+    // activePager 是当前命令的分页器，没有分页时为 nil
+    activePager *pager
+)
+
+// 这些命令是交互式或长时间运行的，不经过分页器
+var pagerSkip = map[string]bool{
+    "completion": true, "__complete": true, "__completeNoDesc": true, "self-update": true, "init": true,
+}
+
+func init() {
+    rootCmd.PersistentFlags().BoolVar(&noPager, "no-pager", false, "不把超过一屏的输出交给 $PAGER")
+}
+
+// pager 截获 stdout：输出不足一屏时原样写回终端，超过一屏才启动分页程序，
+// 与 git 的 less -F 效果相同，但不依赖分页程序本身支持 -F
+type pager struct {
+    stdout *os.File // 原来的 stdout
+    w      *os.File // 管道写端，命令运行期间替换为 os.Stdout
+    done   chan struct{}
+}
+
+// pagerCommand 返回分页程序：INSIGHT_PAGER、PAGER 依次优先，都没有时在 PATH 中找 less；
+// 设为空串或 cat 表示不分页
+func pagerCommand() []string {
+    for _, env := range []string{"INSIGHT_PAGER", "PAGER"} {
+        if v, ok := os.LookupEnv(env); ok {
+            if v = strings.TrimSpace(v); v == "" || v == "cat" {
+                return nil
+            }
+            return splitCommand(v)
+        }
+    }
+    if p, err := exec.LookPath("less"); err == nil {
+        // -R 保留颜色，-X 退出后不清屏；-F 由 pager 自己实现
+        return []string{p, "-RX"}
+    }
+    return nil
+}
+
+// startPager 在命令运行前安装分页管道；stdout 不是终端、--no-pager 或没有分页程序时什么都不做
+func startPager(name string) {
+    if noPager || !stdoutTTY || pagerSkip[name] {
+        return
+    }
+    argv := pagerCommand()
+    if argv == nil {
+        return
+    }
+    width, height, err := term.GetSize(int(os.Stdout.Fd()))
+    if err != nil || height <= 1 {
+        return
+    }
+    r, w, err := os.Pipe()
+    if err != nil {
+        return
+    }
+    p := &pager{stdout: os.Stdout, w: w, done: make(chan struct{})}
+    os.Stdout = w
+    activePager = p
+    go p.run(r, argv, width, height)
+}
+
+func (p *pager) run(r *os.File, argv []string, width, height int) {
+    defer close(p.done)
+    defer r.Close()
+    br := bufio.NewReader(r)
+    var buf bytes.Buffer
+    // 按终端宽度折行估算占用的行数，留一行给 shell 提示符
+    for rows := 0; rows < height-1; {
+        line, err := br.ReadString('\n')
+        buf.WriteString(line)
+        rows += max(1, (preview.Width(preview.Render(strings.TrimRight(line, "\n")))+width-1)/max(width, 1))
+        if err != nil {
+            p.stdout.Write(buf.Bytes())
+            return
+        }
+    }
+    cmd := exec.Command(argv[0], argv[1:]...)
+    cmd.Stdin = io.MultiReader(&buf, br)
+    cmd.Stdout, cmd.Stderr = p.stdout, os.Stderr
+    if err := cmd.Run(); err != nil && cmd.ProcessState == nil {
+        // 分页程序启动失败：直接输出
+        p.stdout.Write(buf.Bytes())
+        io.Copy(p.stdout, br)
+        return
+    }
+    // 用户提前退出分页程序时丢弃剩余输出，避免命令阻塞在写 stdout 上
+    io.Copy(io.Discard, br)
+}
+
+// stopPager 关闭管道并等待分页程序退出，恢复原来的 stdout
+func stopPager() {
+    p := activePager
+    if p == nil {
+        return
+    }
+    activePager = nil
+    os.Stdout = p.stdout
+    p.w.Close()
+    <-p.done
+}
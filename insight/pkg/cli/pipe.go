@@ -0,0 +1,228 @@
+package cli
+
+import (
+    "context"
+    "fmt"
+    "io"
+    "os"
+    "sort"
+    "strings"
+
+    "kingbrain/insight/pkg/generated"
+    "kingbrain/insight/pkg/i18n"
+    "kingbrain/insight/pkg/preview"
+    "kingbrain/insight/pkg/sg"
+    "kingbrain/insight/pkg/snippet"
+)
+
+// readQueryArg 返回位置参数中的查询；"-" 表示从 stdin 读取（多行合并为一行，便于 heredoc 书写长查询）
+func readQueryArg(arg string) (string, error) {
+    if arg != "-" {
+        return arg, nil
+    }
+    b, err := io.ReadAll(os.Stdin)
+    if err != nil {
+        return "", err
+    }
+    q := strings.Join(strings.Fields(string(b)), " ")
+    if q == "" {
+        return "", i18n.Errorf("stdin 中没有查询")
+    }
+    return q, nil
+}
+
+// matchPrinter 按输出格式打印搜索结果：text 为人读的分组输出（stdout 为终端时的默认）；
+// lines 每个匹配一行 repo/path:line:preview，类似 grep（stdout 为管道时的默认）；
+// json 每个文件一行 JSON；paths 为去重后的 repo/path，配合 -0 以 NUL 分隔供 xargs -0 使用。
+// hide 为 true（find、run-template 的 --hide-generated）时，噪音匹配只是不打印，仍计入 matched
+type matchPrinter struct {
+    format    string
+    nul       bool
+    enclosing bool
+    seen      map[string]bool
+    matched   int // 搜索返回的匹配数（没有行匹配的文件记为 1 个，含隐藏的），用于决定退出码
+    hide      bool
+    hidden    generated.Counts
+}
+
+// newMatchPrinter 解析输出格式；enclosing 为 true 时 text 模式改为打印每个匹配所在的整个函数
+func newMatchPrinter(format string, nul, enclosing bool) (*matchPrinter, error) {
+    if nul {
+        if format != "" && format != "paths" {
+            return nil, i18n.Errorf("-0 只能与 --format paths 一起使用")
+        }
+        format = "paths"
+    }
+    if enclosing && format != "" && format != "text" {
+        return nil, i18n.Errorf("--enclosing-function 只支持 text 输出")
+    }
+    if format == "" {
+        format = "text"
+        if !enclosing && !stdoutTTY {
+            format = "lines"
+        }
+    }
+    if err := checkFormat(format, "text", "lines", "json", "paths"); err != nil {
+        return nil, err
+    }
+    return &matchPrinter{format: format, nul: nul, enclosing: enclosing, seen: map[string]bool{}, hidden: generated.Counts{}}, nil
+}
+
+// text 表示是否输出总数、分隔标题等只给人看的内容
+func (p *matchPrinter) text() bool { return p.format == "text" }
+
+// print 输出一组结果；instance/rev 非空时写进 json 记录，lines/paths 中作为前缀。
+// c 只在 --enclosing-function 时用来拉取文件内容
+func (p *matchPrinter) print(ctx context.Context, c *sg.Client, instance, rev string, res *sg.SearchResults) error {
+    for _, fm := range res.Results {
+        p.matched += max(len(fm.LineMatches), 1)
+    }
+    if p.hide {
+        shown := &sg.SearchResults{MatchCount: res.MatchCount, LimitHit: res.LimitHit, Truncated: res.Truncated}
+        for _, fm := range res.Results {
+            if fm, ok := dropGenerated(fm, p.hidden); ok {
+                shown.Results = append(shown.Results, fm)
+            }
+        }
+        res = shown
+    }
+    if p.text() && p.enclosing {
+        printEnclosing(ctx, c, instance, rev, res)
+        return nil
+    }
+    if p.text() {
+        printFileMatches(instance, res)
+        return nil
+    }
+    prefix := ""
+    if instance != "" {
+        prefix = "[" + instance + "] "
+    }
+    for _, fm := range res.Results {
+        name := fm.Repository.Name + "/" + fm.File.Path
+        if rev != "" {
+            name = fm.Repository.Name + "@" + rev + "/" + fm.File.Path
+        }
+        switch p.format {
+        case "json":
+            rec := struct {
+                Instance string `json:"instance,omitempty"`
+                Rev      string `json:"rev,omitempty"`
+                sg.FileMatch
+            }{instance, rev, fm}
+            if err := writeJSONLine(os.Stdout, rec); err != nil {
+                return err
+            }
+        case "paths":
+            if p.seen[prefix+name] {
+                continue
+            }
+            p.seen[prefix+name] = true
+            sep := "\n"
+            if p.nul {
+                sep = "\x00"
+            }
+            if _, err := fmt.Print(prefix + name + sep); err != nil {
+                return err
+            }
+        default:
+            for _, m := range fm.LineMatches {
+                if _, err := fmt.Printf("%s%s:%d:%s\n", prefix, name, m.LineNumber+1, m.Preview); err != nil {
+                    return err
+                }
+            }
+        }
+    }
+    return nil
+}
+
+// done 在全部结果打印完后调用，在 stderr 说明隐藏了多少噪音匹配
+func (p *matchPrinter) done() { printHidden(p.hidden) }
+
+// printEnclosing 批量拉取命中文件，按所在函数分组打印；同一函数里的多处匹配只打印一次，
+// 匹配行用 > 标出。不支持的语言或找不到函数时退回单行预览
+func printEnclosing(ctx context.Context, c *sg.Client, instance, rev string, res *sg.SearchResults) {
+    var specs []sg.FileSpec
+    for _, fm := range res.Results {
+        if snippet.Supported(fm.File.Path) {
+            specs = append(specs, sg.FileSpec{Repo: fm.Repository.Name, Rev: rev, Path: fm.File.Path})
+        }
+    }
+    fetched := map[string]sg.FileResult{}
+    for _, r := range c.GetFiles(ctx, specs) {
+        fetched[r.Repo+"\x00"+r.Path] = r
+    }
+    for _, fm := range res.Results {
+        if instance != "" {
+            fmt.Printf("File: [%s] %s/%s%s\n", instance, fm.Repository.Name, fm.File.Path, indexSuffix(fm.Index))
+        } else {
+            fmt.Printf("File: %s%s\n", fm.File.Path, indexSuffix(fm.Index))
+        }
+        var content string
+        var lines []string
+        if r, ok := fetched[fm.Repository.Name+"\x00"+fm.File.Path]; ok {
+            if r.Err != nil {
+                fmt.Fprint(os.Stderr, i18n.Sprintf("警告: 拉取 %s/%s 失败，只显示预览: %v\n", fm.Repository.Name, fm.File.Path, r.Err))
+            } else {
+                content = r.Content
+                lines = strings.Split(content, "\n")
+            }
+        }
+        hit := map[int]bool{}
+        for _, m := range fm.LineMatches {
+            hit[m.LineNumber+1] = true
+        }
+        shown := map[snippet.Func]bool{}
+        for _, m := range fm.LineMatches {
+            line := m.LineNumber + 1
+            f, ok := snippet.Func{}, false
+            if lines != nil {
+                f, ok = snippet.Enclosing(fm.File.Path, content, line)
+            }
+            if !ok {
+                fmt.Printf("   %5d | %s%s\n", line, renderMatch(m), annotationSuffix(m.Annotations))
+                continue
+            }
+            if shown[f] {
+                continue
+            }
+            shown[f] = true
+            fmt.Printf("  ── %s (L%d-%d)\n", f.Name, f.Start, f.End)
+            for i := f.Start; i <= f.End && i <= len(lines); i++ {
+                mark := " "
+                if hit[i] {
+                    mark = ">"
+                }
+                fmt.Printf("  %s%5d | %s\n", mark, i, preview.Render(lines[i-1]))
+            }
+        }
+        fmt.Println()
+    }
+}
+
+// annotationSuffix 把 --hook 添加的注解写成行尾的 "  [k=v ...]"，按键排序；没有注解时为空
+func annotationSuffix(a map[string]string) string {
+    if len(a) == 0 {
+        return ""
+    }
+    keys := make([]string, 0, len(a))
+    for k := range a {
+        keys = append(keys, k)
+    }
+    sort.Strings(keys)
+    for i, k := range keys {
+        keys[i] = k + "=" + a[k]
+    }
+    return "  [" + strings.Join(keys, " ") + "]"
+}
+
+// colorMatches 表示 text 输出是否给匹配区间上色：stdout 为终端且未设置 NO_COLOR
+var colorMatches = stdoutTTY && os.Getenv("NO_COLOR") == ""
+
+// renderMatch 渲染一行预览，终端中按 offsetAndLengths 把匹配区间标红加粗
+func renderMatch(m sg.LineMatch) string {
+    if !colorMatches || len(m.OffsetAndLengths) == 0 {
+        return preview.Render(m.Preview)
+    }
+    return preview.Highlight(m.Preview, m.OffsetAndLengths, "\x1b[1;31m", "\x1b[0m")
+}
@@ -0,0 +1,76 @@
+package cli
+
+import (
+    "fmt"
+    "strings"
+)
+
+// repoFilter 把多个 --repo 合并成一个 repo: 过滤器（多个 repo: 在 Sourcegraph 中是 AND 关系）
+func repoFilter(repos []string) string {
+    switch len(repos) {
+    case 0:
+        return ""
+    case 1:
+        return "repo:" + repos[0]
+    }
+    return "repo:(?:" + strings.Join(repos, "|") + ")"
+}
+
+// buildQuery 拼接查询主体与过滤器，忽略空片段
+func buildQuery(parts ...string) string {
+    var out []string
+    for _, p := range parts {
+        if p = strings.TrimSpace(p); p != "" {
+            out = append(out, p)
+        }
+    }
+    return strings.Join(out, " ")
+}
+
+// countFilter 生成 count: 过滤器，limit<=0 时不限制
+func countFilter(limit int) string {
+    if limit <= 0 {
+        return ""
+    }
+    return fmt.Sprintf("count:%d", limit)
+}
+
+// boolQuery 把 --all-of / --any-of 组合成 Sourcegraph 的 AND/OR 表达式：
+// base 与每个 all-of 关键词是 AND 关系，any-of 整体加括号后作为一个 AND 项
+// （不加括号时后面拼上的 repo: 等过滤器只会绑定到最后一个 OR 分支）
+func boolQuery(base string, allOf, anyOf []string) string {
+    var terms []string
+    if base = strings.TrimSpace(base); base != "" {
+        if len(allOf)+len(anyOf) > 0 && strings.ContainsAny(base, " \t") {
+            base = "(" + base + ")"
+        }
+        terms = append(terms, base)
+    }
+    for _, k := range allOf {
+        terms = append(terms, quoteTerm(k))
+    }
+    if len(anyOf) > 0 {
+        alts := make([]string, len(anyOf))
+        for i, k := range anyOf {
+            alts[i] = quoteTerm(k)
+        }
+        or := strings.Join(alts, " OR ")
+        if len(alts) > 1 {
+            or = "(" + or + ")"
+        }
+        terms = append(terms, or)
+    }
+    return strings.Join(terms, " AND ")
+}
+
+// quoteTerm 给含空白、括号、引号或与运算符同名的关键词加引号，避免被当作查询语法
+func quoteTerm(k string) string {
+    switch strings.ToUpper(k) {
+    case "AND", "OR", "NOT":
+        return fmt.Sprintf("%q", k)
+    }
+    if strings.ContainsAny(k, " \t()\"") {
+        return fmt.Sprintf("%q", k)
+    }
+    return k
+}
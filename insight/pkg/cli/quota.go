@@ -0,0 +1,130 @@
+package cli
+
+import (
+    "fmt"
+    "math"
+    "os"
+    "strings"
+    "time"
+
+    "github.com/spf13/cobra"
+    "kingbrain/insight/pkg/config"
+    "kingbrain/insight/pkg/i18n"
+    "kingbrain/insight/pkg/quota"
+    "kingbrain/insight/pkg/sg"
+)
+
+var (
+    rateLimit   int
+    sharedQuota bool
+)
+
+// applyQuota 打开共享的配额 keyring，并按配置与 --rate-limit 设置各实例的限速
+func applyQuota() error {
+    if !sharedQuota {
+        return nil
+    }
+    cfg, err := config.Load()
+    if err != nil {
+        return err
+    }
+    limits := map[string]quota.Limit{}
+    for _, q := range cfg.Quotas {
+        l := quota.Limit{PerMinute: q.RatePerMinute, Burst: q.Burst}
+        if q.URL == "" {
+            sg.DefaultRateLimit = l
+            continue
+        }
+        limits[strings.TrimSuffix(q.URL, "/")] = l
+    }
+    if rateLimit > 0 {
+        limits = nil
+        sg.DefaultRateLimit = quota.Limit{PerMinute: rateLimit}
+    }
+    k, err := quota.Default()
+    if err != nil {
+        return err
+    }
+    sg.Keyring, sg.RateLimits = k, limits
+    return nil
+}
+
+func newQuotaCmd() *cobra.Command {
+    var format string
+    cmd := &cobra.Command{
+        Use:   "quota",
+        Short: "查看各实例的共享配额：剩余令牌、限速、限流暂停与累计请求数",
+        Long: `每个请求发出前都从 <用户缓存目录>/insight/quota/ 下按实例记录的令牌桶中取令牌（以文件锁保护），
+同一台机器上同时运行的所有 kb 进程（如多个团队脚本）共同遵守实例的限速，而不是各自限速；
+任何一个进程收到 429 时记下 Retry-After，其他进程也随之暂停。
+
+限速在配置文件中设置，URL 为空的一项适用于其他实例；--rate-limit 临时覆盖全部实例：
+
+  quotas:
+    - {url: https://sourcegraph.acme.dev, rate_per_minute: 300, burst: 30}
+    - {rate_per_minute: 60}
+
+  kb quota
+  kb quota reset https://sourcegraph.acme.dev
+  kb batch queries.txt --rate-limit 30`,
+        Args: cobra.NoArgs,
+        RunE: func(cmd *cobra.Command, _ []string) error {
+            if err := checkFormat(format, "text", "json"); err != nil {
+                return err
+            }
+            k, err := quota.Default()
+            if err != nil {
+                return err
+            }
+            states, err := k.List()
+            if err != nil {
+                return err
+            }
+            if format == "json" {
+                return writeJSON(os.Stdout, states)
+            }
+            if len(states) == 0 {
+                fmt.Fprint(os.Stderr, i18n.Sprintf("还没有记录任何实例的配额\n"))
+                return nil
+            }
+            now := time.Now()
+            for _, s := range states {
+                fmt.Println(s.Endpoint)
+                if s.Limit.PerMinute > 0 {
+                    fmt.Print(i18n.Sprintf("  限速: %d/分钟，剩余令牌 %d\n", s.Limit.PerMinute, int(math.Floor(s.Tokens))))
+                } else {
+                    fmt.Print(i18n.Sprintf("  限速: 不限\n"))
+                }
+                if s.PausedUntil.After(now) {
+                    fmt.Print(i18n.Sprintf("  限流暂停中，还剩 %s\n", s.PausedUntil.Sub(now).Round(time.Second)))
+                }
+                fmt.Print(i18n.Sprintf("  累计请求 %d，收到 429 %d 次，最近 %s\n", s.Requests, s.Throttled, s.Updated.Local().Format("2006-01-02 15:04:05")))
+            }
+            return nil
+        },
+    }
+    cmd.Flags().StringVarP(&format, "format", "f", "text", "输出格式：text|json")
+    cmd.AddCommand(&cobra.Command{
+        Use:   "reset [url]",
+        Short: "清除实例（不给时为全部实例）的配额记录与限流暂停",
+        Args:  cobra.MaximumNArgs(1),
+        RunE: func(cmd *cobra.Command, args []string) error {
+            k, err := quota.Default()
+            if err != nil {
+                return err
+            }
+            endpoint := ""
+            if len(args) == 1 {
+                endpoint = strings.TrimSuffix(args[0], "/")
+            }
+            return k.Reset(endpoint)
+        },
+    })
+    return cmd
+}
+
+func init() {
+    rootCmd.PersistentFlags().IntVar(&rateLimit, "rate-limit", 0, "每个实例每分钟最多的请求数，与同时运行的其他 kb 进程共享（覆盖配置中的 quotas）")
+    rootCmd.PersistentFlags().BoolVar(&sharedQuota, "shared-quota", true, "与同时运行的其他 kb 进程共享配额与限流暂停（见 kb quota）")
+    rootCmd.AddCommand(newQuotaCmd())
+}
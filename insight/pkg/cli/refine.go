@@ -0,0 +1,258 @@
+package cli
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "os"
+    "regexp"
+    "strings"
+    "unicode/utf8"
+
+    "github.com/spf13/cobra"
+    "kingbrain/insight/pkg/i18n"
+    "kingbrain/insight/pkg/sg"
+)
+
+// refineRecord 是 find -f json 输出的一行
+type refineRecord struct {
+    Instance string `json:"instance,omitempty"`
+    Rev      string `json:"rev,omitempty"`
+    sg.FileMatch
+}
+
+func newRefineCmd() *cobra.Command {
+    var (
+        paths        []string
+        excludePaths []string
+        matches      []string
+        excludes     []string
+        ctxMatch     string
+        ctxLines     int
+        format       string
+    )
+
+    cmd := &cobra.Command{
+        Use:   "refine [results-file|-]",
+        Short: "在已有的搜索结果（find -f json 的输出或保存的结果文件）上做本地过滤，不重新查询服务端",
+        Long: `输入为 find -f json 的 JSON Lines（不给参数或为 "-" 时读 stdin），也可以是包含 results 的
+搜索结果对象（如 serve 的 /api/search 响应）。过滤条件之间为 AND：
+  --path / --exclude-path   对 repo/path 做正则匹配（--path 可重复，满足任一即可）
+  --match / --exclude       对匹配行的预览做正则匹配（可重复，--match 须全部满足）
+  --context-match           拉取文件，匹配行上下 -C 行内须出现该正则
+--match 过滤后的行按新正则重新计算高亮区间。输出格式与 find 相同，可以继续管道给下一个 refine。
+
+  kb find -p regexp 'http\.Get\(' -f json > calls.jsonl
+  kb refine calls.jsonl --exclude-path '_test\.go$' --context-match 'defer .*Body\.Close' -C 5
+  kb find -f json TODO | kb refine --match 'FIXME|XXX' -f json | kb refine --path '^github\.com/acme/'`,
+        Args: cobra.MaximumNArgs(1),
+        RunE: func(cmd *cobra.Command, args []string) error {
+            out, err := newMatchPrinter(format, false, false)
+            if err != nil {
+                return err
+            }
+            pathRes, err := compileAll(paths)
+            if err != nil {
+                return err
+            }
+            exPathRes, err := compileAll(excludePaths)
+            if err != nil {
+                return err
+            }
+            matchRes, err := compileAll(matches)
+            if err != nil {
+                return err
+            }
+            exRes, err := compileAll(excludes)
+            if err != nil {
+                return err
+            }
+            var ctxRe *regexp.Regexp
+            if ctxMatch != "" {
+                if ctxRe, err = regexp.Compile(ctxMatch); err != nil {
+                    return err
+                }
+            }
+
+            var in io.Reader = os.Stdin
+            if len(args) == 1 && args[0] != "-" {
+                f, err := os.Open(args[0])
+                if err != nil {
+                    return err
+                }
+                defer f.Close()
+                in = f
+            }
+            recs, err := readResults(in)
+            if err != nil {
+                return err
+            }
+
+            var kept []refineRecord
+            for _, r := range recs {
+                name := r.Repository.Name + "/" + r.File.Path
+                if (len(pathRes) > 0 && !anyMatch(pathRes, name)) || anyMatch(exPathRes, name) {
+                    continue
+                }
+                lms := r.LineMatches[:0]
+                for _, m := range r.LineMatches {
+                    if !allMatch(matchRes, m.Preview) || anyMatch(exRes, m.Preview) {
+                        continue
+                    }
+                    if len(matchRes) > 0 {
+                        m.OffsetAndLengths = runeRanges(m.Preview, matchRes)
+                    }
+                    lms = append(lms, m)
+                }
+                if len(lms) == 0 && (len(r.LineMatches) > 0 || len(matchRes) > 0) {
+                    continue
+                }
+                r.LineMatches = lms
+                kept = append(kept, r)
+            }
+
+            c := sg.New()
+            if ctxRe != nil {
+                kept = refineByContext(cmd.Context(), c, kept, ctxRe, ctxLines)
+            }
+
+            if out.text() {
+                n := 0
+                for _, r := range kept {
+                    n += len(r.LineMatches)
+                }
+                fmt.Print(i18n.Sprintf("Total matches: %d（原 %d 个文件，保留 %d 个）\n\n", n, len(recs), len(kept)))
+            }
+            for _, r := range kept {
+                if err := out.print(cmd.Context(), c, r.Instance, r.Rev, &sg.SearchResults{Results: []sg.FileMatch{r.FileMatch}}); err != nil {
+                    return err
+                }
+            }
+            return nil
+        },
+    }
+
+    cmd.Flags().StringArrayVar(&paths, "path", nil, "只保留 repo/path 匹配该正则的文件（可重复，满足任一即可）")
+    cmd.Flags().StringArrayVar(&excludePaths, "exclude-path", nil, "去掉 repo/path 匹配该正则的文件（可重复）")
+    cmd.Flags().StringArrayVar(&matches, "match", nil, "只保留预览匹配该正则的行（可重复，须全部满足）")
+    cmd.Flags().StringArrayVar(&excludes, "exclude", nil, "去掉预览匹配该正则的行（可重复）")
+    cmd.Flags().StringVar(&ctxMatch, "context-match", "", "拉取文件内容，只保留上下文中出现该正则的匹配")
+    cmd.Flags().IntVarP(&ctxLines, "context", "C", 3, "--context-match 检查的上下文行数")
+    cmd.Flags().StringVarP(&format, "format", "f", "", "输出格式：text|lines|json|paths（默认终端为 text，管道为 lines）")
+    return cmd
+}
+
+// readResults 读取 JSON Lines 的文件匹配记录，或带 results 字段的搜索结果对象（可以多个连在一起）
+func readResults(r io.Reader) ([]refineRecord, error) {
+    dec := json.NewDecoder(r)
+    var out []refineRecord
+    for {
+        var raw json.RawMessage
+        err := dec.Decode(&raw)
+        if errors.Is(err, io.EOF) {
+            break
+        }
+        if err != nil {
+            return nil, i18n.Errorf("解析输入失败（需要 find -f json 的输出）: %w", err)
+        }
+        var probe struct {
+            Results []sg.FileMatch `json:"results"`
+        }
+        if json.Unmarshal(raw, &probe) == nil && probe.Results != nil {
+            for _, fm := range probe.Results {
+                out = append(out, refineRecord{FileMatch: fm})
+            }
+            continue
+        }
+        var rec refineRecord
+        if err := json.Unmarshal(raw, &rec); err != nil {
+            return nil, err
+        }
+        if rec.File.Path == "" {
+            return nil, i18n.Errorf("输入中的记录缺少 file.path，需要 find -f json 的输出")
+        }
+        out = append(out, rec)
+    }
+    if len(out) == 0 {
+        return nil, i18n.Errorf("输入中没有搜索结果")
+    }
+    return out, nil
+}
+
+// refineByContext 拉取文件，只保留上下 n 行内出现 re 的匹配行
+func refineByContext(ctx context.Context, c *sg.Client, recs []refineRecord, re *regexp.Regexp, n int) []refineRecord {
+    specs := make([]sg.FileSpec, 0, len(recs))
+    for _, r := range recs {
+        specs = append(specs, sg.FileSpec{Repo: r.Repository.Name, Rev: r.Rev, Path: r.File.Path})
+    }
+    files := c.GetFiles(ctx, specs)
+    var out []refineRecord
+    for i, r := range recs {
+        if f := files[i]; f.Err != nil {
+            fmt.Fprint(os.Stderr, i18n.Sprintf("警告: 拉取 %s/%s 失败，跳过: %v\n", f.Repo, f.Path, f.Err))
+            continue
+        }
+        content := files[i].Content
+        lines := strings.Split(content, "\n")
+        lms := r.LineMatches[:0]
+        for _, m := range r.LineMatches {
+            lo, hi := max(m.LineNumber-n, 0), min(m.LineNumber+n+1, len(lines))
+            if lo < hi && re.MatchString(strings.Join(lines[lo:hi], "\n")) {
+                lms = append(lms, m)
+            }
+        }
+        if len(lms) > 0 {
+            r.LineMatches = lms
+            out = append(out, r)
+        }
+    }
+    return out
+}
+
+func compileAll(exprs []string) ([]*regexp.Regexp, error) {
+    out := make([]*regexp.Regexp, 0, len(exprs))
+    for _, e := range exprs {
+        re, err := regexp.Compile(e)
+        if err != nil {
+            return nil, err
+        }
+        out = append(out, re)
+    }
+    return out, nil
+}
+
+func anyMatch(res []*regexp.Regexp, s string) bool {
+    for _, re := range res {
+        if re.MatchString(s) {
+            return true
+        }
+    }
+    return false
+}
+
+func allMatch(res []*regexp.Regexp, s string) bool {
+    for _, re := range res {
+        if !re.MatchString(s) {
+            return false
+        }
+    }
+    return true
+}
+
+// runeRanges 返回各正则在 s 中的匹配区间，换算成 offsetAndLengths 使用的 rune 偏移
+func runeRanges(s string, res []*regexp.Regexp) [][2]int {
+    var out [][2]int
+    for _, re := range res {
+        for _, loc := range re.FindAllStringIndex(s, -1) {
+            if loc[1] > loc[0] {
+                start := utf8.RuneCountInString(s[:loc[0]])
+                out = append(out, [2]int{start, utf8.RuneCountInString(s[loc[0]:loc[1]])})
+            }
+        }
+    }
+    return out
+}
+
+func init() { rootCmd.AddCommand(newRefineCmd()) }
@@ -0,0 +1,272 @@
+package cli
+
+import (
+    "context"
+    "fmt"
+    "os"
+    "path"
+    "regexp"
+    "strings"
+
+    "github.com/spf13/cobra"
+    "kingbrain/insight/pkg/i18n"
+    "kingbrain/insight/pkg/repocache"
+    "kingbrain/insight/pkg/sg"
+)
+
+func newReposCmd() *cobra.Command {
+    var (
+        refresh  bool
+        quiet    bool
+        offline  bool
+        langs    []string
+        archived bool
+        format   string
+    )
+
+    cmd := &cobra.Command{
+        Use:   "repos [glob]",
+        Short: "列出仓库（名称、语言、默认分支），数据来自本地缓存，过期时后台刷新",
+        Long: `仓库元数据缓存在 <用户缓存目录>/insight/repos.json，供本命令、--repo 补全与
+--repo 通配展开使用。缓存超过 24 小时或切换了实例时会在后台刷新；--refresh 立即刷新。
+
+  kb repos 'github.com/acme/payments-*' --lang go
+  kb repos --refresh`,
+        Args: cobra.MaximumNArgs(1),
+        RunE: func(cmd *cobra.Command, args []string) error {
+            if err := checkFormat(format, "text", "json"); err != nil {
+                return err
+            }
+            c := sg.New()
+            var cache *repocache.Cache
+            var err error
+            if refresh {
+                cache, err = repocache.Refresh(cmd.Context(), c, func(n int) {
+                    if !quiet {
+                        fmt.Fprint(os.Stderr, i18n.Sprintf("\r已获取 %d 个仓库", n))
+                    }
+                })
+                if !quiet {
+                    fmt.Fprintln(os.Stderr)
+                }
+            } else {
+                cache, err = cachedRepos(cmd.Context(), c, offline)
+            }
+            if err != nil {
+                return err
+            }
+            if quiet {
+                return nil
+            }
+
+            var out []sg.RepoInfo
+            for _, r := range cache.Repos {
+                if r.Archived && !archived {
+                    continue
+                }
+                if len(args) > 0 && !matchRepo(args[0], r.Name) {
+                    continue
+                }
+                if len(langs) > 0 && !containsFold(langs, r.Language) {
+                    continue
+                }
+                out = append(out, r)
+            }
+            if format == "json" {
+                return writeJSON(os.Stdout, out)
+            }
+            for _, r := range out {
+                fmt.Printf("%-60s %-12s %s\n", r.Name, r.Language, r.DefaultBranch)
+            }
+            return nil
+        },
+    }
+
+    cmd.Flags().BoolVar(&refresh, "refresh", false, "立即从实例分页拉取并更新缓存")
+    cmd.Flags().BoolVar(&quiet, "quiet", false, "不输出（配合 --refresh 用于后台刷新）")
+    cmd.Flags().BoolVar(&offline, "offline", false, "只用本地缓存，不联网")
+    cmd.Flags().StringSliceVar(&langs, "lang", nil, "只列出主语言为这些的仓库（可重复）")
+    cmd.Flags().BoolVar(&archived, "archived", false, "包含已归档的仓库")
+    cmd.Flags().StringVarP(&format, "format", "f", "text", "输出格式：text|json")
+    return cmd
+}
+
+// cachedRepos 返回仓库缓存：缓存为空时同步拉取，过期时先用旧数据并在后台刷新；offline 时从不联网
+func cachedRepos(ctx context.Context, c *sg.Client, offline bool) (*repocache.Cache, error) {
+    cache, err := repocache.Load()
+    if err != nil {
+        return nil, err
+    }
+    switch {
+    case offline:
+        if len(cache.Repos) == 0 {
+            return nil, i18n.Errorf("仓库缓存为空，请先联网运行 kb repos --refresh")
+        }
+    case len(cache.Repos) == 0:
+        fmt.Fprintln(os.Stderr, i18n.T("首次使用，正在拉取仓库列表..."))
+        return repocache.Refresh(ctx, c, nil)
+    case cache.Stale(c.URL("")):
+        if err := repocache.RefreshInBackground(); err != nil {
+            fmt.Fprint(os.Stderr, i18n.Sprintf("警告: 后台刷新仓库缓存失败: %v\n", err))
+        }
+    }
+    return cache, nil
+}
+
+// reposMatching 按仓库缓存列出名字匹配任一 --repo 正则的仓库（与 repo: 过滤器一样不区分大小写，
+// 不含已归档的仓库与 fork），供需要逐个仓库处理、不能只靠搜索结果找仓库的命令使用
+func reposMatching(ctx context.Context, c *sg.Client, patterns []string) ([]string, error) {
+    res := make([]*regexp.Regexp, 0, len(patterns))
+    for _, p := range patterns {
+        re, err := regexp.Compile("(?i)" + p)
+        if err != nil {
+            return nil, fmt.Errorf("--repo %s: %w", p, err)
+        }
+        res = append(res, re)
+    }
+    cache, err := cachedRepos(ctx, c, false)
+    if err != nil {
+        return nil, err
+    }
+    var out []string
+    for _, r := range cache.Repos {
+        if r.Archived || r.Fork {
+            continue
+        }
+        for _, re := range res {
+            if re.MatchString(r.Name) {
+                out = append(out, r.Name)
+                break
+            }
+        }
+    }
+    return out, nil
+}
+
+// matchRepo 支持 glob（* ? [...]）；不含通配符时按子串匹配
+func matchRepo(pattern, name string) bool {
+    if !strings.ContainsAny(pattern, "*?[") {
+        return strings.Contains(name, pattern)
+    }
+    ok, _ := path.Match(pattern, name)
+    return ok
+}
+
+func containsFold(list []string, s string) bool {
+    for _, v := range list {
+        if strings.EqualFold(v, s) {
+            return true
+        }
+    }
+    return false
+}
+
+// registerRepoCompletion 为所有带 --repo 的命令注册基于缓存的补全；补全时绝不同步联网，
+// 缓存过期只触发后台刷新
+func registerRepoCompletion(root *cobra.Command) {
+    complete := func(_ *cobra.Command, _ []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+        cache, err := repocache.Load()
+        if err != nil {
+            return nil, cobra.ShellCompDirectiveNoFileComp
+        }
+        if cache.Stale(sg.New().URL("")) {
+            _ = repocache.RefreshInBackground()
+        }
+        var names []string
+        for _, r := range cache.Repos {
+            if strings.HasPrefix(r.Name, toComplete) {
+                names = append(names, r.Name)
+            }
+        }
+        return names, cobra.ShellCompDirectiveNoFileComp
+    }
+    var walk func(c *cobra.Command)
+    walk = func(c *cobra.Command) {
+        if c.Flags().Lookup("repo") != nil {
+            _ = c.RegisterFlagCompletionFunc("repo", complete)
+        }
+        for _, sub := range c.Commands() {
+            walk(sub)
+        }
+    }
+    walk(root)
+}
+
+func init() { rootCmd.AddCommand(newReposCmd()) }
+
+// 通配展开出的仓库数超过该值时提示（查询仍会执行，但 repo: 正则会很长）
+const repoGlobWarn = 200
+
+// isRepoGlob 区分 --repo 的 glob 与正则：含 * 或 ?，且没有正则专用的元字符
+func isRepoGlob(p string) bool {
+    if !strings.ContainsAny(p, "*?") || strings.ContainsAny(p, `^$()|+\{}`) {
+        return false
+    }
+    return !strings.Contains(p, ".*") && !strings.Contains(p, ".+") && !strings.Contains(p, ".?")
+}
+
+// expandRepoGlobs 在命令运行前把 --repo 中的 glob 按仓库缓存展开为精确匹配的正则
+func expandRepoGlobs(cmd *cobra.Command) error {
+    f := cmd.Flags().Lookup("repo")
+    if f == nil || !f.Changed {
+        return nil
+    }
+    sv, ok := f.Value.(interface {
+        GetSlice() []string
+        Replace([]string) error
+    })
+    if !ok {
+        return nil
+    }
+    vals := sv.GetSlice()
+    changed := false
+    var cache *repocache.Cache
+    for i, v := range vals {
+        if !isRepoGlob(v) {
+            continue
+        }
+        if cache == nil {
+            var err error
+            if cache, err = cachedRepos(cmd.Context(), sg.New(), false); err != nil {
+                return i18n.Errorf("展开 --repo %s: %w", v, err)
+            }
+        }
+        var names []string
+        for _, r := range cache.Repos {
+            if ok, _ := path.Match(v, r.Name); ok {
+                names = append(names, r.Name)
+            }
+        }
+        if len(names) == 0 {
+            return i18n.Errorf("--repo %s 没有匹配任何仓库（缓存更新于 %s，可运行 kb repos --refresh）", v, cache.Updated.Format("2006-01-02 15:04"))
+        }
+        if len(names) > repoGlobWarn {
+            fmt.Fprint(os.Stderr, i18n.Sprintf("警告: --repo %s 展开为 %d 个仓库，查询可能较慢；考虑缩小范围\n", v, len(names)))
+        }
+        vals[i] = reposRegex(names)
+        changed = true
+    }
+    if !changed {
+        return nil
+    }
+    return sv.Replace(vals)
+}
+
+// reposRegex 为一组仓库名生成锚定的正则，提取公共前缀以缩短查询：
+// [a/x-api a/x-web] → ^a/x\-(?:api|web)$
+func reposRegex(names []string) string {
+    if len(names) == 1 {
+        return "^" + regexp.QuoteMeta(names[0]) + "$"
+    }
+    prefix := names[0]
+    for _, n := range names[1:] {
+        for !strings.HasPrefix(n, prefix) {
+            prefix = prefix[:len(prefix)-1]
+        }
+    }
+    alts := make([]string, 0, len(names))
+    for _, n := range names {
+        alts = append(alts, regexp.QuoteMeta(n[len(prefix):]))
+    }
+    return "^" + regexp.QuoteMeta(prefix) + "(?:" + strings.Join(alts, "|") + ")$"
+}
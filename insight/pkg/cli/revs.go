@@ -0,0 +1,99 @@
+package cli
+
+import (
+    "context"
+    "fmt"
+    "os"
+
+    "kingbrain/insight/pkg/export"
+    "kingbrain/insight/pkg/i18n"
+    "kingbrain/insight/pkg/sg"
+)
+
+// revResult 是某个 revision 上的搜索结果
+type revResult struct {
+    Rev     string
+    Results *sg.SearchResults
+    Err     error
+}
+
+// expandRevs 合并 --rev 与 --all-branches 展开出的分支，保持顺序去重
+func expandRevs(ctx context.Context, c *sg.Client, revs, repos []string, allBranches bool, limit int) ([]string, error) {
+    if allBranches && len(repos) == 0 {
+        return nil, i18n.Errorf("--all-branches 需要用 --repo 指定仓库全名")
+    }
+    seen := map[string]bool{}
+    var out []string
+    add := func(r string) {
+        if !seen[r] {
+            seen[r] = true
+            out = append(out, r)
+        }
+    }
+    for _, r := range revs {
+        add(r)
+    }
+    if allBranches {
+        for _, repo := range repos {
+            branches, err := c.Branches(ctx, repo, limit)
+            if err != nil {
+                return nil, err
+            }
+            for _, b := range branches {
+                add(b)
+            }
+        }
+    }
+    return out, nil
+}
+
+// searchRevs 在每个 revision 上各搜一次；分支在个别仓库中不存在时 Sourcegraph 只返回告警，不算失败
+func searchRevs(ctx context.Context, c *sg.Client, base, pattern string, revs []string) []revResult {
+    out := make([]revResult, 0, len(revs))
+    for _, rev := range revs {
+        res, err := c.Search(ctx, buildQuery(base, "rev:"+rev), pattern)
+        out = append(out, revResult{Rev: rev, Results: res, Err: err})
+    }
+    return out
+}
+
+// findRevs 是 find 的多 revision 模式：逐个分支打印结果，最后给出按分支的汇总
+func findRevs(ctx context.Context, c *sg.Client, out *matchPrinter, run *export.Run, base, pattern string, revs []string, exports []string) error {
+    results := searchRevs(ctx, c, base, pattern, revs)
+    failed := 0
+    for _, r := range results {
+        if r.Err != nil {
+            failed++
+            fmt.Fprintf(os.Stderr, "[%s] %v\n", r.Rev, r.Err)
+            continue
+        }
+        if out.text() {
+            fmt.Printf("=== rev %s: %d matches ===\n\n", r.Rev, r.Results.MatchCount)
+        }
+        if err := out.print(ctx, c, "", r.Rev, r.Results); err != nil {
+            return err
+        }
+        for _, m := range exportMatches(c, r.Results) {
+            m.Rev = r.Rev
+            run.Matches = append(run.Matches, m)
+        }
+    }
+
+    if out.text() {
+        fmt.Println("Matches per revision:")
+        for _, r := range results {
+            if r.Err != nil {
+                fmt.Printf("  %-30s error\n", r.Rev)
+                continue
+            }
+            fmt.Printf("  %-30s %d matches in %d files\n", r.Rev, r.Results.MatchCount, len(r.Results.Results))
+        }
+    }
+    if err := runExports(exports, run); err != nil {
+        return err
+    }
+    if failed == len(results) {
+        return i18n.Errorf("所有 revision 均查询失败")
+    }
+    return nil
+}
@@ -1,5 +1,37 @@
 package cli
-import "github.com/spf13/cobra"
-func Execute() { _ = rootCmd.Execute() }
-var rootCmd = &cobra.Command{Use: "kb"}
-func init() { rootCmd.AddCommand(newFindCmd()) }
+import ("context";"fmt";"os";"time";"github.com/spf13/cobra";"go.opentelemetry.io/otel/trace";"kingbrain/insight/pkg/sg";"kingbrain/insight/pkg/tracing")
+
+// Execute 在一个根 span 下运行命令；span 名在解析出子命令后改成完整命令路径。
+// 命令出错时以 2 退出，命令通过 exitWith 指定的退出码原样使用
+func Execute() {
+    ctx := context.Background()
+    shutdown, err := tracing.Init(ctx)
+    if err != nil { fmt.Fprintln(os.Stderr, "tracing:", err) }
+    ctx, span := tracing.Start(ctx, "kb")
+    started := time.Now()
+    registerRepoCompletion(rootCmd)
+    cmd, err := rootCmd.ExecuteContextC(ctx)
+    stopPager()
+    code, err := exitStatus(err)
+    tracing.End(span, err)
+    recordTelemetry(cmd, started, err)
+    if shutdown != nil { _ = shutdown(context.Background()) }
+    if code != exitOK { os.Exit(code) }
+}
+var rootCmd = &cobra.Command{Use: "kb",
+    PersistentPreRunE: func(cmd *cobra.Command, _ []string) error {
+        trace.SpanFromContext(cmd.Context()).SetName(cmd.CommandPath())
+        if err := applyLang(cmd.Root()); err != nil { return err }
+        if err := applyEndpoint(); err != nil { return err }
+        if err := applyScope(); err != nil { return err }
+        if err := applyHook(); err != nil { return err }
+        if err := applyQuota(); err != nil { return err }
+        if err := applySample(cmd); err != nil { return err }
+        if err := expandRepoGlobs(cmd); err != nil { return err }
+        startPager(cmd.Name())
+        return nil
+    }}
+func init() {
+    rootCmd.AddCommand(newFindCmd())
+    rootCmd.PersistentFlags().IntVar(&sg.DefaultMaxResults, "max-results", sg.DefaultMaxResults, "单次搜索最多保留的匹配数，未写 count: 时自动注入（0 为不限制）")
+}
@@ -0,0 +1,36 @@
+package cli
+
+import (
+    "math/rand/v2"
+
+    "github.com/spf13/cobra"
+    "kingbrain/insight/pkg/sg"
+)
+
+var (
+    sampleFlag string
+    sampleSeed uint64
+)
+
+// applySample 按 --sample/--seed 设置新 Client 的搜索抽样；没有给 --seed 时随机取一个，
+// 抽样结束时会打印出来供复现
+func applySample(cmd *cobra.Command) error {
+    if sampleFlag == "" {
+        return nil
+    }
+    s, err := sg.ParseSample(sampleFlag)
+    if err != nil {
+        return err
+    }
+    s.Seed = sampleSeed
+    if !cmd.Flags().Changed("seed") {
+        s.Seed = rand.Uint64N(1 << 32)
+    }
+    sg.DefaultSample = s
+    return nil
+}
+
+func init() {
+    rootCmd.PersistentFlags().StringVar(&sampleFlag, "sample", "", "只保留搜索结果的随机样本：N 个匹配或 P% 的匹配（取回全部结果边解码边抽样，不受 --max-results 限制）")
+    rootCmd.PersistentFlags().Uint64Var(&sampleSeed, "seed", 0, "--sample 的随机种子，相同的种子与结果得到相同的样本")
+}
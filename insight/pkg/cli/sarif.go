@@ -0,0 +1,74 @@
+package cli
+
+import (
+    "io"
+)
+
+// sarifRule 是 SARIF 报告中的一条规则定义
+type sarifRule struct {
+    ID      string
+    Summary string
+    HelpURI string
+}
+
+// sarifResult 是一条带位置的 SARIF 结果；Level 取 error|warning|note
+type sarifResult struct {
+    Rule    string
+    Level   string
+    Message string
+    Repo    string
+    Path    string
+    Line    int
+}
+
+// writeSARIF 输出 SARIF 2.1.0，URI 写成 <repo>/<path>，便于上传到代码扫描平台
+func writeSARIF(w io.Writer, rules []sarifRule, results []sarifResult) error {
+    type text struct {
+        Text string `json:"text"`
+    }
+    type rule struct {
+        ID               string `json:"id"`
+        ShortDescription text   `json:"shortDescription"`
+        HelpURI          string `json:"helpUri,omitempty"`
+    }
+    type location struct {
+        PhysicalLocation struct {
+            ArtifactLocation struct {
+                URI string `json:"uri"`
+            } `json:"artifactLocation"`
+            Region *struct {
+                StartLine int `json:"startLine"`
+            } `json:"region,omitempty"`
+        } `json:"physicalLocation"`
+    }
+    type result struct {
+        RuleID    string     `json:"ruleId"`
+        Level     string     `json:"level"`
+        Message   text       `json:"message"`
+        Locations []location `json:"locations"`
+    }
+
+    rs := make([]rule, 0, len(rules))
+    for _, r := range rules {
+        rs = append(rs, rule{ID: r.ID, ShortDescription: text{r.Summary}, HelpURI: r.HelpURI})
+    }
+    res := make([]result, 0, len(results))
+    for _, r := range results {
+        var loc location
+        loc.PhysicalLocation.ArtifactLocation.URI = r.Repo + "/" + r.Path
+        if r.Line > 0 {
+            loc.PhysicalLocation.Region = &struct {
+                StartLine int `json:"startLine"`
+            }{r.Line}
+        }
+        res = append(res, result{RuleID: r.Rule, Level: r.Level, Message: text{r.Message}, Locations: []location{loc}})
+    }
+    return writeJSON(w, map[string]any{
+        "$schema": "https://json.schemastore.org/sarif-2.1.0.json",
+        "version": "2.1.0",
+        "runs": []any{map[string]any{
+            "tool":    map[string]any{"driver": map[string]any{"name": "insight", "rules": rs}},
+            "results": res,
+        }},
+    })
+}
@@ -1,14 +1,96 @@
 package cli
-import ("os/exec";"log";"github.com/spf13/cobra")
+import ("encoding/json";"os";"os/exec";"log";"path/filepath";"strings";"github.com/spf13/cobra";"kingbrain/insight/pkg/config";"kingbrain/insight/pkg/export";"kingbrain/insight/pkg/i18n")
+
+// sccLanguage 是 scc --format json 输出中的一项（按语言汇总）
+type sccLanguage struct {
+    Name       string
+    Count      int
+    Lines      int
+    Code       int
+    Comment    int
+    Blank      int
+    Complexity int
+    Bytes      int
+}
+
 func newSccCmd() *cobra.Command {
+    var exports []string
+    var record bool
+    var trendDB string
     cmd := &cobra.Command{Use: "scc",
         RunE: func(_ *cobra.Command, args []string) error {
-            target := "."
-            if len(args) > 0 { target = args[0] }
-            out, err := exec.Command("scc", "--ci", target).CombinedOutput()
+            targets := []string{"."}
+            if len(args) > 0 { targets = args[:1] } else if activeScope != nil {
+                // --scope：统计范围内各仓库目录在本地检出中的对应位置
+                cfg, err := config.Load()
+                if err != nil { return err }
+                if targets, err = scopeDirs(cfg.Workspace); err != nil { return err }
+            }
+            bin, err := sccBinary()
+            if err != nil { return err }
+            out, err := exec.Command(bin, append([]string{"--ci"}, targets...)...).CombinedOutput()
             if err != nil { return err }
-            log.Print("\n" + string(out)); return nil
+            log.Print("\n" + string(out))
+            if record {
+                if err := recordScc(trendDB, targets); err != nil { return err }
+            }
+            if len(exports) == 0 { return nil }
+
+            langs, err := sccJSON(targets...)
+            if err != nil { return err }
+            run := newRun("scc", strings.Join(targets, " "))
+            run.Metrics = sccMetrics("", langs)
+            return runExports(exports, run)
         }}
+    addExportFlag(cmd, &exports)
+    cmd.Flags().BoolVar(&record, "record", false, "把统计按 仓库/目录/revision 记入趋势库，供 kb scc trend 查看")
+    cmd.Flags().StringVar(&trendDB, "trend-db", "", "趋势库路径（默认 <用户缓存目录>/insight/scc-trend.db）")
+    cmd.AddCommand(newSccTrendCmd())
     return cmd
 }
+
+// sccBinary 定位 scc：SCC 环境变量优先，否则在 PATH 中查找（Windows 上会按 PATHEXT 匹配 scc.exe）
+func sccBinary() (string, error) {
+    if p := os.Getenv("SCC"); p != "" { return p, nil }
+    p, err := exec.LookPath("scc")
+    if err != nil { return "", i18n.Errorf("找不到 scc，请安装（https://github.com/boyter/scc）或用 SCC 环境变量指定路径: %w", err) }
+    return p, nil
+}
+
+// sccJSON 以 JSON 格式运行 scc 并解析按语言的统计（多个目录时合并统计）
+func sccJSON(targets ...string) ([]sccLanguage, error) {
+    bin, err := sccBinary()
+    if err != nil { return nil, err }
+    out, err := exec.Command(bin, append([]string{"--format", "json"}, targets...)...).Output()
+    if err != nil { return nil, err }
+    var langs []sccLanguage
+    return langs, json.Unmarshal(out, &langs)
+}
+
+// sccFiles 在 dir 下运行 scc --by-file，返回仓库内相对路径（/ 分隔）到代码行数的映射
+func sccFiles(dir string) (map[string]int, error) {
+    bin, err := sccBinary()
+    if err != nil { return nil, err }
+    cmd := exec.Command(bin, "--by-file", "--format", "json", ".")
+    cmd.Dir = dir
+    out, err := cmd.Output()
+    if err != nil { return nil, err }
+    var langs []struct{ Files []struct{ Location string; Code int } }
+    if err := json.Unmarshal(out, &langs); err != nil { return nil, err }
+    files := map[string]int{}
+    for _, l := range langs { for _, f := range l.Files { files[strings.TrimPrefix(filepath.ToSlash(f.Location), "./")] = f.Code } }
+    return files, nil
+}
+
+// sccMetrics 把按语言统计展开为导出指标，scope 为语言名
+func sccMetrics(repo string, langs []sccLanguage) []export.Metric {
+    var out []export.Metric
+    for _, l := range langs {
+        for name, v := range map[string]int{"files": l.Count, "lines": l.Lines, "code": l.Code,
+            "comments": l.Comment, "blanks": l.Blank, "complexity": l.Complexity, "bytes": l.Bytes} {
+            out = append(out, export.Metric{Repo: repo, Scope: l.Name, Name: name, Value: float64(v)})
+        }
+    }
+    return out
+}
 func init() { rootCmd.AddCommand(newSccCmd()) }
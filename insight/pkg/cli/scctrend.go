@@ -0,0 +1,264 @@
+package cli
+
+import (
+    "fmt"
+    "os"
+    "path/filepath"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/spf13/cobra"
+    "kingbrain/insight/pkg/i18n"
+    "kingbrain/insight/pkg/trend"
+)
+
+// sparkBlocks 是 sparkline 从低到高的八级字符
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+func newSccTrendCmd() *cobra.Command {
+    var (
+        dir      string
+        language string
+        since    string
+        dbPath   string
+        format   string
+    )
+
+    cmd := &cobra.Command{
+        Use:   "trend [dir|repo]",
+        Short: "画出用 kb scc --record 记录的代码行数与复杂度随时间的变化",
+        Long: `参数为本地目录（默认当前目录，按所在 git 仓库与仓库内的相对路径查找）或仓库名
+（此时用 --dir 指定仓库内的目录）。每个 revision 一个数据点，按提交时间排序；
+text 输出各指标的 sparkline 与逐点变化，csv/json 输出全部数据点供画图。
+
+  kb scc --record                        # 在 CI 或定时任务里记录
+  kb scc trend
+  kb scc trend github.com/acme/api --dir services/billing --language Go -f csv > billing.csv`,
+        Args: cobra.MaximumNArgs(1),
+        RunE: func(cmd *cobra.Command, args []string) error {
+            if err := checkFormat(format, "text", "csv", "json"); err != nil {
+                return err
+            }
+            target := "."
+            if len(args) == 1 {
+                target = args[0]
+            }
+            repo, sub := target, filepath.ToSlash(filepath.Clean(dir))
+            if isDir(target) {
+                p, err := sccPoint(target)
+                if err != nil {
+                    return err
+                }
+                repo, sub = p.Repo, p.Dir
+            }
+            db, err := openTrendDB(dbPath)
+            if err != nil {
+                return err
+            }
+            defer db.Close()
+            points, err := db.Series(repo, sub, language)
+            if err != nil {
+                return err
+            }
+            if since != "" {
+                t, err := time.ParseInLocation("2006-01-02", since, time.Local)
+                if err != nil {
+                    return i18n.Errorf("--since 应为 YYYY-MM-DD: %w", err)
+                }
+                kept := points[:0]
+                for _, p := range points {
+                    if !p.Committed.Before(t) {
+                        kept = append(kept, p)
+                    }
+                }
+                points = kept
+            }
+            if len(points) == 0 {
+                printTrendTargets(db)
+                return i18n.Errorf("趋势库中没有 %s（%s）的数据点，先在该目录运行 kb scc --record", repo, sub)
+            }
+
+            switch format {
+            case "json":
+                return writeJSON(os.Stdout, points)
+            case "csv":
+                rows := make([][]string, 0, len(points))
+                for _, p := range points {
+                    t := p.Total
+                    rows = append(rows, []string{p.Committed.Format(time.RFC3339), p.Rev, strconv.Itoa(t.Files), strconv.Itoa(t.Lines),
+                        strconv.Itoa(t.Code), strconv.Itoa(t.Comments), strconv.Itoa(t.Blanks), strconv.Itoa(t.Complexity)})
+                }
+                return writeCSV(os.Stdout, []string{"committed", "rev", "files", "lines", "code", "comments", "blanks", "complexity"}, rows)
+            }
+            printTrend(repo, sub, language, points)
+            return nil
+        },
+    }
+
+    cmd.Flags().StringVar(&dir, "dir", ".", "参数为仓库名时，仓库内的目录")
+    cmd.Flags().StringVar(&language, "language", "", "只看该语言（scc 的语言名，如 Go、TypeScript）")
+    cmd.Flags().StringVar(&since, "since", "", "只看该日期（YYYY-MM-DD）之后提交的数据点")
+    cmd.Flags().StringVar(&dbPath, "trend-db", "", "趋势库路径（默认 <用户缓存目录>/insight/scc-trend.db）")
+    cmd.Flags().StringVarP(&format, "format", "f", "text", "输出格式：text|csv|json")
+    return cmd
+}
+
+func openTrendDB(path string) (*trend.DB, error) {
+    if path == "" {
+        var err error
+        if path, err = trend.DefaultPath(); err != nil {
+            return nil, err
+        }
+    }
+    return trend.Open(path)
+}
+
+// sccPoint 确定目录对应的 仓库、仓库内路径、revision 与提交时间；没有 origin 时以仓库根目录的路径作为仓库名
+func sccPoint(dir string) (trend.Point, error) {
+    root, err := gitRoot(dir)
+    if err != nil {
+        return trend.Point{}, i18n.Errorf("%s 不在 git 仓库中，趋势按 revision 区分数据点: %w", dir, err)
+    }
+    repo, err := localRepoName(root)
+    if err != nil {
+        repo = filepath.ToSlash(root)
+    }
+    abs, err := filepath.Abs(dir)
+    if err != nil {
+        return trend.Point{}, err
+    }
+    if r, err := filepath.EvalSymlinks(abs); err == nil {
+        abs = r
+    }
+    rel, err := filepath.Rel(root, abs)
+    if err != nil {
+        return trend.Point{}, err
+    }
+    rev, err := gitOutput(root, "rev-parse", "HEAD")
+    if err != nil {
+        return trend.Point{}, err
+    }
+    date, err := gitOutput(root, "show", "-s", "--format=%cI", "HEAD")
+    if err != nil {
+        return trend.Point{}, err
+    }
+    committed, err := time.Parse(time.RFC3339, date)
+    if err != nil {
+        return trend.Point{}, err
+    }
+    return trend.Point{Repo: repo, Dir: filepath.ToSlash(rel), Rev: rev, Committed: committed, Recorded: time.Now()}, nil
+}
+
+// recordScc 对每个目录运行 scc 并记入趋势库
+func recordScc(dbPath string, targets []string) error {
+    db, err := openTrendDB(dbPath)
+    if err != nil {
+        return err
+    }
+    defer db.Close()
+    for _, t := range targets {
+        p, err := sccPoint(t)
+        if err != nil {
+            return err
+        }
+        langs, err := sccJSON(t)
+        if err != nil {
+            return err
+        }
+        for _, l := range langs {
+            p.Languages = append(p.Languages, trend.Stats{Language: l.Name, Files: l.Count, Lines: l.Lines, Code: l.Code,
+                Comments: l.Comment, Blanks: l.Blank, Complexity: l.Complexity})
+        }
+        ok, err := db.Record(p)
+        if err != nil {
+            return err
+        }
+        short := p.Rev[:min(len(p.Rev), 10)]
+        if ok {
+            fmt.Fprint(os.Stderr, i18n.Sprintf("已记录 %s（%s）@ %s\n", p.Repo, p.Dir, short))
+        } else {
+            fmt.Fprint(os.Stderr, i18n.Sprintf("%s（%s）@ %s 的统计与上一个数据点相同，未记录\n", p.Repo, p.Dir, short))
+        }
+    }
+    return nil
+}
+
+// printTrendTargets 在 stderr 列出趋势库中已有数据点的仓库与目录，方便改正参数；库为空或读取失败时不输出
+func printTrendTargets(db *trend.DB) {
+    targets, err := db.Targets()
+    if err != nil || len(targets) == 0 {
+        return
+    }
+    fmt.Fprint(os.Stderr, i18n.T("趋势库中已有的数据：\n"))
+    for _, t := range targets {
+        fmt.Fprintf(os.Stderr, "  %s  --dir %s\n", t[0], t[1])
+    }
+}
+
+func printTrend(repo, dir, language string, points []trend.Point) {
+    first, last := points[0], points[len(points)-1]
+    title := repo
+    if dir != "." {
+        title += "/" + dir
+    }
+    if language != "" {
+        title += " [" + language + "]"
+    }
+    fmt.Print(i18n.Sprintf("%s（%d 个数据点，%s → %s）\n\n", title, len(points),
+        first.Committed.Format("2006-01-02"), last.Committed.Format("2006-01-02")))
+
+    metrics := []struct {
+        name string
+        get  func(trend.Stats) int
+    }{
+        {"code", func(s trend.Stats) int { return s.Code }},
+        {"lines", func(s trend.Stats) int { return s.Lines }},
+        {"complexity", func(s trend.Stats) int { return s.Complexity }},
+        {"files", func(s trend.Stats) int { return s.Files }},
+    }
+    for _, m := range metrics {
+        vals := make([]int, len(points))
+        for i, p := range points {
+            vals[i] = m.get(p.Total)
+        }
+        a, b := vals[0], vals[len(vals)-1]
+        fmt.Printf("  %-10s  %s  %d → %d  %s\n", m.name, sparkline(vals), a, b, delta(a, b))
+    }
+
+    fmt.Printf("\n  %-10s  %-10s  %8s  %8s  %10s  %8s\n", "date", "rev", "code", "Δcode", "complexity", "Δcplx")
+    for i, p := range points {
+        dc, dx := "", ""
+        if i > 0 {
+            dc = fmt.Sprintf("%+d", p.Total.Code-points[i-1].Total.Code)
+            dx = fmt.Sprintf("%+d", p.Total.Complexity-points[i-1].Total.Complexity)
+        }
+        fmt.Printf("  %-10s  %-10s  %8d  %8s  %10d  %8s\n", p.Committed.Format("2006-01-02"), p.Rev[:min(len(p.Rev), 10)],
+            p.Total.Code, dc, p.Total.Complexity, dx)
+    }
+}
+
+// sparkline 把数列按最小值到最大值线性映射到八级字符
+func sparkline(vals []int) string {
+    lo, hi := vals[0], vals[0]
+    for _, v := range vals {
+        lo, hi = min(lo, v), max(hi, v)
+    }
+    var b strings.Builder
+    for _, v := range vals {
+        i := 0
+        if hi > lo {
+            i = (v - lo) * (len(sparkBlocks) - 1) / (hi - lo)
+        }
+        b.WriteRune(sparkBlocks[i])
+    }
+    return b.String()
+}
+
+// delta 写出 a 到 b 的变化量与百分比
+func delta(a, b int) string {
+    if a == 0 {
+        return fmt.Sprintf("%+d", b-a)
+    }
+    return fmt.Sprintf("%+d (%+.1f%%)", b-a, float64(b-a)*100/float64(a))
+}
@@ -0,0 +1,236 @@
+package cli
+
+import (
+    "context"
+    "fmt"
+    "os"
+    "sort"
+    "time"
+
+    "github.com/spf13/cobra"
+    "kingbrain/insight/pkg/gql"
+    "kingbrain/insight/pkg/i18n"
+    "kingbrain/insight/pkg/sg"
+)
+
+// QueryCheck 是一个查询按 schema 校验的结果
+type QueryCheck struct {
+    Name     string        `json:"name"`
+    Error    string        `json:"error,omitempty"`
+    Problems []gql.Problem `json:"problems,omitempty"`
+}
+
+func newSchemaCmd() *cobra.Command {
+    cmd := &cobra.Command{
+        Use:   "schema",
+        Short: "缓存实例的 GraphQL schema，校验 kb 与自己写的查询，导出 SDL",
+        Long: `kb schema refresh 内省当前实例（SG_URL/LOCAL_SG_ENDPOINT）的 GraphQL schema 并缓存到
+<用户缓存目录>/insight/schema/。有缓存时，每个命令在第一次发送某个查询前都会按它校验，
+查询用到了实例上不存在（多半已被移除）或已废弃的字段、参数时在 stderr 提示；--schema-check=false 关闭。
+不带子命令时显示缓存的状态。
+
+  kb schema refresh
+  kb schema check                      # 校验 kb 内置的全部查询，有字段不存在时退出码为 1
+  kb schema check my-query.graphql     # 校验自己写的查询（配合 kb api 使用）
+  kb schema dump Repository GitCommit  # 以 SDL 输出指定类型，不给类型时输出全部`,
+        Args: cobra.NoArgs,
+        RunE: func(cmd *cobra.Command, _ []string) error {
+            endpoint := sg.New().URL("")
+            cached, err := gql.LoadCached(endpoint)
+            if err != nil {
+                return err
+            }
+            path, err := gql.CachePath(endpoint)
+            if err != nil {
+                return err
+            }
+            if cached == nil {
+                fmt.Print(i18n.Sprintf("%s 的 schema 还没有缓存，运行 kb schema refresh\n", endpoint))
+                return nil
+            }
+            fmt.Print(i18n.Sprintf("实例: %s\n缓存: %s\n更新于: %s（%s 前）\n类型数: %d\n", cached.Endpoint, path,
+                cached.Fetched.Local().Format("2006-01-02 15:04"), time.Since(cached.Fetched).Round(time.Minute), len(cached.Schema.Types)))
+            return nil
+        },
+    }
+    cmd.AddCommand(newSchemaRefreshCmd(), newSchemaCheckCmd(), newSchemaDumpCmd())
+    return cmd
+}
+
+func newSchemaRefreshCmd() *cobra.Command {
+    return &cobra.Command{
+        Use:   "refresh",
+        Short: "重新内省实例的 schema 并更新缓存",
+        Args:  cobra.NoArgs,
+        RunE: func(cmd *cobra.Command, _ []string) error {
+            s, path, err := refreshSchema(cmd.Context(), sg.New())
+            if err != nil {
+                return err
+            }
+            fmt.Fprint(os.Stderr, i18n.Sprintf("已缓存 %d 个类型到 %s\n", len(s.Types), path))
+            return nil
+        },
+    }
+}
+
+func newSchemaCheckCmd() *cobra.Command {
+    var (
+        refresh bool
+        format  string
+    )
+    cmd := &cobra.Command{
+        Use:   "check [query-file|-]...",
+        Short: "按实例的 schema 校验 kb 内置的查询或给定的查询文件",
+        RunE: func(cmd *cobra.Command, args []string) error {
+            if err := checkFormat(format, "text", "json"); err != nil {
+                return err
+            }
+            s, err := loadSchema(cmd.Context(), sg.New(), refresh)
+            if err != nil {
+                return err
+            }
+            queries := map[string]string{}
+            if len(args) == 0 {
+                queries = sg.Queries()
+            }
+            for _, a := range args {
+                doc, err := readAPIDocument(a)
+                if err != nil {
+                    return err
+                }
+                queries[a] = doc
+            }
+            names := make([]string, 0, len(queries))
+            for n := range queries {
+                names = append(names, n)
+            }
+            sort.Strings(names)
+
+            var results []QueryCheck
+            broken := 0
+            for _, n := range names {
+                r := QueryCheck{Name: n}
+                problems, err := s.ValidateQuery(queries[n])
+                if err != nil {
+                    r.Error = err.Error()
+                }
+                r.Problems = problems
+                for _, p := range problems {
+                    if p.Removed {
+                        broken++
+                        break
+                    }
+                }
+                if err != nil {
+                    broken++
+                }
+                results = append(results, r)
+            }
+            if format == "json" {
+                if err := writeJSON(os.Stdout, results); err != nil {
+                    return err
+                }
+            } else {
+                for _, r := range results {
+                    mark := "✓"
+                    if r.Error != "" || len(r.Problems) > 0 {
+                        mark = "✗"
+                    }
+                    fmt.Printf("%s %s\n", mark, r.Name)
+                    if r.Error != "" {
+                        fmt.Printf("    %s\n", r.Error)
+                    }
+                    for _, p := range r.Problems {
+                        level := i18n.T("已废弃")
+                        if p.Removed {
+                            level = i18n.T("不存在")
+                        }
+                        fmt.Print(i18n.Sprintf("    第 %d 行 %s [%s]: %s\n", p.Line, p.Path, level, p.Message))
+                    }
+                }
+            }
+            if broken > 0 {
+                return exitWith(cmd, exitFalse, i18n.Sprintf("%d 个查询与实例的 schema 不符", broken))
+            }
+            return nil
+        },
+    }
+    cmd.Flags().BoolVar(&refresh, "refresh", false, "先重新内省实例的 schema")
+    cmd.Flags().StringVarP(&format, "format", "f", "text", "输出格式：text|json")
+    return cmd
+}
+
+func newSchemaDumpCmd() *cobra.Command {
+    var (
+        refresh bool
+        format  string
+    )
+    cmd := &cobra.Command{
+        Use:   "dump [type]...",
+        Short: "以 SDL 或内省 JSON 输出实例的 schema，供本地编写查询时参考",
+        RunE: func(cmd *cobra.Command, args []string) error {
+            if err := checkFormat(format, "sdl", "json"); err != nil {
+                return err
+            }
+            s, err := loadSchema(cmd.Context(), sg.New(), refresh)
+            if err != nil {
+                return err
+            }
+            if format == "json" {
+                if len(args) == 0 {
+                    return writeJSON(os.Stdout, s)
+                }
+                var types []*gql.Type
+                for _, a := range args {
+                    t := s.Type(a)
+                    if t == nil {
+                        return i18n.Errorf("schema 中没有类型 %s", a)
+                    }
+                    types = append(types, t)
+                }
+                return writeJSON(os.Stdout, types)
+            }
+            return s.WriteSDL(os.Stdout, args)
+        },
+    }
+    cmd.Flags().BoolVar(&refresh, "refresh", false, "先重新内省实例的 schema")
+    cmd.Flags().StringVarP(&format, "format", "f", "sdl", "输出格式：sdl|json")
+    return cmd
+}
+
+// refreshSchema 内省实例并写入缓存
+func refreshSchema(ctx context.Context, c *sg.Client) (*gql.Schema, string, error) {
+    s, err := c.Schema(ctx)
+    if err != nil {
+        return nil, "", err
+    }
+    path, err := (&gql.Cached{Endpoint: c.URL(""), Fetched: time.Now(), Schema: s}).Save()
+    if err != nil {
+        return nil, "", err
+    }
+    return s, path, nil
+}
+
+// loadSchema 取缓存的 schema，没有缓存或 refresh 时先内省
+func loadSchema(ctx context.Context, c *sg.Client, refresh bool) (*gql.Schema, error) {
+    if !refresh {
+        cached, err := gql.LoadCached(c.URL(""))
+        if err != nil {
+            return nil, err
+        }
+        if cached != nil {
+            return cached.Schema, nil
+        }
+    }
+    s, path, err := refreshSchema(ctx, c)
+    if err != nil {
+        return nil, err
+    }
+    fmt.Fprint(os.Stderr, i18n.Sprintf("已缓存 %d 个类型到 %s\n", len(s.Types), path))
+    return s, nil
+}
+
+func init() {
+    rootCmd.PersistentFlags().BoolVar(&sg.CheckSchema, "schema-check", true, "发送查询前按缓存的实例 schema 校验字段（见 kb schema）")
+    rootCmd.AddCommand(newSchemaCmd())
+}
@@ -0,0 +1,115 @@
+package cli
+
+import (
+    "path/filepath"
+    "regexp"
+    "slices"
+    "sort"
+    "strings"
+
+    "github.com/spf13/cobra"
+    "kingbrain/insight/pkg/config"
+    "kingbrain/insight/pkg/i18n"
+    "kingbrain/insight/pkg/sg"
+)
+
+var (
+    scopeName string
+    // activeScope 是 --scope 解析出的范围，未指定时为 nil
+    activeScope *config.Scope
+)
+
+func init() {
+    rootCmd.PersistentFlags().StringVar(&scopeName, "scope", "", "使用配置文件 scopes 中的命名范围，自动追加 repo:/file: 过滤器（scc、audit 的行数统计也只算范围内）")
+    rootCmd.RegisterFlagCompletionFunc("scope", func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+        cfg, err := config.Load()
+        if err != nil {
+            return nil, cobra.ShellCompDirectiveNoFileComp
+        }
+        return scopeNames(cfg), cobra.ShellCompDirectiveNoFileComp
+    })
+}
+
+// applyScope 解析 --scope 并设置 sg.DefaultFilters，之后创建的 Client 都会带上范围过滤器
+func applyScope() error {
+    if scopeName == "" {
+        return nil
+    }
+    cfg, err := config.Load()
+    if err != nil {
+        return err
+    }
+    s, ok := cfg.Scopes[scopeName]
+    if !ok {
+        return i18n.Errorf("配置中没有名为 %s 的 scope（可用：%s）", scopeName, strings.Join(scopeNames(cfg), ", "))
+    }
+    if len(s.Repos) == 0 {
+        return i18n.Errorf("scope %s 没有配置 repos", scopeName)
+    }
+    activeScope = &s
+    sg.DefaultFilters = scopeFilters(s)
+    return nil
+}
+
+func scopeNames(cfg *config.Config) []string {
+    names := make([]string, 0, len(cfg.Scopes))
+    for n := range cfg.Scopes {
+        names = append(names, n)
+    }
+    sort.Strings(names)
+    return names
+}
+
+// scopeFilters 生成范围对应的过滤器：repo:^(?:a|b)$ file:^(?:x/|y/)
+func scopeFilters(s config.Scope) string {
+    quote := func(list []string) string {
+        q := make([]string, len(list))
+        for i, v := range list {
+            q[i] = regexp.QuoteMeta(strings.TrimPrefix(v, "/"))
+        }
+        return strings.Join(q, "|")
+    }
+    f := "repo:^(?:" + quote(s.Repos) + ")$"
+    if len(s.Paths) > 0 {
+        f += " file:^(?:" + quote(s.Paths) + ")"
+    }
+    return f
+}
+
+// scopeAllows 判断 repo 中的 path 是否在 --scope 范围内；未指定 scope 时总是 true
+func scopeAllows(repo, path string) bool {
+    if activeScope == nil {
+        return true
+    }
+    if !slices.Contains(activeScope.Repos, repo) {
+        return false
+    }
+    if len(activeScope.Paths) == 0 {
+        return true
+    }
+    for _, p := range activeScope.Paths {
+        if strings.HasPrefix(path, strings.TrimPrefix(p, "/")) {
+            return true
+        }
+    }
+    return false
+}
+
+// scopeDirs 返回范围在本地检出中对应的目录，供 scc 等本地统计使用
+func scopeDirs(ws config.Workspace) ([]string, error) {
+    var dirs []string
+    for _, repo := range activeScope.Repos {
+        dir, err := findCheckout(ws, repo)
+        if err != nil {
+            return nil, err
+        }
+        if len(activeScope.Paths) == 0 {
+            dirs = append(dirs, dir)
+            continue
+        }
+        for _, p := range activeScope.Paths {
+            dirs = append(dirs, filepath.Join(dir, filepath.FromSlash(strings.TrimPrefix(p, "/"))))
+        }
+    }
+    return dirs, nil
+}
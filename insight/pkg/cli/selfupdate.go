@@ -0,0 +1,92 @@
+package cli
+
+import (
+    "fmt"
+    "os"
+    "regexp"
+
+    "github.com/spf13/cobra"
+    "kingbrain/insight/pkg/config"
+    "kingbrain/insight/pkg/i18n"
+    "kingbrain/insight/pkg/manifest"
+    "kingbrain/insight/pkg/selfupdate"
+    "kingbrain/insight/pkg/version"
+)
+
+// releaseVersionRe 匹配可以与发布版本比较的版本号：v1.2、1.2.3、v1.2.3-rc.1、git describe 的 v1.2.3-4-gabcdef-dirty
+var releaseVersionRe = regexp.MustCompile(`^v?\d+\.\d+(\.\d+)?([-+].*)?$`)
+
+func newSelfUpdateCmd() *cobra.Command {
+    var (
+        source string
+        check  bool
+        force  bool
+    )
+
+    cmd := &cobra.Command{
+        Use:   "self-update",
+        Short: "检查发布源的最新版本，校验后替换当前二进制",
+        Long: `发布源取 --url，其次 INSIGHT_UPDATE_URL，再次配置文件中的 update.url：
+
+  github.com/acme/insight          GitHub releases（GITHUB_TOKEN 可选）
+  https://artifacts.acme.dev/kb    制品库：<url>/latest 给出版本号，
+                                   <url>/<version>/ 下放 kb_<os>_<arch> 与 checksums.txt
+
+下载的二进制必须与 checksums.txt（sha256sum 格式）一致；配置了 update.public_key
+时还会校验 checksums.txt.sig 的 ed25519 签名。`,
+        Args: cobra.NoArgs,
+        RunE: func(cmd *cobra.Command, _ []string) error {
+            cfg, err := config.Load()
+            if err != nil {
+                return err
+            }
+            if source == "" {
+                source = os.Getenv("INSIGHT_UPDATE_URL")
+            }
+            if source == "" {
+                source = cfg.Update.URL
+            }
+            if source == "" {
+                return i18n.Errorf("未配置发布源：使用 --url、INSIGHT_UPDATE_URL 或配置文件 update.url")
+            }
+
+            cur := version.Get().Version
+            rel, err := selfupdate.Latest(cmd.Context(), source)
+            if err != nil {
+                return err
+            }
+            // dev 构建与没有标签时 git describe 给出的提交号无法比较版本，总是视为可更新
+            newer := !releaseVersionRe.MatchString(cur) || manifest.Compare(rel.Version, cur) > 0
+            if !newer && !force {
+                fmt.Print(i18n.Sprintf("已是最新版本 %s\n", cur))
+                return nil
+            }
+            if check {
+                fmt.Print(i18n.Sprintf("有新版本：%s → %s\n", cur, rel.Version))
+                return nil
+            }
+
+            fmt.Fprint(os.Stderr, i18n.Sprintf("下载 %s %s ...\n", rel.Version, selfupdate.AssetName()))
+            bin, err := rel.Download(cmd.Context(), cfg.Update.PublicKey)
+            if err != nil {
+                return err
+            }
+            exe, err := os.Executable()
+            if err != nil {
+                return err
+            }
+            if err := selfupdate.Replace(exe, bin); err != nil {
+                return err
+            }
+            fmt.Print(i18n.Sprintf("已更新：%s → %s\n", cur, rel.Version))
+            return nil
+        },
+    }
+
+    cmd.Flags().StringVar(&source, "url", "", "发布源（GitHub 仓库或制品库地址）")
+    cmd.Flags().BoolVar(&check, "check", false, "只检查是否有新版本，不下载")
+    cmd.Flags().BoolVar(&force, "force", false, "版本相同也重新安装")
+    return cmd
+}
+
+func init() { rootCmd.AddCommand(newSelfUpdateCmd()) }
@@ -0,0 +1,278 @@
+package cli
+
+import (
+    "context"
+    "crypto/sha256"
+    "encoding/hex"
+    "fmt"
+    "os"
+    "strings"
+
+    "github.com/spf13/cobra"
+    "kingbrain/insight/pkg/config"
+    "kingbrain/insight/pkg/i18n"
+    "kingbrain/insight/pkg/llm"
+    "kingbrain/insight/pkg/preview"
+    "kingbrain/insight/pkg/progress"
+    "kingbrain/insight/pkg/sg"
+    "kingbrain/insight/pkg/snippet"
+    "kingbrain/insight/pkg/vecstore"
+)
+
+const (
+    // 没有所在函数（或函数过长）时，取匹配行上下各 chunkContext 行作为片段
+    chunkContext  = 10
+    chunkMaxLines = 120
+    // 每个片段发送给 embedding 接口的估算 token 上限
+    chunkMaxTokens = 2000
+    embedBatch     = 32
+)
+
+// newEmbedder 按配置文件 llm 段创建 embedding 客户端与脱敏规则
+func newEmbedder() (*llm.Client, string, *llm.Redactor, error) {
+    cfg, err := config.Load()
+    if err != nil {
+        return nil, "", nil, err
+    }
+    l := cfg.LLM
+    url := l.EmbeddingURL
+    if url == "" {
+        url = l.URL
+    }
+    if url == "" || l.EmbeddingModel == "" {
+        p, _ := config.Path()
+        return nil, "", nil, i18n.Errorf("未配置 embedding 接口，请在 %s 中设置 llm.url（或 llm.embedding_url）与 llm.embedding_model", p)
+    }
+    keyEnv := l.APIKeyEnv
+    if keyEnv == "" {
+        keyEnv = "OPENAI_API_KEY"
+    }
+    red, err := llm.NewRedactor(l.Redact)
+    if err != nil {
+        return nil, "", nil, err
+    }
+    return llm.New(url, l.EmbeddingModel, os.Getenv(keyEnv)), l.EmbeddingModel, red, nil
+}
+
+func newSemanticCmd() *cobra.Command {
+    var (
+        top    int
+        lines  int
+        format string
+    )
+
+    cmd := &cobra.Command{
+        Use:   "semantic <question>",
+        Short: "在本地向量索引中按语义检索代码片段（先用 semantic index 建索引）",
+        Long: `适合“我们在哪里处理 X”这类说不出确切关键字的问题，是精确搜索的补充。
+索引只包含用 semantic index 收录过的搜索结果，检索完全在本地进行，只有问题本身会发给 embedding 接口。
+
+  kb semantic index 'lang:go retry' 'lang:go backoff' --repo github.com/acme/.*
+  kb semantic "失败的请求在哪里重试"`,
+        Args: cobra.MinimumNArgs(1),
+        RunE: func(cmd *cobra.Command, args []string) error {
+            if err := checkFormat(format, "text", "json"); err != nil {
+                return err
+            }
+            store, err := vecstore.Load()
+            if err != nil {
+                return err
+            }
+            if len(store.Chunks) == 0 {
+                return i18n.Errorf("语义索引为空，请先运行 kb semantic index <query>")
+            }
+            client, model, _, err := newEmbedder()
+            if err != nil {
+                return err
+            }
+            if store.Model != model {
+                return i18n.Errorf("索引由 embedding 模型 %s 生成，与当前配置的 %s 不一致，请用 semantic index --rebuild 重建", store.Model, model)
+            }
+            vecs, err := client.Embed(cmd.Context(), []string{strings.Join(args, " ")})
+            if err != nil {
+                return err
+            }
+            hits := store.Search(vecs[0], top)
+            if format == "json" {
+                for i := range hits {
+                    hits[i].Vector = nil
+                }
+                return writeJSON(os.Stdout, hits)
+            }
+            for i, h := range hits {
+                name := ""
+                if h.Name != "" {
+                    name = " " + h.Name
+                }
+                fmt.Print(i18n.Sprintf("%d. %s/%s:%d-%d%s（%.2f）\n", i+1, h.Repo, h.Path, h.Start, h.End, name, h.Score))
+                body := strings.Split(h.Text, "\n")
+                shown := body
+                if lines > 0 && len(body) > lines {
+                    shown = body[:lines]
+                }
+                for j, l := range shown {
+                    fmt.Printf("  %5d | %s\n", h.Start+j, preview.Render(l))
+                }
+                if len(shown) < len(body) {
+                    fmt.Print(i18n.Sprintf("        ...（另有 %d 行）\n", len(body)-len(shown)))
+                }
+                fmt.Println()
+            }
+            return nil
+        },
+    }
+
+    cmd.Flags().IntVarP(&top, "top", "k", 5, "返回的片段数")
+    cmd.Flags().IntVar(&lines, "lines", 20, "每个片段最多显示的行数（0 为全部）")
+    cmd.Flags().StringVarP(&format, "format", "f", "text", "输出格式：text|json")
+    cmd.AddCommand(newSemanticIndexCmd())
+    return cmd
+}
+
+func newSemanticIndexCmd() *cobra.Command {
+    var (
+        pattern   string
+        repos     []string
+        maxChunks int
+        rebuild   bool
+    )
+
+    cmd := &cobra.Command{
+        Use:   "index <query...>",
+        Short: "执行搜索，把匹配所在的函数（或上下文窗口）切成片段，向量化后加入本地索引",
+        Long: `片段以所在函数为单位（支持的语言见 find --enclosing-function），其余按匹配行上下 10 行切分。
+内容先按 llm.redact 与内置规则脱敏再发给 embedding 接口。已在索引中的片段（内容未变）不会重复向量化。`,
+        Args: cobra.MinimumNArgs(1),
+        RunE: func(cmd *cobra.Command, args []string) error {
+            client, model, red, err := newEmbedder()
+            if err != nil {
+                return err
+            }
+            store, err := vecstore.Load()
+            if err != nil {
+                return err
+            }
+            if rebuild || store.Model != model {
+                if len(store.Chunks) > 0 && !rebuild {
+                    return i18n.Errorf("索引由 embedding 模型 %s 生成，换用 %s 需要加 --rebuild", store.Model, model)
+                }
+                store = &vecstore.Store{Model: model}
+            }
+
+            c := sg.New()
+            var chunks []vecstore.Chunk
+            for _, q := range args {
+                res, err := c.Search(cmd.Context(), buildQuery(q, repoFilter(repos)), pattern)
+                if err != nil {
+                    return fmt.Errorf("%s: %w", q, err)
+                }
+                chunks = append(chunks, chunkMatches(cmd.Context(), c, res)...)
+            }
+            fresh := chunks[:0]
+            seen := map[string]bool{}
+            for _, ch := range chunks {
+                if !store.Has(ch.Hash) && !seen[ch.Hash] {
+                    seen[ch.Hash] = true
+                    fresh = append(fresh, ch)
+                }
+            }
+            skipped := len(chunks) - len(fresh)
+            if maxChunks > 0 && len(fresh) > maxChunks {
+                fmt.Fprint(os.Stderr, i18n.Sprintf("警告: 新片段 %d 个，只收录前 %d 个（--max-chunks）\n", len(fresh), maxChunks))
+                fresh = fresh[:maxChunks]
+            }
+            if err := embedChunks(cmd.Context(), client, red, fresh); err != nil {
+                return err
+            }
+            store.Add(fresh...)
+            if err := store.Save(); err != nil {
+                return err
+            }
+            fmt.Fprint(os.Stderr, i18n.Sprintf("新增 %d 个片段（跳过已收录的 %d 个），索引共 %d 个片段\n", len(fresh), skipped, len(store.Chunks)))
+            return nil
+        },
+    }
+
+    cmd.Flags().StringVarP(&pattern, "pattern", "p", "literal", "搜索模式：literal|regexp|structural")
+    cmd.Flags().StringSliceVar(&repos, "repo", nil, "限定仓库（可重复，支持正则；含 * ? 的 glob 按仓库缓存展开）")
+    cmd.Flags().IntVar(&maxChunks, "max-chunks", 1000, "本次最多向量化的新片段数（0 为不限制）")
+    cmd.Flags().BoolVar(&rebuild, "rebuild", false, "清空已有索引后重建（更换 embedding 模型时需要）")
+    return cmd
+}
+
+// chunkMatches 拉取匹配所在的文件并切分片段；同一函数或重叠窗口只保留一份
+func chunkMatches(ctx context.Context, c *sg.Client, res *sg.SearchResults) []vecstore.Chunk {
+    specs := make([]sg.FileSpec, 0, len(res.Results))
+    for _, fm := range res.Results {
+        specs = append(specs, sg.FileSpec{Repo: fm.Repository.Name, Path: fm.File.Path})
+    }
+    files := map[string]string{}
+    for _, r := range c.GetFiles(ctx, specs) {
+        if r.Err != nil {
+            fmt.Fprint(os.Stderr, i18n.Sprintf("警告: 拉取 %s/%s 失败，跳过: %v\n", r.Repo, r.Path, r.Err))
+            continue
+        }
+        files[r.Repo+"\x00"+r.Path] = r.Content
+    }
+
+    var out []vecstore.Chunk
+    for _, fm := range res.Results {
+        content, ok := files[fm.Repository.Name+"\x00"+fm.File.Path]
+        if !ok {
+            continue
+        }
+        lines := strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+        covered := 0 // 已切分到的最后一行，按行号顺序处理时用于跳过重叠
+        for _, m := range fm.LineMatches {
+            line := m.LineNumber + 1
+            if line <= covered {
+                continue
+            }
+            start, end, name := max(1, line-chunkContext), min(len(lines), line+chunkContext), ""
+            if f, ok := snippet.Enclosing(fm.File.Path, content, line); ok && f.End-f.Start < chunkMaxLines {
+                start, end, name = f.Start, f.End, f.Name
+            }
+            text := strings.Join(lines[start-1:end], "\n")
+            sum := sha256.Sum256([]byte(fm.Repository.Name + "\x00" + fm.File.Path + "\x00" + text))
+            out = append(out, vecstore.Chunk{
+                Repo: fm.Repository.Name, Path: fm.File.Path, Start: start, End: end, Name: name,
+                Text: text, Hash: hex.EncodeToString(sum[:8]),
+            })
+            covered = end
+        }
+    }
+    return out
+}
+
+// embedChunks 分批向量化；发送的内容带上路径与函数名，先脱敏并截断到 chunkMaxTokens
+func embedChunks(ctx context.Context, client *llm.Client, red *llm.Redactor, chunks []vecstore.Chunk) error {
+    if len(chunks) == 0 {
+        return nil
+    }
+    bar := progress.New((len(chunks) + embedBatch - 1) / embedBatch)
+    defer bar.Finish()
+    for i := 0; i < len(chunks); i += embedBatch {
+        batch := chunks[i:min(i+embedBatch, len(chunks))]
+        inputs := make([]string, len(batch))
+        for j, ch := range batch {
+            text, _ := red.Redact(fmt.Sprintf("%s/%s %s\n%s", ch.Repo, ch.Path, ch.Name, ch.Text))
+            for llm.EstimateTokens(text) > chunkMaxTokens {
+                text = text[:len(text)*3/4]
+            }
+            inputs[j] = strings.ToValidUTF8(text, "")
+        }
+        label := fmt.Sprintf("%d-%d", i+1, i+len(batch))
+        bar.Begin(label)
+        vecs, err := client.Embed(ctx, inputs)
+        bar.End(label, err)
+        if err != nil {
+            return err
+        }
+        for j := range batch {
+            batch[j].Vector = vecs[j]
+        }
+    }
+    return nil
+}
+
+func init() { rootCmd.AddCommand(newSemanticCmd()) }
@@ -0,0 +1,388 @@
+package cli
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "net"
+    "net/http"
+    "os"
+    "os/exec"
+    "os/signal"
+    "path/filepath"
+    "strings"
+    "syscall"
+    "time"
+
+    "github.com/spf13/cobra"
+    "github.com/spf13/pflag"
+    "kingbrain/insight/pkg/config"
+    "kingbrain/insight/pkg/i18n"
+    "kingbrain/insight/pkg/server"
+    "kingbrain/insight/pkg/sg"
+    "kingbrain/insight/pkg/version"
+)
+
+// serveMaxOutput 是 /api/run 返回的 stdout/stderr 各自的上限
+const serveMaxOutput = 16 << 20
+
+// runResult 是 /api/run 的响应
+type runResult struct {
+    ExitCode   int    `json:"exit_code"`
+    Stdout     string `json:"stdout"`
+    Stderr     string `json:"stderr,omitempty"`
+    Truncated  bool   `json:"truncated,omitempty"`
+    DurationMS int64  `json:"duration_ms"`
+}
+
+func newServeCmd() *cobra.Command {
+    var (
+        addr       string
+        auditLog   string
+        timeout    time.Duration
+        noAuth     bool
+        userTokens string
+    )
+
+    cmd := &cobra.Command{
+        Use:   "serve",
+        Short: "以 HTTP JSON API 的形式提供搜索与 kb 命令，供团队共用或给网页前端调用",
+        Long: `接口：
+  GET  /healthz                                     健康检查，不需要认证
+  POST /api/search  {"query": "...", "pattern": "literal"}   返回搜索结果（与 find -f json 的结构相同）
+  POST /api/run     {"args": ["find", "-f", "json", "..."]}  以子进程执行一条 kb 命令，返回退出码与输出
+
+调用方在 Authorization: Bearer <key> 或 X-API-Key 头中带上配置文件 serve.keys 里的密钥。
+每个 key 可以设置每分钟请求数（rate_per_minute）与允许的命令（commands，search 对应 /api/search，
+其余为 /api/run 的命令名，如 find、ws list；"*" 表示全部）。
+/api/run 只能调用只读的搜索与报告命令（key 的 commands 为 "*" 时也一样）：find、usage-examples、repos、tree、
+compare-revs、compare-repos、changelog、count-loc-remote、deadcode、drift、dupes、grep-archive、migrate-plan、
+testmap、todos、vulns、bigfiles、modgraph、context、schema dump、ws list、version；也不能带值为服务端路径、
+执行脚本或以服务端身份对外操作的 flag：-o/--output、--out、--export、--hook、--state、--transcript、--baseline、
+--write-baseline、--checkpoint、--trend-db、--queries、--report、--repos-file、--message-template、--vars、
+--create-issues、--summarize，参数中也不能有 "--"，否则返回 403。
+每个请求写一行 JSON 审计日志（key 名、来源、命令与参数、状态码、耗时，不含密钥）。
+浏览器前端需要在 serve.cors_origins 中列出其 Origin。
+
+serve.user_tokens 为 allow 或 require 时，调用方可以（require 时必须）在 X-Sourcegraph-Token 头中
+带上自己的 Sourcegraph token，请求以该用户的身份访问实例，只能看到其有权限的仓库；
+/api/run 的子进程通过 SG_TOKEN 拿到同一个 token。token 先用 currentUser 校验，
+无效时返回 401；校验结果按 token 缓存 5 分钟，审计日志记下对应的用户名（不含 token）。
+
+  serve:
+    addr: 0.0.0.0:7070
+    cors_origins: [https://insight.example.com]
+    user_tokens: require
+    keys:
+      - name: web
+        key_env: INSIGHT_SERVE_KEY_WEB
+        rate_per_minute: 60
+        commands: [search, find, usage-examples]
+
+  kb serve
+  kb serve --no-auth        # 本机试用，只能监听回环地址`,
+        Args: cobra.NoArgs,
+        RunE: func(cmd *cobra.Command, args []string) error {
+            cfg, err := config.Load()
+            if err != nil {
+                return err
+            }
+            sc := cfg.Serve
+            if !cmd.Flags().Changed("addr") && sc.Addr != "" {
+                addr = sc.Addr
+            }
+            if !cmd.Flags().Changed("audit-log") && sc.AuditLog != "" {
+                auditLog = config.ExpandHome(sc.AuditLog)
+            }
+            if !cmd.Flags().Changed("user-tokens") && sc.UserTokens != "" {
+                userTokens = sc.UserTokens
+            }
+            users, err := newUserClients(sg.New(), userTokens)
+            if err != nil {
+                return err
+            }
+
+            var keys []server.Key
+            for _, k := range sc.Keys {
+                secret := k.Secret()
+                if secret == "" {
+                    fmt.Fprint(os.Stderr, i18n.Sprintf("警告: serve.keys 中的 %s 没有密钥（key 或 key_env 为空），已忽略\n", k.Name))
+                    continue
+                }
+                commands := k.Commands
+                if len(commands) == 0 {
+                    commands = []string{"search"}
+                }
+                keys = append(keys, server.Key{Name: k.Name, Secret: secret, RatePerMinute: k.RatePerMinute, Commands: commands})
+            }
+            if noAuth {
+                if !loopbackAddr(addr) {
+                    return i18n.Errorf("--no-auth 只能监听回环地址（如 127.0.0.1:7070），当前为 %s", addr)
+                }
+            } else if len(keys) == 0 {
+                p, _ := config.Path()
+                return i18n.Errorf("未配置可用的 API key，请在 %s 的 serve.keys 中添加；本机试用可加 --no-auth", p)
+            }
+
+            audit, auditLog, err := openAuditLog(auditLog)
+            if err != nil {
+                return err
+            }
+            defer audit.Close()
+
+            guard := server.New(server.Options{Keys: keys, Origins: sc.CORSOrigins, Audit: audit, NoAuth: noAuth})
+            api := http.NewServeMux()
+            api.HandleFunc("POST /api/search", func(w http.ResponseWriter, r *http.Request) { serveSearch(w, r, users) })
+            api.HandleFunc("POST /api/run", func(w http.ResponseWriter, r *http.Request) { serveRun(w, r, users, timeout) })
+            mux := http.NewServeMux()
+            mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+                server.JSON(w, http.StatusOK, map[string]string{"status": "ok", "version": version.Get().Version})
+            })
+            mux.Handle("/api/", guard.Wrap(api))
+
+            ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+            defer stop()
+            srv := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+            errc := make(chan error, 1)
+            go func() { errc <- srv.ListenAndServe() }()
+            fmt.Fprint(os.Stderr, i18n.Sprintf("监听 http://%s（%d 个 API key，用户 token %s，审计日志 %s）\n", addr, len(keys), users.mode, auditLog))
+            select {
+            case err := <-errc:
+                return err
+            case <-ctx.Done():
+            }
+            shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+            defer cancel()
+            if err := srv.Shutdown(shutdownCtx); err != nil {
+                return err
+            }
+            if err := <-errc; !errors.Is(err, http.ErrServerClosed) {
+                return err
+            }
+            return nil
+        },
+    }
+
+    cmd.Flags().StringVar(&addr, "addr", "127.0.0.1:7070", "监听地址（默认取配置文件 serve.addr）")
+    cmd.Flags().StringVar(&auditLog, "audit-log", "", `审计日志路径，"-" 为 stderr（默认 <用户缓存目录>/insight/serve-audit.jsonl）`)
+    cmd.Flags().DurationVar(&timeout, "timeout", 2*time.Minute, "/api/run 单条命令的超时")
+    cmd.Flags().BoolVar(&noAuth, "no-auth", false, "不校验 API key（只允许监听回环地址）")
+    cmd.Flags().StringVar(&userTokens, "user-tokens", "off", "调用方自带的 X-Sourcegraph-Token：off|allow|require（默认取配置文件 serve.user_tokens）")
+    return cmd
+}
+
+// openAuditLog 以追加方式打开审计日志并返回实际路径；path 为空时使用缓存目录下的默认位置
+func openAuditLog(path string) (io.WriteCloser, string, error) {
+    if path == "-" {
+        return nopCloser{os.Stderr}, "stderr", nil
+    }
+    if path == "" {
+        dir, err := os.UserCacheDir()
+        if err != nil {
+            return nil, "", err
+        }
+        path = filepath.Join(dir, "insight", "serve-audit.jsonl")
+    }
+    if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+        return nil, "", err
+    }
+    f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+    return f, path, err
+}
+
+type nopCloser struct{ io.Writer }
+
+func (nopCloser) Close() error { return nil }
+
+func loopbackAddr(addr string) bool {
+    host, _, err := net.SplitHostPort(addr)
+    if err != nil {
+        return false
+    }
+    if host == "localhost" {
+        return true
+    }
+    ip := net.ParseIP(host)
+    return ip != nil && ip.IsLoopback()
+}
+
+func serveSearch(w http.ResponseWriter, r *http.Request, users *userClients) {
+    var in struct {
+        Query   string `json:"query"`
+        Pattern string `json:"pattern"`
+    }
+    if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&in); err != nil || strings.TrimSpace(in.Query) == "" {
+        server.Error(w, http.StatusBadRequest, `body must be {"query": "...", "pattern": "literal|regexp|structural"}`)
+        return
+    }
+    if !server.Allow(r, "search", in.Query) {
+        server.Error(w, http.StatusForbidden, `API key is not allowed to call "search"`)
+        return
+    }
+    if in.Pattern == "" {
+        in.Pattern = "literal"
+    }
+    c, _, ok := users.client(w, r)
+    if !ok {
+        return
+    }
+    res, err := c.Search(r.Context(), in.Query, in.Pattern)
+    if err != nil {
+        server.Error(w, http.StatusBadGateway, err.Error())
+        return
+    }
+    server.JSON(w, http.StatusOK, res)
+}
+
+// serveAllowedCommands 是 /api/run 能调用的命令：只读的搜索与报告命令，位置参数都是查询、仓库名或仓库内路径，
+// 不读写服务端的文件、不执行外部命令、也不以服务端的身份发信或调用 GitHub/GitLab。
+// key 的 commands 为 "*" 时也只能在这些命令中选
+var serveAllowedCommands = map[string]bool{
+    "find": true, "usage-examples": true, "repos": true, "tree": true, "compare-revs": true, "compare-repos": true,
+    "changelog": true, "count-loc-remote": true, "deadcode": true, "drift": true, "dupes": true, "grep-archive": true,
+    "migrate-plan": true, "testmap": true, "todos": true, "vulns": true, "bigfiles": true, "modgraph": true,
+    "context": true, "schema dump": true, "ws list": true, "version": true,
+}
+
+// serveDeniedFlags 是 /api/run 不能带的 flag：它们的值是服务端的路径（读取或写入），--hook 还会执行脚本，
+// --create-issues、--summarize 会以服务端的 token 或模型密钥对外调用
+var serveDeniedFlags = map[string]bool{
+    "output": true, "out": true, "export": true, "hook": true, "state": true, "transcript": true,
+    "baseline": true, "write-baseline": true, "checkpoint": true, "trend-db": true, "queries": true,
+    "report": true, "repos-file": true, "message-template": true, "vars": true, "create-issues": true, "summarize": true,
+}
+
+// deniedFlag 返回 args 中第一个被禁止的 flag，没有时返回空串；按 cmd 的 flag 定义跳过参数值，
+// 以 - 开头的值（如 --query -o）不会被误判。"--" 之后的参数无法检查，一律拒绝
+func deniedFlag(cmd *cobra.Command, args []string) string {
+    lookup := func(name string) *pflag.Flag {
+        if f := cmd.Flags().Lookup(name); f != nil {
+            return f
+        }
+        return cmd.InheritedFlags().Lookup(name)
+    }
+    shorthand := func(c string) *pflag.Flag {
+        if f := cmd.Flags().ShorthandLookup(c); f != nil {
+            return f
+        }
+        return cmd.InheritedFlags().ShorthandLookup(c)
+    }
+    for i := 0; i < len(args); i++ {
+        a := args[i]
+        switch {
+        case a == "--":
+            return "--"
+        case strings.HasPrefix(a, "--"):
+            name, _, inline := strings.Cut(a[2:], "=")
+            f := lookup(name)
+            if f == nil {
+                continue
+            }
+            if serveDeniedFlags[f.Name] {
+                return "--" + f.Name
+            }
+            if !inline && f.NoOptDefVal == "" {
+                i++
+            }
+        case strings.HasPrefix(a, "-") && len(a) > 1:
+            // -fjson、-qo 这类合写的短 flag：遇到要取值的 flag 时，其后的部分（或下一个参数）是它的值
+            for j := 1; j < len(a); j++ {
+                f := shorthand(a[j : j+1])
+                if f == nil {
+                    break
+                }
+                if serveDeniedFlags[f.Name] {
+                    return "-" + f.Shorthand
+                }
+                if f.NoOptDefVal == "" {
+                    if j == len(a)-1 {
+                        i++
+                    }
+                    break
+                }
+            }
+        }
+    }
+    return ""
+}
+
+// serveRun 以子进程执行 kb 命令，避免与其他请求共享 cobra 的全局 flag 状态；
+// 调用方带了自己的 token 时通过 SG_TOKEN 交给子进程
+func serveRun(w http.ResponseWriter, r *http.Request, users *userClients, timeout time.Duration) {
+    var in struct {
+        Args []string `json:"args"`
+    }
+    if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&in); err != nil || len(in.Args) == 0 || strings.HasPrefix(in.Args[0], "-") {
+        server.Error(w, http.StatusBadRequest, `body must be {"args": ["<command>", ...]}`)
+        return
+    }
+    found, _, err := rootCmd.Find(in.Args)
+    if err != nil || found == rootCmd {
+        server.Error(w, http.StatusBadRequest, fmt.Sprintf("unknown command %q", in.Args[0]))
+        return
+    }
+    command := strings.TrimPrefix(found.CommandPath(), rootCmd.Name()+" ")
+    if !server.Allow(r, command, strings.Join(in.Args, " ")) || !serveAllowedCommands[command] {
+        server.Error(w, http.StatusForbidden, fmt.Sprintf("API key is not allowed to call %q", command))
+        return
+    }
+    if f := deniedFlag(found, in.Args); f != "" {
+        server.Error(w, http.StatusForbidden, fmt.Sprintf("argument %s is not allowed in /api/run", f))
+        return
+    }
+    _, token, ok := users.client(w, r)
+    if !ok {
+        return
+    }
+    exe, err := os.Executable()
+    if err != nil {
+        server.Error(w, http.StatusInternalServerError, err.Error())
+        return
+    }
+    ctx, cancel := context.WithTimeout(r.Context(), timeout)
+    defer cancel()
+    started := time.Now()
+    var stdout, stderr limitedBuffer
+    c := exec.CommandContext(ctx, exe, in.Args...)
+    c.Stdout, c.Stderr = &stdout, &stderr
+    if token != "" {
+        c.Env = append(os.Environ(), "SG_TOKEN="+token)
+    }
+    err = c.Run()
+    out := runResult{
+        Stdout: stdout.String(), Stderr: stderr.String(), Truncated: stdout.truncated || stderr.truncated,
+        DurationMS: time.Since(started).Milliseconds(),
+    }
+    var exitErr *exec.ExitError
+    switch {
+    case ctx.Err() == context.DeadlineExceeded:
+        server.Error(w, http.StatusGatewayTimeout, fmt.Sprintf("command timed out after %s", timeout))
+        return
+    case errors.As(err, &exitErr):
+        out.ExitCode = exitErr.ExitCode()
+    case err != nil:
+        server.Error(w, http.StatusInternalServerError, err.Error())
+        return
+    }
+    server.JSON(w, http.StatusOK, out)
+}
+
+// limitedBuffer 只保留前 serveMaxOutput 字节，超出部分丢弃
+type limitedBuffer struct {
+    bytes.Buffer
+    truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+    if n := serveMaxOutput - b.Len(); len(p) > n {
+        b.truncated = true
+        b.Buffer.Write(p[:max(n, 0)])
+        return len(p), nil
+    }
+    return b.Buffer.Write(p)
+}
+
+func init() { rootCmd.AddCommand(newServeCmd()) }
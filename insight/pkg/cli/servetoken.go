@@ -0,0 +1,118 @@
+package cli
+
+import (
+    "crypto/sha256"
+    "errors"
+    "net/http"
+    "strings"
+    "sync"
+    "time"
+
+    "kingbrain/insight/pkg/i18n"
+    "kingbrain/insight/pkg/server"
+    "kingbrain/insight/pkg/sg"
+)
+
+// tokenHeader 是调用方带上自己 Sourcegraph token 的请求头
+const tokenHeader = "X-Sourcegraph-Token"
+
+const (
+    // userTokenTTL 是一个 token 校验结果的有效期，过期后重新查询 currentUser，及时发现吊销的 token
+    userTokenTTL = 5 * time.Minute
+    // maxUserTokens 是最多缓存的 token 数，超出时淘汰最久没有使用的
+    maxUserTokens = 1000
+)
+
+// userClient 是一个调用方 token 对应的 Client 及其 Sourcegraph 用户名
+type userClient struct {
+    c       *sg.Client
+    user    string
+    checked time.Time
+    used    time.Time
+}
+
+// userClients 按调用方的 Sourcegraph token 缓存 Client：mode 为 off 时只用服务自己的 token，
+// allow 时请求可以带 X-Sourcegraph-Token，require 时必须带。缓存以 token 的 SHA-256 为键
+type userClients struct {
+    base *sg.Client
+    mode string
+
+    mu sync.Mutex
+    m  map[[32]byte]*userClient
+}
+
+func newUserClients(base *sg.Client, mode string) (*userClients, error) {
+    switch mode {
+    case "":
+        mode = "off"
+    case "off", "allow", "require":
+    default:
+        return nil, i18n.Errorf("--user-tokens（serve.user_tokens）应为 off|allow|require，当前为 %q", mode)
+    }
+    return &userClients{base: base, mode: mode, m: map[[32]byte]*userClient{}}, nil
+}
+
+// client 返回处理请求应使用的 Client 与调用方的 token（没有带时为空）；
+// 失败时已写好错误响应并返回 ok=false
+func (u *userClients) client(w http.ResponseWriter, r *http.Request) (c *sg.Client, token string, ok bool) {
+    token = strings.TrimSpace(r.Header.Get(tokenHeader))
+    switch {
+    case token == "" && u.mode == "require":
+        server.Error(w, http.StatusUnauthorized, tokenHeader+" is required: this server only acts on behalf of the caller's own Sourcegraph token")
+        return nil, "", false
+    case token == "":
+        return u.base, "", true
+    case u.mode == "off":
+        server.Error(w, http.StatusBadRequest, tokenHeader+" is not accepted by this server (serve.user_tokens is off)")
+        return nil, "", false
+    }
+
+    key := sha256.Sum256([]byte(token))
+    now := time.Now()
+    u.mu.Lock()
+    e := u.m[key]
+    if e != nil && now.Sub(e.checked) < userTokenTTL {
+        e.used = now
+        u.mu.Unlock()
+        server.SetUser(r, e.user)
+        return e.c, token, true
+    }
+    u.mu.Unlock()
+
+    // 校验在锁外进行；同一个 token 的并发请求可能各查一次，结果相同
+    c = u.base.WithToken(token)
+    user, err := c.CurrentUser(r.Context())
+    var se *sg.StatusError
+    switch {
+    case errors.As(err, &se) && (se.StatusCode == http.StatusUnauthorized || se.StatusCode == http.StatusForbidden), err == nil && user == "":
+        u.mu.Lock()
+        delete(u.m, key)
+        u.mu.Unlock()
+        server.Error(w, http.StatusUnauthorized, "invalid Sourcegraph token in "+tokenHeader)
+        return nil, "", false
+    case err != nil:
+        server.Error(w, http.StatusBadGateway, err.Error())
+        return nil, "", false
+    }
+    server.SetUser(r, user)
+
+    u.mu.Lock()
+    defer u.mu.Unlock()
+    if e == nil && len(u.m) >= maxUserTokens {
+        u.evict()
+    }
+    u.m[key] = &userClient{c: c, user: user, checked: now, used: now}
+    return c, token, true
+}
+
+// evict 淘汰最久没有使用的一个 token，调用方持有 u.mu
+func (u *userClients) evict() {
+    var oldest [32]byte
+    var at time.Time
+    for k, e := range u.m {
+        if at.IsZero() || e.used.Before(at) {
+            oldest, at = k, e.used
+        }
+    }
+    delete(u.m, oldest)
+}
@@ -0,0 +1,138 @@
+package cli
+
+import (
+    "context"
+    "fmt"
+    "io"
+    "os"
+    "strings"
+
+    "kingbrain/insight/pkg/config"
+    "kingbrain/insight/pkg/export"
+    "kingbrain/insight/pkg/i18n"
+    "kingbrain/insight/pkg/llm"
+)
+
+const (
+    defaultLLMInputTokens  = 6000
+    defaultLLMOutputTokens = 1024
+)
+
+const summarizePrompt = `你是代码搜索结果的分析助手。用户会给出一条 Sourcegraph 查询和匹配到的代码行，
+每行以 repo/path:line 开头，相同内容的行已合并并注明出现次数。
+请用中文总结这些匹配体现出的用法模式：归纳成几类，说明每类的典型写法和大致占比，
+指出不一致、过时或可疑的用法；每类给出一两个 repo/path:line 作为例子。
+只依据给出的内容，不要编造；内容被截断时说明结论只基于部分结果。`
+
+// llmSession 是按配置文件 llm 段创建的模型客户端、脱敏规则与 token 上限
+type llmSession struct {
+    client   *llm.Client
+    redactor *llm.Redactor
+    maxIn    int
+    maxOut   int
+    redacted int // 累计脱敏处数
+}
+
+func newLLMSession() (*llmSession, error) {
+    cfg, err := config.Load()
+    if err != nil {
+        return nil, err
+    }
+    l := cfg.LLM
+    if l.URL == "" || l.Model == "" {
+        p, _ := config.Path()
+        return nil, i18n.Errorf("未配置模型接口，请在 %s 中设置 llm.url 与 llm.model（OpenAI 兼容接口）", p)
+    }
+    keyEnv := l.APIKeyEnv
+    if keyEnv == "" {
+        keyEnv = "OPENAI_API_KEY"
+    }
+    red, err := llm.NewRedactor(l.Redact)
+    if err != nil {
+        return nil, err
+    }
+    s := &llmSession{
+        client:   llm.New(l.URL, l.Model, os.Getenv(keyEnv)),
+        redactor: red,
+        maxIn:    l.MaxInputTokens,
+        maxOut:   l.MaxOutputTokens,
+    }
+    if s.maxIn <= 0 {
+        s.maxIn = defaultLLMInputTokens
+    }
+    if s.maxOut <= 0 {
+        s.maxOut = defaultLLMOutputTokens
+    }
+    return s, nil
+}
+
+func (s *llmSession) redact(text string) string {
+    text, n := s.redactor.Redact(text)
+    s.redacted += n
+    return text
+}
+
+// summarizeMatches 把匹配行（脱敏、去重、按 token 上限截断后）发给模型，把摘要写到 w
+func summarizeMatches(ctx context.Context, w io.Writer, query string, matches []export.Match) error {
+    if len(matches) == 0 {
+        return nil
+    }
+    s, err := newLLMSession()
+    if err != nil {
+        return err
+    }
+
+    // 相同内容的行只发送第一处，后面注明次数
+    type group struct {
+        first export.Match
+        n     int
+    }
+    var groups []*group
+    byText := map[string]*group{}
+    for _, m := range matches {
+        text := strings.TrimSpace(m.Preview)
+        if g, ok := byText[text]; ok {
+            g.n++
+            continue
+        }
+        g := &group{first: m, n: 1}
+        byText[text] = g
+        groups = append(groups, g)
+    }
+
+    head := i18n.Sprintf("查询: %s\n匹配行数: %d（不同内容 %d 种）\n\n", s.redact(query), len(matches), len(groups))
+    budget := s.maxIn - llm.EstimateTokens(summarizePrompt+head)
+    var body strings.Builder
+    sent := 0
+    for _, g := range groups {
+        m := g.first
+        line := fmt.Sprintf("%s/%s:%d: %s", m.Repo, m.Path, m.Line, strings.TrimSpace(m.Preview))
+        if g.n > 1 {
+            line += i18n.Sprintf("  （共 %d 处）", g.n)
+        }
+        line = s.redact(line) + "\n"
+        t := llm.EstimateTokens(line)
+        if t > budget {
+            break
+        }
+        budget -= t
+        body.WriteString(line)
+        sent++
+    }
+    if sent < len(groups) {
+        fmt.Fprint(os.Stderr, i18n.Sprintf("警告: 受 token 上限（%d）限制，只发送了 %d/%d 种匹配内容\n", s.maxIn, sent, len(groups)))
+    }
+    if s.redacted > 0 {
+        fmt.Fprint(os.Stderr, i18n.Sprintf("已脱敏 %d 处疑似密钥/口令\n", s.redacted))
+    }
+    fmt.Fprintln(os.Stderr, i18n.T("正在生成摘要..."))
+    summary, err := s.client.Chat(ctx, []llm.Message{
+        {Role: "system", Content: summarizePrompt},
+        {Role: "user", Content: head + body.String()},
+    }, s.maxOut)
+    if err != nil {
+        return err
+    }
+    fmt.Fprintf(w, "Summary:\n\n%s\n", summary)
+    return nil
+}
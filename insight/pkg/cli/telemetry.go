@@ -0,0 +1,157 @@
+package cli
+
+import (
+    "fmt"
+    "os"
+    "sort"
+    "strings"
+    "time"
+
+    "github.com/spf13/cobra"
+    "github.com/spf13/pflag"
+    "kingbrain/insight/pkg/config"
+    "kingbrain/insight/pkg/i18n"
+    "kingbrain/insight/pkg/telemetry"
+)
+
+// telemetryEnabled 判断是否记录使用统计：INSIGHT_TELEMETRY=0/1 优先于配置文件
+func telemetryEnabled(cfg *config.Config) bool {
+    switch os.Getenv("INSIGHT_TELEMETRY") {
+    case "0", "false", "off":
+        return false
+    case "1", "true", "on":
+        return true
+    }
+    return cfg.Telemetry.Enabled
+}
+
+// recordTelemetry 在命令结束后记录一条事件；任何失败都静默忽略，不影响命令本身
+func recordTelemetry(cmd *cobra.Command, started time.Time, err error) {
+    if cmd == nil || strings.HasPrefix(cmd.CommandPath(), rootCmd.Name()+" telemetry") {
+        return
+    }
+    cfg, cerr := config.Load()
+    if cerr != nil || !telemetryEnabled(cfg) {
+        return
+    }
+    var flags []string
+    cmd.Flags().Visit(func(f *pflag.Flag) { flags = append(flags, f.Name) })
+    _ = telemetry.Record(telemetry.Event{
+        Time:       started.UTC(),
+        Command:    cmd.CommandPath(),
+        Flags:      flags,
+        DurationMS: time.Since(started).Milliseconds(),
+        OK:         err == nil,
+    })
+}
+
+func newTelemetryCmd() *cobra.Command {
+    cmd := &cobra.Command{
+        Use:   "telemetry",
+        Short: "本地使用统计（默认关闭）：开启/关闭、查看报告、推送到内部端点",
+    }
+
+    setEnabled := func(on bool) func(*cobra.Command, []string) error {
+        return func(_ *cobra.Command, _ []string) error {
+            cfg, err := config.Load()
+            if err != nil {
+                return err
+            }
+            cfg.Telemetry.Enabled = on
+            commented := config.HasComments()
+            if err := cfg.Save(); err != nil {
+                return err
+            }
+            if on {
+                p, _ := telemetry.Path()
+                fmt.Print(i18n.Sprintf("已开启使用统计，只记录命令名、flag 名与耗时，保存在 %s\n", p))
+            } else {
+                fmt.Println(i18n.T("已关闭使用统计（本地数据保留，可用 telemetry reset 删除）"))
+            }
+            if commented {
+                fmt.Print(i18n.T("注意: 配置文件按当前设置重新生成，原有的注释没有保留\n"))
+            }
+            return nil
+        }
+    }
+
+    var format string
+    report := &cobra.Command{
+        Use:   "report",
+        Short: "汇总本地记录：各命令调用次数、错误数、延迟分位数与常用 flag",
+        RunE: func(_ *cobra.Command, _ []string) error {
+            if err := checkFormat(format, "text", "json"); err != nil {
+                return err
+            }
+            events, err := telemetry.Load()
+            if err != nil {
+                return err
+            }
+            r := telemetry.Aggregate(events)
+            if format == "json" {
+                return writeJSON(os.Stdout, r)
+            }
+            if r.Events == 0 {
+                fmt.Println(i18n.T("没有记录（用 telemetry enable 开启）"))
+                return nil
+            }
+            fmt.Print(i18n.Sprintf("自 %s 起共 %d 次调用\n\n", r.Since.Local().Format("2006-01-02"), r.Events))
+            fmt.Printf("%-28s %6s %6s %8s %8s %8s  %s\n", "COMMAND", "COUNT", "ERRORS", "P50", "P90", "P99", "FLAGS")
+            for _, c := range r.Commands {
+                fmt.Printf("%-28s %6d %6d %7dms %7dms %7dms  %s\n", c.Command, c.Count, c.Errors, c.P50, c.P90, c.P99, topFlags(c.Flags, 5))
+            }
+            return nil
+        },
+    }
+    report.Flags().StringVarP(&format, "format", "f", "text", "输出格式：text|json")
+
+    push := &cobra.Command{
+        Use:   "push",
+        Short: "把聚合后的报告推送到配置的 telemetry.endpoint",
+        RunE: func(_ *cobra.Command, _ []string) error {
+            cfg, err := config.Load()
+            if err != nil {
+                return err
+            }
+            if cfg.Telemetry.Endpoint == "" {
+                return i18n.Errorf("未配置 telemetry.endpoint")
+            }
+            events, err := telemetry.Load()
+            if err != nil {
+                return err
+            }
+            if err := telemetry.Push(cfg.Telemetry.Endpoint, telemetry.Aggregate(events)); err != nil {
+                return err
+            }
+            fmt.Print(i18n.Sprintf("已推送 %d 条记录的汇总\n", len(events)))
+            return nil
+        },
+    }
+
+    cmd.AddCommand(
+        &cobra.Command{Use: "enable", Short: "开启使用统计", RunE: setEnabled(true)},
+        &cobra.Command{Use: "disable", Short: "关闭使用统计", RunE: setEnabled(false)},
+        report,
+        push,
+        &cobra.Command{Use: "reset", Short: "删除本地记录", RunE: func(_ *cobra.Command, _ []string) error { return telemetry.Reset() }},
+    )
+    return cmd
+}
+
+// topFlags 按使用次数列出前 n 个 flag
+func topFlags(flags map[string]int, n int) string {
+    names := make([]string, 0, len(flags))
+    for k := range flags {
+        names = append(names, k)
+    }
+    sort.Slice(names, func(i, j int) bool { return flags[names[i]] > flags[names[j]] })
+    if len(names) > n {
+        names = names[:n]
+    }
+    for i, k := range names {
+        names[i] = fmt.Sprintf("--%s(%d)", k, flags[k])
+    }
+    return strings.Join(names, " ")
+}
+
+func init() { rootCmd.AddCommand(newTelemetryCmd()) }
@@ -0,0 +1,320 @@
+package cli
+
+import (
+    "fmt"
+    "os"
+    "path/filepath"
+    "regexp"
+    "strings"
+
+    "github.com/spf13/cobra"
+    "kingbrain/insight/pkg/config"
+    "kingbrain/insight/pkg/i18n"
+    "kingbrain/insight/pkg/sg"
+    "kingbrain/insight/pkg/templates"
+)
+
+// templateDirs 返回查找模板的目录：先是本地的 <配置目录>/insight/templates，再是同步下来的共享模板仓库
+func templateDirs(cfg *config.Config) ([]string, error) {
+    p, err := config.Path()
+    if err != nil {
+        return nil, err
+    }
+    dirs := []string{filepath.Join(filepath.Dir(p), "templates")}
+    if cfg.Templates.Repo != "" {
+        clone, err := templateClone(cfg.Templates)
+        if err != nil {
+            return nil, err
+        }
+        dirs = append(dirs, filepath.Join(clone, filepath.FromSlash(cfg.Templates.Dir)))
+    }
+    return dirs, nil
+}
+
+var cloneNameRe = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// templateClone 返回共享模板仓库的本地克隆目录：<用户缓存目录>/insight/templates/<仓库地址>
+func templateClone(t config.Templates) (string, error) {
+    dir, err := os.UserCacheDir()
+    if err != nil {
+        return "", err
+    }
+    return filepath.Join(dir, "insight", "templates", cloneNameRe.ReplaceAllString(t.Repo, "_")), nil
+}
+
+// syncTemplates 克隆或更新共享模板仓库，返回当前的提交
+func syncTemplates(t config.Templates) (string, error) {
+    if t.Repo == "" {
+        return "", i18n.Errorf("配置文件中没有 templates.repo")
+    }
+    clone, err := templateClone(t)
+    if err != nil {
+        return "", err
+    }
+    if !isDir(filepath.Join(clone, ".git")) {
+        if err := os.MkdirAll(filepath.Dir(clone), 0o755); err != nil {
+            return "", err
+        }
+        args := []string{"clone", "--depth", "1"}
+        if t.Ref != "" {
+            args = append(args, "--branch", t.Ref)
+        }
+        if _, err := gitOutput(filepath.Dir(clone), append(args, t.Repo, clone)...); err != nil {
+            return "", err
+        }
+    } else {
+        ref := t.Ref
+        if ref == "" {
+            ref = "HEAD"
+        }
+        if _, err := gitOutput(clone, "fetch", "--depth", "1", "origin", ref); err != nil {
+            return "", err
+        }
+        if _, err := gitOutput(clone, "reset", "--hard", "FETCH_HEAD"); err != nil {
+            return "", err
+        }
+    }
+    return gitOutput(clone, "rev-parse", "--short", "HEAD")
+}
+
+// findTemplate 按名字查找模板；配置了共享仓库但还没有同步过时先同步一次
+func findTemplate(name string) (*templates.Template, error) {
+    cfg, err := config.Load()
+    if err != nil {
+        return nil, err
+    }
+    dirs, err := templateDirs(cfg)
+    if err != nil {
+        return nil, err
+    }
+    t, err := templates.Find(dirs, name)
+    if err != nil || t != nil {
+        return t, err
+    }
+    if cfg.Templates.Repo != "" && !isDir(dirs[len(dirs)-1]) {
+        fmt.Fprint(os.Stderr, i18n.Sprintf("同步共享模板仓库 %s\n", cfg.Templates.Repo))
+        if _, err := syncTemplates(cfg.Templates); err != nil {
+            return nil, err
+        }
+        if t, err = templates.Find(dirs, name); err != nil || t != nil {
+            return t, err
+        }
+    }
+    return nil, i18n.Errorf("没有名为 %s 的模板（见 kb template list）", name)
+}
+
+// parseVars 解析重复的 --var K=V
+func parseVars(specs []string) (map[string]string, error) {
+    vars := map[string]string{}
+    for _, s := range specs {
+        k, v, ok := strings.Cut(s, "=")
+        if !ok || k == "" {
+            return nil, i18n.Errorf("--var 格式应为 name=value：%q", s)
+        }
+        vars[k] = v
+    }
+    return vars, nil
+}
+
+func newRunTemplateCmd() *cobra.Command {
+    var (
+        vars     []string
+        pattern  string
+        exports  []string
+        format   string
+        nul      bool
+        dryRun   bool
+        failHit  bool
+        failNone bool
+    )
+    cmd := &cobra.Command{
+        Use:   "run-template <name>",
+        Short: "代入参数渲染搜索模板并执行",
+        Long: `模板是 <配置目录>/insight/templates/ 或共享模板仓库（配置中的 templates，见 kb template）下的 YAML 文件，
+名字为去掉 .yaml 的相对路径。--var 逐个给出参数，没有给出的取默认值；输出与退出码同 kb find。
+
+  # templates/deps/go-module.yaml
+  description: 查找依赖某个 Go 模块特定版本的服务
+  pattern: regexp
+  query: 'repo:{{.Service}} file:go\.mod {{re .Module}} v{{re .Version}}'
+  params:
+    - {name: Service, default: '.*'}
+    - {name: Module, required: true}
+    - {name: Version, required: true}
+
+  kb run-template deps/go-module --var Module=github.com/pkg/errors --var Version=0.9.1
+  kb run-template deps/go-module --var Service=payments --var Module=golang.org/x/net --var Version=0.17 -n`,
+        Args: cobra.ExactArgs(1),
+        RunE: func(cmd *cobra.Command, args []string) error {
+            if failHit && failNone {
+                return i18n.Errorf("--fail-if-matches 与 --fail-if-none 不能同时使用")
+            }
+            t, err := findTemplate(args[0])
+            if err != nil {
+                return err
+            }
+            values, err := parseVars(vars)
+            if err != nil {
+                return err
+            }
+            q, err := t.Render(values)
+            if err != nil {
+                return err
+            }
+            if !cmd.Flags().Changed("pattern") && t.Pattern != "" {
+                pattern = t.Pattern
+            }
+            if dryRun {
+                fmt.Println(q)
+                return nil
+            }
+            out, err := newMatchPrinter(format, nul, false)
+            if err != nil {
+                return err
+            }
+            out.hide = hideGenerated
+            run := newRun("run-template "+t.Name, q)
+            if err := findStream(cmd.Context(), sg.New(), out, run, q, pattern, len(exports) > 0); err != nil {
+                return err
+            }
+            out.done()
+            if err := runExports(exports, run); err != nil {
+                return err
+            }
+            return matchExit(cmd, out.matched, failHit, failNone)
+        },
+    }
+    cmd.Flags().StringArrayVar(&vars, "var", nil, "模板参数 name=value（可重复）")
+    cmd.Flags().StringVarP(&pattern, "pattern", "p", "literal", "搜索模式，默认取模板中的 pattern：literal|regexp|structural")
+    cmd.Flags().StringVarP(&format, "format", "f", "", "输出格式：text|lines|json|paths（默认终端为 text，管道为 lines）")
+    cmd.Flags().BoolVarP(&nul, "null", "0", false, "只输出文件路径，以 NUL 分隔（配合 xargs -0）")
+    cmd.Flags().BoolVarP(&dryRun, "dry-run", "n", false, "只打印渲染出的查询，不执行")
+    cmd.Flags().BoolVar(&failHit, "fail-if-matches", false, "有匹配时以退出码 1 结束（没有匹配为 0）")
+    cmd.Flags().BoolVar(&failNone, "fail-if-none", false, "没有匹配时以退出码 1 结束，并在 stderr 说明（默认行为的显式写法）")
+    addExportFlag(cmd, &exports)
+    return cmd
+}
+
+func newTemplateCmd() *cobra.Command {
+    cmd := &cobra.Command{
+        Use:   "template",
+        Short: "列出、查看与同步搜索模板（执行见 kb run-template）",
+        Long: `本地模板放在 <配置目录>/insight/templates/；团队共享的模板放在一个 git 仓库中，在配置文件里指定：
+
+  templates:
+    repo: git@github.com:acme/insight-templates.git
+    ref: main        # 可选，默认为仓库的默认分支
+    dir: templates   # 可选，模板在仓库中的子目录
+
+kb template sync 克隆或更新到 <用户缓存目录>/insight/templates/；本地模板与共享模板同名时取本地的。`,
+    }
+    cmd.AddCommand(newTemplateListCmd(), newTemplateShowCmd(), newTemplateSyncCmd())
+    return cmd
+}
+
+func newTemplateListCmd() *cobra.Command {
+    var format string
+    cmd := &cobra.Command{
+        Use:   "list",
+        Short: "列出可用的模板",
+        Args:  cobra.NoArgs,
+        RunE: func(cmd *cobra.Command, _ []string) error {
+            if err := checkFormat(format, "text", "json"); err != nil {
+                return err
+            }
+            cfg, err := config.Load()
+            if err != nil {
+                return err
+            }
+            dirs, err := templateDirs(cfg)
+            if err != nil {
+                return err
+            }
+            list, err := templates.List(dirs)
+            if err != nil {
+                return err
+            }
+            if format == "json" {
+                return writeJSON(os.Stdout, list)
+            }
+            if len(list) == 0 {
+                fmt.Fprint(os.Stderr, i18n.Sprintf("没有模板；把 YAML 模板放到 %s，或在配置中设置 templates.repo 后运行 kb template sync\n", dirs[0]))
+                return nil
+            }
+            width := 0
+            for _, t := range list {
+                width = max(width, len(t.Name))
+            }
+            for _, t := range list {
+                fmt.Printf("%-*s  %s\n", width, t.Name, t.Description)
+            }
+            return nil
+        },
+    }
+    cmd.Flags().StringVarP(&format, "format", "f", "text", "输出格式：text|json")
+    return cmd
+}
+
+func newTemplateShowCmd() *cobra.Command {
+    return &cobra.Command{
+        Use:   "show <name>",
+        Short: "显示模板的查询与参数",
+        Args:  cobra.ExactArgs(1),
+        RunE: func(cmd *cobra.Command, args []string) error {
+            t, err := findTemplate(args[0])
+            if err != nil {
+                return err
+            }
+            fmt.Println(t.Name)
+            if t.Description != "" {
+                fmt.Println(t.Description)
+            }
+            fmt.Print(i18n.Sprintf("\n文件: %s\n", t.Source))
+            if t.Pattern != "" {
+                fmt.Print(i18n.Sprintf("模式: %s\n", t.Pattern))
+            }
+            fmt.Print(i18n.Sprintf("查询: %s\n", strings.TrimSpace(t.Query)))
+            if len(t.Params) > 0 {
+                fmt.Print(i18n.Sprintf("\n参数:\n"))
+                for _, p := range t.Params {
+                    line := "  " + p.Name
+                    switch {
+                    case p.Default != "":
+                        line += i18n.Sprintf("（默认 %s）", p.Default)
+                    case p.Required:
+                        line += i18n.T("（必填）")
+                    }
+                    if p.Description != "" {
+                        line += "  " + p.Description
+                    }
+                    fmt.Println(line)
+                }
+            }
+            return nil
+        },
+    }
+}
+
+func newTemplateSyncCmd() *cobra.Command {
+    return &cobra.Command{
+        Use:   "sync",
+        Short: "克隆或更新配置中的共享模板仓库",
+        Args:  cobra.NoArgs,
+        RunE: func(cmd *cobra.Command, _ []string) error {
+            cfg, err := config.Load()
+            if err != nil {
+                return err
+            }
+            head, err := syncTemplates(cfg.Templates)
+            if err != nil {
+                return err
+            }
+            fmt.Fprint(os.Stderr, i18n.Sprintf("共享模板已更新到 %s（%s）\n", head, cfg.Templates.Repo))
+            return nil
+        },
+    }
+}
+
+func init() {
+    rootCmd.AddCommand(newRunTemplateCmd(), newTemplateCmd())
+}
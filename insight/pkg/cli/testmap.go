@@ -0,0 +1,421 @@
+package cli
+
+import (
+    "context"
+    "fmt"
+    "os"
+    "path"
+    "regexp"
+    "sort"
+    "strconv"
+    "strings"
+
+    "github.com/spf13/cobra"
+    "kingbrain/insight/pkg/i18n"
+    "kingbrain/insight/pkg/loc"
+    "kingbrain/insight/pkg/sg"
+)
+
+// testableLangs 是需要找测试的语言；配置、文档、脚本类文件不计入
+var testableLangs = map[string]bool{
+    "Go": true, "Python": true, "JavaScript": true, "TypeScript": true, "Java": true, "Kotlin": true, "C": true,
+    "C++": true, "C#": true, "Rust": true, "Swift": true, "Scala": true, "PHP": true, "Ruby": true,
+}
+
+// testStemRes 从测试文件名中去掉测试标记，得到被测文件的名字：foo_test.go、test_foo.py、
+// foo.spec.ts、FooTest.java、foo_spec.rb 都得到 foo
+var testStemRes = []*regexp.Regexp{
+    regexp.MustCompile(`^(.+)_(?:test|spec)\.\w+$`),
+    regexp.MustCompile(`^test_(.+)\.py$`),
+    regexp.MustCompile(`^(.+)\.(?:test|spec)\.[cm]?[jt]sx?$`),
+    regexp.MustCompile(`^(.+?)Tests?\.\w+$`),
+}
+
+// genericStems 是太常见、按名字做引用搜索只会带来误报的文件名
+var genericStems = map[string]bool{
+    "index": true, "main": true, "__init__": true, "init": true, "mod": true, "lib": true, "util": true,
+    "utils": true, "types": true, "constants": true, "const": true, "config": true, "errors": true, "doc": true,
+}
+
+// TestRef 是为源码文件找到的一个测试文件；Kind 为 name（按命名规则）、package（同目录的
+// Go 包测试）、ref（测试文件中引用了它）或 inline（Rust 文件内的 #[cfg(test)]）
+type TestRef struct {
+    Repo string `json:"repo"`
+    Path string `json:"path"`
+    Kind string `json:"kind"`
+}
+
+// TestMapEntry 是一个源码文件及为它找到的测试
+type TestMapEntry struct {
+    Repo  string    `json:"repo"`
+    Path  string    `json:"path"`
+    Dir   string    `json:"dir"`
+    Tests []TestRef `json:"tests,omitempty"`
+}
+
+func newTestMapCmd() *cobra.Command {
+    var (
+        rev       string
+        testRepos []string
+        exclude   []string
+        noRefs    bool
+        all       bool
+        format    string
+    )
+
+    cmd := &cobra.Command{
+        Use:   "testmap <repo> [path]...",
+        Short: "为源码文件找对应的测试文件，按目录列出找不到测试的文件",
+        Long: `path 为仓库内的文件或目录（包），默认整个仓库。先按命名规则在仓库内找测试：foo_test.go、
+test_foo.py、foo.test.ts、foo.spec.js、__tests__/foo.ts、FooTest.java（含 src/main → src/test 的镜像目录）、
+foo_spec.rb 等，同名的测试有多个时取目录最接近的。Go 文件没有同名测试但同目录有 _test.go 时记为包级测试。
+剩下的文件再按文件名搜索测试文件中的引用（import、类名），--test-repo 可加入存放集成测试的其他仓库
+（支持 * ? glob）；index、utils 这类太常见的文件名不做引用搜索。Rust 文件内的 #[cfg(test)] 也算作测试。
+
+这是启发式的结果：找到的测试不代表覆盖了文件中的代码，找不到也可能是测试的命名不合规则。
+默认只列出找不到测试的文件，--all 列出全部；只给一个文件时总是列出它的测试。
+
+  kb testmap github.com/acme/api services/billing
+  kb testmap github.com/acme/web src/cart/Cart.tsx
+  kb testmap github.com/acme/api --test-repo github.com/acme/api-e2e -f csv > untested.csv`,
+        Args: cobra.MinimumNArgs(1),
+        RunE: func(cmd *cobra.Command, args []string) error {
+            if err := checkFormat(format, "text", "csv", "json"); err != nil {
+                return err
+            }
+            ctx := cmd.Context()
+            c := sg.New()
+            repo, scopes := args[0], args[1:]
+            paths, err := c.Tree(ctx, repo, rev)
+            if err != nil {
+                return err
+            }
+            entries := testMapSources(repo, paths, scopes, exclude)
+            if len(entries) == 0 && len(scopes) > 0 {
+                return i18n.Errorf("%s 的 %s 中没有需要测试的源码文件", repo, strings.Join(scopes, i18n.T("、")))
+            }
+            if len(entries) == 0 {
+                return i18n.Errorf("%s 中没有需要测试的源码文件", repo)
+            }
+            matchTestNames(repo, entries, paths)
+            if !noRefs {
+                repos := []string{repo}
+                if len(testRepos) > 0 {
+                    extra, err := expandRepoNames(ctx, c, testRepos)
+                    if err != nil {
+                        return err
+                    }
+                    repos = append(repos, extra...)
+                }
+                if err := matchTestRefs(ctx, c, repos, entries); err != nil {
+                    return err
+                }
+                if err := matchInlineTests(ctx, c, repo, entries); err != nil {
+                    return err
+                }
+            }
+
+            single := len(scopes) == 1 && len(entries) == 1 && entries[0].Path == strings.Trim(scopes[0], "/")
+            if !all && !single {
+                kept := entries[:0]
+                for _, e := range entries {
+                    if len(e.Tests) == 0 {
+                        kept = append(kept, e)
+                    }
+                }
+                total := len(entries)
+                entries = kept
+                defer fmt.Fprint(os.Stderr, i18n.Sprintf("%d/%d 个源码文件找不到测试\n", len(entries), total))
+            }
+            switch format {
+            case "json":
+                return writeJSON(os.Stdout, entries)
+            case "csv":
+                var rows [][]string
+                for _, e := range entries {
+                    var tests, kinds []string
+                    for _, t := range e.Tests {
+                        tests = append(tests, testRefName(e.Repo, t))
+                        kinds = append(kinds, t.Kind)
+                    }
+                    rows = append(rows, []string{e.Repo, e.Dir, e.Path, strconv.Itoa(len(e.Tests)), strings.Join(tests, " "), strings.Join(kinds, " ")})
+                }
+                return writeCSV(os.Stdout, []string{"repo", "dir", "path", "tests", "test_files", "kinds"}, rows)
+            }
+            printTestMap(entries)
+            return nil
+        },
+    }
+
+    cmd.Flags().StringVar(&rev, "rev", "", "分支、标签或 commit（默认 HEAD）")
+    cmd.Flags().StringSliceVar(&testRepos, "test-repo", nil, "同时在这些仓库的测试文件中搜索引用（可重复，支持 * ? glob）")
+    cmd.Flags().StringSliceVar(&exclude, "exclude-dir", []string{"vendor", "node_modules", "testdata", "third_party"}, "跳过这些目录名")
+    cmd.Flags().BoolVar(&noRefs, "no-refs", false, "只按命名规则匹配，不做引用搜索")
+    cmd.Flags().BoolVar(&all, "all", false, "列出全部源码文件及找到的测试")
+    cmd.Flags().StringVarP(&format, "format", "f", "text", "输出格式：text|csv|json")
+    return cmd
+}
+
+// testMapSources 从文件树中取出 scopes 范围内需要测试的源码文件（测试文件本身不算）
+func testMapSources(repo string, paths, scopes, exclude []string) []*TestMapEntry {
+    var out []*TestMapEntry
+    for _, p := range paths {
+        l := loc.Detect(p)
+        if l == nil || !testableLangs[l.Name] || testFileRe.MatchString(p) || inExcludedDir(p, exclude) || !inScopes(p, scopes) {
+            continue
+        }
+        out = append(out, &TestMapEntry{Repo: repo, Path: p, Dir: path.Dir(p)})
+    }
+    return out
+}
+
+func inExcludedDir(p string, exclude []string) bool {
+    for _, part := range strings.Split(path.Dir(p), "/") {
+        for _, e := range exclude {
+            if part == e {
+                return true
+            }
+        }
+    }
+    return false
+}
+
+func inScopes(p string, scopes []string) bool {
+    if len(scopes) == 0 {
+        return true
+    }
+    for _, s := range scopes {
+        s = strings.Trim(s, "/")
+        if s == "" || s == "." || p == s || strings.HasPrefix(p, s+"/") {
+            return true
+        }
+    }
+    return false
+}
+
+// fileStem 是去掉扩展名并转为小写的文件名
+func fileStem(p string) string {
+    base := path.Base(p)
+    return strings.ToLower(strings.TrimSuffix(base, path.Ext(base)))
+}
+
+// testStem 返回测试文件对应的被测文件名；测试目录中没有测试标记的文件（tests/foo.py）取文件名本身
+func testStem(p string) string {
+    base := path.Base(p)
+    for _, re := range testStemRes {
+        if m := re.FindStringSubmatch(base); m != nil {
+            return strings.ToLower(m[1])
+        }
+    }
+    return fileStem(p)
+}
+
+// matchTestNames 按命名规则匹配仓库内的测试文件；同名的测试有多个时取目录最接近的（可能并列）
+func matchTestNames(repo string, entries []*TestMapEntry, paths []string) {
+    byStem := map[string][]string{}
+    goTestDirs := map[string]bool{}
+    for _, p := range paths {
+        if !testFileRe.MatchString(p) {
+            continue
+        }
+        byStem[testStem(p)] = append(byStem[testStem(p)], p)
+        if strings.HasSuffix(p, "_test.go") {
+            goTestDirs[path.Dir(p)] = true
+        }
+    }
+    for _, e := range entries {
+        lang := loc.Detect(e.Path).Name
+        best, bestScore := []string(nil), -1
+        for _, t := range byStem[fileStem(e.Path)] {
+            if l := loc.Detect(t); l == nil || !sameTestLang(lang, l.Name) {
+                continue
+            }
+            switch s := dirAffinity(path.Dir(e.Path), path.Dir(t)); {
+            case s < 0:
+            case s > bestScore:
+                best, bestScore = []string{t}, s
+            case s == bestScore:
+                best = append(best, t)
+            }
+        }
+        for _, t := range best {
+            e.Tests = append(e.Tests, TestRef{Repo: repo, Path: t, Kind: "name"})
+        }
+        if len(e.Tests) == 0 && lang == "Go" && goTestDirs[e.Dir] {
+            e.Tests = append(e.Tests, TestRef{Repo: repo, Path: e.Dir + "/*_test.go", Kind: "package"})
+        }
+    }
+}
+
+// sameTestLang 判断测试文件的语言能否测试源码文件：JavaScript 与 TypeScript 常常互相测试
+func sameTestLang(src, test string) bool {
+    js := map[string]bool{"JavaScript": true, "TypeScript": true}
+    return src == test || (js[src] && js[test])
+}
+
+// dirAffinity 衡量测试目录与源码目录的接近程度：同目录最高，其次按去掉测试目录名后
+// 从末尾开始相同的路径段数（src/main/java/x 与 src/test/java/x 得 1 分）；末尾不同时只有
+// 上级目录中的测试（如仓库根目录的 tests/）算数，得 0 分，其余为 -1，表示不是它的测试
+func dirAffinity(src, test string) int {
+    if src == test {
+        return 1 << 10
+    }
+    var kept []string
+    for _, part := range strings.Split(test, "/") {
+        switch part {
+        case "test", "tests", "__tests__", "spec":
+            continue
+        }
+        kept = append(kept, part)
+    }
+    if strings.Join(kept, "/") == src {
+        return 1 << 9
+    }
+    a, b := strings.Split(src, "/"), kept
+    n := 0
+    for n < len(a) && n < len(b) && a[len(a)-1-n] == b[len(b)-1-n] {
+        n++
+    }
+    if rest := strings.Join(kept, "/"); n == 0 && rest != "" && !strings.HasPrefix(src+"/", rest+"/") {
+        return -1
+    }
+    return n
+}
+
+// refBatch 是一次引用搜索中的文件名数，避免正则过长
+const refBatch = 40
+
+// matchTestRefs 对还没有测试的文件按文件名在测试文件中做引用搜索，每批文件名一次查询
+func matchTestRefs(ctx context.Context, c *sg.Client, repos []string, entries []*TestMapEntry) error {
+    byName := map[string][]*TestMapEntry{}
+    var names []string
+    for _, e := range entries {
+        base := path.Base(e.Path)
+        name := strings.TrimSuffix(base, path.Ext(base))
+        if len(e.Tests) > 0 || len(name) < 4 || genericStems[strings.ToLower(name)] {
+            continue
+        }
+        if _, ok := byName[name]; !ok {
+            names = append(names, name)
+        }
+        byName[name] = append(byName[name], e)
+    }
+    repoRe := make([]string, len(repos))
+    for i, r := range repos {
+        repoRe[i] = regexp.QuoteMeta(r)
+    }
+    scope := "repo:^(" + strings.Join(repoRe, "|") + ")$"
+    for i := 0; i < len(names); i += refBatch {
+        batch := names[i:min(i+refBatch, len(names))]
+        quoted := make([]string, len(batch))
+        for j, n := range batch {
+            quoted[j] = regexp.QuoteMeta(n)
+        }
+        wordRes := make([]*regexp.Regexp, len(batch))
+        for j, q := range quoted {
+            wordRes[j] = regexp.MustCompile(`\b` + q + `\b`)
+        }
+        q := buildQuery(scope, "file:"+testFileRe.String(), "count:all", `\b(`+strings.Join(quoted, "|")+`)\b`)
+        found := map[string]map[TestRef]bool{}
+        _, err := c.Uncapped().SearchEach(ctx, q, "regexp", func(_ *sg.SearchResults, fm sg.FileMatch) error {
+            for _, lm := range fm.LineMatches {
+                for j, re := range wordRes {
+                    if !re.MatchString(lm.Preview) {
+                        continue
+                    }
+                    if found[batch[j]] == nil {
+                        found[batch[j]] = map[TestRef]bool{}
+                    }
+                    found[batch[j]][TestRef{Repo: fm.Repository.Name, Path: fm.File.Path, Kind: "ref"}] = true
+                }
+            }
+            return nil
+        })
+        if err != nil {
+            return err
+        }
+        for name, refs := range found {
+            sorted := make([]TestRef, 0, len(refs))
+            for r := range refs {
+                sorted = append(sorted, r)
+            }
+            sort.Slice(sorted, func(i, j int) bool {
+                if sorted[i].Repo != sorted[j].Repo {
+                    return sorted[i].Repo < sorted[j].Repo
+                }
+                return sorted[i].Path < sorted[j].Path
+            })
+            for _, e := range byName[name] {
+                e.Tests = append(e.Tests, sorted...)
+            }
+        }
+    }
+    return nil
+}
+
+// matchInlineTests 把含 #[cfg(test)] 的 Rust 文件记为有测试
+func matchInlineTests(ctx context.Context, c *sg.Client, repo string, entries []*TestMapEntry) error {
+    byPath := map[string]*TestMapEntry{}
+    for _, e := range entries {
+        if len(e.Tests) == 0 && strings.HasSuffix(e.Path, ".rs") {
+            byPath[e.Path] = e
+        }
+    }
+    if len(byPath) == 0 {
+        return nil
+    }
+    q := buildQuery("repo:^"+regexp.QuoteMeta(repo)+"$", `file:\.rs$`, "count:all", `#[cfg(test)]`)
+    _, err := c.Uncapped().SearchEach(ctx, q, "literal", func(_ *sg.SearchResults, fm sg.FileMatch) error {
+        if e, ok := byPath[fm.File.Path]; ok {
+            e.Tests = append(e.Tests, TestRef{Repo: repo, Path: fm.File.Path, Kind: "inline"})
+        }
+        return nil
+    })
+    return err
+}
+
+// testRefName 在测试文件属于其他仓库时加上仓库名
+func testRefName(repo string, t TestRef) string {
+    if t.Repo != repo {
+        return t.Repo + ":" + t.Path
+    }
+    return t.Path
+}
+
+func printTestMap(entries []*TestMapEntry) {
+    dirs := map[string][]*TestMapEntry{}
+    var order []string
+    for _, e := range entries {
+        if _, ok := dirs[e.Dir]; !ok {
+            order = append(order, e.Dir)
+        }
+        dirs[e.Dir] = append(dirs[e.Dir], e)
+    }
+    sort.Strings(order)
+    for i, d := range order {
+        if i > 0 {
+            fmt.Println()
+        }
+        untested := 0
+        for _, e := range dirs[d] {
+            if len(e.Tests) == 0 {
+                untested++
+            }
+        }
+        fmt.Print(i18n.Sprintf("%s/（%d 个文件，%d 个找不到测试）\n", d, len(dirs[d]), untested))
+        for _, e := range dirs[d] {
+            name := path.Base(e.Path)
+            if len(e.Tests) == 0 {
+                fmt.Printf("  ✗ %s\n", name)
+                continue
+            }
+            var tests []string
+            for _, t := range e.Tests {
+                tests = append(tests, testRefName(e.Repo, t)+" ("+t.Kind+")")
+            }
+            fmt.Printf("  ✓ %s → %s\n", name, strings.Join(tests, ", "))
+        }
+    }
+}
+
+func init() { rootCmd.AddCommand(newTestMapCmd()) }
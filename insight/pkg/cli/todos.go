@@ -0,0 +1,245 @@
+package cli
+
+import (
+    "context"
+    "fmt"
+    "os"
+    "regexp"
+    "sort"
+    "strconv"
+    "strings"
+
+    "github.com/spf13/cobra"
+    "kingbrain/insight/pkg/baseline"
+    "kingbrain/insight/pkg/preview"
+    "kingbrain/insight/pkg/sg"
+)
+
+const unassigned = "(unassigned)"
+
+var (
+    mentionRe = regexp.MustCompile(`@([A-Za-z0-9][\w.-]*)`)
+    ticketRe  = regexp.MustCompile(`\b[A-Z][A-Z0-9]+-\d+\b|#\d+\b`)
+)
+
+// Todo 是解析后的一条 TODO/FIXME/HACK 注释
+type Todo struct {
+    Repo     string   `json:"repo"`
+    Path     string   `json:"path"`
+    Line     int      `json:"line"`
+    Tag      string   `json:"tag"`
+    Assignee string   `json:"assignee"`
+    Tickets  []string `json:"tickets,omitempty"`
+    Text     string   `json:"text"`
+    URL      string   `json:"url"`
+}
+
+// TodoGroup 是按 负责人/仓库 聚合后的一组 TODO
+type TodoGroup struct {
+    Owner string `json:"owner"`
+    Repo  string `json:"repo"`
+    Items []Todo `json:"items"`
+}
+
+func newTodosCmd() *cobra.Command {
+    var (
+        repos   []string
+        tags    []string
+        format  string
+        limit   int
+        issue   issueOptions
+        exports []string
+        bl      baselineFlags
+    )
+
+    cmd := &cobra.Command{
+        Use:   "todos",
+        Short: "跨仓库提取 TODO/FIXME/HACK 注释，解析负责人与工单号",
+        Args:  cobra.NoArgs,
+        RunE: func(cmd *cobra.Command, _ []string) error {
+            if err := checkFormat(format, "text", "json", "csv"); err != nil {
+                return err
+            }
+            known, err := bl.load("todos")
+            if err != nil {
+                return err
+            }
+            run := newRun("todos", strings.Join(tags, "|"))
+            all, err := searchTodos(cmd.Context(), sg.New(), repos, tags, limit)
+            if err != nil {
+                return err
+            }
+            todos, baselined := splitBaseline(known, all, todoBaselineFinding)
+            if err := bl.save("todos", baselined); err != nil {
+                return err
+            }
+            if err := printTodos(format, todos); err != nil {
+                return err
+            }
+            findings := todoFindings(todos)
+            run.Matches = exportFindings(findings)
+            if err := runExports(exports, run); err != nil {
+                return err
+            }
+            if err := createIssues(issue, findings); err != nil {
+                return err
+            }
+            return bl.failOnNew(cmd, len(todos), len(all)-len(todos))
+        },
+    }
+
+    cmd.Flags().StringSliceVar(&repos, "repo", nil, "限定仓库（可重复，支持正则；含 * ? 的 glob 按仓库缓存展开）")
+    cmd.Flags().StringSliceVar(&tags, "tags", []string{"TODO", "FIXME", "HACK"}, "要提取的标记")
+    cmd.Flags().StringVarP(&format, "format", "f", "text", "输出格式：text|json|csv")
+    cmd.Flags().IntVar(&limit, "limit", 1000, "最多返回的匹配数（count:）")
+    addIssueFlags(cmd, &issue)
+    addExportFlag(cmd, &exports)
+    addBaselineFlags(cmd, &bl)
+    return cmd
+}
+
+func printTodos(format string, todos []Todo) error {
+    groups := groupTodos(todos)
+    switch format {
+    case "json":
+        return writeJSON(os.Stdout, groups)
+    case "csv":
+        var rows [][]string
+        for _, t := range todos {
+            rows = append(rows, []string{t.Assignee, t.Repo, t.Path, strconv.Itoa(t.Line),
+                t.Tag, strings.Join(t.Tickets, " "), t.Text, t.URL})
+        }
+        return writeCSV(os.Stdout, []string{"owner", "repo", "path", "line", "tag", "tickets", "text", "url"}, rows)
+    }
+
+    fmt.Printf("Total: %d\n\n", len(todos))
+    for _, g := range groups {
+        fmt.Printf("%s @ %s (%d)\n", g.Owner, g.Repo, len(g.Items))
+        for _, t := range g.Items {
+            ticket := ""
+            if len(t.Tickets) > 0 {
+                ticket = " [" + strings.Join(t.Tickets, ",") + "]"
+            }
+            fmt.Printf("  %-5s %s:%d%s %s\n", t.Tag, t.Path, t.Line, ticket, preview.Render(t.Text))
+        }
+        fmt.Println()
+    }
+    return nil
+}
+
+// todoFindings 把 TODO 转成通用 Finding，标记（TODO/FIXME/HACK）即规则
+func todoFindings(todos []Todo) []Finding {
+    out := make([]Finding, 0, len(todos))
+    for _, t := range todos {
+        out = append(out, Finding{Repo: t.Repo, Path: t.Path, Line: t.Line, Rule: t.Tag, Text: t.Text, URL: t.URL})
+    }
+    return out
+}
+
+// todoBaselineFinding 按 标记 + 注释内容 识别，改写注释算作新发现
+func todoBaselineFinding(t Todo) baseline.Finding {
+    return baseline.Finding{
+        Fingerprint: baseline.Fingerprint("todos", t.Tag, t.Repo, t.Path, t.Text),
+        Rule:        t.Tag,
+        Repo:        t.Repo,
+        Path:        t.Path,
+        Line:        t.Line,
+        Text:        t.Text,
+    }
+}
+
+// searchTodos 在 Sourcegraph 上搜索标记并逐行解析
+func searchTodos(ctx context.Context, c *sg.Client, repos, tags []string, limit int) ([]Todo, error) {
+    alt := `\b(` + strings.Join(tags, "|") + `)\b`
+    res, err := c.Search(ctx, buildQuery(alt, repoFilter(repos), countFilter(limit)), "regexp")
+    if err != nil {
+        return nil, err
+    }
+
+    // TODO(alice): xxx / FIXME[bob, PAY-12] xxx / HACK: xxx
+    re, err := regexp.Compile(alt + `(?:\(([^)]*)\)|\[([^\]]*)\])?:?\s*(.*)$`)
+    if err != nil {
+        return nil, err
+    }
+    var todos []Todo
+    for _, fm := range res.Results {
+        for _, lm := range fm.LineMatches {
+            t, ok := parseTodo(re, lm.Preview)
+            if !ok {
+                continue
+            }
+            t.Repo = fm.Repository.Name
+            t.Path = fm.File.Path
+            t.Line = lm.LineNumber + 1 // Sourcegraph 行号从 0 开始
+            t.URL = fmt.Sprintf("%s?L%d", c.URL(fm.File.URL), t.Line)
+            todos = append(todos, t)
+        }
+    }
+    return todos, nil
+}
+
+// parseTodo 从一行预览中解析标记、负责人与工单号
+func parseTodo(re *regexp.Regexp, line string) (Todo, bool) {
+    loc := re.FindStringSubmatchIndex(line)
+    if loc == nil {
+        return Todo{}, false
+    }
+    t := Todo{Tag: line[loc[2]:loc[3]], Text: strings.TrimSpace(line[loc[8]:loc[9]])}
+
+    // 括号内可能是 负责人、工单号 或两者混合（逗号分隔）
+    var inner string
+    if loc[4] >= 0 {
+        inner = line[loc[4]:loc[5]]
+    } else if loc[6] >= 0 {
+        inner = line[loc[6]:loc[7]]
+    }
+    for _, part := range strings.Split(inner, ",") {
+        part = strings.TrimSpace(part)
+        switch {
+        case part == "":
+        case ticketRe.FindString(part) == part:
+            t.Tickets = append(t.Tickets, part)
+        case t.Assignee == "":
+            t.Assignee = strings.TrimPrefix(part, "@")
+        }
+    }
+    if t.Assignee == "" {
+        if m := mentionRe.FindStringSubmatch(t.Text); m != nil {
+            t.Assignee = m[1]
+        }
+    }
+    if t.Assignee == "" {
+        t.Assignee = unassigned
+    }
+    t.Tickets = append(t.Tickets, ticketRe.FindAllString(t.Text, -1)...)
+    return t, true
+}
+
+// groupTodos 按 负责人/仓库 聚合，未分配的排在最后
+func groupTodos(todos []Todo) []TodoGroup {
+    idx := map[[2]string]int{}
+    var groups []TodoGroup
+    for _, t := range todos {
+        k := [2]string{t.Assignee, t.Repo}
+        i, ok := idx[k]
+        if !ok {
+            i = len(groups)
+            idx[k] = i
+            groups = append(groups, TodoGroup{Owner: t.Assignee, Repo: t.Repo})
+        }
+        groups[i].Items = append(groups[i].Items, t)
+    }
+    sort.SliceStable(groups, func(i, j int) bool {
+        a, b := groups[i], groups[j]
+        if (a.Owner == unassigned) != (b.Owner == unassigned) {
+            return b.Owner == unassigned
+        }
+        if a.Owner != b.Owner {
+            return a.Owner < b.Owner
+        }
+        return a.Repo < b.Repo
+    })
+    return groups
+}
+
+func init() { rootCmd.AddCommand(newTodosCmd()) }
@@ -0,0 +1,258 @@
+package cli
+
+import (
+    "context"
+    "fmt"
+    "os"
+    "path"
+    "sort"
+    "strings"
+
+    "github.com/spf13/cobra"
+    "kingbrain/insight/pkg/i18n"
+    "kingbrain/insight/pkg/sg"
+)
+
+// treeNode 是目录树中的一项；Files 为目录下（递归）的文件数。load 不为空的目录还没有读取子项，
+// 浏览到时才调用它（tree -i 逐层浏览），这类目录的 Files 为 0
+type treeNode struct {
+    Name     string      `json:"name"`
+    Dir      bool        `json:"dir,omitempty"`
+    Files    int         `json:"files,omitempty"`
+    Children []*treeNode `json:"children,omitempty"`
+
+    byName map[string]*treeNode
+    load   func() error
+}
+
+func newTreeCmd() *cobra.Command {
+    var (
+        rev         string
+        depth       int
+        patterns    []string
+        format      string
+        interactive bool
+    )
+
+    cmd := &cobra.Command{
+        Use:   "tree <repo> [path]",
+        Short: "打印远端仓库在某个 revision 下的目录树，可限制深度、按文件名过滤，或交互式浏览",
+        Long: `目录在前、文件在后，各自按名字排序；--depth 截断的目录显示其下的文件数。
+-P 按 glob 过滤文件（匹配文件名或相对路径，可重复），不含匹配文件的目录不显示。
+-i 进入交互模式：↑↓/jk 移动，回车/→ 进入目录或查看文件（经 $PAGER），←/h 返回上级，q 退出；
+没有 -P 时每进入一个目录才读取它的下一层，大仓库也不用先取回整棵树。
+
+  kb tree github.com/acme/api
+  kb tree github.com/acme/api pkg --depth 2 --rev v1.4.0
+  kb tree github.com/acme/api -P '*.proto' -f paths
+  kb tree github.com/acme/api -i`,
+        Args: cobra.RangeArgs(1, 2),
+        RunE: func(cmd *cobra.Command, args []string) error {
+            if err := checkFormat(format, "text", "json", "paths"); err != nil {
+                return err
+            }
+            repo, sub := args[0], ""
+            if len(args) == 2 {
+                sub = strings.Trim(args[1], "/")
+            }
+            c := sg.New()
+            title := repo
+            if rev != "" {
+                title += "@" + rev
+            }
+            if interactive && (!stdoutTTY || !stdinTTY()) {
+                return i18n.Errorf("-i 需要在终端中运行")
+            }
+            empty := func() error {
+                if sub != "" {
+                    return i18n.Errorf("%s 中没有 %s 下的文件", repo, sub)
+                }
+                return i18n.Errorf("%s 中没有匹配的文件", repo)
+            }
+            // browseTree 给出的路径含根节点的名字（即 sub），相对仓库根
+            open := func(p string) error { return viewFile(cmd.Context(), c, repo, rev, p) }
+            if interactive && len(patterns) == 0 {
+                root := lazyTreeDir(cmd.Context(), c, repo, rev, sub, sub)
+                if err := root.load(); err != nil {
+                    return err
+                }
+                root.load = nil
+                if len(root.Children) == 0 {
+                    return empty()
+                }
+                stopPager()
+                return browseTree(title, root, open)
+            }
+            entries, err := c.TreeEntries(cmd.Context(), repo, rev, sub, true)
+            if err != nil {
+                return err
+            }
+            paths := filterTreePaths(entries, sub, patterns)
+            if len(paths) == 0 {
+                return empty()
+            }
+            root := buildTree(paths)
+            root.Name = sub
+
+            if interactive {
+                stopPager()
+                return browseTree(title, root, open)
+            }
+            switch format {
+            case "json":
+                return writeJSON(os.Stdout, pruneTree(root, depth))
+            case "paths":
+                for _, p := range paths {
+                    fmt.Println(path.Join(sub, p))
+                }
+                return nil
+            }
+            label := title
+            if sub != "" {
+                label += "/" + sub
+            }
+            fmt.Println(label)
+            dirs := printTree(root, "", depth, 1)
+            fmt.Print(i18n.Sprintf("\n%d 个目录，%d 个文件\n", dirs, root.Files))
+            return nil
+        },
+    }
+
+    cmd.Flags().StringVar(&rev, "rev", "", "分支、标签或 commit（默认 HEAD）")
+    cmd.Flags().IntVarP(&depth, "depth", "L", 0, "最多显示的目录层数（0 为不限制）")
+    cmd.Flags().StringArrayVarP(&patterns, "pattern", "P", nil, "只显示匹配 glob 的文件（可重复）")
+    cmd.Flags().StringVarP(&format, "format", "f", "text", "输出格式：text|json|paths")
+    cmd.Flags().BoolVarP(&interactive, "interactive", "i", false, "交互式浏览，可进入目录、查看文件")
+    return cmd
+}
+
+// filterTreePaths 取 sub 目录下（entries 已限定在其中）匹配任一 glob 的文件，返回相对 sub 的路径
+func filterTreePaths(entries []sg.TreeEntry, sub string, patterns []string) []string {
+    var out []string
+    for _, e := range entries {
+        if e.Dir {
+            continue
+        }
+        p := e.Path
+        if sub != "" {
+            if !strings.HasPrefix(p, sub+"/") {
+                continue
+            }
+            p = p[len(sub)+1:]
+        }
+        if len(patterns) > 0 && !matchAnyGlob(p, patterns) {
+            continue
+        }
+        out = append(out, p)
+    }
+    return out
+}
+
+// lazyTreeDir 返回仓库目录 dir 的节点，子项在调用 load 时才向服务端读取（只读一层）
+func lazyTreeDir(ctx context.Context, c *sg.Client, repo, rev, dir, name string) *treeNode {
+    n := &treeNode{Name: name, Dir: true}
+    n.load = func() error {
+        entries, err := c.TreeEntries(ctx, repo, rev, dir, false)
+        if err != nil {
+            return err
+        }
+        n.Children = nil
+        for _, e := range entries {
+            if e.Dir {
+                n.Children = append(n.Children, lazyTreeDir(ctx, c, repo, rev, e.Path, path.Base(e.Path)))
+            } else {
+                n.Children = append(n.Children, &treeNode{Name: path.Base(e.Path)})
+            }
+        }
+        sortTree(n)
+        return nil
+    }
+    return n
+}
+
+func matchAnyGlob(p string, patterns []string) bool {
+    for _, g := range patterns {
+        if ok, _ := path.Match(g, path.Base(p)); ok {
+            return true
+        }
+        if ok, _ := path.Match(g, p); ok {
+            return true
+        }
+    }
+    return false
+}
+
+// buildTree 由文件路径列表建树，目录由路径隐含
+func buildTree(paths []string) *treeNode {
+    root := &treeNode{Dir: true}
+    for _, p := range paths {
+        n := root
+        parts := strings.Split(p, "/")
+        for i, part := range parts {
+            n.Files++
+            child := n.byName[part]
+            if child == nil {
+                child = &treeNode{Name: part, Dir: i < len(parts)-1}
+                if n.byName == nil {
+                    n.byName = map[string]*treeNode{}
+                }
+                n.byName[part] = child
+                n.Children = append(n.Children, child)
+            }
+            n = child
+        }
+    }
+    sortTree(root)
+    return root
+}
+
+func sortTree(n *treeNode) {
+    sort.Slice(n.Children, func(i, j int) bool {
+        a, b := n.Children[i], n.Children[j]
+        if a.Dir != b.Dir {
+            return a.Dir
+        }
+        return a.Name < b.Name
+    })
+    for _, c := range n.Children {
+        sortTree(c)
+    }
+}
+
+// pruneTree 返回只含 depth 层子项的副本（0 为不限制），被截断的目录只保留文件数
+func pruneTree(n *treeNode, depth int) *treeNode {
+    cp := &treeNode{Name: n.Name, Dir: n.Dir, Files: n.Files}
+    for _, c := range n.Children {
+        if depth == 1 {
+            cp.Children = append(cp.Children, &treeNode{Name: c.Name, Dir: c.Dir, Files: c.Files})
+        } else {
+            cp.Children = append(cp.Children, pruneTree(c, depth-1))
+        }
+    }
+    return cp
+}
+
+// printTree 按 tree(1) 的样式打印 n 的子项，返回打印出的目录数
+func printTree(n *treeNode, indent string, depth, level int) int {
+    dirs := 0
+    for i, c := range n.Children {
+        branch, next := "├── ", "│   "
+        if i == len(n.Children)-1 {
+            branch, next = "└── ", "    "
+        }
+        if !c.Dir {
+            fmt.Println(indent + branch + c.Name)
+            continue
+        }
+        dirs++
+        if depth > 0 && level >= depth {
+            fmt.Print(i18n.Sprintf("%s%s%s/ (%d 个文件)\n", indent, branch, c.Name, c.Files))
+            continue
+        }
+        fmt.Println(indent + branch + c.Name + "/")
+        dirs += printTree(c, indent+next, depth, level+1)
+    }
+    return dirs
+}
+
+func init() { rootCmd.AddCommand(newTreeCmd()) }
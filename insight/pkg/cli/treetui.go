@@ -0,0 +1,231 @@
+package cli
+
+import (
+    "bufio"
+    "context"
+    "fmt"
+    "os"
+    "os/exec"
+    "path"
+    "strings"
+
+    "golang.org/x/term"
+    "kingbrain/insight/pkg/i18n"
+    "kingbrain/insight/pkg/preview"
+    "kingbrain/insight/pkg/progress"
+    "kingbrain/insight/pkg/sg"
+)
+
+func stdinTTY() bool { return progress.IsTerminal(os.Stdin) }
+
+// treeView 是 tree -i 的浏览状态：stack 为从根到当前目录的路径，cursor 为各层的选中项
+type treeView struct {
+    stack  []*treeNode
+    cursor []int
+    status string
+}
+
+func (v *treeView) dir() *treeNode { return v.stack[len(v.stack)-1] }
+
+// rel 返回当前目录下第 i 项相对仓库根的路径
+func (v *treeView) rel(i int) string {
+    parts := make([]string, 0, len(v.stack))
+    for _, n := range v.stack {
+        if n.Name != "" {
+            parts = append(parts, n.Name)
+        }
+    }
+    return path.Join(append(parts, v.dir().Children[i].Name)...)
+}
+
+// browseTree 在终端的原始模式下浏览目录树，选中文件时调用 open（参数为相对根的路径），
+// open 期间恢复终端的正常模式，返回的错误显示在状态栏
+func browseTree(title string, root *treeNode, open func(p string) error) error {
+    fd := int(os.Stdin.Fd())
+    state, err := term.MakeRaw(fd)
+    if err != nil {
+        return err
+    }
+    // 使用备用屏幕，退出后恢复原来的终端内容
+    fmt.Print("\x1b[?1049h\x1b[?25l")
+    defer func() {
+        fmt.Print("\x1b[?25h\x1b[?1049l")
+        term.Restore(fd, state)
+    }()
+
+    v := &treeView{stack: []*treeNode{root}, cursor: []int{0}}
+    in := bufio.NewReader(os.Stdin)
+    for {
+        v.draw(title)
+        key, err := readKey(in)
+        if err != nil {
+            return err
+        }
+        cur := &v.cursor[len(v.cursor)-1]
+        n := len(v.dir().Children)
+        v.status = ""
+        switch key {
+        case "q", "\x03":
+            return nil
+        case "up", "k":
+            *cur = max(*cur-1, 0)
+        case "down", "j":
+            *cur = min(*cur+1, n-1)
+        case "home", "g":
+            *cur = 0
+        case "end", "G":
+            *cur = n - 1
+        case "left", "h", "\x7f":
+            if len(v.stack) > 1 {
+                v.stack, v.cursor = v.stack[:len(v.stack)-1], v.cursor[:len(v.cursor)-1]
+            }
+        case "right", "l", "\r":
+            if n == 0 {
+                continue
+            }
+            sel := v.dir().Children[*cur]
+            if sel.Dir {
+                if sel.load != nil {
+                    if err := sel.load(); err != nil {
+                        v.status = err.Error()
+                        continue
+                    }
+                    sel.load = nil
+                }
+                v.stack, v.cursor = append(v.stack, sel), append(v.cursor, 0)
+                continue
+            }
+            term.Restore(fd, state)
+            fmt.Print("\x1b[?25h\x1b[?1049l")
+            err := open(v.rel(*cur))
+            fmt.Print("\x1b[?1049h\x1b[?25l")
+            if _, rerr := term.MakeRaw(fd); rerr != nil {
+                return rerr
+            }
+            if err != nil {
+                v.status = err.Error()
+            }
+        }
+    }
+}
+
+// draw 重绘整个屏幕；列表超过一屏时保持选中项可见
+func (v *treeView) draw(title string) {
+    width, height, err := term.GetSize(int(os.Stdout.Fd()))
+    if err != nil || width <= 0 || height <= 0 {
+        width, height = 80, 24
+    }
+    var b strings.Builder
+    b.WriteString("\x1b[H\x1b[2J")
+    loc := ""
+    for _, n := range v.stack {
+        if n.Name != "" {
+            loc += "/" + n.Name
+        }
+    }
+    fmt.Fprintf(&b, "\x1b[1m%s:%s/\x1b[0m\r\n", title, loc)
+
+    rows := max(height-3, 1)
+    items := v.dir().Children
+    cur := v.cursor[len(v.cursor)-1]
+    top := 0
+    if cur >= rows {
+        top = cur - rows + 1
+    }
+    for i := top; i < len(items) && i < top+rows; i++ {
+        it := items[i]
+        line := it.Name
+        if it.Dir {
+            line = it.Name + "/"
+            if it.Files > 0 {
+                line = fmt.Sprintf("%s/  (%d)", it.Name, it.Files)
+            }
+        }
+        line = truncateWidth(preview.Render(line), width-2)
+        if i == cur {
+            fmt.Fprintf(&b, "\x1b[7m %s \x1b[0m\r\n", preview.Pad(line, width-2))
+        } else {
+            fmt.Fprintf(&b, " %s\r\n", line)
+        }
+    }
+    fmt.Fprintf(&b, "\x1b[%d;1H\x1b[2m", height)
+    if v.status != "" {
+        b.WriteString(truncateWidth(preview.Render(v.status), width))
+    } else {
+        fmt.Fprint(&b, i18n.Sprintf("%d/%d  ↑↓ 移动  回车 打开  ← 上级  q 退出", cur+1, len(items)))
+    }
+    b.WriteString("\x1b[0m")
+    os.Stdout.WriteString(b.String())
+}
+
+func truncateWidth(s string, w int) string {
+    if preview.Width(s) <= w {
+        return s
+    }
+    n := 0
+    for i, r := range s {
+        if n+preview.RuneWidth(r) > w-1 {
+            return s[:i] + "…"
+        }
+        n += preview.RuneWidth(r)
+    }
+    return s
+}
+
+// readKey 读取一次按键，方向键等转义序列转成 up/down/left/right/home/end
+func readKey(in *bufio.Reader) (string, error) {
+    r, _, err := in.ReadRune()
+    if err != nil {
+        return "", err
+    }
+    if r != 0x1b {
+        return string(r), nil
+    }
+    if in.Buffered() == 0 {
+        return "esc", nil
+    }
+    b, _ := in.ReadByte()
+    if b != '[' && b != 'O' {
+        return "esc", nil
+    }
+    b, _ = in.ReadByte()
+    switch b {
+    case 'A':
+        return "up", nil
+    case 'B':
+        return "down", nil
+    case 'C':
+        return "right", nil
+    case 'D':
+        return "left", nil
+    case 'H':
+        return "home", nil
+    case 'F':
+        return "end", nil
+    }
+    return "esc", nil
+}
+
+// viewFile 拉取文件内容交给分页程序
+func viewFile(ctx context.Context, c *sg.Client, repo, rev, p string) error {
+    content, err := c.FileContent(ctx, repo, rev, p)
+    if err != nil {
+        return err
+    }
+    return pageText(repo+"/"+p, content)
+}
+
+// pageText 把内容交给分页程序；没有分页程序时直接输出并等待回车
+func pageText(title, content string) error {
+    if argv := pagerCommand(); argv != nil {
+        cmd := exec.Command(argv[0], argv[1:]...)
+        cmd.Stdin = strings.NewReader(content)
+        cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+        if err := cmd.Run(); err == nil || cmd.ProcessState != nil {
+            return nil
+        }
+    }
+    fmt.Print(i18n.Sprintf("==> %s <==\n%s\n[回车返回]", title, content))
+    bufio.NewReader(os.Stdin).ReadString('\n')
+    return nil
+}
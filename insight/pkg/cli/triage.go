@@ -0,0 +1,555 @@
+package cli
+
+import (
+    "bufio"
+    "crypto/sha256"
+    "encoding/hex"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "io/fs"
+    "os"
+    "path/filepath"
+    "sort"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/spf13/cobra"
+    "golang.org/x/term"
+    "kingbrain/insight/pkg/i18n"
+    "kingbrain/insight/pkg/preview"
+    "kingbrain/insight/pkg/sg"
+)
+
+// 分诊的结论；未处理的匹配没有结论
+const (
+    triageKeep     = "keep"
+    triageIgnore   = "ignore"
+    triageFollowUp = "follow-up"
+)
+
+// TriageItem 是分诊列表中的一处匹配；Status 为 keep|ignore|follow-up，未处理时为空
+type TriageItem struct {
+    Repo     string `json:"repo"`
+    Rev      string `json:"rev,omitempty"`
+    Path     string `json:"path"`
+    Line     int    `json:"line"`
+    Preview  string `json:"preview"`
+    URL      string `json:"url"`
+    Instance string `json:"instance,omitempty"`
+    Status   string `json:"status,omitempty"`
+    Note     string `json:"note,omitempty"`
+}
+
+func (it *TriageItem) id() string {
+    return it.Instance + "\x00" + it.Repo + "@" + it.Rev + "/" + it.Path + ":" + strconv.Itoa(it.Line)
+}
+
+// triageState 是保存在磁盘上的分诊进度，按匹配的 id 记录结论与备注，中途退出后可以接着做
+type triageState struct {
+    Updated time.Time                  `json:"updated"`
+    Marks   map[string]triageStateMark `json:"marks"`
+}
+
+type triageStateMark struct {
+    Status string `json:"status"`
+    Note   string `json:"note,omitempty"`
+}
+
+func newTriageCmd() *cobra.Command {
+    var (
+        query     string
+        pattern   string
+        statePath string
+        output    string
+        format    string
+        issue     issueOptions
+    )
+    cmd := &cobra.Command{
+        Use:   "triage [results-file|-]",
+        Short: "在终端中逐个分诊搜索结果：保留、忽略或待跟进，然后导出保留的结果或建 issue",
+        Long: `输入同 refine：find -f json 的 JSON Lines（不给参数或为 "-" 时读 stdin），或带 results 的搜索结果对象；
+也可以用 -q 直接执行一次搜索。每处匹配占一行，单键给出结论，处理后自动跳到下一个未处理的匹配：
+
+  a 保留    x 忽略    f 待跟进    u 撤销结论    c 添加备注
+  ↑↓/j k 移动    n 下一个未处理    回车 查看文件    tab 只看未处理    q 完成
+
+进度随时保存在 --state（默认 <用户缓存目录>/insight/triage/ 下按结果集区分的文件），
+同一批结果再次打开时接着上次的进度。退出后按 -o 导出保留与待跟进的匹配（.md 为 Markdown，
+其余为 JSON，"-" 为 stdout），--create-issues 为保留的匹配建 issue（选项同 todos）。
+
+  kb find -p regexp 'InsecureSkipVerify:\s*true' -f json > tls.jsonl
+  kb triage tls.jsonl -o tls-review.md
+  kb triage -q 'os.Setenv(' --create-issues --issue-label security`,
+        Args: cobra.MaximumNArgs(1),
+        RunE: func(cmd *cobra.Command, args []string) error {
+            if format != "" {
+                if err := checkFormat(format, "json", "markdown"); err != nil {
+                    return err
+                }
+            }
+            if query != "" && len(args) > 0 {
+                return i18n.Errorf("-q 与结果文件不能同时使用")
+            }
+            if !stdoutTTY {
+                return i18n.Errorf("triage 需要在终端中运行")
+            }
+            c := sg.New()
+            items, err := loadTriageItems(cmd, c, query, pattern, args)
+            if err != nil {
+                return err
+            }
+            if statePath == "" {
+                if statePath, err = defaultTriageState(items); err != nil {
+                    return err
+                }
+            }
+            if err := loadTriageState(statePath, items); err != nil {
+                return err
+            }
+            stopPager()
+            save := func() error { return saveTriageState(statePath, items) }
+            if err := runTriage(cmd, c, items, save); err != nil {
+                return err
+            }
+            if err := save(); err != nil {
+                return err
+            }
+            fmt.Fprint(os.Stderr, triageSummary(items))
+            fmt.Fprint(os.Stderr, i18n.Sprintf("进度已保存到 %s\n", statePath))
+
+            if output != "" {
+                if err := exportTriage(output, format, items); err != nil {
+                    return err
+                }
+            }
+            var findings []Finding
+            for _, it := range items {
+                if it.Status == triageKeep {
+                    findings = append(findings, Finding{Repo: it.Repo, Path: it.Path, Line: it.Line, Text: strings.TrimSpace(it.Preview), URL: it.URL})
+                }
+            }
+            return createIssues(issue, findings)
+        },
+    }
+    cmd.Flags().StringVarP(&query, "query", "q", "", "直接执行这个搜索作为分诊列表，而不是读取结果文件")
+    cmd.Flags().StringVarP(&pattern, "pattern", "p", "literal", "-q 的搜索模式：literal|regexp|structural")
+    cmd.Flags().StringVar(&statePath, "state", "", "分诊进度文件（默认按结果集保存在缓存目录，再次打开时接着做）")
+    cmd.Flags().StringVarP(&output, "output", "o", "", `退出后把保留与待跟进的匹配导出到文件，"-" 为 stdout`)
+    cmd.Flags().StringVarP(&format, "format", "f", "", "导出格式：json|markdown（默认按 -o 的扩展名，.md 为 markdown）")
+    addIssueFlags(cmd, &issue)
+    return cmd
+}
+
+// loadTriageItems 读取结果文件或执行 -q 搜索，把每处行匹配展开成一个分诊项
+func loadTriageItems(cmd *cobra.Command, c *sg.Client, query, pattern string, args []string) ([]*TriageItem, error) {
+    var recs []refineRecord
+    if query != "" {
+        res, err := c.Search(cmd.Context(), query, pattern)
+        if err != nil {
+            return nil, err
+        }
+        for _, fm := range res.Results {
+            recs = append(recs, refineRecord{FileMatch: fm})
+        }
+    } else {
+        var in io.Reader = os.Stdin
+        if len(args) == 1 && args[0] != "-" {
+            f, err := os.Open(args[0])
+            if err != nil {
+                return nil, err
+            }
+            defer f.Close()
+            in = f
+        } else if stdinTTY() {
+            return nil, i18n.Errorf("需要结果文件、stdin 中的 find -f json 输出，或 -q 查询")
+        }
+        var err error
+        if recs, err = readResults(in); err != nil {
+            return nil, err
+        }
+    }
+    var items []*TriageItem
+    seen := map[string]bool{}
+    for _, r := range recs {
+        for _, m := range r.LineMatches {
+            it := &TriageItem{
+                Repo: r.Repository.Name, Rev: r.Rev, Path: r.File.Path, Line: m.LineNumber + 1,
+                Preview: m.Preview, Instance: r.Instance,
+                URL: blobURL(c, r.Repository.Name, r.Rev, r.File.Path, strconv.Itoa(m.LineNumber+1)),
+            }
+            if !seen[it.id()] {
+                seen[it.id()] = true
+                items = append(items, it)
+            }
+        }
+    }
+    if len(items) == 0 {
+        return nil, i18n.Errorf("没有需要分诊的匹配")
+    }
+    return items, nil
+}
+
+// defaultTriageState 按结果集（各匹配 id 排序后的哈希）在缓存目录中选一个进度文件
+func defaultTriageState(items []*TriageItem) (string, error) {
+    dir, err := os.UserCacheDir()
+    if err != nil {
+        return "", err
+    }
+    ids := make([]string, len(items))
+    for i, it := range items {
+        ids[i] = it.id()
+    }
+    sort.Strings(ids)
+    sum := sha256.Sum256([]byte(strings.Join(ids, "\n")))
+    return filepath.Join(dir, "insight", "triage", hex.EncodeToString(sum[:8])+".json"), nil
+}
+
+func loadTriageState(path string, items []*TriageItem) error {
+    b, err := os.ReadFile(path)
+    if errors.Is(err, fs.ErrNotExist) {
+        return nil
+    }
+    if err != nil {
+        return err
+    }
+    var st triageState
+    if err := json.Unmarshal(b, &st); err != nil {
+        return i18n.Errorf("解析 %s 失败: %w", path, err)
+    }
+    for _, it := range items {
+        if m, ok := st.Marks[it.id()]; ok {
+            it.Status, it.Note = m.Status, m.Note
+        }
+    }
+    return nil
+}
+
+func saveTriageState(path string, items []*TriageItem) error {
+    st := triageState{Updated: time.Now().UTC(), Marks: map[string]triageStateMark{}}
+    for _, it := range items {
+        if it.Status != "" || it.Note != "" {
+            st.Marks[it.id()] = triageStateMark{Status: it.Status, Note: it.Note}
+        }
+    }
+    if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+        return err
+    }
+    b, err := json.MarshalIndent(st, "", "  ")
+    if err != nil {
+        return err
+    }
+    tmp := path + ".tmp"
+    if err := os.WriteFile(tmp, append(b, '\n'), 0o644); err != nil {
+        return err
+    }
+    return os.Rename(tmp, path)
+}
+
+// triageView 是分诊界面的状态：visible 为当前显示的项在 items 中的下标
+type triageView struct {
+    items     []*TriageItem
+    visible   []int
+    cursor    int
+    undecided bool
+    status    string
+}
+
+// filter 按“只看未处理”重新计算可见项，尽量让光标停在原来的项上
+func (v *triageView) filter() {
+    cur := -1
+    if v.cursor < len(v.visible) {
+        cur = v.visible[v.cursor]
+    }
+    v.visible = v.visible[:0]
+    for i, it := range v.items {
+        if !v.undecided || it.Status == "" || i == cur {
+            v.visible = append(v.visible, i)
+        }
+    }
+    v.cursor = 0
+    for j, i := range v.visible {
+        if i >= cur {
+            v.cursor = j
+            break
+        }
+    }
+}
+
+// next 把光标移到当前项之后第一个未处理的项，之后没有时从头找；全部处理完时不动
+func (v *triageView) next() {
+    for k := 1; k <= len(v.visible); k++ {
+        j := (v.cursor + k) % len(v.visible)
+        if v.items[v.visible[j]].Status == "" {
+            v.cursor = j
+            return
+        }
+    }
+}
+
+// runTriage 在原始模式下运行分诊界面；结果从 stdin 读入时改从 /dev/tty 读取按键。
+// 每次给出结论后调用 save 保存进度
+func runTriage(cmd *cobra.Command, c *sg.Client, items []*TriageItem, save func() error) error {
+    tty := os.Stdin
+    if !stdinTTY() {
+        f, err := os.Open("/dev/tty")
+        if err != nil {
+            return i18n.Errorf("triage 需要在终端中运行")
+        }
+        defer f.Close()
+        tty = f
+    }
+    fd := int(tty.Fd())
+    state, err := term.MakeRaw(fd)
+    if err != nil {
+        return err
+    }
+    fmt.Print("\x1b[?1049h\x1b[?25l")
+    defer func() {
+        fmt.Print("\x1b[?25h\x1b[?1049l")
+        term.Restore(fd, state)
+    }()
+    // suspend 在查看文件、输入备注时恢复终端的正常模式
+    suspend := func(fn func() error) error {
+        term.Restore(fd, state)
+        fmt.Print("\x1b[?25h\x1b[?1049l")
+        err := fn()
+        fmt.Print("\x1b[?1049h\x1b[?25l")
+        if _, rerr := term.MakeRaw(fd); rerr != nil {
+            return rerr
+        }
+        return err
+    }
+
+    v := &triageView{items: items}
+    v.filter()
+    if items[v.visible[v.cursor]].Status != "" {
+        v.next()
+    }
+    in := bufio.NewReader(tty)
+    for {
+        v.draw()
+        key, err := readKey(in)
+        if err != nil {
+            return err
+        }
+        v.status = ""
+        if len(v.visible) == 0 {
+            if key == "q" || key == "\x03" {
+                return nil
+            }
+            if key == "\t" {
+                v.undecided = false
+                v.filter()
+            }
+            continue
+        }
+        it := items[v.visible[v.cursor]]
+        mark := func(status string) {
+            it.Status = status
+            if err := save(); err != nil {
+                v.status = err.Error()
+            }
+            v.next()
+        }
+        switch key {
+        case "q", "\x03":
+            return nil
+        case "up", "k":
+            v.cursor = max(v.cursor-1, 0)
+        case "down", "j":
+            v.cursor = min(v.cursor+1, len(v.visible)-1)
+        case "home", "g":
+            v.cursor = 0
+        case "end", "G":
+            v.cursor = len(v.visible) - 1
+        case "n":
+            v.next()
+        case "a", "y":
+            mark(triageKeep)
+        case "x":
+            mark(triageIgnore)
+        case "f":
+            mark(triageFollowUp)
+        case "u", " ":
+            it.Status = ""
+            if err := save(); err != nil {
+                v.status = err.Error()
+            }
+        case "\t":
+            v.undecided = !v.undecided
+            v.filter()
+        case "c":
+            err := suspend(func() error {
+                fmt.Print(i18n.Sprintf("%s/%s:%d 的备注（回车确认，留空清除）: ", it.Repo, it.Path, it.Line))
+                line, err := in.ReadString('\n')
+                it.Note = strings.TrimSpace(line)
+                if err != nil && !errors.Is(err, io.EOF) {
+                    return err
+                }
+                return save()
+            })
+            if err != nil {
+                v.status = err.Error()
+            }
+        case "\r", "o", "right":
+            err := suspend(func() error {
+                return viewFile(cmd.Context(), c, it.Repo, it.Rev, it.Path)
+            })
+            if err != nil {
+                v.status = err.Error()
+            }
+        }
+    }
+}
+
+func (v *triageView) draw() {
+    width, height, err := term.GetSize(int(os.Stdout.Fd()))
+    if err != nil || width <= 0 || height <= 0 {
+        width, height = 80, 24
+    }
+    var b strings.Builder
+    b.WriteString("\x1b[H\x1b[2J")
+    counts := map[string]int{}
+    for _, it := range v.items {
+        counts[it.Status]++
+    }
+    title := i18n.Sprintf("分诊 %d 处匹配：保留 %d  忽略 %d  待跟进 %d  未处理 %d", len(v.items),
+        counts[triageKeep], counts[triageIgnore], counts[triageFollowUp], counts[""])
+    if v.undecided {
+        title += i18n.T("（只看未处理）")
+    }
+    fmt.Fprintf(&b, "\x1b[1m%s\x1b[0m\r\n", truncateWidth(title, width))
+
+    // 留出标题、选中项的详情（备注与 URL）和状态栏
+    rows := max(height-5, 1)
+    top := 0
+    if v.cursor >= rows {
+        top = v.cursor - rows + 1
+    }
+    for j := top; j < len(v.visible) && j < top+rows; j++ {
+        it := v.items[v.visible[j]]
+        sign, color := " ", ""
+        switch it.Status {
+        case triageKeep:
+            sign, color = "✓", "\x1b[32m"
+        case triageIgnore:
+            sign, color = "✗", "\x1b[2m"
+        case triageFollowUp:
+            sign, color = "?", "\x1b[33m"
+        }
+        loc := fmt.Sprintf("%s/%s:%d", it.Repo, it.Path, it.Line)
+        line := truncateWidth(preview.Render(loc+"  "+strings.TrimSpace(it.Preview)), width-4)
+        if j == v.cursor {
+            fmt.Fprintf(&b, "\x1b[7m%s %s \x1b[0m\r\n", sign, preview.Pad(line, width-3))
+        } else {
+            fmt.Fprintf(&b, "%s%s %s\x1b[0m\r\n", color, sign, line)
+        }
+    }
+    if len(v.visible) == 0 {
+        b.WriteString(i18n.T("全部处理完了；tab 显示全部，q 完成") + "\r\n")
+    } else {
+        it := v.items[v.visible[v.cursor]]
+        fmt.Fprintf(&b, "\x1b[%d;1H\x1b[2m%s\x1b[0m", height-2, truncateWidth(it.URL, width))
+        if it.Note != "" {
+            fmt.Fprintf(&b, "\x1b[%d;1H%s", height-1, truncateWidth(preview.Render(i18n.Sprintf("备注: %s", it.Note)), width))
+        }
+    }
+    fmt.Fprintf(&b, "\x1b[%d;1H\x1b[2m", height)
+    if v.status != "" {
+        b.WriteString(truncateWidth(preview.Render(v.status), width))
+    } else {
+        help := i18n.T("a 保留  x 忽略  f 待跟进  u 撤销  c 备注  n 下一个  回车 查看  tab 未处理  q 完成")
+        b.WriteString(truncateWidth(fmt.Sprintf("%d/%d  %s", v.cursor+1, len(v.visible), help), width))
+    }
+    b.WriteString("\x1b[0m")
+    os.Stdout.WriteString(b.String())
+}
+
+// triageSummary 是退出时打印的统计
+func triageSummary(items []*TriageItem) string {
+    counts := map[string]int{}
+    for _, it := range items {
+        counts[it.Status]++
+    }
+    return i18n.Sprintf("保留 %d，忽略 %d，待跟进 %d，未处理 %d\n", counts[triageKeep], counts[triageIgnore], counts[triageFollowUp], counts[""])
+}
+
+// exportTriage 导出保留与待跟进的匹配；format 为空时按扩展名选择
+func exportTriage(output, format string, items []*TriageItem) error {
+    if format == "" {
+        format = "json"
+        if ext := strings.ToLower(filepath.Ext(output)); ext == ".md" || ext == ".markdown" {
+            format = "markdown"
+        }
+    }
+    var kept []*TriageItem
+    for _, it := range items {
+        if it.Status == triageKeep || it.Status == triageFollowUp {
+            kept = append(kept, it)
+        }
+    }
+    var w io.Writer = os.Stdout
+    if output != "-" {
+        f, err := os.Create(output)
+        if err != nil {
+            return err
+        }
+        defer f.Close()
+        w = f
+    }
+    if format == "json" {
+        if kept == nil {
+            kept = []*TriageItem{}
+        }
+        if err := writeJSON(w, kept); err != nil {
+            return err
+        }
+    } else {
+        writeTriageMarkdown(w, kept)
+    }
+    if output != "-" {
+        fmt.Fprint(os.Stderr, i18n.Sprintf("已导出 %d 处匹配到 %s\n", len(kept), output))
+    }
+    return nil
+}
+
+// writeTriageMarkdown 按结论分节、节内按仓库分组写出 Markdown 清单
+func writeTriageMarkdown(w io.Writer, items []*TriageItem) {
+    sections := []struct{ status, title string }{
+        {triageKeep, i18n.T("保留")},
+        {triageFollowUp, i18n.T("待跟进")},
+    }
+    for _, s := range sections {
+        var list []*TriageItem
+        for _, it := range items {
+            if it.Status == s.status {
+                list = append(list, it)
+            }
+        }
+        if len(list) == 0 {
+            continue
+        }
+        fmt.Fprintf(w, "## %s (%d)\n\n", s.title, len(list))
+        repo := ""
+        for _, it := range list {
+            if it.Repo != repo {
+                if repo != "" {
+                    fmt.Fprintln(w)
+                }
+                repo = it.Repo
+                fmt.Fprintf(w, "### %s\n\n", repo)
+            }
+            fmt.Fprintf(w, "- [ ] [%s:%d](%s) `%s`", it.Path, it.Line, it.URL, strings.ReplaceAll(strings.TrimSpace(it.Preview), "`", "'"))
+            if it.Note != "" {
+                fmt.Fprintf(w, " — %s", it.Note)
+            }
+            fmt.Fprintln(w)
+        }
+        fmt.Fprintln(w)
+    }
+}
+
+func init() { rootCmd.AddCommand(newTriageCmd()) }
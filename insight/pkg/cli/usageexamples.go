@@ -0,0 +1,343 @@
+package cli
+
+import (
+    "context"
+    "fmt"
+    "os"
+    "regexp"
+    "sort"
+    "strings"
+
+    "github.com/spf13/cobra"
+    "kingbrain/insight/pkg/graph"
+    "kingbrain/insight/pkg/i18n"
+    "kingbrain/insight/pkg/preview"
+    "kingbrain/insight/pkg/sg"
+)
+
+// UsageExample 是一类调用形状的代表调用点
+type UsageExample struct {
+    Repo      string   `json:"repo"`
+    Path      string   `json:"path"`
+    Line      int      `json:"line"`
+    Stars     int      `json:"stars,omitempty"`
+    Shape     string   `json:"shape"`
+    Similar   int      `json:"similar"` // 同形状的其他调用点数
+    StartLine int      `json:"startLine,omitempty"`
+    Snippet   []string `json:"snippet,omitempty"`
+    URL       string   `json:"url"`
+}
+
+var (
+    shapeStrRe   = regexp.MustCompile("\"(?:\\\\.|[^\"\\\\])*\"|'(?:\\\\.|[^'\\\\])*'|`[^`]*`")
+    shapeNumRe   = regexp.MustCompile(`\b\d[\w.]*`)
+    shapeIdentRe = regexp.MustCompile(`[A-Za-z_]\w*`)
+    shapeSpaceRe = regexp.MustCompile(`\s+`)
+)
+
+func newUsageExamplesCmd() *cobra.Command {
+    var (
+        repos    []string
+        lang     string
+        count    int
+        ctxLines int
+        maxFiles int
+        offline  bool
+        format   string
+    )
+
+    cmd := &cobra.Command{
+        Use:   "usage-examples <name>",
+        Short: "为函数/类型挑选几个有代表性的调用示例（跨仓库、按调用形状去重、按仓库热度排序）",
+        Long: `搜索标识符在整个实例中的出现位置，跳过定义与注释，把调用行归一化成"形状"
+（字面量、其他标识符抹掉）后去重，每种形状保留一个代表；再按仓库 star 数排序，
+优先从不同仓库各取一个，最后拉取文件打印上下文。-f dot|mermaid 改为输出调用关系图：各仓库指向该标识符，
+边上标注仓库中的调用点数，可直接交给 Graphviz 或贴进 Markdown 文档。
+
+  kb usage-examples http.NewRequestWithContext -n 3
+  kb usage-examples NewClient --lang go --repo 'github.com/acme/*'
+  kb usage-examples NewClient -f dot | dot -Tsvg > usage.svg`,
+        Args: cobra.ExactArgs(1),
+        RunE: func(cmd *cobra.Command, args []string) error {
+            if err := checkFormat(format, "text", "json", "dot", "mermaid"); err != nil {
+                return err
+            }
+            c := sg.New()
+            examples, calls, err := findUsageExamples(cmd.Context(), c, args[0], lang, repos, maxFiles, offline)
+            if err != nil {
+                return err
+            }
+            if format == "dot" || format == "mermaid" {
+                return usageGraph(args[0], calls).Write(os.Stdout, format)
+            }
+            total := 0
+            for _, n := range calls {
+                total += n
+            }
+            if len(examples) > count {
+                examples = examples[:count]
+            }
+            fillSnippets(cmd.Context(), c, examples, ctxLines)
+            if format == "json" {
+                return writeJSON(os.Stdout, examples)
+            }
+            fmt.Print(i18n.Sprintf("%s：%d 处调用，显示 %d 个代表示例\n\n", args[0], total, len(examples)))
+            for _, e := range examples {
+                head := fmt.Sprintf("%s/%s:%d", e.Repo, e.Path, e.Line)
+                if e.Stars > 0 {
+                    head += fmt.Sprintf("  ★%d", e.Stars)
+                }
+                if e.Similar > 0 {
+                    head += i18n.Sprintf("  (+%d 处同形状调用)", e.Similar)
+                }
+                fmt.Println(head)
+                for i, l := range e.Snippet {
+                    mark := " "
+                    if e.StartLine+i == e.Line {
+                        mark = ">"
+                    }
+                    fmt.Printf("  %s %5d | %s\n", mark, e.StartLine+i, preview.Render(l))
+                }
+                fmt.Println()
+            }
+            return nil
+        },
+    }
+
+    cmd.Flags().StringSliceVar(&repos, "repo", nil, "限定仓库（可重复，支持正则；含 * ? 的 glob 按仓库缓存展开）")
+    cmd.Flags().StringVar(&lang, "lang", "", "限定语言（Sourcegraph lang: 过滤器）")
+    cmd.Flags().IntVarP(&count, "count", "n", 5, "显示的示例数")
+    cmd.Flags().IntVarP(&ctxLines, "context", "C", 3, "每个示例前后显示的行数")
+    cmd.Flags().IntVar(&maxFiles, "max-files", 500, "最多检查的文件数（0 为不限制）")
+    cmd.Flags().BoolVar(&offline, "offline", false, "只用本地仓库缓存里的 star 数，不联网刷新")
+    cmd.Flags().StringVarP(&format, "format", "f", "text", "输出格式：text|json|dot|mermaid")
+    return cmd
+}
+
+// usageGraph 画出 仓库 → 标识符 的调用关系图，边上标注调用点数，调用多的仓库在前
+func usageGraph(name string, calls map[string]int) *graph.Graph {
+    repos := make([]string, 0, len(calls))
+    for r := range calls {
+        repos = append(repos, r)
+    }
+    sort.Slice(repos, func(i, j int) bool {
+        if calls[repos[i]] != calls[repos[j]] {
+            return calls[repos[i]] > calls[repos[j]]
+        }
+        return repos[i] < repos[j]
+    })
+    g := graph.New("usage " + name)
+    g.AddNode(graph.Node{ID: name, Color: "#d1ecf1"})
+    for _, r := range repos {
+        g.AddEdge(r, name, i18n.Sprintf("%d 处调用", calls[r]))
+    }
+    return g
+}
+
+// findUsageExamples 返回按形状去重、排好序的示例（尚未拉取上下文）与各仓库的调用点数
+func findUsageExamples(ctx context.Context, c *sg.Client, name, lang string, repos []string, maxFiles int, offline bool) ([]UsageExample, map[string]int, error) {
+    // 只取最后一段作为标识符：http.NewRequest → NewRequest
+    ident := name[strings.LastIndex(name, ".")+1:]
+    langFilter := ""
+    if lang != "" {
+        langFilter = "lang:" + lang
+    }
+    q := buildQuery(`\b`+regexp.QuoteMeta(ident)+`\b`, langFilter, repoFilter(repos), countFilter(maxFiles))
+    res, err := c.Search(ctx, q, "regexp")
+    if err != nil {
+        return nil, nil, err
+    }
+
+    stars := map[string]int{}
+    if cache, err := cachedRepos(ctx, c, offline); err != nil {
+        fmt.Fprint(os.Stderr, i18n.Sprintf("警告: 读取仓库缓存失败，不按热度排序: %v\n", err))
+    } else {
+        for _, r := range cache.Repos {
+            stars[r.Name] = r.Stars
+        }
+    }
+
+    defRe := regexp.MustCompile(`\b(?:func|type|class|def|interface|struct|enum|trait|fn)\s+(?:\([^)]*\)\s*)?` + regexp.QuoteMeta(ident) + `\b`)
+    inComment := blockCommentLines(ctx, c, res)
+    byShape := map[string]*UsageExample{}
+    var order []string
+    calls := map[string]int{}
+    for _, fm := range res.Results {
+        spec := sg.FileSpec{Repo: fm.Repository.Name, Path: fm.File.Path}
+        for _, m := range fm.LineMatches {
+            line := strings.TrimSpace(m.Preview)
+            if defRe.MatchString(line) || isCommentLine(line) || strings.HasPrefix(line, "*") && inComment[spec][m.LineNumber] {
+                continue
+            }
+            calls[fm.Repository.Name]++
+            shape := usageShape(line, ident)
+            if e, ok := byShape[shape]; ok {
+                e.Similar++
+                continue
+            }
+            byShape[shape] = &UsageExample{
+                Repo:  fm.Repository.Name,
+                Path:  fm.File.Path,
+                Line:  m.LineNumber + 1,
+                Stars: stars[fm.Repository.Name],
+                Shape: shape,
+                URL:   c.URL(fm.File.URL) + fmt.Sprintf("?L%d", m.LineNumber+1),
+            }
+            order = append(order, shape)
+        }
+    }
+
+    cands := make([]UsageExample, 0, len(order))
+    for _, s := range order {
+        cands = append(cands, *byShape[s])
+    }
+    sort.SliceStable(cands, func(i, j int) bool {
+        if cands[i].Stars != cands[j].Stars {
+            return cands[i].Stars > cands[j].Stars
+        }
+        return cands[i].Similar > cands[j].Similar
+    })
+    return diversify(cands), calls, nil
+}
+
+// diversify 保持原有顺序，但先让每个仓库各出一个示例，其余的排在后面
+func diversify(cands []UsageExample) []UsageExample {
+    seen := map[string]bool{}
+    var first, rest []UsageExample
+    for _, e := range cands {
+        if seen[e.Repo] {
+            rest = append(rest, e)
+            continue
+        }
+        seen[e.Repo] = true
+        first = append(first, e)
+    }
+    return append(first, rest...)
+}
+
+// usageShape 把调用行归一化：字面量变成 S/N，除目标外的标识符变成 _，去掉空白
+func usageShape(line, ident string) string {
+    s := shapeStrRe.ReplaceAllString(line, "S")
+    s = shapeNumRe.ReplaceAllString(s, "N")
+    s = shapeIdentRe.ReplaceAllStringFunc(s, func(w string) string {
+        if w == ident || w == "S" || w == "N" {
+            return w
+        }
+        return "_"
+    })
+    return shapeSpaceRe.ReplaceAllString(s, "")
+}
+
+// isCommentLine 判断一行是否以注释开头。以 * 开头的行可能是 /* */ 注释块的续行，也可能是 *p = x 这样的代码，
+// 单看一行分不出来，由 blockCommentLines 结合文件内容判断
+func isCommentLine(line string) bool {
+    for _, p := range []string{"//", "#", "/*", "--"} {
+        if strings.HasPrefix(line, p) {
+            return true
+        }
+    }
+    return false
+}
+
+// blockCommentLines 拉取有以 * 开头的匹配行的文件，返回各文件中位于 /* */ 注释块内的行号（从 0 开始）。
+// 拉取失败的文件把这些行都当作注释，与只看行首时一样
+func blockCommentLines(ctx context.Context, c *sg.Client, res *sg.SearchResults) map[sg.FileSpec]map[int]bool {
+    var specs []sg.FileSpec
+    starred := map[sg.FileSpec][]int{}
+    for _, fm := range res.Results {
+        spec := sg.FileSpec{Repo: fm.Repository.Name, Path: fm.File.Path}
+        for _, m := range fm.LineMatches {
+            if strings.HasPrefix(strings.TrimSpace(m.Preview), "*") {
+                if starred[spec] == nil {
+                    specs = append(specs, spec)
+                }
+                starred[spec] = append(starred[spec], m.LineNumber)
+            }
+        }
+    }
+    out := map[sg.FileSpec]map[int]bool{}
+    if len(specs) == 0 {
+        return out
+    }
+    for _, r := range c.GetFiles(ctx, specs) {
+        if r.Err != nil {
+            lines := map[int]bool{}
+            for _, n := range starred[r.FileSpec] {
+                lines[n] = true
+            }
+            out[r.FileSpec] = lines
+            continue
+        }
+        out[r.FileSpec] = scanBlockComments(r.Content)
+    }
+    return out
+}
+
+// scanBlockComments 扫描源码，返回与 /* */ 注释块有交集的行号（从 0 开始）。
+// 跳过字符串字面量与 // 行注释里的 /*，字符串不跨行
+func scanBlockComments(src string) map[int]bool {
+    lines := map[int]bool{}
+    line, inBlock := 0, false
+    var quote byte
+    for i := 0; i < len(src); i++ {
+        ch := src[i]
+        if inBlock {
+            lines[line] = true
+        }
+        next := byte(0)
+        if i+1 < len(src) {
+            next = src[i+1]
+        }
+        switch {
+        case ch == '\n':
+            line++
+            quote = 0
+        case inBlock:
+            if ch == '*' && next == '/' {
+                inBlock = false
+                i++
+            }
+        case quote != 0:
+            if ch == '\\' && next != '\n' {
+                i++
+            } else if ch == quote {
+                quote = 0
+            }
+        case ch == '"' || ch == '\'' || ch == '`':
+            quote = ch
+        case ch == '/' && next == '/':
+            for i+1 < len(src) && src[i+1] != '\n' {
+                i++
+            }
+        case ch == '/' && next == '*':
+            inBlock = true
+            lines[line] = true
+            i++
+        }
+    }
+    return lines
+}
+
+// fillSnippets 批量拉取示例所在文件并截取匹配行前后 n 行；拉取失败的示例只保留位置
+func fillSnippets(ctx context.Context, c *sg.Client, examples []UsageExample, n int) {
+    specs := make([]sg.FileSpec, len(examples))
+    for i, e := range examples {
+        specs[i] = sg.FileSpec{Repo: e.Repo, Path: e.Path}
+    }
+    for i, r := range c.GetFiles(ctx, specs) {
+        e := &examples[i]
+        if r.Err != nil {
+            fmt.Fprint(os.Stderr, i18n.Sprintf("警告: 拉取 %s/%s 失败: %v\n", e.Repo, e.Path, r.Err))
+            continue
+        }
+        lines := strings.Split(strings.TrimRight(r.Content, "\n"), "\n")
+        start, end := max(e.Line-n, 1), min(e.Line+n, len(lines))
+        if start > end {
+            continue
+        }
+        e.StartLine = start
+        e.Snippet = lines[start-1 : end]
+    }
+}
+
+func init() { rootCmd.AddCommand(newUsageExamplesCmd()) }
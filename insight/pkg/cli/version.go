@@ -0,0 +1,37 @@
+package cli
+
+import (
+    "fmt"
+    "os"
+
+    "github.com/spf13/cobra"
+    "kingbrain/insight/pkg/version"
+)
+
+func newVersionCmd() *cobra.Command {
+    var format string
+
+    cmd := &cobra.Command{
+        Use:   "version",
+        Short: "显示版本、提交与构建时间",
+        Args:  cobra.NoArgs,
+        RunE: func(cmd *cobra.Command, _ []string) error {
+            if err := checkFormat(format, "text", "json"); err != nil {
+                return err
+            }
+            if format == "json" {
+                return writeJSON(os.Stdout, version.Get())
+            }
+            fmt.Println("kb", version.Get())
+            return nil
+        },
+    }
+
+    cmd.Flags().StringVarP(&format, "format", "f", "text", "输出格式：text|json")
+    return cmd
+}
+
+func init() {
+    rootCmd.Version = version.Get().String()
+    rootCmd.AddCommand(newVersionCmd())
+}
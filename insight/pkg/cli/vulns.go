@@ -0,0 +1,283 @@
+package cli
+
+import (
+    "context"
+    "fmt"
+    "os"
+    "sort"
+    "strconv"
+    "strings"
+
+    "github.com/spf13/cobra"
+    "kingbrain/insight/pkg/baseline"
+    "kingbrain/insight/pkg/i18n"
+    "kingbrain/insight/pkg/manifest"
+    "kingbrain/insight/pkg/osv"
+    "kingbrain/insight/pkg/progress"
+    "kingbrain/insight/pkg/sg"
+)
+
+// VulnHit 是某仓库清单中的一个依赖命中了一条已知漏洞
+type VulnHit struct {
+    Repo      string   `json:"repo"`
+    Path      string   `json:"path"`
+    Line      int      `json:"line"`
+    Ecosystem string   `json:"ecosystem"`
+    Package   string   `json:"package"`
+    Version   string   `json:"version"`
+    ID        string   `json:"id"`
+    Aliases   []string `json:"aliases,omitempty"`
+    Severity  string   `json:"severity"`
+    Summary   string   `json:"summary"`
+    Fixed     string   `json:"fixed,omitempty"`
+    URL       string   `json:"url"`
+}
+
+type manifestDep struct {
+    repo, path, url string
+    dep             manifest.Dependency
+}
+
+func newVulnsCmd() *cobra.Command {
+    var (
+        repos       []string
+        minSeverity string
+        format      string
+        maxFiles    int
+        exports     []string
+        bl          baselineFlags
+    )
+
+    cmd := &cobra.Command{
+        Use:   "vulns",
+        Short: "从各仓库的依赖清单中提取依赖，查询 OSV.dev 已知漏洞并报告受影响的仓库与版本",
+        Long: `搜索 go.mod/package.json/requirements*.txt，逐个拉取并解析依赖，
+再批量查询 OSV.dev（可用 OSV_API_URL 指向镜像）。范围写法的版本（^1.2、>=2.0）
+按其下限版本查询。--baseline 时只报告（和导出）基线之外的新漏洞，有新漏洞时以退出码 1 结束。例如：
+
+  kb vulns --repo '^github.com/acme/' --min-severity high -f sarif > vulns.sarif
+  kb vulns --repo '^github.com/acme/' --baseline vulns-baseline.json`,
+        Args: cobra.NoArgs,
+        RunE: func(cmd *cobra.Command, _ []string) error {
+            if err := checkFormat(format, "text", "json", "sarif"); err != nil {
+                return err
+            }
+            if minSeverity != "" && osv.Rank(minSeverity) == 0 {
+                return i18n.Errorf("--min-severity 只能是 low|moderate|high|critical")
+            }
+            known, err := bl.load("vulns")
+            if err != nil {
+                return err
+            }
+            c := sg.New()
+            deps, err := fetchManifestDeps(cmd.Context(), c, repos, maxFiles)
+            if err != nil {
+                return err
+            }
+            hits, err := scanVulns(cmd.Context(), osv.New(), deps, minSeverity)
+            if err != nil {
+                return err
+            }
+            var findings []baseline.Finding
+            var fresh []VulnHit
+            for _, h := range hits {
+                f := vulnBaselineFinding(h)
+                findings = append(findings, f)
+                if !known.Has(f.Fingerprint) {
+                    fresh = append(fresh, h)
+                }
+            }
+            if err := bl.save("vulns", findings); err != nil {
+                return err
+            }
+            if err := printVulns(format, fresh); err != nil {
+                return err
+            }
+            run := newRun("vulns", strings.Join(repos, ","))
+            run.Matches = exportFindings(vulnFindings(fresh))
+            if err := runExports(exports, run); err != nil {
+                return err
+            }
+            return bl.failOnNew(cmd, len(fresh), len(hits)-len(fresh))
+        },
+    }
+
+    cmd.Flags().StringSliceVar(&repos, "repo", nil, "限定仓库（可重复，支持正则；含 * ? 的 glob 按仓库缓存展开）")
+    cmd.Flags().StringVar(&minSeverity, "min-severity", "", "只报告不低于该等级的漏洞：low|moderate|high|critical")
+    cmd.Flags().StringVarP(&format, "format", "f", "text", "输出格式：text|json|sarif")
+    cmd.Flags().IntVar(&maxFiles, "max-files", 500, "最多拉取的清单文件数")
+    addExportFlag(cmd, &exports)
+    addBaselineFlags(cmd, &bl)
+    return cmd
+}
+
+// fetchManifestDeps 找出清单文件并批量拉取解析
+func fetchManifestDeps(ctx context.Context, c *sg.Client, repos []string, maxFiles int) ([]manifestDep, error) {
+    res, err := c.Search(ctx, buildQuery(manifest.FileFilter, repoFilter(repos), "type:path", countFilter(maxFiles)), "literal")
+    if err != nil {
+        return nil, err
+    }
+    files := res.Results
+    if len(files) > maxFiles {
+        files = files[:maxFiles]
+    }
+    specs := make([]sg.FileSpec, len(files))
+    for i, fm := range files {
+        specs[i] = sg.FileSpec{Repo: fm.Repository.Name, Path: fm.File.Path}
+    }
+    bar := progress.New(len(files))
+    fetched := c.GetFilesFunc(ctx, specs, func(r sg.FileResult) { bar.End(r.Repo+"/"+r.Path, r.Err) })
+    bar.Finish()
+    var deps []manifestDep
+    for i, fm := range files {
+        if fetched[i].Err != nil {
+            continue
+        }
+        for _, d := range manifest.Parse(fm.File.Path, fetched[i].Content) {
+            deps = append(deps, manifestDep{repo: fm.Repository.Name, path: fm.File.Path, url: c.URL(fm.File.URL), dep: d})
+        }
+    }
+    return deps, nil
+}
+
+// scanVulns 对去重后的 (生态, 包, 版本) 批量查询 OSV，再展开回每个仓库
+func scanVulns(ctx context.Context, oc *osv.Client, deps []manifestDep, minSeverity string) ([]VulnHit, error) {
+    idx := map[osv.Query]int{}
+    var queries []osv.Query
+    for _, d := range deps {
+        v := manifest.CleanVersion(d.dep.Version)
+        if v == "" || strings.ContainsAny(v, "*x") {
+            continue // 未锁定版本，无法判断
+        }
+        q := osv.Query{Package: osv.Package{Name: d.dep.Name, Ecosystem: d.dep.Ecosystem}, Version: v}
+        if _, ok := idx[q]; !ok {
+            idx[q] = len(queries)
+            queries = append(queries, q)
+        }
+    }
+    if len(queries) == 0 {
+        return nil, nil
+    }
+    ids, err := oc.QueryBatch(ctx, queries)
+    if err != nil {
+        return nil, err
+    }
+
+    details := map[string]*osv.Vuln{}
+    var hits []VulnHit
+    for _, d := range deps {
+        q := osv.Query{Package: osv.Package{Name: d.dep.Name, Ecosystem: d.dep.Ecosystem}, Version: manifest.CleanVersion(d.dep.Version)}
+        i, ok := idx[q]
+        if !ok {
+            continue
+        }
+        for _, id := range ids[i] {
+            v, ok := details[id]
+            if !ok {
+                if v, err = oc.Get(ctx, id); err != nil {
+                    return nil, err
+                }
+                details[id] = v
+            }
+            if minSeverity != "" && osv.Rank(v.Level()) < osv.Rank(minSeverity) {
+                continue
+            }
+            url := d.url
+            if d.dep.Line > 0 {
+                url = fmt.Sprintf("%s?L%d", d.url, d.dep.Line)
+            }
+            hits = append(hits, VulnHit{
+                Repo: d.repo, Path: d.path, Line: d.dep.Line,
+                Ecosystem: d.dep.Ecosystem, Package: d.dep.Name, Version: d.dep.Version,
+                ID: id, Aliases: v.Aliases, Severity: v.Level(), Summary: v.Summary,
+                Fixed: v.Fixed(q.Package), URL: url,
+            })
+        }
+    }
+    sort.SliceStable(hits, func(i, j int) bool {
+        a, b := hits[i], hits[j]
+        if osv.Rank(a.Severity) != osv.Rank(b.Severity) {
+            return osv.Rank(a.Severity) > osv.Rank(b.Severity)
+        }
+        if a.Repo != b.Repo {
+            return a.Repo < b.Repo
+        }
+        return a.ID < b.ID
+    })
+    return hits, nil
+}
+
+func vulnFindings(hits []VulnHit) []Finding {
+    out := make([]Finding, 0, len(hits))
+    for _, h := range hits {
+        out = append(out, Finding{Repo: h.Repo, Path: h.Path, Line: h.Line, Rule: h.ID,
+            Text: fmt.Sprintf("%s@%s: %s", h.Package, h.Version, h.Summary), URL: h.URL})
+    }
+    return out
+}
+
+// vulnBaselineFinding 按 漏洞 ID + 依赖版本 识别，升级到仍受影响的另一个版本算作新发现
+func vulnBaselineFinding(h VulnHit) baseline.Finding {
+    return baseline.Finding{
+        Fingerprint: baseline.Fingerprint("vulns", h.ID, h.Repo, h.Path, h.Package+"@"+h.Version),
+        Rule:        h.ID,
+        Repo:        h.Repo,
+        Path:        h.Path,
+        Line:        h.Line,
+        Text:        h.Package + "@" + h.Version,
+    }
+}
+
+func printVulns(format string, hits []VulnHit) error {
+    switch format {
+    case "json":
+        return writeJSON(os.Stdout, hits)
+    case "sarif":
+        var rules []sarifRule
+        var results []sarifResult
+        seen := map[string]bool{}
+        for _, h := range hits {
+            if !seen[h.ID] {
+                seen[h.ID] = true
+                rules = append(rules, sarifRule{ID: h.ID, Summary: h.Summary, HelpURI: "https://osv.dev/vulnerability/" + h.ID})
+            }
+            msg := i18n.Sprintf("%s %s 受 %s 影响", h.Package, h.Version, h.ID)
+            if h.Fixed != "" {
+                msg += i18n.Sprintf("，修复版本 %s", h.Fixed)
+            }
+            results = append(results, sarifResult{Rule: h.ID, Level: sarifLevel(h.Severity), Message: msg, Repo: h.Repo, Path: h.Path, Line: h.Line})
+        }
+        return writeSARIF(os.Stdout, rules, results)
+    }
+
+    if len(hits) == 0 {
+        fmt.Println(i18n.T("未发现已知漏洞"))
+        return nil
+    }
+    repos := map[string]bool{}
+    for _, h := range hits {
+        repos[h.Repo] = true
+    }
+    fmt.Print(i18n.Sprintf("%d 个仓库共 %d 处漏洞依赖\n\n", len(repos), len(hits)))
+    for _, h := range hits {
+        fixed := ""
+        if h.Fixed != "" {
+            fixed = " → " + h.Fixed
+        }
+        fmt.Printf("%-8s %-20s %s@%s%s\n         %s/%s:%s  %s\n", h.Severity, h.ID, h.Package, h.Version, fixed,
+            h.Repo, h.Path, strconv.Itoa(h.Line), h.Summary)
+    }
+    return nil
+}
+
+func sarifLevel(severity string) string {
+    switch osv.Rank(severity) {
+    case 4, 3:
+        return "error"
+    case 2:
+        return "warning"
+    }
+    return "note"
+}
+
+func init() { rootCmd.AddCommand(newVulnsCmd()) }
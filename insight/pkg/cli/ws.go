@@ -0,0 +1,363 @@
+package cli
+
+import (
+    "bufio"
+    "bytes"
+    "context"
+    "errors"
+    "fmt"
+    "io"
+    "os"
+    "os/exec"
+    "path/filepath"
+    "sort"
+    "strings"
+    "sync"
+    "time"
+
+    "github.com/spf13/cobra"
+    "kingbrain/insight/pkg/config"
+    "kingbrain/insight/pkg/i18n"
+    "kingbrain/insight/pkg/progress"
+    "kingbrain/insight/pkg/sg"
+)
+
+// wsTarget 是工作区中的一个仓库及其本地检出目录；找不到检出时 Err 非空
+type wsTarget struct {
+    Repo string
+    Dir  string
+    Err  error
+}
+
+// WSResult 是 ws run 在一个仓库中执行命令的结果
+type WSResult struct {
+    Repo       string `json:"repo"`
+    Dir        string `json:"dir,omitempty"`
+    Status     string `json:"status"` // ok|failed|missing|unchanged
+    ExitCode   int    `json:"exitCode"`
+    DurationMS int64  `json:"durationMs"`
+    Output     string `json:"output,omitempty"` // stdout 与 stderr 按写入顺序合并
+    Error      string `json:"error,omitempty"`
+    URL        string `json:"url,omitempty"` // ws commit 创建或找到的 PR
+}
+
+// wsSource 是 ws 子命令共用的仓库来源 flag
+type wsSource struct {
+    query     string
+    pattern   string
+    reposFile string
+}
+
+func (s *wsSource) addFlags(cmd *cobra.Command) {
+    cmd.Flags().StringVarP(&s.query, "query", "q", "", "用搜索结果中出现的仓库作为目标（- 表示从 stdin 读取查询）")
+    cmd.Flags().StringVarP(&s.pattern, "pattern", "p", "literal", "--query 的搜索模式：literal|regexp|structural")
+    cmd.Flags().StringVar(&s.reposFile, "repos-file", "", "从文件读取仓库列表（每行一个，# 为注释，- 表示 stdin）")
+}
+
+// targets 解析仓库列表并映射到本地检出
+func (s *wsSource) targets(ctx context.Context) ([]wsTarget, error) {
+    var repos []string
+    switch {
+    case s.query != "" && s.reposFile != "":
+        return nil, i18n.Errorf("--query 与 --repos-file 只能指定一个")
+    case s.query != "":
+        q, err := readQueryArg(s.query)
+        if err != nil {
+            return nil, err
+        }
+        res, err := sg.New().Search(ctx, buildQuery(q, "count:all"), s.pattern)
+        if err != nil {
+            return nil, err
+        }
+        seen := map[string]bool{}
+        for _, m := range res.Results {
+            if name := m.Repository.Name; !seen[name] {
+                seen[name] = true
+                repos = append(repos, name)
+            }
+        }
+        sort.Strings(repos)
+    case s.reposFile != "":
+        var err error
+        if repos, err = readRepoList(s.reposFile); err != nil {
+            return nil, err
+        }
+    default:
+        return nil, i18n.Errorf("需要用 --query 或 --repos-file 指定仓库")
+    }
+    if len(repos) == 0 {
+        return nil, i18n.Errorf("没有匹配的仓库")
+    }
+    cfg, err := config.Load()
+    if err != nil {
+        return nil, err
+    }
+    out := make([]wsTarget, len(repos))
+    for i, repo := range repos {
+        dir, err := findCheckout(cfg.Workspace, repo)
+        out[i] = wsTarget{Repo: repo, Dir: dir, Err: err}
+    }
+    return out, nil
+}
+
+// readRepoList 读取每行一个仓库名的列表，忽略空行、# 注释与重复项
+func readRepoList(path string) ([]string, error) {
+    var r io.Reader = os.Stdin
+    if path != "-" {
+        f, err := os.Open(path)
+        if err != nil {
+            return nil, err
+        }
+        defer f.Close()
+        r = f
+    }
+    var out []string
+    seen := map[string]bool{}
+    sc := bufio.NewScanner(r)
+    for sc.Scan() {
+        line := strings.TrimSpace(sc.Text())
+        if line == "" || strings.HasPrefix(line, "#") || seen[line] {
+            continue
+        }
+        seen[line] = true
+        out = append(out, line)
+    }
+    return out, sc.Err()
+}
+
+func newWSCmd() *cobra.Command {
+    cmd := &cobra.Command{
+        Use:   "ws",
+        Short: "多仓库工作区：对搜索结果或仓库列表对应的本地检出批量执行命令",
+    }
+    cmd.AddCommand(newWSListCmd(), newWSRunCmd(), newWSCommitCmd())
+    return cmd
+}
+
+func newWSListCmd() *cobra.Command {
+    var src wsSource
+    cmd := &cobra.Command{
+        Use:   "list",
+        Short: "列出目标仓库与对应的本地检出目录",
+        Args:  cobra.NoArgs,
+        RunE: func(cmd *cobra.Command, _ []string) error {
+            targets, err := src.targets(cmd.Context())
+            if err != nil {
+                return err
+            }
+            for _, t := range targets {
+                if t.Err != nil {
+                    fmt.Print(i18n.Sprintf("%s\t（%v）\n", t.Repo, t.Err))
+                    continue
+                }
+                fmt.Printf("%s\t%s\n", t.Repo, t.Dir)
+            }
+            return nil
+        },
+    }
+    src.addFlags(cmd)
+    return cmd
+}
+
+func newWSRunCmd() *cobra.Command {
+    var (
+        src      wsSource
+        parallel int
+        shell    bool
+        format   string
+        outDir   string
+        quiet    bool
+    )
+
+    cmd := &cobra.Command{
+        Use:   "run (-q <query> | --repos-file <file>) -- <command> [args...]",
+        Short: "在每个目标仓库的本地检出中并发执行命令，汇总各仓库状态与失败原因",
+        Long: `类似 git submodule foreach，但目标仓库来自搜索结果或仓库列表文件，
+按 workspace.repos / workspace.roots 映射到本地检出。命令在检出根目录下执行，
+环境变量 INSIGHT_REPO 与 INSIGHT_REPO_DIR 为当前仓库名与目录。
+
+各仓库的输出在全部完成后按仓库名顺序打印，不会交错；找不到本地检出的仓库跳过并计入汇总。
+有仓库执行失败时命令以非零状态退出。
+
+  kb ws run -q 'github.com/pkg/errors file:go.mod' -- go get github.com/pkg/errors@v0.9.1
+  kb ws run --repos-file repos.txt -j 8 --sh -- 'git fetch && git status -sb'
+  kb ws run --repos-file repos.txt --out logs/ -- make test`,
+        RunE: func(cmd *cobra.Command, args []string) error {
+            if err := checkFormat(format, "text", "json"); err != nil {
+                return err
+            }
+            if cmd.ArgsLenAtDash() < 0 || len(args) == 0 {
+                return i18n.Errorf("用 -- 分隔要执行的命令，如 kb ws run -q <query> -- git status")
+            }
+            argv := args
+            if shell {
+                argv = []string{"sh", "-c", strings.Join(args, " ")}
+            }
+            targets, err := src.targets(cmd.Context())
+            if err != nil {
+                return err
+            }
+            results := runWorkspace(cmd.Context(), targets, parallel, func(ctx context.Context, t wsTarget) WSResult {
+                return runInCheckout(ctx, t, argv)
+            })
+            if outDir != "" {
+                if err := saveWSLogs(outDir, results); err != nil {
+                    return err
+                }
+            }
+            if format == "json" {
+                if err := writeJSON(os.Stdout, results); err != nil {
+                    return err
+                }
+            } else {
+                printWSResults(results, quiet)
+            }
+            return wsSummary(results)
+        },
+    }
+
+    src.addFlags(cmd)
+    cmd.Flags().IntVarP(&parallel, "parallel", "j", 4, "同时执行的仓库数")
+    cmd.Flags().BoolVar(&shell, "sh", false, "把命令交给 sh -c 执行（可以使用管道、&& 等）")
+    cmd.Flags().StringVarP(&format, "format", "f", "text", "输出格式：text|json")
+    cmd.Flags().StringVar(&outDir, "out", "", "把每个仓库的输出另存为 <dir>/<仓库名>.log")
+    cmd.Flags().BoolVar(&quiet, "quiet", false, "text 格式下只打印失败仓库的输出")
+    return cmd
+}
+
+// runWorkspace 用 parallel 个 worker 对各检出调用 fn，结果顺序与 targets 一致
+func runWorkspace(ctx context.Context, targets []wsTarget, parallel int, fn func(context.Context, wsTarget) WSResult) []WSResult {
+    results := make([]WSResult, len(targets))
+    bar := progress.New(len(targets))
+    idx := make(chan int)
+    var wg sync.WaitGroup
+    for w := 0; w < max(parallel, 1); w++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            for i := range idx {
+                t := targets[i]
+                bar.Begin(t.Repo)
+                r := fn(ctx, t)
+                results[i] = r
+                var err error
+                if r.Status == "failed" {
+                    err = errors.New(r.Status)
+                }
+                bar.End(t.Repo, err)
+            }
+        }()
+    }
+    for i := range targets {
+        idx <- i
+    }
+    close(idx)
+    wg.Wait()
+    bar.Finish()
+    return results
+}
+
+func runInCheckout(ctx context.Context, t wsTarget, argv []string) WSResult {
+    r := WSResult{Repo: t.Repo, Dir: t.Dir}
+    if t.Err != nil {
+        r.Status, r.ExitCode, r.Error = "missing", -1, t.Err.Error()
+        return r
+    }
+    var out bytes.Buffer
+    c := exec.CommandContext(ctx, argv[0], argv[1:]...)
+    c.Dir = t.Dir
+    c.Env = append(os.Environ(), "INSIGHT_REPO="+t.Repo, "INSIGHT_REPO_DIR="+t.Dir)
+    c.Stdout, c.Stderr = &out, &out
+    start := time.Now()
+    err := c.Run()
+    r.DurationMS = time.Since(start).Milliseconds()
+    r.Output = out.String()
+    var ee *exec.ExitError
+    switch {
+    case err == nil:
+        r.Status = "ok"
+    case errors.As(err, &ee):
+        r.Status, r.ExitCode, r.Error = "failed", ee.ExitCode(), err.Error()
+    default:
+        // 命令不存在等启动失败
+        r.Status, r.ExitCode, r.Error = "failed", -1, err.Error()
+    }
+    return r
+}
+
+func printWSResults(results []WSResult, quiet bool) {
+    for _, r := range results {
+        switch r.Status {
+        case "missing":
+            fmt.Print(i18n.Sprintf("==> %s：跳过（%s）\n", r.Repo, r.Error))
+            continue
+        case "unchanged":
+            fmt.Print(i18n.Sprintf("==> %s：没有改动\n", r.Repo))
+            continue
+        case "ok":
+            fmt.Print(i18n.Sprintf("==> %s：成功（%.1fs）\n", r.Repo, float64(r.DurationMS)/1000))
+            if quiet {
+                continue
+            }
+        default:
+            fmt.Print(i18n.Sprintf("==> %s：失败（%s，%.1fs）\n", r.Repo, r.Error, float64(r.DurationMS)/1000))
+        }
+        if out := strings.TrimRight(r.Output, "\n"); out != "" {
+            fmt.Println(out)
+        }
+    }
+}
+
+// wsSummary 在 stderr 打印汇总，有仓库失败时返回错误
+func wsSummary(results []WSResult) error {
+    var ok, missing, unchanged int
+    var failed []WSResult
+    for _, r := range results {
+        switch r.Status {
+        case "ok":
+            ok++
+        case "missing":
+            missing++
+        case "unchanged":
+            unchanged++
+        default:
+            failed = append(failed, r)
+        }
+    }
+    fmt.Fprint(os.Stderr, i18n.Sprintf("\n%d 个仓库：成功 %d，失败 %d，未检出 %d", len(results), ok, len(failed), missing))
+    if unchanged > 0 {
+        fmt.Fprint(os.Stderr, i18n.Sprintf("，没有改动 %d", unchanged))
+    }
+    fmt.Fprintln(os.Stderr)
+    if len(failed) == 0 {
+        return nil
+    }
+    for _, r := range failed {
+        fmt.Fprint(os.Stderr, i18n.Sprintf("  %s（%s）%s\n", r.Repo, r.Error, lastLine(r.Output)))
+    }
+    return i18n.Errorf("%d/%d 个仓库执行失败", len(failed), len(results))
+}
+
+// lastLine 返回输出的最后一个非空行，通常就是错误信息
+func lastLine(s string) string {
+    s = strings.TrimRight(s, "\n")
+    return strings.TrimSpace(s[strings.LastIndex(s, "\n")+1:])
+}
+
+func saveWSLogs(dir string, results []WSResult) error {
+    for _, r := range results {
+        if r.Status == "missing" || r.Status == "unchanged" {
+            continue
+        }
+        p := filepath.Join(dir, filepath.FromSlash(r.Repo)+".log")
+        if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+            return err
+        }
+        if err := os.WriteFile(p, []byte(r.Output), 0o644); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+func init() { rootCmd.AddCommand(newWSCmd()) }
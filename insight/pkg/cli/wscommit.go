@@ -0,0 +1,227 @@
+package cli
+
+import (
+    "bytes"
+    "context"
+    "fmt"
+    "os"
+    "strings"
+    "text/template"
+    "time"
+
+    "github.com/spf13/cobra"
+    "kingbrain/insight/pkg/i18n"
+    "kingbrain/insight/pkg/issues"
+)
+
+// wsCommitData 是提交说明模板可用的字段
+type wsCommitData struct {
+    Repo   string
+    Branch string
+    Dir    string
+    Files  []string // 有改动的文件（相对检出根目录）
+}
+
+type wsCommitOptions struct {
+    branch string
+    base   string
+    draft  bool
+    push   bool
+    pr     bool
+    dryRun bool
+}
+
+func newWSCommitCmd() *cobra.Command {
+    var (
+        src      wsSource
+        o        wsCommitOptions
+        tmplFile string
+        message  string
+        parallel int
+        format   string
+        noPush   bool
+        noPR     bool
+    )
+
+    cmd := &cobra.Command{
+        Use:   "commit (-q <query> | --repos-file <file>) --branch <name> --message-template <file>",
+        Short: "对有改动的检出统一建分支、提交、推送并创建 GitHub PR / GitLab MR",
+        Long: `通常在 ws run 批量修改之后使用。只处理工作区有改动的仓库，其余仓库记为没有改动。
+
+提交说明为 text/template，可用 .Repo .Branch .Dir .Files；渲染结果的第一行作为 PR 标题，
+其余部分作为 PR 正文。同名分支已有打开的 PR 时不重复创建。令牌取 GITHUB_TOKEN / GITLAB_TOKEN，
+与 --create-issues 相同。
+
+  kb ws commit --repos-file repos.txt --branch bump-errors --message-template msg.tmpl --dry-run
+  kb ws commit -q 'github.com/pkg/errors file:go.mod' --branch bump-errors -m 'Bump pkg/errors to v0.9.1' --draft`,
+        Args: cobra.NoArgs,
+        RunE: func(cmd *cobra.Command, _ []string) error {
+            if err := checkFormat(format, "text", "json"); err != nil {
+                return err
+            }
+            if o.branch == "" {
+                return i18n.Errorf("需要用 --branch 指定分支名")
+            }
+            if (tmplFile == "") == (message == "") {
+                return i18n.Errorf("--message-template 与 --message 需要且只能指定一个")
+            }
+            if tmplFile != "" {
+                b, err := os.ReadFile(tmplFile)
+                if err != nil {
+                    return err
+                }
+                message = string(b)
+            }
+            tmpl, err := template.New("message").Parse(message)
+            if err != nil {
+                return i18n.Errorf("提交说明模板: %w", err)
+            }
+            o.push, o.pr = !noPush, !noPR
+            if o.pr && !o.push {
+                return i18n.Errorf("--no-push 时不能创建 PR，请同时指定 --no-pr")
+            }
+            targets, err := src.targets(cmd.Context())
+            if err != nil {
+                return err
+            }
+            results := runWorkspace(cmd.Context(), targets, parallel, func(_ context.Context, t wsTarget) WSResult {
+                return commitInCheckout(t, tmpl, o)
+            })
+            if format == "json" {
+                if err := writeJSON(os.Stdout, results); err != nil {
+                    return err
+                }
+            } else {
+                printWSResults(results, false)
+            }
+            return wsSummary(results)
+        },
+    }
+
+    src.addFlags(cmd)
+    cmd.Flags().StringVar(&o.branch, "branch", "", "新建（或重置到当前提交）的分支名")
+    cmd.Flags().StringVar(&tmplFile, "message-template", "", "提交说明模板文件（text/template）")
+    cmd.Flags().StringVarP(&message, "message", "m", "", "直接给出提交说明模板，代替 --message-template")
+    cmd.Flags().StringVar(&o.base, "base", "", "PR 的目标分支（默认为仓库默认分支）")
+    cmd.Flags().BoolVar(&o.draft, "draft", false, "创建草稿 PR（GitLab 为 Draft: 前缀）")
+    cmd.Flags().BoolVar(&o.dryRun, "dry-run", false, "只显示每个仓库的改动与渲染后的提交说明，不修改仓库、不调用 API")
+    cmd.Flags().IntVarP(&parallel, "parallel", "j", 4, "同时处理的仓库数")
+    cmd.Flags().StringVarP(&format, "format", "f", "text", "输出格式：text|json")
+    cmd.Flags().BoolVar(&noPush, "no-push", false, "只在本地提交，不推送")
+    cmd.Flags().BoolVar(&noPR, "no-pr", false, "推送但不创建 PR")
+    return cmd
+}
+
+// commitInCheckout 在一个检出中完成 建分支 → 提交 → 推送 → 创建 PR，每一步的输出记入结果
+func commitInCheckout(t wsTarget, tmpl *template.Template, o wsCommitOptions) (r WSResult) {
+    r = WSResult{Repo: t.Repo, Dir: t.Dir}
+    if t.Err != nil {
+        r.Status, r.ExitCode, r.Error = "missing", -1, t.Err.Error()
+        return r
+    }
+    start := time.Now()
+    defer func() { r.DurationMS = time.Since(start).Milliseconds() }()
+    var log strings.Builder
+    fail := func(err error) WSResult {
+        r.Status, r.ExitCode, r.Error, r.Output = "failed", 1, err.Error(), log.String()
+        return r
+    }
+
+    status, err := gitRawOutput(t.Dir, "status", "--porcelain", "-z")
+    if err != nil {
+        return fail(err)
+    }
+    if status == "" {
+        r.Status = "unchanged"
+        return r
+    }
+    data := wsCommitData{Repo: t.Repo, Branch: o.branch, Dir: t.Dir, Files: porcelainFiles(status)}
+    var msg bytes.Buffer
+    if err := tmpl.Execute(&msg, data); err != nil {
+        return fail(err)
+    }
+    title, body, _ := strings.Cut(strings.TrimSpace(msg.String()), "\n")
+    if title = strings.TrimSpace(title); title == "" {
+        return fail(i18n.Errorf("提交说明的第一行为空"))
+    }
+
+    if o.dryRun {
+        fmt.Fprint(&log, i18n.Sprintf("分支: %s\n改动:\n%s\n提交说明:\n%s\n", o.branch, indent(strings.Join(data.Files, "\n")), indent(msg.String())))
+        steps := []string{i18n.T("提交")}
+        if o.push {
+            steps = append(steps, i18n.T("推送到 origin"))
+        }
+        if o.pr {
+            steps = append(steps, i18n.T("创建 PR"))
+        }
+        fmt.Fprint(&log, i18n.Sprintf("将会: %s", strings.Join(steps, " → ")))
+        r.Status, r.Output = "ok", log.String()
+        return r
+    }
+
+    for _, args := range [][]string{
+        {"checkout", "-B", o.branch},
+        {"add", "-A"},
+        {"commit", "-q", "-m", strings.TrimSpace(msg.String())},
+    } {
+        if _, err := gitOutput(t.Dir, args...); err != nil {
+            return fail(err)
+        }
+    }
+    head, _ := gitOutput(t.Dir, "rev-parse", "--short", "HEAD")
+    fmt.Fprint(&log, i18n.Sprintf("已提交 %s 到 %s\n", head, o.branch))
+    if o.push {
+        if _, err := gitOutput(t.Dir, "push", "-q", "-u", "origin", o.branch); err != nil {
+            return fail(err)
+        }
+        fmt.Fprint(&log, i18n.Sprintf("已推送到 origin/%s\n", o.branch))
+    }
+    if o.pr {
+        url, existed, err := openWSPull(t.Repo, issues.NewPull{Title: title, Body: strings.TrimSpace(body), Head: o.branch, Base: o.base, Draft: o.draft})
+        if err != nil {
+            return fail(err)
+        }
+        r.URL = url
+        if existed {
+            url += i18n.T("（已存在）")
+        }
+        fmt.Fprintf(&log, "PR: %s\n", url)
+    }
+    r.Status, r.Output = "ok", log.String()
+    return r
+}
+
+// openWSPull 创建 PR，同一分支已有打开的 PR 时返回原链接
+func openWSPull(repo string, p issues.NewPull) (url string, existed bool, err error) {
+    tr, err := issues.ForRepo(repo)
+    if err != nil {
+        return "", false, err
+    }
+    if url, found, err := tr.FindPull(p.Head); err != nil || found {
+        return url, found, err
+    }
+    url, err = tr.CreatePull(p)
+    return url, false, err
+}
+
+// porcelainFiles 从 git status --porcelain -z 的输出中取出文件路径：每条记录是 XY、空格与原样的路径，
+// 以 NUL 结尾；改名、复制（XY 含 R 或 C）时紧跟着一条只有原路径的记录，取新路径、跳过原路径
+func porcelainFiles(status string) []string {
+    var files []string
+    recs := strings.Split(status, "\x00")
+    for i := 0; i < len(recs); i++ {
+        rec := recs[i]
+        if len(rec) < 4 {
+            continue
+        }
+        files = append(files, rec[3:])
+        if strings.ContainsAny(rec[:2], "RC") {
+            i++
+        }
+    }
+    return files
+}
+
+func indent(s string) string {
+    return "  " + strings.ReplaceAll(strings.TrimRight(s, "\n"), "\n", "\n  ")
+}
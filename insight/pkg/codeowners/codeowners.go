@@ -0,0 +1,83 @@
+package codeowners
+
+import (
+    "regexp"
+    "strings"
+)
+
+// Candidates 是 GitHub/GitLab 查找 CODEOWNERS 的位置，按优先级排列
+var Candidates = []string{".github/CODEOWNERS", "CODEOWNERS", "docs/CODEOWNERS", ".gitlab/CODEOWNERS"}
+
+type rule struct {
+    re     *regexp.Regexp
+    owners []string
+}
+
+// File 是解析后的 CODEOWNERS；后面的规则优先于前面的
+type File struct {
+    rules []rule
+}
+
+// Parse 解析 CODEOWNERS 内容，忽略注释、空行、GitLab 的 [Section] 头与无法识别的模式
+func Parse(content string) *File {
+    f := &File{}
+    for _, line := range strings.Split(content, "\n") {
+        if i := strings.Index(line, " #"); i >= 0 {
+            line = line[:i]
+        }
+        fields := strings.Fields(line)
+        if len(fields) == 0 || strings.HasPrefix(fields[0], "#") || strings.HasPrefix(fields[0], "[") || strings.HasPrefix(fields[0], "^[") {
+            continue
+        }
+        re, err := compile(fields[0])
+        if err != nil {
+            continue
+        }
+        f.rules = append(f.rules, rule{re: re, owners: fields[1:]})
+    }
+    return f
+}
+
+// Owners 返回 path（仓库内相对路径，/ 分隔）的所有者；最后一条匹配的规则生效，
+// 没有匹配或规则不带所有者时返回 nil
+func (f *File) Owners(path string) []string {
+    path = strings.TrimPrefix(path, "/")
+    for i := len(f.rules) - 1; i >= 0; i-- {
+        if f.rules[i].re.MatchString(path) {
+            return f.rules[i].owners
+        }
+    }
+    return nil
+}
+
+// compile 按 gitignore 语义把模式转成正则：以 / 开头或中间含 / 的模式从仓库根匹配，
+// 否则可匹配任意层级；匹配到目录时也匹配其下所有文件
+func compile(p string) (*regexp.Regexp, error) {
+    anchored := strings.HasPrefix(p, "/") || strings.Contains(strings.TrimSuffix(p, "/"), "/")
+    p = strings.Trim(p, "/")
+    var b strings.Builder
+    if anchored {
+        b.WriteString("^")
+    } else {
+        b.WriteString("^(?:.*/)?")
+    }
+    for i := 0; i < len(p); i++ {
+        switch c := p[i]; {
+        case strings.HasPrefix(p[i:], "**/"):
+            // a/**/b 也要匹配 a/b
+            b.WriteString("(?:.*/)?")
+            i += 2
+        case strings.HasPrefix(p[i:], "**"):
+            b.WriteString(".*")
+            i++
+        case c == '*':
+            b.WriteString("[^/]*")
+        case c == '?':
+            b.WriteString("[^/]")
+        default:
+            b.WriteString(regexp.QuoteMeta(string(c)))
+        }
+    }
+    b.WriteString("(?:/.*)?$")
+    return regexp.Compile(b.String())
+}
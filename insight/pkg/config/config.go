@@ -0,0 +1,299 @@
+package config
+
+import (
+    "errors"
+    "fmt"
+    "os"
+    "path/filepath"
+    "regexp"
+    "strings"
+
+    "gopkg.in/yaml.v3"
+)
+
+// Instance 是一个 Sourcegraph 实例；Token 为空时从 TokenEnv 指定的环境变量读取
+type Instance struct {
+    Name     string `yaml:"name"`
+    URL      string `yaml:"url"`
+    Token    string `yaml:"token,omitempty"`
+    TokenEnv string `yaml:"token_env,omitempty"`
+}
+
+// Telemetry 是使用统计的开关；默认关闭，需要用户显式开启
+type Telemetry struct {
+    Enabled  bool   `yaml:"enabled"`
+    Endpoint string `yaml:"endpoint,omitempty"`
+}
+
+// Workspace 描述本地检出的位置：Repos 为显式的 仓库名→目录 映射，
+// 其余仓库在 Roots 下按目录名或 git remote 查找
+type Workspace struct {
+    Roots []string          `yaml:"roots,omitempty"`
+    Repos map[string]string `yaml:"repos,omitempty"`
+}
+
+// Update 是 self-update 的发布源：URL 为 GitHub 仓库（github.com/owner/repo）
+// 或内部制品库根地址；PublicKey 为 base64 的 ed25519 公钥，配置后要求校验 checksums 签名
+type Update struct {
+    URL       string `yaml:"url,omitempty"`
+    PublicKey string `yaml:"public_key,omitempty"`
+}
+
+// DigestQuery 是周报中跟踪的一条查询
+type DigestQuery struct {
+    Name    string `yaml:"name"`
+    Query   string `yaml:"query"`
+    Pattern string `yaml:"pattern,omitempty"`
+}
+
+// SMTP 是发信配置；密码从 PasswordEnv 指定的环境变量读取，避免写进配置文件
+type SMTP struct {
+    Host        string `yaml:"host"`
+    Port        int    `yaml:"port,omitempty"`
+    Username    string `yaml:"username,omitempty"`
+    PasswordEnv string `yaml:"password_env,omitempty"`
+}
+
+// Digest 是 digest 命令的配置：跟踪的查询、统计 LOC 的仓库（需本地检出）与收件人
+type Digest struct {
+    Subject string        `yaml:"subject,omitempty"`
+    From    string        `yaml:"from,omitempty"`
+    To      []string      `yaml:"to,omitempty"`
+    SMTP    SMTP          `yaml:"smtp,omitempty"`
+    Queries []DigestQuery `yaml:"queries,omitempty"`
+    LOC     []string      `yaml:"loc,omitempty"`
+}
+
+// Scope 是一个命名的路径范围（多用于 monorepo）：Repos 为仓库全名，
+// Paths 为仓库内的目录或文件前缀，为空表示整个仓库
+type Scope struct {
+    Repos []string `yaml:"repos"`
+    Paths []string `yaml:"paths,omitempty"`
+}
+
+// LLM 是 OpenAI 兼容的模型接口（也可以是内部代理）；密钥从 APIKeyEnv 指定的环境变量读取，
+// 默认 OPENAI_API_KEY。MaxInputTokens 是发送内容的硬上限（估算值），Redact 为额外的脱敏正则。
+// EmbeddingModel 用于 semantic 索引，EmbeddingURL 为空时与 URL 相同
+type LLM struct {
+    URL             string   `yaml:"url"`
+    Model           string   `yaml:"model"`
+    EmbeddingURL    string   `yaml:"embedding_url,omitempty"`
+    EmbeddingModel  string   `yaml:"embedding_model,omitempty"`
+    APIKeyEnv       string   `yaml:"api_key_env,omitempty"`
+    MaxInputTokens  int      `yaml:"max_input_tokens,omitempty"`
+    MaxOutputTokens int      `yaml:"max_output_tokens,omitempty"`
+    Redact          []string `yaml:"redact,omitempty"`
+}
+
+// ServeKey 是 serve 的一个调用方；密钥写在 Key 中或从 KeyEnv 指定的环境变量读取。
+// RatePerMinute 为 0 表示不限速；Commands 为允许调用的命令（如 search、find、ws list），
+// "*" 表示全部，为空时只允许 search
+type ServeKey struct {
+    Name          string   `yaml:"name"`
+    Key           string   `yaml:"key,omitempty"`
+    KeyEnv        string   `yaml:"key_env,omitempty"`
+    RatePerMinute int      `yaml:"rate_per_minute,omitempty"`
+    Commands      []string `yaml:"commands,omitempty"`
+}
+
+// Serve 是 serve 命令的配置：CORSOrigins 为允许从浏览器调用的 Origin（"*" 表示任意），
+// AuditLog 为审计日志路径（"-" 表示 stderr）；UserTokens 为 off|allow|require，
+// 决定调用方能否（或必须）在 X-Sourcegraph-Token 头中带上自己的 Sourcegraph token
+type Serve struct {
+    Addr        string     `yaml:"addr,omitempty"`
+    Keys        []ServeKey `yaml:"keys,omitempty"`
+    CORSOrigins []string   `yaml:"cors_origins,omitempty"`
+    AuditLog    string     `yaml:"audit_log,omitempty"`
+    UserTokens  string     `yaml:"user_tokens,omitempty"`
+}
+
+// ChangelogGroup 是 changelog 中的一节：提交说明（标题与正文）匹配 Pattern 正则的提交归入此节，
+// 按配置顺序取第一个匹配的节
+type ChangelogGroup struct {
+    Title   string `yaml:"title"`
+    Pattern string `yaml:"pattern"`
+}
+
+// Changelog 是 changelog 命令的配置：Groups 为空时使用内置的 Conventional Commits 分组；
+// Tickets 为工单号正则，TicketURL 中的 {id} 替换为工单号后生成链接
+type Changelog struct {
+    Groups    []ChangelogGroup `yaml:"groups,omitempty"`
+    Tickets   string           `yaml:"tickets,omitempty"`
+    TicketURL string           `yaml:"ticket_url,omitempty"`
+}
+
+// Quota 是一个实例的客户端限速，同时运行的所有 kb 进程共同遵守：URL 为空的一项适用于没有单独配置的实例；
+// Burst 为最多积攒的请求数，为 0 时取每分钟请求数的六分之一
+type Quota struct {
+    URL           string `yaml:"url,omitempty"`
+    RatePerMinute int    `yaml:"rate_per_minute"`
+    Burst         int    `yaml:"burst,omitempty"`
+}
+
+// Templates 是共享搜索模板的 git 仓库：Ref 为空时取默认分支，Dir 为模板在仓库中的子目录
+type Templates struct {
+    Repo string `yaml:"repo,omitempty"`
+    Ref  string `yaml:"ref,omitempty"`
+    Dir  string `yaml:"dir,omitempty"`
+}
+
+// Annotate 是 annotate 命令的配置：没有给 --query 时运行 Queries，SccDiff 为 true 时同样附上代码行数变化
+type Annotate struct {
+    Queries []DigestQuery `yaml:"queries,omitempty"`
+    SccDiff bool          `yaml:"scc_diff,omitempty"`
+}
+
+// Config 是 insight 的配置文件内容；Endpoint 是没有设置 SG_URL、LOCAL_SG_ENDPOINT 时使用的默认实例（由 init 写入），
+// Instances 是 federate、bench 等跨实例命令使用的实例列表
+type Config struct {
+    Endpoint  Instance         `yaml:"endpoint,omitempty"`
+    Instances []Instance       `yaml:"instances,omitempty"`
+    Telemetry Telemetry        `yaml:"telemetry,omitempty"`
+    Workspace Workspace        `yaml:"workspace,omitempty"`
+    Update    Update           `yaml:"update,omitempty"`
+    Digest    Digest           `yaml:"digest,omitempty"`
+    Scopes    map[string]Scope `yaml:"scopes,omitempty"`
+    LLM       LLM              `yaml:"llm,omitempty"`
+    Serve     Serve            `yaml:"serve,omitempty"`
+    Changelog Changelog        `yaml:"changelog,omitempty"`
+    Quotas    []Quota          `yaml:"quotas,omitempty"`
+    Templates Templates        `yaml:"templates,omitempty"`
+    Annotate  Annotate         `yaml:"annotate,omitempty"`
+}
+
+// Path 返回配置文件路径：INSIGHT_CONFIG 优先，否则为 <用户配置目录>/insight/config.yaml
+func Path() (string, error) {
+    if p := os.Getenv("INSIGHT_CONFIG"); p != "" {
+        return p, nil
+    }
+    dir, err := os.UserConfigDir()
+    if err != nil {
+        return "", err
+    }
+    return filepath.Join(dir, "insight", "config.yaml"), nil
+}
+
+// Load 读取配置文件；文件不存在时返回空配置
+func Load() (*Config, error) {
+    p, err := Path()
+    if err != nil {
+        return nil, err
+    }
+    var c Config
+    b, err := os.ReadFile(p)
+    if errors.Is(err, os.ErrNotExist) {
+        return &c, nil
+    }
+    if err != nil {
+        return nil, err
+    }
+    if err := yaml.Unmarshal(b, &c); err != nil {
+        return nil, fmt.Errorf("%s: %w", p, err)
+    }
+    return &c, nil
+}
+
+// Save 把配置写回配置文件，必要时创建目录。配置里可能有 token，先写到同目录的临时文件（权限 0600）
+// 再改名覆盖，原文件的权限不会沿用，写到一半中断也不会留下残缺的配置；配置文件是符号链接时写到链接目标。
+// 按结构体重新生成 YAML，原文件中的注释与未知字段不会保留（见 HasComments）
+func (c *Config) Save() error {
+    p, err := Path()
+    if err != nil {
+        return err
+    }
+    if t, err := filepath.EvalSymlinks(p); err == nil {
+        p = t
+    }
+    if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+        return err
+    }
+    b, err := yaml.Marshal(c)
+    if err != nil {
+        return err
+    }
+    f, err := os.CreateTemp(filepath.Dir(p), ".config-*.yaml")
+    if err != nil {
+        return err
+    }
+    defer os.Remove(f.Name()) // 改名成功后已不存在，删除失败可以忽略
+    if err := f.Chmod(0o600); err != nil {
+        f.Close()
+        return err
+    }
+    if _, err := f.Write(b); err != nil {
+        f.Close()
+        return err
+    }
+    if err := f.Close(); err != nil {
+        return err
+    }
+    return os.Rename(f.Name(), p)
+}
+
+// HasComments 报告现有配置文件中是否有注释（Save 会丢掉它们）；文件不存在或无法解析时返回 false
+func HasComments() bool {
+    p, err := Path()
+    if err != nil {
+        return false
+    }
+    b, err := os.ReadFile(p)
+    if err != nil {
+        return false
+    }
+    var n yaml.Node
+    if yaml.Unmarshal(b, &n) != nil {
+        return false
+    }
+    var walk func(*yaml.Node) bool
+    walk = func(n *yaml.Node) bool {
+        if n.HeadComment != "" || n.LineComment != "" || n.FootComment != "" {
+            return true
+        }
+        for _, c := range n.Content {
+            if walk(c) {
+                return true
+            }
+        }
+        return false
+    }
+    return walk(&n)
+}
+
+// ExpandHome 把开头的 ~ 与 %VAR%/$VAR 环境变量展开，并统一为本平台的分隔符
+func ExpandHome(p string) string {
+    if p == "" {
+        return p
+    }
+    p = os.Expand(winEnvRe.ReplaceAllString(p, "$${$1}"), os.Getenv)
+    if p == "~" || strings.HasPrefix(p, "~/") || strings.HasPrefix(p, `~\`) {
+        if home, err := os.UserHomeDir(); err == nil {
+            return filepath.Join(home, p[1:])
+        }
+    }
+    return filepath.Clean(filepath.FromSlash(p))
+}
+
+// winEnvRe 匹配 Windows 风格的 %APPDATA% 变量
+var winEnvRe = regexp.MustCompile(`%(\w+)%`)
+
+// TokenFor 取实例的访问令牌
+func (i Instance) TokenFor() string {
+    if i.Token != "" {
+        return i.Token
+    }
+    if i.TokenEnv != "" {
+        return os.Getenv(i.TokenEnv)
+    }
+    return ""
+}
+
+// Secret 取调用方的密钥
+func (k ServeKey) Secret() string {
+    if k.Key != "" {
+        return k.Key
+    }
+    if k.KeyEnv != "" {
+        return os.Getenv(k.KeyEnv)
+    }
+    return ""
+}
@@ -0,0 +1,226 @@
+package diff
+
+import (
+    "fmt"
+    "strings"
+)
+
+type opKind int
+
+const (
+    opEq opKind = iota
+    opDel
+    opIns
+)
+
+type edit struct {
+    op   opKind
+    a, b int // 在 a/b 中的行下标；插入/删除的另一侧为该位置之后的下一行
+}
+
+// maxEditDistance 是 Myers 搜索的上限；差异比这还大时直接按整段替换输出，避免内存失控
+const maxEditDistance = 2000
+
+// Lines 按 \n 切分文本，末尾换行不产生空行
+func Lines(s string) []string {
+    if s == "" {
+        return nil
+    }
+    return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// Unified 返回 a→b 的 unified diff（context 行上下文），内容相同时返回空串
+func Unified(nameA, nameB string, a, b []string, context int) string {
+    edits := compute(a, b)
+    var out strings.Builder
+    for _, h := range hunks(edits, context) {
+        if out.Len() == 0 {
+            fmt.Fprintf(&out, "--- %s\n+++ %s\n", nameA, nameB)
+        }
+        aLen, bLen := 0, 0
+        for _, e := range h {
+            if e.op != opIns {
+                aLen++
+            }
+            if e.op != opDel {
+                bLen++
+            }
+        }
+        fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(h[0].a, aLen), hunkRange(h[0].b, bLen))
+        for _, e := range h {
+            switch e.op {
+            case opEq:
+                out.WriteString(" " + a[e.a] + "\n")
+            case opDel:
+                out.WriteString("-" + a[e.a] + "\n")
+            case opIns:
+                out.WriteString("+" + b[e.b] + "\n")
+            }
+        }
+    }
+    return out.String()
+}
+
+// hunkRange 生成 start,len（start 从 1 开始）；长度为 0 时 start 取前一行，与 GNU diff 一致
+func hunkRange(start, n int) string {
+    switch n {
+    case 0:
+        return fmt.Sprintf("%d,0", start)
+    case 1:
+        return fmt.Sprintf("%d", start+1)
+    }
+    return fmt.Sprintf("%d,%d", start+1, n)
+}
+
+// hunks 把编辑序列切成带上下文的块，相距不超过 2*context 的改动合并为一块
+func hunks(edits []edit, context int) [][]edit {
+    var out [][]edit
+    i := 0
+    for i < len(edits) {
+        for i < len(edits) && edits[i].op == opEq {
+            i++
+        }
+        if i == len(edits) {
+            break
+        }
+        start := max(i-context, 0)
+        end := i
+        for end < len(edits) {
+            if edits[end].op != opEq {
+                end++
+                continue
+            }
+            run := end
+            for run < len(edits) && edits[run].op == opEq {
+                run++
+            }
+            if run == len(edits) || run-end > 2*context {
+                end = min(end+context, len(edits))
+                break
+            }
+            end = run
+        }
+        out = append(out, edits[start:end])
+        i = end
+    }
+    return out
+}
+
+// compute 先去掉公共前后缀，再对中间部分做 Myers 差分
+func compute(a, b []string) []edit {
+    pre := 0
+    for pre < len(a) && pre < len(b) && a[pre] == b[pre] {
+        pre++
+    }
+    suf := 0
+    for suf < len(a)-pre && suf < len(b)-pre && a[len(a)-1-suf] == b[len(b)-1-suf] {
+        suf++
+    }
+
+    var edits []edit
+    for i := 0; i < pre; i++ {
+        edits = append(edits, edit{opEq, i, i})
+    }
+    for _, e := range myers(a[pre:len(a)-suf], b[pre:len(b)-suf]) {
+        if e.a >= 0 {
+            e.a += pre
+        }
+        if e.b >= 0 {
+            e.b += pre
+        }
+        edits = append(edits, e)
+    }
+    for i := 0; i < suf; i++ {
+        edits = append(edits, edit{opEq, len(a) - suf + i, len(b) - suf + i})
+    }
+    fillPositions(edits)
+    return edits
+}
+
+// fillPositions 为插入/删除补上另一侧的当前位置（即另一侧下一行的下标），用于 hunk 头
+func fillPositions(edits []edit) {
+    ai, bi := 0, 0
+    for i := range edits {
+        switch edits[i].op {
+        case opEq:
+            ai, bi = edits[i].a+1, edits[i].b+1
+        case opDel:
+            ai = edits[i].a + 1
+            edits[i].b = bi
+        case opIns:
+            edits[i].a = ai
+            bi = edits[i].b + 1
+        }
+    }
+}
+
+func myers(a, b []string) []edit {
+    n, m := len(a), len(b)
+    limit := min(n+m, maxEditDistance)
+    off := limit + 1
+    v := make([]int, 2*limit+3)
+    var trace [][]int
+    for d := 0; d <= limit; d++ {
+        trace = append(trace, append([]int(nil), v...))
+        for k := -d; k <= d; k += 2 {
+            var x int
+            if k == -d || (k != d && v[off+k-1] < v[off+k+1]) {
+                x = v[off+k+1]
+            } else {
+                x = v[off+k-1] + 1
+            }
+            y := x - k
+            for x < n && y < m && a[x] == b[y] {
+                x++
+                y++
+            }
+            v[off+k] = x
+            if x >= n && y >= m {
+                return backtrack(trace, n, m, off)
+            }
+        }
+    }
+    // 差异过大：整段删除再整段插入
+    var edits []edit
+    for i := range a {
+        edits = append(edits, edit{opDel, i, -1})
+    }
+    for j := range b {
+        edits = append(edits, edit{opIns, -1, j})
+    }
+    return edits
+}
+
+func backtrack(trace [][]int, n, m, off int) []edit {
+    var rev []edit
+    x, y := n, m
+    for d := len(trace) - 1; d >= 0; d-- {
+        v := trace[d]
+        k := x - y
+        var prevK int
+        if k == -d || (k != d && v[off+k-1] < v[off+k+1]) {
+            prevK = k + 1
+        } else {
+            prevK = k - 1
+        }
+        prevX := v[off+prevK]
+        prevY := prevX - prevK
+        for x > prevX && y > prevY {
+            rev = append(rev, edit{opEq, x - 1, y - 1})
+            x--
+            y--
+        }
+        if d > 0 {
+            if x == prevX {
+                rev = append(rev, edit{opIns, -1, y - 1})
+            } else {
+                rev = append(rev, edit{opDel, x - 1, -1})
+            }
+        }
+        x, y = prevX, prevY
+    }
+    for i, j := 0, len(rev)-1; i < j; i, j = i+1, j-1 {
+        rev[i], rev[j] = rev[j], rev[i]
+    }
+    return rev
+}
@@ -0,0 +1,89 @@
+package export
+
+import (
+    "bytes"
+    "encoding/json"
+    "fmt"
+    "io"
+    "net/http"
+    "os"
+    "strings"
+    "time"
+
+    "kingbrain/insight/pkg/i18n"
+)
+
+const bigQueryAPI = "https://bigquery.googleapis.com/bigquery/v2"
+
+// writeBigQuery 通过 tabledata.insertAll 流式写入 project.dataset 下的 matches/metrics 表。
+// 表需预先按 matchRow/metricRow 的 json 字段建好；令牌取 BIGQUERY_TOKEN
+// （如 `gcloud auth print-access-token` 的输出）。
+func writeBigQuery(target string, run *Run) error {
+    project, dataset, ok := strings.Cut(target, ".")
+    if !ok {
+        return i18n.Errorf("bigquery 目标格式应为 project.dataset：%q", target)
+    }
+    token := os.Getenv("BIGQUERY_TOKEN")
+    if token == "" {
+        return i18n.Errorf("未设置 BIGQUERY_TOKEN")
+    }
+    matches, metrics := run.rows()
+    base := fmt.Sprintf("%s/projects/%s/datasets/%s/tables/", bigQueryAPI, project, dataset)
+    if err := insertAll(base+"matches/insertAll", token, toAny(matches)); err != nil {
+        return err
+    }
+    return insertAll(base+"metrics/insertAll", token, toAny(metrics))
+}
+
+func toAny[T any](rows []T) []any {
+    out := make([]any, len(rows))
+    for i, r := range rows {
+        out[i] = r
+    }
+    return out
+}
+
+// insertAll 按 500 行一批写入，并检查逐行错误
+func insertAll(url, token string, rows []any) error {
+    client := &http.Client{Timeout: 30 * time.Second}
+    for len(rows) > 0 {
+        n := min(len(rows), 500)
+        batch := make([]map[string]any, n)
+        for i, r := range rows[:n] {
+            batch[i] = map[string]any{"json": r}
+        }
+        rows = rows[n:]
+
+        body, err := json.Marshal(map[string]any{"rows": batch})
+        if err != nil {
+            return err
+        }
+        req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+        if err != nil {
+            return err
+        }
+        req.Header.Set("Authorization", "Bearer "+token)
+        req.Header.Set("Content-Type", "application/json")
+        resp, err := client.Do(req)
+        if err != nil {
+            return err
+        }
+        var out struct {
+            InsertErrors []json.RawMessage `json:"insertErrors"`
+        }
+        if resp.StatusCode >= 300 {
+            msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+            resp.Body.Close()
+            return fmt.Errorf("bigquery: %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+        }
+        err = json.NewDecoder(resp.Body).Decode(&out)
+        resp.Body.Close()
+        if err != nil {
+            return err
+        }
+        if len(out.InsertErrors) > 0 {
+            return i18n.Errorf("bigquery: %d 行写入失败，首条：%s", len(out.InsertErrors), out.InsertErrors[0])
+        }
+    }
+    return nil
+}
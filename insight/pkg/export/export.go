@@ -0,0 +1,72 @@
+package export
+
+import (
+    "sort"
+    "strings"
+    "time"
+
+    "kingbrain/insight/pkg/i18n"
+)
+
+// Match 是一条导出的匹配
+type Match struct {
+    Instance    string // 联邦搜索时的来源实例，否则为空
+    Repo        string
+    Rev         string // 按 revision 搜索时的分支/标签，否则为空
+    Path        string
+    Line        int
+    Rule        string
+    Preview     string
+    URL         string
+    Annotations map[string]string // --hook 脚本添加的注解，以 JSON 对象写入 annotations 列
+}
+
+// Metric 是一条导出的指标（如 scc 的按语言统计），Repo/Scope 可为空
+type Metric struct {
+    Repo  string
+    Scope string
+    Name  string
+    Value float64
+}
+
+// Run 是一次命令执行的全部结果
+type Run struct {
+    Command string
+    Query   string
+    Started time.Time
+    Matches []Match
+    Metrics []Metric
+}
+
+// writers 按 kind 注册导出器，path 为 spec 中 = 之后的部分
+var writers = map[string]func(path string, run *Run) error{
+    "sqlite":   writeSQLite,
+    "parquet":  writeParquet,
+    "bigquery": writeBigQuery,
+}
+
+// Write 解析 kind=path 形式的 spec 并导出
+func Write(spec string, run *Run) error {
+    kind, path, ok := strings.Cut(spec, "=")
+    if !ok || path == "" {
+        return i18n.Errorf("--export 格式应为 kind=path：%q", spec)
+    }
+    w, ok := writers[kind]
+    if !ok {
+        return i18n.Errorf("不支持的导出类型 %q（可选：%s）", kind, strings.Join(Kinds(), "|"))
+    }
+    if err := w(path, run); err != nil {
+        return i18n.Errorf("导出 %s: %w", spec, err)
+    }
+    return nil
+}
+
+// Kinds 返回已注册的导出类型
+func Kinds() []string {
+    out := make([]string, 0, len(writers))
+    for k := range writers {
+        out = append(out, k)
+    }
+    sort.Strings(out)
+    return out
+}
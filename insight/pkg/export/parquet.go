@@ -0,0 +1,80 @@
+package export
+
+import (
+    "encoding/json"
+    "fmt"
+    "os"
+    "path/filepath"
+    "time"
+
+    "github.com/parquet-go/parquet-go"
+)
+
+// matchRow/metricRow 是数仓侧的扁平行结构，每行都带上 run 信息便于分区与关联
+type matchRow struct {
+    Command     string    `parquet:"command" json:"command"`
+    Query       string    `parquet:"query" json:"query"`
+    StartedAt   time.Time `parquet:"started_at,timestamp" json:"started_at"`
+    Instance    string    `parquet:"instance" json:"instance"`
+    Repo        string    `parquet:"repo" json:"repo"`
+    Rev         string    `parquet:"rev" json:"rev"`
+    Path        string    `parquet:"path" json:"path"`
+    Line        int64     `parquet:"line" json:"line"`
+    Rule        string    `parquet:"rule" json:"rule"`
+    Preview     string    `parquet:"preview" json:"preview"`
+    URL         string    `parquet:"url" json:"url"`
+    Annotations string    `parquet:"annotations" json:"annotations,omitempty"` // 为空时 BigQuery 不写这一列，旧表不必加列
+}
+
+type metricRow struct {
+    Command   string    `parquet:"command" json:"command"`
+    Query     string    `parquet:"query" json:"query"`
+    StartedAt time.Time `parquet:"started_at,timestamp" json:"started_at"`
+    Repo      string    `parquet:"repo" json:"repo"`
+    Scope     string    `parquet:"scope" json:"scope"`
+    Name      string    `parquet:"name" json:"name"`
+    Value     float64   `parquet:"value" json:"value"`
+}
+
+func (r *Run) rows() ([]matchRow, []metricRow) {
+    started := r.Started.UTC()
+    matches := make([]matchRow, 0, len(r.Matches))
+    for _, m := range r.Matches {
+        matches = append(matches, matchRow{r.Command, r.Query, started, m.Instance, m.Repo, m.Rev, m.Path, int64(m.Line), m.Rule, m.Preview, m.URL, annotationsJSON(m.Annotations)})
+    }
+    metrics := make([]metricRow, 0, len(r.Metrics))
+    for _, m := range r.Metrics {
+        metrics = append(metrics, metricRow{r.Command, r.Query, started, m.Repo, m.Scope, m.Name, m.Value})
+    }
+    return matches, metrics
+}
+
+// annotationsJSON 把注解写成 JSON 对象，没有注解时为空字符串
+func annotationsJSON(a map[string]string) string {
+    if len(a) == 0 {
+        return ""
+    }
+    b, _ := json.Marshal(a)
+    return string(b)
+}
+
+// writeParquet 在目录 dir 下为本次 run 写 matches-<ts>.parquet / metrics-<ts>.parquet，
+// 文件名带时间戳，多次运行互不覆盖，适合直接作为外部表的数据目录
+func writeParquet(dir string, run *Run) error {
+    if err := os.MkdirAll(dir, 0o755); err != nil {
+        return err
+    }
+    ts := run.Started.UTC().Format("20060102T150405Z")
+    matches, metrics := run.rows()
+    if len(matches) > 0 {
+        if err := parquet.WriteFile(filepath.Join(dir, fmt.Sprintf("matches-%s.parquet", ts)), matches); err != nil {
+            return err
+        }
+    }
+    if len(metrics) > 0 {
+        if err := parquet.WriteFile(filepath.Join(dir, fmt.Sprintf("metrics-%s.parquet", ts)), metrics); err != nil {
+            return err
+        }
+    }
+    return nil
+}
@@ -0,0 +1,122 @@
+package export
+
+import (
+    "database/sql"
+
+    _ "modernc.org/sqlite"
+)
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS runs (
+    id         INTEGER PRIMARY KEY,
+    command    TEXT NOT NULL,
+    query      TEXT,
+    started_at TIMESTAMP NOT NULL
+);
+CREATE TABLE IF NOT EXISTS repos (
+    id   INTEGER PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE
+);
+CREATE TABLE IF NOT EXISTS files (
+    id      INTEGER PRIMARY KEY,
+    repo_id INTEGER NOT NULL REFERENCES repos(id),
+    path    TEXT NOT NULL,
+    UNIQUE (repo_id, path)
+);
+CREATE TABLE IF NOT EXISTS matches (
+    id       INTEGER PRIMARY KEY,
+    run_id   INTEGER NOT NULL REFERENCES runs(id),
+    file_id  INTEGER NOT NULL REFERENCES files(id),
+    line     INTEGER,
+    rule     TEXT,
+    instance TEXT,
+    rev      TEXT,
+    preview  TEXT,
+    url      TEXT,
+    annotations TEXT
+);
+CREATE TABLE IF NOT EXISTS metrics (
+    id      INTEGER PRIMARY KEY,
+    run_id  INTEGER NOT NULL REFERENCES runs(id),
+    repo_id INTEGER REFERENCES repos(id),
+    scope   TEXT,
+    name    TEXT NOT NULL,
+    value   REAL
+);
+`
+
+// writeSQLite 把一次 run 追加写入 SQLite 库，库与表不存在时自动创建
+func writeSQLite(path string, run *Run) error {
+    db, err := sql.Open("sqlite", path)
+    if err != nil {
+        return err
+    }
+    defer db.Close()
+    if _, err := db.Exec(sqliteSchema); err != nil {
+        return err
+    }
+    // 旧库缺少后加的列，逐个补上；列已存在时报错忽略
+    for _, col := range []string{"instance", "rev", "annotations"} {
+        _, _ = db.Exec(`ALTER TABLE matches ADD COLUMN ` + col + ` TEXT`)
+    }
+
+    tx, err := db.Begin()
+    if err != nil {
+        return err
+    }
+    defer tx.Rollback()
+
+    res, err := tx.Exec(`INSERT INTO runs (command, query, started_at) VALUES (?, ?, ?)`,
+        run.Command, run.Query, run.Started.UTC())
+    if err != nil {
+        return err
+    }
+    runID, _ := res.LastInsertId()
+
+    // repoRow 取仓库行的 id，不存在时插入；files.repo_id 不能为空，本地结果（没有仓库名）记在名为 '' 的仓库行下，
+    // 这样 (repo_id, path) 的 UNIQUE 也能对它们去重
+    repoRow := func(name string) (int64, error) {
+        if _, err := tx.Exec(`INSERT OR IGNORE INTO repos (name) VALUES (?)`, name); err != nil {
+            return 0, err
+        }
+        var id int64
+        err := tx.QueryRow(`SELECT id FROM repos WHERE name = ?`, name).Scan(&id)
+        return id, err
+    }
+    // repoID 用于 metrics：没有仓库名的指标 repo_id 为 NULL
+    repoID := func(name string) (any, error) {
+        if name == "" {
+            return nil, nil
+        }
+        return repoRow(name)
+    }
+
+    for _, m := range run.Matches {
+        rid, err := repoRow(m.Repo)
+        if err != nil {
+            return err
+        }
+        if _, err := tx.Exec(`INSERT OR IGNORE INTO files (repo_id, path) VALUES (?, ?)`, rid, m.Path); err != nil {
+            return err
+        }
+        var fid int64
+        if err := tx.QueryRow(`SELECT id FROM files WHERE repo_id = ? AND path = ?`, rid, m.Path).Scan(&fid); err != nil {
+            return err
+        }
+        if _, err := tx.Exec(`INSERT INTO matches (run_id, file_id, line, rule, instance, rev, preview, url, annotations) VALUES (?, ?, ?, ?, ?, ?, ?, ?, NULLIF(?, ''))`,
+            runID, fid, m.Line, m.Rule, m.Instance, m.Rev, m.Preview, m.URL, annotationsJSON(m.Annotations)); err != nil {
+            return err
+        }
+    }
+    for _, m := range run.Metrics {
+        rid, err := repoID(m.Repo)
+        if err != nil {
+            return err
+        }
+        if _, err := tx.Exec(`INSERT INTO metrics (run_id, repo_id, scope, name, value) VALUES (?, ?, ?, ?, ?)`,
+            runID, rid, m.Scope, m.Name, m.Value); err != nil {
+            return err
+        }
+    }
+    return tx.Commit()
+}
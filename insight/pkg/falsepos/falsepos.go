@@ -0,0 +1,173 @@
+// Package falsepos 保存 audit 规则的误报标记。误报按 规则 + 仓库 + 路径 + 匹配行内容的哈希 识别，
+// 不含行号，文件其他地方改动导致行号变化时标记仍然有效；匹配行本身改了则需要重新标记。
+package falsepos
+
+import (
+    "crypto/sha256"
+    "encoding/hex"
+    "encoding/json"
+    "errors"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+    "time"
+)
+
+// Entry 是一条误报标记
+type Entry struct {
+    ID      string    `json:"id"`
+    Rule    string    `json:"rule"`
+    Repo    string    `json:"repo"`
+    Path    string    `json:"path"`
+    Hash    string    `json:"hash"`
+    Preview string    `json:"preview,omitempty"`
+    Reason  string    `json:"reason,omitempty"`
+    By      string    `json:"by,omitempty"`
+    Marked  time.Time `json:"marked"`
+}
+
+// Store 是本地的误报列表
+type Store struct {
+    Entries []Entry `json:"entries"`
+
+    byID map[string]int
+}
+
+// Path 返回误报列表的路径：<用户配置目录>/insight/false-positives.json。
+// 这是人工维护的数据，放在配置目录而不是缓存目录，清缓存时不会丢
+func Path() (string, error) {
+    if p := os.Getenv("INSIGHT_FALSE_POSITIVES"); p != "" {
+        return p, nil
+    }
+    dir, err := os.UserConfigDir()
+    if err != nil {
+        return "", err
+    }
+    return filepath.Join(dir, "insight", "false-positives.json"), nil
+}
+
+// LineHash 返回匹配行去掉首尾空白后的内容哈希，缩进变化不影响结果
+func LineHash(line string) string {
+    sum := sha256.Sum256([]byte(strings.TrimSpace(line)))
+    return hex.EncodeToString(sum[:8])
+}
+
+// MatchID 返回一处匹配的 ID，audit 列出违规时显示，mark-fp 用它标记
+func MatchID(rule, repo, path, line string) string {
+    return idOf(Entry{Rule: rule, Repo: repo, Path: path, Hash: LineHash(line)})
+}
+
+// Load 读取误报列表；不存在时返回空列表
+func Load() (*Store, error) {
+    p, err := Path()
+    if err != nil {
+        return nil, err
+    }
+    s := &Store{}
+    b, err := os.ReadFile(p)
+    if errors.Is(err, os.ErrNotExist) {
+        s.index()
+        return s, nil
+    }
+    if err != nil {
+        return nil, err
+    }
+    if err := json.Unmarshal(b, s); err != nil {
+        return nil, err
+    }
+    s.index()
+    return s, nil
+}
+
+// Save 按 ID 排序后写回，方便把文件放进版本库或与同事对比
+func (s *Store) Save() error {
+    p, err := Path()
+    if err != nil {
+        return err
+    }
+    if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+        return err
+    }
+    sort.Slice(s.Entries, func(i, j int) bool { return s.Entries[i].ID < s.Entries[j].ID })
+    s.index()
+    b, err := json.MarshalIndent(s, "", "  ")
+    if err != nil {
+        return err
+    }
+    tmp := p + ".tmp"
+    if err := os.WriteFile(tmp, append(b, '\n'), 0o644); err != nil {
+        return err
+    }
+    return os.Rename(tmp, p)
+}
+
+func (s *Store) index() {
+    s.byID = make(map[string]int, len(s.Entries))
+    for i, e := range s.Entries {
+        s.byID[e.ID] = i
+    }
+}
+
+// Add 加入一条标记，已有同一 ID 时用新的原因覆盖；返回是否为新标记
+func (s *Store) Add(e Entry) bool {
+    if e.ID == "" {
+        e.ID = idOf(e)
+    }
+    if i, ok := s.byID[e.ID]; ok {
+        if e.Reason != "" {
+            s.Entries[i].Reason = e.Reason
+        }
+        return false
+    }
+    s.byID[e.ID] = len(s.Entries)
+    s.Entries = append(s.Entries, e)
+    return true
+}
+
+// Remove 删除一条标记，返回是否存在
+func (s *Store) Remove(id string) bool {
+    i, ok := s.byID[id]
+    if !ok {
+        return false
+    }
+    s.Entries = append(s.Entries[:i], s.Entries[i+1:]...)
+    s.index()
+    return true
+}
+
+// Get 按 ID 取标记
+func (s *Store) Get(id string) (Entry, bool) {
+    i, ok := s.byID[id]
+    if !ok {
+        return Entry{}, false
+    }
+    return s.Entries[i], true
+}
+
+// Excluded 判断某处匹配是否已被标记为误报
+func (s *Store) Excluded(rule, repo, path, line string) bool {
+    _, ok := s.byID[MatchID(rule, repo, path, line)]
+    return ok
+}
+
+// idOf 由标记的字段重新计算 ID，导入的文件里 ID 缺失或被手改时以字段为准
+func idOf(e Entry) string {
+    sum := sha256.Sum256([]byte(e.Rule + "\x00" + e.Repo + "\x00" + e.Path + "\x00" + e.Hash))
+    return hex.EncodeToString(sum[:6])
+}
+
+// Merge 导入别人导出的标记，返回新增的条数
+func (s *Store) Merge(entries []Entry) int {
+    n := 0
+    for _, e := range entries {
+        if e.Hash == "" || e.Path == "" {
+            continue
+        }
+        e.ID = idOf(e)
+        if s.Add(e) {
+            n++
+        }
+    }
+    return n
+}
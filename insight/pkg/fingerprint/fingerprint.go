@@ -0,0 +1,155 @@
+package fingerprint
+
+import (
+    "hash/fnv"
+    "sort"
+    "strings"
+)
+
+// Fingerprint 是一个 k 行片段的哈希；Line 为片段首行在原文件中的行号（从 1 开始）
+type Fingerprint struct {
+    Hash uint64
+    Line int
+}
+
+// Region 是两份文件中对应的一段重复代码（闭区间，行号从 1 开始）
+type Region struct {
+    StartA, EndA int
+    StartB, EndB int
+}
+
+const base = 1000003
+
+// normalize 去掉缩进与行尾空白，丢弃空行和纯注释行；返回保留行及其原始行号
+func normalize(content string) ([]string, []int) {
+    var lines []string
+    var nums []int
+    for i, l := range strings.Split(content, "\n") {
+        l = strings.Join(strings.Fields(l), " ")
+        if l == "" || strings.HasPrefix(l, "//") || strings.HasPrefix(l, "#") || strings.HasPrefix(l, "*") || strings.HasPrefix(l, "/*") {
+            continue
+        }
+        lines = append(lines, l)
+        nums = append(nums, i+1)
+    }
+    return lines, nums
+}
+
+// Winnow 对规范化后的内容计算 k 行滚动哈希，再用大小为 w 的窗口做 winnowing，
+// 保证任何长度 ≥ k+w-1 行的公共片段至少共享一个指纹
+func Winnow(content string, k, w int) []Fingerprint {
+    lines, nums := normalize(content)
+    if len(lines) < k {
+        return nil
+    }
+    lh := make([]uint64, len(lines))
+    for i, l := range lines {
+        h := fnv.New64a()
+        h.Write([]byte(l))
+        lh[i] = h.Sum64()
+    }
+
+    // 多项式滚动哈希：H = Σ lh[i+j]·base^(k-1-j)
+    var pow uint64 = 1
+    for i := 1; i < k; i++ {
+        pow *= base
+    }
+    grams := make([]Fingerprint, 0, len(lines)-k+1)
+    var h uint64
+    for i := range lines {
+        if i >= k {
+            h -= lh[i-k] * pow
+        }
+        h = h*base + lh[i]
+        if i >= k-1 {
+            grams = append(grams, Fingerprint{Hash: h, Line: nums[i-k+1]})
+        }
+    }
+
+    if w <= 1 || len(grams) <= w {
+        if w > 1 {
+            return []Fingerprint{minOf(grams)}
+        }
+        return grams
+    }
+    var out []Fingerprint
+    last := -1
+    for i := 0; i+w <= len(grams); i++ {
+        // 取窗口内最右侧的最小值，相同指纹不重复记录
+        m := i
+        for j := i; j < i+w; j++ {
+            if grams[j].Hash <= grams[m].Hash {
+                m = j
+            }
+        }
+        if m != last {
+            out = append(out, grams[m])
+            last = m
+        }
+    }
+    return out
+}
+
+func minOf(fs []Fingerprint) Fingerprint {
+    m := fs[0]
+    for _, f := range fs[1:] {
+        if f.Hash < m.Hash {
+            m = f
+        }
+    }
+    return m
+}
+
+// Compare 返回 b 对 a 的覆盖度（共享指纹数 / 较小一方的指纹数）以及重复区域；
+// 相距不超过 gap 行的共享指纹合并为同一区域，区域末尾按 k 行片段补齐
+func Compare(a, b []Fingerprint, k, gap int) (float64, []Region) {
+    if len(a) == 0 || len(b) == 0 {
+        return 0, nil
+    }
+    inB := map[uint64][]int{}
+    for _, f := range b {
+        inB[f.Hash] = append(inB[f.Hash], f.Line)
+    }
+    type pair struct{ a, b int }
+    var shared []pair
+    seen := map[uint64]bool{}
+    n := 0
+    for _, f := range a {
+        lines, ok := inB[f.Hash]
+        if !ok {
+            continue
+        }
+        if !seen[f.Hash] {
+            seen[f.Hash] = true
+            n++
+        }
+        shared = append(shared, pair{f.Line, lines[0]})
+    }
+    if n == 0 {
+        return 0, nil
+    }
+    score := float64(n) / float64(min(len(uniq(a)), len(uniq(b))))
+
+    sort.Slice(shared, func(i, j int) bool { return shared[i].a < shared[j].a })
+    var regions []Region
+    for _, p := range shared {
+        if len(regions) > 0 {
+            r := &regions[len(regions)-1]
+            if p.a-r.EndA <= gap && p.b >= r.StartB && p.b-r.EndB <= gap {
+                r.EndA = max(r.EndA, p.a+k-1)
+                r.EndB = max(r.EndB, p.b+k-1)
+                continue
+            }
+        }
+        regions = append(regions, Region{StartA: p.a, EndA: p.a + k - 1, StartB: p.b, EndB: p.b + k - 1})
+    }
+    return min(score, 1), regions
+}
+
+func uniq(fs []Fingerprint) map[uint64]bool {
+    m := make(map[uint64]bool, len(fs))
+    for _, f := range fs {
+        m[f.Hash] = true
+    }
+    return m
+}
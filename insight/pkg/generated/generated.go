@@ -0,0 +1,117 @@
+// Package generated 识别搜索结果中的“噪音”内容：压缩（minified）的 JS/CSS、打包产物、
+// 内嵌的 base64 等高熵数据、二进制内容，以及带生成代码标记的行与文件。
+// 判断只看路径与匹配行的预览，不需要拉取文件内容。
+package generated
+
+import (
+    "math"
+    "regexp"
+    "strings"
+    "unicode"
+    "unicode/utf8"
+)
+
+// Reason 是一处匹配被判为噪音的原因，空字符串表示正常内容
+type Reason string
+
+const (
+    Minified  Reason = "minified"
+    Encoded   Reason = "encoded"
+    Binary    Reason = "binary"
+    Generated Reason = "generated"
+)
+
+var (
+    // MaxLineLength 是正常代码行的最大长度（字符数），超过时视为压缩代码
+    MaxLineLength = 500
+    // MinEntropy 是视为编码数据的最小香农熵（比特/字节）：普通代码约 4-5，base64 约 6
+    MinEntropy = 5.2
+)
+
+// minTokenLength 是按熵判断时，行中最长的无空白片段至少要有的长度，避免短行误判
+const minTokenLength = 100
+
+// markerRe 匹配常见的生成代码标记（Go、protobuf、C#、Java、Facebook 的 @generated 等）
+var markerRe = regexp.MustCompile(`(?i)(code generated .* do not edit|@generated\b|<auto-?generated|autogenerated file|this file (is|was) (automatically )?generated|generated by (protoc|the protocol buffer compiler|webpack|rollup|esbuild|babel))`)
+
+// pathRe 匹配通常由工具生成的文件路径
+var pathRe = regexp.MustCompile(`(\.min\.(js|css|mjs)|[.-]bundle\.(js|mjs)|\.chunk\.(js|css)|\.(js|css)\.map|\.pb\.go|\.pb\.gw\.go|_pb2\.py|\.g\.dart|\.designer\.cs|_generated\.go|\.generated\.\w+)$`)
+
+// Path 报告文件路径是否像生成的文件（压缩产物、source map、protobuf 生成代码等）。
+// lock 文件不算：依赖查询常常就是要搜它们
+func Path(p string) bool {
+    return pathRe.MatchString(p)
+}
+
+// Line 判断一行预览是否为噪音并返回原因
+func Line(s string) Reason {
+    if binaryish(s) {
+        return Binary
+    }
+    if markerRe.MatchString(s) {
+        return Generated
+    }
+    n := utf8.RuneCountInString(s)
+    if n > MaxLineLength {
+        return Minified
+    }
+    if n >= minTokenLength && longestToken(s) >= minTokenLength && Entropy(s) >= MinEntropy {
+        return Encoded
+    }
+    return ""
+}
+
+// binaryish 报告 s 是否含 NUL，或控制字符与无效 UTF-8 超过 10%
+func binaryish(s string) bool {
+    if strings.IndexByte(s, 0) >= 0 {
+        return true
+    }
+    bad, total := 0, 0
+    for _, r := range s {
+        total++
+        if r == utf8.RuneError || (unicode.IsControl(r) && r != '\t' && r != '\r' && r != '\n') {
+            bad++
+        }
+    }
+    return total > 0 && bad*10 > total
+}
+
+// longestToken 返回 s 中最长的无空白片段的长度
+func longestToken(s string) int {
+    longest := 0
+    for _, f := range strings.Fields(s) {
+        longest = max(longest, len(f))
+    }
+    return longest
+}
+
+// Entropy 返回 s 的按字节计算的香农熵（比特/字节）
+func Entropy(s string) float64 {
+    if s == "" {
+        return 0
+    }
+    var counts [256]int
+    for i := 0; i < len(s); i++ {
+        counts[s[i]]++
+    }
+    h, n := 0.0, float64(len(s))
+    for _, c := range counts {
+        if c > 0 {
+            p := float64(c) / n
+            h -= p * math.Log2(p)
+        }
+    }
+    return h
+}
+
+// Counts 按原因统计被隐藏的匹配数
+type Counts map[Reason]int
+
+// Total 返回被隐藏的匹配总数
+func (c Counts) Total() int {
+    n := 0
+    for _, v := range c {
+        n += v
+    }
+    return n
+}
@@ -0,0 +1,360 @@
+package gql
+
+import (
+    "strings"
+
+    "kingbrain/insight/pkg/i18n"
+)
+
+// Selection 是选择集中的一项：字段（Field 非空）、内联片段（On 非空或两者都空）或片段展开（Spread 非空）
+type Selection struct {
+    Field    string
+    Args     []string
+    On       string
+    Spread   string
+    Children []Selection
+    Line     int
+}
+
+// Operation 是文档中的一个操作；简写形式 { ... } 的 Kind 为 query
+type Operation struct {
+    Kind       string
+    Name       string
+    Selections []Selection
+}
+
+// Fragment 是具名片段
+type Fragment struct {
+    Name       string
+    On         string
+    Selections []Selection
+}
+
+// Document 是解析后的查询文档，只保留校验需要的结构（字段、参数名、类型条件），不保留取值
+type Document struct {
+    Operations []Operation
+    Fragments  map[string]Fragment
+}
+
+type token struct {
+    kind byte // n 名字，p 标点，s 字符串，v 其他取值（数字）
+    text string
+    line int
+}
+
+// lex 切分 GraphQL 文档；逗号与注释被丢弃
+func lex(src string) ([]token, error) {
+    var toks []token
+    line := 1
+    for i := 0; i < len(src); {
+        c := src[i]
+        switch {
+        case c == '\n':
+            line++
+            i++
+        case c == ' ' || c == '\t' || c == '\r' || c == ',':
+            i++
+        case c == '#':
+            for i < len(src) && src[i] != '\n' {
+                i++
+            }
+        case strings.HasPrefix(src[i:], "..."):
+            toks = append(toks, token{'p', "...", line})
+            i += 3
+        case strings.ContainsRune("{}()[]:!$@=|&", rune(c)):
+            toks = append(toks, token{'p', string(c), line})
+            i++
+        case strings.HasPrefix(src[i:], `"""`):
+            end := strings.Index(src[i+3:], `"""`)
+            if end < 0 {
+                return nil, i18n.Errorf("第 %d 行：块字符串没有结束", line)
+            }
+            s := src[i+3 : i+3+end]
+            toks = append(toks, token{'s', s, line})
+            line += strings.Count(s, "\n")
+            i += end + 6
+        case c == '"':
+            j := i + 1
+            for j < len(src) && src[j] != '"' {
+                if src[j] == '\\' {
+                    j++
+                }
+                if j < len(src) && src[j] == '\n' {
+                    return nil, i18n.Errorf("第 %d 行：字符串没有结束", line)
+                }
+                j++
+            }
+            if j >= len(src) {
+                return nil, i18n.Errorf("第 %d 行：字符串没有结束", line)
+            }
+            toks = append(toks, token{'s', src[i+1 : j], line})
+            i = j + 1
+        case c == '_' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z':
+            j := i
+            for j < len(src) && (src[j] == '_' || src[j] >= 'A' && src[j] <= 'Z' || src[j] >= 'a' && src[j] <= 'z' || src[j] >= '0' && src[j] <= '9') {
+                j++
+            }
+            toks = append(toks, token{'n', src[i:j], line})
+            i = j
+        case c == '-' || c >= '0' && c <= '9':
+            j := i + 1
+            for j < len(src) && strings.ContainsRune("0123456789.eE+-", rune(src[j])) {
+                j++
+            }
+            toks = append(toks, token{'v', src[i:j], line})
+            i = j
+        default:
+            return nil, i18n.Errorf("第 %d 行：无法识别的字符 %q", line, c)
+        }
+    }
+    return toks, nil
+}
+
+type parser struct {
+    toks []token
+    pos  int
+}
+
+func (p *parser) peek() token {
+    if p.pos < len(p.toks) {
+        return p.toks[p.pos]
+    }
+    line := 0
+    if n := len(p.toks); n > 0 {
+        line = p.toks[n-1].line
+    }
+    return token{line: line}
+}
+
+func (p *parser) next() token {
+    t := p.peek()
+    if p.pos < len(p.toks) {
+        p.pos++
+    }
+    return t
+}
+
+func (p *parser) is(text string) bool {
+    t := p.peek()
+    return t.kind == 'p' && t.text == text
+}
+
+func (p *parser) expect(text string) error {
+    if t := p.next(); t.kind != 'p' || t.text != text {
+        return i18n.Errorf("第 %d 行：应为 %q，实际为 %q", t.line, text, t.text)
+    }
+    return nil
+}
+
+func (p *parser) name() (string, error) {
+    t := p.next()
+    if t.kind != 'n' {
+        return "", i18n.Errorf("第 %d 行：应为名字，实际为 %q", t.line, t.text)
+    }
+    return t.text, nil
+}
+
+// skipBalanced 跳过从当前的开括号到与之配对的闭括号之间的全部内容
+func (p *parser) skipBalanced() error {
+    open := p.next()
+    depth := 1
+    pairs := map[string]string{"(": ")", "[": "]", "{": "}"}
+    for depth > 0 {
+        t := p.next()
+        switch {
+        case t.kind == 0:
+            return i18n.Errorf("第 %d 行：%q 没有配对", open.line, open.text)
+        case t.kind == 'p' && pairs[t.text] != "":
+            depth++
+        case t.kind == 'p' && (t.text == ")" || t.text == "]" || t.text == "}"):
+            depth--
+        }
+    }
+    return nil
+}
+
+// args 解析参数列表，只保留参数名
+func (p *parser) args() ([]string, error) {
+    if err := p.expect("("); err != nil {
+        return nil, err
+    }
+    var names []string
+    for !p.is(")") {
+        n, err := p.name()
+        if err != nil {
+            return nil, err
+        }
+        if err := p.expect(":"); err != nil {
+            return nil, err
+        }
+        if err := p.skipValue(); err != nil {
+            return nil, err
+        }
+        names = append(names, n)
+    }
+    p.next()
+    return names, nil
+}
+
+func (p *parser) skipValue() error {
+    switch t := p.peek(); {
+    case t.kind == 'p' && (t.text == "[" || t.text == "{"):
+        return p.skipBalanced()
+    case t.kind == 'p' && t.text == "$":
+        p.next()
+        _, err := p.name()
+        return err
+    case t.kind == 'n' || t.kind == 's' || t.kind == 'v':
+        p.next()
+        return nil
+    default:
+        return i18n.Errorf("第 %d 行：应为取值，实际为 %q", t.line, t.text)
+    }
+}
+
+func (p *parser) directives() error {
+    for p.is("@") {
+        p.next()
+        if _, err := p.name(); err != nil {
+            return err
+        }
+        if p.is("(") {
+            if err := p.skipBalanced(); err != nil {
+                return err
+            }
+        }
+    }
+    return nil
+}
+
+func (p *parser) selectionSet() ([]Selection, error) {
+    if err := p.expect("{"); err != nil {
+        return nil, err
+    }
+    var out []Selection
+    for !p.is("}") {
+        if p.peek().kind == 0 {
+            return nil, i18n.Errorf("第 %d 行：选择集没有结束", p.peek().line)
+        }
+        s, err := p.selection()
+        if err != nil {
+            return nil, err
+        }
+        out = append(out, s)
+    }
+    p.next()
+    return out, nil
+}
+
+func (p *parser) selection() (Selection, error) {
+    s := Selection{Line: p.peek().line}
+    if p.is("...") {
+        p.next()
+        switch t := p.peek(); {
+        case t.kind == 'n' && t.text == "on":
+            p.next()
+            on, err := p.name()
+            if err != nil {
+                return s, err
+            }
+            s.On = on
+        case t.kind == 'n':
+            p.next()
+            s.Spread = t.text
+            return s, p.directives()
+        }
+        if err := p.directives(); err != nil {
+            return s, err
+        }
+        children, err := p.selectionSet()
+        s.Children = children
+        return s, err
+    }
+    n, err := p.name()
+    if err != nil {
+        return s, err
+    }
+    if p.is(":") {
+        p.next()
+        if n, err = p.name(); err != nil {
+            return s, err
+        }
+    }
+    s.Field = n
+    if p.is("(") {
+        if s.Args, err = p.args(); err != nil {
+            return s, err
+        }
+    }
+    if err := p.directives(); err != nil {
+        return s, err
+    }
+    if p.is("{") {
+        s.Children, err = p.selectionSet()
+    }
+    return s, err
+}
+
+// Parse 解析查询文档
+func Parse(src string) (*Document, error) {
+    toks, err := lex(src)
+    if err != nil {
+        return nil, err
+    }
+    p := &parser{toks: toks}
+    doc := &Document{Fragments: map[string]Fragment{}}
+    for p.peek().kind != 0 {
+        if p.is("{") {
+            sel, err := p.selectionSet()
+            if err != nil {
+                return nil, err
+            }
+            doc.Operations = append(doc.Operations, Operation{Kind: "query", Selections: sel})
+            continue
+        }
+        kw, err := p.name()
+        if err != nil {
+            return nil, err
+        }
+        switch kw {
+        case "query", "mutation", "subscription":
+            op := Operation{Kind: kw}
+            if p.peek().kind == 'n' {
+                op.Name = p.next().text
+            }
+            if p.is("(") {
+                if err := p.skipBalanced(); err != nil {
+                    return nil, err
+                }
+            }
+            if err := p.directives(); err != nil {
+                return nil, err
+            }
+            if op.Selections, err = p.selectionSet(); err != nil {
+                return nil, err
+            }
+            doc.Operations = append(doc.Operations, op)
+        case "fragment":
+            var f Fragment
+            if f.Name, err = p.name(); err != nil {
+                return nil, err
+            }
+            if on, err := p.name(); err != nil || on != "on" {
+                return nil, i18n.Errorf("第 %d 行：片段 %s 缺少类型条件", p.peek().line, f.Name)
+            }
+            if f.On, err = p.name(); err != nil {
+                return nil, err
+            }
+            if err := p.directives(); err != nil {
+                return nil, err
+            }
+            if f.Selections, err = p.selectionSet(); err != nil {
+                return nil, err
+            }
+            doc.Fragments[f.Name] = f
+        default:
+            return nil, i18n.Errorf("第 %d 行：无法识别的定义 %q", p.toks[p.pos-1].line, kw)
+        }
+    }
+    return doc, nil
+}
@@ -0,0 +1,207 @@
+// Package gql 缓存 Sourcegraph 实例的 GraphQL schema（内省结果），按 schema 校验查询文档，
+// 找出实例上已经不存在或已废弃的字段与参数，并能把 schema 写成 SDL 供本地编写查询时参考。
+// 校验只覆盖字段、参数与类型条件，不检查变量类型与取值。
+package gql
+
+import (
+    "encoding/json"
+    "errors"
+    "net/url"
+    "os"
+    "path/filepath"
+    "regexp"
+    "time"
+)
+
+// IntrospectionQuery 是取得完整 schema 的标准内省查询
+const IntrospectionQuery = `
+query IntrospectionQuery {
+  __schema {
+    queryType { name }
+    mutationType { name }
+    types {
+      kind name description
+      fields(includeDeprecated: true) {
+        name description isDeprecated deprecationReason
+        args { name type { ...TypeRef } defaultValue }
+        type { ...TypeRef }
+      }
+      inputFields { name type { ...TypeRef } defaultValue }
+      interfaces { name }
+      enumValues(includeDeprecated: true) { name isDeprecated deprecationReason }
+      possibleTypes { name }
+    }
+  }
+}
+
+fragment TypeRef on __Type {
+  kind name
+  ofType { kind name ofType { kind name ofType { kind name ofType { kind name } } } }
+}
+`
+
+// TypeRef 是字段或参数的类型，NON_NULL 与 LIST 通过 OfType 嵌套
+type TypeRef struct {
+    Kind   string   `json:"kind"`
+    Name   string   `json:"name,omitempty"`
+    OfType *TypeRef `json:"ofType,omitempty"`
+}
+
+// Named 返回去掉 NON_NULL 与 LIST 之后的类型名
+func (t *TypeRef) Named() string {
+    for t != nil && t.Name == "" {
+        t = t.OfType
+    }
+    if t == nil {
+        return ""
+    }
+    return t.Name
+}
+
+// String 按 SDL 的写法返回类型，如 [String!]!
+func (t *TypeRef) String() string {
+    switch {
+    case t == nil:
+        return ""
+    case t.Kind == "NON_NULL":
+        return t.OfType.String() + "!"
+    case t.Kind == "LIST":
+        return "[" + t.OfType.String() + "]"
+    }
+    return t.Name
+}
+
+// InputValue 是字段参数或输入类型的字段
+type InputValue struct {
+    Name         string   `json:"name"`
+    Type         *TypeRef `json:"type"`
+    DefaultValue *string  `json:"defaultValue,omitempty"`
+}
+
+// Field 是对象或接口类型的字段
+type Field struct {
+    Name              string       `json:"name"`
+    Description       string       `json:"description,omitempty"`
+    Args              []InputValue `json:"args,omitempty"`
+    Type              *TypeRef     `json:"type"`
+    IsDeprecated      bool         `json:"isDeprecated,omitempty"`
+    DeprecationReason string       `json:"deprecationReason,omitempty"`
+}
+
+// EnumValue 是枚举类型的取值
+type EnumValue struct {
+    Name              string `json:"name"`
+    IsDeprecated      bool   `json:"isDeprecated,omitempty"`
+    DeprecationReason string `json:"deprecationReason,omitempty"`
+}
+
+type named struct {
+    Name string `json:"name"`
+}
+
+// Type 是 schema 中的一个类型
+type Type struct {
+    Kind          string       `json:"kind"`
+    Name          string       `json:"name"`
+    Description   string       `json:"description,omitempty"`
+    Fields        []Field      `json:"fields,omitempty"`
+    InputFields   []InputValue `json:"inputFields,omitempty"`
+    Interfaces    []named      `json:"interfaces,omitempty"`
+    EnumValues    []EnumValue  `json:"enumValues,omitempty"`
+    PossibleTypes []named      `json:"possibleTypes,omitempty"`
+}
+
+// Field 按名字查找字段
+func (t *Type) Field(name string) *Field {
+    for i := range t.Fields {
+        if t.Fields[i].Name == name {
+            return &t.Fields[i]
+        }
+    }
+    return nil
+}
+
+// Schema 是内省得到的 schema
+type Schema struct {
+    QueryType    *named  `json:"queryType"`
+    MutationType *named  `json:"mutationType,omitempty"`
+    Types        []*Type `json:"types"`
+
+    byName map[string]*Type
+}
+
+// Type 按名字查找类型
+func (s *Schema) Type(name string) *Type {
+    if s.byName == nil {
+        s.byName = make(map[string]*Type, len(s.Types))
+        for _, t := range s.Types {
+            s.byName[t.Name] = t
+        }
+    }
+    return s.byName[name]
+}
+
+// Cached 是缓存文件的内容：Endpoint 为 schema 所属的实例
+type Cached struct {
+    Endpoint string    `json:"endpoint"`
+    Fetched  time.Time `json:"fetched"`
+    Schema   *Schema   `json:"schema"`
+}
+
+var unsafeChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// CachePath 返回实例 schema 的缓存路径：<用户缓存目录>/insight/schema/<主机名>.json
+func CachePath(endpoint string) (string, error) {
+    dir, err := os.UserCacheDir()
+    if err != nil {
+        return "", err
+    }
+    name := endpoint
+    if u, err := url.Parse(endpoint); err == nil && u.Host != "" {
+        name = u.Host + u.Path
+    }
+    return filepath.Join(dir, "insight", "schema", unsafeChars.ReplaceAllString(name, "_")+".json"), nil
+}
+
+// LoadCached 读取实例的缓存 schema；没有缓存时返回 nil, nil
+func LoadCached(endpoint string) (*Cached, error) {
+    p, err := CachePath(endpoint)
+    if err != nil {
+        return nil, err
+    }
+    b, err := os.ReadFile(p)
+    if errors.Is(err, os.ErrNotExist) {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, err
+    }
+    var c Cached
+    if err := json.Unmarshal(b, &c); err != nil {
+        return nil, err
+    }
+    if c.Schema == nil {
+        return nil, nil
+    }
+    return &c, nil
+}
+
+// Save 写入缓存，先写临时文件再 rename
+func (c *Cached) Save() (string, error) {
+    p, err := CachePath(c.Endpoint)
+    if err != nil {
+        return "", err
+    }
+    if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+        return "", err
+    }
+    b, err := json.Marshal(c)
+    if err != nil {
+        return "", err
+    }
+    tmp := p + ".tmp"
+    if err := os.WriteFile(tmp, b, 0o644); err != nil {
+        return "", err
+    }
+    return p, os.Rename(tmp, p)
+}
@@ -0,0 +1,125 @@
+package gql
+
+import (
+    "fmt"
+    "io"
+    "sort"
+    "strconv"
+    "strings"
+
+    "kingbrain/insight/pkg/i18n"
+)
+
+// WriteSDL 把 schema 写成 SDL；names 非空时只写这些类型，否则写全部（内省类型 __* 除外），按名字排序
+func (s *Schema) WriteSDL(w io.Writer, names []string) error {
+    var types []*Type
+    if len(names) > 0 {
+        for _, n := range names {
+            t := s.Type(n)
+            if t == nil {
+                return i18n.Errorf("schema 中没有类型 %s", n)
+            }
+            types = append(types, t)
+        }
+    } else {
+        for _, t := range s.Types {
+            if !strings.HasPrefix(t.Name, "__") {
+                types = append(types, t)
+            }
+        }
+        sort.Slice(types, func(i, j int) bool { return types[i].Name < types[j].Name })
+    }
+    for i, t := range types {
+        if i > 0 {
+            fmt.Fprintln(w)
+        }
+        writeType(w, t)
+    }
+    return nil
+}
+
+func writeDescription(w io.Writer, indent, desc string) {
+    if desc == "" {
+        return
+    }
+    if !strings.Contains(desc, "\n") {
+        fmt.Fprintf(w, "%s%s\n", indent, strconv.Quote(desc))
+        return
+    }
+    fmt.Fprintf(w, "%s\"\"\"\n", indent)
+    for _, l := range strings.Split(desc, "\n") {
+        fmt.Fprintf(w, "%s%s\n", indent, l)
+    }
+    fmt.Fprintf(w, "%s\"\"\"\n", indent)
+}
+
+func writeType(w io.Writer, t *Type) {
+    writeDescription(w, "", t.Description)
+    switch t.Kind {
+    case "SCALAR":
+        fmt.Fprintf(w, "scalar %s\n", t.Name)
+    case "UNION":
+        var members []string
+        for _, p := range t.PossibleTypes {
+            members = append(members, p.Name)
+        }
+        fmt.Fprintf(w, "union %s = %s\n", t.Name, strings.Join(members, " | "))
+    case "ENUM":
+        fmt.Fprintf(w, "enum %s {\n", t.Name)
+        for _, v := range t.EnumValues {
+            fmt.Fprintf(w, "  %s%s\n", v.Name, deprecated(v.IsDeprecated, v.DeprecationReason))
+        }
+        fmt.Fprintln(w, "}")
+    case "INPUT_OBJECT":
+        fmt.Fprintf(w, "input %s {\n", t.Name)
+        for _, f := range t.InputFields {
+            fmt.Fprintf(w, "  %s\n", inputValue(f))
+        }
+        fmt.Fprintln(w, "}")
+    default:
+        kw := "type"
+        if t.Kind == "INTERFACE" {
+            kw = "interface"
+        }
+        head := kw + " " + t.Name
+        if len(t.Interfaces) > 0 {
+            var names []string
+            for _, i := range t.Interfaces {
+                names = append(names, i.Name)
+            }
+            head += " implements " + strings.Join(names, " & ")
+        }
+        fmt.Fprintf(w, "%s {\n", head)
+        for _, f := range t.Fields {
+            writeDescription(w, "  ", f.Description)
+            args := ""
+            if len(f.Args) > 0 {
+                parts := make([]string, len(f.Args))
+                for i, a := range f.Args {
+                    parts[i] = inputValue(a)
+                }
+                args = "(" + strings.Join(parts, ", ") + ")"
+            }
+            fmt.Fprintf(w, "  %s%s: %s%s\n", f.Name, args, f.Type, deprecated(f.IsDeprecated, f.DeprecationReason))
+        }
+        fmt.Fprintln(w, "}")
+    }
+}
+
+func inputValue(v InputValue) string {
+    s := v.Name + ": " + v.Type.String()
+    if v.DefaultValue != nil {
+        s += " = " + *v.DefaultValue
+    }
+    return s
+}
+
+func deprecated(is bool, reason string) string {
+    switch {
+    case !is:
+        return ""
+    case reason == "":
+        return " @deprecated"
+    }
+    return " @deprecated(reason: " + strconv.Quote(reason) + ")"
+}
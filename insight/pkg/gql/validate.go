@@ -0,0 +1,145 @@
+package gql
+
+import (
+    "kingbrain/insight/pkg/i18n"
+)
+
+// Problem 是查询中与 schema 不符的一处。Removed 为 true 表示字段、参数或类型在 schema 中不存在
+// （多半已被移除），请求会失败；为 false 表示字段已废弃，目前仍可用
+type Problem struct {
+    Line    int    `json:"line"`
+    Path    string `json:"path"`
+    Message string `json:"message"`
+    Removed bool   `json:"removed"`
+}
+
+// ValidateQuery 解析并校验查询文档
+func (s *Schema) ValidateQuery(src string) ([]Problem, error) {
+    doc, err := Parse(src)
+    if err != nil {
+        return nil, err
+    }
+    return s.Validate(doc), nil
+}
+
+// Validate 按 schema 检查文档中的每个字段、参数、类型条件与片段展开
+func (s *Schema) Validate(doc *Document) []Problem {
+    v := &validator{s: s, doc: doc}
+    for _, op := range doc.Operations {
+        var root *named
+        switch op.Kind {
+        case "query":
+            root = s.QueryType
+        case "mutation":
+            root = s.MutationType
+        }
+        if root == nil || s.Type(root.Name) == nil {
+            v.add(Problem{Path: op.Kind, Message: i18n.Sprintf("schema 不支持 %s 操作", op.Kind), Removed: true})
+            continue
+        }
+        v.walk(s.Type(root.Name), op.Selections, "", map[string]bool{})
+    }
+    return v.problems
+}
+
+type validator struct {
+    s        *Schema
+    doc      *Document
+    problems []Problem
+    seen     map[Problem]bool
+}
+
+// add 记下一处问题；同一片段在多处展开时同样的问题只记一次
+func (v *validator) add(p Problem) {
+    if v.seen == nil {
+        v.seen = map[Problem]bool{}
+    }
+    if !v.seen[p] {
+        v.seen[p] = true
+        v.problems = append(v.problems, p)
+    }
+}
+
+func (v *validator) walk(t *Type, sels []Selection, path string, spreading map[string]bool) {
+    for _, sel := range sels {
+        switch {
+        case sel.Spread != "":
+            f, ok := v.doc.Fragments[sel.Spread]
+            if !ok {
+                v.add(Problem{Line: sel.Line, Path: path, Message: i18n.Sprintf("片段 %s 没有定义", sel.Spread), Removed: true})
+                continue
+            }
+            if spreading[f.Name] {
+                continue
+            }
+            spreading[f.Name] = true
+            v.fragment(t, f.On, f.Selections, sel.Line, path, spreading)
+            delete(spreading, f.Name)
+        case sel.Field == "":
+            v.fragment(t, sel.On, sel.Children, sel.Line, path, spreading)
+        default:
+            v.field(t, sel, path, spreading)
+        }
+    }
+}
+
+func (v *validator) fragment(t *Type, on string, sels []Selection, line int, path string, spreading map[string]bool) {
+    if on == "" {
+        v.walk(t, sels, path, spreading)
+        return
+    }
+    ft := v.s.Type(on)
+    if ft == nil {
+        v.add(Problem{Line: line, Path: path, Message: i18n.Sprintf("类型 %s 不存在", on), Removed: true})
+        return
+    }
+    v.walk(ft, sels, path+"<"+on+">", spreading)
+}
+
+func (v *validator) field(t *Type, sel Selection, path string, spreading map[string]bool) {
+    p := join(path, sel.Field)
+    switch sel.Field {
+    case "__typename":
+        return
+    case "__schema", "__type":
+        // 内省字段不在 schema 的类型里
+        return
+    }
+    f := t.Field(sel.Field)
+    if f == nil {
+        v.add(Problem{Line: sel.Line, Path: p, Message: i18n.Sprintf("%s.%s 不存在", t.Name, sel.Field), Removed: true})
+        return
+    }
+    if f.IsDeprecated {
+        msg := i18n.Sprintf("%s.%s 已废弃", t.Name, f.Name)
+        if f.DeprecationReason != "" {
+            msg += ": " + f.DeprecationReason
+        }
+        v.add(Problem{Line: sel.Line, Path: p, Message: msg})
+    }
+    for _, a := range sel.Args {
+        found := false
+        for _, fa := range f.Args {
+            if fa.Name == a {
+                found = true
+                break
+            }
+        }
+        if !found {
+            v.add(Problem{Line: sel.Line, Path: p, Message: i18n.Sprintf("%s.%s 没有参数 %s", t.Name, f.Name, a), Removed: true})
+        }
+    }
+    if len(sel.Children) == 0 {
+        return
+    }
+    if ct := v.s.Type(f.Type.Named()); ct != nil {
+        v.walk(ct, sel.Children, p, spreading)
+    }
+}
+
+func join(path, name string) string {
+    if path == "" {
+        return name
+    }
+    return path + "." + name
+}
@@ -0,0 +1,270 @@
+package graph
+
+import (
+    "fmt"
+    "io"
+    "regexp"
+    "sort"
+    "strings"
+
+    "kingbrain/insight/pkg/i18n"
+)
+
+// Node 是图中的一个节点；Group 非空时 DOT 中同组节点放进同一个 cluster
+type Node struct {
+    ID    string
+    Label string
+    Group string
+    Color string // 可选，CSS 颜色名或 #rrggbb
+}
+
+type Edge struct {
+    From, To string
+    Label    string
+}
+
+// Graph 是一张有向图，节点按 ID 去重
+type Graph struct {
+    Name  string
+    nodes map[string]Node
+    order []string
+    Edges []Edge
+}
+
+func New(name string) *Graph {
+    return &Graph{Name: name, nodes: map[string]Node{}}
+}
+
+// AddNode 添加节点；已存在时只补全空的字段
+func (g *Graph) AddNode(n Node) {
+    if n.Label == "" {
+        n.Label = n.ID
+    }
+    old, ok := g.nodes[n.ID]
+    if !ok {
+        g.order = append(g.order, n.ID)
+        g.nodes[n.ID] = n
+        return
+    }
+    if old.Color == "" {
+        old.Color = n.Color
+    }
+    if old.Group == "" {
+        old.Group = n.Group
+    }
+    g.nodes[n.ID] = old
+}
+
+func (g *Graph) AddEdge(from, to, label string) {
+    g.AddNode(Node{ID: from})
+    g.AddNode(Node{ID: to})
+    g.Edges = append(g.Edges, Edge{From: from, To: to, Label: label})
+}
+
+// Write 按 format（dot|mermaid）输出
+func (g *Graph) Write(w io.Writer, format string) error {
+    switch format {
+    case "dot":
+        return g.WriteDOT(w)
+    case "mermaid":
+        return g.WriteMermaid(w)
+    }
+    return i18n.Errorf("不支持的图格式 %q", format)
+}
+
+func dotQuote(s string) string {
+    return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
+
+// WriteDOT 输出 Graphviz DOT
+func (g *Graph) WriteDOT(w io.Writer) error {
+    var b strings.Builder
+    fmt.Fprintf(&b, "digraph %s {\n  rankdir=LR;\n  node [shape=box, style=rounded, fontname=\"Helvetica\"];\n", dotQuote(g.Name))
+    groups := map[string][]string{}
+    var names []string
+    for _, id := range g.order {
+        n := g.nodes[id]
+        if _, ok := groups[n.Group]; !ok {
+            names = append(names, n.Group)
+        }
+        groups[n.Group] = append(groups[n.Group], id)
+    }
+    sort.Strings(names)
+    for i, grp := range names {
+        indent := "  "
+        if grp != "" {
+            fmt.Fprintf(&b, "  subgraph cluster_%d {\n    label=%s;\n", i, dotQuote(grp))
+            indent = "    "
+        }
+        for _, id := range groups[grp] {
+            n := g.nodes[id]
+            attrs := "label=" + dotQuote(n.Label)
+            if n.Color != "" {
+                attrs += ", style=\"rounded,filled\", fillcolor=" + dotQuote(n.Color)
+            }
+            fmt.Fprintf(&b, "%s%s [%s];\n", indent, dotQuote(id), attrs)
+        }
+        if grp != "" {
+            b.WriteString("  }\n")
+        }
+    }
+    for _, e := range g.Edges {
+        fmt.Fprintf(&b, "  %s -> %s", dotQuote(e.From), dotQuote(e.To))
+        if e.Label != "" {
+            fmt.Fprintf(&b, " [label=%s]", dotQuote(e.Label))
+        }
+        b.WriteString(";\n")
+    }
+    b.WriteString("}\n")
+    _, err := io.WriteString(w, b.String())
+    return err
+}
+
+var mermaidIDRe = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// mermaidText 转义 Mermaid 标签中的引号
+func mermaidText(s string) string {
+    return `"` + strings.NewReplacer(`"`, "#quot;", "\n", "<br>").Replace(s) + `"`
+}
+
+// WriteMermaid 输出 Mermaid flowchart，可直接嵌进 Markdown 的 ```mermaid 代码块
+func (g *Graph) WriteMermaid(w io.Writer) error {
+    ids := map[string]string{}
+    for i, id := range g.order {
+        ids[id] = fmt.Sprintf("n%d_%s", i, mermaidIDRe.ReplaceAllString(id, "_"))
+    }
+    var b strings.Builder
+    b.WriteString("flowchart LR\n")
+    groups := map[string][]string{}
+    var names []string
+    for _, id := range g.order {
+        n := g.nodes[id]
+        if _, ok := groups[n.Group]; !ok {
+            names = append(names, n.Group)
+        }
+        groups[n.Group] = append(groups[n.Group], id)
+    }
+    sort.Strings(names)
+    for i, grp := range names {
+        indent := "  "
+        if grp != "" {
+            fmt.Fprintf(&b, "  subgraph g%d[%s]\n", i, mermaidText(grp))
+            indent = "    "
+        }
+        for _, id := range groups[grp] {
+            fmt.Fprintf(&b, "%s%s[%s]\n", indent, ids[id], mermaidText(g.nodes[id].Label))
+        }
+        if grp != "" {
+            b.WriteString("  end\n")
+        }
+    }
+    for _, e := range g.Edges {
+        if e.Label != "" {
+            fmt.Fprintf(&b, "  %s -->|%s| %s\n", ids[e.From], mermaidText(e.Label), ids[e.To])
+        } else {
+            fmt.Fprintf(&b, "  %s --> %s\n", ids[e.From], ids[e.To])
+        }
+    }
+    for _, id := range g.order {
+        if c := g.nodes[id].Color; c != "" {
+            fmt.Fprintf(&b, "  style %s fill:%s\n", ids[id], c)
+        }
+    }
+    _, err := io.WriteString(w, b.String())
+    return err
+}
+
+// Cycles 返回图中的依赖环：每个强连通分量给出一条经过其中最小节点的环路（首尾相同），
+// 自环也算；各环按首节点排序
+func (g *Graph) Cycles() [][]string {
+    adj := map[string][]string{}
+    for _, e := range g.Edges {
+        adj[e.From] = append(adj[e.From], e.To)
+    }
+    for _, next := range adj {
+        sort.Strings(next)
+    }
+
+    // Tarjan 强连通分量
+    index, low := map[string]int{}, map[string]int{}
+    onStack := map[string]bool{}
+    var stack []string
+    var sccs [][]string
+    var visit func(v string)
+    visit = func(v string) {
+        index[v], low[v] = len(index), len(index)
+        stack = append(stack, v)
+        onStack[v] = true
+        for _, w := range adj[v] {
+            if _, ok := index[w]; !ok {
+                visit(w)
+                low[v] = min(low[v], low[w])
+            } else if onStack[w] {
+                low[v] = min(low[v], index[w])
+            }
+        }
+        if low[v] != index[v] {
+            return
+        }
+        var scc []string
+        for {
+            w := stack[len(stack)-1]
+            stack = stack[:len(stack)-1]
+            onStack[w] = false
+            scc = append(scc, w)
+            if w == v {
+                break
+            }
+        }
+        sccs = append(sccs, scc)
+    }
+    for _, id := range g.order {
+        if _, ok := index[id]; !ok {
+            visit(id)
+        }
+    }
+
+    var cycles [][]string
+    for _, scc := range sccs {
+        sort.Strings(scc)
+        if c := cycleThrough(scc[0], scc, adj); c != nil {
+            cycles = append(cycles, c)
+        }
+    }
+    sort.Slice(cycles, func(i, j int) bool { return cycles[i][0] < cycles[j][0] })
+    return cycles
+}
+
+// cycleThrough 在强连通分量 scc 内广度优先找一条从 start 出发回到 start 的最短环路；
+// 单个节点没有自环时返回 nil
+func cycleThrough(start string, scc []string, adj map[string][]string) []string {
+    in := map[string]bool{}
+    for _, v := range scc {
+        in[v] = true
+    }
+    prev := map[string]string{}
+    queue := []string{start}
+    for len(queue) > 0 {
+        v := queue[0]
+        queue = queue[1:]
+        for _, w := range adj[v] {
+            if w == start {
+                path := []string{start}
+                for u := v; u != start; u = prev[u] {
+                    path = append(path, u)
+                }
+                path = append(path, start)
+                // 回溯得到的是逆序（首尾都是 start）
+                for i, j := 1, len(path)-2; i < j; i, j = i+1, j-1 {
+                    path[i], path[j] = path[j], path[i]
+                }
+                return path
+            }
+            if _, seen := prev[w]; !seen && in[w] {
+                prev[w] = v
+                queue = append(queue, w)
+            }
+        }
+    }
+    return nil
+}
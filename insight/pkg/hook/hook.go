@@ -0,0 +1,179 @@
+// Package hook 加载用户提供的 Starlark 脚本，在搜索结果输出、导出之前逐处处理匹配：
+// 过滤掉不关心的匹配、改写预览，或者加上注解（如按自己的规则算出的严重程度）。
+//
+// 脚本必须定义 match(m)，m 是一个 dict：repo、path、url、line（从 1 开始，路径匹配为 0）、
+// preview 与 annotations（dict）。返回 None 或 False 丢弃这处匹配，True 原样保留，
+// 返回 dict（通常就是改过的 m）时按其中的 preview 与 annotations 更新匹配。
+// 除 Starlark 内置函数外还提供 re_search(pattern, s)，返回第一处匹配的子串，没有时为 None。
+package hook
+
+import (
+    "fmt"
+    "os"
+    "regexp"
+    "sync"
+
+    "go.starlark.net/starlark"
+    "go.starlark.net/syntax"
+    "kingbrain/insight/pkg/i18n"
+    "kingbrain/insight/pkg/sg"
+)
+
+// Script 是加载好的钩子脚本；全局变量在加载后冻结，可以在多个 goroutine 中同时调用
+type Script struct {
+    path string
+    fn   starlark.Callable
+}
+
+var fileOptions = &syntax.FileOptions{Set: true, While: true, GlobalReassign: true}
+
+// Load 执行脚本并取出其中的 match 函数；脚本不能 load 其他文件
+func Load(path string) (*Script, error) {
+    src, err := os.ReadFile(path)
+    if err != nil {
+        return nil, err
+    }
+    globals, err := starlark.ExecFileOptions(fileOptions, newThread(path), path, src, builtins)
+    if err != nil {
+        return nil, scriptError(path, err)
+    }
+    fn, ok := globals["match"].(starlark.Callable)
+    if !ok {
+        return nil, i18n.Errorf("%s 中没有定义 match(m) 函数", path)
+    }
+    return &Script{path: path, fn: fn}, nil
+}
+
+func newThread(path string) *starlark.Thread {
+    return &starlark.Thread{
+        Name:  path,
+        Print: func(_ *starlark.Thread, msg string) { fmt.Fprintln(os.Stderr, msg) },
+    }
+}
+
+// Apply 对文件中的每处匹配调用 match，删掉被丢弃的行；返回 false 表示整个文件都被丢弃。
+// 路径匹配没有行，只按返回值决定去留
+func (s *Script) Apply(fm *sg.FileMatch) (bool, error) {
+    thread := newThread(s.path)
+    if len(fm.LineMatches) == 0 {
+        lm := sg.LineMatch{LineNumber: -1}
+        return s.call(thread, fm, &lm)
+    }
+    kept := fm.LineMatches[:0]
+    for _, lm := range fm.LineMatches {
+        keep, err := s.call(thread, fm, &lm)
+        if err != nil {
+            return false, err
+        }
+        if keep {
+            kept = append(kept, lm)
+        }
+    }
+    fm.LineMatches = kept
+    return len(kept) > 0, nil
+}
+
+func (s *Script) call(thread *starlark.Thread, fm *sg.FileMatch, lm *sg.LineMatch) (bool, error) {
+    ann := starlark.NewDict(len(lm.Annotations))
+    for k, v := range lm.Annotations {
+        ann.SetKey(starlark.String(k), starlark.String(v))
+    }
+    m := starlark.NewDict(6)
+    m.SetKey(starlark.String("repo"), starlark.String(fm.Repository.Name))
+    m.SetKey(starlark.String("path"), starlark.String(fm.File.Path))
+    m.SetKey(starlark.String("url"), starlark.String(fm.File.URL))
+    m.SetKey(starlark.String("line"), starlark.MakeInt(lm.LineNumber+1))
+    m.SetKey(starlark.String("preview"), starlark.String(lm.Preview))
+    m.SetKey(starlark.String("annotations"), ann)
+
+    v, err := starlark.Call(thread, s.fn, starlark.Tuple{m}, nil)
+    if err != nil {
+        return false, scriptError(s.path, err)
+    }
+    switch v := v.(type) {
+    case starlark.NoneType:
+        return false, nil
+    case starlark.Bool:
+        return bool(v), nil
+    case *starlark.Dict:
+        return true, update(lm, v)
+    }
+    return false, i18n.Errorf("%s: match() 应返回 None、bool 或 dict，实际返回了 %s", s.path, v.Type())
+}
+
+// update 按 match 返回的 dict 更新预览与注解；预览改了之后原来的匹配区间不再有效
+func update(lm *sg.LineMatch, d *starlark.Dict) error {
+    if v, ok, _ := d.Get(starlark.String("preview")); ok {
+        p, isStr := starlark.AsString(v)
+        if !isStr {
+            return i18n.Errorf("preview 应为字符串，实际为 %s", v.Type())
+        }
+        if p != lm.Preview {
+            lm.Preview, lm.OffsetAndLengths = p, nil
+        }
+    }
+    v, ok, _ := d.Get(starlark.String("annotations"))
+    if !ok || v == starlark.None {
+        return nil
+    }
+    ann, isDict := v.(*starlark.Dict)
+    if !isDict {
+        return i18n.Errorf("annotations 应为 dict，实际为 %s", v.Type())
+    }
+    lm.Annotations = nil
+    for _, kv := range ann.Items() {
+        if lm.Annotations == nil {
+            lm.Annotations = map[string]string{}
+        }
+        lm.Annotations[str(kv[0])] = str(kv[1])
+    }
+    return nil
+}
+
+// str 取字符串的值本身，其他类型用 Starlark 的表示（数字 3 写成 "3"）
+func str(v starlark.Value) string {
+    if s, ok := starlark.AsString(v); ok {
+        return s
+    }
+    return v.String()
+}
+
+func scriptError(path string, err error) error {
+    if e, ok := err.(*starlark.EvalError); ok {
+        return i18n.Errorf("钩子脚本 %s 出错: %s", path, e.Backtrace())
+    }
+    return i18n.Errorf("钩子脚本 %s 出错: %v", path, err)
+}
+
+var builtins = starlark.StringDict{
+    "re_search": starlark.NewBuiltin("re_search", reSearch),
+}
+
+var (
+    reMu    sync.Mutex
+    reCache = map[string]*regexp.Regexp{}
+)
+
+// reSearch 实现 re_search(pattern, s)；每个 pattern 只编译一次
+func reSearch(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+    var pattern, s string
+    if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 2, &pattern, &s); err != nil {
+        return nil, err
+    }
+    reMu.Lock()
+    re, ok := reCache[pattern]
+    if !ok {
+        var err error
+        if re, err = regexp.Compile(pattern); err != nil {
+            reMu.Unlock()
+            return nil, fmt.Errorf("%s: %v", b.Name(), err)
+        }
+        reCache[pattern] = re
+    }
+    reMu.Unlock()
+    loc := re.FindStringIndex(s)
+    if loc == nil {
+        return starlark.None, nil
+    }
+    return starlark.String(s[loc[0]:loc[1]]), nil
+}
@@ -0,0 +1,86 @@
+// Package i18n 是命令帮助与提示信息的消息目录：源码里的原文（中文或英文）本身就是键，
+// 在当前语言的 locales/<lang>.json 中查找译文，没有译文时原样输出。
+package i18n
+
+import (
+    "embed"
+    "encoding/json"
+    "fmt"
+    "strings"
+)
+
+// Default 是未指定语言时使用的语言
+const Default = "zh-CN"
+
+//go:embed locales/*.json
+var locales embed.FS
+
+var (
+    lang    = Default
+    catalog map[string]string
+)
+
+func init() {
+    catalog, _ = load(Default)
+}
+
+// Supported 返回内置的语言
+func Supported() []string { return []string{"zh-CN", "en-US"} }
+
+// Normalize 把 zh、zh_CN.UTF-8、en、en-GB 等写法归一到内置的语言，不支持时返回 false
+func Normalize(l string) (string, bool) {
+    l = strings.ToLower(strings.ReplaceAll(l, "_", "-"))
+    if i := strings.IndexAny(l, ".@"); i >= 0 {
+        l = l[:i]
+    }
+    switch {
+    case l == "zh" || strings.HasPrefix(l, "zh-"):
+        return "zh-CN", true
+    case l == "en" || strings.HasPrefix(l, "en-"):
+        return "en-US", true
+    }
+    return "", false
+}
+
+// SetLang 切换当前语言；应在程序启动、输出任何文本之前调用
+func SetLang(l string) error {
+    name, ok := Normalize(l)
+    if !ok {
+        return Errorf("不支持的语言 %q（可选：%s）", l, strings.Join(Supported(), ", "))
+    }
+    c, err := load(name)
+    if err != nil {
+        return err
+    }
+    lang, catalog = name, c
+    return nil
+}
+
+// Lang 返回当前语言
+func Lang() string { return lang }
+
+// T 返回 s 在当前语言下的译文
+func T(s string) string {
+    if t, ok := catalog[s]; ok {
+        return t
+    }
+    return s
+}
+
+// Sprintf 先翻译格式串再格式化
+func Sprintf(format string, a ...any) string { return fmt.Sprintf(T(format), a...) }
+
+// Errorf 先翻译格式串再构造错误，支持 %w
+func Errorf(format string, a ...any) error { return fmt.Errorf(T(format), a...) }
+
+func load(name string) (map[string]string, error) {
+    b, err := locales.ReadFile("locales/" + name + ".json")
+    if err != nil {
+        return nil, err
+    }
+    var c map[string]string
+    if err := json.Unmarshal(b, &c); err != nil {
+        return nil, fmt.Errorf("locales/%s.json: %w", name, err)
+    }
+    return c, nil
+}
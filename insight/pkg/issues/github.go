@@ -0,0 +1,90 @@
+package issues
+
+import (
+    "fmt"
+    "net/url"
+    "strings"
+)
+
+type github struct {
+    api   string
+    repo  string // owner/name
+    token string
+}
+
+func (g *github) header() map[string]string {
+    h := map[string]string{"Accept": "application/vnd.github+json"}
+    if g.token != "" {
+        h["Authorization"] = "Bearer " + g.token
+    }
+    return h
+}
+
+func (g *github) FindOpen(title string) (string, bool, error) {
+    q := fmt.Sprintf(`repo:%s is:issue is:open in:title "%s"`, g.repo, title)
+    var out struct {
+        Items []struct {
+            Title   string `json:"title"`
+            HTMLURL string `json:"html_url"`
+        } `json:"items"`
+    }
+    if err := doJSON("GET", g.api+"/search/issues?q="+url.QueryEscape(q), g.header(), nil, &out); err != nil {
+        return "", false, err
+    }
+    // 搜索是模糊匹配，这里要求标题完全一致
+    for _, it := range out.Items {
+        if it.Title == title {
+            return it.HTMLURL, true, nil
+        }
+    }
+    return "", false, nil
+}
+
+func (g *github) Create(is Issue) (string, error) {
+    in := map[string]any{"title": is.Title, "body": is.Body}
+    if len(is.Labels) > 0 {
+        in["labels"] = is.Labels
+    }
+    var out struct {
+        HTMLURL string `json:"html_url"`
+    }
+    if err := doJSON("POST", g.api+"/repos/"+g.repo+"/issues", g.header(), in, &out); err != nil {
+        return "", err
+    }
+    return out.HTMLURL, nil
+}
+
+func (g *github) FindPull(head string) (string, bool, error) {
+    owner, _, _ := strings.Cut(g.repo, "/")
+    v := url.Values{"state": {"open"}, "head": {owner + ":" + head}}
+    var out []struct {
+        HTMLURL string `json:"html_url"`
+    }
+    if err := doJSON("GET", g.api+"/repos/"+g.repo+"/pulls?"+v.Encode(), g.header(), nil, &out); err != nil {
+        return "", false, err
+    }
+    if len(out) == 0 {
+        return "", false, nil
+    }
+    return out[0].HTMLURL, true, nil
+}
+
+func (g *github) CreatePull(p NewPull) (string, error) {
+    if p.Base == "" {
+        var repo struct {
+            DefaultBranch string `json:"default_branch"`
+        }
+        if err := doJSON("GET", g.api+"/repos/"+g.repo, g.header(), nil, &repo); err != nil {
+            return "", err
+        }
+        p.Base = repo.DefaultBranch
+    }
+    in := map[string]any{"title": p.Title, "body": p.Body, "head": p.Head, "base": p.Base, "draft": p.Draft}
+    var out struct {
+        HTMLURL string `json:"html_url"`
+    }
+    if err := doJSON("POST", g.api+"/repos/"+g.repo+"/pulls", g.header(), in, &out); err != nil {
+        return "", err
+    }
+    return out.HTMLURL, nil
+}
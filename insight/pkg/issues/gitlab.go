@@ -0,0 +1,97 @@
+package issues
+
+import (
+    "net/url"
+    "strings"
+)
+
+type gitlab struct {
+    base    string
+    project string // group/sub/name
+    token   string
+}
+
+func (g *gitlab) projectURL() string {
+    return g.base + "/api/v4/projects/" + url.PathEscape(g.project)
+}
+
+func (g *gitlab) endpoint() string {
+    return g.projectURL() + "/issues"
+}
+
+func (g *gitlab) header() map[string]string {
+    h := map[string]string{}
+    if g.token != "" {
+        h["PRIVATE-TOKEN"] = g.token
+    }
+    return h
+}
+
+func (g *gitlab) FindOpen(title string) (string, bool, error) {
+    v := url.Values{"state": {"opened"}, "in": {"title"}, "search": {title}}
+    var out []struct {
+        Title  string `json:"title"`
+        WebURL string `json:"web_url"`
+    }
+    if err := doJSON("GET", g.endpoint()+"?"+v.Encode(), g.header(), nil, &out); err != nil {
+        return "", false, err
+    }
+    for _, it := range out {
+        if it.Title == title {
+            return it.WebURL, true, nil
+        }
+    }
+    return "", false, nil
+}
+
+func (g *gitlab) Create(is Issue) (string, error) {
+    in := map[string]any{"title": is.Title, "description": is.Body}
+    if len(is.Labels) > 0 {
+        in["labels"] = strings.Join(is.Labels, ",")
+    }
+    var out struct {
+        WebURL string `json:"web_url"`
+    }
+    if err := doJSON("POST", g.endpoint(), g.header(), in, &out); err != nil {
+        return "", err
+    }
+    return out.WebURL, nil
+}
+
+func (g *gitlab) FindPull(head string) (string, bool, error) {
+    v := url.Values{"state": {"opened"}, "source_branch": {head}}
+    var out []struct {
+        WebURL string `json:"web_url"`
+    }
+    if err := doJSON("GET", g.projectURL()+"/merge_requests?"+v.Encode(), g.header(), nil, &out); err != nil {
+        return "", false, err
+    }
+    if len(out) == 0 {
+        return "", false, nil
+    }
+    return out[0].WebURL, true, nil
+}
+
+func (g *gitlab) CreatePull(p NewPull) (string, error) {
+    if p.Base == "" {
+        var proj struct {
+            DefaultBranch string `json:"default_branch"`
+        }
+        if err := doJSON("GET", g.projectURL(), g.header(), nil, &proj); err != nil {
+            return "", err
+        }
+        p.Base = proj.DefaultBranch
+    }
+    title := p.Title
+    if p.Draft {
+        title = "Draft: " + title
+    }
+    in := map[string]any{"source_branch": p.Head, "target_branch": p.Base, "title": title, "description": p.Body}
+    var out struct {
+        WebURL string `json:"web_url"`
+    }
+    if err := doJSON("POST", g.projectURL()+"/merge_requests", g.header(), in, &out); err != nil {
+        return "", err
+    }
+    return out.WebURL, nil
+}
@@ -0,0 +1,101 @@
+package issues
+
+import (
+    "bytes"
+    "encoding/json"
+    "fmt"
+    "io"
+    "net/http"
+    "os"
+    "strings"
+    "time"
+
+    "kingbrain/insight/pkg/i18n"
+)
+
+type Issue struct {
+    Title  string
+    Body   string
+    Labels []string
+}
+
+// NewPull 是要创建的 PR（GitLab 上为 MR）；Base 为空时使用仓库默认分支
+type NewPull struct {
+    Title string
+    Body  string
+    Head  string // 源分支，需已推送到同一仓库
+    Base  string
+    Draft bool
+}
+
+// Tracker 是某个代码托管平台上单个仓库的 issue 与 PR 接口
+type Tracker interface {
+    // FindOpen 按标题查找已打开的 issue，返回其链接
+    FindOpen(title string) (url string, found bool, err error)
+    Create(is Issue) (url string, err error)
+    // FindPull 查找以 head 为源分支的已打开 PR，返回其链接
+    FindPull(head string) (url string, found bool, err error)
+    CreatePull(p NewPull) (url string, err error)
+}
+
+var httpClient = &http.Client{Timeout: 15 * time.Second}
+
+// ForRepo 根据 Sourcegraph 仓库名（如 github.com/acme/api）选择对应平台。
+// github.com 或 GITHUB_HOST 走 GitHub；gitlab.* 或 GITLAB_HOST 走 GitLab。
+func ForRepo(repo string) (Tracker, error) {
+    host, path, ok := strings.Cut(repo, "/")
+    if !ok || path == "" {
+        return nil, i18n.Errorf("无法解析仓库名 %q", repo)
+    }
+    switch {
+    case host == "github.com" || host == os.Getenv("GITHUB_HOST"):
+        api := os.Getenv("GITHUB_API_URL")
+        if api == "" {
+            api = "https://api.github.com"
+            if host != "github.com" {
+                api = "https://" + host + "/api/v3"
+            }
+        }
+        return &github{api: api, repo: path, token: os.Getenv("GITHUB_TOKEN")}, nil
+    case strings.HasPrefix(host, "gitlab.") || host == os.Getenv("GITLAB_HOST"):
+        base := os.Getenv("GITLAB_URL")
+        if base == "" {
+            base = "https://" + host
+        }
+        return &gitlab{base: base, project: path, token: os.Getenv("GITLAB_TOKEN")}, nil
+    }
+    return nil, i18n.Errorf("仓库 %s 不在 GitHub/GitLab 上（可设置 GITHUB_HOST/GITLAB_HOST）", repo)
+}
+
+// doJSON 发送一次 JSON 请求并解码响应；非 2xx 时带上服务端返回的内容
+func doJSON(method, url string, header map[string]string, in, out any) error {
+    var body io.Reader
+    if in != nil {
+        b, err := json.Marshal(in)
+        if err != nil {
+            return err
+        }
+        body = bytes.NewReader(b)
+    }
+    req, err := http.NewRequest(method, url, body)
+    if err != nil {
+        return err
+    }
+    req.Header.Set("Content-Type", "application/json")
+    for k, v := range header {
+        req.Header.Set(k, v)
+    }
+    resp, err := httpClient.Do(req)
+    if err != nil {
+        return err
+    }
+    defer resp.Body.Close()
+    if resp.StatusCode >= 300 {
+        msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+        return fmt.Errorf("%s %s: %s: %s", method, url, resp.Status, strings.TrimSpace(string(msg)))
+    }
+    if out == nil {
+        return nil
+    }
+    return json.NewDecoder(resp.Body).Decode(out)
+}
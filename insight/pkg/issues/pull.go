@@ -0,0 +1,141 @@
+package issues
+
+import (
+    "fmt"
+    "os"
+    "regexp"
+    "strconv"
+    "strings"
+
+    "kingbrain/insight/pkg/i18n"
+)
+
+var (
+    pullRefRe = regexp.MustCompile(`^([\w.-]+/[\w.-]+)#(\d+)$`)
+    hunkRe    = regexp.MustCompile(`^@@ -\d+(?:,\d+)? \+(\d+)(?:,\d+)? @@`)
+)
+
+// PullRequest 是 GitHub 上的一个 PR
+type PullRequest struct {
+    gh      *github
+    Number  int
+    Repo    string // Sourcegraph 仓库名，如 github.com/acme/api
+    HeadRef string // 同仓库 PR 为分支名，fork 的 PR 为 head commit
+    BaseRef string // 目标分支的 commit
+}
+
+// PullFile 是 PR 改动的文件及其新增/修改的行号（只有这些行可以挂 review 评论）；
+// Status 为 GitHub 的 added|removed|modified|renamed 等，Previous 为重命名前的路径；删除的文件 Lines 为空
+type PullFile struct {
+    Path     string
+    Status   string
+    Previous string
+    Lines    map[int]bool
+}
+
+type ReviewComment struct {
+    Path string `json:"path"`
+    Line int    `json:"line"`
+    Body string `json:"body"`
+}
+
+// OpenPull 解析 owner/repo#N 并读取 PR 信息；主机取 GITHUB_HOST，默认 github.com
+func OpenPull(ref string) (*PullRequest, error) {
+    m := pullRefRe.FindStringSubmatch(ref)
+    if m == nil {
+        return nil, i18n.Errorf("PR 格式应为 owner/repo#N：%q", ref)
+    }
+    host := os.Getenv("GITHUB_HOST")
+    if host == "" {
+        host = "github.com"
+    }
+    tr, err := ForRepo(host + "/" + m[1])
+    if err != nil {
+        return nil, err
+    }
+    gh := tr.(*github)
+    n, _ := strconv.Atoi(m[2])
+
+    var out struct {
+        Head struct {
+            Ref  string `json:"ref"`
+            SHA  string `json:"sha"`
+            Repo struct {
+                FullName string `json:"full_name"`
+            } `json:"repo"`
+        } `json:"head"`
+        Base struct {
+            SHA string `json:"sha"`
+        } `json:"base"`
+    }
+    if err := doJSON("GET", fmt.Sprintf("%s/repos/%s/pulls/%d", gh.api, gh.repo, n), gh.header(), nil, &out); err != nil {
+        return nil, err
+    }
+    head := out.Head.SHA
+    if out.Head.Repo.FullName == gh.repo {
+        head = out.Head.Ref
+    }
+    return &PullRequest{gh: gh, Number: n, Repo: host + "/" + gh.repo, HeadRef: head, BaseRef: out.Base.SHA}, nil
+}
+
+// Files 列出 PR 改动的文件（含已删除的），分页读取
+func (p *PullRequest) Files() ([]PullFile, error) {
+    var files []PullFile
+    for page := 1; ; page++ {
+        var out []struct {
+            Filename string `json:"filename"`
+            Previous string `json:"previous_filename"`
+            Status   string `json:"status"`
+            Patch    string `json:"patch"`
+        }
+        url := fmt.Sprintf("%s/repos/%s/pulls/%d/files?per_page=100&page=%d", p.gh.api, p.gh.repo, p.Number, page)
+        if err := doJSON("GET", url, p.gh.header(), nil, &out); err != nil {
+            return nil, err
+        }
+        for _, f := range out {
+            pf := PullFile{Path: f.Filename, Status: f.Status, Previous: f.Previous}
+            if f.Status != "removed" {
+                pf.Lines = patchLines(f.Patch)
+            }
+            files = append(files, pf)
+        }
+        if len(out) < 100 {
+            return files, nil
+        }
+    }
+}
+
+// Comment 在 PR 下发一条普通评论
+func (p *PullRequest) Comment(body string) error {
+    url := fmt.Sprintf("%s/repos/%s/issues/%d/comments", p.gh.api, p.gh.repo, p.Number)
+    return doJSON("POST", url, p.gh.header(), map[string]any{"body": body}, nil)
+}
+
+// Review 提交一次 COMMENT 类型的 review，附带逐行评论
+func (p *PullRequest) Review(body string, comments []ReviewComment) error {
+    url := fmt.Sprintf("%s/repos/%s/pulls/%d/reviews", p.gh.api, p.gh.repo, p.Number)
+    in := map[string]any{"body": body, "event": "COMMENT", "comments": comments}
+    return doJSON("POST", url, p.gh.header(), in, nil)
+}
+
+// patchLines 从 unified diff 中取出新文件里新增的行号
+func patchLines(patch string) map[int]bool {
+    lines := map[int]bool{}
+    n := 0
+    for _, l := range strings.Split(patch, "\n") {
+        if m := hunkRe.FindStringSubmatch(l); m != nil {
+            n, _ = strconv.Atoi(m[1])
+            continue
+        }
+        switch {
+        case strings.HasPrefix(l, "+"):
+            lines[n] = true
+            n++
+        case strings.HasPrefix(l, "-"), strings.HasPrefix(l, `\`):
+            // 删除行与 "\ No newline at end of file" 都不占新文件的行号
+        default:
+            n++
+        }
+    }
+    return lines
+}
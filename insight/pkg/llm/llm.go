@@ -0,0 +1,123 @@
+package llm
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "fmt"
+    "io"
+    "net/http"
+    "strings"
+    "time"
+    "unicode/utf8"
+
+    "kingbrain/insight/pkg/i18n"
+)
+
+// Message 是一条对话消息，Role 为 system|user|assistant
+type Message struct {
+    Role    string `json:"role"`
+    Content string `json:"content"`
+}
+
+// Client 调用 OpenAI 兼容的 /chat/completions 接口（OpenAI、vLLM、内部代理等）
+type Client struct {
+    base       string
+    model      string
+    key        string
+    httpClient *http.Client
+}
+
+// New 返回一个 Client；base 为接口根地址（如 https://api.openai.com/v1），key 为空时不带认证头
+func New(base, model, key string) *Client {
+    return &Client{
+        base:       strings.TrimSuffix(base, "/"),
+        model:      model,
+        key:        key,
+        httpClient: &http.Client{Timeout: 2 * time.Minute},
+    }
+}
+
+// Chat 发送一次对话请求并返回第一个候选的回复；maxTokens<=0 时由服务端决定回复长度
+func (c *Client) Chat(ctx context.Context, msgs []Message, maxTokens int) (string, error) {
+    in := map[string]any{"model": c.model, "messages": msgs, "temperature": 0}
+    if maxTokens > 0 {
+        in["max_tokens"] = maxTokens
+    }
+    var out struct {
+        Choices []struct {
+            Message Message `json:"message"`
+        } `json:"choices"`
+    }
+    if err := c.post(ctx, "/chat/completions", in, &out); err != nil {
+        return "", err
+    }
+    if len(out.Choices) == 0 {
+        return "", i18n.Errorf("llm: 响应中没有 choices")
+    }
+    return strings.TrimSpace(out.Choices[0].Message.Content), nil
+}
+
+// Embed 调用 /embeddings 接口，返回与 inputs 一一对应的向量
+func (c *Client) Embed(ctx context.Context, inputs []string) ([][]float32, error) {
+    var out struct {
+        Data []struct {
+            Index     int       `json:"index"`
+            Embedding []float32 `json:"embedding"`
+        } `json:"data"`
+    }
+    if err := c.post(ctx, "/embeddings", map[string]any{"model": c.model, "input": inputs}, &out); err != nil {
+        return nil, err
+    }
+    vecs := make([][]float32, len(inputs))
+    for _, d := range out.Data {
+        if d.Index >= 0 && d.Index < len(vecs) {
+            vecs[d.Index] = d.Embedding
+        }
+    }
+    for i, v := range vecs {
+        if v == nil {
+            return nil, i18n.Errorf("llm: 响应中缺少第 %d 个输入的向量", i)
+        }
+    }
+    return vecs, nil
+}
+
+func (c *Client) post(ctx context.Context, path string, in, out any) error {
+    b, err := json.Marshal(in)
+    if err != nil {
+        return err
+    }
+    req, err := http.NewRequestWithContext(ctx, "POST", c.base+path, bytes.NewReader(b))
+    if err != nil {
+        return err
+    }
+    req.Header.Set("Content-Type", "application/json")
+    if c.key != "" {
+        req.Header.Set("Authorization", "Bearer "+c.key)
+    }
+    resp, err := c.httpClient.Do(req)
+    if err != nil {
+        return err
+    }
+    defer resp.Body.Close()
+    if resp.StatusCode >= 300 {
+        msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+        return fmt.Errorf("llm: %s %s: %s", path, resp.Status, strings.TrimSpace(string(msg)))
+    }
+    return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// EstimateTokens 粗略估算文本的 token 数：ASCII 约 4 个字符一个 token，其余（中文等）每个字符一个。
+// 只用于执行上限，不追求与具体模型的分词器一致
+func EstimateTokens(s string) int {
+    ascii, other := 0, 0
+    for _, r := range s {
+        if r < utf8.RuneSelf {
+            ascii++
+        } else {
+            other++
+        }
+    }
+    return (ascii+3)/4 + other
+}
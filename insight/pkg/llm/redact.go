@@ -0,0 +1,57 @@
+package llm
+
+import (
+    "regexp"
+
+    "kingbrain/insight/pkg/i18n"
+)
+
+// Redacted 是替换敏感内容后的占位符
+const Redacted = "[REDACTED]"
+
+type redaction struct {
+    re   *regexp.Regexp
+    repl string
+}
+
+// 内置规则：常见云厂商/平台的密钥格式、私钥头、JWT，以及 password=xxx 一类赋值（只替换值）
+var defaultRedactions = []redaction{
+    {regexp.MustCompile(`\bAKIA[0-9A-Z]{16}\b`), Redacted},
+    {regexp.MustCompile(`\bgh[pousr]_[A-Za-z0-9]{36,}\b`), Redacted},
+    {regexp.MustCompile(`\bglpat-[A-Za-z0-9_-]{20,}\b`), Redacted},
+    {regexp.MustCompile(`\bxox[abprs]-[A-Za-z0-9-]{10,}\b`), Redacted},
+    {regexp.MustCompile(`\bsk-[A-Za-z0-9_-]{20,}\b`), Redacted},
+    {regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]+`), Redacted},
+    {regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----`), Redacted},
+    {regexp.MustCompile(`(?i)\b((?:password|passwd|pwd|secret|token|api[_-]?key|access[_-]?key)["']?\s*[:=]\s*)("[^"]*"|'[^']*'|[^\s,;]+)`), "${1}" + Redacted},
+}
+
+// Redactor 在内容发往模型之前替换其中的密钥、口令等敏感信息
+type Redactor struct {
+    rules []redaction
+}
+
+// NewRedactor 返回内置规则加上 extra（正则，整个匹配替换为 [REDACTED]）的 Redactor
+func NewRedactor(extra []string) (*Redactor, error) {
+    r := &Redactor{rules: append([]redaction(nil), defaultRedactions...)}
+    for _, p := range extra {
+        re, err := regexp.Compile(p)
+        if err != nil {
+            return nil, i18n.Errorf("脱敏规则 %q: %w", p, err)
+        }
+        r.rules = append(r.rules, redaction{re, Redacted})
+    }
+    return r, nil
+}
+
+// Redact 返回替换后的文本与被替换的处数
+func (r *Redactor) Redact(s string) (string, int) {
+    n := 0
+    for _, rule := range r.rules {
+        s = rule.re.ReplaceAllStringFunc(s, func(m string) string {
+            n++
+            return rule.re.ReplaceAllString(m, rule.repl)
+        })
+    }
+    return s, n
+}
@@ -0,0 +1,202 @@
+package loc
+
+import (
+    "bytes"
+    "path"
+    "regexp"
+    "sort"
+    "strings"
+)
+
+// Stats 是一个文件或一种语言的统计，字段与 scc 的 JSON 输出对应
+type Stats struct {
+    Name       string
+    Count      int // 文件数
+    Lines      int
+    Code       int
+    Comment    int
+    Blank      int
+    Complexity int
+    Bytes      int
+}
+
+// Lang 描述一种语言的注释语法与分支关键字（用于近似 scc 的圈复杂度）
+type Lang struct {
+    Name     string
+    Line     []string    // 行注释前缀
+    Block    [][2]string // 块注释起止
+    Branches *regexp.Regexp
+}
+
+var cBranches = regexp.MustCompile(`\b(?:if|for|while|case|catch)\b|&&|\|\|`)
+
+var (
+    cLike   = []string{"//"}
+    cBlock  = [][2]string{{"/*", "*/"}}
+    hash    = []string{"#"}
+    noBlock [][2]string
+)
+
+var langs = map[string]*Lang{
+    "Go":               {"Go", cLike, cBlock, regexp.MustCompile(`\b(?:if|for|case|select|go|defer)\b|&&|\|\|`)},
+    "Python":           {"Python", hash, [][2]string{{`"""`, `"""`}, {"'''", "'''"}}, regexp.MustCompile(`\b(?:if|elif|for|while|except|with|and|or)\b`)},
+    "JavaScript":       {"JavaScript", cLike, cBlock, cBranches},
+    "TypeScript":       {"TypeScript", cLike, cBlock, cBranches},
+    "Java":             {"Java", cLike, cBlock, cBranches},
+    "Kotlin":           {"Kotlin", cLike, cBlock, regexp.MustCompile(`\b(?:if|for|while|when|catch)\b|&&|\|\|`)},
+    "C":                {"C", cLike, cBlock, cBranches},
+    "C Header":         {"C Header", cLike, cBlock, cBranches},
+    "C++":              {"C++", cLike, cBlock, cBranches},
+    "C#":               {"C#", cLike, cBlock, cBranches},
+    "Rust":             {"Rust", cLike, cBlock, regexp.MustCompile(`\b(?:if|for|while|loop|match)\b|&&|\|\|`)},
+    "Swift":            {"Swift", cLike, cBlock, regexp.MustCompile(`\b(?:if|for|while|case|guard|catch)\b|&&|\|\|`)},
+    "Scala":            {"Scala", cLike, cBlock, regexp.MustCompile(`\b(?:if|for|while|case|catch)\b|&&|\|\|`)},
+    "PHP":              {"PHP", append([]string{"#"}, cLike...), cBlock, regexp.MustCompile(`\b(?:if|elseif|for|foreach|while|case|catch)\b|&&|\|\|`)},
+    "Ruby":             {"Ruby", hash, [][2]string{{"=begin", "=end"}}, regexp.MustCompile(`\b(?:if|elsif|unless|while|until|for|when|rescue)\b|&&|\|\|`)},
+    "Shell":            {"Shell", hash, noBlock, regexp.MustCompile(`\b(?:if|elif|for|while|case)\b|&&|\|\|`)},
+    "SQL":              {"SQL", []string{"--"}, cBlock, regexp.MustCompile(`(?i)\b(?:case|when|and|or)\b`)},
+    "Protocol Buffers": {"Protocol Buffers", cLike, cBlock, nil},
+    "YAML":             {"YAML", hash, noBlock, nil},
+    "TOML":             {"TOML", hash, noBlock, nil},
+    "JSON":             {"JSON", nil, noBlock, nil},
+    "HTML":             {"HTML", nil, [][2]string{{"<!--", "-->"}}, nil},
+    "CSS":              {"CSS", nil, cBlock, nil},
+    "Markdown":         {"Markdown", nil, noBlock, nil},
+    "Makefile":         {"Makefile", hash, noBlock, nil},
+    "Dockerfile":       {"Dockerfile", hash, noBlock, nil},
+}
+
+var byExt = map[string]string{
+    ".go": "Go", ".py": "Python", ".js": "JavaScript", ".jsx": "JavaScript", ".mjs": "JavaScript", ".cjs": "JavaScript",
+    ".ts": "TypeScript", ".tsx": "TypeScript", ".java": "Java", ".kt": "Kotlin", ".kts": "Kotlin",
+    ".c": "C", ".h": "C Header", ".cc": "C++", ".cpp": "C++", ".cxx": "C++", ".hpp": "C++", ".hh": "C++",
+    ".cs": "C#", ".rs": "Rust", ".swift": "Swift", ".scala": "Scala", ".php": "PHP", ".rb": "Ruby",
+    ".sh": "Shell", ".bash": "Shell", ".zsh": "Shell", ".sql": "SQL", ".proto": "Protocol Buffers",
+    ".yaml": "YAML", ".yml": "YAML", ".toml": "TOML", ".json": "JSON", ".html": "HTML", ".htm": "HTML",
+    ".css": "CSS", ".scss": "CSS", ".md": "Markdown", ".markdown": "Markdown",
+}
+
+// Detect 按文件名与扩展名识别语言，不认识时返回 nil
+func Detect(p string) *Lang {
+    base := path.Base(p)
+    switch {
+    case base == "Makefile" || base == "GNUmakefile" || strings.HasSuffix(base, ".mk"):
+        return langs["Makefile"]
+    case base == "Dockerfile" || strings.HasPrefix(base, "Dockerfile."):
+        return langs["Dockerfile"]
+    }
+    return langs[byExt[strings.ToLower(path.Ext(base))]]
+}
+
+// Binary 用前 8KB 是否含 NUL 判断二进制文件
+func Binary(content []byte) bool {
+    return bytes.IndexByte(content[:min(len(content), 8000)], 0) >= 0
+}
+
+// Count 统计一个文件；注释与代码在同一行时算作代码行，与 scc 一致
+func Count(l *Lang, content []byte) Stats {
+    s := Stats{Name: l.Name, Count: 1, Bytes: len(content)}
+    text := strings.TrimSuffix(string(content), "\n")
+    if text == "" {
+        return s
+    }
+    var inBlock string // 当前块注释的结束符
+    for _, line := range strings.Split(text, "\n") {
+        s.Lines++
+        t := strings.TrimSpace(line)
+        if t == "" {
+            s.Blank++
+            continue
+        }
+        code := false
+        for t != "" {
+            if inBlock != "" {
+                i := strings.Index(t, inBlock)
+                if i < 0 {
+                    t = ""
+                    break
+                }
+                t = strings.TrimSpace(t[i+len(inBlock):])
+                inBlock = ""
+                continue
+            }
+            if hasPrefix(t, l.Line) {
+                break
+            }
+            if end, rest, ok := blockStart(t, l.Block); ok {
+                inBlock, t = end, rest
+                continue
+            }
+            code = true
+            // 代码后面开始、本行没有结束的块注释：后续行按注释处理
+            for _, b := range l.Block {
+                if i := strings.LastIndex(t, b[0]); i > 0 && !strings.Contains(t[i+len(b[0]):], b[1]) {
+                    inBlock = b[1]
+                }
+            }
+            break
+        }
+        if code {
+            s.Code++
+            if l.Branches != nil {
+                s.Complexity += len(l.Branches.FindAllStringIndex(line, -1))
+            }
+        } else {
+            s.Comment++
+        }
+    }
+    return s
+}
+
+func hasPrefix(t string, prefixes []string) bool {
+    for _, p := range prefixes {
+        if strings.HasPrefix(t, p) {
+            return true
+        }
+    }
+    return false
+}
+
+// blockStart 判断 t 是否以块注释开头，返回结束符与开头之后的内容
+func blockStart(t string, blocks [][2]string) (end, rest string, ok bool) {
+    for _, b := range blocks {
+        if strings.HasPrefix(t, b[0]) {
+            return b[1], t[len(b[0]):], true
+        }
+    }
+    return "", "", false
+}
+
+// Summary 按语言累加文件统计，按代码行数降序排列
+type Summary map[string]*Stats
+
+// Add 把一个文件的统计计入对应语言
+func (s Summary) Add(f Stats) {
+    t, ok := s[f.Name]
+    if !ok {
+        t = &Stats{Name: f.Name}
+        s[f.Name] = t
+    }
+    t.Count += f.Count
+    t.Lines += f.Lines
+    t.Code += f.Code
+    t.Comment += f.Comment
+    t.Blank += f.Blank
+    t.Complexity += f.Complexity
+    t.Bytes += f.Bytes
+}
+
+// Sorted 返回按代码行数降序排列的语言统计
+func (s Summary) Sorted() []Stats {
+    out := make([]Stats, 0, len(s))
+    for _, t := range s {
+        out = append(out, *t)
+    }
+    sort.Slice(out, func(i, j int) bool {
+        if out[i].Code != out[j].Code {
+            return out[i].Code > out[j].Code
+        }
+        return out[i].Name < out[j].Name
+    })
+    return out
+}
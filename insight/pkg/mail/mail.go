@@ -0,0 +1,103 @@
+package mail
+
+import (
+    "bytes"
+    "crypto/tls"
+    "encoding/base64"
+    "fmt"
+    "mime"
+    "net"
+    "net/smtp"
+    "strconv"
+    "strings"
+    "time"
+
+    "kingbrain/insight/pkg/i18n"
+)
+
+// Server 是 SMTP 服务器；Port 为 465 时使用隐式 TLS，其余端口在服务器支持时升级 STARTTLS
+type Server struct {
+    Host     string
+    Port     int
+    Username string
+    Password string
+}
+
+// SendHTML 发送一封 HTML 邮件
+func (s Server) SendHTML(from string, to []string, subject, html string) error {
+    if s.Host == "" {
+        return i18n.Errorf("未配置 SMTP 服务器")
+    }
+    if len(to) == 0 {
+        return i18n.Errorf("没有收件人")
+    }
+    port := s.Port
+    if port == 0 {
+        port = 587
+    }
+    addr := net.JoinHostPort(s.Host, strconv.Itoa(port))
+    msg := buildMessage(from, to, subject, html)
+
+    var c *smtp.Client
+    var err error
+    if port == 465 {
+        conn, derr := tls.Dial("tcp", addr, &tls.Config{ServerName: s.Host})
+        if derr != nil {
+            return derr
+        }
+        c, err = smtp.NewClient(conn, s.Host)
+    } else {
+        c, err = smtp.Dial(addr)
+        if err == nil {
+            if ok, _ := c.Extension("STARTTLS"); ok {
+                err = c.StartTLS(&tls.Config{ServerName: s.Host})
+            }
+        }
+    }
+    if err != nil {
+        return err
+    }
+    defer c.Close()
+    if s.Username != "" {
+        if err := c.Auth(smtp.PlainAuth("", s.Username, s.Password, s.Host)); err != nil {
+            return err
+        }
+    }
+    if err := c.Mail(from); err != nil {
+        return err
+    }
+    for _, r := range to {
+        if err := c.Rcpt(r); err != nil {
+            return fmt.Errorf("%s: %w", r, err)
+        }
+    }
+    w, err := c.Data()
+    if err != nil {
+        return err
+    }
+    if _, err := w.Write(msg); err != nil {
+        return err
+    }
+    if err := w.Close(); err != nil {
+        return err
+    }
+    return c.Quit()
+}
+
+func buildMessage(from string, to []string, subject, html string) []byte {
+    var b bytes.Buffer
+    fmt.Fprintf(&b, "From: %s\r\n", from)
+    fmt.Fprintf(&b, "To: %s\r\n", strings.Join(to, ", "))
+    fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+    fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+    b.WriteString("MIME-Version: 1.0\r\n")
+    b.WriteString("Content-Type: text/html; charset=utf-8\r\n")
+    b.WriteString("Content-Transfer-Encoding: base64\r\n\r\n")
+    enc := base64.StdEncoding.EncodeToString([]byte(html))
+    for len(enc) > 76 {
+        b.WriteString(enc[:76] + "\r\n")
+        enc = enc[76:]
+    }
+    b.WriteString(enc + "\r\n")
+    return b.Bytes()
+}
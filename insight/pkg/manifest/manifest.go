@@ -0,0 +1,168 @@
+package manifest
+
+import (
+    "encoding/json"
+    "path"
+    "regexp"
+    "strings"
+)
+
+// 生态名与 OSV 保持一致
+const (
+    Go   = "Go"
+    NPM  = "npm"
+    PyPI = "PyPI"
+)
+
+// Dependency 是清单中声明的一个依赖；Line 从 1 开始，无法定位时为 0
+type Dependency struct {
+    Name      string `json:"name"`
+    Version   string `json:"version"`
+    Ecosystem string `json:"ecosystem"`
+    Indirect  bool   `json:"indirect,omitempty"`
+    Line      int    `json:"line,omitempty"`
+}
+
+var (
+    goReqRe  = regexp.MustCompile(`^\s*(?:require\s+)?([^\s()]+)\s+(v[^\s]+)(\s*//\s*indirect)?`)
+    npmDepRe = regexp.MustCompile(`^\s*"([^"]+)"\s*:\s*"([^"]*)"`)
+    pyReqRe  = regexp.MustCompile(`^\s*([A-Za-z0-9][A-Za-z0-9._-]*)(?:\[[^\]]*\])?\s*(===|==|~=|>=|<=|!=|>|<)?\s*([^\s;,#]*)`)
+)
+
+// Ecosystem 按文件名判断清单类型，不认识时返回空串
+func Ecosystem(p string) string {
+    base := path.Base(p)
+    switch {
+    case base == "go.mod":
+        return Go
+    case base == "package.json":
+        return NPM
+    case strings.HasPrefix(base, "requirements") && strings.HasSuffix(base, ".txt"):
+        return PyPI
+    }
+    return ""
+}
+
+// FileFilter 是匹配全部支持的清单文件的 Sourcegraph file: 过滤器
+const FileFilter = `file:(^|/)(go\.mod|package\.json|requirements[^/]*\.txt)$`
+
+// Parse 解析整份清单
+func Parse(p, content string) []Dependency {
+    eco := Ecosystem(p)
+    switch eco {
+    case NPM:
+        return parsePackageJSON(content)
+    case Go:
+        return parseGoMod(content)
+    case PyPI:
+        var deps []Dependency
+        for i, l := range strings.Split(content, "\n") {
+            if d, ok := ParseLine(p, l); ok {
+                d.Line = i + 1
+                deps = append(deps, d)
+            }
+        }
+        return deps
+    }
+    return nil
+}
+
+// ParseLine 从单行中解析依赖声明（用于直接处理搜索结果的行预览）
+func ParseLine(p, line string) (Dependency, bool) {
+    switch eco := Ecosystem(p); eco {
+    case Go:
+        t := strings.TrimSpace(line)
+        if strings.HasPrefix(t, "module ") || strings.HasPrefix(t, "go ") || strings.HasPrefix(t, "replace ") ||
+            strings.HasPrefix(t, "exclude ") || strings.HasPrefix(t, "retract ") || strings.HasPrefix(t, "toolchain ") ||
+            strings.Contains(t, "=>") {
+            return Dependency{}, false
+        }
+        m := goReqRe.FindStringSubmatch(line)
+        if m == nil {
+            return Dependency{}, false
+        }
+        return Dependency{Name: m[1], Version: m[2], Ecosystem: eco, Indirect: m[3] != ""}, true
+    case NPM:
+        m := npmDepRe.FindStringSubmatch(line)
+        if m == nil {
+            return Dependency{}, false
+        }
+        return Dependency{Name: m[1], Version: m[2], Ecosystem: eco}, true
+    case PyPI:
+        t := strings.TrimSpace(line)
+        if t == "" || strings.HasPrefix(t, "#") || strings.HasPrefix(t, "-") {
+            return Dependency{}, false
+        }
+        m := pyReqRe.FindStringSubmatch(t)
+        if m == nil {
+            return Dependency{}, false
+        }
+        return Dependency{Name: strings.ToLower(m[1]), Version: m[2] + m[3], Ecosystem: eco}, true
+    }
+    return Dependency{}, false
+}
+
+func parseGoMod(content string) []Dependency {
+    var deps []Dependency
+    inRequire, inOther := false, false
+    for i, l := range strings.Split(content, "\n") {
+        t := strings.TrimSpace(l)
+        switch {
+        case t == ")":
+            inRequire, inOther = false, false
+            continue
+        case strings.HasPrefix(t, "require ("):
+            inRequire = true
+            continue
+        case strings.HasSuffix(t, "(") && !strings.HasPrefix(t, "require"):
+            inOther = true // replace/exclude/retract 块
+            continue
+        case inOther:
+            continue
+        case !inRequire && !strings.HasPrefix(t, "require "):
+            continue
+        }
+        if d, ok := ParseLine("go.mod", l); ok {
+            d.Line = i + 1
+            deps = append(deps, d)
+        }
+    }
+    return deps
+}
+
+// GoModule 返回 go.mod 中的 module 路径
+func GoModule(content string) string {
+    for _, l := range strings.Split(content, "\n") {
+        if t := strings.TrimSpace(l); strings.HasPrefix(t, "module ") {
+            return strings.Trim(strings.TrimSpace(strings.TrimPrefix(t, "module ")), `"`)
+        }
+    }
+    return ""
+}
+
+func parsePackageJSON(content string) []Dependency {
+    var pkg map[string]json.RawMessage
+    if json.Unmarshal([]byte(content), &pkg) != nil {
+        return nil
+    }
+    lines := strings.Split(content, "\n")
+    var deps []Dependency
+    for _, section := range []string{"dependencies", "devDependencies", "peerDependencies", "optionalDependencies"} {
+        var m map[string]string
+        if json.Unmarshal(pkg[section], &m) != nil {
+            continue
+        }
+        for name, ver := range m {
+            d := Dependency{Name: name, Version: ver, Ecosystem: NPM, Indirect: section != "dependencies"}
+            needle := `"` + name + `"`
+            for i, l := range lines {
+                if strings.Contains(l, needle) {
+                    d.Line = i + 1
+                    break
+                }
+            }
+            deps = append(deps, d)
+        }
+    }
+    return deps
+}
@@ -0,0 +1,71 @@
+package manifest
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "net/url"
+    "strings"
+    "time"
+
+    "kingbrain/insight/pkg/i18n"
+)
+
+var registryClient = &http.Client{Timeout: 10 * time.Second}
+
+// Latest 从公共注册中心（proxy.golang.org / npm / PyPI）查询依赖的最新版本
+func Latest(ctx context.Context, ecosystem, name string) (string, error) {
+    var u string
+    switch ecosystem {
+    case Go:
+        u = "https://proxy.golang.org/" + escapeModule(name) + "/@latest"
+    case NPM:
+        u = "https://registry.npmjs.org/" + strings.Replace(url.PathEscape(name), "%40", "@", 1) + "/latest"
+    case PyPI:
+        u = "https://pypi.org/pypi/" + url.PathEscape(name) + "/json"
+    default:
+        return "", i18n.Errorf("不支持的生态 %q", ecosystem)
+    }
+    req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+    if err != nil {
+        return "", err
+    }
+    resp, err := registryClient.Do(req)
+    if err != nil {
+        return "", err
+    }
+    defer resp.Body.Close()
+    if resp.StatusCode >= 300 {
+        return "", fmt.Errorf("%s: %s", u, resp.Status)
+    }
+    var out struct {
+        Version  string `json:"Version"`
+        NPMVer   string `json:"version"`
+        PyPIInfo struct {
+            Version string `json:"version"`
+        } `json:"info"`
+    }
+    if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+        return "", err
+    }
+    for _, v := range []string{out.Version, out.NPMVer, out.PyPIInfo.Version} {
+        if v != "" {
+            return v, nil
+        }
+    }
+    return "", i18n.Errorf("%s: 响应中没有版本号", u)
+}
+
+// escapeModule 按 Go module proxy 协议转义大写字母：A → !a
+func escapeModule(m string) string {
+    var b strings.Builder
+    for _, r := range m {
+        if r >= 'A' && r <= 'Z' {
+            b.WriteByte('!')
+            r += 'a' - 'A'
+        }
+        b.WriteRune(r)
+    }
+    return b.String()
+}
@@ -0,0 +1,166 @@
+package manifest
+
+import (
+    "regexp"
+    "strconv"
+    "strings"
+    "unicode"
+
+    "kingbrain/insight/pkg/i18n"
+)
+
+// CleanVersion 去掉范围前缀（^ ~ >= == v 等），只留版本号本身
+func CleanVersion(v string) string {
+    v = strings.TrimSpace(v)
+    v = strings.TrimLeft(v, "^~=<>! ")
+    v = strings.TrimPrefix(v, "v")
+    if i := strings.IndexAny(v, " ,|"); i >= 0 {
+        v = v[:i]
+    }
+    return v
+}
+
+// Compare 按 semver 语义比较两个版本（宽松：允许缺位、前缀与 Go 伪版本），返回 -1/0/1
+func Compare(a, b string) int {
+    a, b = CleanVersion(a), CleanVersion(b)
+    // 构建元数据不参与比较
+    a, _, _ = strings.Cut(a, "+")
+    b, _, _ = strings.Cut(b, "+")
+    ac, apre, _ := strings.Cut(a, "-")
+    bc, bpre, _ := strings.Cut(b, "-")
+    ap, bp := strings.Split(ac, "."), strings.Split(bc, ".")
+    for i := 0; i < max(len(ap), len(bp)); i++ {
+        if c := compareField(at(ap, i), at(bp, i)); c != 0 {
+            return c
+        }
+    }
+    // 有预发布标记的版本低于正式版本
+    switch {
+    case apre == bpre:
+        return 0
+    case apre == "":
+        return 1
+    case bpre == "":
+        return -1
+    }
+    pa, pb := strings.Split(apre, "."), strings.Split(bpre, ".")
+    for i := 0; i < max(len(pa), len(pb)); i++ {
+        if i >= len(pa) {
+            return -1
+        }
+        if i >= len(pb) {
+            return 1
+        }
+        if c := compareField(pa[i], pb[i]); c != 0 {
+            return c
+        }
+    }
+    return 0
+}
+
+func at(s []string, i int) string {
+    if i < len(s) {
+        return s[i]
+    }
+    return "0"
+}
+
+// compareField 数字按数值比较，否则按字典序；数字低于非数字
+func compareField(a, b string) int {
+    ai, aerr := strconv.Atoi(a)
+    bi, berr := strconv.Atoi(b)
+    switch {
+    case aerr == nil && berr == nil:
+        return cmpInt(ai, bi)
+    case aerr == nil:
+        return -1
+    case berr == nil:
+        return 1
+    }
+    return strings.Compare(a, b)
+}
+
+func cmpInt(a, b int) int {
+    switch {
+    case a < b:
+        return -1
+    case a > b:
+        return 1
+    }
+    return 0
+}
+
+// Constraint 是一组版本条件，如 "<v1.5"、">=1.2,<2"；全部满足才算匹配，空 Constraint 匹配任何版本
+type Constraint []bound
+
+type bound struct {
+    op      string
+    version string
+}
+
+var opSpaceRe = regexp.MustCompile(`([<>=!]+)\s+`)
+
+// ParseConstraint 解析逗号或空白分隔的版本条件，运算符为 < <= > >= = == !=，省略时为 =
+func ParseConstraint(s string) (Constraint, error) {
+    var c Constraint
+    // 运算符与版本号之间允许有空白（"< v1.5"）
+    s = opSpaceRe.ReplaceAllString(s, "$1")
+    for _, f := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || unicode.IsSpace(r) }) {
+        v := strings.TrimLeft(f, "<>=!")
+        op := f[:len(f)-len(v)]
+        switch op {
+        case "":
+            op = "="
+        case "==":
+            op = "="
+        case "<", "<=", ">", ">=", "=", "!=":
+        default:
+            return nil, i18n.Errorf("无效的版本运算符 %q", op)
+        }
+        switch cv := CleanVersion(v); {
+        case cv == "":
+            return nil, i18n.Errorf("%q 缺少版本号", f)
+        case cv != strings.TrimPrefix(v, "v"):
+            return nil, i18n.Errorf("无效的版本条件 %q", f)
+        }
+        c = append(c, bound{op: op, version: v})
+    }
+    return c, nil
+}
+
+// Match 报告版本 v 是否满足全部条件；无法解析的版本不满足任何非空条件
+func (c Constraint) Match(v string) bool {
+    if len(c) > 0 && CleanVersion(v) == "" {
+        return false
+    }
+    for _, b := range c {
+        n := Compare(v, b.version)
+        var ok bool
+        switch b.op {
+        case "<":
+            ok = n < 0
+        case "<=":
+            ok = n <= 0
+        case ">":
+            ok = n > 0
+        case ">=":
+            ok = n >= 0
+        case "=":
+            ok = n == 0
+        case "!=":
+            ok = n != 0
+        }
+        if !ok {
+            return false
+        }
+    }
+    return true
+}
+
+func (c Constraint) String() string {
+    parts := make([]string, len(c))
+    for i, b := range c {
+        parts[i] = b.op + b.version
+    }
+    return strings.Join(parts, ",")
+}
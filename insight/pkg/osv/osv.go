@@ -0,0 +1,166 @@
+package osv
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "os"
+    "strings"
+    "time"
+
+    "kingbrain/insight/pkg/i18n"
+)
+
+// 单次 querybatch 最多 1000 条查询
+const batchSize = 1000
+
+// Package 对应 OSV 的 package 字段；Ecosystem 取值与 manifest 包一致（Go/npm/PyPI）
+type Package struct {
+    Name      string `json:"name"`
+    Ecosystem string `json:"ecosystem"`
+}
+
+type Query struct {
+    Package Package `json:"package"`
+    Version string  `json:"version"`
+}
+
+// Vuln 是 OSV 漏洞记录中我们关心的部分
+type Vuln struct {
+    ID               string   `json:"id"`
+    Summary          string   `json:"summary"`
+    Aliases          []string `json:"aliases"`
+    DatabaseSpecific struct {
+        Severity string `json:"severity"`
+    } `json:"database_specific"`
+    Severity []struct {
+        Type  string `json:"type"`
+        Score string `json:"score"`
+    } `json:"severity"`
+    Affected []struct {
+        Package Package `json:"package"`
+        Ranges  []struct {
+            Events []map[string]string `json:"events"`
+        } `json:"ranges"`
+    } `json:"affected"`
+}
+
+// Client 访问 OSV.dev API，地址可用 OSV_API_URL 覆盖（例如内网镜像）
+type Client struct {
+    base string
+    http *http.Client
+}
+
+func New() *Client {
+    base := os.Getenv("OSV_API_URL")
+    if base == "" {
+        base = "https://api.osv.dev"
+    }
+    return &Client{base: strings.TrimRight(base, "/"), http: &http.Client{Timeout: 30 * time.Second}}
+}
+
+// QueryBatch 返回与 queries 一一对应的漏洞 ID 列表
+func (c *Client) QueryBatch(ctx context.Context, queries []Query) ([][]string, error) {
+    out := make([][]string, 0, len(queries))
+    for start := 0; start < len(queries); start += batchSize {
+        chunk := queries[start:min(start+batchSize, len(queries))]
+        var resp struct {
+            Results []struct {
+                Vulns []struct {
+                    ID string `json:"id"`
+                } `json:"vulns"`
+            } `json:"results"`
+        }
+        if err := c.do(ctx, "POST", "/v1/querybatch", map[string]any{"queries": chunk}, &resp); err != nil {
+            return nil, err
+        }
+        if len(resp.Results) != len(chunk) {
+            return nil, i18n.Errorf("osv: 返回 %d 条结果，期望 %d 条", len(resp.Results), len(chunk))
+        }
+        for _, r := range resp.Results {
+            ids := make([]string, 0, len(r.Vulns))
+            for _, v := range r.Vulns {
+                ids = append(ids, v.ID)
+            }
+            out = append(out, ids)
+        }
+    }
+    return out, nil
+}
+
+// Get 拉取单条漏洞详情
+func (c *Client) Get(ctx context.Context, id string) (*Vuln, error) {
+    var v Vuln
+    if err := c.do(ctx, "GET", "/v1/vulns/"+id, nil, &v); err != nil {
+        return nil, err
+    }
+    return &v, nil
+}
+
+func (c *Client) do(ctx context.Context, method, path string, in, out any) error {
+    var body bytes.Buffer
+    if in != nil {
+        if err := json.NewEncoder(&body).Encode(in); err != nil {
+            return err
+        }
+    }
+    req, err := http.NewRequestWithContext(ctx, method, c.base+path, &body)
+    if err != nil {
+        return err
+    }
+    req.Header.Set("Content-Type", "application/json")
+    resp, err := c.http.Do(req)
+    if err != nil {
+        return err
+    }
+    defer resp.Body.Close()
+    if resp.StatusCode >= 300 {
+        return fmt.Errorf("osv %s %s: %s", method, path, resp.Status)
+    }
+    return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// Level 归一化为 CRITICAL/HIGH/MODERATE/LOW/UNKNOWN；优先使用 GHSA 的 database_specific.severity
+func (v *Vuln) Level() string {
+    switch s := strings.ToUpper(v.DatabaseSpecific.Severity); s {
+    case "CRITICAL", "HIGH", "MODERATE", "LOW":
+        return s
+    case "MEDIUM":
+        return "MODERATE"
+    }
+    return "UNKNOWN"
+}
+
+// Fixed 返回 pkg 的修复版本（取第一个 fixed 事件），没有时为空
+func (v *Vuln) Fixed(pkg Package) string {
+    for _, a := range v.Affected {
+        if a.Package.Name != pkg.Name || a.Package.Ecosystem != pkg.Ecosystem {
+            continue
+        }
+        for _, r := range a.Ranges {
+            for _, e := range r.Events {
+                if f := e["fixed"]; f != "" {
+                    return f
+                }
+            }
+        }
+    }
+    return ""
+}
+
+// Rank 把等级映射成可比较的整数，UNKNOWN 最低
+func Rank(level string) int {
+    switch strings.ToUpper(level) {
+    case "CRITICAL":
+        return 4
+    case "HIGH":
+        return 3
+    case "MODERATE", "MEDIUM":
+        return 2
+    case "LOW":
+        return 1
+    }
+    return 0
+}
@@ -0,0 +1,131 @@
+package preview
+
+import (
+    "strings"
+    "unicode"
+    "unicode/utf8"
+)
+
+// TabWidth 是展开制表符时的制表位间隔
+var TabWidth = 4
+
+// wide 是终端中占两列的码点区间（东亚宽字符、全角字符与常见 emoji）
+var wide = [][2]rune{
+    {0x1100, 0x115F}, {0x2E80, 0x303E}, {0x3041, 0x33FF}, {0x3400, 0x4DBF},
+    {0x4E00, 0x9FFF}, {0xA000, 0xA4CF}, {0xAC00, 0xD7A3}, {0xF900, 0xFAFF},
+    {0xFE30, 0xFE4F}, {0xFF00, 0xFF60}, {0xFFE0, 0xFFE6}, {0x1F300, 0x1F64F},
+    {0x1F900, 0x1F9FF}, {0x20000, 0x2FFFD}, {0x30000, 0x3FFFD},
+}
+
+// RuneWidth 返回 r 在终端中占的列数：组合符号与零宽字符为 0，宽字符为 2
+func RuneWidth(r rune) int {
+    switch {
+    case r == 0x200B || r == 0x200C || r == 0x200D || r == 0xFEFF:
+        return 0
+    case unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf):
+        return 0
+    }
+    for _, w := range wide {
+        if r >= w[0] && r <= w[1] {
+            return 2
+        }
+    }
+    return 1
+}
+
+// Width 返回 s 的显示宽度（s 中不应再含制表符与控制字符，见 Render）
+func Width(s string) int {
+    n := 0
+    for _, r := range s {
+        n += RuneWidth(r)
+    }
+    return n
+}
+
+// Pad 在右侧补空格到显示宽度 w，超过时原样返回；用于替代对宽字符计数错误的 %-*s
+func Pad(s string, w int) string {
+    if n := Width(s); n < w {
+        return s + strings.Repeat(" ", w-n)
+    }
+    return s
+}
+
+// PadLeft 在左侧补空格到显示宽度 w，用于右对齐的列
+func PadLeft(s string, w int) string {
+    if n := Width(s); n < w {
+        return strings.Repeat(" ", w-n) + s
+    }
+    return s
+}
+
+// Render 把一行预览整理成可以安全打印到终端的文本：非法 UTF-8 替换为 U+FFFD，
+// 控制字符（含 ANSI 转义的 ESC、CRLF 残留的 \r）写成 ^X 形式，制表符按显示宽度展开
+func Render(s string) string {
+    return render(s, nil)
+}
+
+// Highlight 与 Render 相同，另外用 on/off 包住匹配区间；ranges 中每项为 [起始, 长度]，
+// 以 rune 计（不是字节），超出行尾的部分忽略
+func Highlight(s string, ranges [][2]int, on, off string) string {
+    return render(s, func(i int) (bool, bool) {
+        start, end := false, false
+        for _, r := range ranges {
+            if r[1] <= 0 {
+                continue
+            }
+            start = start || i == r[0]
+            end = end || i == r[0]+r[1]
+        }
+        return start, end
+    }, on, off)
+}
+
+// render 逐 rune 输出；mark 返回第 i 个 rune 之前是否要插入开始/结束标记
+func render(s string, mark func(i int) (bool, bool), tags ...string) string {
+    var b strings.Builder
+    col, i, open := 0, 0, false
+    emit := func(i int) {
+        if mark == nil {
+            return
+        }
+        start, end := mark(i)
+        if end && open {
+            b.WriteString(tags[1])
+            open = false
+        }
+        if start && !open {
+            b.WriteString(tags[0])
+            open = true
+        }
+    }
+    for len(s) > 0 {
+        r, size := utf8.DecodeRuneInString(s)
+        s = s[size:]
+        emit(i)
+        i++
+        switch {
+        case r == '\t':
+            n := TabWidth - col%TabWidth
+            b.WriteString(strings.Repeat(" ", n))
+            col += n
+        case r == utf8.RuneError && size == 1:
+            b.WriteRune(utf8.RuneError)
+            col++
+        case r < 0x20 || r == 0x7F:
+            b.WriteByte('^')
+            b.WriteByte(byte(r) ^ 0x40)
+            col += 2
+        case r >= 0x80 && r < 0xA0:
+            b.WriteRune(utf8.RuneError)
+            col++
+        default:
+            b.WriteRune(r)
+            col += RuneWidth(r)
+        }
+    }
+    emit(i)
+    if open {
+        b.WriteString(tags[1])
+    }
+    return b.String()
+}
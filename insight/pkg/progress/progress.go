@@ -0,0 +1,151 @@
+package progress
+
+import (
+    "fmt"
+    "os"
+    "strings"
+    "sync"
+    "time"
+
+    "kingbrain/insight/pkg/i18n"
+)
+
+var spinner = []rune("⠋⠙⠹⠸⠼⠴⠦⠧⠇⠏")
+
+const (
+    barWidth      = 24
+    plainInterval = 10 * time.Second
+)
+
+// Bar 是批量任务的进度显示：终端下为带转圈、ETA 与错误计数的单行进度条，
+// 非终端（CI、重定向）时退化为每隔 plainInterval 打一行纯文本。并发安全。
+type Bar struct {
+    mu      sync.Mutex
+    out     *os.File
+    tty     bool
+    total   int
+    done    int
+    failed  int
+    active  []string
+    start   time.Time
+    last    time.Time
+    tick    int
+    stop    chan struct{}
+    stopped sync.WaitGroup
+}
+
+// New 创建一个总数为 total 的进度条，输出到 stderr
+func New(total int) *Bar {
+    b := &Bar{out: os.Stderr, tty: IsTerminal(os.Stderr), total: total, start: time.Now(), stop: make(chan struct{})}
+    b.last = b.start
+    if b.tty {
+        b.stopped.Add(1)
+        go b.spin()
+    }
+    return b
+}
+
+// IsTerminal 判断文件是否连接到终端
+func IsTerminal(f *os.File) bool {
+    fi, err := f.Stat()
+    return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// Begin 标记一项开始执行，label 显示在进度条末尾
+func (b *Bar) Begin(label string) {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    b.active = append(b.active, label)
+    b.render()
+}
+
+// End 标记一项结束，err 非空计入错误数
+func (b *Bar) End(label string, err error) {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    for i, a := range b.active {
+        if a == label {
+            b.active = append(b.active[:i], b.active[i+1:]...)
+            break
+        }
+    }
+    b.done++
+    if err != nil {
+        b.failed++
+        b.clear()
+        fmt.Fprintf(b.out, "✗ %s: %v\n", label, err)
+    }
+    b.render()
+}
+
+// Finish 停止刷新并打印最终统计
+func (b *Bar) Finish() {
+    if b.tty {
+        close(b.stop)
+        b.stopped.Wait()
+    }
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    b.clear()
+    fmt.Fprint(b.out, i18n.Sprintf("完成 %d/%d，失败 %d，用时 %s\n", b.done, b.total, b.failed, time.Since(b.start).Round(time.Second)))
+}
+
+func (b *Bar) spin() {
+    defer b.stopped.Done()
+    t := time.NewTicker(100 * time.Millisecond)
+    defer t.Stop()
+    for {
+        select {
+        case <-b.stop:
+            return
+        case <-t.C:
+            b.mu.Lock()
+            b.tick++
+            b.render()
+            b.mu.Unlock()
+        }
+    }
+}
+
+func (b *Bar) eta() string {
+    if b.done == 0 {
+        return "?"
+    }
+    per := time.Since(b.start) / time.Duration(b.done)
+    return (per * time.Duration(b.total-b.done)).Round(time.Second).String()
+}
+
+func (b *Bar) render() {
+    pct := 100
+    if b.total > 0 {
+        pct = b.done * 100 / b.total
+    }
+    if !b.tty {
+        if time.Since(b.last) < plainInterval && b.done < b.total {
+            return
+        }
+        b.last = time.Now()
+        fmt.Fprintf(b.out, "progress: %d/%d (%d%%) errors=%d eta=%s\n", b.done, b.total, pct, b.failed, b.eta())
+        return
+    }
+    filled := pct * barWidth / 100
+    cur := ""
+    if len(b.active) > 0 {
+        cur = " | " + b.active[0]
+        if n := len(b.active) - 1; n > 0 {
+            cur += fmt.Sprintf(" (+%d)", n)
+        }
+        if r := []rune(cur); len(r) > 60 {
+            cur = string(r[:59]) + "…"
+        }
+    }
+    fmt.Fprint(b.out, i18n.Sprintf("\r\033[K%c [%s%s] %d/%d %d%% ETA %s 错误:%d%s",
+        spinner[b.tick%len(spinner)], strings.Repeat("█", filled), strings.Repeat("░", barWidth-filled),
+        b.done, b.total, pct, b.eta(), b.failed, cur))
+}
+
+func (b *Bar) clear() {
+    if b.tty {
+        fmt.Fprint(b.out, "\r\033[K")
+    }
+}
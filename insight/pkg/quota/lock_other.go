@@ -0,0 +1,10 @@
+//go:build !unix && !windows
+
+package quota
+
+import "os"
+
+// 没有文件锁的平台上只能依靠 rename 的原子性，并发进程之间的计数可能有少量偏差
+func lock(*os.File) error { return nil }
+
+func unlock(*os.File) error { return nil }
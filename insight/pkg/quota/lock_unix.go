@@ -0,0 +1,12 @@
+//go:build unix
+
+package quota
+
+import (
+    "os"
+    "syscall"
+)
+
+func lock(f *os.File) error { return syscall.Flock(int(f.Fd()), syscall.LOCK_EX) }
+
+func unlock(f *os.File) error { return syscall.Flock(int(f.Fd()), syscall.LOCK_UN) }
@@ -0,0 +1,216 @@
+package quota
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "net/url"
+    "os"
+    "path/filepath"
+    "regexp"
+    "sort"
+    "strings"
+    "time"
+)
+
+// Limit 是一个实例的限速：每分钟 PerMinute 个请求，最多积攒 Burst 个；PerMinute 为 0 表示不限速，
+// 此时只遵守其他进程记下的限流暂停
+type Limit struct {
+    PerMinute int `json:"perMinute"`
+    Burst     int `json:"burst,omitempty"`
+}
+
+func (l Limit) burst() float64 {
+    if l.Burst > 0 {
+        return float64(l.Burst)
+    }
+    return float64(max(l.PerMinute/6, 1))
+}
+
+// State 是 keyring 中一个实例的状态，由所有 kb 进程共同读写
+type State struct {
+    Endpoint    string    `json:"endpoint"`
+    Limit       Limit     `json:"limit"`
+    Tokens      float64   `json:"tokens"`
+    Updated     time.Time `json:"updated"`
+    PausedUntil time.Time `json:"pausedUntil"`
+    Requests    int64     `json:"requests"`
+    Throttled   int64     `json:"throttled"`
+}
+
+// refill 按经过的时间回填令牌；限速变化时按新的容量截断
+func (s *State) refill(l Limit, now time.Time) {
+    if s.Limit != l {
+        if s.Updated.IsZero() {
+            s.Tokens = l.burst()
+        }
+        s.Limit = l
+    }
+    if l.PerMinute > 0 && !s.Updated.IsZero() {
+        s.Tokens += now.Sub(s.Updated).Seconds() * float64(l.PerMinute) / 60
+    }
+    s.Tokens = min(s.Tokens, l.burst())
+    s.Updated = now
+}
+
+// Keyring 是按实例记录配额的目录，每个实例一个状态文件和一个锁文件
+type Keyring struct {
+    Dir string
+}
+
+// Default 返回默认的 keyring：<用户缓存目录>/insight/quota
+func Default() (*Keyring, error) {
+    dir, err := os.UserCacheDir()
+    if err != nil {
+        return nil, err
+    }
+    return &Keyring{Dir: filepath.Join(dir, "insight", "quota")}, nil
+}
+
+var unsafeChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+func (k *Keyring) path(endpoint string) string {
+    name := endpoint
+    if u, err := url.Parse(endpoint); err == nil && u.Host != "" {
+        name = u.Host + strings.TrimSuffix(u.Path, "/")
+    }
+    return filepath.Join(k.Dir, unsafeChars.ReplaceAllString(name, "_"))
+}
+
+// update 在文件锁内读取、修改并写回实例的状态
+func (k *Keyring) update(endpoint string, fn func(s *State)) error {
+    if err := os.MkdirAll(k.Dir, 0o755); err != nil {
+        return err
+    }
+    p := k.path(endpoint)
+    lf, err := os.OpenFile(p+".lock", os.O_CREATE|os.O_RDWR, 0o644)
+    if err != nil {
+        return err
+    }
+    defer lf.Close()
+    if err := lock(lf); err != nil {
+        return err
+    }
+    defer unlock(lf)
+
+    s, err := readState(p + ".json")
+    if err != nil {
+        return err
+    }
+    s.Endpoint = endpoint
+    fn(s)
+    b, err := json.Marshal(s)
+    if err != nil {
+        return err
+    }
+    tmp := p + ".json.tmp"
+    if err := os.WriteFile(tmp, b, 0o644); err != nil {
+        return err
+    }
+    return os.Rename(tmp, p+".json")
+}
+
+// readState 读取状态文件；文件不存在或损坏时返回空状态，令牌在第一次回填时装满
+func readState(p string) (*State, error) {
+    s := &State{}
+    b, err := os.ReadFile(p)
+    if errors.Is(err, os.ErrNotExist) {
+        return s, nil
+    }
+    if err != nil {
+        return nil, err
+    }
+    if json.Unmarshal(b, s) != nil {
+        return &State{}, nil
+    }
+    return s, nil
+}
+
+// Take 取一个令牌，令牌用完或实例被暂停时等待；wait 非空时在每次等待前以等待时长与是否为限流暂停调用
+func (k *Keyring) Take(ctx context.Context, endpoint string, l Limit, wait func(d time.Duration, paused bool)) error {
+    for {
+        var d time.Duration
+        var paused bool
+        err := k.update(endpoint, func(s *State) {
+            now := time.Now()
+            s.refill(l, now)
+            if s.PausedUntil.After(now) {
+                d, paused = s.PausedUntil.Sub(now), true
+                return
+            }
+            if l.PerMinute > 0 && s.Tokens < 1 {
+                d = time.Duration((1 - s.Tokens) / float64(l.PerMinute) * 60 * float64(time.Second))
+                return
+            }
+            if l.PerMinute > 0 {
+                s.Tokens--
+            }
+            s.Requests++
+        })
+        if err != nil || d <= 0 {
+            return err
+        }
+        if wait != nil {
+            wait(d, paused)
+        }
+        select {
+        case <-ctx.Done():
+            return ctx.Err()
+        case <-time.After(d):
+        }
+    }
+}
+
+// Pause 记下实例返回的限流（HTTP 429），在 until 之前所有进程的 Take 都会等待；令牌同时清空
+func (k *Keyring) Pause(endpoint string, l Limit, until time.Time) error {
+    return k.update(endpoint, func(s *State) {
+        s.refill(l, time.Now())
+        if until.After(s.PausedUntil) {
+            s.PausedUntil = until
+        }
+        s.Tokens = 0
+        s.Throttled++
+    })
+}
+
+// List 返回 keyring 中全部实例的状态，按实例排序，令牌数回填到当前时间
+func (k *Keyring) List() ([]State, error) {
+    files, err := filepath.Glob(filepath.Join(k.Dir, "*.json"))
+    if err != nil {
+        return nil, err
+    }
+    now := time.Now()
+    var out []State
+    for _, f := range files {
+        s, err := readState(f)
+        if err != nil {
+            return nil, err
+        }
+        if s.Endpoint == "" {
+            continue
+        }
+        s.refill(s.Limit, now)
+        out = append(out, *s)
+    }
+    sort.Slice(out, func(i, j int) bool { return out[i].Endpoint < out[j].Endpoint })
+    return out, nil
+}
+
+// Reset 删除实例的状态（endpoint 为空时删除全部），下一个请求从满令牌开始；锁文件保留，
+// 正在等待的进程不受影响
+func (k *Keyring) Reset(endpoint string) error {
+    pattern := filepath.Join(k.Dir, "*.json")
+    if endpoint != "" {
+        pattern = k.path(endpoint) + ".json"
+    }
+    files, err := filepath.Glob(pattern)
+    if err != nil {
+        return err
+    }
+    for _, f := range files {
+        if err := os.Remove(f); err != nil && !errors.Is(err, os.ErrNotExist) {
+            return err
+        }
+    }
+    return nil
+}
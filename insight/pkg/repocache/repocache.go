@@ -0,0 +1,127 @@
+package repocache
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "os"
+    "os/exec"
+    "path/filepath"
+    "sort"
+    "time"
+
+    "kingbrain/insight/pkg/sg"
+)
+
+// 每页拉取的仓库数
+const pageSize = 1000
+
+// TTL 是缓存被视为过期的时间，过期后由使用方在后台刷新
+const TTL = 24 * time.Hour
+
+// Cache 是本地的仓库元数据索引
+type Cache struct {
+    Endpoint string        `json:"endpoint"`
+    Updated  time.Time     `json:"updated"`
+    Repos    []sg.RepoInfo `json:"repos"`
+}
+
+// Path 返回缓存文件路径：<用户缓存目录>/insight/repos.json
+func Path() (string, error) {
+    dir, err := os.UserCacheDir()
+    if err != nil {
+        return "", err
+    }
+    return filepath.Join(dir, "insight", "repos.json"), nil
+}
+
+// Load 读取缓存；不存在时返回空缓存
+func Load() (*Cache, error) {
+    p, err := Path()
+    if err != nil {
+        return nil, err
+    }
+    var c Cache
+    b, err := os.ReadFile(p)
+    if errors.Is(err, os.ErrNotExist) {
+        return &c, nil
+    }
+    if err != nil {
+        return nil, err
+    }
+    return &c, json.Unmarshal(b, &c)
+}
+
+// Save 先写到同目录下独有的临时文件再改名覆盖：同时运行的多个刷新各写各的临时文件，
+// 读到的缓存总是某一次完整的写入，写到一半中断也不会留下残缺的文件
+func (c *Cache) Save() error {
+    p, err := Path()
+    if err != nil {
+        return err
+    }
+    if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+        return err
+    }
+    b, err := json.Marshal(c)
+    if err != nil {
+        return err
+    }
+    f, err := os.CreateTemp(filepath.Dir(p), ".repos-*.json")
+    if err != nil {
+        return err
+    }
+    defer os.Remove(f.Name()) // 改名成功后已不存在，删除失败可以忽略
+    if err := f.Chmod(0o644); err != nil {
+        f.Close()
+        return err
+    }
+    if _, err := f.Write(b); err != nil {
+        f.Close()
+        return err
+    }
+    if err := f.Close(); err != nil {
+        return err
+    }
+    return os.Rename(f.Name(), p)
+}
+
+// Stale 表示缓存为空、过期或来自其他实例
+func (c *Cache) Stale(endpoint string) bool {
+    return len(c.Repos) == 0 || c.Endpoint != endpoint || time.Since(c.Updated) > TTL
+}
+
+// Refresh 分页拉取全部仓库；page 在每页完成后回调（可为 nil），用于显示进度
+func Refresh(ctx context.Context, client *sg.Client, page func(n int)) (*Cache, error) {
+    c := &Cache{Endpoint: client.URL(""), Updated: time.Now()}
+    after := ""
+    for {
+        repos, next, err := client.Repositories(ctx, pageSize, after)
+        if err != nil {
+            return nil, err
+        }
+        c.Repos = append(c.Repos, repos...)
+        if page != nil {
+            page(len(c.Repos))
+        }
+        if next == "" {
+            break
+        }
+        after = next
+    }
+    sort.Slice(c.Repos, func(i, j int) bool { return c.Repos[i].Name < c.Repos[j].Name })
+    return c, c.Save()
+}
+
+// RefreshInBackground 启动一个脱离当前进程的 `kb repos --refresh --quiet`，不等待其结束
+func RefreshInBackground() error {
+    exe, err := os.Executable()
+    if err != nil {
+        return err
+    }
+    cmd := exec.Command(exe, "repos", "--refresh", "--quiet")
+    cmd.Stdin, cmd.Stdout, cmd.Stderr = nil, nil, nil
+    if err := cmd.Start(); err != nil {
+        return err
+    }
+    return cmd.Process.Release()
+}
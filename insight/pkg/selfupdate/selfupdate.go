@@ -0,0 +1,237 @@
+package selfupdate
+
+import (
+    "bufio"
+    "bytes"
+    "context"
+    "crypto/ed25519"
+    "crypto/sha256"
+    "encoding/base64"
+    "encoding/hex"
+    "encoding/json"
+    "fmt"
+    "io"
+    "net/http"
+    "net/url"
+    "os"
+    "path/filepath"
+    "runtime"
+    "strings"
+    "time"
+
+    "kingbrain/insight/pkg/i18n"
+)
+
+const checksumsFile = "checksums.txt"
+
+var httpClient = &http.Client{Timeout: 5 * time.Minute}
+
+// Release 是一个可下载的发布版本；Assets 为 文件名→下载地址
+type Release struct {
+    Version string
+    Assets  map[string]string
+}
+
+// AssetName 返回当前平台的二进制文件名：kb_<os>_<arch>，Windows 带 .exe
+func AssetName() string {
+    name := "kb_" + runtime.GOOS + "_" + runtime.GOARCH
+    if runtime.GOOS == "windows" {
+        name += ".exe"
+    }
+    return name
+}
+
+// Latest 查询发布源的最新版本。source 为 github.com/owner/repo（或 GitHub Enterprise 的 host/owner/repo）
+// 时走 releases API；否则视为制品库根地址，要求 <source>/latest 给出版本号，
+// 文件位于 <source>/<version>/ 下
+func Latest(ctx context.Context, source string) (*Release, error) {
+    if owner, repo, host, ok := githubSource(source); ok {
+        return githubLatest(ctx, host, owner, repo)
+    }
+    base := strings.TrimRight(source, "/")
+    b, err := get(ctx, base+"/latest", "")
+    if err != nil {
+        return nil, err
+    }
+    v := strings.TrimSpace(string(b))
+    if v == "" {
+        return nil, i18n.Errorf("%s/latest 为空", base)
+    }
+    dir := base + "/" + url.PathEscape(v) + "/"
+    return &Release{
+        Version: v,
+        Assets: map[string]string{
+            AssetName():            dir + AssetName(),
+            checksumsFile:          dir + checksumsFile,
+            checksumsFile + ".sig": dir + checksumsFile + ".sig",
+        },
+    }, nil
+}
+
+func githubSource(source string) (owner, repo, host string, ok bool) {
+    s := strings.TrimSuffix(strings.TrimPrefix(strings.TrimPrefix(source, "https://"), "http://"), "/")
+    parts := strings.Split(s, "/")
+    if len(parts) != 3 || !strings.Contains(parts[0], "github") {
+        return "", "", "", false
+    }
+    return parts[1], parts[2], parts[0], true
+}
+
+func githubLatest(ctx context.Context, host, owner, repo string) (*Release, error) {
+    api := "https://api.github.com"
+    if host != "github.com" {
+        api = "https://" + host + "/api/v3"
+    }
+    b, err := get(ctx, fmt.Sprintf("%s/repos/%s/%s/releases/latest", api, owner, repo), os.Getenv("GITHUB_TOKEN"))
+    if err != nil {
+        return nil, err
+    }
+    var rel struct {
+        TagName string `json:"tag_name"`
+        Assets  []struct {
+            Name string `json:"name"`
+            URL  string `json:"browser_download_url"`
+        } `json:"assets"`
+    }
+    if err := json.Unmarshal(b, &rel); err != nil {
+        return nil, err
+    }
+    r := &Release{Version: rel.TagName, Assets: map[string]string{}}
+    for _, a := range rel.Assets {
+        r.Assets[a.Name] = a.URL
+    }
+    return r, nil
+}
+
+// Download 下载当前平台的二进制并校验 sha256；publicKey 非空时还要求 checksums.txt.sig 的 ed25519 签名有效
+func (r *Release) Download(ctx context.Context, publicKey string) ([]byte, error) {
+    name := AssetName()
+    binURL, ok := r.Assets[name]
+    if !ok {
+        return nil, i18n.Errorf("版本 %s 没有 %s 的构建", r.Version, name)
+    }
+    sumURL, ok := r.Assets[checksumsFile]
+    if !ok {
+        return nil, i18n.Errorf("版本 %s 缺少 %s，拒绝安装未校验的二进制", r.Version, checksumsFile)
+    }
+    sums, err := get(ctx, sumURL, "")
+    if err != nil {
+        return nil, err
+    }
+    if publicKey != "" {
+        if err := verifySignature(ctx, r, sums, publicKey); err != nil {
+            return nil, err
+        }
+    }
+    want, err := lookupChecksum(sums, name)
+    if err != nil {
+        return nil, err
+    }
+    bin, err := get(ctx, binURL, "")
+    if err != nil {
+        return nil, err
+    }
+    got := sha256.Sum256(bin)
+    if hex.EncodeToString(got[:]) != want {
+        return nil, i18n.Errorf("%s 校验和不匹配：期望 %s，实际 %x", name, want, got)
+    }
+    return bin, nil
+}
+
+func verifySignature(ctx context.Context, r *Release, sums []byte, publicKey string) error {
+    key, err := base64.StdEncoding.DecodeString(publicKey)
+    if err != nil || len(key) != ed25519.PublicKeySize {
+        return i18n.Errorf("update.public_key 不是有效的 base64 ed25519 公钥")
+    }
+    sigURL, ok := r.Assets[checksumsFile+".sig"]
+    if !ok {
+        return i18n.Errorf("版本 %s 缺少 %s.sig", r.Version, checksumsFile)
+    }
+    raw, err := get(ctx, sigURL, "")
+    if err != nil {
+        return err
+    }
+    // 签名文件可以是原始 64 字节，也可以是 base64 文本
+    sig := raw
+    if len(raw) != ed25519.SignatureSize {
+        if sig, err = base64.StdEncoding.DecodeString(strings.TrimSpace(string(raw))); err != nil {
+            return fmt.Errorf("%s.sig: %w", checksumsFile, err)
+        }
+    }
+    if !ed25519.Verify(ed25519.PublicKey(key), sums, sig) {
+        return i18n.Errorf("%s 签名校验失败", checksumsFile)
+    }
+    return nil
+}
+
+// lookupChecksum 解析 sha256sum 格式（"<hex>  <name>"，名字前可能带 *）
+func lookupChecksum(sums []byte, name string) (string, error) {
+    sc := bufio.NewScanner(bytes.NewReader(sums))
+    for sc.Scan() {
+        f := strings.Fields(sc.Text())
+        if len(f) == 2 && strings.TrimPrefix(f[1], "*") == name {
+            return strings.ToLower(f[0]), nil
+        }
+    }
+    return "", i18n.Errorf("%s 中没有 %s", checksumsFile, name)
+}
+
+// Replace 用新二进制替换 exe：先写同目录临时文件再 rename，保证原子性；
+// Windows 无法覆盖正在运行的 exe，先把旧文件挪成 .old
+func Replace(exe string, bin []byte) error {
+    exe, err := filepath.EvalSymlinks(exe)
+    if err != nil {
+        return err
+    }
+    info, err := os.Stat(exe)
+    if err != nil {
+        return err
+    }
+    tmp, err := os.CreateTemp(filepath.Dir(exe), ".kb-update-*")
+    if err != nil {
+        return i18n.Errorf("无法写入 %s（可能需要更高权限）: %w", filepath.Dir(exe), err)
+    }
+    defer os.Remove(tmp.Name())
+    if _, err := tmp.Write(bin); err != nil {
+        tmp.Close()
+        return err
+    }
+    if err := tmp.Close(); err != nil {
+        return err
+    }
+    if err := os.Chmod(tmp.Name(), info.Mode().Perm()|0o111); err != nil {
+        return err
+    }
+    if runtime.GOOS == "windows" {
+        old := exe + ".old"
+        os.Remove(old)
+        if err := os.Rename(exe, old); err != nil {
+            return err
+        }
+        if err := os.Rename(tmp.Name(), exe); err != nil {
+            os.Rename(old, exe)
+            return err
+        }
+        return nil
+    }
+    return os.Rename(tmp.Name(), exe)
+}
+
+func get(ctx context.Context, u, token string) ([]byte, error) {
+    req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+    if err != nil {
+        return nil, err
+    }
+    if token != "" {
+        req.Header.Set("Authorization", "Bearer "+token)
+    }
+    resp, err := httpClient.Do(req)
+    if err != nil {
+        return nil, err
+    }
+    defer resp.Body.Close()
+    if resp.StatusCode >= 300 {
+        return nil, fmt.Errorf("GET %s: %s", u, resp.Status)
+    }
+    return io.ReadAll(resp.Body)
+}
@@ -0,0 +1,264 @@
+package server
+
+import (
+    "context"
+    "crypto/sha256"
+    "encoding/json"
+    "io"
+    "math"
+    "net/http"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+
+    "go.opentelemetry.io/otel"
+    "go.opentelemetry.io/otel/attribute"
+    "go.opentelemetry.io/otel/propagation"
+    "kingbrain/insight/pkg/tracing"
+)
+
+// Key 是一个调用方的 API key：RatePerMinute 为 0 表示不限速；
+// Commands 为允许调用的命令（如 search、find、ws list），"*" 表示全部
+type Key struct {
+    Name          string
+    Secret        string
+    RatePerMinute int
+    Commands      []string
+}
+
+// Allows 判断 key 是否可以调用 command；配置 "ws" 时也允许 "ws list" 等子命令
+func (k *Key) Allows(command string) bool {
+    for _, c := range k.Commands {
+        if c == "*" || c == command || strings.HasPrefix(command, c+" ") {
+            return true
+        }
+    }
+    return false
+}
+
+// Options 是 Guard 的配置；NoAuth 时所有请求视为拥有全部命令权限的匿名调用方
+type Options struct {
+    Keys    []Key
+    Origins []string // 允许跨域调用的 Origin，"*" 表示任意
+    Audit   io.Writer
+    NoAuth  bool
+}
+
+// Guard 为请求做 CORS、API key 认证、按 key 限速与审计日志
+type Guard struct {
+    keys    map[[32]byte]*Key
+    buckets map[string]*bucket
+    origins []string
+    noAuth  bool
+
+    mu    sync.Mutex // 保护 audit 的写入
+    audit io.Writer
+}
+
+// AuditEntry 是审计日志中的一行；不记录密钥与 token 本身，只记录 key 的名字与 token 对应的用户
+type AuditEntry struct {
+    Time       time.Time `json:"time"`
+    Key        string    `json:"key,omitempty"`
+    User       string    `json:"user,omitempty"`
+    Remote     string    `json:"remote"`
+    Origin     string    `json:"origin,omitempty"`
+    Method     string    `json:"method"`
+    Path       string    `json:"path"`
+    Command    string    `json:"command,omitempty"`
+    Detail     string    `json:"detail,omitempty"`
+    Status     int       `json:"status"`
+    DurationMS int64     `json:"duration_ms"`
+}
+
+type ctxKey struct{}
+
+type call struct {
+    key   *Key
+    entry *AuditEntry
+}
+
+var anonymous = &Key{Name: "anonymous", Commands: []string{"*"}}
+
+// New 按配置创建 Guard；同名的 key 共用一个限速桶
+func New(o Options) *Guard {
+    g := &Guard{
+        keys:    map[[32]byte]*Key{},
+        buckets: map[string]*bucket{},
+        origins: o.Origins,
+        noAuth:  o.NoAuth,
+        audit:   o.Audit,
+    }
+    for i := range o.Keys {
+        k := &o.Keys[i]
+        g.keys[sha256.Sum256([]byte(k.Secret))] = k
+        if k.RatePerMinute > 0 {
+            g.buckets[k.Name] = newBucket(k.RatePerMinute)
+        }
+    }
+    return g
+}
+
+// Wrap 包装 next：预检请求直接应答，其余请求要求 Authorization: Bearer <key> 或 X-API-Key，
+// 通过后在 context 中带上调用方，由 handler 调用 Allow 检查命令权限。每个请求写一行审计日志
+func (g *Guard) Wrap(next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        started := time.Now()
+        entry := &AuditEntry{Time: started.UTC(), Remote: r.RemoteAddr, Origin: r.Header.Get("Origin"), Method: r.Method, Path: r.URL.Path}
+        sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+        defer func() {
+            entry.Status, entry.DurationMS = sw.status, time.Since(started).Milliseconds()
+            g.record(entry)
+        }()
+
+        if !g.cors(sw, r) {
+            Error(sw, http.StatusForbidden, "origin not allowed")
+            return
+        }
+        if r.Method == http.MethodOptions {
+            sw.WriteHeader(http.StatusNoContent)
+            return
+        }
+        k := g.authenticate(r)
+        if k == nil {
+            sw.Header().Set("WWW-Authenticate", "Bearer")
+            Error(sw, http.StatusUnauthorized, "missing or invalid API key")
+            return
+        }
+        entry.Key = k.Name
+        if b := g.buckets[k.Name]; b != nil {
+            if wait := b.take(); wait > 0 {
+                sw.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+                Error(sw, http.StatusTooManyRequests, "rate limit exceeded")
+                return
+            }
+        }
+
+        // 每个请求单独成为一条 trace，调用方带了 traceparent 时接到调用方的 trace 上
+        ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+        ctx, span := tracing.Start(ctx, "serve "+r.URL.Path, attribute.String("insight.api_key", k.Name))
+        defer func() {
+            span.SetAttributes(attribute.Int("http.status_code", sw.status))
+            tracing.End(span, nil)
+        }()
+        ctx = context.WithValue(ctx, ctxKey{}, &call{key: k, entry: entry})
+        next.ServeHTTP(sw, r.WithContext(ctx))
+    })
+}
+
+// Allow 在审计日志中记下命令及其参数，并返回当前调用方是否有权调用该命令
+func Allow(r *http.Request, command, detail string) bool {
+    c, ok := r.Context().Value(ctxKey{}).(*call)
+    if !ok {
+        return false
+    }
+    c.entry.Command, c.entry.Detail = command, detail
+    return c.key.Allows(command)
+}
+
+// SetUser 在审计日志中记下请求所代表的 Sourcegraph 用户（使用调用方自带的 token 时）
+func SetUser(r *http.Request, user string) {
+    if c, ok := r.Context().Value(ctxKey{}).(*call); ok {
+        c.entry.User = user
+    }
+}
+
+func (g *Guard) authenticate(r *http.Request) *Key {
+    if g.noAuth {
+        return anonymous
+    }
+    secret := r.Header.Get("X-API-Key")
+    if auth := r.Header.Get("Authorization"); secret == "" && strings.HasPrefix(auth, "Bearer ") {
+        secret = strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
+    }
+    if secret == "" {
+        return nil
+    }
+    return g.keys[sha256.Sum256([]byte(secret))]
+}
+
+// cors 为允许的 Origin 加上响应头；不带 Origin 的请求（curl、服务端调用）不受限制
+func (g *Guard) cors(w http.ResponseWriter, r *http.Request) bool {
+    origin := r.Header.Get("Origin")
+    if origin == "" {
+        return true
+    }
+    allowed := false
+    for _, o := range g.origins {
+        if o == "*" || strings.EqualFold(o, origin) {
+            allowed = true
+            break
+        }
+    }
+    if !allowed {
+        return false
+    }
+    h := w.Header()
+    h.Set("Access-Control-Allow-Origin", origin)
+    h.Add("Vary", "Origin")
+    if r.Method == http.MethodOptions {
+        h.Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+        h.Set("Access-Control-Allow-Headers", "Authorization, X-API-Key, X-Sourcegraph-Token, Content-Type, Traceparent")
+        h.Set("Access-Control-Max-Age", "600")
+    }
+    return true
+}
+
+func (g *Guard) record(e *AuditEntry) {
+    if g.audit == nil {
+        return
+    }
+    b, _ := json.Marshal(e)
+    g.mu.Lock()
+    defer g.mu.Unlock()
+    g.audit.Write(append(b, '\n'))
+}
+
+// Error 以 {"error": msg} 的形式返回错误
+func Error(w http.ResponseWriter, status int, msg string) {
+    JSON(w, status, map[string]string{"error": msg})
+}
+
+// JSON 写出 JSON 响应
+func JSON(w http.ResponseWriter, status int, v any) {
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(status)
+    json.NewEncoder(w).Encode(v)
+}
+
+type statusWriter struct {
+    http.ResponseWriter
+    status int
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+    w.status = code
+    w.ResponseWriter.WriteHeader(code)
+}
+
+// bucket 是令牌桶：容量为每分钟的请求数，按均匀速率回填
+type bucket struct {
+    mu     sync.Mutex
+    tokens float64
+    max    float64
+    rate   float64 // 每秒回填的令牌数
+    last   time.Time
+}
+
+func newBucket(perMinute int) *bucket {
+    return &bucket{tokens: float64(perMinute), max: float64(perMinute), rate: float64(perMinute) / 60, last: time.Now()}
+}
+
+// take 取一个令牌；没有令牌时返回需要等待的时间
+func (b *bucket) take() time.Duration {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    now := time.Now()
+    b.tokens = min(b.max, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+    b.last = now
+    if b.tokens < 1 {
+        return time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+    }
+    b.tokens--
+    return 0
+}
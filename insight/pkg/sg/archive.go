@@ -0,0 +1,30 @@
+package sg
+
+import (
+    "context"
+    "io"
+    "net/http"
+    "strings"
+)
+
+// Archive 以 tar 流下载仓库在某个 revision 下的全部文件（raw 接口，Accept: application/x-tar）；
+// rev 为空时取默认分支。调用方负责关闭返回的 ReadCloser。与 GraphQL 请求一样遵守共享配额、
+// 重试 429 并在失败时换用备用端点；归档可能很大，不设整体超时
+func (c *Client) Archive(ctx context.Context, repo, rev string) (io.ReadCloser, error) {
+    path := "/" + repo
+    if rev != "" {
+        path += "@" + rev
+    }
+    resp, _, errs := c.do(ctx, 0, func(ctx context.Context, base string) (*http.Request, error) {
+        req, err := http.NewRequestWithContext(ctx, "GET", strings.TrimSuffix(base, "/")+path+"/-/raw/", nil)
+        if err != nil {
+            return nil, err
+        }
+        req.Header.Set("Accept", "application/x-tar")
+        return req, nil
+    })
+    if resp == nil {
+        return nil, requestError(errs)
+    }
+    return resp.Body, nil
+}
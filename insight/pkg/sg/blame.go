@@ -0,0 +1,96 @@
+package sg
+
+import (
+    "context"
+    "time"
+
+    "kingbrain/insight/pkg/i18n"
+)
+
+const blameQuery = `
+query ($repo: String!, $rev: String!, $path: String!, $startLine: Int!, $endLine: Int!) {
+  repository(name: $repo) {
+    commit(rev: $rev) {
+      blob(path: $path) {
+        blame(startLine: $startLine, endLine: $endLine) {
+          startLine endLine
+          author { person { name email } date }
+          commit { oid subject body url }
+        }
+      }
+    }
+  }
+}
+`
+
+// Hunk 是 blame 的一段：StartLine 起、EndLine 之前（不含）的行最后由 Commit 修改，行号从 1 开始
+type Hunk struct {
+    StartLine int    `json:"startLine"`
+    EndLine   int    `json:"endLine"`
+    Commit    Commit `json:"commit"`
+}
+
+// Blame 返回文件在 rev（为空时取 HEAD）下第 start 到 end 行（含两端）的 blame
+func (c *Client) Blame(ctx context.Context, repo, rev, path string, start, end int) ([]Hunk, error) {
+    if rev == "" {
+        rev = "HEAD"
+    }
+    var out struct {
+        Data struct {
+            Repository *struct {
+                Commit *struct {
+                    Blob *struct {
+                        Blame []struct {
+                            StartLine int `json:"startLine"`
+                            EndLine   int `json:"endLine"`
+                            Author    struct {
+                                Person struct {
+                                    Name  string `json:"name"`
+                                    Email string `json:"email"`
+                                } `json:"person"`
+                                Date time.Time `json:"date"`
+                            } `json:"author"`
+                            Commit struct {
+                                OID     string `json:"oid"`
+                                Subject string `json:"subject"`
+                                Body    string `json:"body"`
+                                URL     string `json:"url"`
+                            } `json:"commit"`
+                        } `json:"blame"`
+                    } `json:"blob"`
+                } `json:"commit"`
+            } `json:"repository"`
+        } `json:"data"`
+        Errors []gqlError `json:"errors"`
+    }
+    vars := map[string]any{"repo": repo, "rev": rev, "path": path, "startLine": start, "endLine": end}
+    if err := c.GraphQL(ctx, blameQuery, vars, &out); err != nil {
+        return nil, err
+    }
+    if err := joinErrors(out.Errors); err != nil {
+        return nil, err
+    }
+    r := out.Data.Repository
+    switch {
+    case r == nil:
+        return nil, i18n.Errorf("仓库不存在：%s", repo)
+    case r.Commit == nil:
+        return nil, i18n.Errorf("%s 中找不到 revision %s", repo, rev)
+    case r.Commit.Blob == nil:
+        return nil, i18n.Errorf("%s@%s 中找不到文件 %s", repo, rev, path)
+    }
+    hunks := make([]Hunk, 0, len(r.Commit.Blob.Blame))
+    for _, h := range r.Commit.Blob.Blame {
+        hunks = append(hunks, Hunk{StartLine: h.StartLine, EndLine: h.EndLine, Commit: Commit{
+            Repo:    repo,
+            OID:     h.Commit.OID,
+            Subject: h.Commit.Subject,
+            Body:    h.Commit.Body,
+            Author:  h.Author.Person.Name,
+            Email:   h.Author.Person.Email,
+            Date:    h.Author.Date,
+            URL:     h.Commit.URL,
+        }})
+    }
+    return hunks, nil
+}
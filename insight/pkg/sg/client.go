@@ -2,32 +2,196 @@ package sg
 
 import (
     "bytes"
+    "context"
     "encoding/json"
     "errors"
+    "fmt"
+    "io"
     "net/http"
     "os"
+    "strconv"
+    "strings"
     "time"
+
+    "go.opentelemetry.io/otel"
+    "go.opentelemetry.io/otel/attribute"
+    "go.opentelemetry.io/otel/propagation"
+    "kingbrain/insight/pkg/i18n"
+    "kingbrain/insight/pkg/tracing"
 )
 
+// DefaultMaxResults is the match cap given to new Clients (0 disables it);
+// see Search for how it is enforced.
+var DefaultMaxResults = 10000
+
+// DefaultFilters is appended to every search and symbol query issued by new
+// Clients (e.g. the repo:/file: filters of a --scope profile).
+var DefaultFilters string
+
+// DefaultHook is applied to every file match of new Clients' searches before it
+// reaches the caller (e.g. a --hook script); it may rewrite the match in place and
+// returns false to drop it.
+var DefaultHook func(fm *FileMatch) (bool, error)
+
 type Client struct {
     primary   string
     fallback  string
     token     string
     httpClient *http.Client
+    maxResults int
+    filters   string
+    hook      func(fm *FileMatch) (bool, error)
+    sample    Sample
+    freshness Freshness
+    indexes   *indexCache
+    withRev   bool
+    unthrottled bool
 }
 
-// New returns a Client that will first try SG_URL, then LOCAL_SG_ENDPOINT.
+// requestTimeout bounds one attempt of a regular GraphQL request, reading the
+// body included. Searches get no such deadline: their results are decoded as
+// they stream in and a large result set can take minutes to read, so they are
+// bounded only by responseHeaderTimeout and the caller's context; so are the
+// whole-repo requests sent through graphQLBulk.
+const requestTimeout = 5 * time.Second
+
+// responseHeaderTimeout bounds the wait for response headers. Sourcegraph only
+// starts answering a search after running it, hence the generous value.
+const responseHeaderTimeout = time.Minute
+
+// transport is shared by all Clients so connections are reused across them.
+var transport = func() *http.Transport {
+    t := http.DefaultTransport.(*http.Transport).Clone()
+    t.ResponseHeaderTimeout = responseHeaderTimeout
+    return t
+}()
+
+// DefaultEndpoint and DefaultToken are used by New when neither SG_URL nor
+// LOCAL_SG_ENDPOINT is set (e.g. the endpoint saved by kb init); SG_TOKEN still
+// takes precedence over DefaultToken.
+var DefaultEndpoint, DefaultToken string
+
+// New returns a Client that will first try SG_URL, then LOCAL_SG_ENDPOINT, and
+// falls back to DefaultEndpoint when neither is set.
 func New() *Client {
+    primary, fallback, token := os.Getenv("SG_URL"), os.Getenv("LOCAL_SG_ENDPOINT"), os.Getenv("SG_TOKEN")
+    if primary == "" && fallback == "" {
+        primary = DefaultEndpoint
+        if token == "" { token = DefaultToken }
+    }
+    return &Client{
+        primary:  primary,
+        fallback: fallback,
+        token:    token,
+        httpClient: &http.Client{ Transport: transport },
+        maxResults: DefaultMaxResults,
+        filters:  DefaultFilters,
+        hook:     DefaultHook,
+        sample:   DefaultSample,
+        freshness: DefaultFreshness,
+        indexes:  &indexCache{m: map[string]*IndexStatus{}},
+    }
+}
+
+// NewInstance returns a Client bound to a single endpoint, without fallback.
+func NewInstance(url, token string) *Client {
     return &Client{
-        primary:  os.Getenv("SG_URL"),
-        fallback: os.Getenv("LOCAL_SG_ENDPOINT"),
-        token:    os.Getenv("SG_TOKEN"),
-        httpClient: &http.Client{ Timeout: 5 * time.Second },
+        primary:  url,
+        token:    token,
+        httpClient: &http.Client{ Transport: transport },
+        maxResults: DefaultMaxResults,
+        filters:  DefaultFilters,
+        hook:     DefaultHook,
+        sample:   DefaultSample,
+        freshness: DefaultFreshness,
+        indexes:  &indexCache{m: map[string]*IndexStatus{}},
+    }
+}
+
+// Uncapped returns a copy of c without the match cap, for queries that only need
+// the match count and would otherwise trip the truncation warning.
+func (c *Client) Uncapped() *Client {
+    cp := *c
+    cp.maxResults = 0
+    return &cp
+}
+
+// WithRevisions returns a copy of c whose searches also fetch each match's
+// revision into FileMatch.Rev (empty for the default branch), so the matched
+// files can be read at the revision that was searched.
+func (c *Client) WithRevisions() *Client {
+    cp := *c
+    cp.withRev = true
+    return &cp
+}
+
+// WithoutThrottling returns a copy of c that neither waits for the shared quota
+// nor retries HTTP 429, so the time a request takes is the instance's alone (for
+// bench). A 429 still pauses the other kb processes and comes back as a
+// *StatusError.
+func (c *Client) WithoutThrottling() *Client {
+    cp := *c
+    cp.unthrottled = true
+    return &cp
+}
+
+// WithToken returns a copy of c that authenticates as token instead, sharing the
+// endpoints, HTTP client and defaults (e.g. for serve's per-user passthrough).
+func (c *Client) WithToken(token string) *Client {
+    cp := *c
+    cp.token = token
+    return &cp
+}
+
+// URL turns a relative Sourcegraph path (e.g. file.url) into an absolute link.
+func (c *Client) URL(path string) string {
+    base := c.primary
+    if base == "" {
+        base = c.fallback
     }
+    return strings.TrimSuffix(base, "/") + path
+}
+
+// scoped appends the client's default filters to a search query. A query with a
+// top-level OR is parenthesized first so the filters apply to every branch.
+func (c *Client) scoped(q string) string {
+    if c.filters == "" {
+        return q
+    }
+    if strings.Contains(strings.ToUpper(q), " OR ") {
+        q = "(" + q + ")"
+    }
+    return q + " " + c.filters
 }
 
 // GraphQL runs the given query+variables, trying primary then fallback.
-func (c *Client) GraphQL(q string, v map[string]any, out any) error {
+// Each call is traced as an "sg.graphql" span; the search query (variable q)
+// is recorded as the sg.query attribute. Each attempt times out after
+// requestTimeout.
+func (c *Client) GraphQL(ctx context.Context, q string, v map[string]any, out any) error {
+    return c.graphQLStream(ctx, q, v, requestTimeout, func(dec *json.Decoder) error { return dec.Decode(out) })
+}
+
+// graphQLBulk is GraphQL without the per-attempt deadline, for whole-repo or
+// count:all requests (symbols, recursive trees, commit searches) whose responses
+// can take far longer than requestTimeout on a large monorepo. Like searches they
+// are bounded only by responseHeaderTimeout and the caller's context.
+func (c *Client) graphQLBulk(ctx context.Context, q string, v map[string]any, out any) error {
+    return c.graphQLStream(ctx, q, v, 0, func(dec *json.Decoder) error { return dec.Decode(out) })
+}
+
+// graphQLStream is GraphQL with the response body handed to decode as a
+// json.Decoder, so large responses can be consumed token by token. A positive
+// timeout bounds each attempt from sending the request to the end of decode;
+// with 0 only responseHeaderTimeout and ctx apply.
+func (c *Client) graphQLStream(ctx context.Context, q string, v map[string]any, timeout time.Duration, decode func(*json.Decoder) error) (err error) {
+    ctx, span := tracing.Start(ctx, "sg.graphql")
+    defer func() { tracing.End(span, err) }()
+    c.checkSchema(q)
+    if s, ok := v["q"].(string); ok {
+        span.SetAttributes(attribute.String("sg.query", s))
+    }
+
     payload := map[string]any{
         "query":     q,
         "variables": v,
@@ -37,40 +201,178 @@ func (c *Client) GraphQL(q string, v map[string]any, out any) error {
         return err
     }
 
-    // helper to do one request
-    doReq := func(url string) (*http.Response, error) {
-        req, err := http.NewRequest("POST", url+"/.api/graphql", bytes.NewReader(body))
+    resp, url, errs := c.do(ctx, timeout, func(ctx context.Context, base string) (*http.Request, error) {
+        req, err := http.NewRequestWithContext(ctx, "POST", base+"/.api/graphql", bytes.NewReader(body))
         if err != nil {
             return nil, err
         }
-        req.Header.Set("Authorization", "token "+c.token)
         req.Header.Set("Content-Type", "application/json")
-        return c.httpClient.Do(req)
+        return req, nil
+    })
+    if resp != nil {
+        defer resp.Body.Close()
+        span.SetAttributes(attribute.String("sg.endpoint", url))
+        return decode(json.NewDecoder(resp.Body))
     }
 
-    // try primary
-    if c.primary != "" {
-        resp, err := doReq(c.primary)
-        if err == nil && resp.StatusCode < 300 {
-            defer resp.Body.Close()
-            return json.NewDecoder(resp.Body).Decode(out)
-        }
-        if resp != nil {
+    switch len(errs) {
+    case 0:
+        return errNoEndpoint()
+    case 1:
+        return i18n.Errorf("GraphQL request failed: %w", errs[0])
+    }
+    return i18n.Errorf("GraphQL request failed on both primary and fallback endpoints: %w", errors.Join(errs...))
+}
+
+// do sends the request built by newReq for base, trying primary then fallback,
+// and returns the first 2xx response with the endpoint that produced it; the
+// caller closes its body. Every attempt first waits for the shared quota, 429
+// responses are retried after Retry-After, and a positive timeout bounds each
+// attempt from the quota wait's end until the body is closed. On failure it
+// returns nil and one error per endpoint tried (none when no endpoint is set).
+func (c *Client) do(ctx context.Context, timeout time.Duration, newReq func(ctx context.Context, base string) (*http.Request, error)) (*http.Response, string, []error) {
+    try := func(url string) (*http.Response, error) {
+        for attempt := 0; ; attempt++ {
+            if !c.unthrottled {
+                if err := takeQuota(ctx, url); err != nil {
+                    return nil, err
+                }
+            }
+            actx, cancel := ctx, context.CancelFunc(func() {})
+            if timeout > 0 {
+                actx, cancel = context.WithTimeout(ctx, timeout)
+            }
+            req, err := newReq(actx, url)
+            if err != nil {
+                cancel()
+                return nil, err
+            }
+            req.Header.Set("Authorization", "token "+c.token)
+            otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+            resp, err := c.httpClient.Do(req)
+            if err != nil {
+                cancel()
+                return nil, err
+            }
+            resp.Body = cancelOnClose{resp.Body, cancel}
+            if resp.StatusCode != http.StatusTooManyRequests || attempt >= maxRetries {
+                return resp, nil
+            }
+            wait := retryAfter(resp.Header.Get("Retry-After"), attempt)
+            pauseQuota(url, wait)
+            if c.unthrottled {
+                return resp, nil
+            }
             resp.Body.Close()
+            fmt.Fprint(os.Stderr, i18n.Sprintf("%s 限流 (HTTP 429)，%s 后重试 (%d/%d)\n", url, wait, attempt+1, maxRetries))
+            select {
+            case <-ctx.Done():
+                return nil, ctx.Err()
+            case <-time.After(wait):
+            }
         }
     }
 
-    // fallback
-    if c.fallback != "" {
-        resp, err := doReq(c.fallback)
-        if err == nil && resp.StatusCode < 300 {
-            defer resp.Body.Close()
-            return json.NewDecoder(resp.Body).Decode(out)
+    // every failure is kept for the final error
+    var errs []error
+    for _, url := range []string{c.primary, c.fallback} {
+        if url == "" {
+            continue
+        }
+        resp, err := try(url)
+        if err != nil {
+            errs = append(errs, fmt.Errorf("%s: %w", url, err))
+            continue
         }
-        if resp != nil {
+        if resp.StatusCode >= 300 {
+            errs = append(errs, newStatusError(url, resp))
             resp.Body.Close()
+            continue
         }
+        return resp, url, nil
+    }
+    return nil, "", errs
+}
+
+// requestError folds the failures returned by do into one error, keeping a
+// lone endpoint's error (e.g. a *StatusError) as is.
+func requestError(errs []error) error {
+    switch len(errs) {
+    case 0:
+        return errNoEndpoint()
+    case 1:
+        return errs[0]
+    }
+    return errors.Join(errs...)
+}
+
+func errNoEndpoint() error {
+    return errors.New(i18n.T("no Sourcegraph endpoint configured: run kb init, or set SG_URL or LOCAL_SG_ENDPOINT"))
+}
+
+// cancelOnClose releases an attempt's deadline once its body has been closed.
+type cancelOnClose struct {
+    io.ReadCloser
+    cancel context.CancelFunc
+}
+
+func (b cancelOnClose) Close() error {
+    err := b.ReadCloser.Close()
+    b.cancel()
+    return err
+}
+
+// maxRetries is how many times a rate-limited (429) request is retried.
+const maxRetries = 3
+
+// maxRetryWait caps how long a single Retry-After is honored.
+const maxRetryWait = time.Minute
+
+// retryAfter parses a Retry-After header (seconds or an HTTP date); without
+// one it backs off exponentially from one second.
+func retryAfter(h string, attempt int) time.Duration {
+    wait := time.Second << attempt
+    if n, err := strconv.Atoi(strings.TrimSpace(h)); err == nil && n >= 0 {
+        wait = time.Duration(n) * time.Second
+    } else if t, err := http.ParseTime(h); err == nil {
+        wait = time.Until(t)
+    }
+    return min(max(wait, 0), maxRetryWait)
+}
+
+// StatusError is a non-2xx response; Body holds the start of the server's
+// error message, and Error appends advice for common statuses.
+type StatusError struct {
+    Endpoint   string
+    StatusCode int
+    Body       string
+}
+
+func newStatusError(url string, resp *http.Response) *StatusError {
+    b, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+    return &StatusError{Endpoint: url, StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(b))}
+}
+
+func (e *StatusError) Error() string {
+    msg := fmt.Sprintf("HTTP %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+    if e.Body != "" {
+        msg += ": " + e.Body
+    }
+    if h := e.Hint(); h != "" {
+        msg = i18n.Sprintf("%s（%s）", msg, h)
     }
+    return e.Endpoint + ": " + msg
+}
 
-    return errors.New("GraphQL request failed on both primary and fallback endpoints")
+// Hint returns advice for the status code, or "" when there is none.
+func (e *StatusError) Hint() string {
+    switch e.StatusCode {
+    case http.StatusUnauthorized, http.StatusForbidden:
+        return i18n.T("请检查 SG_TOKEN 或实例配置的 token 是否有效、是否有访问权限")
+    case http.StatusTooManyRequests:
+        return i18n.T("被限流，重试次数已用完，请稍后再试或降低并发（-j）")
+    case http.StatusBadGateway, http.StatusGatewayTimeout:
+        return i18n.T("服务端处理超时，可尝试缩小查询范围：加 repo:/file:/lang: 过滤器或降低 count:")
+    }
+    return ""
 }
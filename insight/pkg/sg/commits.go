@@ -0,0 +1,243 @@
+package sg
+
+import (
+    "context"
+    "time"
+
+    "kingbrain/insight/pkg/i18n"
+)
+
+const commitsQuery = `
+query ($repo: String!, $rev: String!, $first: Int!, $path: String, $after: String, $cursor: String) {
+  repository(name: $repo) {
+    commit(rev: $rev) {
+      ancestors(first: $first, path: $path, after: $after, afterCursor: $cursor) {
+        nodes {
+          oid subject body url
+          parents { oid }
+          author { person { name email } date }
+        }
+        pageInfo { endCursor hasNextPage }
+      }
+    }
+  }
+}
+`
+
+// Commit 是提交历史中的一个提交；Repo 只在提交搜索的结果中填写
+type Commit struct {
+    Repo    string    `json:"repo,omitempty"`
+    OID     string    `json:"oid"`
+    Subject string    `json:"subject"`
+    Body    string    `json:"body,omitempty"`
+    Author  string    `json:"author"`
+    Email   string    `json:"email"`
+    Date    time.Time `json:"date"`
+    URL     string    `json:"url,omitempty"`
+    Merge   bool      `json:"merge,omitempty"`
+}
+
+// commitNode 是查询返回的一个提交（commitsQuery、commitSearchQuery、comparisonQuery 共用的字段）
+type commitNode struct {
+    Repository *struct {
+        Name string `json:"name"`
+    } `json:"repository"`
+    OID     string `json:"oid"`
+    Subject string `json:"subject"`
+    Body    string `json:"body"`
+    URL     string `json:"url"`
+    Parents []struct {
+        OID string `json:"oid"`
+    } `json:"parents"`
+    Author struct {
+        Person struct {
+            Name  string `json:"name"`
+            Email string `json:"email"`
+        } `json:"person"`
+        Date time.Time `json:"date"`
+    } `json:"author"`
+}
+
+func (n *commitNode) commit() Commit {
+    cm := Commit{
+        OID:     n.OID,
+        Subject: n.Subject,
+        Body:    n.Body,
+        Author:  n.Author.Person.Name,
+        Email:   n.Author.Person.Email,
+        Date:    n.Author.Date,
+        URL:     n.URL,
+        Merge:   len(n.Parents) > 1,
+    }
+    if n.Repository != nil {
+        cm.Repo = n.Repository.Name
+    }
+    return cm
+}
+
+// commitPage 是每次请求的提交数
+const commitPage = 500
+
+// Commits 从 rev（为空时取 HEAD）往回列出提交，最新的在前；path 非空时只列修改过该路径的提交，
+// since 非零时只列该时间之后的提交，最多 limit 个（0 表示不限）
+func (c *Client) Commits(ctx context.Context, repo, rev, path string, since time.Time, limit int) ([]Commit, error) {
+    if rev == "" {
+        rev = "HEAD"
+    }
+    vars := map[string]any{"repo": repo, "rev": rev, "path": nil, "after": nil, "cursor": nil}
+    if path != "" {
+        vars["path"] = path
+    }
+    if !since.IsZero() {
+        vars["after"] = since.UTC().Format(time.RFC3339)
+    }
+    var commits []Commit
+    for {
+        first := commitPage
+        if limit > 0 {
+            first = min(first, limit-len(commits))
+        }
+        vars["first"] = first
+        var out struct {
+            Data struct {
+                Repository *struct {
+                    Commit *struct {
+                        Ancestors struct {
+                            Nodes    []commitNode `json:"nodes"`
+                            PageInfo struct {
+                                EndCursor   string `json:"endCursor"`
+                                HasNextPage bool   `json:"hasNextPage"`
+                            } `json:"pageInfo"`
+                        } `json:"ancestors"`
+                    } `json:"commit"`
+                } `json:"repository"`
+            } `json:"data"`
+            Errors []gqlError `json:"errors"`
+        }
+        if err := c.GraphQL(ctx, commitsQuery, vars, &out); err != nil {
+            return nil, err
+        }
+        if err := joinErrors(out.Errors); err != nil {
+            return nil, err
+        }
+        switch r := out.Data.Repository; {
+        case r == nil:
+            return nil, i18n.Errorf("仓库不存在：%s", repo)
+        case r.Commit == nil:
+            return nil, i18n.Errorf("%s 中找不到 revision %s", repo, rev)
+        }
+        a := out.Data.Repository.Commit.Ancestors
+        for _, n := range a.Nodes {
+            commits = append(commits, n.commit())
+        }
+        if !a.PageInfo.HasNextPage || a.PageInfo.EndCursor == "" || (limit > 0 && len(commits) >= limit) {
+            return commits, nil
+        }
+        vars["cursor"] = a.PageInfo.EndCursor
+    }
+}
+
+const commitSearchQuery = `
+query ($q: String!) {
+  search(version: V3, query: $q, patternType: regexp) {
+    results {
+      limitHit
+      results {
+        ... on CommitSearchResult {
+          commit {
+            repository { name }
+            oid subject body url
+            parents { oid }
+            author { person { name email } date }
+          }
+        }
+      }
+    }
+  }
+}
+`
+
+// SearchCommits 执行提交搜索（查询中应含 type:commit），按服务端的顺序返回命中的提交；
+// limitHit 表示结果被服务端截断。不注入 count:，需要全部结果时在查询里写 count:all
+func (c *Client) SearchCommits(ctx context.Context, q string) (commits []Commit, limitHit bool, err error) {
+    var out struct {
+        Data struct {
+            Search struct {
+                Results struct {
+                    LimitHit bool `json:"limitHit"`
+                    Results  []struct {
+                        Commit *commitNode `json:"commit"`
+                    } `json:"results"`
+                } `json:"results"`
+            } `json:"search"`
+        } `json:"data"`
+        Errors []gqlError `json:"errors"`
+    }
+    if err := c.graphQLBulk(ctx, commitSearchQuery, map[string]any{"q": q}, &out); err != nil {
+        return nil, false, err
+    }
+    if err := joinErrors(out.Errors); err != nil {
+        return nil, false, err
+    }
+    r := out.Data.Search.Results
+    for _, n := range r.Results {
+        if n.Commit == nil {
+            continue
+        }
+        commits = append(commits, n.Commit.commit())
+    }
+    return commits, r.LimitHit, nil
+}
+
+const comparisonQuery = `
+query ($repo: String!, $base: String!, $head: String!, $first: Int!) {
+  repository(name: $repo) {
+    comparison(base: $base, head: $head) {
+      commits(first: $first) {
+        nodes {
+          oid subject body url
+          parents { oid }
+          author { person { name email } date }
+        }
+        pageInfo { hasNextPage }
+      }
+    }
+  }
+}
+`
+
+// Comparison 按提交的祖先关系列出 (base, head] 之间的提交，即 head 可达而 base 不可达的提交
+// （git log base..head），最新的在前，从旁支合入的更早提交也包括在内；最多 limit 个，
+// 超出时 truncated 为 true。比较大的范围时服务端可能很慢，不受单次请求的超时限制
+func (c *Client) Comparison(ctx context.Context, repo, base, head string, limit int) (commits []Commit, truncated bool, err error) {
+    vars := map[string]any{"repo": repo, "base": base, "head": head, "first": limit}
+    var out struct {
+        Data struct {
+            Repository *struct {
+                Comparison struct {
+                    Commits struct {
+                        Nodes    []commitNode `json:"nodes"`
+                        PageInfo struct {
+                            HasNextPage bool `json:"hasNextPage"`
+                        } `json:"pageInfo"`
+                    } `json:"commits"`
+                } `json:"comparison"`
+            } `json:"repository"`
+        } `json:"data"`
+        Errors []gqlError `json:"errors"`
+    }
+    if err := c.graphQLBulk(ctx, comparisonQuery, vars, &out); err != nil {
+        return nil, false, err
+    }
+    if err := joinErrors(out.Errors); err != nil {
+        return nil, false, err
+    }
+    r := out.Data.Repository
+    if r == nil {
+        return nil, false, i18n.Errorf("仓库不存在：%s", repo)
+    }
+    for _, n := range r.Comparison.Commits.Nodes {
+        commits = append(commits, n.commit())
+    }
+    return commits, r.Comparison.Commits.PageInfo.HasNextPage, nil
+}
@@ -0,0 +1,124 @@
+package sg
+
+import (
+    "context"
+    "fmt"
+    "strings"
+    "sync"
+
+    "kingbrain/insight/pkg/i18n"
+)
+
+// FileSpec 标识一个要读取的文件；Rev 为空时取 HEAD
+type FileSpec struct {
+    Repo string
+    Rev  string
+    Path string
+}
+
+// FileResult 是 GetFiles 中一个文件的读取结果
+type FileResult struct {
+    FileSpec
+    Content string
+    Err     error
+}
+
+var (
+    // FilesPerRequest 是 GetFiles 在一个 GraphQL 请求里用别名合并读取的文件数
+    FilesPerRequest = 16
+    // FetchParallel 是 GetFiles 同时在途的请求数
+    FetchParallel = 4
+)
+
+// GetFiles 批量读取文件：每 FilesPerRequest 个文件合并成一个 GraphQL 请求，
+// 最多 FetchParallel 个请求并发；结果顺序与 specs 一致，单个文件失败记在对应的 Err 中
+func (c *Client) GetFiles(ctx context.Context, specs []FileSpec) []FileResult {
+    return c.GetFilesFunc(ctx, specs, nil)
+}
+
+// GetFilesFunc 与 GetFiles 相同，每个文件读完后（从 worker goroutine 中）调用 done，
+// 供调用方更新进度；done 可为 nil
+func (c *Client) GetFilesFunc(ctx context.Context, specs []FileSpec, done func(FileResult)) []FileResult {
+    out := make([]FileResult, len(specs))
+    for i, s := range specs {
+        if s.Rev == "" {
+            s.Rev = "HEAD"
+        }
+        out[i].FileSpec = s
+    }
+    per := max(FilesPerRequest, 1)
+    batches := make(chan []int)
+    var wg sync.WaitGroup
+    for w := 0; w < max(FetchParallel, 1); w++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            for idx := range batches {
+                c.fetchBatch(ctx, out, idx)
+                if done != nil {
+                    for _, i := range idx {
+                        done(out[i])
+                    }
+                }
+            }
+        }()
+    }
+    for start := 0; start < len(specs); start += per {
+        idx := make([]int, 0, per)
+        for i := start; i < min(start+per, len(specs)); i++ {
+            idx = append(idx, i)
+        }
+        batches <- idx
+    }
+    close(batches)
+    wg.Wait()
+    return out
+}
+
+// fetchBatch 用别名 f0、f1…… 在一个查询里读取 out[idx...] 的内容
+func (c *Client) fetchBatch(ctx context.Context, out []FileResult, idx []int) {
+    var params, fields []string
+    vars := map[string]any{}
+    for n, i := range idx {
+        params = append(params, fmt.Sprintf("$r%d: String!, $v%d: String!, $p%d: String!", n, n, n))
+        fields = append(fields, fmt.Sprintf("  f%d: repository(name: $r%d) { commit(rev: $v%d) { blob(path: $p%d) { content } } }", n, n, n, n))
+        s := out[i].FileSpec
+        vars[fmt.Sprintf("r%d", n)], vars[fmt.Sprintf("v%d", n)], vars[fmt.Sprintf("p%d", n)] = s.Repo, s.Rev, s.Path
+    }
+    q := "query (" + strings.Join(params, ", ") + ") {\n" + strings.Join(fields, "\n") + "\n}"
+
+    var resp struct {
+        Data map[string]*struct {
+            Commit *struct {
+                Blob *struct {
+                    Content string `json:"content"`
+                } `json:"blob"`
+            } `json:"commit"`
+        } `json:"data"`
+        Errors []gqlError `json:"errors"`
+    }
+    err := c.GraphQL(ctx, q, vars, &resp)
+    if err == nil && resp.Data == nil {
+        err = joinErrors(resp.Errors)
+        if err == nil {
+            err = i18n.Errorf("响应中没有 data")
+        }
+    }
+    for n, i := range idx {
+        s := &out[i]
+        if err != nil {
+            s.Err = err
+            continue
+        }
+        switch r := resp.Data[fmt.Sprintf("f%d", n)]; {
+        case r == nil:
+            s.Err = i18n.Errorf("仓库不存在：%s", s.Repo)
+        case r.Commit == nil:
+            s.Err = i18n.Errorf("%s 中找不到 revision %s", s.Repo, s.Rev)
+        case r.Commit.Blob == nil:
+            s.Err = i18n.Errorf("%s@%s 中找不到文件 %s", s.Repo, s.Rev, s.Path)
+        default:
+            s.Content = r.Commit.Blob.Content
+        }
+    }
+}
@@ -0,0 +1,284 @@
+package sg
+
+import (
+    "context"
+    "fmt"
+    "os"
+    "sort"
+    "strings"
+    "sync"
+    "time"
+
+    "kingbrain/insight/pkg/i18n"
+)
+
+// indexStatusFields 是批量查询中每个仓库（别名 r0、r1……）要取的字段
+const indexStatusFields = `defaultBranch { displayName }
+    textSearchIndex {
+      status { updatedAt }
+      refs {
+        ref { displayName }
+        indexed current
+        indexedCommit { oid commit { committer { date } } }
+      }
+    }`
+
+// indexStatusBatch 是一个 GraphQL 请求里用别名合并查询的仓库数
+const indexStatusBatch = 50
+
+// indexStatusQuery 返回一次查询 n 个仓库索引状态的请求，仓库名依次为变量 $r0、$r1……
+func indexStatusQuery(n int) string {
+    var params, fields []string
+    for i := range n {
+        params = append(params, fmt.Sprintf("$r%d: String!", i))
+        fields = append(fields, fmt.Sprintf("  r%d: repository(name: $r%d) {\n    %s\n  }", i, i, indexStatusFields))
+    }
+    return "query (" + strings.Join(params, ", ") + ") {\n" + strings.Join(fields, "\n") + "\n}"
+}
+
+// IndexStatus 是仓库某个分支（默认为默认分支）的搜索索引状态：Indexed 为 false 时搜索直接读取最新代码（不经过索引）；
+// Current 为 false 时索引落后于分支，匹配来自 Commit（提交于 CommitDate）的快照，索引最后更新于 UpdatedAt
+type IndexStatus struct {
+    Branch     string    `json:"branch,omitempty"`
+    Indexed    bool      `json:"indexed"`
+    Current    bool      `json:"current"`
+    Commit     string    `json:"commit,omitempty"`
+    CommitDate time.Time `json:"commitDate,omitzero"`
+    UpdatedAt  time.Time `json:"updatedAt,omitzero"`
+    Stale      bool      `json:"stale,omitempty"`
+}
+
+// Lag 返回索引落后的时长：索引是最新的或仓库没有索引时为 0，否则为距索引最后更新的时间
+func (s *IndexStatus) Lag(now time.Time) time.Duration {
+    if !s.Indexed || s.Current || s.UpdatedAt.IsZero() {
+        return 0
+    }
+    return now.Sub(s.UpdatedAt)
+}
+
+// Freshness 控制搜索结果的索引新鲜度标注：Annotate 时给每个文件匹配带上仓库的 IndexStatus；
+// StaleAfter 大于 0 时把落后超过它的索引标为 Stale，并在搜索结束时在 stderr 列出这些仓库
+type Freshness struct {
+    Annotate   bool
+    StaleAfter time.Duration
+}
+
+// DefaultFreshness 是新 Client 的索引新鲜度设置（如 --index-age、--warn-stale）
+var DefaultFreshness Freshness
+
+// Active 报告是否需要查询索引状态
+func (f Freshness) Active() bool { return f.Annotate || f.StaleAfter > 0 }
+
+// indexCache 缓存各仓库各 revision 的索引状态（键为 仓库@revision），由同一 Client 的副本共享
+type indexCache struct {
+    mu sync.Mutex
+    m  map[string]*IndexStatus
+}
+
+// indexData 是一个仓库的索引状态查询结果
+type indexData struct {
+    DefaultBranch *struct {
+        DisplayName string `json:"displayName"`
+    } `json:"defaultBranch"`
+    TextSearchIndex *struct {
+        Status *struct {
+            UpdatedAt time.Time `json:"updatedAt"`
+        } `json:"status"`
+        Refs []struct {
+            Ref struct {
+                DisplayName string `json:"displayName"`
+            } `json:"ref"`
+            Indexed       bool `json:"indexed"`
+            Current       bool `json:"current"`
+            IndexedCommit *struct {
+                OID    string `json:"oid"`
+                Commit *struct {
+                    Committer *struct {
+                        Date time.Time `json:"date"`
+                    } `json:"committer"`
+                } `json:"commit"`
+            } `json:"indexedCommit"`
+        } `json:"refs"`
+    } `json:"textSearchIndex"`
+}
+
+// status 返回 rev（为空或 HEAD 时为默认分支）的索引状态；rev 不是被索引的分支时，
+// 搜索直接读取该 revision，Indexed 为 false
+func (d *indexData) status(rev string) *IndexStatus {
+    s := &IndexStatus{Branch: rev}
+    if d.DefaultBranch != nil && (rev == "" || rev == "HEAD") {
+        s.Branch = d.DefaultBranch.DisplayName
+    }
+    idx := d.TextSearchIndex
+    if idx == nil {
+        return s
+    }
+    for _, rf := range idx.Refs {
+        if rf.Ref.DisplayName != s.Branch {
+            continue
+        }
+        s.Indexed, s.Current = rf.Indexed, rf.Current
+        if ic := rf.IndexedCommit; ic != nil {
+            s.Commit = ic.OID
+            if ic.Commit != nil && ic.Commit.Committer != nil {
+                s.CommitDate = ic.Commit.Committer.Date
+            }
+        }
+        if idx.Status != nil {
+            s.UpdatedAt = idx.Status.UpdatedAt
+        }
+        break
+    }
+    return s
+}
+
+// repoRev 是一个仓库的某个 revision；Rev 为空表示默认分支
+type repoRev struct {
+    Repo, Rev string
+}
+
+// indexStatuses 返回各 repoRev 的索引状态，已缓存的不再查询，其余按仓库去重后
+// 每 indexStatusBatch 个合并成一个请求；查询失败的仓库在 stderr 告警，状态为 nil（只影响标注）
+func (c *Client) indexStatuses(ctx context.Context, keys []repoRev) map[repoRev]*IndexStatus {
+    out := map[repoRev]*IndexStatus{}
+    var repos []string
+    need := map[string]bool{}
+    c.indexes.mu.Lock()
+    for _, k := range keys {
+        if s, ok := c.indexes.m[k.Repo+"@"+k.Rev]; ok {
+            out[k] = s
+        } else if !need[k.Repo] {
+            need[k.Repo] = true
+            repos = append(repos, k.Repo)
+        }
+    }
+    c.indexes.mu.Unlock()
+
+    data := map[string]*indexData{}
+    for start := 0; start < len(repos); start += indexStatusBatch {
+        batch := repos[start:min(start+indexStatusBatch, len(repos))]
+        vars := map[string]any{}
+        for n, r := range batch {
+            vars[fmt.Sprintf("r%d", n)] = r
+        }
+        var resp struct {
+            Data   map[string]*indexData `json:"data"`
+            Errors []gqlError            `json:"errors"`
+        }
+        err := c.GraphQL(ctx, indexStatusQuery(len(batch)), vars, &resp)
+        if err == nil && resp.Data == nil {
+            if err = joinErrors(resp.Errors); err == nil {
+                err = i18n.Errorf("响应中没有 data")
+            }
+        }
+        for n, r := range batch {
+            d := resp.Data[fmt.Sprintf("r%d", n)]
+            switch {
+            case err != nil:
+                fmt.Fprint(os.Stderr, i18n.Sprintf("警告: 查询 %s 的索引状态失败: %v\n", r, err))
+            case d == nil:
+                fmt.Fprint(os.Stderr, i18n.Sprintf("警告: 查询 %s 的索引状态失败: %v\n", r, i18n.Errorf("仓库不存在：%s", r)))
+            default:
+                data[r] = d
+            }
+        }
+    }
+
+    now := time.Now()
+    c.indexes.mu.Lock()
+    defer c.indexes.mu.Unlock()
+    for _, k := range keys {
+        if _, ok := out[k]; ok || !need[k.Repo] {
+            continue
+        }
+        var s *IndexStatus
+        if d := data[k.Repo]; d != nil {
+            s = d.status(k.Rev)
+            if c.freshness.StaleAfter > 0 {
+                s.Stale = s.Lag(now) > c.freshness.StaleAfter
+            }
+        }
+        c.indexes.m[k.Repo+"@"+k.Rev] = s
+        out[k] = s
+    }
+    return out
+}
+
+// searchAnnotated 是开启索引新鲜度标注时的 SearchEach：先收齐全部结果（搜索响应照常边读边解码，
+// 不为查询索引状态而停下），再按 仓库+匹配所在的 revision 批量查询索引状态，标注后依次交给 fn
+func (c *Client) searchAnnotated(ctx context.Context, q, patternType string, fn func(res *SearchResults, fm FileMatch) error) (*SearchResults, error) {
+    cp := *c
+    cp.freshness, cp.withRev = Freshness{}, true
+    var matches []FileMatch
+    res, err := cp.SearchEach(ctx, q, patternType, func(_ *SearchResults, fm FileMatch) error {
+        matches = append(matches, fm)
+        return nil
+    })
+    if err != nil {
+        return nil, err
+    }
+    keys := make([]repoRev, 0, len(matches))
+    for _, fm := range matches {
+        keys = append(keys, repoRev{fm.Repository.Name, fm.Rev})
+    }
+    statuses := c.indexStatuses(ctx, keys)
+    var stale staleReport
+    for _, fm := range matches {
+        fm.Index = statuses[repoRev{fm.Repository.Name, fm.Rev}]
+        stale.add(fm.Repository.Name, fm.Index, max(len(fm.LineMatches), 1))
+        if err := fn(res, fm); err != nil {
+            return nil, err
+        }
+    }
+    stale.print(c.freshness.StaleAfter)
+    return res, nil
+}
+
+// staleReport 统计一次搜索中来自过期索引的匹配，搜索结束时打印
+type staleReport struct {
+    matches int
+    repos   map[string]*IndexStatus
+}
+
+func (r *staleReport) add(repo string, s *IndexStatus, n int) {
+    if s == nil || !s.Stale {
+        return
+    }
+    if r.repos == nil {
+        r.repos = map[string]*IndexStatus{}
+    }
+    r.matches += n
+    r.repos[repo] = s
+}
+
+func (r *staleReport) print(threshold time.Duration) {
+    if r.matches == 0 {
+        return
+    }
+    now := time.Now()
+    names := make([]string, 0, len(r.repos))
+    for name := range r.repos {
+        names = append(names, name)
+    }
+    sort.Slice(names, func(i, j int) bool { return r.repos[names[i]].Lag(now) > r.repos[names[j]].Lag(now) })
+    const shown = 5
+    list := make([]string, 0, shown)
+    for _, name := range names[:min(len(names), shown)] {
+        list = append(list, i18n.Sprintf("%s（%s前）", name, FormatAge(r.repos[name].Lag(now))))
+    }
+    if len(names) > shown {
+        list = append(list, i18n.Sprintf("等 %d 个", len(names)))
+    }
+    fmt.Fprint(os.Stderr, i18n.Sprintf("警告: %d 个匹配来自索引已超过 %s未更新的仓库，结果可能与最新代码不符：%s\n", r.matches, FormatAge(threshold), strings.Join(list, i18n.T("，"))))
+}
+
+// FormatAge 把时长写成 "3 天"、"5 小时"、"12 分钟" 这样的粗略形式
+func FormatAge(d time.Duration) string {
+    switch {
+    case d >= 48*time.Hour:
+        return i18n.Sprintf("%d 天", int(d/(24*time.Hour)))
+    case d >= 2*time.Hour:
+        return i18n.Sprintf("%d 小时", int(d/time.Hour))
+    }
+    return i18n.Sprintf("%d 分钟", int(d/time.Minute))
+}
@@ -0,0 +1,77 @@
+package sg
+
+import (
+    "context"
+    "fmt"
+    "os"
+    "strings"
+    "sync"
+    "time"
+
+    "kingbrain/insight/pkg/i18n"
+    "kingbrain/insight/pkg/quota"
+)
+
+// Keyring 为非空时，所有 Client 在发出每个请求前从中取令牌，收到 429 时记下暂停，
+// 使同时运行的多个 kb 进程共同遵守实例的限速；为 nil 时只按 Retry-After 各自重试
+var Keyring *quota.Keyring
+
+// RateLimits 按实例 URL 给出限速；没有单独配置的实例使用 DefaultRateLimit
+var (
+    RateLimits       map[string]quota.Limit
+    DefaultRateLimit quota.Limit
+)
+
+// waitNotified 记下已经提示过在等待配额的实例，每个实例只提示一次
+var waitNotified sync.Map
+
+// rateLimit 返回实例的限速，URL 末尾的 / 不影响匹配
+func rateLimit(url string) quota.Limit {
+    if l, ok := RateLimits[strings.TrimSuffix(url, "/")]; ok {
+        return l
+    }
+    return DefaultRateLimit
+}
+
+// takeQuota 在发出请求前等待共享配额；keyring 读写失败时只提示一次并放行
+func takeQuota(ctx context.Context, url string) error {
+    if Keyring == nil {
+        return nil
+    }
+    err := Keyring.Take(ctx, url, rateLimit(url), func(d time.Duration, paused bool) {
+        if d < 2*time.Second {
+            return
+        }
+        if _, done := waitNotified.LoadOrStore(url, true); done {
+            return
+        }
+        if paused {
+            fmt.Fprint(os.Stderr, i18n.Sprintf("%s 刚返回限流，所有 kb 进程暂停 %s\n", url, d.Round(time.Second)))
+        } else {
+            fmt.Fprint(os.Stderr, i18n.Sprintf("%s 的共享配额已用完，等待 %s（见 kb quota）\n", url, d.Round(time.Second)))
+        }
+    })
+    if err != nil && ctx.Err() == nil {
+        quotaFailed(err)
+        return nil
+    }
+    return err
+}
+
+// pauseQuota 把实例的 Retry-After 记入 keyring，其他进程也随之暂停
+func pauseQuota(url string, wait time.Duration) {
+    if Keyring == nil {
+        return
+    }
+    if err := Keyring.Pause(url, rateLimit(url), time.Now().Add(wait)); err != nil {
+        quotaFailed(err)
+    }
+}
+
+var quotaWarned sync.Once
+
+func quotaFailed(err error) {
+    quotaWarned.Do(func() {
+        fmt.Fprint(os.Stderr, i18n.Sprintf("警告: 共享配额不可用，本进程不再与其他进程协调限速：%v\n", err))
+    })
+}
@@ -0,0 +1,354 @@
+package sg
+
+import (
+    "context"
+    "time"
+
+    "kingbrain/insight/pkg/i18n"
+)
+
+const branchesQuery = `
+query ($name: String!, $first: Int!) {
+  repository(name: $name) {
+    branches(first: $first, orderBy: AUTHORED_OR_COMMITTED_AT) {
+      nodes { displayName }
+    }
+  }
+}
+`
+
+// Branches 列出仓库的分支（按最近提交排序，最多 limit 个）
+func (c *Client) Branches(ctx context.Context, repo string, limit int) ([]string, error) {
+    var out struct {
+        Data struct {
+            Repository *struct {
+                Branches struct {
+                    Nodes []struct {
+                        DisplayName string `json:"displayName"`
+                    } `json:"nodes"`
+                } `json:"branches"`
+            } `json:"repository"`
+        } `json:"data"`
+        Errors []gqlError `json:"errors"`
+    }
+    if err := c.GraphQL(ctx, branchesQuery, map[string]any{"name": repo, "first": limit}, &out); err != nil {
+        return nil, err
+    }
+    if err := joinErrors(out.Errors); err != nil {
+        return nil, err
+    }
+    if out.Data.Repository == nil {
+        return nil, i18n.Errorf("仓库不存在：%s", repo)
+    }
+    var names []string
+    for _, n := range out.Data.Repository.Branches.Nodes {
+        names = append(names, n.DisplayName)
+    }
+    return names, nil
+}
+
+const tagsQuery = `
+query ($name: String!, $first: Int!, $query: String) {
+  repository(name: $name) {
+    gitRefs(type: GIT_TAG, first: $first, query: $query, orderBy: AUTHORED_OR_COMMITTED_AT) {
+      nodes { displayName target { commit { committer { date } } } }
+    }
+  }
+}
+`
+
+// Tag 是仓库的一个标签；Date 为标签指向的提交时间
+type Tag struct {
+    Name string    `json:"name"`
+    Date time.Time `json:"date"`
+}
+
+// Tags 列出仓库中名字包含 query 的标签（按最近提交排序，最多 limit 个）
+func (c *Client) Tags(ctx context.Context, repo, query string, limit int) ([]Tag, error) {
+    var out struct {
+        Data struct {
+            Repository *struct {
+                GitRefs struct {
+                    Nodes []struct {
+                        DisplayName string `json:"displayName"`
+                        Target      struct {
+                            Commit *struct {
+                                Committer *struct {
+                                    Date time.Time `json:"date"`
+                                } `json:"committer"`
+                            } `json:"commit"`
+                        } `json:"target"`
+                    } `json:"nodes"`
+                } `json:"gitRefs"`
+            } `json:"repository"`
+        } `json:"data"`
+        Errors []gqlError `json:"errors"`
+    }
+    if err := c.GraphQL(ctx, tagsQuery, map[string]any{"name": repo, "first": limit, "query": query}, &out); err != nil {
+        return nil, err
+    }
+    if err := joinErrors(out.Errors); err != nil {
+        return nil, err
+    }
+    if out.Data.Repository == nil {
+        return nil, i18n.Errorf("仓库不存在：%s", repo)
+    }
+    var tags []Tag
+    for _, n := range out.Data.Repository.GitRefs.Nodes {
+        t := Tag{Name: n.DisplayName}
+        if n.Target.Commit != nil && n.Target.Commit.Committer != nil {
+            t.Date = n.Target.Commit.Committer.Date
+        }
+        tags = append(tags, t)
+    }
+    return tags, nil
+}
+
+const blobQuery = `
+query ($repo: String!, $rev: String!, $path: String!) {
+  repository(name: $repo) {
+    commit(rev: $rev) {
+      blob(path: $path) { content }
+    }
+  }
+}
+`
+
+// FileContent 读取仓库在某个 revision 下的文件内容；rev 为空时取 HEAD
+func (c *Client) FileContent(ctx context.Context, repo, rev, path string) (string, error) {
+    if rev == "" {
+        rev = "HEAD"
+    }
+    var out struct {
+        Data struct {
+            Repository *struct {
+                Commit *struct {
+                    Blob *struct {
+                        Content string `json:"content"`
+                    } `json:"blob"`
+                } `json:"commit"`
+            } `json:"repository"`
+        } `json:"data"`
+        Errors []gqlError `json:"errors"`
+    }
+    if err := c.GraphQL(ctx, blobQuery, map[string]any{"repo": repo, "rev": rev, "path": path}, &out); err != nil {
+        return "", err
+    }
+    if err := joinErrors(out.Errors); err != nil {
+        return "", err
+    }
+    switch r := out.Data.Repository; {
+    case r == nil:
+        return "", i18n.Errorf("仓库不存在：%s", repo)
+    case r.Commit == nil:
+        return "", i18n.Errorf("%s 中找不到 revision %s", repo, rev)
+    case r.Commit.Blob == nil:
+        return "", i18n.Errorf("%s@%s 中找不到文件 %s", repo, rev, path)
+    default:
+        return r.Commit.Blob.Content, nil
+    }
+}
+
+const repositoriesQuery = `
+query ($first: Int!, $after: String) {
+  repositories(first: $first, after: $after) {
+    nodes {
+      name url language isArchived isFork stars
+      defaultBranch { displayName }
+    }
+    pageInfo { endCursor hasNextPage }
+  }
+}
+`
+
+// RepoInfo 是仓库列表中的一项
+type RepoInfo struct {
+    Name          string `json:"name"`
+    URL           string `json:"url"`
+    Language      string `json:"language,omitempty"`
+    DefaultBranch string `json:"defaultBranch,omitempty"`
+    Archived      bool   `json:"archived,omitempty"`
+    Fork          bool   `json:"fork,omitempty"`
+    Stars         int    `json:"stars,omitempty"`
+}
+
+// Repositories 分页列出实例上的仓库；after 为上一页返回的游标，next 为空表示没有下一页
+func (c *Client) Repositories(ctx context.Context, first int, after string) (repos []RepoInfo, next string, err error) {
+    var out struct {
+        Data struct {
+            Repositories struct {
+                Nodes []struct {
+                    Name          string `json:"name"`
+                    URL           string `json:"url"`
+                    Language      string `json:"language"`
+                    IsArchived    bool   `json:"isArchived"`
+                    IsFork        bool   `json:"isFork"`
+                    Stars         int    `json:"stars"`
+                    DefaultBranch *struct {
+                        DisplayName string `json:"displayName"`
+                    } `json:"defaultBranch"`
+                } `json:"nodes"`
+                PageInfo struct {
+                    EndCursor   string `json:"endCursor"`
+                    HasNextPage bool   `json:"hasNextPage"`
+                } `json:"pageInfo"`
+            } `json:"repositories"`
+        } `json:"data"`
+        Errors []gqlError `json:"errors"`
+    }
+    vars := map[string]any{"first": first, "after": nil}
+    if after != "" {
+        vars["after"] = after
+    }
+    if err := c.GraphQL(ctx, repositoriesQuery, vars, &out); err != nil {
+        return nil, "", err
+    }
+    if err := joinErrors(out.Errors); err != nil {
+        return nil, "", err
+    }
+    for _, n := range out.Data.Repositories.Nodes {
+        r := RepoInfo{Name: n.Name, URL: n.URL, Language: n.Language, Archived: n.IsArchived, Fork: n.IsFork, Stars: n.Stars}
+        if n.DefaultBranch != nil {
+            r.DefaultBranch = n.DefaultBranch.DisplayName
+        }
+        repos = append(repos, r)
+    }
+    if pi := out.Data.Repositories.PageInfo; pi.HasNextPage {
+        next = pi.EndCursor
+    }
+    return repos, next, nil
+}
+
+const treeQuery = `
+query ($repo: String!, $rev: String!, $path: String!, $recursive: Boolean!) {
+  repository(name: $repo) {
+    commit(rev: $rev) {
+      tree(path: $path, recursive: $recursive) {
+        entries { path isDirectory }
+      }
+    }
+  }
+}
+`
+
+// TreeEntry 是目录树中的一项；Path 为相对仓库根的路径
+type TreeEntry struct {
+    Path string `json:"path"`
+    Dir  bool   `json:"isDirectory"`
+}
+
+// Tree 列出仓库在某个 revision 下的所有文件路径（不含目录）；rev 为空时取 HEAD
+func (c *Client) Tree(ctx context.Context, repo, rev string) ([]string, error) {
+    entries, err := c.TreeEntries(ctx, repo, rev, "", true)
+    if err != nil {
+        return nil, err
+    }
+    var paths []string
+    for _, e := range entries {
+        if !e.Dir {
+            paths = append(paths, e.Path)
+        }
+    }
+    return paths, nil
+}
+
+// TreeEntries 列出目录 dir（为空时为仓库根）下的文件与子目录；recursive 为 false 时只取一层，
+// 服务端只需读取这一个目录，适合逐层浏览大仓库。rev 为空时取 HEAD
+func (c *Client) TreeEntries(ctx context.Context, repo, rev, dir string, recursive bool) ([]TreeEntry, error) {
+    if rev == "" {
+        rev = "HEAD"
+    }
+    var out struct {
+        Data struct {
+            Repository *struct {
+                Commit *struct {
+                    Tree *struct {
+                        Entries []TreeEntry `json:"entries"`
+                    } `json:"tree"`
+                } `json:"commit"`
+            } `json:"repository"`
+        } `json:"data"`
+        Errors []gqlError `json:"errors"`
+    }
+    v := map[string]any{"repo": repo, "rev": rev, "path": dir, "recursive": recursive}
+    if err := c.graphQLBulk(ctx, treeQuery, v, &out); err != nil {
+        return nil, err
+    }
+    if err := joinErrors(out.Errors); err != nil {
+        return nil, err
+    }
+    switch r := out.Data.Repository; {
+    case r == nil:
+        return nil, i18n.Errorf("仓库不存在：%s", repo)
+    case r.Commit == nil:
+        return nil, i18n.Errorf("%s 中找不到 revision %s", repo, rev)
+    case r.Commit.Tree == nil && dir != "":
+        return nil, i18n.Errorf("%s@%s 中没有目录 %s", repo, rev, dir)
+    case r.Commit.Tree == nil:
+        return nil, i18n.Errorf("%s 中找不到 revision %s", repo, rev)
+    }
+    return out.Data.Repository.Commit.Tree.Entries, nil
+}
+
+const treeSizesQuery = `
+query ($repo: String!, $rev: String!) {
+  repository(name: $repo) {
+    commit(rev: $rev) {
+      tree(recursive: true) {
+        entries { path isDirectory ... on GitBlob { byteSize binary } }
+      }
+    }
+  }
+}
+`
+
+// TreeFile 是目录树中的一个文件及其大小；Binary 为服务端按内容判断的二进制文件
+type TreeFile struct {
+    Path   string `json:"path"`
+    Size   int64  `json:"size"`
+    Binary bool   `json:"binary,omitempty"`
+}
+
+// TreeSizes 与 Tree 相同，但同时返回每个文件的字节数与是否为二进制；服务端要读取文件内容，比 Tree 慢
+func (c *Client) TreeSizes(ctx context.Context, repo, rev string) ([]TreeFile, error) {
+    if rev == "" {
+        rev = "HEAD"
+    }
+    var out struct {
+        Data struct {
+            Repository *struct {
+                Commit *struct {
+                    Tree *struct {
+                        Entries []struct {
+                            Path        string `json:"path"`
+                            IsDirectory bool   `json:"isDirectory"`
+                            ByteSize    int64  `json:"byteSize"`
+                            Binary      bool   `json:"binary"`
+                        } `json:"entries"`
+                    } `json:"tree"`
+                } `json:"commit"`
+            } `json:"repository"`
+        } `json:"data"`
+        Errors []gqlError `json:"errors"`
+    }
+    if err := c.graphQLBulk(ctx, treeSizesQuery, map[string]any{"repo": repo, "rev": rev}, &out); err != nil {
+        return nil, err
+    }
+    if err := joinErrors(out.Errors); err != nil {
+        return nil, err
+    }
+    switch r := out.Data.Repository; {
+    case r == nil:
+        return nil, i18n.Errorf("仓库不存在：%s", repo)
+    case r.Commit == nil || r.Commit.Tree == nil:
+        return nil, i18n.Errorf("%s 中找不到 revision %s", repo, rev)
+    default:
+        var files []TreeFile
+        for _, e := range r.Commit.Tree.Entries {
+            if !e.IsDirectory {
+                files = append(files, TreeFile{Path: e.Path, Size: e.ByteSize, Binary: e.Binary})
+            }
+        }
+        return files, nil
+    }
+}
@@ -0,0 +1,144 @@
+package sg
+
+import (
+    "context"
+    "fmt"
+    "math/rand/v2"
+    "os"
+    "sort"
+    "strconv"
+    "strings"
+
+    "kingbrain/insight/pkg/i18n"
+)
+
+// Sample 是搜索的随机抽样：N 大于 0 时保留 N 个匹配（蓄水池抽样），否则按 Percent（0-100）
+// 逐个匹配独立抽取。相同的 Seed 与相同的结果顺序得到相同的样本
+type Sample struct {
+    N       int
+    Percent float64
+    Seed    uint64
+}
+
+// DefaultSample 是新 Client 的搜索抽样设置（如 --sample），零值表示不抽样
+var DefaultSample Sample
+
+// Active 报告是否需要抽样
+func (s Sample) Active() bool { return s.N > 0 || s.Percent > 0 }
+
+// ParseSample 解析 N 或 P% 形式的抽样大小
+func ParseSample(v string) (Sample, error) {
+    v = strings.TrimSpace(v)
+    if p, ok := strings.CutSuffix(v, "%"); ok {
+        f, err := strconv.ParseFloat(p, 64)
+        if err != nil || f <= 0 || f > 100 {
+            return Sample{}, i18n.Errorf("--sample 的百分比应在 (0, 100] 之间：%q", v)
+        }
+        return Sample{Percent: f}, nil
+    }
+    n, err := strconv.Atoi(v)
+    if err != nil || n <= 0 {
+        return Sample{}, i18n.Errorf("--sample 应为正整数 N 或百分比 P%%：%q", v)
+    }
+    return Sample{N: n}, nil
+}
+
+// sampleItem 是蓄水池中的一个匹配；seq 为它在结果流中的序号，用于按原顺序还原
+type sampleItem struct {
+    seq  int
+    fm   FileMatch
+    line *LineMatch
+}
+
+// searchSample 取回全部结果（不注入 count 上限，也不按 maxResults 截断），边解码边抽样，
+// 只把样本交给 fn；返回的计数仍是整个结果集的。读完整个结果集可能要几分钟，
+// 与 SearchEach 一样没有整体超时，只受响应头超时与 ctx 约束
+func (c *Client) searchSample(ctx context.Context, q, patternType string, fn func(res *SearchResults, fm FileMatch) error) (*SearchResults, error) {
+    s := c.sample
+    cp := *c
+    cp.sample, cp.maxResults = Sample{}, 0
+    if !countRe.MatchString(q) {
+        q += " count:all"
+    }
+    rng := rand.New(rand.NewPCG(s.Seed, s.Seed^0x9e3779b97f4a7c15))
+
+    var reservoir []sampleItem
+    seen, kept := 0, 0
+    res, err := cp.SearchEach(ctx, q, patternType, func(res *SearchResults, fm FileMatch) error {
+        if s.N == 0 {
+            lines := fm.LineMatches[:0:0]
+            for _, lm := range fm.LineMatches {
+                if rng.Float64()*100 < s.Percent {
+                    lines = append(lines, lm)
+                }
+            }
+            seen += len(fm.LineMatches)
+            if len(fm.LineMatches) == 0 {
+                seen++
+                if rng.Float64()*100 >= s.Percent {
+                    return nil
+                }
+            } else if len(lines) == 0 {
+                return nil
+            }
+            kept += max(len(lines), 1)
+            fm.LineMatches = lines
+            return fn(res, fm)
+        }
+        add := func(it sampleItem) {
+            seen++
+            if len(reservoir) < s.N {
+                reservoir = append(reservoir, it)
+            } else if j := rng.IntN(seen); j < s.N {
+                reservoir[j] = it
+            }
+        }
+        head := fm
+        head.LineMatches = nil
+        if len(fm.LineMatches) == 0 {
+            add(sampleItem{seq: seen, fm: head})
+        }
+        for i := range fm.LineMatches {
+            add(sampleItem{seq: seen, fm: head, line: &fm.LineMatches[i]})
+        }
+        return nil
+    })
+    if err != nil {
+        return nil, err
+    }
+    if s.N > 0 {
+        kept = len(reservoir)
+        if err := emitReservoir(res, reservoir, fn); err != nil {
+            return nil, err
+        }
+    }
+    fmt.Fprint(os.Stderr, i18n.Sprintf("抽样: 从 %d 个匹配中保留 %d 个（--seed %d 可复现）\n", seen, kept, s.Seed))
+    return res, nil
+}
+
+// emitReservoir 按结果流中的原顺序把样本重新组合成文件匹配交给 fn
+func emitReservoir(res *SearchResults, items []sampleItem, fn func(res *SearchResults, fm FileMatch) error) error {
+    sort.Slice(items, func(i, j int) bool { return items[i].seq < items[j].seq })
+    var cur *FileMatch
+    flush := func() error {
+        if cur == nil {
+            return nil
+        }
+        err := fn(res, *cur)
+        cur = nil
+        return err
+    }
+    for _, it := range items {
+        if cur == nil || cur.Repository.Name != it.fm.Repository.Name || cur.File.Path != it.fm.File.Path {
+            if err := flush(); err != nil {
+                return err
+            }
+            fm := it.fm
+            cur = &fm
+        }
+        if it.line != nil {
+            cur.LineMatches = append(cur.LineMatches, *it.line)
+        }
+    }
+    return flush()
+}
@@ -0,0 +1,104 @@
+package sg
+
+import (
+    "context"
+    "fmt"
+    "os"
+    "sync"
+
+    "kingbrain/insight/pkg/gql"
+    "kingbrain/insight/pkg/i18n"
+)
+
+// CheckSchema 为 true 时，Client 在第一次发送每个查询前按实例的缓存 schema（kb schema refresh）
+// 校验，把不存在或已废弃的字段打印到 stderr；没有缓存时不校验
+var CheckSchema bool
+
+var (
+    schemas  sync.Map // endpoint → *gql.Schema，没有缓存时为 nil
+    checked  sync.Map // 已校验过的查询
+    reported sync.Map // 已经提示过的问题，同一问题只提示一次
+)
+
+// Queries 返回客户端内置的查询，供 kb schema check 校验；按文件读取的批量查询与 blob 使用相同的字段
+func Queries() map[string]string {
+    return map[string]string{
+        "search":        fmt.Sprintf(searchQuery, "literal", ""),
+        "branches":      branchesQuery,
+        "tags":          tagsQuery,
+        "blob":          blobQuery,
+        "repositories":  repositoriesQuery,
+        "tree":          treeQuery,
+        "treeSizes":     treeSizesQuery,
+        "symbols":       symbolQuery,
+        "references":    referencesQuery,
+        "commits":       commitsQuery,
+        "commitSearch":  commitSearchQuery,
+        "comparison":    comparisonQuery,
+        "blame":         blameQuery,
+        "currentUser":   currentUserQuery,
+        "indexStatus":   indexStatusQuery(1),
+        "introspection": gql.IntrospectionQuery,
+    }
+}
+
+// Schema 内省实例的 GraphQL schema
+func (c *Client) Schema(ctx context.Context) (*gql.Schema, error) {
+    var out struct {
+        Data struct {
+            Schema *gql.Schema `json:"__schema"`
+        } `json:"data"`
+        Errors []gqlError `json:"errors"`
+    }
+    if err := c.GraphQL(ctx, gql.IntrospectionQuery, nil, &out); err != nil {
+        return nil, err
+    }
+    if err := joinErrors(out.Errors); err != nil {
+        return nil, err
+    }
+    if out.Data.Schema == nil {
+        return nil, i18n.Errorf("实例没有返回 schema（可能关闭了内省）")
+    }
+    return out.Data.Schema, nil
+}
+
+// cachedSchema 读取实例的缓存 schema，每个实例只读一次
+func cachedSchema(endpoint string) *gql.Schema {
+    if s, ok := schemas.Load(endpoint); ok {
+        return s.(*gql.Schema)
+    }
+    var s *gql.Schema
+    if c, err := gql.LoadCached(endpoint); err == nil && c != nil {
+        s = c.Schema
+    }
+    schemas.Store(endpoint, s)
+    return s
+}
+
+// checkSchema 在 CheckSchema 打开时校验查询；解析失败的查询交给服务端报错
+func (c *Client) checkSchema(q string) {
+    if !CheckSchema {
+        return
+    }
+    if _, done := checked.LoadOrStore(q, true); done {
+        return
+    }
+    s := cachedSchema(c.URL(""))
+    if s == nil {
+        return
+    }
+    problems, err := s.ValidateQuery(q)
+    if err != nil {
+        return
+    }
+    for _, p := range problems {
+        if _, dup := reported.LoadOrStore(p.Message, true); dup {
+            continue
+        }
+        if p.Removed {
+            fmt.Fprint(os.Stderr, i18n.Sprintf("警告: 查询中的 %s：%s，请求可能失败（实例刚升级过时先运行 kb schema refresh 更新缓存）\n", p.Path, p.Message))
+        } else {
+            fmt.Fprint(os.Stderr, i18n.Sprintf("提示: 查询中的 %s：%s，之后的实例版本可能移除\n", p.Path, p.Message))
+        }
+    }
+}
@@ -0,0 +1,274 @@
+package sg
+
+import (
+    "cmp"
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "os"
+    "regexp"
+    "strings"
+
+    "kingbrain/insight/pkg/i18n"
+)
+
+type Repository struct {
+    Name string `json:"name"`
+    URL  string `json:"url"`
+}
+
+type File struct {
+    Path string `json:"path"`
+    URL  string `json:"url"`
+}
+
+// LineMatch 是一行匹配；LineNumber 从 0 开始。OffsetAndLengths 为行内每个匹配区间的
+// [起始, 长度]，以字符（rune）计，可直接交给 preview.Highlight。Annotations 由 --hook 脚本添加，
+// 服务端不返回
+type LineMatch struct {
+    Preview          string            `json:"preview"`
+    LineNumber       int               `json:"lineNumber"`
+    OffsetAndLengths [][2]int          `json:"offsetAndLengths,omitempty"`
+    Annotations      map[string]string `json:"annotations,omitempty"`
+}
+
+// FileMatch 是一个文件中的匹配；Rev 为匹配所在的 revision（查询没有指定 revision 时为空），
+// 只在 WithRevisions 或开启索引新鲜度标注时填上；Index 为其索引状态，只在开启索引新鲜度标注时填上（见 Freshness）
+type FileMatch struct {
+    Repository  Repository   `json:"repository"`
+    File        File         `json:"file"`
+    LineMatches []LineMatch  `json:"lineMatches"`
+    Rev         string       `json:"-"`
+    Index       *IndexStatus `json:"index,omitempty"`
+}
+
+type SearchResults struct {
+    MatchCount int         `json:"matchCount"`
+    LimitHit   bool        `json:"limitHit,omitempty"`
+    Truncated  bool        `json:"truncated,omitempty"`
+    Results    []FileMatch `json:"results"`
+}
+
+var countRe = regexp.MustCompile(`(^|\s)count:\S`)
+
+type gqlError struct {
+    Message string `json:"message"`
+}
+
+// searchQuery 的两个占位符为 patternType 与额外的 FileMatch 字段（revSpecField 或空）
+const searchQuery = `
+query ($q: String!) {
+  search(version: V3, query: $q, patternType: %s) {
+    results {
+      matchCount
+      limitHit
+      results {
+        ... on FileMatch {
+          repository { name url }
+          file { path url }
+          lineMatches { preview lineNumber offsetAndLengths }%s
+        }
+      }
+    }
+  }
+}
+`
+
+// revSpecField 取匹配所在的 revision，供索引新鲜度标注按 revision 查询索引状态
+const revSpecField = `
+          revSpec {
+            ... on GitRef { displayName }
+            ... on GitRevSpecExpr { expr }
+            ... on GitObject { abbreviatedOID }
+          }`
+
+// revSpec 是 revSpecField 的解码结果，三者只有一个非空
+type revSpec struct {
+    DisplayName    string `json:"displayName"`
+    Expr           string `json:"expr"`
+    AbbreviatedOID string `json:"abbreviatedOID"`
+}
+
+// Search 执行一次搜索并返回类型化的结果；patternType 为 literal|regexp|structural。
+// 查询里没有 count: 时自动追加 count:<maxResults>，让服务端先截断；返回的匹配数
+// 超过 maxResults 时再在客户端截断，两种情况都会在 stderr 提示如何放宽限制
+func (c *Client) Search(ctx context.Context, q, patternType string) (*SearchResults, error) {
+    files := []FileMatch{}
+    res, err := c.SearchEach(ctx, q, patternType, func(_ *SearchResults, fm FileMatch) error {
+        files = append(files, fm)
+        return nil
+    })
+    if err != nil {
+        return nil, err
+    }
+    res.Results = files
+    return res, nil
+}
+
+// SearchEach 与 Search 相同，但边解码响应边把每个文件匹配交给 fn，不在内存中保留结果，
+// 大结果集（如十万级匹配的正则查询）的内存占用与匹配数无关；设置了抽样时只交出样本（见 Sample）；
+// 开启索引新鲜度标注时先收齐结果再交出（见 searchAnnotated）。
+// 返回的 SearchResults 只有计数，
+// Results 为空；fn 收到的 res 是解码到当前位置的计数（响应中 matchCount 在结果之前）。
+// fn 返回错误时停止并返回该错误
+func (c *Client) SearchEach(ctx context.Context, q, patternType string, fn func(res *SearchResults, fm FileMatch) error) (*SearchResults, error) {
+    if c.freshness.Active() {
+        return c.searchAnnotated(ctx, q, patternType, fn)
+    }
+    if c.sample.Active() {
+        return c.searchSample(ctx, q, patternType, fn)
+    }
+    q = c.scoped(q)
+    injected := false
+    if c.maxResults > 0 && !countRe.MatchString(q) {
+        q, injected = fmt.Sprintf("%s count:%d", q, c.maxResults), true
+    }
+    res := &SearchResults{}
+    n := 0
+    // emit 丢弃非 FileMatch 的结果（仓库、提交等解码后为空路径），交给钩子处理后按 maxResults 截断：
+    // 超出上限的文件只保留能放下的行匹配，之后的结果不再交给 fn
+    emit := func(fm FileMatch) error {
+        if fm.File.Path == "" || res.Truncated {
+            return nil
+        }
+        if c.hook != nil {
+            keep, err := c.hook(&fm)
+            if err != nil || !keep {
+                return err
+            }
+        }
+        if c.maxResults > 0 {
+            k := max(len(fm.LineMatches), 1)
+            if n+k > c.maxResults {
+                res.Truncated = true
+                rest := c.maxResults - n
+                if rest <= 0 || len(fm.LineMatches) <= rest {
+                    return nil
+                }
+                fm.LineMatches = fm.LineMatches[:rest]
+            }
+            n += k
+        }
+        return fn(res, fm)
+    }
+
+    extra := ""
+    if c.withRev {
+        extra = revSpecField
+    }
+    var errs []gqlError
+    err := c.graphQLStream(ctx, fmt.Sprintf(searchQuery, patternType, extra), map[string]any{"q": q}, 0, func(dec *json.Decoder) error {
+        return walkObject(dec, func(key string) error {
+            switch key {
+            case "errors":
+                return dec.Decode(&errs)
+            case "data":
+                return walkPath(dec, []string{"search", "results"}, func(key string) error {
+                    switch key {
+                    case "matchCount":
+                        return dec.Decode(&res.MatchCount)
+                    case "limitHit":
+                        return dec.Decode(&res.LimitHit)
+                    case "results":
+                        return walkArray(dec, func() error {
+                            var m struct {
+                                FileMatch
+                                RevSpec *revSpec `json:"revSpec"`
+                            }
+                            if err := dec.Decode(&m); err != nil {
+                                return err
+                            }
+                            if r := m.RevSpec; r != nil {
+                                m.Rev = cmp.Or(r.DisplayName, r.Expr, r.AbbreviatedOID)
+                            }
+                            return emit(m.FileMatch)
+                        })
+                    }
+                    return skipValue(dec)
+                })
+            }
+            return skipValue(dec)
+        })
+    })
+    if err != nil {
+        return nil, err
+    }
+    if err := joinErrors(errs); err != nil {
+        return nil, err
+    }
+    if res.Truncated || (injected && res.LimitHit) {
+        fmt.Fprint(os.Stderr, i18n.Sprintf("警告: 结果已截断为 %d 个匹配；如需更多，用 --max-results N 放宽（0 为不限制），或在查询中写 count:N / count:all\n", c.maxResults))
+    }
+    return res, nil
+}
+
+// walkObject 读取一个 JSON 对象，对每个键调用 fn，由 fn 读取（或跳过）对应的值；值为 null 时什么也不做
+func walkObject(dec *json.Decoder, fn func(key string) error) error {
+    t, err := dec.Token()
+    if err != nil || t == nil {
+        return err
+    }
+    if d, ok := t.(json.Delim); !ok || d != '{' {
+        return i18n.Errorf("sg: 响应格式错误，期望 JSON 对象，得到 %v", t)
+    }
+    for dec.More() {
+        t, err := dec.Token()
+        if err != nil {
+            return err
+        }
+        key, _ := t.(string)
+        if err := fn(key); err != nil {
+            return err
+        }
+    }
+    _, err = dec.Token()
+    return err
+}
+
+// walkPath 沿着 path 逐层进入嵌套对象，对最内层对象的每个键调用 fn；其余的键跳过
+func walkPath(dec *json.Decoder, path []string, fn func(key string) error) error {
+    if len(path) == 0 {
+        return walkObject(dec, fn)
+    }
+    return walkObject(dec, func(key string) error {
+        if key != path[0] {
+            return skipValue(dec)
+        }
+        return walkPath(dec, path[1:], fn)
+    })
+}
+
+// walkArray 读取一个 JSON 数组，每个元素调用一次 fn，由 fn 解码该元素；值为 null 时什么也不做
+func walkArray(dec *json.Decoder, fn func() error) error {
+    t, err := dec.Token()
+    if err != nil || t == nil {
+        return err
+    }
+    if d, ok := t.(json.Delim); !ok || d != '[' {
+        return i18n.Errorf("sg: 响应格式错误，期望 JSON 数组，得到 %v", t)
+    }
+    for dec.More() {
+        if err := fn(); err != nil {
+            return err
+        }
+    }
+    _, err = dec.Token()
+    return err
+}
+
+func skipValue(dec *json.Decoder) error {
+    var raw json.RawMessage
+    return dec.Decode(&raw)
+}
+
+func joinErrors(errs []gqlError) error {
+    if len(errs) == 0 {
+        return nil
+    }
+    msgs := make([]string, 0, len(errs))
+    for _, e := range errs {
+        msgs = append(msgs, e.Message)
+    }
+    return errors.New("graphql: " + strings.Join(msgs, "; "))
+}
@@ -0,0 +1,91 @@
+package sg
+
+import (
+    "bufio"
+    "context"
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "net/url"
+    "strings"
+    "time"
+)
+
+// StreamStats 是一次流式搜索的计时与计数
+type StreamStats struct {
+    FirstMatch time.Duration // 收到第一批匹配的耗时，没有匹配时为 0
+    Elapsed    time.Duration
+    MatchCount int // 最后一个 progress 事件报告的匹配数
+}
+
+// StreamSearch 调用流式搜索接口（GET /.api/search/stream，SSE），读到 done 事件为止；
+// 只统计首个匹配时间与匹配数，不保留结果。与 GraphQL 请求一样遵守共享配额、重试 429 并在失败时换用备用端点；
+// 流式请求可能很长，不设整体超时。计时从实际发出请求开始，不含等待配额的时间
+func (c *Client) StreamSearch(ctx context.Context, q, patternType string) (*StreamStats, error) {
+    v := url.Values{"q": {c.scoped(q)}, "v": {"V3"}, "t": {patternType}, "display": {"0"}}
+    var start time.Time
+    resp, _, errs := c.do(ctx, 0, func(ctx context.Context, base string) (*http.Request, error) {
+        req, err := http.NewRequestWithContext(ctx, "GET", strings.TrimSuffix(base, "/")+"/.api/search/stream?"+v.Encode(), nil)
+        if err != nil {
+            return nil, err
+        }
+        req.Header.Set("Accept", "text/event-stream")
+        start = time.Now()
+        return req, nil
+    })
+    if resp == nil {
+        return nil, requestError(errs)
+    }
+    defer resp.Body.Close()
+
+    st := &StreamStats{}
+    sc := bufio.NewScanner(resp.Body)
+    sc.Buffer(make([]byte, 64*1024), 64*1024*1024)
+    var event string
+    var data strings.Builder
+    for sc.Scan() {
+        line := sc.Text()
+        switch {
+        case strings.HasPrefix(line, "event:"):
+            event = strings.TrimSpace(line[len("event:"):])
+            continue
+        case strings.HasPrefix(line, "data:"):
+            data.WriteString(strings.TrimPrefix(line[len("data:"):], " "))
+            continue
+        case line != "":
+            continue
+        }
+        // 空行结束一个事件
+        switch event {
+        case "matches":
+            if st.FirstMatch == 0 && data.Len() > 2 {
+                st.FirstMatch = time.Since(start)
+            }
+        case "progress":
+            var p struct {
+                MatchCount int `json:"matchCount"`
+            }
+            if json.Unmarshal([]byte(data.String()), &p) == nil {
+                st.MatchCount = p.MatchCount
+            }
+        case "error":
+            var e struct {
+                Message string `json:"message"`
+            }
+            if json.Unmarshal([]byte(data.String()), &e) != nil || e.Message == "" {
+                e.Message = data.String()
+            }
+            return nil, fmt.Errorf("stream search: %s", e.Message)
+        case "done":
+            st.Elapsed = time.Since(start)
+            return st, nil
+        }
+        event = ""
+        data.Reset()
+    }
+    if err := sc.Err(); err != nil {
+        return nil, err
+    }
+    st.Elapsed = time.Since(start)
+    return st, nil
+}
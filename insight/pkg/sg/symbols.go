@@ -0,0 +1,163 @@
+package sg
+
+import (
+    "context"
+
+    "kingbrain/insight/pkg/i18n"
+)
+
+// Symbol 是符号搜索返回的一个定义；Line/Character 从 0 开始
+type Symbol struct {
+    Repo          string `json:"repo"`
+    Path          string `json:"path"`
+    Name          string `json:"name"`
+    Kind          string `json:"kind"`
+    ContainerName string `json:"containerName,omitempty"`
+    Line          int    `json:"line"`
+    Character     int    `json:"character"`
+}
+
+// Location 是代码智能返回的一个引用位置
+type Location struct {
+    Repo string `json:"repo"`
+    Path string `json:"path"`
+    Line int    `json:"line"`
+}
+
+const symbolQuery = `
+query ($q: String!) {
+  search(version: V3, query: $q, patternType: regexp) {
+    results {
+      results {
+        ... on FileMatch {
+          repository { name }
+          file { path }
+          symbols {
+            name kind containerName
+            location { range { start { line character } } }
+          }
+        }
+      }
+    }
+  }
+}
+`
+
+// Symbols 执行符号搜索（查询中需带 type:symbol），模式按正则解释
+func (c *Client) Symbols(ctx context.Context, q string) ([]Symbol, error) {
+    var out struct {
+        Data struct {
+            Search struct {
+                Results struct {
+                    Results []struct {
+                        Repository Repository `json:"repository"`
+                        File       File       `json:"file"`
+                        Symbols    []struct {
+                            Name          string `json:"name"`
+                            Kind          string `json:"kind"`
+                            ContainerName string `json:"containerName"`
+                            Location      struct {
+                                Range struct {
+                                    Start struct {
+                                        Line      int `json:"line"`
+                                        Character int `json:"character"`
+                                    } `json:"start"`
+                                } `json:"range"`
+                            } `json:"location"`
+                        } `json:"symbols"`
+                    } `json:"results"`
+                } `json:"results"`
+            } `json:"search"`
+        } `json:"data"`
+        Errors []gqlError `json:"errors"`
+    }
+    if err := c.graphQLBulk(ctx, symbolQuery, map[string]any{"q": c.scoped(q)}, &out); err != nil {
+        return nil, err
+    }
+    if err := joinErrors(out.Errors); err != nil {
+        return nil, err
+    }
+    var syms []Symbol
+    for _, fm := range out.Data.Search.Results.Results {
+        for _, s := range fm.Symbols {
+            syms = append(syms, Symbol{
+                Repo:          fm.Repository.Name,
+                Path:          fm.File.Path,
+                Name:          s.Name,
+                Kind:          s.Kind,
+                ContainerName: s.ContainerName,
+                Line:          s.Location.Range.Start.Line,
+                Character:     s.Location.Range.Start.Character,
+            })
+        }
+    }
+    return syms, nil
+}
+
+const referencesQuery = `
+query ($repo: String!, $rev: String!, $path: String!, $line: Int!, $character: Int!, $first: Int!) {
+  repository(name: $repo) {
+    commit(rev: $rev) {
+      blob(path: $path) {
+        lsif {
+          references(line: $line, character: $character, first: $first) {
+            nodes { resource { repository { name } path } range { start { line } } }
+          }
+        }
+      }
+    }
+  }
+}
+`
+
+// References 通过精确代码智能（LSIF/SCIP）查询符号的引用；
+// 文件没有精确索引时 precise 为 false，调用方应改用基于搜索的估计
+func (c *Client) References(ctx context.Context, repo, rev, path string, line, character, first int) (refs []Location, precise bool, err error) {
+    if rev == "" {
+        rev = "HEAD"
+    }
+    var out struct {
+        Data struct {
+            Repository *struct {
+                Commit *struct {
+                    Blob *struct {
+                        LSIF *struct {
+                            References struct {
+                                Nodes []struct {
+                                    Resource struct {
+                                        Repository Repository `json:"repository"`
+                                        Path       string     `json:"path"`
+                                    } `json:"resource"`
+                                    Range struct {
+                                        Start struct {
+                                            Line int `json:"line"`
+                                        } `json:"start"`
+                                    } `json:"range"`
+                                } `json:"nodes"`
+                            } `json:"references"`
+                        } `json:"lsif"`
+                    } `json:"blob"`
+                } `json:"commit"`
+            } `json:"repository"`
+        } `json:"data"`
+        Errors []gqlError `json:"errors"`
+    }
+    v := map[string]any{"repo": repo, "rev": rev, "path": path, "line": line, "character": character, "first": first}
+    if err := c.GraphQL(ctx, referencesQuery, v, &out); err != nil {
+        return nil, false, err
+    }
+    if err := joinErrors(out.Errors); err != nil {
+        return nil, false, err
+    }
+    r := out.Data.Repository
+    if r == nil || r.Commit == nil || r.Commit.Blob == nil {
+        return nil, false, i18n.Errorf("%s@%s 中找不到文件 %s", repo, rev, path)
+    }
+    if r.Commit.Blob.LSIF == nil {
+        return nil, false, nil
+    }
+    for _, n := range r.Commit.Blob.LSIF.References.Nodes {
+        refs = append(refs, Location{Repo: n.Resource.Repository.Name, Path: n.Resource.Path, Line: n.Range.Start.Line})
+    }
+    return refs, true, nil
+}
@@ -0,0 +1,27 @@
+package sg
+
+import "context"
+
+const currentUserQuery = `query { currentUser { username } }`
+
+// CurrentUser 返回 token 对应的 Sourcegraph 用户名；匿名访问（实例允许时）返回空字符串
+func (c *Client) CurrentUser(ctx context.Context) (string, error) {
+    var out struct {
+        Data struct {
+            CurrentUser *struct {
+                Username string `json:"username"`
+            } `json:"currentUser"`
+        } `json:"data"`
+        Errors []gqlError `json:"errors"`
+    }
+    if err := c.GraphQL(ctx, currentUserQuery, nil, &out); err != nil {
+        return "", err
+    }
+    if err := joinErrors(out.Errors); err != nil {
+        return "", err
+    }
+    if out.Data.CurrentUser == nil {
+        return "", nil
+    }
+    return out.Data.CurrentUser.Username, nil
+}
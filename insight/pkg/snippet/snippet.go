@@ -0,0 +1,243 @@
+package snippet
+
+import (
+    "go/ast"
+    "go/parser"
+    "go/token"
+    "path"
+    "regexp"
+    "strings"
+)
+
+// Func 是包含某行的函数/方法，行号从 1 开始（含两端）
+type Func struct {
+    Name  string `json:"name"`
+    Start int    `json:"start"`
+    End   int    `json:"end"`
+}
+
+var (
+    pyDefRe = regexp.MustCompile(`^(\s*)(?:async\s+)?def\s+(\w+)`)
+    // 花括号语言的函数头：尽量宽松，真正的范围由括号匹配确定
+    braceHeaderRe = map[string]*regexp.Regexp{
+        "c":    regexp.MustCompile(`^[\w\s\*&:<>,~\[\]]*?\b(\w+)\s*\([^;]*$`),
+        "js":   regexp.MustCompile(`(?:function\s*\*?\s*(\w+)|(\w+)\s*[:=]\s*(?:async\s+)?(?:function\b|\([^)]*\)\s*=>|\w+\s*=>)|^\s*(?:(?:public|private|protected|static|async|get|set)\s+)*(\w+)\s*\([^)]*\)\s*(?::\s*[^{]+)?\{)`),
+        "rust": regexp.MustCompile(`\bfn\s+(\w+)`),
+        "kt":   regexp.MustCompile(`\bfun\s+(?:<[^>]*>\s*)?(?:\w+\.)?(\w+)`),
+        "php":  regexp.MustCompile(`\bfunction\s+(\w+)`),
+    }
+    keywordLike = map[string]bool{"if": true, "for": true, "while": true, "switch": true, "catch": true, "return": true, "sizeof": true, "else": true, "do": true, "try": true, "synchronized": true}
+)
+
+// family 按扩展名归类语言，返回空串表示不支持
+func family(p string) string {
+    switch strings.ToLower(path.Ext(p)) {
+    case ".go":
+        return "go"
+    case ".py":
+        return "py"
+    case ".c", ".h", ".cc", ".cpp", ".cxx", ".hpp", ".java", ".cs", ".scala", ".m":
+        return "c"
+    case ".js", ".jsx", ".ts", ".tsx", ".mjs", ".cjs":
+        return "js"
+    case ".rs":
+        return "rust"
+    case ".kt", ".kts", ".swift":
+        return "kt"
+    case ".php":
+        return "php"
+    }
+    return ""
+}
+
+// Supported 表示是否能为该文件提取所在函数
+func Supported(p string) bool { return family(p) != "" }
+
+// Enclosing 返回 content 中包含第 line 行的最内层函数；Go 用 go/parser 精确解析，
+// Python 按缩进，其余花括号语言用函数头识别 + 括号匹配
+func Enclosing(p, content string, line int) (Func, bool) {
+    switch fam := family(p); fam {
+    case "go":
+        if f, ok := enclosingGo(content, line); ok {
+            return f, true
+        }
+        // 语法错误的文件退回到通用的括号匹配
+        return enclosingBrace(braceHeaderRe["rust"], strings.Split(content, "\n"), line)
+    case "py":
+        return enclosingPython(strings.Split(content, "\n"), line)
+    case "":
+        return Func{}, false
+    default:
+        return enclosingBrace(braceHeaderRe[fam], strings.Split(content, "\n"), line)
+    }
+}
+
+func enclosingGo(content string, line int) (Func, bool) {
+    fset := token.NewFileSet()
+    file, err := parser.ParseFile(fset, "", content, parser.SkipObjectResolution)
+    if err != nil || file == nil {
+        return Func{}, false
+    }
+    var best Func
+    found := false
+    ast.Inspect(file, func(n ast.Node) bool {
+        if n == nil {
+            return false
+        }
+        start, end := fset.Position(n.Pos()).Line, fset.Position(n.End()).Line
+        if line < start || line > end {
+            return false
+        }
+        switch fn := n.(type) {
+        case *ast.FuncDecl:
+            name := fn.Name.Name
+            if fn.Recv != nil && len(fn.Recv.List) > 0 {
+                name = "(" + exprString(fn.Recv.List[0].Type) + ")." + name
+            }
+            best, found = Func{Name: name, Start: start, End: end}, true
+        case *ast.FuncLit:
+            // 只有跨多行的闭包才值得单独展示，否则保留外层函数
+            if end-start >= 2 {
+                best, found = Func{Name: best.Name + ".func", Start: start, End: end}, true
+            }
+        }
+        return true
+    })
+    return best, found
+}
+
+func exprString(e ast.Expr) string {
+    switch t := e.(type) {
+    case *ast.StarExpr:
+        return "*" + exprString(t.X)
+    case *ast.Ident:
+        return t.Name
+    case *ast.IndexExpr:
+        return exprString(t.X)
+    case *ast.IndexListExpr:
+        return exprString(t.X)
+    }
+    return "?"
+}
+
+func indentOf(s string) int {
+    return len(s) - len(strings.TrimLeft(s, " \t"))
+}
+
+func enclosingPython(lines []string, line int) (Func, bool) {
+    if line < 1 || line > len(lines) {
+        return Func{}, false
+    }
+    // 自下而上找缩进比 limit 浅的 def；匹配行本身是 def 时也算
+    limit := indentOf(lines[line-1]) + 1
+    for i := line - 1; i >= 0; i-- {
+        l := lines[i]
+        ind := indentOf(l)
+        if strings.TrimSpace(l) == "" || ind >= limit {
+            continue
+        }
+        if m := pyDefRe.FindStringSubmatch(l); m != nil {
+            end := i + 1
+            for j := i + 1; j < len(lines); j++ {
+                if strings.TrimSpace(lines[j]) == "" {
+                    continue
+                }
+                if indentOf(lines[j]) <= ind {
+                    break
+                }
+                end = j + 1
+            }
+            if end >= line {
+                // 带上紧挨着的装饰器
+                start := i + 1
+                for start > 1 && strings.HasPrefix(strings.TrimSpace(lines[start-2]), "@") {
+                    start--
+                }
+                return Func{Name: m[2], Start: start, End: end}, true
+            }
+        }
+        limit = ind
+    }
+    return Func{}, false
+}
+
+// enclosingBrace 从 line 向上找函数头，再从函数头后的第一个 { 做括号匹配，
+// 取第一个范围覆盖 line 的函数；会跳过字符串与注释中的括号
+func enclosingBrace(header *regexp.Regexp, lines []string, line int) (Func, bool) {
+    if line < 1 || line > len(lines) {
+        return Func{}, false
+    }
+    for i := line - 1; i >= 0 && line-i < 2000; i-- {
+        m := header.FindStringSubmatch(lines[i])
+        if m == nil {
+            continue
+        }
+        name := ""
+        for _, g := range m[1:] {
+            if g != "" {
+                name = g
+                break
+            }
+        }
+        if name == "" || keywordLike[name] {
+            continue
+        }
+        end, ok := matchBraces(lines, i)
+        if ok && end >= line {
+            return Func{Name: name, Start: i + 1, End: end}, true
+        }
+    }
+    return Func{}, false
+}
+
+// matchBraces 返回从 start 行开始的第一个 { 对应的 } 所在行（1 起）；
+// 函数头之后 5 行内没有 { 或先遇到 ; 则认为不是函数定义
+func matchBraces(lines []string, start int) (int, bool) {
+    depth, opened := 0, false
+    inBlock := false
+    var quote byte
+    for i := start; i < len(lines); i++ {
+        l := lines[i]
+        for j := 0; j < len(l); j++ {
+            ch := l[j]
+            switch {
+            case inBlock:
+                if ch == '*' && j+1 < len(l) && l[j+1] == '/' {
+                    inBlock = false
+                    j++
+                }
+            case quote != 0:
+                if ch == '\\' {
+                    j++
+                } else if ch == quote {
+                    quote = 0
+                }
+            case ch == '/' && j+1 < len(l) && l[j+1] == '/':
+                j = len(l)
+            case ch == '/' && j+1 < len(l) && l[j+1] == '*':
+                inBlock = true
+                j++
+            case ch == '"' || ch == '\'' || ch == '`':
+                quote = ch
+            case ch == ';' && !opened:
+                return 0, false
+            case ch == '{':
+                depth++
+                opened = true
+            case ch == '}':
+                depth--
+                if opened && depth == 0 {
+                    return i + 1, true
+                }
+            }
+        }
+        // 反引号模板字符串可以跨行，其余引号在行尾结束
+        if quote != '`' {
+            quote = 0
+        }
+        if !opened && i-start >= 5 {
+            return 0, false
+        }
+    }
+    return 0, false
+}
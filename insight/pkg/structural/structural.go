@@ -0,0 +1,352 @@
+package structural
+
+import (
+    "slices"
+    "strings"
+    "unicode"
+
+    "kingbrain/insight/pkg/i18n"
+)
+
+// 单个洞最多匹配的字节数，防止病态模式在大文件上回溯过久
+const maxHole = 64 << 10
+
+var openOf = map[byte]byte{')': '(', ']': '[', '}': '{'}
+
+type tokKind int
+
+const (
+    tokLit   tokKind = iota
+    tokSpace         // 模式中的空白：两侧都是标识符字符时要求至少一个空白，否则可以没有
+    tokHole          // :[x] 或 ...，匹配括号平衡的任意文本（可跨行）
+    tokIdent         // :[[x]]，只匹配标识符字符
+)
+
+type token struct {
+    kind  tokKind
+    text  string // tokLit 的字面量或洞名（匿名洞为空）
+    needs bool   // tokSpace 是否至少需要一个空白
+}
+
+// Pattern 是编译后的结构化模式，语法与 Sourcegraph/comby 的结构化搜索一致的子集：
+// :[name] 匹配括号平衡的任意文本，:[[name]] 匹配标识符，... 是匿名洞，
+// 同名洞必须匹配相同文本，空白匹配任意空白
+type Pattern struct {
+    toks []token
+}
+
+// Match 是一处匹配；Start/End 为字节偏移，Line 从 1 开始
+type Match struct {
+    Start, End int
+    Line       int
+    Holes      map[string]string
+}
+
+// Compile 解析模式
+func Compile(pattern string) (*Pattern, error) {
+    var toks []token
+    lit := func(s string) {
+        if n := len(toks); n > 0 && toks[n-1].kind == tokLit {
+            toks[n-1].text += s
+            return
+        }
+        toks = append(toks, token{kind: tokLit, text: s})
+    }
+    s := strings.TrimSpace(pattern)
+    for i := 0; i < len(s); {
+        switch {
+        case strings.HasPrefix(s[i:], ":[["):
+            end := strings.Index(s[i:], "]]")
+            if end < 0 {
+                return nil, i18n.Errorf("未闭合的 :[[ 洞（位置 %d）", i)
+            }
+            toks = append(toks, token{kind: tokIdent, text: s[i+3 : i+end]})
+            i += end + 2
+        case strings.HasPrefix(s[i:], ":["):
+            end := strings.IndexByte(s[i:], ']')
+            if end < 0 {
+                return nil, i18n.Errorf("未闭合的 :[ 洞（位置 %d）", i)
+            }
+            toks = append(toks, token{kind: tokHole, text: s[i+2 : i+end]})
+            i += end + 1
+        case strings.HasPrefix(s[i:], "..."):
+            toks = append(toks, token{kind: tokHole})
+            i += 3
+        case isSpace(s[i]):
+            j := i
+            for j < len(s) && isSpace(s[j]) {
+                j++
+            }
+            needs := i > 0 && j < len(s) && isWord(rune(s[i-1])) && isWord(rune(s[j]))
+            toks = append(toks, token{kind: tokSpace, needs: needs})
+            i = j
+        default:
+            lit(s[i : i+1])
+            i++
+        }
+    }
+    if len(toks) == 0 {
+        return nil, i18n.Errorf("模式为空")
+    }
+    for i := 1; i < len(toks); i++ {
+        if toks[i].kind == tokHole && toks[i-1].kind == tokHole {
+            return nil, i18n.Errorf("两个洞不能直接相邻")
+        }
+    }
+    return &Pattern{toks: toks}, nil
+}
+
+func isSpace(b byte) bool { return b == ' ' || b == '\t' || b == '\n' || b == '\r' }
+
+func isWord(r rune) bool { return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) }
+
+// FindAll 返回 src 中所有不重叠的匹配
+func (p *Pattern) FindAll(src string) []Match {
+    var out []Match
+    lines := 1
+    last := 0
+    add := func(start, end int, holes map[string]string) {
+        lines += strings.Count(src[last:start], "\n")
+        last = start
+        out = append(out, Match{Start: start, End: end, Line: lines, Holes: holes})
+    }
+    // 模式以洞开头时不逐个偏移地尝试（每个偏移都要把洞扩展到 maxHole），而是以第一个字面量为锚点：
+    // 其余部分能从锚点匹配时，把开头的洞从锚点向前扩展得到起点，再从起点完整匹配一次得到各洞的值。
+    // 向前扩展不越过锚点之前未闭合的左括号，嵌套时先得到内层的匹配
+    if k := p.anchor(); k > 0 {
+        lit := p.toks[k].text
+        prev := 0 // 上一处匹配的结尾，洞不能向前越过它
+        for i := 0; i < len(src); {
+            j := strings.Index(src[i:], lit)
+            if j < 0 {
+                break
+            }
+            at := i + j
+            var holes map[string]string
+            var end int
+            found := false
+            if _, ok := p.match(src, k, at, map[string]string{}); ok {
+                found = p.matchBack(src, k, at, max(prev, at-maxHole), func(start int) bool {
+                    holes = map[string]string{}
+                    e, ok := p.match(src, 0, start, holes)
+                    if ok && e > start {
+                        i, end = start, e
+                        return true
+                    }
+                    return false
+                })
+            }
+            if !found {
+                i = at + 1
+                continue
+            }
+            add(i, end, holes)
+            i, prev = end, end
+        }
+        return out
+    }
+    for i := 0; i < len(src); {
+        // 首个 token 为字面量时直接跳到下一次出现处
+        if t := p.toks[0]; t.kind == tokLit {
+            j := strings.Index(src[i:], t.text)
+            if j < 0 {
+                break
+            }
+            i += j
+        }
+        holes := map[string]string{}
+        if end, ok := p.match(src, 0, i, holes); ok && end > i {
+            add(i, end, holes)
+            i = end
+            continue
+        }
+        i++
+    }
+    return out
+}
+
+// anchor 返回第一个字面量 token 的下标；没有字面量时为 -1
+func (p *Pattern) anchor() int {
+    for i, t := range p.toks {
+        if t.kind == tokLit {
+            return i
+        }
+    }
+    return -1
+}
+
+// matchBack 从 pos 向前匹配 toks[:ti]（不越过 lo），对每个可能的起点调用 fn，fn 返回 true 时停止。
+// 洞都从长到短尝试，先得到最靠前的起点，与从左到右逐个偏移尝试时找到的最左匹配一致
+func (p *Pattern) matchBack(src string, ti, pos, lo int, fn func(start int) bool) bool {
+    if ti == 0 {
+        return fn(pos)
+    }
+    t := p.toks[ti-1]
+    switch t.kind {
+    case tokLit:
+        if !strings.HasSuffix(src[lo:pos], t.text) {
+            return false
+        }
+        return p.matchBack(src, ti-1, pos-len(t.text), lo, fn)
+    case tokSpace:
+        j := pos
+        for j > lo && isSpace(src[j-1]) {
+            j--
+        }
+        if t.needs && j == pos {
+            return false
+        }
+        return p.matchBack(src, ti-1, j, lo, fn)
+    }
+
+    // 洞的候选起点：标识符洞为之前连续的标识符字符，平衡洞为 src[j:pos] 括号平衡且不在字符串中的位置，
+    // 向前遇到未闭合的左括号即停止
+    var starts []int
+    if t.kind == tokIdent {
+        for j := pos - 1; j >= lo && isWord(rune(src[j])); j-- {
+            starts = append(starts, j)
+        }
+    } else {
+        var stack []byte
+        var quote byte
+    scan:
+        for j := pos; j >= lo && pos-j <= maxHole; j-- {
+            if len(stack) == 0 && quote == 0 {
+                starts = append(starts, j)
+            }
+            if j == lo {
+                break
+            }
+            c := src[j-1]
+            if c == '"' || c == '\'' || c == '`' {
+                if escaped(src, j-1) {
+                    continue
+                }
+                switch quote {
+                case 0:
+                    quote = c
+                case c:
+                    quote = 0
+                }
+                continue
+            }
+            if quote != 0 {
+                continue
+            }
+            switch c {
+            case ')', ']', '}':
+                stack = append(stack, c)
+            case '(', '[', '{':
+                if len(stack) == 0 || openOf[stack[len(stack)-1]] != c {
+                    break scan // 洞不能越过外层的左括号
+                }
+                stack = stack[:len(stack)-1]
+            }
+        }
+    }
+    slices.Reverse(starts)
+    for _, j := range starts {
+        if p.matchBack(src, ti-1, j, lo, fn) {
+            return true
+        }
+    }
+    return false
+}
+
+// escaped 报告 src[i] 前是否有奇数个反斜杠
+func escaped(src string, i int) bool {
+    n := 0
+    for i > 0 && src[i-1] == '\\' {
+        n++
+        i--
+    }
+    return n%2 == 1
+}
+
+func (p *Pattern) match(src string, ti, pos int, holes map[string]string) (int, bool) {
+    if ti == len(p.toks) {
+        return pos, true
+    }
+    t := p.toks[ti]
+    switch t.kind {
+    case tokLit:
+        if !strings.HasPrefix(src[pos:], t.text) {
+            return 0, false
+        }
+        return p.match(src, ti+1, pos+len(t.text), holes)
+    case tokSpace:
+        j := pos
+        for j < len(src) && isSpace(src[j]) {
+            j++
+        }
+        if t.needs && j == pos {
+            return 0, false
+        }
+        return p.match(src, ti+1, j, holes)
+    case tokIdent:
+        j := pos
+        for j < len(src) && isWord(rune(src[j])) {
+            j++
+        }
+        // 标识符洞也是惰性的，让后续的字面量有机会匹配
+        for k := pos + 1; k <= j; k++ {
+            if end, ok := p.bind(src, ti, pos, k, holes); ok {
+                return end, true
+            }
+        }
+        return 0, false
+    }
+
+    // 平衡洞：逐字节扩展，只在括号深度为 0 且不在字符串里时尝试匹配后续 token
+    var stack []byte
+    var quote byte
+    for j := pos; j <= len(src) && j-pos <= maxHole; j++ {
+        if len(stack) == 0 && quote == 0 {
+            if end, ok := p.bind(src, ti, pos, j, holes); ok {
+                return end, true
+            }
+        }
+        if j == len(src) {
+            break
+        }
+        c := src[j]
+        switch {
+        case quote != 0:
+            if c == '\\' {
+                j++
+            } else if c == quote {
+                quote = 0
+            }
+        case c == '"' || c == '\'' || c == '`':
+            quote = c
+        case c == '(' || c == '[' || c == '{':
+            stack = append(stack, c)
+        case c == ')' || c == ']' || c == '}':
+            if len(stack) == 0 || stack[len(stack)-1] != openOf[c] {
+                return 0, false // 洞不能越过外层的右括号
+            }
+            stack = stack[:len(stack)-1]
+        }
+    }
+    return 0, false
+}
+
+// bind 把 src[pos:end] 绑定到洞上并继续匹配；同名洞必须一致
+func (p *Pattern) bind(src string, ti, pos, end int, holes map[string]string) (int, bool) {
+    name, val := p.toks[ti].text, src[pos:end]
+    if name != "" && name != "_" {
+        if prev, ok := holes[name]; ok {
+            if prev != val {
+                return 0, false
+            }
+            return p.match(src, ti+1, end, holes)
+        }
+        holes[name] = val
+        if e, ok := p.match(src, ti+1, end, holes); ok {
+            return e, true
+        }
+        delete(holes, name)
+        return 0, false
+    }
+    return p.match(src, ti+1, end, holes)
+}
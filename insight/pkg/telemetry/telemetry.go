@@ -0,0 +1,163 @@
+package telemetry
+
+import (
+    "bufio"
+    "bytes"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "net/http"
+    "os"
+    "path/filepath"
+    "sort"
+    "time"
+)
+
+// Event 是一次命令执行的记录；只记录命令路径与用到的 flag 名，不记录参数值与查询内容
+type Event struct {
+    Time       time.Time `json:"time"`
+    Command    string    `json:"command"`
+    Flags      []string  `json:"flags,omitempty"`
+    DurationMS int64     `json:"duration_ms"`
+    OK         bool      `json:"ok"`
+}
+
+// CommandStats 是单个命令的聚合统计，延迟单位为毫秒
+type CommandStats struct {
+    Command string         `json:"command"`
+    Count   int            `json:"count"`
+    Errors  int            `json:"errors"`
+    P50     int64          `json:"p50_ms"`
+    P90     int64          `json:"p90_ms"`
+    P99     int64          `json:"p99_ms"`
+    Flags   map[string]int `json:"flags,omitempty"`
+}
+
+type Report struct {
+    Since    time.Time      `json:"since"`
+    Events   int            `json:"events"`
+    Commands []CommandStats `json:"commands"`
+}
+
+// Path 返回本地事件文件路径：<用户缓存目录>/insight/telemetry.jsonl
+func Path() (string, error) {
+    dir, err := os.UserCacheDir()
+    if err != nil {
+        return "", err
+    }
+    return filepath.Join(dir, "insight", "telemetry.jsonl"), nil
+}
+
+// Record 追加一条事件
+func Record(e Event) error {
+    p, err := Path()
+    if err != nil {
+        return err
+    }
+    if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+        return err
+    }
+    f, err := os.OpenFile(p, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+    if err != nil {
+        return err
+    }
+    defer f.Close()
+    return json.NewEncoder(f).Encode(e)
+}
+
+// Load 读取全部本地事件
+func Load() ([]Event, error) {
+    p, err := Path()
+    if err != nil {
+        return nil, err
+    }
+    f, err := os.Open(p)
+    if errors.Is(err, os.ErrNotExist) {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, err
+    }
+    defer f.Close()
+    var out []Event
+    sc := bufio.NewScanner(f)
+    for sc.Scan() {
+        var e Event
+        if json.Unmarshal(sc.Bytes(), &e) == nil {
+            out = append(out, e)
+        }
+    }
+    return out, sc.Err()
+}
+
+// Reset 删除本地事件
+func Reset() error {
+    p, err := Path()
+    if err != nil {
+        return err
+    }
+    if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+        return err
+    }
+    return nil
+}
+
+// Aggregate 按命令汇总调用次数、错误数、flag 使用次数与延迟分位数
+func Aggregate(events []Event) Report {
+    r := Report{Events: len(events)}
+    by := map[string]*CommandStats{}
+    lat := map[string][]int64{}
+    for _, e := range events {
+        if r.Since.IsZero() || e.Time.Before(r.Since) {
+            r.Since = e.Time
+        }
+        s, ok := by[e.Command]
+        if !ok {
+            s = &CommandStats{Command: e.Command, Flags: map[string]int{}}
+            by[e.Command] = s
+        }
+        s.Count++
+        if !e.OK {
+            s.Errors++
+        }
+        for _, f := range e.Flags {
+            s.Flags[f]++
+        }
+        lat[e.Command] = append(lat[e.Command], e.DurationMS)
+    }
+    for cmd, s := range by {
+        l := lat[cmd]
+        sort.Slice(l, func(i, j int) bool { return l[i] < l[j] })
+        s.P50, s.P90, s.P99 = percentile(l, 50), percentile(l, 90), percentile(l, 99)
+        r.Commands = append(r.Commands, *s)
+    }
+    sort.Slice(r.Commands, func(i, j int) bool { return r.Commands[i].Count > r.Commands[j].Count })
+    return r
+}
+
+// percentile 对已排序的切片取最近秩分位数
+func percentile(sorted []int64, p int) int64 {
+    if len(sorted) == 0 {
+        return 0
+    }
+    i := (len(sorted)*p + 99) / 100
+    return sorted[max(i-1, 0)]
+}
+
+// Push 把聚合报告（而非原始事件）POST 到内部端点
+func Push(endpoint string, r Report) error {
+    b, err := json.Marshal(r)
+    if err != nil {
+        return err
+    }
+    client := &http.Client{Timeout: 10 * time.Second}
+    resp, err := client.Post(endpoint, "application/json", bytes.NewReader(b))
+    if err != nil {
+        return err
+    }
+    defer resp.Body.Close()
+    if resp.StatusCode >= 300 {
+        return fmt.Errorf("telemetry push: %s", resp.Status)
+    }
+    return nil
+}
@@ -0,0 +1,210 @@
+package templates
+
+import (
+    "errors"
+    "io/fs"
+    "os"
+    "path/filepath"
+    "regexp"
+    "slices"
+    "sort"
+    "strings"
+    "text/template"
+    "text/template/parse"
+
+    "gopkg.in/yaml.v3"
+    "kingbrain/insight/pkg/i18n"
+)
+
+// Param 是模板的一个参数；Default 为空且 Required 时必须用 --var 给出
+type Param struct {
+    Name        string `yaml:"name" json:"name"`
+    Description string `yaml:"description,omitempty" json:"description,omitempty"`
+    Default     string `yaml:"default,omitempty" json:"default,omitempty"`
+    Required    bool   `yaml:"required,omitempty" json:"required,omitempty"`
+}
+
+// Template 是一个搜索模板：Query 为 text/template 语法，如 repo:{{.Service}} {{.Module}}@v{{.Version}}。
+// Name 为相对模板目录去掉 .yaml 的路径，Source 为模板文件的路径
+type Template struct {
+    Name        string  `yaml:"-" json:"name"`
+    Description string  `yaml:"description,omitempty" json:"description,omitempty"`
+    Pattern     string  `yaml:"pattern,omitempty" json:"pattern,omitempty"`
+    Query       string  `yaml:"query" json:"query"`
+    Params      []Param `yaml:"params,omitempty" json:"params,omitempty"`
+    Source      string  `yaml:"-" json:"source"`
+}
+
+// Ext 是模板文件的扩展名
+const Ext = ".yaml"
+
+// funcs 是模板中可用的函数：re 转义正则元字符，quote 加上双引号（用于含空格的字面量）
+var funcs = template.FuncMap{
+    "re":    regexp.QuoteMeta,
+    "quote": func(s string) string { return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"` },
+    "lower": strings.ToLower,
+    "upper": strings.ToUpper,
+}
+
+// Load 读取一个模板文件
+func Load(file, name string) (*Template, error) {
+    b, err := os.ReadFile(file)
+    if err != nil {
+        return nil, err
+    }
+    var t Template
+    if err := yaml.Unmarshal(b, &t); err != nil {
+        return nil, i18n.Errorf("%s: %w", file, err)
+    }
+    if strings.TrimSpace(t.Query) == "" {
+        return nil, i18n.Errorf("%s: 模板没有 query", file)
+    }
+    if _, err := template.New(name).Funcs(funcs).Parse(t.Query); err != nil {
+        return nil, i18n.Errorf("%s: %w", file, err)
+    }
+    t.Name, t.Source = name, file
+    return &t, nil
+}
+
+// List 列出各目录中的模板，按名字排序；同名模板取排在前面的目录中的那个
+func List(dirs []string) ([]*Template, error) {
+    byName := map[string]*Template{}
+    for _, dir := range dirs {
+        err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+            if err != nil {
+                if errors.Is(err, fs.ErrNotExist) {
+                    return fs.SkipDir
+                }
+                return err
+            }
+            if d.IsDir() {
+                if d.Name() == ".git" {
+                    return fs.SkipDir
+                }
+                return nil
+            }
+            if !strings.HasSuffix(p, Ext) {
+                return nil
+            }
+            rel, err := filepath.Rel(dir, p)
+            if err != nil {
+                return err
+            }
+            name := strings.TrimSuffix(filepath.ToSlash(rel), Ext)
+            if byName[name] != nil {
+                return nil
+            }
+            t, err := Load(p, name)
+            if err != nil {
+                return err
+            }
+            byName[name] = t
+            return nil
+        })
+        if err != nil {
+            return nil, err
+        }
+    }
+    out := make([]*Template, 0, len(byName))
+    for _, t := range byName {
+        out = append(out, t)
+    }
+    sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+    return out, nil
+}
+
+// Find 按名字在各目录中查找模板；没有时返回 nil, nil。名字必须是模板目录下的相对路径，
+// 绝对路径或含 .. 的名字返回错误，不会读到模板目录之外的文件
+func Find(dirs []string, name string) (*Template, error) {
+    if !filepath.IsLocal(filepath.FromSlash(name)) || slices.Contains(strings.Split(filepath.ToSlash(name), "/"), "..") {
+        return nil, i18n.Errorf("模板名 %s 无效：必须是模板目录下的相对路径，不能含 ..", name)
+    }
+    for _, dir := range dirs {
+        p := filepath.Join(dir, filepath.FromSlash(name)+Ext)
+        if _, err := os.Stat(p); err == nil {
+            return Load(p, name)
+        }
+    }
+    return nil, nil
+}
+
+// Render 代入参数生成查询：没有给出的参数取默认值；模板声明了 params 时拒绝未声明的参数，
+// 以免拼错的 --var 被悄悄忽略
+func (t *Template) Render(vars map[string]string) (string, error) {
+    data := map[string]string{}
+    declared := map[string]bool{}
+    var missing []string
+    for _, p := range t.Params {
+        declared[p.Name] = true
+        if v, ok := vars[p.Name]; ok {
+            data[p.Name] = v
+        } else if p.Default != "" || !p.Required {
+            data[p.Name] = p.Default
+        } else {
+            missing = append(missing, p.Name)
+        }
+    }
+    for k, v := range vars {
+        if len(t.Params) > 0 && !declared[k] {
+            return "", i18n.Errorf("模板 %s 没有参数 %s（可用：%s）", t.Name, k, strings.Join(t.paramNames(), ", "))
+        }
+        data[k] = v
+    }
+    if len(missing) > 0 {
+        return "", i18n.Errorf("模板 %s 缺少参数：%s（用 --var %s=... 给出）", t.Name, strings.Join(missing, ", "), missing[0])
+    }
+    tmpl, err := template.New(t.Name).Funcs(funcs).Option("missingkey=error").Parse(t.Query)
+    if err != nil {
+        return "", err
+    }
+    for _, tt := range tmpl.Templates() {
+        joinLines(tt.Tree.Root)
+    }
+    var b strings.Builder
+    if err := tmpl.Execute(&b, data); err != nil {
+        return "", i18n.Errorf("渲染模板 %s: %w", t.Name, err)
+    }
+    return strings.TrimSpace(breaks.ReplaceAllString(b.String(), " ")), nil
+}
+
+// lineBreak 是模板文本中的换行连同两侧的空白；joinLines 先把它换成 breakMark，渲染后再把
+// 相邻的 breakMark（如 {{end}} 前后各一个换行）合成一个空格
+var (
+    lineBreak = regexp.MustCompile(`[ \t\r]*\n\s*`)
+    breaks    = regexp.MustCompile("[ \t]*\x00[\x00 \t]*")
+)
+
+const breakMark = "\x00"
+
+// joinLines 把模板文本自身的换行（连同两侧的空白）换成 breakMark，多行写的模板得到单行查询；
+// 代入的参数值原样保留，其中的连续空格（如 "a  b" 字面量）不受影响
+func joinLines(n parse.Node) {
+    switch n := n.(type) {
+    case *parse.ListNode:
+        if n == nil {
+            return
+        }
+        for _, c := range n.Nodes {
+            joinLines(c)
+        }
+    case *parse.TextNode:
+        n.Text = lineBreak.ReplaceAll(n.Text, []byte(breakMark))
+    case *parse.IfNode:
+        joinLines(n.List)
+        joinLines(n.ElseList)
+    case *parse.RangeNode:
+        joinLines(n.List)
+        joinLines(n.ElseList)
+    case *parse.WithNode:
+        joinLines(n.List)
+        joinLines(n.ElseList)
+    }
+}
+
+func (t *Template) paramNames() []string {
+    names := make([]string, len(t.Params))
+    for i, p := range t.Params {
+        names[i] = p.Name
+    }
+    return names
+}
@@ -0,0 +1,53 @@
+package tracing
+
+import (
+    "context"
+    "os"
+
+    "go.opentelemetry.io/otel"
+    "go.opentelemetry.io/otel/attribute"
+    "go.opentelemetry.io/otel/codes"
+    "go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+    "go.opentelemetry.io/otel/propagation"
+    "go.opentelemetry.io/otel/sdk/resource"
+    sdktrace "go.opentelemetry.io/otel/sdk/trace"
+    semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+    "go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("kingbrain/insight")
+
+// Init 在设置了 OTEL_EXPORTER_OTLP_ENDPOINT / OTEL_EXPORTER_OTLP_TRACES_ENDPOINT 时
+// 启用 OTLP/HTTP 导出（其余 OTEL_* 环境变量由 SDK 自行读取）；未设置时保持 no-op。
+// 返回的 shutdown 负责在退出前把未发送的 span 刷出去。
+func Init(ctx context.Context) (func(context.Context) error, error) {
+    if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" && os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") == "" {
+        return func(context.Context) error { return nil }, nil
+    }
+    exp, err := otlptracehttp.New(ctx)
+    if err != nil {
+        return nil, err
+    }
+    res, err := resource.Merge(resource.Default(), resource.NewSchemaless(semconv.ServiceName("insight")))
+    if err != nil {
+        return nil, err
+    }
+    tp := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exp), sdktrace.WithResource(res))
+    otel.SetTracerProvider(tp)
+    otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+    return tp.Shutdown, nil
+}
+
+// Start 开启一个子 span
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+    return tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End 结束 span，err 非空时记录为错误状态
+func End(span trace.Span, err error) {
+    if err != nil {
+        span.RecordError(err)
+        span.SetStatus(codes.Error, err.Error())
+    }
+    span.End()
+}
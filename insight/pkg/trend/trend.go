@@ -0,0 +1,212 @@
+// Package trend 把 scc 的统计按 仓库 + 目录 + revision 存进 SQLite，供 kb scc trend 画出
+// 代码行数与复杂度随时间的变化。同一 revision 重复记录时覆盖；与上一个数据点完全相同的
+// 统计不再记录，CI 里每次构建都记录也不会堆出大量重复的点。
+package trend
+
+import (
+    "database/sql"
+    "os"
+    "path/filepath"
+    "time"
+
+    _ "modernc.org/sqlite"
+)
+
+const schema = `
+CREATE TABLE IF NOT EXISTS points (
+    id           INTEGER PRIMARY KEY,
+    repo         TEXT NOT NULL,
+    dir          TEXT NOT NULL,
+    rev          TEXT NOT NULL,
+    committed_at TIMESTAMP NOT NULL,
+    recorded_at  TIMESTAMP NOT NULL,
+    UNIQUE (repo, dir, rev)
+);
+CREATE TABLE IF NOT EXISTS languages (
+    point_id   INTEGER NOT NULL REFERENCES points(id),
+    language   TEXT NOT NULL,
+    files      INTEGER,
+    lines      INTEGER,
+    code       INTEGER,
+    comments   INTEGER,
+    blanks     INTEGER,
+    complexity INTEGER,
+    PRIMARY KEY (point_id, language)
+);
+`
+
+// Stats 是一种语言（或合计）的统计
+type Stats struct {
+    Language   string `json:"language,omitempty"`
+    Files      int    `json:"files"`
+    Lines      int    `json:"lines"`
+    Code       int    `json:"code"`
+    Comments   int    `json:"comments"`
+    Blanks     int    `json:"blanks"`
+    Complexity int    `json:"complexity"`
+}
+
+func (s *Stats) add(o Stats) {
+    s.Files += o.Files
+    s.Lines += o.Lines
+    s.Code += o.Code
+    s.Comments += o.Comments
+    s.Blanks += o.Blanks
+    s.Complexity += o.Complexity
+}
+
+// Point 是一次记录：Committed 为 revision 的提交时间，趋势按它排序
+type Point struct {
+    Repo      string    `json:"repo"`
+    Dir       string    `json:"dir"`
+    Rev       string    `json:"rev"`
+    Committed time.Time `json:"committed"`
+    Recorded  time.Time `json:"recorded"`
+    Total     Stats     `json:"total"`
+    Languages []Stats   `json:"languages,omitempty"`
+}
+
+// DB 是趋势库
+type DB struct{ db *sql.DB }
+
+// DefaultPath 返回默认的库路径：<用户缓存目录>/insight/scc-trend.db
+func DefaultPath() (string, error) {
+    dir, err := os.UserCacheDir()
+    if err != nil {
+        return "", err
+    }
+    return filepath.Join(dir, "insight", "scc-trend.db"), nil
+}
+
+// Open 打开趋势库，库与表不存在时自动创建
+func Open(path string) (*DB, error) {
+    if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+        return nil, err
+    }
+    db, err := sql.Open("sqlite", path)
+    if err != nil {
+        return nil, err
+    }
+    if _, err := db.Exec(schema); err != nil {
+        db.Close()
+        return nil, err
+    }
+    return &DB{db}, nil
+}
+
+// Close 关闭趋势库
+func (d *DB) Close() error { return d.db.Close() }
+
+// Record 保存一个数据点；返回 false 表示与该仓库目录最近的数据点（另一个 revision）统计完全相同，
+// 没有记录
+func (d *DB) Record(p Point) (bool, error) {
+    prev, err := d.Series(p.Repo, p.Dir, "")
+    if err != nil {
+        return false, err
+    }
+    if n := len(prev); n > 0 && prev[n-1].Rev != p.Rev && sameLanguages(prev[n-1].Languages, p.Languages) {
+        return false, nil
+    }
+
+    tx, err := d.db.Begin()
+    if err != nil {
+        return false, err
+    }
+    defer tx.Rollback()
+    for _, q := range []string{
+        `DELETE FROM languages WHERE point_id IN (SELECT id FROM points WHERE repo = ? AND dir = ? AND rev = ?)`,
+        `DELETE FROM points WHERE repo = ? AND dir = ? AND rev = ?`,
+    } {
+        if _, err := tx.Exec(q, p.Repo, p.Dir, p.Rev); err != nil {
+            return false, err
+        }
+    }
+    res, err := tx.Exec(`INSERT INTO points (repo, dir, rev, committed_at, recorded_at) VALUES (?, ?, ?, ?, ?)`,
+        p.Repo, p.Dir, p.Rev, p.Committed.UTC(), p.Recorded.UTC())
+    if err != nil {
+        return false, err
+    }
+    id, _ := res.LastInsertId()
+    for _, l := range p.Languages {
+        if _, err := tx.Exec(`INSERT INTO languages (point_id, language, files, lines, code, comments, blanks, complexity) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+            id, l.Language, l.Files, l.Lines, l.Code, l.Comments, l.Blanks, l.Complexity); err != nil {
+            return false, err
+        }
+    }
+    return true, tx.Commit()
+}
+
+// Series 按提交时间从旧到新返回仓库目录的全部数据点；language 非空时 Total 只算该语言
+func (d *DB) Series(repo, dir, language string) ([]Point, error) {
+    rows, err := d.db.Query(`
+SELECT p.id, p.rev, p.committed_at, p.recorded_at, l.language, l.files, l.lines, l.code, l.comments, l.blanks, l.complexity
+FROM points p LEFT JOIN languages l ON l.point_id = p.id
+WHERE p.repo = ? AND p.dir = ?
+ORDER BY p.committed_at, p.id, l.language`, repo, dir)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+    var out []Point
+    last := int64(-1)
+    for rows.Next() {
+        var (
+            id   int64
+            p    Point
+            lang sql.NullString
+            n    [6]sql.NullInt64
+        )
+        if err := rows.Scan(&id, &p.Rev, &p.Committed, &p.Recorded, &lang, &n[0], &n[1], &n[2], &n[3], &n[4], &n[5]); err != nil {
+            return nil, err
+        }
+        if id != last {
+            p.Repo, p.Dir = repo, dir
+            out = append(out, p)
+            last = id
+        }
+        if !lang.Valid {
+            continue
+        }
+        s := Stats{lang.String, int(n[0].Int64), int(n[1].Int64), int(n[2].Int64), int(n[3].Int64), int(n[4].Int64), int(n[5].Int64)}
+        cur := &out[len(out)-1]
+        cur.Languages = append(cur.Languages, s)
+        if language == "" || language == s.Language {
+            cur.Total.add(s)
+        }
+    }
+    return out, rows.Err()
+}
+
+// Targets 列出库中已有的 仓库/目录，用于提示
+func (d *DB) Targets() ([][2]string, error) {
+    rows, err := d.db.Query(`SELECT DISTINCT repo, dir FROM points ORDER BY repo, dir`)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+    var out [][2]string
+    for rows.Next() {
+        var t [2]string
+        if err := rows.Scan(&t[0], &t[1]); err != nil {
+            return nil, err
+        }
+        out = append(out, t)
+    }
+    return out, rows.Err()
+}
+
+func sameLanguages(a, b []Stats) bool {
+    if len(a) != len(b) {
+        return false
+    }
+    m := make(map[string]Stats, len(a))
+    for _, s := range a {
+        m[s.Language] = s
+    }
+    for _, s := range b {
+        if m[s.Language] != s {
+            return false
+        }
+    }
+    return true
+}
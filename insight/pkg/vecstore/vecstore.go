@@ -0,0 +1,147 @@
+package vecstore
+
+import (
+    "encoding/json"
+    "errors"
+    "math"
+    "os"
+    "path/filepath"
+    "sort"
+    "time"
+)
+
+// Chunk 是一段已向量化的代码，行号从 1 开始（含两端）
+type Chunk struct {
+    Repo   string    `json:"repo"`
+    Path   string    `json:"path"`
+    Start  int       `json:"start"`
+    End    int       `json:"end"`
+    Name   string    `json:"name,omitempty"` // 所在函数名，按行窗口切分时为空
+    Text   string    `json:"text"`
+    Hash   string    `json:"hash"` // 仓库、路径与内容的摘要，用于增量索引时去重
+    Vector []float32 `json:"vector,omitempty"`
+}
+
+// Hit 是一条检索结果，Score 为余弦相似度
+type Hit struct {
+    Chunk
+    Score float32 `json:"score"`
+}
+
+// Store 是本地的向量索引；同一个索引只能使用同一个 embedding 模型
+type Store struct {
+    Model   string    `json:"model"`
+    Updated time.Time `json:"updated"`
+    Chunks  []Chunk   `json:"chunks"`
+
+    byHash map[string]bool
+}
+
+// Path 返回索引文件路径：<用户缓存目录>/insight/semantic.json
+func Path() (string, error) {
+    dir, err := os.UserCacheDir()
+    if err != nil {
+        return "", err
+    }
+    return filepath.Join(dir, "insight", "semantic.json"), nil
+}
+
+// Load 读取索引；不存在时返回空索引
+func Load() (*Store, error) {
+    p, err := Path()
+    if err != nil {
+        return nil, err
+    }
+    s := &Store{}
+    b, err := os.ReadFile(p)
+    if errors.Is(err, os.ErrNotExist) {
+        return s, nil
+    }
+    if err != nil {
+        return nil, err
+    }
+    if err := json.Unmarshal(b, s); err != nil {
+        return nil, err
+    }
+    return s, nil
+}
+
+// Save 先写临时文件再 rename
+func (s *Store) Save() error {
+    p, err := Path()
+    if err != nil {
+        return err
+    }
+    if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+        return err
+    }
+    s.Updated = time.Now().UTC()
+    b, err := json.Marshal(s)
+    if err != nil {
+        return err
+    }
+    tmp := p + ".tmp"
+    if err := os.WriteFile(tmp, b, 0o644); err != nil {
+        return err
+    }
+    return os.Rename(tmp, p)
+}
+
+// Has 表示索引中是否已有该摘要的片段
+func (s *Store) Has(hash string) bool {
+    if s.byHash == nil {
+        s.byHash = make(map[string]bool, len(s.Chunks))
+        for _, c := range s.Chunks {
+            s.byHash[c.Hash] = true
+        }
+    }
+    return s.byHash[hash]
+}
+
+// Add 加入新片段，已有相同摘要的跳过；向量在写入前归一化，检索时点积即余弦相似度
+func (s *Store) Add(chunks ...Chunk) {
+    for _, c := range chunks {
+        if s.Has(c.Hash) {
+            continue
+        }
+        normalize(c.Vector)
+        s.Chunks = append(s.Chunks, c)
+        s.byHash[c.Hash] = true
+    }
+}
+
+// Search 返回与 vec 最相似的 k 个片段，按相似度降序
+func (s *Store) Search(vec []float32, k int) []Hit {
+    q := append([]float32(nil), vec...)
+    normalize(q)
+    hits := make([]Hit, 0, len(s.Chunks))
+    for _, c := range s.Chunks {
+        if len(c.Vector) != len(q) {
+            continue
+        }
+        var dot float32
+        for i, v := range c.Vector {
+            dot += v * q[i]
+        }
+        hits = append(hits, Hit{Chunk: c, Score: dot})
+    }
+    sort.Slice(hits, func(i, j int) bool { return hits[i].Score > hits[j].Score })
+    if len(hits) > k {
+        hits = hits[:k]
+    }
+    return hits
+}
+
+func normalize(v []float32) {
+    var sum float64
+    for _, x := range v {
+        sum += float64(x) * float64(x)
+    }
+    if sum == 0 {
+        return
+    }
+    n := float32(1 / math.Sqrt(sum))
+    for i := range v {
+        v[i] *= n
+    }
+}
@@ -0,0 +1,58 @@
+package version
+
+import (
+    "fmt"
+    "runtime"
+    "runtime/debug"
+)
+
+// 构建时通过 -ldflags -X 注入（见 Makefile 的 kb 目标），未注入时为 dev
+var (
+    Version = "dev"
+    Commit  = ""
+    Date    = ""
+)
+
+// Info 是版本命令与 self-update 使用的构建信息
+type Info struct {
+    Version string `json:"version"`
+    Commit  string `json:"commit,omitempty"`
+    Date    string `json:"date,omitempty"`
+    Go      string `json:"go"`
+    OS      string `json:"os"`
+    Arch    string `json:"arch"`
+}
+
+// Get 返回构建信息；未注入时回退到 go build 自动记录的 vcs 信息
+func Get() Info {
+    info := Info{Version: Version, Commit: Commit, Date: Date, Go: runtime.Version(), OS: runtime.GOOS, Arch: runtime.GOARCH}
+    if bi, ok := debug.ReadBuildInfo(); ok {
+        for _, s := range bi.Settings {
+            switch {
+            case s.Key == "vcs.revision" && info.Commit == "":
+                info.Commit = s.Value
+            case s.Key == "vcs.time" && info.Date == "":
+                info.Date = s.Value
+            case s.Key == "vcs.modified" && s.Value == "true" && Version == "dev":
+                info.Version = "dev-dirty"
+            }
+        }
+    }
+    return info
+}
+
+func (i Info) String() string {
+    commit := i.Commit
+    if len(commit) > 12 {
+        commit = commit[:12]
+    }
+    s := i.Version
+    if commit != "" {
+        s += " (" + commit
+        if i.Date != "" {
+            s += ", " + i.Date
+        }
+        s += ")"
+    }
+    return fmt.Sprintf("%s %s %s/%s", s, i.Go, i.OS, i.Arch)
+}